@@ -2,6 +2,7 @@ package api
 
 import (
 	"errors"
+	"github.com/WeBankPartners/wecube-platform/platform-auth-server/api/middleware"
 	"github.com/WeBankPartners/wecube-platform/platform-auth-server/api/support"
 	"github.com/WeBankPartners/wecube-platform/platform-auth-server/common/constant"
 	"github.com/WeBankPartners/wecube-platform/platform-auth-server/model"
@@ -13,7 +14,7 @@ import (
 func Login(c *gin.Context) {
 	var credential model.CredentialDto
 	if c.ShouldBindJSON(&credential) == nil {
-		if authResp, err := service.AuthServiceInstance.Login(&credential, false); err == nil {
+		if authResp, err := service.AuthServiceInstance.Login(&credential, false, middleware.GetTraceparent(c)); err == nil {
 			setupTokenHeaders(authResp.Tokens, c)
 			support.ReturnData(c, authResp.Tokens)
 		} else {
@@ -27,7 +28,7 @@ func Login(c *gin.Context) {
 func TaskLogin(c *gin.Context) {
 	var credential model.CredentialDto
 	if c.ShouldBindJSON(&credential) == nil {
-		if authResp, err := service.AuthServiceInstance.Login(&credential, true); err == nil {
+		if authResp, err := service.AuthServiceInstance.Login(&credential, true, middleware.GetTraceparent(c)); err == nil {
 			setupTokenHeaders(authResp.Tokens, c)
 			support.ReturnData(c, authResp)
 		} else {
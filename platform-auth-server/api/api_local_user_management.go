@@ -262,7 +262,7 @@ func UpdateRoleApply(c *gin.Context) {
 	}
 
 	curUser := middleware.GetRequestUser(c)
-	err := service.UserManagementServiceInstance.UpdateRoleApply(param, curUser)
+	err := service.UserManagementServiceInstance.UpdateRoleApply(param, curUser, middleware.GetTraceparent(c))
 	if err != nil {
 		support.ReturnError(c, err)
 	} else {
@@ -26,7 +26,7 @@ func HttpLogHandle() gin.HandlerFunc {
 		}
 		c.Next()
 		costTime := time.Since(start).Seconds() * 1000
-		log.AccessLogger.Info("Got request -", log.String("url", c.Request.RequestURI), log.String("method", c.Request.Method), log.Int("code", c.Writer.Status()), log.String("operator", c.GetString("user")), log.String("ip", getRemoteIp(c)), log.Float64("cost_ms", costTime), log.String("body", c.GetString("responseBody")))
+		log.AccessLogger.Info("Got request -", log.String("url", c.Request.RequestURI), log.String("method", c.Request.Method), log.Int("code", c.Writer.Status()), log.String("operator", c.GetString("user")), log.String("ip", getRemoteIp(c)), log.Float64("cost_ms", costTime), log.String("traceparent", GetTraceparent(c)), log.String("body", c.GetString("responseBody")))
 
 	}
 }
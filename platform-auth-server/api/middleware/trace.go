@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 按 W3C trace-context 透传 traceparent,auth server 不上报span,只保证链路不断开
+
+const (
+	TraceparentHeader = "traceparent"
+	traceparentKey    = "traceparent"
+)
+
+// TraceHandle 沿用上游的 trace-id 生成本服务的 parent-id,没有或格式不对时新开一条链路,并在响应头中返回
+func TraceHandle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		traceparent := buildTraceparent(c.GetHeader(TraceparentHeader))
+		c.Set(traceparentKey, traceparent)
+		c.Header(TraceparentHeader, traceparent)
+		c.Next()
+	}
+}
+
+// GetTraceparent 本次请求的traceparent,调用其它服务时放到请求头中
+func GetTraceparent(c *gin.Context) string {
+	return c.GetString(traceparentKey)
+}
+
+func buildTraceparent(upstream string) string {
+	parts := strings.Split(strings.TrimSpace(upstream), "-")
+	if len(parts) == 4 && parts[0] == "00" && isTraceHex(parts[1], 32) && isTraceHex(parts[2], 16) && isTraceHex(parts[3], 2) {
+		return "00-" + parts[1] + "-" + newTraceHex(8) + "-" + parts[3]
+	}
+	return "00-" + newTraceHex(16) + "-" + newTraceHex(8) + "-01"
+}
+
+func isTraceHex(input string, length int) bool {
+	if len(input) != length || strings.Trim(input, "0") == "" {
+		return false
+	}
+	_, err := hex.DecodeString(input)
+	return err == nil && input == strings.ToLower(input)
+}
+
+func newTraceHex(byteLen int) string {
+	b := make([]byte, byteLen)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
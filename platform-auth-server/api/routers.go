@@ -33,6 +33,7 @@ func NewRouter() *gin.Engine {
 	//redirectRoutes := buildRedirectRoutes()
 	//httpHandlerFuncList = append(httpHandlerFuncList, redirectRoutes...)
 	router := gin.Default()
+	router.Use(middleware.TraceHandle())
 
 	if model.Config.Log.AccessLogEnable {
 		router.Use(middleware.HttpLogHandle())
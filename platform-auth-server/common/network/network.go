@@ -30,8 +30,8 @@ func HttpGet(url, userToken, language string) (byteArr []byte, err error) {
 	return
 }
 
-// HttpPost Post请求,关注返回结果
-func HttpPost(url, userToken, language string, postBytes []byte) (byteArr []byte, err error) {
+// HttpPost Post请求,关注返回结果,traceparent不为空时透传给下游
+func HttpPost(url, userToken, language, traceparent string, postBytes []byte) (byteArr []byte, err error) {
 	req, reqErr := http.NewRequest(http.MethodPost, url, bytes.NewReader(postBytes))
 	if reqErr != nil {
 		err = fmt.Errorf("new http reqeust fail,%s ", reqErr.Error())
@@ -39,6 +39,9 @@ func HttpPost(url, userToken, language string, postBytes []byte) (byteArr []byte
 	}
 	req.Header.Set("Authorization", userToken)
 	req.Header.Set("Accept-Language", language)
+	if traceparent != "" {
+		req.Header.Set("traceparent", traceparent)
+	}
 	resp, respErr := http.DefaultClient.Do(req)
 	if respErr != nil {
 		err = fmt.Errorf("do http reqeust fail,%s ", respErr.Error())
@@ -54,7 +54,7 @@ func (AuthService) InitKey() error {
 	return nil
 }
 
-func (AuthService) Login(credential *model.CredentialDto, taskLogin bool) (*model.AuthenticationResponse, error) {
+func (AuthService) Login(credential *model.CredentialDto, taskLogin bool, traceparent string) (*model.AuthenticationResponse, error) {
 
 	if err := validateCredential(credential); err != nil {
 		return nil, err
@@ -68,7 +68,7 @@ func (AuthService) Login(credential *model.CredentialDto, taskLogin bool) (*mode
 		}
 
 	} else {
-		if authResp, err := authenticateUser(credential, taskLogin); err != nil {
+		if authResp, err := authenticateUser(credential, taskLogin, traceparent); err != nil {
 			return nil, err
 		} else {
 			return authResp, nil
@@ -237,7 +237,7 @@ func RSADecryptByPublic(encryptString, publicKeyContent string) ([]byte, error)
 	return out[skip:], nil
 }
 
-func authenticateUser(credential *model.CredentialDto, taskLogin bool) (*model.AuthenticationResponse, error) {
+func authenticateUser(credential *model.CredentialDto, taskLogin bool, traceparent string) (*model.AuthenticationResponse, error) {
 	username := credential.Username
 	if isBlank(username) {
 		log.Logger.Debug("blank user name")
@@ -253,7 +253,7 @@ func authenticateUser(credential *model.CredentialDto, taskLogin bool) (*model.A
 		log.Logger.Debug("User does not exist", log.String("username", username))
 		if taskLogin {
 			// 检查UM用户是否存在
-			umExists, err := checkUmUserExists(credential)
+			umExists, err := checkUmUserExists(credential, traceparent)
 			if err != nil {
 				return nil, err
 			}
@@ -409,7 +409,7 @@ func checkAuthentication(user *model.SysUser, credential *model.CredentialDto) e
 	return nil
 }
 
-func GetUmAuthContext(username string) (string, error) {
+func GetUmAuthContext(username, traceparent string) (string, error) {
 	// 生成token
 	accessToken, _, err := buildAccessToken(username, []string{}, []string{}, false)
 	if err != nil {
@@ -430,7 +430,7 @@ func GetUmAuthContext(username string) (string, error) {
 			PageSize:   10,
 		},
 	}
-	queryResult, err := api_platform.QuerySystemVariables(accessToken, "", queryParam)
+	queryResult, err := api_platform.QuerySystemVariables(accessToken, "", traceparent, queryParam)
 	if err != nil {
 		return "", err
 	}
@@ -440,8 +440,8 @@ func GetUmAuthContext(username string) (string, error) {
 	return queryResult.Contents[0].DefaultValue, nil
 }
 
-func checkUmUserExists(credential *model.CredentialDto) (bool, error) {
-	umAuthCtx, err := GetUmAuthContext(credential.Username)
+func checkUmUserExists(credential *model.CredentialDto, traceparent string) (bool, error) {
+	umAuthCtx, err := GetUmAuthContext(credential.Username, traceparent)
 	if err != nil {
 		return false, err
 	}
@@ -14,12 +14,12 @@ const (
 )
 
 // QuerySystemVariables 查询系统参数
-func QuerySystemVariables(userToken, language string, param *model.QueryRequestParam) (result *model.PlatSystemVariablesListPageData, err error) {
+func QuerySystemVariables(userToken, language, traceparent string, param *model.QueryRequestParam) (result *model.PlatSystemVariablesListPageData, err error) {
 	postBytes, err := json.Marshal(param)
 	if err != nil {
 		return
 	}
-	byteArr, err := network.HttpPost(model.Config.RemoteConfig.PlatformUrl+pathQuerySystemVariables, userToken, language, postBytes)
+	byteArr, err := network.HttpPost(model.Config.RemoteConfig.PlatformUrl+pathQuerySystemVariables, userToken, language, traceparent, postBytes)
 	if err != nil {
 		return
 	}
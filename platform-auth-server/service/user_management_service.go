@@ -1131,7 +1131,7 @@ func (UserManagementService) ListRoleApplyByApplier(ctx context.Context, param *
 	return result, err
 }
 
-func (UserManagementService) UpdateRoleApply(param []*model.RoleApplyDto, curUser string) error {
+func (UserManagementService) UpdateRoleApply(param []*model.RoleApplyDto, curUser, traceparent string) error {
 	if len(param) == 0 {
 		return nil
 	}
@@ -1163,7 +1163,7 @@ func (UserManagementService) UpdateRoleApply(param []*model.RoleApplyDto, curUse
 		adminRoleIdMap[adminRole.ID] = nil
 	}
 
-	umAuthCtx, err := GetUmAuthContext(curUser)
+	umAuthCtx, err := GetUmAuthContext(curUser, traceparent)
 	if err != nil {
 		return err
 	}
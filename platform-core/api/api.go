@@ -2,9 +2,11 @@ package api
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,6 +19,8 @@ import (
 	"github.com/WeBankPartners/wecube-platform/platform-core/api/v1/system"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/metric"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/trace"
 	"github.com/WeBankPartners/wecube-platform/platform-core/models"
 	"github.com/gin-gonic/gin"
 )
@@ -73,6 +77,14 @@ func init() {
 		&handlerFuncObj{Url: "/hosts/:hostIp/next-available-port", Method: "GET", HandlerFunc: plugin.GetHostAvailablePort, ApiCode: "get-available-port"},
 		&handlerFuncObj{Url: "/packages/:pluginPackageId/hosts/:hostIp/ports/:port/instance/launch", Method: "POST", HandlerFunc: plugin.LaunchPlugin, ApiCode: "launch-plugin"},
 		&handlerFuncObj{Url: "/packages/instances/:pluginInstanceId/remove", Method: "DELETE", HandlerFunc: plugin.RemovePlugin, ApiCode: "remove-plugin"},
+		&handlerFuncObj{Url: "/packages/instances/:pluginInstanceId/container", Method: "GET", HandlerFunc: plugin.GetPluginInstanceContainer, ApiCode: "get-plugin-instance-container"},
+		&handlerFuncObj{Url: "/packages/instances/:pluginInstanceId/logs", Method: "GET", HandlerFunc: plugin.GetPluginInstanceLogs, ApiCode: "get-plugin-instance-logs"},
+		&handlerFuncObj{Url: "/packages/instances/health", Method: "GET", HandlerFunc: plugin.GetPluginInstanceHealth, ApiCode: "get-plugin-instance-health"},
+		&handlerFuncObj{Url: "/packages/:pluginPackageId/instances/upgrade", Method: "POST", HandlerFunc: plugin.UpgradePlugin, ApiCode: "upgrade-plugin"},
+		&handlerFuncObj{Url: "/packages/upgrade/:upgradeId", Method: "GET", HandlerFunc: plugin.GetPluginUpgrade, ApiCode: "get-plugin-upgrade"},
+		&handlerFuncObj{Url: "/packages/install-plans/preview", Method: "POST", HandlerFunc: plugin.PreviewPluginInstallPlan, ApiCode: "preview-plugin-install-plan"},
+		&handlerFuncObj{Url: "/packages/install-plans", Method: "POST", HandlerFunc: plugin.ExecPluginInstallPlan, ApiCode: "exec-plugin-install-plan"},
+		&handlerFuncObj{Url: "/packages/install-plans/:planId", Method: "GET", HandlerFunc: plugin.GetPluginInstallPlan, ApiCode: "get-plugin-install-plan"},
 		&handlerFuncObj{Url: "/packages/:pluginPackageId/instances", Method: "GET", HandlerFunc: plugin.GetPluginRunningInstances, ApiCode: "get-plugin-running-instance"},
 		&handlerFuncObj{Url: "/packages/name/list", Method: "GET", HandlerFunc: plugin.GetPackageNames, ApiCode: "get-package-names"},
 		&handlerFuncObj{Url: "/packages/:pluginPackageId/resources/s3/files", Method: "GET", HandlerFunc: plugin.GetPluginS3Files, ApiCode: "get-plugin-s3-files"},
@@ -138,6 +150,11 @@ func init() {
 		&handlerFuncObj{Url: "/process/definitions/export", Method: "POST", HandlerFunc: process.ExportProcessDefinition, ApiCode: "process-definition-export"},
 		&handlerFuncObj{Url: "/process/definitions/import", Method: "POST", HandlerFunc: process.ImportProcessDefinition, ApiCode: "import-process-definition"},
 		&handlerFuncObj{Url: "/process/definitions/deploy/:proc-def-id", Method: "POST", HandlerFunc: process.DeployProcessDefinition, ApiCode: "deploy-process-definition"},
+		&handlerFuncObj{Url: "/process/definitions/:proc-def-id/validate", Method: "GET", HandlerFunc: process.ValidateProcessDefinition, ApiCode: "validate-process-definition"},
+		&handlerFuncObj{Url: "/process/definitions/:proc-def-id/sla-statistics", Method: "GET", HandlerFunc: process.GetProcDefSlaStatistics, ApiCode: "process-definition-sla-statistics"},
+		&handlerFuncObj{Url: "/process/definitions/versions", Method: "GET", HandlerFunc: process.GetProcDefVersions, ApiCode: "get-process-definition-versions"},
+		&handlerFuncObj{Url: "/process/definitions/diff", Method: "GET", HandlerFunc: process.DiffProcDefVersion, ApiCode: "diff-process-definition"},
+		&handlerFuncObj{Url: "/process/definitions/:proc-def-id/rollback", Method: "POST", HandlerFunc: process.RollbackProcDefVersion, ApiCode: "rollback-process-definition"},
 		&handlerFuncObj{Url: "/process/definitions/:proc-def-id/tasknodes/briefs", Method: "GET", HandlerFunc: process.GetProcDefRootTaskNode, ApiCode: "get-process-definition-root-nodes"},
 		&handlerFuncObj{Url: "/process/definitions/tasknodes", Method: "POST", HandlerFunc: process.AddOrUpdateProcDefTaskNodes, ApiCode: "add-update-process-definition-nodes"},
 		&handlerFuncObj{Url: "/process/definitions/:proc-def-id/tasknodes/:node-id", Method: "DELETE", HandlerFunc: process.DeleteProcDefNode, ApiCode: "delete-process-definition-nodes"},
@@ -166,15 +183,24 @@ func init() {
 		&handlerFuncObj{Url: "/process/instances", Method: "GET", HandlerFunc: process.ProcInsList, ApiCode: "process-ins-list"},
 		&handlerFuncObj{Url: "/process/instances/:procInsId", Method: "GET", HandlerFunc: process.ProcInsDetail, ApiCode: "process-ins-detail"},
 		&handlerFuncObj{Url: "/process/instances/:procInsId/tasknodes/:procInsNodeId/context", Method: "GET", HandlerFunc: process.GetProcInsNodeContext, ApiCode: "process-ins-node-context"},
+		&handlerFuncObj{Url: "/process/instances/:procInsId/tasknodes/:procInsNodeId/iterations", Method: "GET", HandlerFunc: process.GetProcInsNodeIterations, ApiCode: "process-ins-node-iterations"},
+		&handlerFuncObj{Url: "/process/instances/:procInsId/tasknodes/:procInsNodeId/approval-votes", Method: "POST", HandlerFunc: process.SubmitApprovalVote, ApiCode: "process-ins-node-approval-vote"},
+		&handlerFuncObj{Url: "/process/instances/:procInsId/sla-breaches", Method: "GET", HandlerFunc: process.GetProcInsSlaBreaches, ApiCode: "process-ins-sla-breaches"},
+		&handlerFuncObj{Url: "/process/human-tasks/pending", Method: "GET", HandlerFunc: process.GetPendingHumanTasks, ApiCode: "human-task-pending"},
+		&handlerFuncObj{Url: "/process/human-tasks/:taskId/reassign", Method: "POST", HandlerFunc: process.ReassignHumanTask, ApiCode: "human-task-reassign"},
+		&handlerFuncObj{Url: "/process/human-tasks/:taskId/delegate", Method: "POST", HandlerFunc: process.DelegateHumanTask, ApiCode: "human-task-delegate"},
+		&handlerFuncObj{Url: "/process/human-tasks/:taskId/logs", Method: "GET", HandlerFunc: process.GetHumanTaskLogs, ApiCode: "human-task-logs"},
 		&handlerFuncObj{Url: "/process/instances/:procInsId/tasknodes/:procInsNodeId/tasknode-bindings", Method: "POST", HandlerFunc: process.ProcInsNodeRetry, ApiCode: "process-ins-node-retry"},
 		&handlerFuncObj{Url: "/process/instances/:procInsId/tasknodes/:procInsNodeId/tasknode-bindings", Method: "GET", HandlerFunc: process.GetProcInsTaskNodeBindings, ApiCode: "get-process-ins-node-retry"},
 		&handlerFuncObj{Url: "/process/instances/:procInsId/tasknode-bindings", Method: "GET", HandlerFunc: process.GetInstanceTaskNodeBindings, ApiCode: "get-process-ins-binding"},
 		&handlerFuncObj{Url: "/process/instances/:procInsId/preview/entities", Method: "GET", HandlerFunc: process.GetProcInsPreview, ApiCode: "get-ins-preview"},
 		&handlerFuncObj{Url: "/public/process/instances/:procInsId/terminations", Method: "POST", HandlerFunc: process.ProcTermination, ApiCode: "process-ins-terminations"},
 		&handlerFuncObj{Url: "/process/instances/proceed", Method: "POST", HandlerFunc: process.ProcInsOperation, ApiCode: "proc-ins-operation"},
+		&handlerFuncObj{Url: "/process/operations/:operationId/notify", Method: "POST", HandlerFunc: process.NotifyProcOperation, ApiCode: "proc-operation-notify"},
 		&handlerFuncObj{Url: "/packages/:pluginPackageId/entities/:entityName/query", Method: "POST", HandlerFunc: process.ProcEntityDataQuery, ApiCode: "proc-ins-operation"},
 		&handlerFuncObj{Url: "/process/instances/callback", Method: "POST", HandlerFunc: process.ProcInstanceCallback, ApiCode: "proc-ins-callback"},
 		&handlerFuncObj{Url: "/process/instancesWithPaging", Method: "POST", HandlerFunc: process.QueryProcInsPageData, ApiCode: "proc-ins-page-data"},
+		&handlerFuncObj{Url: "/process/instances/search", Method: "POST", HandlerFunc: process.SearchProcIns, ApiCode: "proc-ins-search"},
 		&handlerFuncObj{Url: "/operation-events", Method: "POST", HandlerFunc: process.ProcStartEvents, ApiCode: "proc-start-events"},
 		&handlerFuncObj{Url: "/public/process/definitions/:proc-def-id/options/:proc-node-def-id", Method: "GET", HandlerFunc: process.GetProcNodeAllowOptions, ApiCode: "get-proc-node-options"},
 
@@ -196,6 +222,17 @@ func init() {
 		&handlerFuncObj{Url: "/batch-execution/list", Method: "POST", HandlerFunc: batch_execution.RetrieveBatchExec, ApiCode: "retrieve-batch-execution"},
 		&handlerFuncObj{Url: "/batch-execution/:batchExecId", Method: "GET", HandlerFunc: batch_execution.GetBatchExec, ApiCode: "get-batch-execution"},
 		&handlerFuncObj{Url: "/batch-execution/job/run", Method: "POST", HandlerFunc: batch_execution.RunJob, ApiCode: "run-batch-execution-job"},
+		&handlerFuncObj{Url: "/batch-execution/:batchExecId/progress", Method: "GET", HandlerFunc: batch_execution.GetBatchExecProgress, ApiCode: "get-batch-execution-progress"},
+		&handlerFuncObj{Url: "/batch-execution/:batchExecId/cancel", Method: "POST", HandlerFunc: batch_execution.CancelBatchExec, ApiCode: "cancel-batch-execution"},
+		&handlerFuncObj{Url: "/batch-execution/:batchExecId/retry", Method: "POST", HandlerFunc: batch_execution.RetryBatchExec, ApiCode: "retry-batch-execution"},
+		&handlerFuncObj{Url: "/batch-execution/:batchExecId/export", Method: "GET", HandlerFunc: batch_execution.ExportBatchExec, ApiCode: "export-batch-execution"},
+
+		&handlerFuncObj{Url: "/batch-execution/schedules/query", Method: "POST", HandlerFunc: batch_execution.QueryBatchExecScheduleList, ApiCode: "query-batch-execution-schedule"},
+		&handlerFuncObj{Url: "/batch-execution/schedules/create", Method: "POST", HandlerFunc: batch_execution.CreateBatchExecSchedule, ApiCode: "create-batch-execution-schedule"},
+		&handlerFuncObj{Url: "/batch-execution/schedules/stop", Method: "POST", HandlerFunc: batch_execution.StopBatchExecSchedule, ApiCode: "stop-batch-execution-schedule"},
+		&handlerFuncObj{Url: "/batch-execution/schedules/resume", Method: "POST", HandlerFunc: batch_execution.StartBatchExecSchedule, ApiCode: "resume-batch-execution-schedule"},
+		&handlerFuncObj{Url: "/batch-execution/schedules/delete", Method: "POST", HandlerFunc: batch_execution.DeleteBatchExecSchedule, ApiCode: "delete-batch-execution-schedule"},
+		&handlerFuncObj{Url: "/batch-execution/schedules/:scheduleId/executions", Method: "GET", HandlerFunc: batch_execution.QueryBatchExecScheduleExecList, ApiCode: "query-batch-execution-schedule-exec"},
 
 		// process schedule
 		&handlerFuncObj{Url: "/user-scheduled-tasks/query", Method: "POST", HandlerFunc: process.QueryProcScheduleList, ApiCode: "query_proc_schedule"},
@@ -238,6 +275,11 @@ func InitHttpServer() {
 	}
 	r.GET(models.UrlPrefix+"/v1/route-items", system.GetRouteItems)
 	r.GET(models.UrlPrefix+"/v1/route-items/:name", system.GetRouteItems)
+	if metricPort := models.Config.HttpServer.MetricPort; metricPort != "" && metricPort != models.Config.HttpServer.Port {
+		go startMetricServer(metricPort)
+	} else {
+		r.GET("/metrics", middleware.AuthToken, metricHandle)
+	}
 	r.Run(":" + models.Config.HttpServer.Port)
 }
 
@@ -260,8 +302,26 @@ func httpLogHandle() gin.HandlerFunc {
 			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 			c.Set(models.ContextRequestBody, string(bodyBytes))
 		}
-		log.AccessLogger.Info(fmt.Sprintf("[%s] [%s] ->", requestId, transactionId), log.String("uri", c.Request.RequestURI), log.String("method", c.Request.Method), log.String("sourceIp", getRemoteIp(c)), log.String(models.ContextOperator, c.GetString(models.ContextOperator)), log.String(models.ContextRequestBody, c.GetString(models.ContextRequestBody)))
+		_, span := trace.StartServerSpan(c.Request.Context(), c.Request.Header, fmt.Sprintf("%s %s", c.Request.Method, c.FullPath()))
+		if span != nil {
+			c.Set(trace.ContextSpanKey, span)
+			c.Header(trace.TraceparentHeader, span.Traceparent())
+			span.SetAttribute("http.method", c.Request.Method)
+			span.SetAttribute("http.route", c.FullPath())
+			span.SetAttribute("transaction_id", transactionId)
+		}
+		log.AccessLogger.Info(fmt.Sprintf("[%s] [%s] ->", requestId, transactionId), log.String("uri", c.Request.RequestURI), log.String("method", c.Request.Method), log.String("sourceIp", getRemoteIp(c)), log.String(models.ContextOperator, c.GetString(models.ContextOperator)), log.String("traceId", trace.TraceId(c)), log.String(models.ContextRequestBody, c.GetString(models.ContextRequestBody)))
 		c.Next()
+		span.SetAttribute("http.status_code", strconv.Itoa(c.Writer.Status()))
+		if errorCode := c.GetInt(models.ContextErrorCode); errorCode != 0 {
+			span.SetAttribute("error_code", strconv.Itoa(errorCode))
+			span.End(errors.New(c.GetString(models.ContextErrorMessage)))
+		} else {
+			span.End(nil)
+		}
+		if apiCode := apiCodeMap[c.Request.Method+"_"+strings.TrimPrefix(c.FullPath(), models.UrlPrefix)]; apiCode != "" {
+			metric.HttpRequestDuration.Observe(time.Since(start).Seconds(), apiCode, c.Request.Method, strconv.Itoa(c.Writer.Status()))
+		}
 		costTime := time.Since(start).Seconds() * 1000
 		userId := c.GetString(models.ContextUserId)
 		if log.DebugEnable {
@@ -272,6 +332,25 @@ func httpLogHandle() gin.HandlerFunc {
 	}
 }
 
+// metricHandle 输出prometheus格式的指标
+func metricHandle(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := metric.WriteMetrics(c.Writer); err != nil {
+		log.Logger.Error("write metrics fail", log.Error(err))
+	}
+}
+
+// startMetricServer 监控指标单独端口监听,只对采集网络开放,不走用户鉴权
+func startMetricServer(port string) {
+	r := gin.New()
+	r.Use(gin.CustomRecovery(recoverHandle))
+	r.GET("/metrics", metricHandle)
+	if err := r.Run(":" + port); err != nil {
+		log.Logger.Error("start metric server fail", log.String("port", port), log.Error(err))
+	}
+}
+
 func getRemoteIp(c *gin.Context) string {
 	return c.ClientIP()
 }
@@ -1,14 +1,20 @@
 package batch_execution
 
 import (
-	"encoding/json"
+	"context"
+	"encoding/csv"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/WeBankPartners/wecube-platform/platform-core/api/middleware"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/trace"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/try"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/xlsx"
 	"github.com/WeBankPartners/wecube-platform/platform-core/models"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/execution"
@@ -451,23 +457,13 @@ func doRunJob(c *gin.Context, reqParam *models.BatchExecRun) (result *models.Bat
 	}
 	result.BatchExecId = batchExecId
 
-	execTime := time.Now()
-	errCode := models.BatchExecErrorCodeSucceed
-	errMsg := ""
-	batchExecRunResult, dangerousCheckResult, pluginCallParam, err := execution.BatchExecutionCallPluginService(c, operator, authToken,
+	// 高危检测针对全部实体同步进行,拦截结果需要返回给用户确认
+	dangerousCheckResult, err := execution.BatchExecutionDangerousCheck(c, operator, authToken,
 		pluginInterfaceId, entityType, entityInstances, inputParamConstants, continueToken)
 	if err != nil {
-		errCode = models.BatchExecErrorCodeFailed
-		errMsg = fmt.Sprintf("plugin call error: %s", err.Error())
+		errMsg := fmt.Sprintf("plugin call error: %s", err.Error())
 		log.Logger.Error(errMsg)
-		// update batch exec record，更新批量执行记录
-		updateData := make(map[string]interface{})
-		updateData["error_code"] = errCode
-		updateData["error_message"] = errMsg
-		updateData["updated_by"] = middleware.GetRequestUser(c)
-		updateData["updated_time"] = time.Now()
-		tmpErr = database.UpdateBatchExec(c, batchExecId, updateData)
-		if tmpErr != nil {
+		if tmpErr = updateBatchExecResult(c, batchExecId, models.BatchExecErrorCodeFailed, errMsg); tmpErr != nil {
 			err = tmpErr
 			log.Logger.Error("update batch execution record failed", log.Error(err), log.String("batchExecErrMsg", errMsg))
 			return
@@ -475,70 +471,266 @@ func doRunJob(c *gin.Context, reqParam *models.BatchExecRun) (result *models.Bat
 		err = exterror.New().BatchExecPluginApiError.WithParam(err.Error())
 		return
 	}
-
 	if dangerousCheckResult != nil {
 		result.DangerousCheckResult = dangerousCheckResult
 		log.Logger.Warn("dangerous check result existed", log.JsonObj("dangerousCheckResult", dangerousCheckResult))
-		// if reqParam.IsDangerousBlock {
 		// update batch exec errorCode record，更新批量执行记录
-		errCode = models.BatchExecErrorCodeDangerousBlock
-		errMsg = "dangerous block"
-		updateData := make(map[string]interface{})
-		updateData["error_code"] = errCode
-		updateData["error_message"] = errMsg
-		updateData["updated_by"] = middleware.GetRequestUser(c)
-		updateData["updated_time"] = time.Now()
-		tmpErr = database.UpdateBatchExec(c, batchExecId, updateData)
-		if tmpErr != nil {
+		if tmpErr = updateBatchExecResult(c, batchExecId, models.BatchExecErrorCodeDangerousBlock, "dangerous block"); tmpErr != nil {
 			err = tmpErr
-			log.Logger.Error("plugin call succeed and dangerous block, but update batch execution record failed", log.Error(err))
-			return
+			log.Logger.Error("dangerous block, but update batch execution record failed", log.Error(err))
 		}
-		// }
 		return
 	}
 
-	err = database.InsertBatchExecJobs(c, batchExecId, &execTime, reqParam, pluginCallParam, batchExecRunResult)
+	// 按实体生成待执行纪录,异步分片执行,前端通过进度接口轮询结果
+	jobs, err := database.CreateBatchExecPendingJobs(c, batchExecId, reqParam, time.Now())
 	if err != nil {
-		// update batch exec record，更新批量执行记录
-		errCode = models.BatchExecErrorCodeFailed
-		errMsg = fmt.Sprintf("plugin call succeed, but insert batch execution jobs record failed: %s", err.Error())
-		batchExecRunResultByte, tmpErr := json.Marshal(batchExecRunResult)
-		if tmpErr != nil {
-			errMsg += fmt.Sprintf(" marshal batchExecRunResult failed: %s", tmpErr.Error())
-		} else {
-			errMsg += fmt.Sprintf(" batchExecRunResult: %s", string(batchExecRunResultByte))
+		errMsg := fmt.Sprintf("insert batch execution jobs record failed: %s", err.Error())
+		if tmpErr = updateBatchExecResult(c, batchExecId, models.BatchExecErrorCodeFailed, errMsg); tmpErr != nil {
+			log.Logger.Error("update batch execution record failed", log.Error(tmpErr), log.String("batchExecErrMsg", errMsg))
 		}
-		updateData := make(map[string]interface{})
-		updateData["error_code"] = errCode
-		updateData["error_message"] = errMsg
-		updateData["updated_by"] = middleware.GetRequestUser(c)
-		updateData["updated_time"] = time.Now()
-		tmpErr = database.UpdateBatchExec(c, batchExecId, updateData)
-		if tmpErr != nil {
-			err = tmpErr
-			log.Logger.Error("update batch execution record failed", log.Error(err), log.String("batchExecErrMsg", errMsg))
+		return
+	}
+	if continueToken != "" {
+		// 高危确认后继续执行,状态从拦截改回执行中
+		if err = updateBatchExecResult(c, batchExecId, models.BatchExecErrorCodePending, ""); err != nil {
 			return
 		}
-		log.Logger.Error(fmt.Sprintf("batchExecErrMsg: %s", errMsg))
+	}
+	go execution.StartBatchExecution(buildBatchExecContext(c), &execution.BatchExecRunParam{
+		BatchExecId:   batchExecId,
+		Operator:      operator,
+		ContinueToken: continueToken,
+		ReqParam:      reqParam,
+		Jobs:          jobs,
+	})
+	return
+}
+
+// GetBatchExecProgress 查询批量执行进度
+func GetBatchExecProgress(c *gin.Context) {
+	if _, err := getOwnBatchExec(c); err != nil {
+		middleware.ReturnError(c, err)
 		return
+	}
+	result, err := database.GetBatchExecProgress(c, c.Param("batchExecId"))
+	if err != nil {
+		middleware.ReturnError(c, err)
 	} else {
-		errCode = models.BatchExecErrorCodeSucceed
-		errMsg = ""
-		updateData := make(map[string]interface{})
-		updateData["error_code"] = errCode
-		updateData["error_message"] = errMsg
-		updateData["updated_by"] = middleware.GetRequestUser(c)
-		updateData["updated_time"] = time.Now()
-		tmpErr = database.UpdateBatchExec(c, batchExecId, updateData)
-		if tmpErr != nil {
-			err = tmpErr
-			log.Logger.Error("plugin call succeed, but update batch execution record failed", log.Error(err))
+		middleware.ReturnData(c, result)
+	}
+}
+
+// CancelBatchExec 取消执行中的批量执行,已发出的分片会执行完,后续分片不再执行
+func CancelBatchExec(c *gin.Context) {
+	batchExec, err := getOwnBatchExec(c)
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	ok, err := database.CancelBatchExec(c, batchExec.Id, middleware.GetRequestUser(c), fmt.Sprintf("canceled by %s", middleware.GetRequestUser(c)))
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if !ok {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("batch execution %s is not running", batchExec.Id)))
+		return
+	}
+	execution.CancelBatchExecution(batchExec.Id)
+	middleware.ReturnSuccess(c)
+}
+
+// RetryBatchExec 只重新执行失败和取消未执行的实体
+func RetryBatchExec(c *gin.Context) {
+	batchExec, err := getOwnBatchExec(c)
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if batchExec.ErrorCode != models.BatchExecErrorCodeFailed && batchExec.ErrorCode != models.BatchExecErrorCodeCanceled {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("batch execution %s is not failed or canceled", batchExec.Id)))
+		return
+	}
+	reqParam := batchExec.ConfigData
+	if reqParam == nil || ValidateRunJobParams(reqParam) != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("batch execution %s config data illegal", batchExec.Id)))
+		return
+	}
+	if err = ValidateRunJobPermission(c, middleware.GetRequestRoles(c), reqParam.PluginConfigInterface.PluginConfigId); err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	jobs, err := database.GetBatchExecRetryJobs(c, batchExec.Id)
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if len(jobs) == 0 {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("batch execution %s has no failed entity to retry", batchExec.Id)))
+		return
+	}
+	operator := middleware.GetRequestUser(c)
+	authToken := c.GetHeader(models.AuthorizationHeader)
+	continueToken := c.DefaultQuery(models.ContinueToken, "")
+	var entityInstances []*models.BatchExecutionPluginExecEntityInstances
+	for _, job := range jobs {
+		entityInstances = append(entityInstances, &models.BatchExecutionPluginExecEntityInstances{Id: job.RootEntityId, BusinessKeyValue: job.BusinessKey})
+	}
+	var inputParamConstants []*models.BatchExecutionPluginDefInputParams
+	for _, inputParam := range reqParam.InputParameterDefinitions {
+		inputParamConstants = append(inputParamConstants, &models.BatchExecutionPluginDefInputParams{ParamId: inputParam.InputParameter.Id, ParameValue: inputParam.InputParameterValue})
+	}
+	result := &models.BatchExecRunResp{BatchExecId: batchExec.Id}
+	result.DangerousCheckResult, err = execution.BatchExecutionDangerousCheck(c, operator, authToken, reqParam.PluginConfigInterface.Id, reqParam.DataModelExpression, entityInstances, inputParamConstants, continueToken)
+	if err != nil {
+		middleware.ReturnError(c, exterror.New().BatchExecPluginApiError.WithParam(err.Error()))
+		return
+	}
+	if result.DangerousCheckResult != nil {
+		middleware.ReturnDataWithStatus(c, result, models.DefaultHttpConfirmCode)
+		return
+	}
+	if err = database.ResetBatchExecRetryJobs(c, batchExec.Id, operator, jobs); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
+		return
+	}
+	go execution.StartBatchExecution(buildBatchExecContext(c), &execution.BatchExecRunParam{
+		BatchExecId:   batchExec.Id,
+		Operator:      operator,
+		ContinueToken: continueToken,
+		ReqParam:      reqParam,
+		Jobs:          jobs,
+	})
+	middleware.ReturnData(c, result)
+}
+
+// ExportBatchExec 导出批量执行各实体的输入输出结果,format->csv | xlsx,onlyFailed=true时只导出失败的实体
+func ExportBatchExec(c *gin.Context) {
+	batchExec, err := getAccessibleBatchExec(c)
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("format:%s illegal", format)))
+		return
+	}
+	fileName := fmt.Sprintf("%s-%s.%s", url.PathEscape(batchExec.Name), time.Now().Format("20060102150405"), format)
+	var exportWriter batchExecExportWriter
+	err = execution.ExportBatchExecTable(c, batchExec, c.Query("onlyFailed") == "true", func(row []string) error {
+		if exportWriter == nil {
+			// 第一行写出前才设置下载头,查询失败时还能返回错误信息
+			newWriter, newErr := newBatchExecExportWriter(c, format, fileName)
+			if newErr != nil {
+				return newErr
+			}
+			exportWriter = newWriter
+		}
+		return exportWriter.WriteRow(row)
+	})
+	if err == nil {
+		err = exportWriter.Close()
+	}
+	if err != nil {
+		if exportWriter == nil {
+			middleware.ReturnError(c, err)
 			return
 		}
+		// 已经开始写响应,只能纪录日志
+		log.Logger.Error("export batch execution fail", log.String("batchExecId", batchExec.Id), log.Error(err))
 	}
+}
 
-	result.BatchExecRunResult = batchExecRunResult
-	result.DangerousCheckResult = dangerousCheckResult
+// batchExecExportWriter 导出文件逐行写入,写完必须 Close
+type batchExecExportWriter interface {
+	WriteRow(cells []string) error
+	Close() error
+}
+
+func newBatchExecExportWriter(c *gin.Context, format, fileName string) (batchExecExportWriter, error) {
+	c.Header("Content-Disposition", fmt.Sprintf("attachment;filename=%s", fileName))
+	if format == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		return xlsx.NewWriter(c.Writer, "result")
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	// 带BOM头,excel打开时中文不乱码
+	if _, err := c.Writer.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return nil, err
+	}
+	return &batchExecCsvWriter{csvWriter: csv.NewWriter(c.Writer)}, nil
+}
+
+type batchExecCsvWriter struct {
+	csvWriter *csv.Writer
+}
+
+func (w *batchExecCsvWriter) WriteRow(cells []string) error {
+	escapedCells := make([]string, len(cells))
+	for i, cell := range cells {
+		escapedCells[i] = escapeCsvFormula(cell)
+	}
+	return w.csvWriter.Write(escapedCells)
+}
+
+func (w *batchExecCsvWriter) Close() error {
+	w.csvWriter.Flush()
+	return w.csvWriter.Error()
+}
+
+// escapeCsvFormula excel会把=,+,-,@开头的单元格当成公式执行,插件返回的内容前面加单引号按文本显示
+func escapeCsvFormula(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@", rune(cell[0])) {
+		return cell
+	}
+	// 负数等数字不是公式,保持数字导出
+	if _, err := strconv.ParseFloat(cell, 64); err != nil {
+		return "'" + cell
+	}
+	return cell
+}
+
+func getOwnBatchExec(c *gin.Context) (batchExec *models.BatchExecution, err error) {
+	batchExecId := c.Param("batchExecId")
+	if batchExecId == "" {
+		err = exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("batchExecId cannot be empty"))
+		return
+	}
+	if batchExec, err = database.GetSimpleBatchExec(c, batchExecId); err != nil {
+		return
+	}
+	if batchExec.CreatedBy != middleware.GetRequestUser(c) {
+		err = exterror.New().DataPermissionDeny
+	}
 	return
 }
+
+// getAccessibleBatchExec 查询并校验当前用户能否导出批量执行结果
+func getAccessibleBatchExec(c *gin.Context) (batchExec *models.BatchExecution, err error) {
+	batchExecId := c.Param("batchExecId")
+	if batchExecId == "" {
+		err = exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("batchExecId cannot be empty"))
+		return
+	}
+	if batchExec, err = database.GetSimpleBatchExec(c, batchExecId); err != nil {
+		return
+	}
+	err = database.CheckBatchExecAccess(c, batchExec, middleware.GetRequestUser(c), middleware.GetRequestRoles(c))
+	return
+}
+
+func updateBatchExecResult(c *gin.Context, batchExecId, errCode, errMsg string) error {
+	updateData := make(map[string]interface{})
+	updateData["error_code"] = errCode
+	updateData["error_message"] = errMsg
+	updateData["updated_by"] = middleware.GetRequestUser(c)
+	updateData["updated_time"] = time.Now()
+	return database.UpdateBatchExec(c, batchExecId, updateData)
+}
+
+// buildBatchExecContext 异步执行不能用请求的上下文,保留transactionId和追踪关系
+func buildBatchExecContext(c *gin.Context) context.Context {
+	return context.WithValue(trace.Detach(c), models.TransactionIdHeader, c.GetString(models.TransactionIdHeader))
+}
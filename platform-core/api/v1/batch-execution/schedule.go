@@ -0,0 +1,241 @@
+package batch_execution
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/api/middleware"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/timer"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/execution"
+	"github.com/gin-gonic/gin"
+)
+
+func QueryBatchExecScheduleList(c *gin.Context) {
+	var param models.BatchExecScheduleQueryParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
+		return
+	}
+	result, err := database.QueryBatchExecScheduleList(c, &param, middleware.GetRequestUser(c), middleware.GetRequestRoles(c))
+	if err != nil {
+		middleware.ReturnError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
+
+// CreateBatchExecSchedule 定时执行批量执行模板,需要有模板和插件的使用权限
+func CreateBatchExecSchedule(c *gin.Context) {
+	var param models.CreateBatchExecScheduleParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
+		return
+	}
+	if param.MailMode != "role" && param.MailMode != "user" && param.MailMode != "none" {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("mailMode:%s illegal", param.MailMode)))
+		return
+	}
+	template, err := database.GetTemplate(c, param.BatchExecutionTemplateId)
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if !checkTemplateUsePermission(template, middleware.GetRequestRoles(c)) {
+		middleware.ReturnError(c, exterror.New().DataPermissionDeny)
+		return
+	}
+	if template.ConfigData == nil || ValidateRunJobParams(template.ConfigData) != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("batch execution template %s config data illegal", template.Id)))
+		return
+	}
+	if err = ValidateRunJobPermission(c, middleware.GetRequestRoles(c), template.ConfigData.PluginConfigInterface.PluginConfigId); err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	param.Operator = middleware.GetRequestUser(c)
+	param.TemplateName = template.Name
+	cronExpr, transErr := database.TransScheduleToCronExpr(param.ScheduleMode, param.ScheduleExpr)
+	if transErr != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, transErr))
+		return
+	}
+	param.CronExpr = cronExpr
+	newRow, err := database.CreateBatchExecSchedule(c, &param)
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if err = batchExecScheduleTimer.AddCron(newRow.Id, newRow.CronExpr, handleBatchExecScheduleJob, *newRow); err != nil {
+		err = fmt.Errorf("register cron job:%s fail,%s", newRow.CronExpr, err.Error())
+		if rollbackErr := database.DeleteBatchExecSchedule(c, newRow.Id); rollbackErr != nil {
+			log.Logger.Error("rollback create batch execution schedule config fail", log.String("id", newRow.Id), log.Error(rollbackErr))
+		}
+		middleware.ReturnError(c, err)
+		return
+	}
+	batchExecScheduleConfigMap.Store(newRow.Id, newRow)
+	middleware.ReturnData(c, newRow)
+}
+
+func StartBatchExecSchedule(c *gin.Context) {
+	updateBatchExecScheduleStatus(c, models.ScheduleStatusReady)
+}
+
+func StopBatchExecSchedule(c *gin.Context) {
+	updateBatchExecScheduleStatus(c, models.ScheduleStatusStop)
+}
+
+func DeleteBatchExecSchedule(c *gin.Context) {
+	updateBatchExecScheduleStatus(c, models.ScheduleStatusDelete)
+}
+
+// QueryBatchExecScheduleExecList 定时配置触发过的批量执行,只有创建人和管理角色可以查看
+func QueryBatchExecScheduleExecList(c *gin.Context) {
+	scheduleId := c.Param("scheduleId")
+	if err := database.CheckBatchExecScheduleAccess(c, scheduleId, middleware.GetRequestUser(c), middleware.GetRequestRoles(c)); err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	result, err := database.QueryBatchExecScheduleExecList(c, scheduleId)
+	if err != nil {
+		middleware.ReturnError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
+
+func updateBatchExecScheduleStatus(c *gin.Context, status string) {
+	var param []*models.ProcScheduleOperationParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
+		return
+	}
+	var err error
+	for _, v := range param {
+		if err = database.UpdateBatchExecScheduleStatus(c, v.Id, status, middleware.GetRequestUser(c), middleware.GetRequestRoles(c)); err != nil {
+			break
+		}
+		if status == models.ScheduleStatusDelete {
+			batchExecScheduleTimer.Remove(v.Id)
+			batchExecScheduleConfigMap.Delete(v.Id)
+		}
+	}
+	if err != nil {
+		middleware.ReturnError(c, err)
+	} else {
+		middleware.ReturnSuccess(c)
+	}
+}
+
+func checkTemplateUsePermission(template *models.BatchExecutionTemplate, userRoles []string) bool {
+	if template.PermissionToRole == nil {
+		return false
+	}
+	templateRoleMap := make(map[string]bool)
+	for _, role := range template.PermissionToRole.MGMT {
+		templateRoleMap[role] = true
+	}
+	for _, role := range template.PermissionToRole.USE {
+		templateRoleMap[role] = true
+	}
+	for _, role := range userRoles {
+		if templateRoleMap[role] {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	batchExecScheduleTimer     *timer.CronSecond
+	batchExecScheduleConfigMap *sync.Map
+)
+
+func InitBatchExecScheduleTimer() {
+	batchExecScheduleTimer = timer.New()
+	batchExecScheduleTimer.Start()
+	// 加载数据库定时配置
+	batchExecScheduleConfigMap = new(sync.Map)
+	go checkNewBatchExecScheduleJob()
+	configList, getErr := database.GetBatchExecScheduleLoadList()
+	if getErr != nil {
+		log.Logger.Error("InitBatchExecScheduleTimer load config list fail", log.Error(getErr))
+		return
+	}
+	for _, row := range configList {
+		if tmpErr := batchExecScheduleTimer.AddCron(row.Id, row.CronExpr, handleBatchExecScheduleJob, *row); tmpErr != nil {
+			log.Logger.Error("InitBatchExecScheduleTimer load config add cron job fail", log.String("scheduleId", row.Id), log.Error(tmpErr))
+		} else {
+			batchExecScheduleConfigMap.Store(row.Id, row)
+		}
+	}
+}
+
+// 定时检测在其它实例受理的批量执行定时配置
+func checkNewBatchExecScheduleJob() {
+	t := time.NewTicker(300 * time.Second).C
+	for {
+		<-t
+		newConfigList, getErr := database.GetNewBatchExecScheduleList()
+		if getErr != nil {
+			log.Logger.Error("checkNewBatchExecScheduleJob get newly config list fail", log.Error(getErr))
+			continue
+		}
+		for _, newConfigRow := range newConfigList {
+			if _, ok := batchExecScheduleConfigMap.Load(newConfigRow.Id); !ok {
+				if registerErr := batchExecScheduleTimer.AddCron(newConfigRow.Id, newConfigRow.CronExpr, handleBatchExecScheduleJob, *newConfigRow); registerErr != nil {
+					log.Logger.Error("register batch execution schedule config fail", log.String("id", newConfigRow.Id), log.Error(registerErr))
+				} else {
+					batchExecScheduleConfigMap.Store(newConfigRow.Id, newConfigRow)
+				}
+			}
+		}
+	}
+}
+
+func handleBatchExecScheduleJob(unixTimestamp int64, param interface{}) {
+	config, ok := param.(models.BatchExecScheduleConfig)
+	if !ok {
+		log.Logger.Error("handleBatchExecScheduleJob fail with assert param to BatchExecScheduleConfig")
+		return
+	}
+	jobId := fmt.Sprintf("%s_%d", config.Id, unixTimestamp)
+	ctx := context.WithValue(context.Background(), models.TransactionIdHeader, jobId)
+	// 检测状态
+	status := database.GetBatchExecScheduleConfigStatus(ctx, config.Id)
+	if status == models.ScheduleStatusStop || status == "" {
+		return
+	}
+	if status == models.ScheduleStatusDelete {
+		batchExecScheduleTimer.Remove(config.Id)
+		batchExecScheduleConfigMap.Delete(config.Id)
+		return
+	}
+	log.Logger.Info("start handleBatchExecScheduleJob", log.String("scheduleId", config.Id), log.String("jobId", jobId))
+	// 抢占任务
+	if duplicateRow, err := database.NewBatchExecScheduleJob(ctx, &config, jobId); err != nil {
+		log.Logger.Error("NewBatchExecScheduleJob fail", log.String("scheduleId", config.Id), log.Error(err))
+		return
+	} else if duplicateRow {
+		log.Logger.Warn("NewBatchExecScheduleJob insert with duplicate id,break", log.String("scheduleId", config.Id))
+		return
+	}
+	database.AddBatchExecScheduleExecTimes(ctx, config.Id)
+	jobStatus, errorMsg := "done", ""
+	batchExecId, entityNum, err := execution.RunBatchExecSchedule(ctx, &config)
+	if err != nil {
+		jobStatus, errorMsg = "fail", err.Error()
+		log.Logger.Error("handleBatchExecScheduleJob fail", log.String("scheduleId", config.Id), log.String("jobId", jobId), log.Error(err))
+	}
+	if updateErr := database.UpdateBatchExecScheduleJob(ctx, jobId, jobStatus, errorMsg, batchExecId, entityNum); updateErr != nil {
+		log.Logger.Error("handleBatchExecScheduleJob update schedule job fail", log.String("jobId", jobId), log.String("batchExecId", batchExecId), log.Error(updateErr))
+	} else {
+		log.Logger.Info("done handleBatchExecScheduleJob", log.String("scheduleId", config.Id), log.String("jobId", jobId), log.String("batchExecId", batchExecId))
+	}
+}
@@ -1,27 +1,23 @@
 package plugin
 
 import (
-	"context"
-	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
-	"github.com/WeBankPartners/go-common-lib/cipher"
 	"github.com/WeBankPartners/go-common-lib/guid"
 	"github.com/WeBankPartners/wecube-platform/platform-core/api/middleware"
-	"github.com/WeBankPartners/wecube-platform/platform-core/common/encrypt"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/tools"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/try"
 	"github.com/WeBankPartners/wecube-platform/platform-core/models"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/bash"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/container"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/execution"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
 	"github.com/gin-gonic/gin"
 )
@@ -221,7 +217,7 @@ func doPullPackageBackground(c *gin.Context, pullId, fileName string) {
 		return
 	}
 	archiveFilePath := tmpFile.Name()
-	pkgId, err := doUploadPackage(c, archiveFilePath)
+	pkgId, err := execution.UploadPluginPackageArchive(c, archiveFilePath)
 	if err != nil {
 		// update failed
 		database.UpdatePluginPackagePullReq(c, pullId, "", "Faulted", err.Error(), "", tmpFileSize)
@@ -237,109 +233,6 @@ func doPullPackageBackground(c *gin.Context, pullId, fileName string) {
 	log.Logger.Debug("pull plugin package,clean up plugin package tmp file", log.JsonObj("fileName", fileName), log.JsonObj("archiveFilePath", archiveFilePath))
 }
 
-func doUploadPackage(c context.Context, archiveFilePath string) (pluginPkgId string, err error) {
-	tmpFileDir := fmt.Sprintf("/tmp/%d", time.Now().UnixNano())
-	if err = os.MkdirAll(tmpFileDir, 0777); err != nil {
-		err = fmt.Errorf("make tmp dir fail,%s ", err.Error())
-		return
-	}
-	// 上传包
-	log.Logger.Debug("tmpFile", log.String("tmpFileDir", tmpFileDir))
-	defer func() {
-		if removeTmpDirErr := os.RemoveAll(tmpFileDir); removeTmpDirErr != nil {
-			log.Logger.Error("Try to remove package upload tmp dir fail", log.String("tmpDir", tmpFileDir), log.Error(removeTmpDirErr))
-		}
-	}()
-	if _, err = bash.DecompressFile(archiveFilePath, tmpFileDir); err != nil {
-		return
-	}
-	packageFiles, readErr := bash.ListDirFiles(tmpFileDir)
-	if readErr != nil {
-		err = readErr
-		return
-	}
-	// 解析xml文件
-	var registerFile, imageFile, uiFile, initSql, upgradeSql string
-	withUi := false
-	for _, v := range packageFiles {
-		if v == "register.xml" {
-			registerFile = v
-			continue
-		}
-		if v == "image.tar" {
-			imageFile = v
-			continue
-		}
-		if v == "ui.zip" {
-			uiFile = v
-			withUi = true
-			continue
-		}
-	}
-	if registerFile == "" || imageFile == "" {
-		err = fmt.Errorf("register xml and image tar can not empty")
-		return
-	}
-	var registerConfig models.RegisterXML
-	registerConfigBytes, readRegisterConfigErr := os.ReadFile(fmt.Sprintf("%s/%s", tmpFileDir, registerFile))
-	if readRegisterConfigErr != nil {
-		err = fmt.Errorf("read register xml file fail,%s ", readRegisterConfigErr.Error())
-		return
-	}
-	if err = xml.Unmarshal(registerConfigBytes, &registerConfig); err != nil {
-		err = fmt.Errorf("xml unmarshal regisger xml fail,%s ", err.Error())
-		return
-	}
-	pluginPackageObj := models.PluginPackages{Name: registerConfig.Name, Version: registerConfig.Version}
-	if err = database.GetSimplePluginPackage(c, &pluginPackageObj, false); err != nil {
-		return
-	}
-	if pluginPackageObj.Id != "" {
-		err = fmt.Errorf("plugin %s:%s already existed", registerConfig.Name, registerConfig.Version)
-		return
-	}
-	if registerConfig.ResourceDependencies.Mysql.InitFileName != "" {
-		initSql = registerConfig.ResourceDependencies.Mysql.InitFileName
-		upgradeSql = registerConfig.ResourceDependencies.Mysql.UpgradeFileName
-		if !bash.ListContains(packageFiles, initSql) {
-			err = fmt.Errorf("init sql file:%s can not find in package", initSql)
-			return
-		}
-		if !bash.ListContains(packageFiles, upgradeSql) {
-			upgradeSql = ""
-		}
-	}
-	// 上传解压后的文件到s3
-	s3Prefix := fmt.Sprintf("%s/%s/", registerConfig.Name, registerConfig.Version)
-	s3FileMap := make(map[string]string)
-	s3FileMap[fmt.Sprintf("%s/%s", tmpFileDir, registerFile)] = s3Prefix + registerFile
-	s3FileMap[fmt.Sprintf("%s/%s", tmpFileDir, imageFile)] = s3Prefix + imageFile
-	if uiFile != "" {
-		s3FileMap[fmt.Sprintf("%s/%s", tmpFileDir, uiFile)] = s3Prefix + uiFile
-	}
-	if initSql != "" {
-		s3FileMap[fmt.Sprintf("%s/%s", tmpFileDir, initSql)] = s3Prefix + initSql
-	}
-	if upgradeSql != "" {
-		s3FileMap[fmt.Sprintf("%s/%s", tmpFileDir, upgradeSql)] = s3Prefix + upgradeSql
-	}
-	if err = bash.UploadPluginPackage(models.Config.S3.PluginPackageBucket, s3FileMap); err != nil {
-		return
-	}
-	if registerConfig.ResourceDependencies.S3.BucketName != "" {
-		if err = bash.MakeBucket(registerConfig.ResourceDependencies.S3.BucketName); err != nil {
-			return
-		}
-	}
-	// 写数据库
-	pkgId := "plugin_" + guid.CreateGuid()
-	err = database.UploadPackage(c, &registerConfig, withUi, false, pkgId)
-	if err != nil {
-		return
-	}
-	return pkgId, nil
-}
-
 // PullOnliePackageStatus 注册在线插件状态
 func PullOnliePackageStatus(c *gin.Context) {
 	pullId := c.Param("pullId")
@@ -425,102 +318,12 @@ func GetAvailableContainerHost(c *gin.Context) {
 
 // RegisterPackage 插件配置 - 注册插件包
 func RegisterPackage(c *gin.Context) {
-	pluginPackageId := c.Param("pluginPackageId")
-	pluginPackageObj := models.PluginPackages{Id: pluginPackageId}
+	pluginPackageObj := models.PluginPackages{Id: c.Param("pluginPackageId")}
 	if err := database.GetSimplePluginPackage(c, &pluginPackageObj, true); err != nil {
 		middleware.ReturnError(c, err)
 		return
 	}
-	// 依赖包检测
-	depOk, err := database.CheckPluginPackageDependence(c, pluginPackageId)
-	if err != nil {
-		middleware.ReturnError(c, err)
-		return
-	}
-	if !depOk {
-		middleware.ReturnError(c, exterror.New().PluginDependencyIllegal)
-		return
-	}
-	if pluginPackageObj.UiPackageIncluded {
-		if len(models.Config.StaticResources) == 0 {
-			middleware.ReturnError(c, fmt.Errorf("static resource config empty"))
-			return
-		}
-		// 把s3上的ui.zip下下来放到本地
-		log.Logger.Debug("register plugin,start download ui.zip")
-		var uiFileLocalPath, uiDir string
-		if uiFileLocalPath, err = bash.DownloadPackageFile(models.Config.S3.PluginPackageBucket, fmt.Sprintf("%s/%s/ui.zip", pluginPackageObj.Name, pluginPackageObj.Version)); err != nil {
-			middleware.ReturnError(c, err)
-			return
-		}
-		log.Logger.Debug("register plugin,start decompress ui.zip", log.String("uiFileLocalPath", uiFileLocalPath))
-		// 本地解压ui.zip
-		if uiDir, err = bash.DecompressFile(uiFileLocalPath, ""); err != nil {
-			middleware.ReturnError(c, err)
-			return
-		}
-		// 把ui.zip用ssh传到静态资源服务器上并解压，如果有两台服务器，则每台都要上传与解压
-		for _, staticResourceObj := range models.Config.StaticResources {
-			targetPath := fmt.Sprintf("%s/%s/%s/ui.zip", staticResourceObj.Path, pluginPackageObj.Name, pluginPackageObj.Version)
-			unzipCmd := fmt.Sprintf("cd %s/%s/%s && unzip -o ui.zip", staticResourceObj.Path, pluginPackageObj.Name, pluginPackageObj.Version)
-			log.Logger.Debug("register plugin,start scp ui.zip to remote host", log.String("server", staticResourceObj.Server), log.String("targetPath", targetPath))
-			if err = bash.RemoteSCP(staticResourceObj.Server, staticResourceObj.User, staticResourceObj.Password, staticResourceObj.Port, uiFileLocalPath, targetPath); err != nil {
-				break
-			}
-			log.Logger.Debug("register plugin,start unzip ui.zip in remote host", log.String("server", staticResourceObj.Server), log.String("unzipCmd", unzipCmd))
-			if err = bash.RemoteSSHCommand(staticResourceObj.Server, staticResourceObj.User, staticResourceObj.Password, staticResourceObj.Port, unzipCmd); err != nil {
-				break
-			}
-		}
-		if err != nil {
-			middleware.ReturnError(c, err)
-			return
-		}
-		// 把ui.zip里的静态文件读出来
-		var fileNameList []string
-		indexPath, matchIndexFlag, findErr := bash.GetDirIndexPath(uiDir)
-		if findErr != nil {
-			middleware.ReturnError(c, findErr)
-			return
-		}
-		if !matchIndexFlag {
-			middleware.ReturnError(c, fmt.Errorf("can not find index.html in ui package"))
-			return
-		}
-		log.Logger.Debug("match index path", log.String("indexPath", indexPath))
-		indexPath = strings.TrimSuffix(indexPath, "/")
-		dirPrefix := uiDir
-		if indexPath != "" {
-			dirPrefix = uiDir + "/" + indexPath
-		}
-		fileNameList, err = bash.ListDirAllFiles(dirPrefix)
-		if err != nil {
-			middleware.ReturnError(c, err)
-			return
-		}
-		uiStaticPath := models.Config.StaticResources[0].Path
-		if pathIndex := strings.LastIndex(uiStaticPath, "/"); pathIndex >= 0 {
-			uiStaticPath = uiStaticPath[pathIndex:]
-		}
-		uiStaticPath = fmt.Sprintf("%s/%s/%s", uiStaticPath, pluginPackageObj.Name, pluginPackageObj.Version)
-		if indexPath != "" {
-			uiStaticPath = uiStaticPath + "/" + indexPath
-		}
-		resourceFileList := []*models.PluginPackageResourceFiles{}
-		for _, v := range fileNameList {
-			tmpResourceObj := models.PluginPackageResourceFiles{PluginPackageId: pluginPackageId, PackageName: pluginPackageObj.Name, PackageVersion: pluginPackageObj.Version, Source: "ui.zip", RelatedPath: strings.ReplaceAll(v, dirPrefix, uiStaticPath)}
-			resourceFileList = append(resourceFileList, &tmpResourceObj)
-		}
-		if len(resourceFileList) > 0 {
-			log.Logger.Debug("register plugin,start update plugin static resource file data", log.JsonObj("resourceFileList", resourceFileList))
-			if err = database.UpdatePluginStaticResourceFiles(c, pluginPackageId, resourceFileList); err != nil {
-				middleware.ReturnError(c, err)
-				return
-			}
-		}
-	}
-	// 把对应插件版本的系统变量置为active
-	if err = database.RegisterPlugin(c, pluginPackageObj.Name, pluginPackageObj.Version); err != nil {
+	if err := execution.RegisterPluginPackage(c, &pluginPackageObj); err != nil {
 		middleware.ReturnError(c, err)
 	} else {
 		middleware.ReturnSuccess(c)
@@ -535,7 +338,13 @@ func GetHostAvailablePort(c *gin.Context) {
 		middleware.ReturnError(c, err)
 		return
 	}
-	port, getPortErr := bash.GetRemoteHostAvailablePort(resourceServer)
+	var port int
+	var getPortErr error
+	if resourceServer.Type == models.ResourceServerTypeK8s {
+		port, getPortErr = database.GetK8sAvailablePort(c, resourceServer.Id)
+	} else {
+		port, getPortErr = bash.GetRemoteHostAvailablePort(resourceServer)
+	}
 	if getPortErr != nil {
 		middleware.ReturnError(c, getPortErr)
 	} else {
@@ -563,318 +372,173 @@ func LaunchPlugin(c *gin.Context) {
 			return
 		}
 	}
-	pluginPackageObj := models.PluginPackages{Id: pluginPackageId}
-	if err := database.GetSimplePluginPackage(c, &pluginPackageObj, true); err != nil {
-		log.Logger.Error("GetSimplePluginPackage fail", log.Error(err))
+	pluginInstance, pluginPackageObj, err := execution.LaunchPluginInstance(c, &models.PluginLaunchParam{PackageId: pluginPackageId, HostIp: hostIp, Port: port, Operator: middleware.GetRequestUser(c)})
+	if err != nil {
 		middleware.ReturnError(c, err)
 		return
 	}
-	log.Logger.Debug("pluginPackage", log.JsonObj("data", pluginPackageObj))
-	resources, getResourceErr := database.GetPluginRuntimeResources(c, pluginPackageId)
-	if getResourceErr != nil {
-		log.Logger.Error("GetPluginRuntimeResources fail", log.Error(getResourceErr))
-		middleware.ReturnError(c, getResourceErr)
-		return
-	}
-	if len(resources.Docker) == 0 {
-		middleware.ReturnError(c, fmt.Errorf("plugin must contain docker resource"))
-		return
-	}
-	operator := middleware.GetRequestUser(c)
-	var mysqlInstance *models.PluginMysqlInstances
-	var mysqlServer *models.ResourceServer
-	pluginInstance := models.PluginInstances{
-		Id:              "p_docker_" + guid.CreateGuid(),
-		Host:            hostIp,
-		Port:            port,
-		ContainerStatus: "RUNNING",
-		PackageId:       pluginPackageId,
-		InstanceName:    pluginPackageObj.Name,
-	}
-	if len(resources.Mysql) > 0 {
-		mysqlResource := resources.Mysql[0]
-		pluginInstance.PluginMysqlInstanceResourceId = mysqlResource.Id
-		// 先检查数据库脚本执行纪录的版本，如果执行过了就跳过下面数据库相关操作
-		var resourceDbErr error
-		mysqlInstance, resourceDbErr = database.GetPluginMysqlInstance(c, pluginPackageObj.Name)
-		if resourceDbErr != nil {
-			middleware.ReturnError(c, resourceDbErr)
-			return
-		}
-		// 如果连纪录都没有，第一次要创建数据库
-		mysqlServer, resourceDbErr = database.GetResourceServer(c, "mysql", "")
-		if resourceDbErr != nil {
-			middleware.ReturnError(c, resourceDbErr)
+	// 向gateway注册插件路由
+	if pluginInstance.ContainerStatus == models.PluginInstanceStatusRunning {
+		err = remote.RegisterPluginRoute(pluginPackageObj.Name, pluginInstance.Host, strconv.Itoa(pluginInstance.Port))
+		if err != nil {
+			middleware.ReturnError(c, err)
 			return
 		}
-		if mysqlInstance == nil {
-			if dbPass, err := bash.CreatePluginDatabase(c, pluginPackageObj.Name, mysqlResource, mysqlServer); err != nil {
-				middleware.ReturnError(c, err)
-				return
-			} else {
-				mysqlInstance = &models.PluginMysqlInstances{
-					Id:              "p_mysql_" + guid.CreateGuid(),
-					Password:        dbPass,
-					PluginPackageId: pluginPackageId,
-					ResourceItemId:  mysqlResource.Id,
-					SchemaName:      mysqlResource.SchemaName,
-					Username:        pluginPackageObj.Name,
-				}
-				log.Logger.Debug("database pwd", log.String("pass", dbPass))
-				if err = database.NewPluginMysqlInstance(c, mysqlServer, mysqlInstance, operator); err != nil {
-					middleware.ReturnError(c, err)
-					return
-				}
-			}
-		}
-		if tools.CompareVersion(pluginPackageObj.Version, mysqlInstance.PreVersion) {
-			// 把s3上的init.sql下载来到本地
-			var intiSqlFile, upgradeSqlFile string
-			if mysqlResource.InitFileName != "" {
-				tmpFile, downloadErr := bash.DownloadPackageFile(models.Config.S3.PluginPackageBucket, fmt.Sprintf("%s/%s/%s", pluginPackageObj.Name, pluginPackageObj.Version, mysqlResource.InitFileName))
-				if downloadErr != nil {
-					middleware.ReturnError(c, downloadErr)
-					return
-				}
-				intiSqlFile = tmpFile
-			}
-			if mysqlResource.UpgradeFileName != "" {
-				tmpFile, downloadErr := bash.DownloadPackageFile(models.Config.S3.PluginPackageBucket, fmt.Sprintf("%s/%s/%s", pluginPackageObj.Name, pluginPackageObj.Version, mysqlResource.UpgradeFileName))
-				if downloadErr != nil {
-					log.Logger.Warn("plugin have no upgrade sql", log.String("plugin", pluginPackageObj.Name), log.String("version", pluginPackageObj.Version))
-				} else {
-					upgradeSqlFile = tmpFile
-				}
-			}
-			// 检查数据库脚本是否有更新
-			outputSqlFile, buildErr := bash.BuildPluginUpgradeSqlFile(intiSqlFile, upgradeSqlFile, mysqlInstance.PreVersion)
-			if buildErr != nil {
-				middleware.ReturnError(c, buildErr)
-				return
-			}
-			// 执行数据库脚本并更新纪录
-			if outputSqlFile != "" {
-				if err := bash.ExecPluginUpgradeSql(c, mysqlInstance, mysqlServer, outputSqlFile); err != nil {
-					middleware.ReturnError(c, err)
-					return
-				}
-			}
-			if err := database.UpdatePluginMysqlInstancePreVersion(c, mysqlInstance.Id, pluginPackageObj.Version); err != nil {
-				middleware.ReturnError(c, err)
-				return
-			}
-		}
 	}
-	dockerResource := resources.Docker[0]
-	pluginInstance.ContainerName = dockerResource.ContainerName
-	dockerServer, getDockerServerErr := database.GetResourceServer(c, "docker", hostIp)
-	if getDockerServerErr != nil {
-		middleware.ReturnError(c, getDockerServerErr)
+	middleware.ReturnSuccess(c)
+}
+
+// RemovePlugin 运行管理 - 插件实例销毁
+func RemovePlugin(c *gin.Context) {
+	pluginInstanceId := c.Param("pluginInstanceId")
+	pluginInstanceObj, err := database.GetPluginInstance(pluginInstanceId)
+	if err != nil {
+		middleware.ReturnError(c, err)
 		return
 	}
-	envMap := make(map[string]string)
-	portBindList := getEnvMap(dockerResource.PortBindings, envMap)
-	volumeBindList := getEnvMap(dockerResource.VolumeBindings, envMap)
-	envBindList := getEnvMap(dockerResource.EnvVariables, envMap)
-	envMap["ALLOCATE_PORT"] = portValue
-	envMap["ALLOCATE_HOST"] = hostIp
-	envMap["BASE_MOUNT_PATH"] = models.Config.Plugin.BaseMountPath
-	envMap["MONITOR_PORT"] = fmt.Sprintf("%d", port+10000)
-	if mysqlInstance != nil {
-		envMap["DB_SCHEMA"] = mysqlInstance.SchemaName
-		envMap["DB_USER"] = mysqlInstance.Username
-		if models.Config.Plugin.PasswordPubKeyContent != "" {
-			if encryptDBPwd, enErr := cipher.EncryptRsa(mysqlInstance.Password, models.Config.Plugin.PasswordPubKeyContent); enErr != nil {
-				log.Logger.Error("Try to encrypt plugin password fail", log.Error(enErr))
-				envMap["DB_PWD"] = mysqlInstance.Password
-			} else {
-				envMap["DB_PWD"] = encryptDBPwd
-			}
-		} else {
-			envMap["DB_PWD"] = mysqlInstance.Password
-		}
-		if mysqlServer != nil {
-			envMap["DB_HOST"] = mysqlServer.Host
-			envMap["DB_PORT"] = mysqlServer.Port
-		}
+	if err = execution.RemovePluginInstance(c, pluginInstanceObj, false); err != nil {
+		middleware.ReturnError(c, err)
+	} else {
+		middleware.ReturnSuccess(c)
 	}
-	// 向auth server注册插件并返回插件认证的code和pubKey,插件会拿着这两个东西去获取插件专属的token来访问platform
-	subSystemCode, subSystemKey, subSystemPubKey, registerAuthErr := remote.RegisterSubSystem(&pluginPackageObj)
-	if registerAuthErr != nil {
-		middleware.ReturnError(c, registerAuthErr)
+}
+
+// UpgradePlugin 运行管理 - 插件滚动升级,新版本实例健康后执行数据库升级脚本、切换路由并销毁旧实例,失败自动回滚实例,数据库升级脚本不可逆
+func UpgradePlugin(c *gin.Context) {
+	var param models.PluginUpgradeParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
 		return
 	}
-	envMap["SUB_SYSTEM_CODE"] = subSystemCode
-	envMap["SUB_SYSTEM_KEY"] = subSystemKey
-	if models.Config.Auth.JwtSigningKey != "" {
-		if models.Config.Plugin.PasswordPubKeyContent != "" {
-			if encryptJwtKey, enErr := cipher.EncryptRsa(models.Config.Auth.JwtSigningKey, models.Config.Plugin.PasswordPubKeyContent); enErr != nil {
-				envMap["JWT_SIGNING_KEY"] = models.Config.Auth.JwtSigningKey
-			} else {
-				envMap["JWT_SIGNING_KEY"] = encryptJwtKey
-			}
-		} else {
-			envMap["JWT_SIGNING_KEY"] = models.Config.Auth.JwtSigningKey
-		}
-	}
-	// 企业版的认证信息环境变量
-	if pluginPackageObj.Edition == "enterprise" {
-		licCode, licPk, licData, licSign, getLicenceErr := database.GeneratePluginEnv(subSystemPubKey, subSystemKey, pluginPackageObj.Name)
-		if getLicenceErr != nil {
-			middleware.ReturnError(c, getLicenceErr)
-			return
-		}
-		envBindList = append(envBindList, "LICENSE_CODE="+licCode)
-		envBindList = append(envBindList, "LICENSE_PK="+licPk)
-		envBindList = append(envBindList, "LICENSE_DATA="+licData)
-		envBindList = append(envBindList, "LICENSE_SIGNATURE="+licSign)
+	result, err := execution.StartPluginUpgrade(c, c.Param("pluginPackageId"), &param, middleware.GetRequestUser(c))
+	if err != nil {
+		middleware.ReturnError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
 	}
-	// 替换容器参数差异化变量
-	replaceMap, err := database.BuildDockerEnvMap(c, envMap)
+}
+
+// GetPluginUpgrade 运行管理 - 插件滚动升级进度
+func GetPluginUpgrade(c *gin.Context) {
+	result, err := database.GetPluginPackageUpgrade(c, c.Param("upgradeId"))
 	if err != nil {
 		middleware.ReturnError(c, err)
-		return
+	} else {
+		middleware.ReturnData(c, result)
 	}
-	portBindList = replaceEnvMap(portBindList, replaceMap)
-	volumeBindList = replaceEnvMap(volumeBindList, replaceMap)
-	envBindList = replaceEnvMap(envBindList, replaceMap)
-	// 先检查目标机器上有没有相关版本容器镜像，如果有的话就跳过下面两个下载和传镜像的操作
-	// 把s3上的image.tar下载来到本地？可否直接让目标机器下载image.tar
-	tmpImageFile, downloadImageErr := bash.DownloadPackageFile(models.Config.S3.PluginPackageBucket, fmt.Sprintf("%s/%s/image.tar", pluginPackageObj.Name, pluginPackageObj.Version))
-	if downloadImageErr != nil {
-		middleware.ReturnError(c, downloadImageErr)
+}
+
+// PreviewPluginInstallPlan 插件配置 - 计算插件安装计划,解析依赖版本并检查与运行中插件的冲突
+func PreviewPluginInstallPlan(c *gin.Context) {
+	var param models.PluginInstallPlanParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
 		return
 	}
-	// 把image.tar传到目标机器
-	targetImagePath := fmt.Sprintf("%s/%s_%s_image.tar", models.Config.Plugin.DeployPath, pluginPackageObj.Name, pluginPackageObj.Version)
-	if err = bash.RemoteSCP(dockerServer.Host, dockerServer.LoginUsername, dockerServer.LoginPassword, dockerServer.Port, tmpImageFile, targetImagePath); err != nil {
+	result, err := execution.PreviewPluginInstallPlan(c, &param)
+	if err != nil {
 		middleware.ReturnError(c, err)
-		return
+	} else {
+		middleware.ReturnData(c, result)
 	}
-	log.Logger.Info("scp plugin image file", log.String("targetHost", dockerServer.Host), log.String("tmpFile", tmpImageFile), log.String("targetPath", targetImagePath))
-	if err = bash.RemoteSSHCommand(dockerServer.Host, dockerServer.LoginUsername, dockerServer.LoginPassword, dockerServer.Port, fmt.Sprintf("docker load --input %s && rm -f %s", targetImagePath, targetImagePath)); err != nil {
-		middleware.ReturnError(c, err)
+}
+
+// ExecPluginInstallPlan 插件配置 - 按依赖顺序执行插件安装计划
+func ExecPluginInstallPlan(c *gin.Context) {
+	var param models.PluginInstallPlanParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
 		return
 	}
-	// 去目标机器上docker run起来，或使用docker-compose
-	dockerCmd := fmt.Sprintf("docker run -d --name %s ", dockerResource.ContainerName)
-	for _, v := range volumeBindList {
-		dockerCmd += fmt.Sprintf("--volume %s ", v)
-	}
-	for _, v := range portBindList {
-		dockerCmd += fmt.Sprintf("-p %s ", v)
-	}
-	for _, v := range envBindList {
-		tmpV := v
-		if eqIndex := strings.Index(v, "="); eqIndex > 0 {
-			tmpV = v[:eqIndex+1] + "'" + v[eqIndex+1:] + "'"
-		}
-		dockerCmd += fmt.Sprintf("-e %s ", tmpV)
-	}
-	dockerCmd += dockerResource.ImageName
-	if err = bash.RemoteSSHCommand(dockerServer.Host, dockerServer.LoginUsername, dockerServer.LoginPassword, dockerServer.Port, dockerCmd); err != nil {
+	result, err := execution.StartPluginInstallPlan(c, &param, middleware.GetRequestUser(c))
+	if err != nil {
 		middleware.ReturnError(c, err)
-		return
-	}
-	// 更新插件注册的菜单状态和更新插件实例数据
-	resourceItemProperties := models.ResourceItemProperties{
-		ImageName:      dockerResource.ImageName,
-		PortBindings:   strings.Join(portBindList, ","),
-		VolumeBindings: strings.Join(volumeBindList, ","),
-		EnvVariables:   strings.Join(envBindList, ","),
-	}
-	resourceItemPropertiesBytes, _ := json.Marshal(&resourceItemProperties)
-	resourceItem := models.ResourceItem{
-		Id:                   "rs_item_" + guid.CreateGuid(),
-		ResourceServerId:     dockerServer.Id,
-		AdditionalProperties: string(resourceItemPropertiesBytes),
-		CreatedBy:            operator,
-		CreatedDate:          time.Now(),
-		Name:                 dockerResource.ContainerName,
+	} else {
+		middleware.ReturnData(c, result)
 	}
-	pluginInstance.DockerInstanceResourceId = resourceItem.Id
-	err = database.LaunchPlugin(c, &pluginInstance, &resourceItem)
+}
+
+// GetPluginInstallPlan 插件配置 - 插件安装计划执行进度
+func GetPluginInstallPlan(c *gin.Context) {
+	result, err := execution.GetPluginInstallPlanRecord(c, c.Param("planId"))
 	if err != nil {
 		middleware.ReturnError(c, err)
-		return
+	} else {
+		middleware.ReturnData(c, result)
 	}
-	// 向gateway注册插件路由
-	err = remote.RegisterPluginRoute(pluginPackageObj.Name, hostIp, portValue)
+}
+
+// GetPluginInstanceHealth 运行管理 - 插件实例健康状态,可按packageId过滤
+func GetPluginInstanceHealth(c *gin.Context) {
+	result, err := database.GetPluginInstanceHealthList(c, c.Query("packageId"))
 	if err != nil {
 		middleware.ReturnError(c, err)
-		return
+	} else {
+		middleware.ReturnData(c, result)
 	}
-	middleware.ReturnSuccess(c)
 }
 
-// RemovePlugin 运行管理 - 插件实例销毁
-func RemovePlugin(c *gin.Context) {
-	pluginInstanceId := c.Param("pluginInstanceId")
-	pluginInstanceObj, err := database.GetPluginInstance(pluginInstanceId)
+// GetPluginInstanceContainer 运行管理 - 插件实例容器状态
+func GetPluginInstanceContainer(c *gin.Context) {
+	pluginInstanceObj, err := database.GetPluginInstance(c.Param("pluginInstanceId"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
 	}
-	pluginPackageObj := models.PluginPackages{Id: pluginInstanceObj.PackageId}
-	if err = database.GetSimplePluginPackage(c, &pluginPackageObj, true); err != nil {
+	containerDriver, err := execution.GetPluginInstanceDriver(pluginInstanceObj.DockerInstanceResourceId)
+	if err != nil {
 		middleware.ReturnError(c, err)
 		return
 	}
-	// 查询容器资源信息
-	resourceServer, getServerErr := database.GetPluginDockerRunningResource(pluginInstanceObj.DockerInstanceResourceId)
-	if getServerErr != nil {
-		middleware.ReturnError(c, getServerErr)
+	state, err := containerDriver.Inspect(c, pluginInstanceObj.ContainerName)
+	if err != nil {
+		middleware.ReturnError(c, err)
 		return
 	}
-	// 查询容器运行信息
-	imageName, containerName, getErr := database.GetPluginDockerRuntimeMessage(pluginInstanceObj.PackageId)
-	if getErr != nil {
-		middleware.ReturnError(c, getErr)
-		return
+	// 状态有变化时回写实例,变成运行中时注册gateway路由;容器在运行但健康检查不通过的由健康检查恢复,滚动升级中的由升级切换
+	containerStatus := container.InstanceStatus(state)
+	if containerStatus == models.PluginInstanceStatusRunning && tools.StringListContains([]string{models.PluginInstanceStatusUnhealthy, models.PluginInstanceStatusStandby, models.PluginInstanceStatusDraining}, pluginInstanceObj.ContainerStatus) {
+		containerStatus = pluginInstanceObj.ContainerStatus
 	}
-	// 销毁容器
-	if strings.HasPrefix(resourceServer.LoginPassword, models.AESPrefix) {
-		resourceServer.LoginPassword = encrypt.DecryptWithAesECB(resourceServer.LoginPassword[5:], models.Config.Plugin.ResourcePasswordSeed, resourceServer.Name)
+	if containerStatus != pluginInstanceObj.ContainerStatus {
+		if err = database.UpdatePluginInstanceContainerStatus(c, pluginInstanceObj.Id, containerStatus); err != nil {
+			middleware.ReturnError(c, err)
+			return
+		}
+		if containerStatus == "RUNNING" {
+			pluginPackageObj := models.PluginPackages{Id: pluginInstanceObj.PackageId}
+			if err = database.GetSimplePluginPackage(c, &pluginPackageObj, true); err != nil {
+				middleware.ReturnError(c, err)
+				return
+			}
+			if err = remote.RegisterPluginRoute(pluginPackageObj.Name, pluginInstanceObj.Host, strconv.Itoa(pluginInstanceObj.Port)); err != nil {
+				middleware.ReturnError(c, err)
+				return
+			}
+		}
 	}
-	removeCmd := fmt.Sprintf("docker rm -f %s && docker rmi %s", containerName, imageName)
-	if err = bash.RemoteSSHCommand(resourceServer.Host, resourceServer.LoginUsername, resourceServer.LoginPassword, resourceServer.Port, removeCmd); err != nil {
+	middleware.ReturnData(c, state)
+}
+
+// GetPluginInstanceLogs 运行管理 - 插件实例容器日志
+func GetPluginInstanceLogs(c *gin.Context) {
+	tail, _ := strconv.Atoi(c.Query("tail"))
+	pluginInstanceObj, err := database.GetPluginInstance(c.Param("pluginInstanceId"))
+	if err != nil {
 		middleware.ReturnError(c, err)
 		return
 	}
-	// 更新插件注册的菜单状态和更新插件实例数据
-	err = database.RemovePlugin(c, pluginPackageObj.Id, pluginInstanceId, pluginInstanceObj.DockerInstanceResourceId)
+	containerDriver, err := execution.GetPluginInstanceDriver(pluginInstanceObj.DockerInstanceResourceId)
 	if err != nil {
 		middleware.ReturnError(c, err)
-	} else {
-		middleware.ReturnSuccess(c)
-	}
-}
-
-func getEnvMap(input string, envMap map[string]string) (inputList []string) {
-	re, _ := regexp.Compile(".*{{(.*)}}.*")
-	inputList = strings.Split(input, ",")
-	for _, v := range inputList {
-		for i, matchEnv := range re.FindStringSubmatch(v) {
-			if i == 0 {
-				continue
-			}
-			envMap[matchEnv] = ""
-		}
+		return
 	}
-	return
-}
-
-func replaceEnvMap(inputList []string, replaceMap map[string]string) (outputList []string) {
-	for _, input := range inputList {
-		inputV := input
-		if strings.Contains(input, "{{") {
-			for k, v := range replaceMap {
-				inputV = strings.ReplaceAll(inputV, k, v)
-			}
-		}
-		outputList = append(outputList, inputV)
+	logs, err := containerDriver.Logs(c, pluginInstanceObj.ContainerName, tail)
+	if err != nil {
+		middleware.ReturnError(c, err)
+	} else {
+		middleware.ReturnData(c, logs)
 	}
-	return
 }
 
 func GetPluginRunningInstances(c *gin.Context) {
@@ -1,19 +1,21 @@
 package process
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/WeBankPartners/go-common-lib/guid"
 	"github.com/WeBankPartners/wecube-platform/platform-core/api/middleware"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/tools"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/trace"
 	"github.com/WeBankPartners/wecube-platform/platform-core/models"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/execution"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/workflow"
 	"github.com/gin-gonic/gin"
+	"strconv"
 	"strings"
 )
 
@@ -335,6 +337,16 @@ func ProcInsStart(c *gin.Context) {
 		return
 	}
 	operator := middleware.GetRequestUser(c)
+	// 试运行只返回各节点的执行报告
+	if param.Simulate {
+		report, simulateErr := execution.SimulateProcInstance(c, &param, operator)
+		if simulateErr != nil {
+			middleware.ReturnError(c, simulateErr)
+		} else {
+			middleware.ReturnData(c, report)
+		}
+		return
+	}
 	// 新增 proc_ins,proc_ins_node,proc_data_binding 纪录
 	procInsId, workflowRow, workNodes, workLinks, err := database.CreateProcInstance(c, &param, operator)
 	if err != nil {
@@ -343,7 +355,7 @@ func ProcInsStart(c *gin.Context) {
 	}
 	// 初始化workflow并开始
 	workObj := workflow.Workflow{ProcRunWorkflow: *workflowRow}
-	workObj.Init(context.Background(), workNodes, workLinks)
+	workObj.Init(trace.Detach(c), workNodes, workLinks)
 	//workflow.GlobalWorkflowMap.Store(workObj.Id, &workObj)
 	go workObj.Start(&models.ProcOperation{CreatedBy: operator})
 	// 查询 detail 返回
@@ -389,6 +401,47 @@ func GetProcInsNodeContext(c *gin.Context) {
 	}
 }
 
+// GetProcInsNodeIterations 查询循环节点或循环体节点的每轮执行纪录
+func GetProcInsNodeIterations(c *gin.Context) {
+	procInsId := c.Param("procInsId")
+	procInsNodeId := c.Param("procInsNodeId")
+	if procInsId == "" || procInsNodeId == "" {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("path param can not empty")))
+		return
+	}
+	result, err := database.GetProcInsNodeIterations(c, procInsId, procInsNodeId)
+	if err != nil {
+		middleware.ReturnError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
+
+// SubmitApprovalVote 审批节点投票,审批有结果后通知工作流继续
+func SubmitApprovalVote(c *gin.Context) {
+	var param models.ApprovalVoteParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
+		return
+	}
+	operator := middleware.GetRequestUser(c)
+	runNode, result, err := execution.SubmitApprovalVote(c, c.Param("procInsId"), c.Param("procInsNodeId"), operator, middleware.GetRequestRoles(c), &param)
+	if err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
+		return
+	}
+	if result != "" {
+		operationObj := models.ProcRunOperation{WorkflowId: runNode.WorkflowId, NodeId: runNode.Id, Operation: "approve", Status: "wait", Message: result, CreatedBy: operator}
+		operationObj.Id, err = database.AddWorkflowOperation(c, &operationObj)
+		if err != nil {
+			middleware.ReturnError(c, err)
+			return
+		}
+		go workflow.NotifyWorkflowOperation(&operationObj)
+	}
+	middleware.ReturnData(c, map[string]string{"result": result})
+}
+
 func PublicProcInsStart(c *gin.Context) {
 	var param models.RequestProcessData
 	if err := c.ShouldBindJSON(&param); err != nil {
@@ -405,7 +458,7 @@ func PublicProcInsStart(c *gin.Context) {
 	}
 	// 初始化workflow并开始
 	workObj := workflow.Workflow{ProcRunWorkflow: *workflowRow}
-	workObj.Init(context.Background(), workNodes, workLinks)
+	workObj.Init(trace.Detach(c), workNodes, workLinks)
 	//workflow.GlobalWorkflowMap.Store(workObj.Id, &workObj)
 	go workObj.Start(&models.ProcOperation{CreatedBy: operator})
 	// 查询 detail 返回
@@ -438,7 +491,24 @@ func ProcInsOperation(c *gin.Context) {
 			middleware.ReturnError(c, err)
 			return
 		}
-		go workflow.HandleProOperation(&operationObj)
+		go workflow.NotifyWorkflowOperation(&operationObj)
+	} else if param.Act == "replay" {
+		procIns, getProcInsErr := database.GetSimpleProcInsRow(c, param.ProcInstId)
+		if getProcInsErr != nil {
+			middleware.ReturnError(c, getProcInsErr)
+			return
+		}
+		if procIns.Status != models.JobStatusSuccess && procIns.Status != models.JobStatusFail && procIns.Status != models.JobStatusKill {
+			middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("proc instance status:%s can not replay,only finished instance support", procIns.Status)))
+			return
+		}
+		operationObj := models.ProcRunOperation{WorkflowId: workflowId, NodeId: nodeId, Operation: "replay", Status: "wait", Message: param.Message, CreatedBy: middleware.GetRequestUser(c)}
+		operationObj.Id, err = database.AddWorkflowOperation(c, &operationObj)
+		if err != nil {
+			middleware.ReturnError(c, err)
+			return
+		}
+		go workflow.NotifyWorkflowOperation(&operationObj)
 	}
 	middleware.ReturnSuccess(c)
 }
@@ -483,7 +553,7 @@ func ProcInsNodeRetry(c *gin.Context) {
 		middleware.ReturnError(c, err)
 		return
 	}
-	go workflow.HandleProOperation(&operationObj)
+	go workflow.NotifyWorkflowOperation(&operationObj)
 	middleware.ReturnSuccess(c)
 }
 
@@ -526,7 +596,7 @@ func ProcInstanceCallback(c *gin.Context) {
 		middleware.ReturnError(c, err)
 		return
 	}
-	go workflow.HandleProOperation(&operationObj)
+	go workflow.NotifyWorkflowOperation(&operationObj)
 	middleware.ReturnSuccess(c)
 }
 
@@ -561,7 +631,21 @@ func ProcTermination(c *gin.Context) {
 		middleware.ReturnError(c, err)
 		return
 	}
-	go workflow.HandleProOperation(&operationObj)
+	go workflow.NotifyWorkflowOperation(&operationObj)
+	middleware.ReturnSuccess(c)
+}
+
+// NotifyProcOperation 其它实例新增了本实例运行中工作流的操作后通知过来直接处理
+func NotifyProcOperation(c *gin.Context) {
+	operationId, err := strconv.ParseInt(c.Param("operationId"), 10, 64)
+	if err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("operationId illegal")))
+		return
+	}
+	if err = workflow.HandleNotifyOperation(operationId); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().DatabaseQueryError, err))
+		return
+	}
 	middleware.ReturnSuccess(c)
 }
 
@@ -595,3 +679,23 @@ func GetProcNodeAllowOptions(c *gin.Context) {
 		middleware.ReturnData(c, options)
 	}
 }
+
+// SearchProcIns 按关键字检索编排执行纪录,匹配编排数据、节点报错和插件请求参数
+func SearchProcIns(c *gin.Context) {
+	var param models.ProcInsSearchParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
+		return
+	}
+	tokens := tools.SplitSearchTokens(param.Keyword, models.ProcSearchMaxKeywordTokens)
+	if len(tokens) == 0 {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("keyword illegal")))
+		return
+	}
+	result, err := database.SearchProcIns(c, &param, tokens)
+	if err != nil {
+		middleware.ReturnError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
@@ -65,6 +65,10 @@ func AddOrUpdateProcessDefinition(c *gin.Context) {
 		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("request param err,permissionToRole MGMT is empty")))
 		return
 	}
+	if param.SlaMinutes < 0 {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("request param err,slaMinutes can not be negative")))
+		return
+	}
 	// 判断名称和版本是否重复
 	repeatNameList, err = database.GetProcessDefinitionByCondition(c, models.ProcDefCondition{Name: param.Name})
 	if err != nil {
@@ -114,6 +118,7 @@ func AddOrUpdateProcessDefinition(c *gin.Context) {
 			ForPlugin:     strings.Join(param.AuthPlugins, ","),
 			Scene:         param.Scene,
 			ConflictCheck: param.ConflictCheck,
+			SlaMinutes:    param.SlaMinutes,
 			UpdatedBy:     middleware.GetRequestUser(c),
 			UpdatedTime:   time.Now(),
 		}
@@ -500,6 +505,11 @@ func DeployProcessDefinition(c *gin.Context) {
 		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("this procDef status is not draft")))
 		return
 	}
+	// 静态校验有错误的编排不能发布
+	if err = checkProcDefValidateErrors(c, procDefId); err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
 	// 检查节点的合法性
 	if err = checkDeployedProcDef(c, procDefId); err != nil {
 		middleware.ReturnError(c, err)
@@ -632,6 +642,55 @@ func AddOrUpdateProcDefTaskNodes(c *gin.Context) {
 	if param.ProcDefNodeCustomAttrs.RoutineExpression != "" && param.ProcDefNodeCustomAttrs.NodeType != string(models.ProcDefNodeTypeData) {
 		param.ProcDefNodeCustomAttrs.RoutineExpression = strings.ReplaceAll(param.ProcDefNodeCustomAttrs.RoutineExpression, "#DMEOP#", "")
 	}
+	// 多实例执行仅支持自动节点
+	if multiInstance := param.ProcDefNodeCustomAttrs.MultiInstance; multiInstance != nil && multiInstance.Enable {
+		if param.ProcDefNodeCustomAttrs.NodeType != string(models.ProcDefNodeTypeAutomatic) || multiInstance.Concurrency < 0 || !multiInstance.ValidThreshold() {
+			middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("multiInstance config illegal,only automatic node support and threshold must be all|any|N%%")))
+			return
+		}
+	}
+	// 自动重试仅支持自动节点和数据节点
+	if retryPolicy := param.ProcDefNodeCustomAttrs.RetryPolicy; retryPolicy != nil && retryPolicy.MaxAttempts > 1 {
+		nodeType := param.ProcDefNodeCustomAttrs.NodeType
+		if (nodeType != string(models.ProcDefNodeTypeAutomatic) && nodeType != string(models.ProcDefNodeTypeData)) || retryPolicy.InitialBackoff < 0 || retryPolicy.Multiplier < 0 || retryPolicy.MaxBackoff < 0 {
+			middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("retryPolicy config illegal,only automatic and data node support and backoff must not be negative")))
+			return
+		}
+	}
+	// 人工任务配置仅支持人工节点
+	if humanTaskConfig := param.ProcDefNodeCustomAttrs.HumanTaskConfig; humanTaskConfig != nil {
+		if param.ProcDefNodeCustomAttrs.NodeType != string(models.ProcDefNodeTypeHuman) || !humanTaskConfig.ValidAssignee() || humanTaskConfig.SlaMinutes < 0 || humanTaskConfig.RemindMinutes < 0 ||
+			humanTaskConfig.EscalationMinutes < 0 || (humanTaskConfig.EscalationMinutes > 0 && humanTaskConfig.EscalationRole == "") {
+			middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("humanTaskConfig illegal,only human node support,assigneeType must be role|user and escalation need escalationRole")))
+			return
+		}
+	}
+	if param.ProcDefNodeCustomAttrs.ExpectedMinutes < 0 {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("expectedMinutes can not be negative")))
+		return
+	}
+	// 审批配置仅支持审批节点
+	if approvalConfig := param.ProcDefNodeCustomAttrs.ApprovalConfig; approvalConfig != nil {
+		if param.ProcDefNodeCustomAttrs.NodeType != string(models.ProcDefNodeTypeApproval) {
+			middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("approvalConfig only support approval node")))
+			return
+		}
+		if err = checkApprovalConfig(models.ConvertApprovalConfigDto2String(approvalConfig)); err != nil {
+			middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("approvalConfig illegal,%s", err.Error())))
+			return
+		}
+	}
+	// 循环配置仅支持循环节点
+	if loopConfig := param.ProcDefNodeCustomAttrs.LoopConfig; loopConfig != nil {
+		if param.ProcDefNodeCustomAttrs.NodeType != string(models.ProcDefNodeTypeLoop) {
+			middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("loopConfig only support loop node")))
+			return
+		}
+		if err = checkLoopConfig(models.ConvertLoopConfigDto2String(loopConfig)); err != nil {
+			middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("loopConfig illegal,%s", err.Error())))
+			return
+		}
+	}
 	procDef, err = database.GetProcessDefinition(c, param.ProcDefNodeCustomAttrs.ProcDefId)
 	if err != nil {
 		middleware.ReturnError(c, err)
@@ -954,6 +1013,21 @@ func AddOrUpdateProcDefNodeLink(c *gin.Context) {
 		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("param id is empty")))
 		return
 	}
+	if linkType := param.ProcDefNodeLinkCustomAttrs.LinkType; linkType != "" && linkType != models.ProcDefLinkTypeTimeout && linkType != models.ProcDefLinkTypeError &&
+		linkType != models.ProcDefLinkTypeLoopBack && linkType != models.ProcDefLinkTypeLoopExit && linkType != models.ProcDefLinkTypeReject {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("param linkType:%s is illegal", linkType)))
+		return
+	}
+	if condition := param.ProcDefNodeLinkCustomAttrs.Condition; condition != "" {
+		if param.ProcDefNodeLinkCustomAttrs.IsDefault {
+			middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("default link can not set condition")))
+			return
+		}
+		if err = tools.ValidateConditionExpr(condition); err != nil {
+			middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
+			return
+		}
+	}
 	procDef, err = database.GetProcessDefinition(c, param.ProcDefId)
 	if err != nil {
 		middleware.ReturnError(c, err)
@@ -981,6 +1055,25 @@ func AddOrUpdateProcDefNodeLink(c *gin.Context) {
 		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("targetNode is empty")))
 		return
 	}
+	// 分支条件和默认分支仅支持判断节点的出线
+	if (param.ProcDefNodeLinkCustomAttrs.Condition != "" || param.ProcDefNodeLinkCustomAttrs.IsDefault) && sourceNode.NodeType != string(models.ProcDefNodeTypeDecision) {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("condition only support decision node link")))
+		return
+	}
+	// 循环回线必须回到循环节点,循环结束出线必须从循环节点出
+	if param.ProcDefNodeLinkCustomAttrs.LinkType == models.ProcDefLinkTypeLoopBack && targetNode.NodeType != string(models.ProcDefNodeTypeLoop) {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("loop back link target must be loop node")))
+		return
+	}
+	if param.ProcDefNodeLinkCustomAttrs.LinkType == models.ProcDefLinkTypeLoopExit && sourceNode.NodeType != string(models.ProcDefNodeTypeLoop) {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("loop exit link source must be loop node")))
+		return
+	}
+	// 驳回分支必须从审批节点出
+	if param.ProcDefNodeLinkCustomAttrs.LinkType == models.ProcDefLinkTypeReject && sourceNode.NodeType != string(models.ProcDefNodeTypeApproval) {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("reject link source must be approval node")))
+		return
+	}
 	procDefNodeLink, err = database.GetProcDefNodeLink(c, param.ProcDefId, param.ProcDefNodeLinkCustomAttrs.Id)
 	if err != nil {
 		middleware.ReturnError(c, err)
@@ -1379,6 +1472,32 @@ func processDefinitionImport(ctx context.Context, inputList []*models.ProcessDef
 	return
 }
 
+// checkSubProcDefCycle 沿子编排逐级往下找,子编排直接或间接又引用回当前编排时认为循环引用
+func checkSubProcDefCycle(ctx context.Context, procDefId, subProcDefId string) (cycleFlag bool, err error) {
+	visitMap := map[string]bool{subProcDefId: true}
+	queue := []string{subProcDefId}
+	for len(queue) > 0 {
+		curProcDefId := queue[0]
+		queue = queue[1:]
+		subProcDefIds, getErr := database.GetProcDefSubProcDefIds(ctx, curProcDefId)
+		if getErr != nil {
+			err = getErr
+			return
+		}
+		for _, nextId := range subProcDefIds {
+			if nextId == procDefId {
+				cycleFlag = true
+				return
+			}
+			if !visitMap[nextId] {
+				visitMap[nextId] = true
+				queue = append(queue, nextId)
+			}
+		}
+	}
+	return
+}
+
 /*
 *
 checkDeployedProcDefNode
@@ -1390,6 +1509,7 @@ checkDeployedProcDefNode
 5、不能有环路
 6. 开始结束节点都不超过一个
 7. 判断节点出的线,必须有名字并且同一个判断节点的所有线的名字不能相同
+8. 审批节点单进单出,最多一条驳回分支,且必须配置审批阶段
 */
 func checkDeployedProcDef(ctx context.Context, procDefId string) error {
 	var inCount, outCount int
@@ -1424,13 +1544,53 @@ func checkDeployedProcDef(ctx context.Context, procDefId string) error {
 		nodeMap[node.Id] = node
 		nodeIdKeymap[node.NodeId] = node
 		sortNodeIds = append(sortNodeIds, node.Id)
+		boundaryLinkMap := make(map[string]int)
+		loopBackCount, loopExitCount, rejectCount := 0, 0, 0
 		for _, link := range linkList {
 			if link.Source == node.Id {
+				// 循环回线计入循环体末尾节点的出线,循环结束出线单独计数
+				if link.LinkType == models.ProcDefLinkTypeLoopBack {
+					outCount++
+					continue
+				}
+				if link.LinkType == models.ProcDefLinkTypeLoopExit {
+					loopExitCount++
+					continue
+				}
+				if link.LinkType == models.ProcDefLinkTypeReject {
+					rejectCount++
+					continue
+				}
+				// 超时和异常分支线不计入节点出线
+				if link.LinkType != "" {
+					boundaryLinkMap[link.LinkType]++
+					continue
+				}
 				outCount++
 			} else if link.Target == node.Id {
+				if link.LinkType == models.ProcDefLinkTypeLoopBack {
+					loopBackCount++
+					continue
+				}
 				inCount++
 			}
 		}
+		if node.NodeType != string(models.ProcDefNodeTypeLoop) && (loopBackCount > 0 || loopExitCount > 0) {
+			return exterror.New().ProcDefLoopNodeIllegalError.WithParam(node.Name, "loop back or loop exit link only supported on loop node")
+		}
+		if node.NodeType != string(models.ProcDefNodeTypeApproval) && rejectCount > 0 {
+			return exterror.New().ProcDefApprovalNodeIllegalError.WithParam(node.Name, "reject link only supported on approval node")
+		}
+		if len(boundaryLinkMap) > 0 {
+			if !checkBoundaryLinkNodeType(node.NodeType) {
+				return exterror.New().ProcDefNodeBoundaryLinkIllegalError.WithParam(node.Name)
+			}
+			for linkType, linkCount := range boundaryLinkMap {
+				if (linkType != models.ProcDefLinkTypeTimeout && linkType != models.ProcDefLinkTypeError) || linkCount > 1 {
+					return exterror.New().ProcDefNodeBoundaryLinkIllegalError.WithParam(node.Name)
+				}
+			}
+		}
 		switch models.ProcDefNodeType(node.NodeType) {
 		case models.ProcDefNodeTypeStart:
 			startNodeNameList = append(startNodeNameList, node.Name)
@@ -1447,6 +1607,22 @@ func checkDeployedProcDef(ctx context.Context, procDefId string) error {
 			if !(inCount > 1 && outCount == 1) {
 				return exterror.New().ProcDefNode20000005Error.WithParam(node.Name)
 			}
+		case models.ProcDefNodeTypeLoop:
+			// 循环节点单进,一条线进入循环体,一条回线回到循环节点,一条循环结束出线
+			if inCount != 1 || outCount != 1 || loopBackCount != 1 || loopExitCount != 1 {
+				return exterror.New().ProcDefLoopNodeIllegalError.WithParam(node.Name, "loop node need one in link,one body link,one loop back link and one loop exit link")
+			}
+			if err2 := checkLoopConfig(node.LoopConfig); err2 != nil {
+				return exterror.New().ProcDefLoopNodeIllegalError.WithParam(node.Name, err2.Error())
+			}
+		case models.ProcDefNodeTypeApproval:
+			// 审批节点单进单出,最多一条驳回分支
+			if inCount != 1 || outCount != 1 || rejectCount > 1 {
+				return exterror.New().ProcDefApprovalNodeIllegalError.WithParam(node.Name, "approval node need one in link,one out link and at most one reject link")
+			}
+			if err2 := checkApprovalConfig(node.ApprovalConfig); err2 != nil {
+				return exterror.New().ProcDefApprovalNodeIllegalError.WithParam(node.Name, err2.Error())
+			}
 		case models.ProcDefNodeTypeDecision:
 			//  判断节点出的线,必须有名字并且同一个判断节点的所有线的名字不能相同
 			var tempLinkNameMap = make(map[string]bool)
@@ -1461,6 +1637,9 @@ func checkDeployedProcDef(ctx context.Context, procDefId string) error {
 					}
 					tempLinkNameMap[link.Name] = true
 				}
+				if err2 = checkDecisionConditionLinks(nodeLinkList); err2 != nil {
+					return exterror.New().ProcDefDecisionConditionIllegalError.WithParam(node.Name, err2.Error())
+				}
 			}
 		default:
 			// 任务三种节点,插件服务不能为空
@@ -1477,6 +1656,24 @@ func checkDeployedProcDef(ctx context.Context, procDefId string) error {
 					return exterror.New().ProcDefNodeDateEmptyError.WithParam(node.Name)
 				}
 			}
+			// 子编排节点,必须关联其它已发布的编排
+			if node.NodeType == string(models.ProcDefNodeTypeSubProcess) {
+				if strings.TrimSpace(node.SubProcDefId) == "" || node.SubProcDefId == procDefId {
+					return exterror.New().ProcDefNodeSubProcIllegalError.WithParam(node.Name)
+				}
+				subProcDef, getSubErr := database.GetProcessDefinition(ctx, node.SubProcDefId)
+				if getSubErr != nil {
+					return getSubErr
+				}
+				if subProcDef == nil || subProcDef.Status != string(models.Deployed) {
+					return exterror.New().ProcDefNodeSubProcIllegalError.WithParam(node.Name)
+				}
+				if cycleFlag, cycleErr := checkSubProcDefCycle(ctx, procDefId, node.SubProcDefId); cycleErr != nil {
+					return cycleErr
+				} else if cycleFlag {
+					return exterror.New().ProcDefNodeSubProcIllegalError.WithParam(node.Name)
+				}
+			}
 			// 任务3种节点、时间2种节点仅支持单进单出.
 			if inCount != 1 || outCount != 1 {
 				return exterror.New().ProcDefNode20000010Error.WithParam(node.Name)
@@ -1489,9 +1686,20 @@ func checkDeployedProcDef(ctx context.Context, procDefId string) error {
 		endNodeName := strings.Join(endNodeNameList, ",")
 		return exterror.New().ProcDefNode20000007Error.WithParam(startNodeName, endNodeName)
 	}
+	for _, node := range list {
+		if node.NodeType != string(models.ProcDefNodeTypeLoop) {
+			continue
+		}
+		if err = checkLoopBody(node, nodeMap, linkList); err != nil {
+			return exterror.New().ProcDefLoopNodeIllegalError.WithParam(node.Name, err.Error())
+		}
+	}
 	// 线的两边不能都是分流或汇聚
 	for _, link := range linkList {
-		sortLinks = append(sortLinks, []string{link.Source, link.Target})
+		// 循环回线是结构化的环,不参与环路检测和排序
+		if link.LinkType != models.ProcDefLinkTypeLoopBack {
+			sortLinks = append(sortLinks, []string{link.Source, link.Target})
+		}
 		if v, ok := nodeMap[link.Source]; ok && nodeMap[link.Target] != nil {
 			if v.NodeType == string(models.ProcDefNodeTypeMerge) || v.NodeType == string(models.ProcDefNodeTypeFork) {
 				if v.NodeType == string(models.ProcDefNodeTypeMerge) && (nodeMap[link.Target] == v || nodeMap[link.Target].NodeType == string(models.ProcDefNodeTypeFork)) {
@@ -1511,6 +1719,122 @@ func checkDeployedProcDef(ctx context.Context, procDefId string) error {
 	return database.UpdateProcDefNodeOrder(ctx, sortNodeIdMap)
 }
 
+// checkDecisionConditionLinks 判断节点使用分支条件时,除唯一的默认分支外每条线都要有合法的条件表达式
+func checkDecisionConditionLinks(linkList []*models.ProcDefNodeLink) error {
+	conditionFlag := false
+	for _, link := range linkList {
+		if link.LinkType == "" && (link.Condition != "" || link.IsDefault) {
+			conditionFlag = true
+			break
+		}
+	}
+	if !conditionFlag {
+		return nil
+	}
+	defaultCount := 0
+	for _, link := range linkList {
+		if link.LinkType != "" {
+			continue
+		}
+		if link.IsDefault {
+			if link.Condition != "" {
+				return fmt.Errorf("default link %s can not set condition", link.Name)
+			}
+			defaultCount++
+			continue
+		}
+		if err := tools.ValidateConditionExpr(link.Condition); err != nil {
+			return fmt.Errorf("link %s %s", link.Name, err.Error())
+		}
+	}
+	if defaultCount > 1 {
+		return fmt.Errorf("only one default link allowed")
+	}
+	return nil
+}
+
+// checkLoopConfig 循环节点必须配置大于0的最大循环次数和合法的退出条件
+func checkLoopConfig(loopConfig string) error {
+	loopConfigDto := models.ConvertString2LoopConfigDto(loopConfig)
+	if loopConfigDto == nil {
+		return fmt.Errorf("loop config can not empty")
+	}
+	if loopConfigDto.MaxIterations <= 0 {
+		return fmt.Errorf("max iterations must greater than 0")
+	}
+	if err := tools.ValidateConditionExpr(loopConfigDto.ExitCondition); err != nil {
+		return fmt.Errorf("exit condition %s", err.Error())
+	}
+	return nil
+}
+
+// checkApprovalConfig 审批节点至少一个阶段,每个阶段要有审批角色和合法的通过规则
+func checkApprovalConfig(approvalConfig string) error {
+	approvalConfigDto := models.ConvertString2ApprovalConfigDto(approvalConfig)
+	if approvalConfigDto == nil || len(approvalConfigDto.Stages) == 0 {
+		return fmt.Errorf("approval stages can not empty")
+	}
+	for i, stage := range approvalConfigDto.Stages {
+		if stage == nil || len(stage.Roles) == 0 {
+			return fmt.Errorf("stage %d roles can not empty", i+1)
+		}
+		if !stage.ValidQuorum() {
+			return fmt.Errorf("stage %d quorum:%s illegal,must be all|any|N", i+1, stage.Quorum)
+		}
+	}
+	return nil
+}
+
+// checkLoopBody 循环体是从循环节点入线出发能到达的节点,循环体内节点只能由循环体内节点或循环节点进入,且必须经回线回到循环节点
+func checkLoopBody(loopNode *models.ProcDefNode, nodeMap map[string]*models.ProcDefNode, linkList []*models.ProcDefNodeLink) error {
+	var bodyEntryLink, loopBackLink *models.ProcDefNodeLink
+	for _, link := range linkList {
+		if link.Source == loopNode.Id && link.LinkType == "" {
+			bodyEntryLink = link
+		}
+		if link.Target == loopNode.Id && link.LinkType == models.ProcDefLinkTypeLoopBack {
+			loopBackLink = link
+		}
+	}
+	if bodyEntryLink == nil || loopBackLink == nil {
+		return fmt.Errorf("loop body link or loop back link not found")
+	}
+	bodyNodeMap := map[string]bool{bodyEntryLink.Target: true}
+	bodyNodeIds := []string{bodyEntryLink.Target}
+	for i := 0; i < len(bodyNodeIds); i++ {
+		for _, link := range linkList {
+			if link.Source == bodyNodeIds[i] && link.Target != loopNode.Id && !bodyNodeMap[link.Target] {
+				bodyNodeMap[link.Target] = true
+				bodyNodeIds = append(bodyNodeIds, link.Target)
+			}
+		}
+	}
+	for _, nodeId := range bodyNodeIds {
+		if bodyNode, ok := nodeMap[nodeId]; ok && bodyNode.NodeType == string(models.ProcDefNodeTypeEnd) {
+			return fmt.Errorf("loop body can not contain end node %s", bodyNode.Name)
+		}
+	}
+	if !bodyNodeMap[loopBackLink.Source] {
+		return fmt.Errorf("loop back link must start from loop body")
+	}
+	for _, link := range linkList {
+		if !bodyNodeMap[link.Target] || bodyNodeMap[link.Source] || link == bodyEntryLink {
+			continue
+		}
+		return fmt.Errorf("loop body can only be entered from loop node")
+	}
+	return nil
+}
+
+// checkBoundaryLinkNodeType 超时和异常分支只支持任务节点
+func checkBoundaryLinkNodeType(nodeType string) bool {
+	switch models.ProcDefNodeType(nodeType) {
+	case models.ProcDefNodeTypeAutomatic, models.ProcDefNodeTypeData, models.ProcDefNodeTypeHuman, models.ProcDefNodeTypeSubProcess, models.ProcDefNodeTypeApproval:
+		return true
+	}
+	return false
+}
+
 // checkProcDefNodeNameRepeat 判断节点名称是否重复
 func checkProcDefNodeNameRepeat(list []*models.ProcDefNode) string {
 	var hashMap = make(map[string]bool)
@@ -0,0 +1,59 @@
+package process
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/api/middleware"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/gin-gonic/gin"
+)
+
+// GetProcDefSlaStatistics 编排时限达标统计,时间范围按实例创建时间,默认最近30天
+func GetProcDefSlaStatistics(c *gin.Context) {
+	procDef, err := database.GetProcessDefinition(c, c.Param("proc-def-id"))
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if procDef == nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("procDefId is invalid")))
+		return
+	}
+	endTime := time.Now()
+	startTime := endTime.AddDate(0, 0, -30)
+	if startTimeString := c.Query("startTime"); startTimeString != "" {
+		if startTime, err = time.ParseInLocation(models.DateTimeFormat, startTimeString, time.Local); err != nil {
+			middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("startTime format must be %s", models.DateTimeFormat)))
+			return
+		}
+	}
+	if endTimeString := c.Query("endTime"); endTimeString != "" {
+		if endTime, err = time.ParseInLocation(models.DateTimeFormat, endTimeString, time.Local); err != nil {
+			middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("endTime format must be %s", models.DateTimeFormat)))
+			return
+		}
+	}
+	if !startTime.Before(endTime) {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("startTime must before endTime")))
+		return
+	}
+	result, err := database.GetProcDefSlaStatistics(c, procDef, startTime, endTime)
+	if err != nil {
+		middleware.ReturnError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
+
+// GetProcInsSlaBreaches 编排实例和节点的超时纪录
+func GetProcInsSlaBreaches(c *gin.Context) {
+	result, err := database.GetProcInsSlaBreaches(c, c.Param("procInsId"))
+	if err != nil {
+		middleware.ReturnError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
@@ -0,0 +1,120 @@
+package process
+
+import (
+	"fmt"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/api/middleware"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/execution"
+	"github.com/gin-gonic/gin"
+)
+
+// GetPendingHumanTasks 当前用户的待办人工任务
+func GetPendingHumanTasks(c *gin.Context) {
+	result, err := database.GetUserPendingHumanTasks(c, middleware.GetRequestUser(c), middleware.GetRequestRoles(c))
+	if err != nil {
+		middleware.ReturnError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
+
+// ReassignHumanTask 编排管理角色转派人工任务
+func ReassignHumanTask(c *gin.Context) {
+	var param models.HumanTaskAssignParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
+		return
+	}
+	task, err := database.GetHumanTask(c, c.Param("taskId"))
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if err = checkHumanTaskMgmtPermission(c, task); err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if err = execution.ReassignHumanTask(c, task, &param, middleware.GetRequestUser(c)); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
+		return
+	}
+	middleware.ReturnSuccess(c)
+}
+
+// DelegateHumanTask 当前处理人委托人工任务给其它用户
+func DelegateHumanTask(c *gin.Context) {
+	var param models.HumanTaskAssignParam
+	if err := c.ShouldBindJSON(&param); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
+		return
+	}
+	if param.AssigneeType != "" && param.AssigneeType != models.HumanTaskAssigneeUser {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("delegate only support user")))
+		return
+	}
+	task, err := database.GetHumanTask(c, c.Param("taskId"))
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	operator := middleware.GetRequestUser(c)
+	if !execution.CheckHumanTaskAssignee(task, operator, middleware.GetRequestRoles(c)) {
+		middleware.ReturnError(c, exterror.New().DataPermissionDeny)
+		return
+	}
+	if param.Assignee == operator {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("can not delegate to yourself")))
+		return
+	}
+	if err = execution.DelegateHumanTask(c, task, &param, operator); err != nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
+		return
+	}
+	middleware.ReturnSuccess(c)
+}
+
+// GetHumanTaskLogs 人工任务的创建、转派、委托、升级和提醒纪录,任务处理人或有编排管理权限的用户可查看
+func GetHumanTaskLogs(c *gin.Context) {
+	task, err := database.GetHumanTask(c, c.Param("taskId"))
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if !execution.CheckHumanTaskAssignee(task, middleware.GetRequestUser(c), middleware.GetRequestRoles(c)) {
+		if err = checkHumanTaskMgmtPermission(c, task); err != nil {
+			middleware.ReturnError(c, err)
+			return
+		}
+	}
+	result, err := database.GetHumanTaskLogs(c, task.Id)
+	if err != nil {
+		middleware.ReturnError(c, err)
+	} else {
+		middleware.ReturnData(c, result)
+	}
+}
+
+// checkHumanTaskMgmtPermission 用户需要有任务所在编排的管理权限
+func checkHumanTaskMgmtPermission(c *gin.Context, task *models.ProcRunHumanTask) error {
+	procIns, err := database.GetSimpleProcInsRow(c, task.ProcInsId)
+	if err != nil {
+		return err
+	}
+	permissionList, err := database.GetProcDefPermissionByCondition(c, models.ProcDefPermission{ProcDefId: procIns.ProcDefId, Permission: string(models.MGMT)})
+	if err != nil {
+		return err
+	}
+	userRoleMap := make(map[string]bool)
+	for _, role := range middleware.GetRequestRoles(c) {
+		userRoleMap[role] = true
+	}
+	for _, permission := range permissionList {
+		if userRoleMap[permission.RoleName] {
+			return nil
+		}
+	}
+	return exterror.New().DataPermissionDeny
+}
@@ -0,0 +1,341 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/api/middleware"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/tools"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
+	"github.com/gin-gonic/gin"
+)
+
+// 编排校验规则
+const (
+	validateRuleStartNode         = "startNode"
+	validateRuleEndNode           = "endNode"
+	validateRuleLoop              = "loop"
+	validateRuleUnreachable       = "unreachable"
+	validateRuleDecisionBranch    = "decisionBranch"
+	validateRuleDynamicBind       = "dynamicBind"
+	validateRuleContextParam      = "contextParam"
+	validateRuleRoutineExpression = "routineExpression"
+	validateRulePluginService     = "pluginService"
+	validateRuleLoopNode          = "loopNode"
+	validateRuleApprovalNode      = "approvalNode"
+)
+
+// procDefValidator 编排静态校验,收集错误和警告,有错误的编排不能发布
+type procDefValidator struct {
+	ctx         context.Context
+	procDef     *models.ProcDef
+	nodes       []*models.ProcDefNode
+	links       []*models.ProcDefNodeLink
+	nodeMap     map[string]*models.ProcDefNode // key:node.Id
+	nodeIdMap   map[string]*models.ProcDefNode // key:node.NodeId
+	entityMap   map[string]bool                // 数据模型实体是否存在缓存
+	serviceMap  map[string]bool                // 插件服务是否可用缓存
+	result      *models.ProcDefValidateResult
+	upstreamMap map[string]map[string]bool // 节点的所有上游节点
+}
+
+// ValidateProcessDefinition 校验编排,返回错误和警告
+func ValidateProcessDefinition(c *gin.Context) {
+	procDefId := c.Param("proc-def-id")
+	if procDefId == "" {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("proc-def-id is empty")))
+		return
+	}
+	result, err := validateProcDef(c, procDefId)
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	middleware.ReturnData(c, result)
+}
+
+// checkProcDefValidateErrors 发布前校验,有错误时返回
+func checkProcDefValidateErrors(ctx context.Context, procDefId string) error {
+	result, err := validateProcDef(ctx, procDefId)
+	if err != nil {
+		return err
+	}
+	if result.Pass {
+		return nil
+	}
+	var messageList []string
+	for _, item := range result.Errors {
+		messageList = append(messageList, item.Message)
+	}
+	return exterror.New().ProcDefValidateError.WithParam(strconv.Itoa(len(result.Errors)), strings.Join(messageList, "; "))
+}
+
+func validateProcDef(ctx context.Context, procDefId string) (result *models.ProcDefValidateResult, err error) {
+	v := &procDefValidator{ctx: ctx, nodeMap: make(map[string]*models.ProcDefNode), nodeIdMap: make(map[string]*models.ProcDefNode),
+		entityMap: make(map[string]bool), serviceMap: make(map[string]bool), upstreamMap: make(map[string]map[string]bool),
+		result: &models.ProcDefValidateResult{Errors: []*models.ProcDefValidateItem{}, Warnings: []*models.ProcDefValidateItem{}}}
+	if v.procDef, err = database.GetProcessDefinition(ctx, procDefId); err != nil {
+		return
+	}
+	if v.procDef == nil {
+		err = exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("proc-def-id is invalid"))
+		return
+	}
+	if v.nodes, err = database.GetProcDefNodeById(ctx, procDefId); err != nil {
+		return
+	}
+	if v.links, err = database.GetProcDefNodeLinkListByProcDefId(ctx, procDefId); err != nil {
+		return
+	}
+	for _, node := range v.nodes {
+		v.nodeMap[node.Id] = node
+		v.nodeIdMap[node.NodeId] = node
+	}
+	// 有环路或没有开始节点时不再做依赖执行顺序的校验
+	v.validateNodes(v.validateGraph())
+	v.result.Pass = len(v.result.Errors) == 0
+	result = v.result
+	return
+}
+
+func (v *procDefValidator) addError(rule string, node *models.ProcDefNode, message string) {
+	v.result.Errors = append(v.result.Errors, v.buildItem(rule, node, message))
+}
+
+func (v *procDefValidator) addWarning(rule string, node *models.ProcDefNode, message string) {
+	v.result.Warnings = append(v.result.Warnings, v.buildItem(rule, node, message))
+}
+
+func (v *procDefValidator) buildItem(rule string, node *models.ProcDefNode, message string) *models.ProcDefValidateItem {
+	item := &models.ProcDefValidateItem{Rule: rule, Message: message}
+	if node != nil {
+		item.NodeId = node.NodeId
+		item.NodeName = node.Name
+		item.Message = fmt.Sprintf("[%s] %s", node.Name, message)
+	}
+	return item
+}
+
+// validateGraph 校验开始结束节点、环路和不可达节点,返回是否能继续做执行顺序相关的校验
+func (v *procDefValidator) validateGraph() bool {
+	var startNodes, endNodes []*models.ProcDefNode
+	var nodeIds []string
+	var sortLinks [][]string
+	for _, node := range v.nodes {
+		nodeIds = append(nodeIds, node.Id)
+		switch models.ProcDefNodeType(node.NodeType) {
+		case models.ProcDefNodeTypeStart:
+			startNodes = append(startNodes, node)
+		case models.ProcDefNodeTypeEnd:
+			endNodes = append(endNodes, node)
+		}
+	}
+	if len(endNodes) == 0 {
+		v.addWarning(validateRuleEndNode, nil, "process has no end node")
+	}
+	if len(startNodes) != 1 {
+		v.addError(validateRuleStartNode, nil, fmt.Sprintf("process must have exactly one start node,found %d", len(startNodes)))
+		return false
+	}
+	for _, link := range v.links {
+		// 循环回线是循环节点的结构化回路,不算环路
+		if link.LinkType == models.ProcDefLinkTypeLoopBack {
+			continue
+		}
+		sortLinks = append(sortLinks, []string{link.Source, link.Target})
+	}
+	if _, isLoop := tools.ProcNodeSort(nodeIds, sortLinks); isLoop {
+		v.addError(validateRuleLoop, nil, "process has loop")
+		return false
+	}
+	// 从开始节点出发,沿普通线和超时、异常分支线都走不到的节点不会执行
+	reachMap := map[string]bool{startNodes[0].Id: true}
+	queue := []string{startNodes[0].Id}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, link := range v.links {
+			if link.Source == cur && !reachMap[link.Target] {
+				reachMap[link.Target] = true
+				queue = append(queue, link.Target)
+			}
+		}
+	}
+	for _, node := range v.nodes {
+		if !reachMap[node.Id] {
+			v.addError(validateRuleUnreachable, node, "node is unreachable from start node")
+		}
+	}
+	return true
+}
+
+// getUpstreamNodes 查找节点的所有上游节点
+func (v *procDefValidator) getUpstreamNodes(nodeId string) map[string]bool {
+	if upstream, ok := v.upstreamMap[nodeId]; ok {
+		return upstream
+	}
+	upstream := make(map[string]bool)
+	queue := []string{nodeId}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, link := range v.links {
+			if link.LinkType == models.ProcDefLinkTypeLoopBack {
+				continue
+			}
+			if link.Target == cur && !upstream[link.Source] {
+				upstream[link.Source] = true
+				queue = append(queue, link.Source)
+			}
+		}
+	}
+	v.upstreamMap[nodeId] = upstream
+	return upstream
+}
+
+func (v *procDefValidator) validateNodes(checkOrder bool) {
+	if strings.TrimSpace(v.procDef.RootEntity) != "" {
+		v.validateExpression(nil, v.procDef.RootEntity)
+	}
+	for _, node := range v.nodes {
+		switch models.ProcDefNodeType(node.NodeType) {
+		case models.ProcDefNodeTypeDecision:
+			v.validateDecision(node)
+		case models.ProcDefNodeTypeAutomatic, models.ProcDefNodeTypeHuman:
+			v.validatePluginService(node)
+			v.validateRoutineExpression(node)
+		case models.ProcDefNodeTypeData:
+			v.validateRoutineExpression(node)
+		case models.ProcDefNodeTypeLoop:
+			if err := checkLoopConfig(node.LoopConfig); err != nil {
+				v.addError(validateRuleLoopNode, node, err.Error())
+			} else if err = checkLoopBody(node, v.nodeMap, v.links); err != nil {
+				v.addError(validateRuleLoopNode, node, err.Error())
+			}
+		case models.ProcDefNodeTypeApproval:
+			if err := checkApprovalConfig(node.ApprovalConfig); err != nil {
+				v.addError(validateRuleApprovalNode, node, err.Error())
+			}
+		}
+		if node.DynamicBind && node.BindNodeId != "" {
+			if bindNode, ok := v.nodeIdMap[node.BindNodeId]; !ok {
+				v.addError(validateRuleDynamicBind, node, fmt.Sprintf("dynamic bind node %s not exist", node.BindNodeId))
+			} else if checkOrder && !v.getUpstreamNodes(node.Id)[bindNode.Id] {
+				v.addError(validateRuleDynamicBind, node, fmt.Sprintf("dynamic bind node %s does not run before this node", bindNode.Name))
+			}
+		}
+		v.validateContextParams(node, checkOrder)
+	}
+}
+
+// validateDecision 判断节点必须有分支
+func (v *procDefValidator) validateDecision(node *models.ProcDefNode) {
+	branchCount := 0
+	for _, link := range v.links {
+		if link.Source == node.Id && link.LinkType == "" {
+			branchCount++
+		}
+	}
+	if branchCount == 0 {
+		v.addError(validateRuleDecisionBranch, node, "decision node has no branch")
+	} else if branchCount == 1 {
+		v.addWarning(validateRuleDecisionBranch, node, "decision node has only one branch")
+	}
+}
+
+// validateContextParams 上下文参数绑定的节点必须存在并且在当前节点之前执行
+func (v *procDefValidator) validateContextParams(node *models.ProcDefNode, checkOrder bool) {
+	params, err := database.GetProcDefNodeParamByNodeId(v.ctx, node.Id)
+	if err != nil {
+		v.addError(validateRuleContextParam, node, fmt.Sprintf("query node params fail,%s", err.Error()))
+		return
+	}
+	for _, param := range params {
+		if param.BindType != "context" {
+			continue
+		}
+		bindNode, ok := v.nodeIdMap[param.CtxBindNode]
+		if !ok {
+			v.addError(validateRuleContextParam, node, fmt.Sprintf("param %s bind node %s not exist", param.Name, param.CtxBindNode))
+			continue
+		}
+		if !checkOrder || bindNode.NodeType == string(models.ProcDefNodeTypeStart) {
+			continue
+		}
+		if bindNode.Id == node.Id && strings.EqualFold(param.CtxBindType, "input") {
+			continue
+		}
+		if v.getUpstreamNodes(bindNode.Id)[node.Id] || bindNode.Id == node.Id {
+			v.addError(validateRuleContextParam, node, fmt.Sprintf("param %s bind node %s runs after this node", param.Name, bindNode.Name))
+		} else if !v.getUpstreamNodes(node.Id)[bindNode.Id] {
+			v.addWarning(validateRuleContextParam, node, fmt.Sprintf("param %s bind node %s is on a parallel branch and may not have run", param.Name, bindNode.Name))
+		}
+	}
+}
+
+// validateRoutineExpression 定位规则能解析并且引用的实体在数据模型中存在
+func (v *procDefValidator) validateRoutineExpression(node *models.ProcDefNode) {
+	if strings.TrimSpace(node.RoutineExpression) == "" {
+		return
+	}
+	if node.NodeType != string(models.ProcDefNodeTypeData) {
+		v.validateExpression(node, node.RoutineExpression)
+		return
+	}
+	exprObjList, err := database.GetProcDataNodeExpression(node.RoutineExpression)
+	if err != nil {
+		v.addError(validateRuleRoutineExpression, node, err.Error())
+		return
+	}
+	for _, exprObj := range exprObjList {
+		v.validateExpression(node, exprObj.Expression)
+	}
+}
+
+func (v *procDefValidator) validateExpression(node *models.ProcDefNode, expression string) {
+	exprList, err := remote.AnalyzeExpression(expression)
+	if err != nil {
+		v.addError(validateRuleRoutineExpression, node, fmt.Sprintf("expression %s illegal,%s", expression, err.Error()))
+		return
+	}
+	for _, exprObj := range exprList {
+		entityKey := exprObj.Package + ":" + exprObj.Entity
+		exist, ok := v.entityMap[entityKey]
+		if !ok {
+			if exist, err = database.CheckEntityModelExist(v.ctx, exprObj.Package, exprObj.Entity); err != nil {
+				v.addError(validateRuleRoutineExpression, node, fmt.Sprintf("query entity %s fail,%s", entityKey, err.Error()))
+				continue
+			}
+			v.entityMap[entityKey] = exist
+		}
+		if !exist {
+			v.addError(validateRuleRoutineExpression, node, fmt.Sprintf("expression %s reference entity %s not exist in data model", expression, entityKey))
+		}
+	}
+}
+
+// validatePluginService 任务节点的插件服务必须是启用状态
+func (v *procDefValidator) validatePluginService(node *models.ProcDefNode) {
+	if strings.TrimSpace(node.ServiceName) == "" {
+		v.addError(validateRulePluginService, node, "plugin service is empty")
+		return
+	}
+	enable, ok := v.serviceMap[node.ServiceName]
+	if !ok {
+		interfaceList, err := database.GetAllByServiceNameAndConfigStatus(v.ctx, node.ServiceName, "ENABLED")
+		if err != nil {
+			v.addError(validateRulePluginService, node, fmt.Sprintf("query plugin service %s fail,%s", node.ServiceName, err.Error()))
+			return
+		}
+		enable = len(interfaceList) > 0
+		v.serviceMap[node.ServiceName] = enable
+	}
+	if !enable {
+		v.addError(validateRulePluginService, node, fmt.Sprintf("plugin service %s is disabled or not exist", node.ServiceName))
+	}
+}
@@ -0,0 +1,318 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/api/middleware"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	diffChangeTypeAdd    = "add"
+	diffChangeTypeDelete = "delete"
+	diffChangeTypeModify = "modify"
+)
+
+// procDefDiffData 对比用的编排数据
+type procDefDiffData struct {
+	procDef     *models.ProcDef
+	nodes       []*models.ProcDefNode
+	nodeParams  map[string][]*models.ProcDefNodeParam
+	links       []*models.ProcDefNodeLink
+	permissions []*models.ProcDefPermission
+}
+
+// GetProcDefVersions 查询同一个编排key的所有版本
+func GetProcDefVersions(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("key is empty")))
+		return
+	}
+	list, err := database.GetProcessDefinitionByCondition(c, models.ProcDefCondition{Key: key})
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	sort.Sort(models.ProcDefSort(list))
+	result := []*models.ProcDefVersionDto{}
+	for i := len(list) - 1; i >= 0; i-- {
+		result = append(result, &models.ProcDefVersionDto{
+			Id:          list[i].Id,
+			Key:         list[i].Key,
+			Name:        list[i].Name,
+			Version:     list[i].Version,
+			Status:      list[i].Status,
+			UpdatedBy:   list[i].UpdatedBy,
+			UpdatedTime: list[i].UpdatedTime,
+		})
+	}
+	middleware.ReturnData(c, result)
+}
+
+// DiffProcDefVersion 对比两个编排版本的差异
+func DiffProcDefVersion(c *gin.Context) {
+	sourceId, targetId := c.Query("source"), c.Query("target")
+	if sourceId == "" || targetId == "" {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("source or target is empty")))
+		return
+	}
+	source, err := getProcDefDiffData(c, sourceId)
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	target, err := getProcDefDiffData(c, targetId)
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if source.procDef.Key != target.procDef.Key {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("source and target are not versions of the same procDef")))
+		return
+	}
+	middleware.ReturnData(c, buildProcDefDiff(source, target))
+}
+
+// RollbackProcDefVersion 把旧版本复制成最新版本并直接发布
+func RollbackProcDefVersion(c *gin.Context) {
+	procDefId := c.Param("proc-def-id")
+	user := middleware.GetRequestUser(c)
+	procDef, err := database.GetProcessDefinition(c, procDefId)
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if procDef == nil {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("proc-def-id is invalid")))
+		return
+	}
+	if procDef.Status == string(models.Draft) {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("draft procDef can not rollback")))
+		return
+	}
+	// 同key有草稿时不允许回滚,避免版本号混乱
+	draftList, err := database.GetProcessDefinitionByCondition(c, models.ProcDefCondition{Key: procDef.Key, Status: string(models.Draft)})
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if len(draftList) > 0 {
+		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("this procDef exist draft data")))
+		return
+	}
+	procDef.Version = calcProcDefVersion(c, procDef.Key)
+	newProcDefId, err := database.CopyProcessDefinition(c, procDef, user)
+	if err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if err = checkProcDefValidateErrors(c, newProcDefId); err == nil {
+		err = checkDeployedProcDef(c, newProcDefId)
+	}
+	if err != nil {
+		if deleteErr := database.DeleteProcDefChain(c, newProcDefId); deleteErr != nil {
+			err = fmt.Errorf("%s,and clean rollback draft fail:%s", err.Error(), deleteErr.Error())
+		}
+		middleware.ReturnError(c, err)
+		return
+	}
+	newProcDef := &models.ProcDef{Id: newProcDefId, Status: string(models.Deployed), Version: procDef.Version, UpdatedBy: user, UpdatedTime: time.Now()}
+	if err = database.UpdateProcDefStatusAndVersion(c, newProcDef); err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	if err = database.UpdateProcDefNodeStatusByProcDefId(c, newProcDefId, string(models.Deployed), user); err != nil {
+		middleware.ReturnError(c, err)
+		return
+	}
+	middleware.ReturnData(c, &models.ProcDefVersionDto{Id: newProcDefId, Key: procDef.Key, Name: procDef.Name, Version: procDef.Version,
+		Status: string(models.Deployed), UpdatedBy: user, UpdatedTime: newProcDef.UpdatedTime})
+}
+
+func getProcDefDiffData(ctx context.Context, procDefId string) (data *procDefDiffData, err error) {
+	data = &procDefDiffData{nodeParams: make(map[string][]*models.ProcDefNodeParam)}
+	if data.procDef, err = database.GetProcessDefinition(ctx, procDefId); err != nil {
+		return
+	}
+	if data.procDef == nil {
+		err = exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("procDefId:%s is invalid", procDefId))
+		return
+	}
+	if data.nodes, err = database.GetProcDefNodeModelByProcDefId(ctx, procDefId); err != nil {
+		return
+	}
+	for _, node := range data.nodes {
+		if data.nodeParams[node.Id], err = database.GetProcDefNodeParamByNodeId(ctx, node.Id); err != nil {
+			return
+		}
+	}
+	if data.links, err = database.GetProcDefNodeLinkListByProcDefId(ctx, procDefId); err != nil {
+		return
+	}
+	data.permissions, err = database.GetProcDefPermissionByCondition(ctx, models.ProcDefPermission{ProcDefId: procDefId})
+	return
+}
+
+func buildProcDefDiff(source, target *procDefDiffData) *models.ProcDefDiffDto {
+	result := &models.ProcDefDiffDto{
+		SourceProcDefId: source.procDef.Id,
+		SourceVersion:   source.procDef.Version,
+		TargetProcDefId: target.procDef.Id,
+		TargetVersion:   target.procDef.Version,
+		ProcDef:         diffFields(procDefDiffFields(source.procDef), procDefDiffFields(target.procDef)),
+		Nodes:           []*models.ProcDefItemDiffDto{},
+		Links:           []*models.ProcDefItemDiffDto{},
+		Permissions:     []*models.ProcDefItemDiffDto{},
+	}
+	// 节点和线按前端id对比,复制出来的新版本前端id保持不变
+	sourceNodeMap, targetNodeMap := make(map[string]*models.ProcDefNode), make(map[string]*models.ProcDefNode)
+	for _, node := range source.nodes {
+		sourceNodeMap[node.NodeId] = node
+	}
+	for _, node := range target.nodes {
+		targetNodeMap[node.NodeId] = node
+	}
+	for _, node := range source.nodes {
+		sourceFields := nodeDiffFields(node, source.nodeParams[node.Id])
+		if targetNode, ok := targetNodeMap[node.NodeId]; ok {
+			if fields := diffFields(sourceFields, nodeDiffFields(targetNode, target.nodeParams[targetNode.Id])); len(fields) > 0 {
+				result.Nodes = append(result.Nodes, &models.ProcDefItemDiffDto{Id: node.NodeId, Name: targetNode.Name, ChangeType: diffChangeTypeModify, Fields: fields})
+			}
+		} else {
+			result.Nodes = append(result.Nodes, &models.ProcDefItemDiffDto{Id: node.NodeId, Name: node.Name, ChangeType: diffChangeTypeDelete, Fields: diffFields(sourceFields, nil)})
+		}
+	}
+	for _, node := range target.nodes {
+		if _, ok := sourceNodeMap[node.NodeId]; !ok {
+			result.Nodes = append(result.Nodes, &models.ProcDefItemDiffDto{Id: node.NodeId, Name: node.Name, ChangeType: diffChangeTypeAdd, Fields: diffFields(nil, nodeDiffFields(node, target.nodeParams[node.Id]))})
+		}
+	}
+	sourceLinkMap, targetLinkMap := make(map[string]*models.ProcDefNodeLink), make(map[string]*models.ProcDefNodeLink)
+	for _, link := range source.links {
+		sourceLinkMap[link.LinkId] = link
+	}
+	for _, link := range target.links {
+		targetLinkMap[link.LinkId] = link
+	}
+	for _, link := range source.links {
+		sourceFields := linkDiffFields(link, source.nodes)
+		if targetLink, ok := targetLinkMap[link.LinkId]; ok {
+			if fields := diffFields(sourceFields, linkDiffFields(targetLink, target.nodes)); len(fields) > 0 {
+				result.Links = append(result.Links, &models.ProcDefItemDiffDto{Id: link.LinkId, Name: targetLink.Name, ChangeType: diffChangeTypeModify, Fields: fields})
+			}
+		} else {
+			result.Links = append(result.Links, &models.ProcDefItemDiffDto{Id: link.LinkId, Name: link.Name, ChangeType: diffChangeTypeDelete, Fields: diffFields(sourceFields, nil)})
+		}
+	}
+	for _, link := range target.links {
+		if _, ok := sourceLinkMap[link.LinkId]; !ok {
+			result.Links = append(result.Links, &models.ProcDefItemDiffDto{Id: link.LinkId, Name: link.Name, ChangeType: diffChangeTypeAdd, Fields: diffFields(nil, linkDiffFields(link, target.nodes))})
+		}
+	}
+	sourcePermissionMap, targetPermissionMap := make(map[string]bool), make(map[string]bool)
+	for _, permission := range source.permissions {
+		sourcePermissionMap[permission.RoleName+":"+permission.Permission] = true
+	}
+	for _, permission := range target.permissions {
+		targetPermissionMap[permission.RoleName+":"+permission.Permission] = true
+	}
+	for _, permission := range source.permissions {
+		if key := permission.RoleName + ":" + permission.Permission; !targetPermissionMap[key] {
+			result.Permissions = append(result.Permissions, &models.ProcDefItemDiffDto{Id: key, Name: permission.RoleName, ChangeType: diffChangeTypeDelete})
+		}
+	}
+	for _, permission := range target.permissions {
+		if key := permission.RoleName + ":" + permission.Permission; !sourcePermissionMap[key] {
+			result.Permissions = append(result.Permissions, &models.ProcDefItemDiffDto{Id: key, Name: permission.RoleName, ChangeType: diffChangeTypeAdd})
+		}
+	}
+	return result
+}
+
+// diffFields 对比两组有序属性,source或target为空时表示新增或删除
+func diffFields(source, target [][2]string) (fields []*models.ProcDefFieldDiff) {
+	sourceMap, targetMap := make(map[string]string), make(map[string]string)
+	for _, v := range source {
+		sourceMap[v[0]] = v[1]
+	}
+	for _, v := range target {
+		targetMap[v[0]] = v[1]
+	}
+	for _, v := range source {
+		if targetValue, ok := targetMap[v[0]]; !ok || targetValue != v[1] {
+			fields = append(fields, &models.ProcDefFieldDiff{Field: v[0], Source: v[1], Target: targetValue})
+		}
+	}
+	for _, v := range target {
+		if _, ok := sourceMap[v[0]]; !ok {
+			fields = append(fields, &models.ProcDefFieldDiff{Field: v[0], Target: v[1]})
+		}
+	}
+	return
+}
+
+func procDefDiffFields(procDef *models.ProcDef) [][2]string {
+	return [][2]string{
+		{"name", procDef.Name},
+		{"rootEntity", procDef.RootEntity},
+		{"tags", procDef.Tags},
+		{"forPlugin", procDef.ForPlugin},
+		{"scene", procDef.Scene},
+		{"conflictCheck", fmt.Sprintf("%t", procDef.ConflictCheck)},
+		{"slaMinutes", fmt.Sprintf("%d", procDef.SlaMinutes)},
+	}
+}
+
+func nodeDiffFields(node *models.ProcDefNode, params []*models.ProcDefNodeParam) [][2]string {
+	fields := [][2]string{
+		{"name", node.Name},
+		{"description", node.Description},
+		{"nodeType", node.NodeType},
+		{"serviceName", node.ServiceName},
+		{"dynamicBind", fmt.Sprintf("%t", node.DynamicBind)},
+		{"bindNodeId", node.BindNodeId},
+		{"riskCheck", fmt.Sprintf("%t", node.RiskCheck)},
+		{"routineExpression", node.RoutineExpression},
+		{"contextParamNodes", node.ContextParamNodes},
+		{"timeout", fmt.Sprintf("%d", node.Timeout)},
+		{"timeConfig", node.TimeConfig},
+		{"subProcDefId", node.SubProcDefId},
+		{"multiInstance", node.MultiInstance},
+		{"retryPolicy", node.RetryPolicy},
+		{"loopConfig", node.LoopConfig},
+		{"humanTaskConfig", node.HumanTaskConfig},
+		{"approvalConfig", node.ApprovalConfig},
+		{"expectedMinutes", fmt.Sprintf("%d", node.ExpectedMinutes)},
+	}
+	for _, param := range params {
+		paramValue := fmt.Sprintf("bindType=%s,value=%s,required=%s", param.BindType, param.Value, param.Required)
+		if param.BindType == "context" {
+			paramValue = fmt.Sprintf("bindType=%s,node=%s,paramType=%s,paramName=%s,required=%s", param.BindType, param.CtxBindNode, param.CtxBindType, param.CtxBindName, param.Required)
+		}
+		fields = append(fields, [2]string{"param." + param.Name, paramValue})
+	}
+	return fields
+}
+
+func linkDiffFields(link *models.ProcDefNodeLink, nodes []*models.ProcDefNode) [][2]string {
+	nodeIdMap := make(map[string]string)
+	for _, node := range nodes {
+		nodeIdMap[node.Id] = node.NodeId
+	}
+	return [][2]string{
+		{"name", link.Name},
+		{"source", nodeIdMap[link.Source]},
+		{"target", nodeIdMap[link.Target]},
+		{"linkType", link.LinkType},
+		{"condition", strings.TrimSpace(link.Condition)},
+		{"isDefault", fmt.Sprintf("%t", link.IsDefault)},
+	}
+}
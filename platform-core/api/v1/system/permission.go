@@ -50,7 +50,7 @@ func CreateUser(c *gin.Context) {
 		}
 		userDto.AuthContext = authContext
 	}
-	response, err = remote.RegisterLocalUser(userDto, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
+	response, err = remote.RegisterLocalUser(c, userDto, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -70,7 +70,7 @@ func CreateRole(c *gin.Context) {
 		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, fmt.Errorf("administrator not empty")))
 		return
 	}
-	response, err := remote.RegisterLocalRole(&param, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
+	response, err := remote.RegisterLocalRole(c, &param, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -81,7 +81,7 @@ func CreateRole(c *gin.Context) {
 // GetAllUser 获取全量用户
 func GetAllUser(c *gin.Context) {
 	var list []models.UserDto
-	response, err := remote.RetrieveAllUsers(c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
+	response, err := remote.RetrieveAllUsers(c, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -111,7 +111,7 @@ func QueryRoles(c *gin.Context) {
 		}
 	}
 
-	response, err := remote.RetrieveAllLocalRoles(requiredAll, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"), roleAdmin)
+	response, err := remote.RetrieveAllLocalRoles(c, requiredAll, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"), roleAdmin)
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -146,7 +146,7 @@ func GetMenusByUsername(c *gin.Context) {
 	var result []*models.RoleMenuDto
 	username := c.Param("username")
 	token := c.GetHeader("Authorization")
-	response, err := remote.GetRolesByUsername(username, token, c.GetHeader("Accept-Language"))
+	response, err := remote.GetRolesByUsername(c, username, token, c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -172,7 +172,7 @@ func GetMenusByUsername(c *gin.Context) {
 // GetRolesByUsername 根据用户名获取用户角色
 func GetRolesByUsername(c *gin.Context) {
 	username := c.Param("username")
-	response, err := remote.GetRolesByUsername(username, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
+	response, err := remote.GetRolesByUsername(c, username, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -195,7 +195,7 @@ func GetMenusByRoleId(c *gin.Context) {
 func GetUsersByRoleId(c *gin.Context) {
 	var result = make([]*models.UserDto, 0)
 	roleId := c.Param("role-id")
-	response, err := remote.GetUsersByRoleId(roleId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
+	response, err := remote.GetUsersByRoleId(c, roleId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -221,7 +221,7 @@ func GrantRoleToUsers(c *gin.Context) {
 		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
 		return
 	}
-	err := remote.ConfigureUserWithRoles(userId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"), roleIds)
+	err := remote.ConfigureUserWithRoles(c, userId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"), roleIds)
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -237,7 +237,7 @@ func GrantUserAddRoles(c *gin.Context) {
 		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
 		return
 	}
-	err := remote.ConfigureRoleForUsers(roleId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"), userIds)
+	err := remote.ConfigureRoleForUsers(c, roleId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"), userIds)
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -252,7 +252,7 @@ func ResetUserPassword(c *gin.Context) {
 		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
 		return
 	}
-	response, err := remote.ResetLocalUserPassword(param, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
+	response, err := remote.ResetLocalUserPassword(c, param, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -267,7 +267,7 @@ func ChangeUserPassword(c *gin.Context) {
 		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
 		return
 	}
-	response, err := remote.ModifyLocalUserPassword(param, middleware.GetRequestUser(c), c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
+	response, err := remote.ModifyLocalUserPassword(c, param, middleware.GetRequestUser(c), c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -279,7 +279,7 @@ func ChangeUserPassword(c *gin.Context) {
 func DeleteUserByUserId(c *gin.Context) {
 	userId := c.Param("user-id")
 	token := c.GetHeader("Authorization")
-	response, err := remote.RetrieveUserByUserId(userId, token, c.GetHeader("Accept-Language"))
+	response, err := remote.RetrieveUserByUserId(c, userId, token, c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -299,7 +299,7 @@ func DeleteUserByUserId(c *gin.Context) {
 		return
 	}
 	// 删除用户
-	err = remote.UnregisterLocalUser(userId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
+	err = remote.UnregisterLocalUser(c, userId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -322,7 +322,7 @@ func UpdateRole(c *gin.Context) {
 		return
 	}
 	// 查看当前角色是否包含 角色管理员,不包含需要将角色管理员加入到当前角色
-	queryUserResponse, err := remote.GetUsersByRoleId(roleId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
+	queryUserResponse, err := remote.GetUsersByRoleId(c, roleId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -336,13 +336,13 @@ func UpdateRole(c *gin.Context) {
 		}
 	}
 	param.ID = roleId
-	response, err := remote.UpdateLocalRole(c.GetHeader("Authorization"), c.GetHeader("Accept-Language"), param)
+	response, err := remote.UpdateLocalRole(c, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"), param)
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
 	}
 	if !exist {
-		err = remote.ConfigureRoleForUsers(roleId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"), []string{param.Administrator})
+		err = remote.ConfigureRoleForUsers(c, roleId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"), []string{param.Administrator})
 		if err != nil {
 			middleware.ReturnError(c, err)
 			return
@@ -364,7 +364,7 @@ func RevokeRoleFromUsers(c *gin.Context) {
 		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
 		return
 	}
-	err := remote.RevokeRoleFromUsers(roleId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"), userIds)
+	err := remote.RevokeRoleFromUsers(c, roleId, c.GetHeader("Authorization"), c.GetHeader("Accept-Language"), userIds)
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -390,7 +390,7 @@ func UpdateRoleToMenusByRoleId(c *gin.Context) {
 
 // GetRolesOfCurrentUser 获取当前用户的roles
 func GetRolesOfCurrentUser(c *gin.Context) {
-	response, err := remote.GetRolesByUsername(middleware.GetRequestUser(c), c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
+	response, err := remote.GetRolesByUsername(c, middleware.GetRequestUser(c), c.GetHeader("Authorization"), c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -405,7 +405,7 @@ func retrieveMenusByRoleId(ctx context.Context, roleId, userToken, language stri
 	var roleMenuEntities []*models.RoleMenu
 	var menuItemsEntity *models.MenuItems
 	var pluginPackageMenusEntities []*models.PluginPackageMenus
-	roleRes, err = remote.RetrieveRoleInfo(roleId, userToken, language)
+	roleRes, err = remote.RetrieveRoleInfo(ctx, roleId, userToken, language)
 	if err != nil {
 		return
 	}
@@ -478,7 +478,7 @@ func updateRoleToMenusByRoleId(ctx context.Context, roleId, userToken, language
 	var currentMenuCodeMap = make(map[string]bool)
 	var authoritiesToRevoke []*models.SimpleAuthorityDto
 	var needAddAuthoritiesToGrantList []*models.SimpleAuthorityDto
-	roleRes, err := remote.RetrieveRoleInfo(roleId, userToken, language)
+	roleRes, err := remote.RetrieveRoleInfo(ctx, roleId, userToken, language)
 	if err != nil {
 		return
 	}
@@ -499,7 +499,7 @@ func updateRoleToMenusByRoleId(ctx context.Context, roleId, userToken, language
 		currentMenuCodeMap[menu.MenuCode] = true
 	}
 	if len(authoritiesToRevoke) > 0 {
-		err = remote.RevokeRoleAuthoritiesById(roleId, userToken, language, authoritiesToRevoke)
+		err = remote.RevokeRoleAuthoritiesById(ctx, roleId, userToken, language, authoritiesToRevoke)
 		if err != nil {
 			return
 		}
@@ -520,7 +520,7 @@ func updateRoleToMenusByRoleId(ctx context.Context, roleId, userToken, language
 		}
 	}
 	if len(needAddAuthoritiesToGrantList) > 0 {
-		err = remote.ConfigureRoleWithAuthoritiesById(roleId, userToken, language, needAddAuthoritiesToGrantList)
+		err = remote.ConfigureRoleWithAuthoritiesById(ctx, roleId, userToken, language, needAddAuthoritiesToGrantList)
 	}
 	return
 }
@@ -545,7 +545,7 @@ func tryCalculateUmAuthContext(ctx context.Context) (authContext string, err err
 func GetUserByUsername(c *gin.Context) {
 	username := c.Param("username")
 	token := c.GetHeader("Authorization")
-	responseData, err := remote.RetrieveUserByUsername(username, token, c.GetHeader("Accept-Language"))
+	responseData, err := remote.RetrieveUserByUsername(c, username, token, c.GetHeader("Accept-Language"))
 	if err != nil {
 		middleware.ReturnError(c, err)
 		return
@@ -567,7 +567,7 @@ func UpdateUser(c *gin.Context) {
 		middleware.ReturnError(c, exterror.Catch(exterror.New().RequestParamValidateError, err))
 		return
 	}
-	err := remote.ModifyUserInfo(username, token, c.GetHeader("Accept-Language"), &param)
+	err := remote.ModifyUserInfo(c, username, token, c.GetHeader("Accept-Language"), &param)
 	if err != nil {
 		middleware.ReturnError(c, err)
 	} else {
@@ -53,7 +53,7 @@ func GetResourceServerStatus(c *gin.Context) {
 }
 
 func GetResourceServerTypes(c *gin.Context) {
-	data := []string{"s3", "mysql", "docker"}
+	data := []string{"s3", "mysql", "docker", "kubernetes"}
 	middleware.ReturnData(c, data)
 }
 
@@ -29,6 +29,23 @@ sed -i "s~{{gateway_host_ports}}~$gateway_host_ports~g" /app/platform-core/confi
 sed -i "s~{{sub_system_private_key}}~$sub_system_private_key~g" /app/platform-core/config/default.json
 sed -i "s~{{cron_keep_batch_exec_days}}~$cron_keep_batch_exec_days~g" /app/platform-core/config/default.json
 sed -i "s~{{host_ip}}~$host_ip~g" /app/platform-core/config/default.json
+sed -i "s~{{operation_notify_type}}~$operation_notify_type~g" /app/platform-core/config/default.json
+sed -i "s~{{operation_notify_port}}~$operation_notify_port~g" /app/platform-core/config/default.json
+sed -i "s~{{metric_port}}~$metric_port~g" /app/platform-core/config/default.json
+sed -i "s~{{trace_enable}}~${trace_enable:-false}~g" /app/platform-core/config/default.json
+sed -i "s~{{trace_exporter}}~$trace_exporter~g" /app/platform-core/config/default.json
+sed -i "s~{{trace_otlp_endpoint}}~$trace_otlp_endpoint~g" /app/platform-core/config/default.json
+sed -i "s~{{plugin_container_driver}}~${plugin_container_driver:-ssh}~g" /app/platform-core/config/default.json
+sed -i "s~{{plugin_docker_api_port}}~${plugin_docker_api_port:-2376}~g" /app/platform-core/config/default.json
+sed -i "s~{{plugin_docker_api_tls_enable}}~${plugin_docker_api_tls_enable:-false}~g" /app/platform-core/config/default.json
+sed -i "s~{{plugin_k8s_namespace}}~${plugin_k8s_namespace:-wecube-plugins}~g" /app/platform-core/config/default.json
+sed -i "s~{{plugin_k8s_service_type}}~${plugin_k8s_service_type:-NodePort}~g" /app/platform-core/config/default.json
+sed -i "s~{{plugin_k8s_node_host}}~$plugin_k8s_node_host~g" /app/platform-core/config/default.json
+sed -i "s~{{plugin_k8s_image_registry}}~$plugin_k8s_image_registry~g" /app/platform-core/config/default.json
+sed -i "s~{{plugin_k8s_image_pull_secret}}~$plugin_k8s_image_pull_secret~g" /app/platform-core/config/default.json
+sed -i "s~{{plugin_health_check_enable}}~${plugin_health_check_enable:-true}~g" /app/platform-core/config/default.json
+sed -i "s~{{plugin_health_auto_restart}}~${plugin_health_auto_restart:-false}~g" /app/platform-core/config/default.json
+sed -i "s~{{plugin_health_alarm_role}}~$plugin_health_alarm_role~g" /app/platform-core/config/default.json
 
 exec ./platform-core
 
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/trace"
 	"github.com/WeBankPartners/wecube-platform/platform-core/models"
 	_ "github.com/go-sql-driver/mysql"
 	"go.uber.org/zap"
@@ -132,6 +133,9 @@ func Transaction(actions []*ExecAction, ctx context.Context) error {
 			return fmt.Errorf("SQL exec transaction index%d is nill error,please check server log", i)
 		}
 	}
+	ctx, span := trace.StartSpan(ctx, "db.transaction", trace.SpanKindClient)
+	span.SetAttribute("db.system", "mysql")
+	span.SetAttribute("db.action_count", strconv.Itoa(len(actions)))
 	session := MysqlEngine.NewSession().Context(ctx)
 	err := session.Begin()
 	for _, action := range actions {
@@ -155,6 +159,7 @@ func Transaction(actions []*ExecAction, ctx context.Context) error {
 		err = session.Commit()
 	}
 	session.Close()
+	span.End(err)
 	return err
 }
 
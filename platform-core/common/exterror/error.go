@@ -45,32 +45,38 @@ type ErrorTemplate struct {
 	DatabaseQueryEmptyError CustomError `json:"database_query_empty_error"`
 	DatabaseExecuteError    CustomError `json:"database_execute_error"`
 	// sever handle error
-	ServerHandleError                 CustomError `json:"server_handle_error"`
-	PluginDependencyIllegal           CustomError `json:"plugin_dependency_illegal"`
-	ProcDefNodeNameEmptyError         CustomError `json:"proc_def_node_name_empty_error"`
-	ProcDefNodeNameRepeatError        CustomError `json:"proc_def_node_name_repeat_error"`
-	ProcDefNodeServiceNameEmptyError  CustomError `json:"proc_def_node_service_name_empty_error"`
-	ProcDefNodeDeleteError            CustomError `json:"proc_def__node_delete_error"`
-	ProcDefNodeDateEmptyError         CustomError `json:"proc_def_node_date_empty_error"`
-	ProcDefNode20000004Error          CustomError `json:"proc_def_node_20000004_error"`
-	ProcDefNode20000005Error          CustomError `json:"proc_def_node_20000005_error"`
-	ProcDefNode20000006Error          CustomError `json:"proc_def_node_20000006_error"`
-	ProcDefNode20000007Error          CustomError `json:"proc_def_node_20000007_error"`
-	ProcDefNode20000008Error          CustomError `json:"proc_def_node_20000008_error"`
-	ProcDefNode20000009Error          CustomError `json:"proc_def_node_20000009_error"`
-	ProcDefNode20000010Error          CustomError `json:"proc_def_node_20000010_error"`
-	ProcDefNode20000015Error          CustomError `json:"proc_def_import_low_version_error"`
-	ProcDefNode20000016Error          CustomError `json:"proc_def_import_draft_conflict_error"`
-	ProcDefNode20000017Error          CustomError `json:"proc_def_import_server_error"`
-	ProcDefLoopCheckError             CustomError `json:"proc_def_loop_check_error"`
-	ProcDefNameRepeatError            CustomError `json:"proc_def_name_repeat_error"`
-	ProcDefRootEntityEmptyError       CustomError `json:"proc_def_root_entity_empty_error"`
-	DeleteUserError                   CustomError `json:"delete_user_error"`
-	BatchExecPluginAuthError          CustomError `json:"batch_exec_plugin_auth_error"`
-	BatchExecPluginApiError           CustomError `json:"batch_exec_plugin_api_error"`
-	BatchExecTmplDuplicateNameError   CustomError `json:"batch_exec_tmpl_duplicate_name_error"`
-	BatchExecTmplHasBeenModifiedError CustomError `json:"batch_exec_tmpl_has_been_modified_error"`
-	BatchExecDuplicateNameError       CustomError `json:"batch_exec_duplicate_name_error"`
+	ServerHandleError                    CustomError `json:"server_handle_error"`
+	PluginDependencyIllegal              CustomError `json:"plugin_dependency_illegal"`
+	ProcDefNodeNameEmptyError            CustomError `json:"proc_def_node_name_empty_error"`
+	ProcDefNodeNameRepeatError           CustomError `json:"proc_def_node_name_repeat_error"`
+	ProcDefNodeServiceNameEmptyError     CustomError `json:"proc_def_node_service_name_empty_error"`
+	ProcDefNodeDeleteError               CustomError `json:"proc_def__node_delete_error"`
+	ProcDefNodeDateEmptyError            CustomError `json:"proc_def_node_date_empty_error"`
+	ProcDefNodeSubProcIllegalError       CustomError `json:"proc_def_node_sub_proc_illegal_error"`
+	ProcDefNodeBoundaryLinkIllegalError  CustomError `json:"proc_def_node_boundary_link_illegal_error"`
+	ProcDefDecisionConditionIllegalError CustomError `json:"proc_def_decision_condition_illegal_error"`
+	ProcDefValidateError                 CustomError `json:"proc_def_validate_error"`
+	ProcDefLoopNodeIllegalError          CustomError `json:"proc_def_loop_node_illegal_error"`
+	ProcDefApprovalNodeIllegalError      CustomError `json:"proc_def_approval_node_illegal_error"`
+	ProcDefNode20000004Error             CustomError `json:"proc_def_node_20000004_error"`
+	ProcDefNode20000005Error             CustomError `json:"proc_def_node_20000005_error"`
+	ProcDefNode20000006Error             CustomError `json:"proc_def_node_20000006_error"`
+	ProcDefNode20000007Error             CustomError `json:"proc_def_node_20000007_error"`
+	ProcDefNode20000008Error             CustomError `json:"proc_def_node_20000008_error"`
+	ProcDefNode20000009Error             CustomError `json:"proc_def_node_20000009_error"`
+	ProcDefNode20000010Error             CustomError `json:"proc_def_node_20000010_error"`
+	ProcDefNode20000015Error             CustomError `json:"proc_def_import_low_version_error"`
+	ProcDefNode20000016Error             CustomError `json:"proc_def_import_draft_conflict_error"`
+	ProcDefNode20000017Error             CustomError `json:"proc_def_import_server_error"`
+	ProcDefLoopCheckError                CustomError `json:"proc_def_loop_check_error"`
+	ProcDefNameRepeatError               CustomError `json:"proc_def_name_repeat_error"`
+	ProcDefRootEntityEmptyError          CustomError `json:"proc_def_root_entity_empty_error"`
+	DeleteUserError                      CustomError `json:"delete_user_error"`
+	BatchExecPluginAuthError             CustomError `json:"batch_exec_plugin_auth_error"`
+	BatchExecPluginApiError              CustomError `json:"batch_exec_plugin_api_error"`
+	BatchExecTmplDuplicateNameError      CustomError `json:"batch_exec_tmpl_duplicate_name_error"`
+	BatchExecTmplHasBeenModifiedError    CustomError `json:"batch_exec_tmpl_has_been_modified_error"`
+	BatchExecDuplicateNameError          CustomError `json:"batch_exec_duplicate_name_error"`
 	// 同时处理报错
 	DealWithAtTheSameTimeError CustomError `json:"deal_with_at_the_same_time_error"`
 	DataPermissionDeny         CustomError `json:"data_permission_deny"`
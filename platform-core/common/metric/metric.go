@@ -0,0 +1,208 @@
+package metric
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 没有引入 prometheus client,按 prometheus text format 0.0.4 自行输出
+
+var (
+	registryLock sync.RWMutex
+	collectors   []collector
+)
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// GaugeSample 抓取时实时计算的指标值
+type GaugeSample struct {
+	LabelValues []string
+	Value       float64
+}
+
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+	lock       sync.Mutex
+	values     map[string]*counterValue
+}
+
+type counterValue struct {
+	labelValues []string
+	value       float64
+}
+
+type HistogramVec struct {
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+	lock       sync.Mutex
+	values     map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+type gaugeFunc struct {
+	name       string
+	help       string
+	labelNames []string
+	collect    func() []*GaugeSample
+}
+
+func register(c collector) {
+	registryLock.Lock()
+	collectors = append(collectors, c)
+	registryLock.Unlock()
+}
+
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labelNames: labelNames, values: make(map[string]*counterValue)}
+	register(c)
+	return c
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, labelNames: labelNames, buckets: buckets, values: make(map[string]*histogramValue)}
+	register(h)
+	return h
+}
+
+// RegisterGaugeFunc 注册抓取时才计算的指标,如内存中的工作流数和数据库中的队列积压
+func RegisterGaugeFunc(name, help string, labelNames []string, collect func() []*GaugeSample) {
+	register(&gaugeFunc{name: name, help: help, labelNames: labelNames, collect: collect})
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	v, ok := c.values[key]
+	if !ok {
+		v = &counterValue{labelValues: labelValues}
+		c.values[key] = v
+	}
+	v.value += value
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	for i, bucket := range h.buckets {
+		if value <= bucket {
+			v.counts[i]++
+		}
+	}
+	v.sum += value
+	v.count++
+}
+
+// WriteMetrics 输出所有已注册指标
+func WriteMetrics(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	registryLock.RLock()
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	registryLock.RUnlock()
+	return bw.Flush()
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		v := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labelNames, v.labelValues, "", ""), formatFloat(v.value))
+	}
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, key := range sortedKeys(h.values) {
+		v := h.values[key]
+		for i, bucket := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labelNames, v.labelValues, "le", formatFloat(bucket)), v.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labelNames, v.labelValues, "le", "+Inf"), v.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labelNames, v.labelValues, "", ""), formatFloat(v.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labelNames, v.labelValues, "", ""), v.count)
+	}
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	for _, sample := range g.collect() {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labelNames, sample.LabelValues, "", ""), formatFloat(sample.Value))
+	}
+}
+
+func writeHeader(w *bufio.Writer, name, help, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+func formatLabels(labelNames, labelValues []string, extraName, extraValue string) string {
+	var pairs []string
+	for i, labelName := range labelNames {
+		labelValue := ""
+		if i < len(labelValues) {
+			labelValue = labelValues[i]
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labelName, escapeLabelValue(labelValue)))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(input string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(input)
+}
+
+func formatFloat(input float64) string {
+	if math.IsInf(input, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(input, 'g', -1, 64)
+}
+
+func sortedKeys[T any](input map[string]T) []string {
+	keys := make([]string, 0, len(input))
+	for key := range input {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
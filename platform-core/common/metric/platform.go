@@ -0,0 +1,19 @@
+package metric
+
+var (
+	defaultHttpBuckets     = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	defaultWorkflowBuckets = []float64{1, 5, 10, 30, 60, 300, 600, 1800, 3600, 7200}
+
+	// WorkflowNodeDuration 编排节点执行耗时
+	WorkflowNodeDuration = NewHistogramVec("platform_core_workflow_node_duration_seconds", "Workflow node execution duration in seconds by job type and status.", defaultWorkflowBuckets, "job_type", "status")
+	// PluginCallDuration 插件接口调用耗时
+	PluginCallDuration = NewHistogramVec("platform_core_plugin_call_duration_seconds", "Plugin interface call latency in seconds by service.", defaultHttpBuckets, "service")
+	// PluginCallErrors 插件接口调用失败次数
+	PluginCallErrors = NewCounterVec("platform_core_plugin_call_errors_total", "Plugin interface call error count by service and result code.", "service", "error_code")
+	// PluginHealthCheckFailures 插件实例健康检查失败次数
+	PluginHealthCheckFailures = NewCounterVec("platform_core_plugin_health_check_failures_total", "Plugin instance health check failure count by plugin.", "plugin")
+	// PluginInstanceRestarts 插件实例不健康时自动重启次数
+	PluginInstanceRestarts = NewCounterVec("platform_core_plugin_instance_restarts_total", "Plugin instance automatic restart count by plugin and result.", "plugin", "result")
+	// HttpRequestDuration 接口请求耗时,按api.go里注册的ApiCode统计
+	HttpRequestDuration = NewHistogramVec("platform_core_http_request_duration_seconds", "Http api request latency in seconds by api code.", defaultHttpBuckets, "api_code", "method", "status")
+)
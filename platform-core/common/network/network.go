@@ -2,16 +2,19 @@ package network
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/trace"
 	"github.com/WeBankPartners/wecube-platform/platform-core/models"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
 // HttpGet  Get请求
-func HttpGet(url, userToken, language string) (byteArr []byte, err error) {
+func HttpGet(ctx context.Context, url, userToken, language string) (byteArr []byte, err error) {
 	req, newReqErr := http.NewRequest(http.MethodGet, url, strings.NewReader(""))
 	if newReqErr != nil {
 		err = fmt.Errorf("try to new http request fail,%s ", newReqErr.Error())
@@ -19,7 +22,7 @@ func HttpGet(url, userToken, language string) (byteArr []byte, err error) {
 	}
 	req.Header.Set("Authorization", userToken)
 	req.Header.Set("Accept-Language", language)
-	resp, respErr := http.DefaultClient.Do(req)
+	resp, respErr := doRequest(ctx, req)
 	if respErr != nil {
 		err = fmt.Errorf("try to do http request fail,%s ", respErr.Error())
 		return
@@ -30,7 +33,7 @@ func HttpGet(url, userToken, language string) (byteArr []byte, err error) {
 }
 
 // HttpPost Post请求,关注返回结果
-func HttpPost(url, userToken, language string, postBytes []byte) (byteArr []byte, err error) {
+func HttpPost(ctx context.Context, url, userToken, language string, postBytes []byte) (byteArr []byte, err error) {
 	req, reqErr := http.NewRequest(http.MethodPost, url, bytes.NewReader(postBytes))
 	if reqErr != nil {
 		err = fmt.Errorf("new http reqeust fail,%s ", reqErr.Error())
@@ -38,7 +41,7 @@ func HttpPost(url, userToken, language string, postBytes []byte) (byteArr []byte
 	}
 	req.Header.Set("Authorization", userToken)
 	req.Header.Set("Accept-Language", language)
-	resp, respErr := http.DefaultClient.Do(req)
+	resp, respErr := doRequest(ctx, req)
 	if respErr != nil {
 		err = fmt.Errorf("do http reqeust fail,%s ", respErr.Error())
 		return
@@ -49,7 +52,7 @@ func HttpPost(url, userToken, language string, postBytes []byte) (byteArr []byte
 }
 
 // HttpPostCommon Post请求,通用返回处理
-func HttpPostCommon(url, userToken, language string, postBytes []byte) (err error) {
+func HttpPostCommon(ctx context.Context, url, userToken, language string, postBytes []byte) (err error) {
 	req, reqErr := http.NewRequest(http.MethodPost, url, bytes.NewReader(postBytes))
 	if reqErr != nil {
 		err = fmt.Errorf("new http reqeust fail,%s ", reqErr.Error())
@@ -57,7 +60,7 @@ func HttpPostCommon(url, userToken, language string, postBytes []byte) (err erro
 	}
 	req.Header.Set("Authorization", userToken)
 	req.Header.Set("Accept-Language", language)
-	resp, respErr := http.DefaultClient.Do(req)
+	resp, respErr := doRequest(ctx, req)
 	if respErr != nil {
 		err = fmt.Errorf("do http reqeust fail,%s ", respErr.Error())
 		return
@@ -75,7 +78,7 @@ func HttpPostCommon(url, userToken, language string, postBytes []byte) (err erro
 }
 
 // HttpDeleteCommon http Delete
-func HttpDeleteCommon(url, userToken, language string) (err error) {
+func HttpDeleteCommon(ctx context.Context, url, userToken, language string) (err error) {
 	req, newReqErr := http.NewRequest(http.MethodDelete, url, strings.NewReader(""))
 	if newReqErr != nil {
 		err = fmt.Errorf("try to new http request fail,%s ", newReqErr.Error())
@@ -83,7 +86,7 @@ func HttpDeleteCommon(url, userToken, language string) (err error) {
 	}
 	req.Header.Set("Authorization", userToken)
 	req.Header.Set("Accept-Language", language)
-	resp, respErr := http.DefaultClient.Do(req)
+	resp, respErr := doRequest(ctx, req)
 	if respErr != nil {
 		err = fmt.Errorf("try to do http request fail,%s ", respErr.Error())
 		return
@@ -99,3 +102,17 @@ func HttpDeleteCommon(url, userToken, language string) (err error) {
 	}
 	return
 }
+
+// doRequest 发出请求并记录出站span,请求头带上traceparent
+func doRequest(ctx context.Context, req *http.Request) (resp *http.Response, err error) {
+	ctx, span := trace.StartSpan(ctx, fmt.Sprintf("HTTP %s", req.Method), trace.SpanKindClient)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.String())
+	trace.Inject(ctx, req.Header)
+	resp, err = http.DefaultClient.Do(req)
+	if err == nil {
+		span.SetAttribute("http.status_code", strconv.Itoa(resp.StatusCode))
+	}
+	span.End(err)
+	return
+}
@@ -0,0 +1,362 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 判断分支条件表达式,支持 && || ! () 以及 == != > >= < <= 比较
+// 变量使用点号访问,如 output.errorCode == '0' && entity.state == 'running'
+
+const (
+	condTokenIdent  = "ident"
+	condTokenString = "string"
+	condTokenNumber = "number"
+	condTokenOp     = "op"
+)
+
+type condToken struct {
+	kind  string
+	value string
+}
+
+type condNode struct {
+	op    string // && || ! 比较符,为空时是值节点
+	left  *condNode
+	right *condNode
+	kind  string // 值节点类型
+	value string
+}
+
+type condParser struct {
+	tokens []*condToken
+	pos    int
+}
+
+// ValidateConditionExpr 校验条件表达式语法
+func ValidateConditionExpr(expr string) error {
+	_, err := parseConditionExpr(expr)
+	return err
+}
+
+// EvalConditionExpr 用变量计算条件表达式结果
+func EvalConditionExpr(expr string, vars map[string]interface{}) (result bool, err error) {
+	node, parseErr := parseConditionExpr(expr)
+	if parseErr != nil {
+		err = parseErr
+		return
+	}
+	result = condTruthy(node.eval(vars))
+	return
+}
+
+func parseConditionExpr(expr string) (node *condNode, err error) {
+	if strings.TrimSpace(expr) == "" {
+		err = fmt.Errorf("condition expression is empty")
+		return
+	}
+	tokens, tokenErr := tokenizeConditionExpr(expr)
+	if tokenErr != nil {
+		err = tokenErr
+		return
+	}
+	p := &condParser{tokens: tokens}
+	if node, err = p.parseOr(); err != nil {
+		return
+	}
+	if p.pos < len(p.tokens) {
+		err = fmt.Errorf("condition expression unexpected token %s", p.tokens[p.pos].value)
+	}
+	return
+}
+
+func tokenizeConditionExpr(expr string) (tokens []*condToken, err error) {
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != c {
+				j++
+			}
+			if j >= len(runes) {
+				err = fmt.Errorf("condition expression string not closed")
+				return
+			}
+			tokens = append(tokens, &condToken{kind: condTokenString, value: string(runes[i+1 : j])})
+			i = j + 1
+		case c == '(' || c == ')':
+			tokens = append(tokens, &condToken{kind: condTokenOp, value: string(c)})
+			i++
+		case c == '&' || c == '|':
+			if i+1 >= len(runes) || runes[i+1] != c {
+				err = fmt.Errorf("condition expression illegal operator %c", c)
+				return
+			}
+			tokens = append(tokens, &condToken{kind: condTokenOp, value: string(runes[i : i+2])})
+			i += 2
+		case c == '=' || c == '!' || c == '>' || c == '<':
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, &condToken{kind: condTokenOp, value: string(runes[i : i+2])})
+				i += 2
+			} else if c == '=' {
+				err = fmt.Errorf("condition expression illegal operator =,use ==")
+				return
+			} else {
+				tokens = append(tokens, &condToken{kind: condTokenOp, value: string(c)})
+				i++
+			}
+		case (c >= '0' && c <= '9') || c == '-':
+			j := i + 1
+			for j < len(runes) && ((runes[j] >= '0' && runes[j] <= '9') || runes[j] == '.') {
+				j++
+			}
+			if _, parseErr := strconv.ParseFloat(string(runes[i:j]), 64); parseErr != nil {
+				err = fmt.Errorf("condition expression illegal number %s", string(runes[i:j]))
+				return
+			}
+			tokens = append(tokens, &condToken{kind: condTokenNumber, value: string(runes[i:j])})
+			i = j
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			j := i + 1
+			for j < len(runes) && (runes[j] == '_' || runes[j] == '.' || (runes[j] >= 'a' && runes[j] <= 'z') || (runes[j] >= 'A' && runes[j] <= 'Z') || (runes[j] >= '0' && runes[j] <= '9')) {
+				j++
+			}
+			tokens = append(tokens, &condToken{kind: condTokenIdent, value: string(runes[i:j])})
+			i = j
+		default:
+			err = fmt.Errorf("condition expression illegal character %c", c)
+			return
+		}
+	}
+	return
+}
+
+func (p *condParser) peek() *condToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *condParser) peekOp(ops ...string) string {
+	if t := p.peek(); t != nil && t.kind == condTokenOp {
+		for _, op := range ops {
+			if t.value == op {
+				return op
+			}
+		}
+	}
+	return ""
+}
+
+func (p *condParser) parseOr() (node *condNode, err error) {
+	if node, err = p.parseAnd(); err != nil {
+		return
+	}
+	for p.peekOp("||") != "" {
+		p.pos++
+		right, rightErr := p.parseAnd()
+		if rightErr != nil {
+			err = rightErr
+			return
+		}
+		node = &condNode{op: "||", left: node, right: right}
+	}
+	return
+}
+
+func (p *condParser) parseAnd() (node *condNode, err error) {
+	if node, err = p.parseNot(); err != nil {
+		return
+	}
+	for p.peekOp("&&") != "" {
+		p.pos++
+		right, rightErr := p.parseNot()
+		if rightErr != nil {
+			err = rightErr
+			return
+		}
+		node = &condNode{op: "&&", left: node, right: right}
+	}
+	return
+}
+
+func (p *condParser) parseNot() (node *condNode, err error) {
+	if p.peekOp("!") != "" {
+		p.pos++
+		child, childErr := p.parseNot()
+		if childErr != nil {
+			err = childErr
+			return
+		}
+		node = &condNode{op: "!", left: child}
+		return
+	}
+	return p.parseCompare()
+}
+
+func (p *condParser) parseCompare() (node *condNode, err error) {
+	if node, err = p.parsePrimary(); err != nil {
+		return
+	}
+	if op := p.peekOp("==", "!=", ">", ">=", "<", "<="); op != "" {
+		p.pos++
+		right, rightErr := p.parsePrimary()
+		if rightErr != nil {
+			err = rightErr
+			return
+		}
+		node = &condNode{op: op, left: node, right: right}
+	}
+	return
+}
+
+func (p *condParser) parsePrimary() (node *condNode, err error) {
+	t := p.peek()
+	if t == nil {
+		err = fmt.Errorf("condition expression unexpected end")
+		return
+	}
+	p.pos++
+	if t.kind == condTokenOp {
+		if t.value != "(" {
+			err = fmt.Errorf("condition expression unexpected operator %s", t.value)
+			return
+		}
+		if node, err = p.parseOr(); err != nil {
+			return
+		}
+		if p.peekOp(")") == "" {
+			err = fmt.Errorf("condition expression missing )")
+			return
+		}
+		p.pos++
+		return
+	}
+	node = &condNode{kind: t.kind, value: t.value}
+	return
+}
+
+func (n *condNode) eval(vars map[string]interface{}) interface{} {
+	switch n.op {
+	case "":
+		return n.valueOf(vars)
+	case "&&":
+		return condTruthy(n.left.eval(vars)) && condTruthy(n.right.eval(vars))
+	case "||":
+		return condTruthy(n.left.eval(vars)) || condTruthy(n.right.eval(vars))
+	case "!":
+		return !condTruthy(n.left.eval(vars))
+	}
+	return condCompare(n.op, n.left.eval(vars), n.right.eval(vars))
+}
+
+func (n *condNode) valueOf(vars map[string]interface{}) interface{} {
+	switch n.kind {
+	case condTokenString:
+		return n.value
+	case condTokenNumber:
+		num, _ := strconv.ParseFloat(n.value, 64)
+		return num
+	}
+	switch n.value {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null", "nil":
+		return nil
+	}
+	var cur interface{} = vars
+	for _, key := range strings.Split(n.value, ".") {
+		curMap, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = curMap[key]
+	}
+	return cur
+}
+
+func condTruthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case float64:
+		return val != 0
+	case string:
+		return val != "" && val != "false" && val != "0"
+	}
+	return true
+}
+
+func condCompare(op string, left, right interface{}) bool {
+	leftNum, leftIsNum := condNumber(left)
+	rightNum, rightIsNum := condNumber(right)
+	_, leftIsStr := left.(string)
+	_, rightIsStr := right.(string)
+	// 两边都是字符串时按字符串比较,否则能转数字的按数字比较
+	if leftIsNum && rightIsNum && !(leftIsStr && rightIsStr) {
+		switch op {
+		case "==":
+			return leftNum == rightNum
+		case "!=":
+			return leftNum != rightNum
+		case ">":
+			return leftNum > rightNum
+		case ">=":
+			return leftNum >= rightNum
+		case "<":
+			return leftNum < rightNum
+		case "<=":
+			return leftNum <= rightNum
+		}
+		return false
+	}
+	leftStr, rightStr := condString(left), condString(right)
+	switch op {
+	case "==":
+		return leftStr == rightStr
+	case "!=":
+		return leftStr != rightStr
+	case ">":
+		return leftStr > rightStr
+	case ">=":
+		return leftStr >= rightStr
+	case "<":
+		return leftStr < rightStr
+	case "<=":
+		return leftStr <= rightStr
+	}
+	return false
+}
+
+func condNumber(v interface{}) (num float64, ok bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case string:
+		if parseNum, err := strconv.ParseFloat(val, 64); err == nil {
+			return parseNum, true
+		}
+	}
+	return
+}
+
+func condString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,86 @@
+package tools
+
+import "testing"
+
+func TestEvalConditionExpr(t *testing.T) {
+	vars := map[string]interface{}{
+		"output": map[string]interface{}{
+			"errorCode": "0",
+			"count":     float64(3),
+			"name":      "abc",
+			"enabled":   true,
+		},
+		"entity": map[string]interface{}{
+			"state": "running",
+		},
+	}
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		{"string equal", "output.errorCode == '0'", true},
+		{"string not equal", "output.errorCode != \"0\"", false},
+		{"number greater", "output.count > 2", true},
+		{"number greater equal", "output.count >= 3", true},
+		{"number less", "output.count < 3", false},
+		{"number less equal", "output.count <= 3", true},
+		{"negative number", "output.count > -1", true},
+		{"string number compare as number", "output.errorCode < 1", true},
+		{"both string compare as string", "output.name > 'abb'", true},
+		{"bool variable", "output.enabled", true},
+		{"bool literal", "output.enabled == true", true},
+		{"missing variable is null", "output.missing == null", true},
+		{"missing nested variable", "entity.state.sub", false},
+		{"not", "!output.enabled", false},
+		{"double not", "!!output.enabled", true},
+		{"and", "output.errorCode == '0' && entity.state == 'running'", true},
+		{"or", "output.errorCode == '1' || entity.state == 'running'", true},
+		{"and before or", "output.errorCode == '1' && false || true", true},
+		{"or then and", "true || false && false", true},
+		{"parentheses override precedence", "(true || false) && false", false},
+		{"not binds tighter than and", "!false && false", false},
+		{"not with parentheses", "!(false && true)", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EvalConditionExpr(tt.expr, vars)
+			if err != nil {
+				t.Fatalf("EvalConditionExpr(%q) error: %v", tt.expr, err)
+			}
+			if got != tt.want {
+				t.Errorf("EvalConditionExpr(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateConditionExpr(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{"valid", "a.b == 'x' && (c > 1 || !d)", false},
+		{"empty", "  ", true},
+		{"single equal", "a = 1", true},
+		{"single and", "a & b", true},
+		{"single or", "a | b", true},
+		{"string not closed", "a == 'x", true},
+		{"missing right paren", "(a == 1", true},
+		{"extra right paren", "a == 1)", true},
+		{"missing right operand", "a ==", true},
+		{"operator at start", "&& a", true},
+		{"illegal number", "a == 1.2.3", true},
+		{"illegal character", "a == #", true},
+		{"two operands without operator", "a b", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConditionExpr(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConditionExpr(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"strings"
+	"unicode"
+)
+
+const maxSearchTokenLength = 100
+
+// SplitSearchTokens 全文检索分词,ip、主机名等带分隔符的词保留整体,含字母的再拆出各段,中文按相邻两个字切分,结果去重且最多limit个
+func SplitSearchTokens(text string, limit int) (tokens []string) {
+	existMap := make(map[string]bool)
+	addToken := func(token string) bool {
+		if token == "" || existMap[token] || len([]rune(token)) > maxSearchTokenLength {
+			return true
+		}
+		if limit > 0 && len(tokens) >= limit {
+			return false
+		}
+		existMap[token] = true
+		tokens = append(tokens, token)
+		return true
+	}
+	var word, han []rune
+	flush := func() bool {
+		if len(word) > 0 {
+			whole := strings.Trim(string(word), ".-_:@")
+			word = word[:0]
+			if !addToken(whole) {
+				return false
+			}
+			if strings.IndexFunc(whole, unicode.IsLetter) >= 0 {
+				for _, part := range strings.FieldsFunc(whole, isSearchTokenSeparator) {
+					if len(part) >= 2 && part != whole && !addToken(part) {
+						return false
+					}
+				}
+			}
+		}
+		if len(han) > 0 {
+			if len(han) == 1 && !addToken(string(han)) {
+				return false
+			}
+			for i := 0; i+1 < len(han); i++ {
+				if !addToken(string(han[i : i+2])) {
+					return false
+				}
+			}
+			han = han[:0]
+		}
+		return true
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			if len(word) > 0 && !flush() {
+				return
+			}
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || isSearchTokenSeparator(r):
+			if len(han) > 0 && !flush() {
+				return
+			}
+			word = append(word, r)
+		default:
+			if !flush() {
+				return
+			}
+		}
+	}
+	flush()
+	return
+}
+
+func isSearchTokenSeparator(r rune) bool {
+	return r == '.' || r == '-' || r == '_' || r == ':' || r == '@'
+}
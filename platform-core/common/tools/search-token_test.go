@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitSearchTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"empty", "", 0, nil},
+		{"lower case words", "Host ERROR down", 0, []string{"host", "error", "down"}},
+		{"ip keep whole", "ping 10.0.0.1 fail", 0, []string{"ping", "10.0.0.1", "fail"}},
+		{"host name split parts", "web-server01.prod", 0, []string{"web-server01.prod", "web", "server01", "prod"}},
+		{"email split parts", "user@example.com", 0, []string{"user@example.com", "user", "example", "com"}},
+		{"short parts skipped", "a-b", 0, []string{"a-b"}},
+		{"trim separators", "...abc--", 0, []string{"abc"}},
+		{"han bigram", "部署失败", 0, []string{"部署", "署失", "失败"}},
+		{"single han", "错", 0, []string{"错"}},
+		{"han and word mixed", "ip地址10.0.0.1", 0, []string{"ip", "地址", "10.0.0.1"}},
+		{"duplicate removed", "abc abc ABC", 0, []string{"abc"}},
+		{"punctuation split", "code=500,msg:timeout", 0, []string{"code", "500", "msg:timeout", "msg", "timeout"}},
+		{"limit", "a1 b2 c3 d4", 2, []string{"a1", "b2"}},
+		{"too long token skipped", strings.Repeat("a", maxSearchTokenLength+1) + " ok", 0, []string{"ok"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitSearchTokens(tt.text, tt.limit)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitSearchTokens(%q, %d) = %v, want %v", tt.text, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
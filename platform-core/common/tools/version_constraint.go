@@ -0,0 +1,195 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	versionConditionRegexp = regexp.MustCompile(`^(>=|<=|!=|>|<|=|\^|~)?\s*[vV]?([0-9]+(?:\.[0-9xX*]+)*)$`)
+	versionHyphenRegexp    = regexp.MustCompile(`^[vV]?([0-9.]+)\s+-\s+[vV]?([0-9.]+)$`)
+	versionOperatorRegexp  = regexp.MustCompile(`(>=|<=|!=|>|<|=|\^|~)\s+`)
+)
+
+type versionCondition struct {
+	op      string
+	version []int
+}
+
+// VersionConstraint 插件依赖的版本约束,||分隔的多组条件满足任意一组即可,组内空格或逗号分隔的条件都要满足
+// 支持 >=1.2.0 <2.0.0、^1.2、~1.2.3、1.2.x、1.0.0 - 2.0.0,不带操作符的版本号表示最低版本
+type VersionConstraint struct {
+	Expr   string
+	groups [][]*versionCondition
+}
+
+// ParseVersionConstraint 解析版本约束,空或*表示任意版本
+func ParseVersionConstraint(expr string) (constraint *VersionConstraint, err error) {
+	constraint = &VersionConstraint{Expr: strings.TrimSpace(expr)}
+	if constraint.Expr == "" || constraint.Expr == "*" {
+		return
+	}
+	for _, groupExpr := range strings.Split(constraint.Expr, "||") {
+		groupExpr = strings.TrimSpace(groupExpr)
+		var group []*versionCondition
+		if matchList := versionHyphenRegexp.FindStringSubmatch(groupExpr); len(matchList) == 3 {
+			group = append(group, &versionCondition{op: ">=", version: parseVersionSegments(matchList[1])}, &versionCondition{op: "<=", version: parseVersionSegments(matchList[2])})
+			constraint.groups = append(constraint.groups, group)
+			continue
+		}
+		// 操作符和版本号之间允许有空格
+		groupExpr = versionOperatorRegexp.ReplaceAllString(groupExpr, "$1")
+		for _, conditionExpr := range strings.FieldsFunc(groupExpr, func(r rune) bool { return r == ' ' || r == ',' }) {
+			conditions, parseErr := parseVersionCondition(conditionExpr)
+			if parseErr != nil {
+				err = fmt.Errorf("version constraint %s illegal,%s", expr, parseErr.Error())
+				return
+			}
+			group = append(group, conditions...)
+		}
+		if len(group) == 0 {
+			err = fmt.Errorf("version constraint %s illegal,empty condition group", expr)
+			return
+		}
+		constraint.groups = append(constraint.groups, group)
+	}
+	return
+}
+
+// Match 版本是否满足约束
+func (c *VersionConstraint) Match(version string) bool {
+	if len(c.groups) == 0 {
+		return true
+	}
+	versionSegments := parseVersionSegments(version)
+	for _, group := range c.groups {
+		matchFlag := true
+		for _, condition := range group {
+			if !condition.match(versionSegments) {
+				matchFlag = false
+				break
+			}
+		}
+		if matchFlag {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *VersionConstraint) String() string {
+	if c.Expr == "" {
+		return "*"
+	}
+	return c.Expr
+}
+
+// CompareVersionSegments 按段比较版本号,v1大返回1,相等返回0,v1小返回-1
+func CompareVersionSegments(v1, v2 string) int {
+	return compareSegments(parseVersionSegments(v1), parseVersionSegments(v2))
+}
+
+func parseVersionCondition(expr string) (conditions []*versionCondition, err error) {
+	matchList := versionConditionRegexp.FindStringSubmatch(expr)
+	if len(matchList) != 3 {
+		err = fmt.Errorf("condition %s illegal", expr)
+		return
+	}
+	op, versionExpr := matchList[1], matchList[2]
+	// 1.2.x 这种通配写法转成范围
+	if wildcardIndex := strings.IndexAny(versionExpr, "xX*"); wildcardIndex >= 0 {
+		if op != "" && op != "=" {
+			err = fmt.Errorf("condition %s illegal,wildcard only support without operator", expr)
+			return
+		}
+		prefix := strings.TrimSuffix(versionExpr[:wildcardIndex], ".")
+		if prefix == "" {
+			return
+		}
+		lower := parseVersionSegments(prefix)
+		conditions = append(conditions, &versionCondition{op: ">=", version: lower}, &versionCondition{op: "<", version: bumpSegment(lower, len(lower)-1)})
+		return
+	}
+	segments := parseVersionSegments(versionExpr)
+	switch op {
+	case "":
+		conditions = append(conditions, &versionCondition{op: ">=", version: segments})
+	case "^":
+		// 兼容第一个非0段的版本
+		bumpIndex := 0
+		for bumpIndex < len(segments)-1 && segments[bumpIndex] == 0 {
+			bumpIndex++
+		}
+		conditions = append(conditions, &versionCondition{op: ">=", version: segments}, &versionCondition{op: "<", version: bumpSegment(segments, bumpIndex)})
+	case "~":
+		// 只写了主版本时兼容主版本,否则兼容次版本
+		bumpIndex := 1
+		if len(segments) == 1 {
+			bumpIndex = 0
+		}
+		conditions = append(conditions, &versionCondition{op: ">=", version: segments}, &versionCondition{op: "<", version: bumpSegment(segments, bumpIndex)})
+	default:
+		conditions = append(conditions, &versionCondition{op: op, version: segments})
+	}
+	return
+}
+
+func (v *versionCondition) match(version []int) bool {
+	result := compareSegments(version, v.version)
+	switch v.op {
+	case ">=":
+		return result >= 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	case "<":
+		return result < 0
+	case "!=":
+		return result != 0
+	default:
+		return result == 0
+	}
+}
+
+func parseVersionSegments(input string) (segments []int) {
+	input = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(input)), "v")
+	// 忽略-之后的预发布标识
+	if index := strings.Index(input, "-"); index >= 0 {
+		input = input[:index]
+	}
+	for _, v := range strings.Split(input, ".") {
+		intV, _ := strconv.Atoi(v)
+		segments = append(segments, intV)
+	}
+	return
+}
+
+func compareSegments(v1, v2 []int) int {
+	for i := 0; i < len(v1) || i < len(v2); i++ {
+		var a, b int
+		if i < len(v1) {
+			a = v1[i]
+		}
+		if i < len(v2) {
+			b = v2[i]
+		}
+		if a != b {
+			if a > b {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
+
+// bumpSegment 指定段加一,后面的段清零,用作范围上限
+func bumpSegment(segments []int, index int) []int {
+	result := make([]int, index+1)
+	copy(result, segments[:index+1])
+	result[index]++
+	return result
+}
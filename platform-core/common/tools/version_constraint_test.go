@@ -0,0 +1,85 @@
+package tools
+
+import "testing"
+
+func TestVersionConstraintMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		version string
+		want    bool
+	}{
+		{"empty is any", "", "0.0.1", true},
+		{"star is any", "*", "9.9.9", true},
+		{"bare version is minimum", "v1.2.0", "v1.3.5", true},
+		{"bare version equal", "1.2.0", "1.2.0", true},
+		{"bare version lower", "1.2.0", "1.1.9", false},
+		{"equal", "=1.2.0", "1.2.1", false},
+		{"greater", ">1.2.0", "1.2.0", false},
+		{"less equal", "<=1.2.0", "1.2", true},
+		{"not equal", "!=1.2.0", "1.2.1", true},
+		{"operator with space", ">= 1.2.0 < 2.0.0", "1.9.9", true},
+		{"range with comma", ">=1.2.0,<2.0.0", "2.0.0", false},
+		{"caret", "^1.2.3", "1.9.0", true},
+		{"caret next major", "^1.2.3", "2.0.0", false},
+		{"caret zero major", "^0.2.3", "0.3.0", false},
+		{"tilde", "~1.2.3", "1.2.9", true},
+		{"tilde next minor", "~1.2.3", "1.3.0", false},
+		{"tilde major only", "~1", "1.9.0", true},
+		{"wildcard", "1.2.x", "1.2.7", true},
+		{"wildcard next minor", "1.2.*", "1.3.0", false},
+		{"hyphen range", "1.0.0 - 2.0.0", "2.0.0", true},
+		{"hyphen range above", "1.0.0 - 2.0.0", "2.0.1", false},
+		{"or groups", "<1.0.0 || >=2.0.0", "2.1.0", true},
+		{"or groups miss", "<1.0.0 || >=2.0.0", "1.5.0", false},
+		{"pre release ignored", ">=1.2.0", "1.2.0-beta", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			constraint, err := ParseVersionConstraint(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseVersionConstraint(%q) error: %v", tt.expr, err)
+			}
+			if got := constraint.Match(tt.version); got != tt.want {
+				t.Errorf("ParseVersionConstraint(%q).Match(%q) = %v, want %v", tt.expr, tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVersionConstraintIllegal(t *testing.T) {
+	tests := []string{
+		">=abc",
+		"1.2.0 <",
+		">=1.2.x",
+		"<1.0.0 ||",
+		"=>1.0.0",
+	}
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := ParseVersionConstraint(expr); err == nil {
+				t.Errorf("ParseVersionConstraint(%q) should fail", expr)
+			}
+		})
+	}
+}
+
+func TestCompareVersionSegments(t *testing.T) {
+	tests := []struct {
+		v1   string
+		v2   string
+		want int
+	}{
+		{"1.2.0", "1.2", 0},
+		{"v1.10.0", "1.9.9", 1},
+		{"1.2.0", "1.2.1", -1},
+		{"2.0.0-rc1", "2.0.0", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.v1+"_"+tt.v2, func(t *testing.T) {
+			if got := CompareVersionSegments(tt.v1, tt.v2); got != tt.want {
+				t.Errorf("CompareVersionSegments(%q, %q) = %d, want %d", tt.v1, tt.v2, got, tt.want)
+			}
+		})
+	}
+}
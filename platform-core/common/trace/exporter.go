@@ -0,0 +1,228 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+const (
+	ExporterOtlp   = "otlp"
+	ExporterFile   = "file"
+	ExporterStdout = "stdout"
+
+	exportBatchSize     = 512
+	exportQueueSize     = 4096
+	exportFlushInterval = 5 * time.Second
+)
+
+var (
+	enable      bool
+	serviceName = "platform-core"
+	spanChan    chan *Span
+	exporter    spanExporter
+)
+
+type spanExporter interface {
+	export(spans []*Span) error
+}
+
+// InitTracer 按配置初始化span导出,未开启时不生成span,只透传上游的traceparent
+func InitTracer() {
+	traceConfig := models.Config.Trace
+	if traceConfig == nil || !traceConfig.Enable {
+		return
+	}
+	if traceConfig.ServiceName != "" {
+		serviceName = traceConfig.ServiceName
+	}
+	switch traceConfig.Exporter {
+	case ExporterOtlp:
+		if traceConfig.Endpoint == "" {
+			log.Logger.Error("init tracer fail,otlp exporter endpoint is empty")
+			return
+		}
+		exporter = &otlpExporter{url: strings.TrimSuffix(traceConfig.Endpoint, "/") + "/v1/traces", client: &http.Client{Timeout: 10 * time.Second}}
+	case ExporterFile:
+		file, err := os.OpenFile(traceConfig.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Logger.Error("init tracer fail,open trace file fail", log.String("path", traceConfig.FilePath), log.Error(err))
+			return
+		}
+		exporter = &writerExporter{writer: file}
+	case ExporterStdout, "":
+		exporter = &writerExporter{writer: os.Stdout}
+	default:
+		log.Logger.Error("init tracer fail,exporter illegal", log.String("exporter", traceConfig.Exporter))
+		return
+	}
+	spanChan = make(chan *Span, exportQueueSize)
+	go startExportLoop()
+	enable = true
+	log.Logger.Info("init tracer done", log.String("exporter", traceConfig.Exporter), log.String("serviceName", serviceName))
+}
+
+func exportSpan(span *Span) {
+	select {
+	case spanChan <- span:
+	default:
+		log.Logger.Warn("trace span queue full,drop span", log.String("name", span.Name), log.String("traceId", span.TraceId))
+	}
+}
+
+// startExportLoop 攒够一批或到时间间隔就导出
+func startExportLoop() {
+	t := time.NewTicker(exportFlushInterval).C
+	var spans []*Span
+	for {
+		select {
+		case span := <-spanChan:
+			spans = append(spans, span)
+			if len(spans) < exportBatchSize {
+				continue
+			}
+		case <-t:
+			if len(spans) == 0 {
+				continue
+			}
+		}
+		if err := exporter.export(spans); err != nil {
+			log.Logger.Error("export trace spans fail", log.Int("spanNum", len(spans)), log.Error(err))
+		}
+		spans = []*Span{}
+	}
+}
+
+// otlpExporter 以 OTLP/HTTP json 格式推送
+type otlpExporter struct {
+	url    string
+	client *http.Client
+}
+
+func (e *otlpExporter) export(spans []*Span) error {
+	postBytes, err := json.Marshal(buildOtlpRequest(spans))
+	if err != nil {
+		return fmt.Errorf("json marshal otlp request fail,%s ", err.Error())
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(postBytes))
+	if err != nil {
+		return fmt.Errorf("new otlp request fail,%s ", err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do otlp request fail,%s ", err.Error())
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("otlp collector response %d,%s ", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// writerExporter 每个span一行json,本地调试用
+type writerExporter struct {
+	writer io.Writer
+}
+
+func (e *writerExporter) export(spans []*Span) error {
+	var buf bytes.Buffer
+	for _, span := range spans {
+		spanBytes, err := json.Marshal(buildOtlpSpan(span))
+		if err != nil {
+			return err
+		}
+		buf.Write(spanBytes)
+		buf.WriteByte('\n')
+	}
+	_, err := e.writer.Write(buf.Bytes())
+	return err
+}
+
+type otlpRequest struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource      `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []*otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope   `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceId           string           `json:"traceId"`
+	SpanId            string           `json:"spanId"`
+	ParentSpanId      string           `json:"parentSpanId,omitempty"`
+	Name              string           `json:"name"`
+	Kind              int              `json:"kind"`
+	StartTimeUnixNano string           `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string           `json:"endTimeUnixNano"`
+	Attributes        []*otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus       `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value otlpAttrString `json:"value"`
+}
+
+type otlpAttrString struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func buildOtlpRequest(spans []*Span) *otlpRequest {
+	scopeSpans := &otlpScopeSpans{Scope: otlpScope{Name: serviceName}}
+	for _, span := range spans {
+		scopeSpans.Spans = append(scopeSpans.Spans, buildOtlpSpan(span))
+	}
+	return &otlpRequest{ResourceSpans: []*otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []*otlpAttribute{{Key: "service.name", Value: otlpAttrString{StringValue: serviceName}}}},
+		ScopeSpans: []*otlpScopeSpans{scopeSpans},
+	}}}
+}
+
+func buildOtlpSpan(span *Span) *otlpSpan {
+	result := otlpSpan{
+		TraceId:           span.TraceId,
+		SpanId:            span.SpanId,
+		ParentSpanId:      span.ParentSpanId,
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+		Status:            otlpStatus{Code: span.StatusCode, Message: span.StatusMsg},
+	}
+	span.lock.Lock()
+	for key, value := range span.Attributes {
+		result.Attributes = append(result.Attributes, &otlpAttribute{Key: key, Value: otlpAttrString{StringValue: value}})
+	}
+	span.lock.Unlock()
+	return &result
+}
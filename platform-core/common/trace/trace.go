@@ -0,0 +1,197 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 按 W3C trace-context 传递 traceparent,span 结构与 OTLP 对齐
+// platform-gateway/common/trace 是同一份代码:core 和 gateway 是各自 vendor 依赖的独立 go module,没有可共同引用的公共 module,
+// 为了不引入跨 module 的 replace 依赖保留两份,只有日志、配置的引用和 serviceName 不同,修改时两边要同步
+
+const (
+	TraceparentHeader = "traceparent"
+	// ContextSpanKey 用字符串做key,gin.Context.Value 会从 c.Keys 里取到同一个span
+	ContextSpanKey = "traceSpan"
+
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+
+	StatusCodeOk    = 1
+	StatusCodeError = 2
+)
+
+// SpanContext 跨进程传递的追踪标识
+type SpanContext struct {
+	TraceId string
+	SpanId  string
+	Sampled bool
+}
+
+type Span struct {
+	SpanContext
+	ParentSpanId string
+	Name         string
+	Kind         int
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]string
+	StatusCode   int
+	StatusMsg    string
+	lock         sync.Mutex
+	ended        bool
+}
+
+// ParseTraceparent 解析 traceparent 头,格式 version-traceId-spanId-flags
+func ParseTraceparent(header string) (sc SpanContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return
+	}
+	if !isHexId(parts[1], 32) || !isHexId(parts[2], 16) || len(parts[3]) != 2 {
+		return
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return
+	}
+	sc = SpanContext{TraceId: parts[1], SpanId: parts[2], Sampled: flags[0]&1 == 1}
+	ok = true
+	return
+}
+
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceId, sc.SpanId, flags)
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceId != "" && sc.SpanId != ""
+}
+
+// SpanContextFromContext 取上下文中当前的span,或从上游带过来的span标识
+func SpanContextFromContext(ctx context.Context) (sc SpanContext) {
+	if ctx == nil {
+		return
+	}
+	switch v := ctx.Value(ContextSpanKey).(type) {
+	case *Span:
+		if v != nil {
+			sc = v.SpanContext
+		}
+	case SpanContext:
+		sc = v
+	}
+	return
+}
+
+// ContextWithSpanContext 把上游传来的span标识放进上下文,后续span作为它的子span
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, ContextSpanKey, sc)
+}
+
+// Detach 脱离请求生命周期的上下文,保留当前追踪关系,用于异步启动的工作流等
+func Detach(ctx context.Context) context.Context {
+	return ContextWithSpanContext(context.Background(), SpanContextFromContext(ctx))
+}
+
+// TraceId 当前上下文的traceId,用于日志关联
+func TraceId(ctx context.Context) string {
+	return SpanContextFromContext(ctx).TraceId
+}
+
+// StartSpan 新建span,上下文里有span时作为子span,否则新开一条trace;未开启追踪时返回nil,Span的方法都可以安全地在nil上调用
+func StartSpan(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if !enable {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	span := &Span{Name: name, Kind: kind, StartTime: time.Now(), Attributes: make(map[string]string)}
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		span.TraceId = parent.TraceId
+		span.ParentSpanId = parent.SpanId
+		span.Sampled = parent.Sampled
+	} else {
+		span.TraceId = newId(16)
+		span.Sampled = true
+	}
+	span.SpanId = newId(8)
+	return context.WithValue(ctx, ContextSpanKey, span), span
+}
+
+// StartServerSpan 以请求头里的 traceparent 为父节点新建服务端span
+func StartServerSpan(ctx context.Context, header http.Header, name string) (context.Context, *Span) {
+	if sc, ok := ParseTraceparent(header.Get(TraceparentHeader)); ok {
+		ctx = ContextWithSpanContext(ctx, sc)
+	}
+	return StartSpan(ctx, name, SpanKindServer)
+}
+
+// Inject 把上下文的追踪标识写入出站请求头
+func Inject(ctx context.Context, header http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		header.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.Attributes[key] = value
+	s.lock.Unlock()
+}
+
+// End 结束span并提交导出,err不为空时标记为失败
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	if err != nil {
+		s.StatusCode = StatusCodeError
+		s.StatusMsg = err.Error()
+	} else if s.StatusCode == 0 {
+		s.StatusCode = StatusCodeOk
+	}
+	s.lock.Unlock()
+	if s.Sampled {
+		exportSpan(s)
+	}
+}
+
+func newId(byteLen int) string {
+	b := make([]byte, byteLen)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func isHexId(input string, length int) bool {
+	if len(input) != length || strings.Trim(input, "0") == "" {
+		return false
+	}
+	_, err := hex.DecodeString(input)
+	return err == nil && strings.ToLower(input) == input
+}
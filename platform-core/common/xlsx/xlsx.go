@@ -0,0 +1,114 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 没有引入 excel 库,按 Office Open XML 最小结构输出单个 sheet,单元格全部用内联字符串
+
+const (
+	contentTypesXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	rootRelsXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	workbookRelsXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	workbookXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	sheetHeaderXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	sheetFooterXml = `</sheetData></worksheet>`
+
+	// MaxCellLength excel 单元格最多32767个字符
+	MaxCellLength = 32767
+)
+
+// Writer 逐行写入 sheet,写完必须 Close
+type Writer struct {
+	zipWriter *zip.Writer
+	sheet     *bufio.Writer
+	rowNum    int
+}
+
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	zipWriter := zip.NewWriter(w)
+	staticParts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypesXml},
+		{"_rels/.rels", rootRelsXml},
+		{"xl/workbook.xml", fmt.Sprintf(workbookXml, escape(sheetName))},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXml},
+	}
+	for _, part := range staticParts {
+		partWriter, err := zipWriter.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = io.WriteString(partWriter, part.content); err != nil {
+			return nil, err
+		}
+	}
+	sheetWriter, err := zipWriter.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	writer := &Writer{zipWriter: zipWriter, sheet: bufio.NewWriter(sheetWriter)}
+	if _, err = writer.sheet.WriteString(sheetHeaderXml); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (w *Writer) WriteRow(cells []string) error {
+	w.rowNum++
+	rowNumStr := strconv.Itoa(w.rowNum)
+	w.sheet.WriteString(`<row r="` + rowNumStr + `">`)
+	for i, cell := range cells {
+		if utf8.RuneCountInString(cell) > MaxCellLength {
+			cell = string([]rune(cell)[:MaxCellLength])
+		}
+		w.sheet.WriteString(`<c r="` + ColumnName(i) + rowNumStr + `" t="inlineStr"><is><t xml:space="preserve">`)
+		w.sheet.WriteString(escape(cell))
+		w.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := w.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (w *Writer) Close() error {
+	if _, err := w.sheet.WriteString(sheetFooterXml); err != nil {
+		return err
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zipWriter.Close()
+}
+
+// ColumnName 列序号转 excel 列名,0->A,26->AA
+func ColumnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// escape 转义xml特殊字符并去掉xml不允许的控制字符
+func escape(input string) string {
+	input = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || r >= 0x20 {
+			return r
+		}
+		return -1
+	}, input)
+	var builder strings.Builder
+	xml.EscapeText(&builder, []byte(input))
+	return builder.String()
+}
@@ -40,3 +40,20 @@ services:
       - sub_system_private_key=[#sub_system_private_key]
       - cron_keep_batch_exec_days=[#cron_keep_batch_exec_days]
       - host_ip=[#host_ip]
+      - operation_notify_type=http
+      - operation_notify_port=[#http_port]
+      - metric_port=
+      - trace_enable=false
+      - trace_exporter=otlp
+      - trace_otlp_endpoint=
+      - plugin_container_driver=ssh
+      - plugin_docker_api_port=2376
+      - plugin_docker_api_tls_enable=false
+      - plugin_k8s_namespace=wecube-plugins
+      - plugin_k8s_service_type=NodePort
+      - plugin_k8s_node_host=
+      - plugin_k8s_image_registry=
+      - plugin_k8s_image_pull_secret=
+      - plugin_health_check_enable=true
+      - plugin_health_auto_restart=false
+      - plugin_health_alarm_role=
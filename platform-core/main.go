@@ -4,9 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"github.com/WeBankPartners/wecube-platform/platform-core/api"
+	batch_execution "github.com/WeBankPartners/wecube-platform/platform-core/api/v1/batch-execution"
 	"github.com/WeBankPartners/wecube-platform/platform-core/api/v1/process"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/trace"
 	"github.com/WeBankPartners/wecube-platform/platform-core/models"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/bash"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/cron"
@@ -22,6 +24,7 @@ func main() {
 		return
 	}
 	log.InitLogger()
+	trace.InitTracer()
 	if initDbError := db.InitDatabase(); initDbError != nil {
 		return
 	}
@@ -32,6 +35,7 @@ func main() {
 	go bash.InitPluginDockerHostSSH()
 	workflow.StartCronJob()
 	process.InitProcScheduleTimer()
+	batch_execution.InitBatchExecScheduleTimer()
 	//start http
 	api.InitHttpServer()
 }
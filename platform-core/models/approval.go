@@ -0,0 +1,60 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	ApprovalQuorumAll = "all"
+	ApprovalQuorumAny = "any"
+
+	ApprovalVoteApprove = "approve"
+	ApprovalVoteReject  = "reject"
+
+	ApprovalResultApproved = "approved"
+	ApprovalResultRejected = "rejected"
+)
+
+// ApprovalConfigDto 多级审批节点配置,按顺序逐级审批
+type ApprovalConfigDto struct {
+	Stages []*ApprovalStageDto `json:"stages"` // 审批阶段
+}
+
+// ApprovalStageDto 审批阶段,持有列表中任一角色的用户可投票,任一驳回票即驳回整个审批
+type ApprovalStageDto struct {
+	Name   string   `json:"name"`   // 阶段名称
+	Roles  []string `json:"roles"`  // 审批角色
+	Quorum string   `json:"quorum"` // 通过规则->all(每个角色都有人通过) | any(任一人通过) | N(N个不同用户通过)
+}
+
+// ProcRunApprovalVote 审批投票纪录
+type ProcRunApprovalVote struct {
+	Id            int       `json:"id" xorm:"id"`                          // 自增id
+	ProcRunNodeId string    `json:"procRunNodeId" xorm:"proc_run_node_id"` // 任务节点id
+	ProcInsNodeId string    `json:"procInsNodeId" xorm:"proc_ins_node_id"` // 编排节点id
+	ProcInsId     string    `json:"procInsId" xorm:"proc_ins_id"`          // 编排实例id
+	Stage         int       `json:"stage" xorm:"stage"`                    // 阶段序号,从0开始
+	StageName     string    `json:"stageName" xorm:"stage_name"`           // 阶段名称
+	Voter         string    `json:"voter" xorm:"voter"`                    // 投票人
+	Role          string    `json:"role" xorm:"role"`                      // 投票人代表的角色
+	Decision      string    `json:"decision" xorm:"decision"`              // 投票->approve(通过) | reject(驳回)
+	Comment       string    `json:"comment" xorm:"comment"`                // 意见
+	RunStartTime  time.Time `json:"runStartTime" xorm:"run_start_time"`    // 任务节点本次运行开始时间
+	CreatedTime   time.Time `json:"createdTime" xorm:"created_time"`       // 投票时间
+}
+
+// ApprovalVoteParam 审批投票参数
+type ApprovalVoteParam struct {
+	Decision string `json:"decision" binding:"required"` // approve | reject
+	Comment  string `json:"comment"`
+}
+
+// ValidQuorum 通过规则为all、any或大于0的整数
+func (a *ApprovalStageDto) ValidQuorum() bool {
+	if a.Quorum == ApprovalQuorumAll || a.Quorum == ApprovalQuorumAny {
+		return true
+	}
+	num, err := strconv.Atoi(a.Quorum)
+	return err == nil && num > 0
+}
@@ -0,0 +1,94 @@
+package models
+
+import "time"
+
+// BatchExecScheduleConfig 批量执行模板定时配置
+type BatchExecScheduleConfig struct {
+	Id                         string    `json:"id" xorm:"id"`                                                    // 唯一标识
+	BatchExecutionTemplateId   string    `json:"batchExecutionTemplateId" xorm:"batch_execution_template_id"`     // 批量执行模板id
+	BatchExecutionTemplateName string    `json:"batchExecutionTemplateName" xorm:"batch_execution_template_name"` // 批量执行模板名称
+	EntityFilters              string    `json:"entityFilters" xorm:"entity_filters"`                             // 每次执行时查询目标实体的过滤条件json
+	Status                     string    `json:"status" xorm:"status"`                                            // 状态->Ready(正在运行) | Stopped(暂停) | Deleted(删除)
+	ScheduleMode               string    `json:"scheduleMode" xorm:"schedule_mode"`                               // 定时模式->Monthly(每月) | Weekly(每周) | Daily(每天) | Hourly(每小时)
+	ScheduleExpr               string    `json:"scheduleExpr" xorm:"schedule_expr"`                               // 时间表达式
+	CronExpr                   string    `json:"cronExpr" xorm:"cron_expr"`                                       // cron表达式
+	ExecTimes                  int       `json:"execTimes" xorm:"exec_times"`                                     // 执行次数
+	Role                       string    `json:"role" xorm:"role"`                                                // 管理角色
+	MailMode                   string    `json:"mailMode" xorm:"mail_mode"`                                       // 邮件发送模式->role(角色邮箱) | user(用户邮箱) | none(不发送)
+	CreatedBy                  string    `json:"createdBy" xorm:"created_by"`                                     // 创建人
+	CreatedTime                time.Time `json:"createdTime" xorm:"created_time"`                                 // 创建时间
+	UpdatedBy                  string    `json:"updatedBy" xorm:"updated_by"`                                     // 更新人
+	UpdatedTime                time.Time `json:"updatedTime" xorm:"updated_time"`                                 // 更新时间
+}
+
+// BatchExecScheduleJob 批量执行定时任务每次触发的纪录
+type BatchExecScheduleJob struct {
+	Id               string    `json:"id" xorm:"id"`                               // 定时配置id加时间戳
+	ScheduleConfigId string    `json:"scheduleConfigId" xorm:"schedule_config_id"` // 定时配置id
+	BatchExecutionId string    `json:"batchExecutionId" xorm:"batch_execution_id"` // 批量执行id
+	EntityNum        int       `json:"entityNum" xorm:"entity_num"`                // 本次查到的目标实体数
+	Status           string    `json:"status" xorm:"status"`                       // 状态->ready(准备启动) | fail(报错) | done(已启动)
+	HandleBy         string    `json:"handleBy" xorm:"handle_by"`                  // 处理的主机
+	ErrorMsg         string    `json:"errorMsg" xorm:"error_msg"`                  // 错误信息
+	MailStatus       string    `json:"mailStatus" xorm:"mail_status"`              // 邮件状态->none(不发邮件) | wait(等待发) | sending(正在发) | fail(发送失败) | done(已发送)
+	MailMsg          string    `json:"mailMsg" xorm:"mail_msg"`                    // 邮件通知信息
+	CreatedTime      time.Time `json:"createdTime" xorm:"created_time"`            // 创建时间
+	UpdatedTime      time.Time `json:"updatedTime" xorm:"updated_time"`            // 更新时间
+}
+
+type CreateBatchExecScheduleParam struct {
+	BatchExecutionTemplateId string                       `json:"batchExecutionTemplateId" binding:"required"`
+	ScheduleMode             string                       `json:"scheduleMode" binding:"required"`
+	ScheduleExpr             string                       `json:"scheduleExpr" binding:"required"`
+	EntityFilters            []*QueryExpressionDataFilter `json:"entityFilters"` // 目标实体过滤条件,为空时执行表达式查到的全部实体
+	Role                     string                       `json:"role" binding:"required"`
+	MailMode                 string                       `json:"mailMode" binding:"required"` // 邮件发送模式->role(角色邮箱) | user(用户邮箱) | none(不发送)
+	TemplateName             string                       `json:"-"`
+	CronExpr                 string                       `json:"-"`
+	Operator                 string                       `json:"-"`
+}
+
+type BatchExecScheduleQueryParam struct {
+	ScheduleMode             string `json:"scheduleMode"`
+	Owner                    string `json:"owner"`
+	BatchExecutionTemplateId string `json:"batchExecutionTemplateId"`
+}
+
+type BatchExecScheduleConfigObj struct {
+	BatchExecScheduleConfig
+	TotalSucceed   int `json:"totalSucceed"`   // 执行成功次数
+	TotalFailed    int `json:"totalFailed"`    // 执行失败次数,包括高危拦截和取消
+	TotalRunning   int `json:"totalRunning"`   // 执行中次数
+	TotalJobFailed int `json:"totalJobFailed"` // 查询实体或启动执行失败次数
+}
+
+// BatchExecScheduleExecObj 定时任务触发的批量执行结果
+type BatchExecScheduleExecObj struct {
+	JobId            string `json:"jobId" xorm:"id"`
+	JobStatus        string `json:"jobStatus" xorm:"status"`
+	ErrorMsg         string `json:"errorMsg" xorm:"error_msg"`
+	EntityNum        int    `json:"entityNum" xorm:"entity_num"`
+	BatchExecutionId string `json:"batchExecutionId" xorm:"batch_execution_id"`
+	ErrorCode        string `json:"errorCode" xorm:"error_code"` // 批量执行状态, 0:成功, 1:失败, 2:执行中, 3:高危拦截, 4:已取消
+	ExecTime         string `json:"execTime" xorm:"created_time"`
+}
+
+type BatchExecScheduleQueryRow struct {
+	Id        string `xorm:"id"`
+	Status    string `xorm:"status"`
+	ErrorCode string `xorm:"error_code"`
+	Num       int    `xorm:"num"`
+}
+
+type BatchExecScheduleJobMailQueryObj struct {
+	Id               string `xorm:"id"`
+	ScheduleConfigId string `xorm:"schedule_config_id"`
+	Status           string `xorm:"status"`
+	ErrorMsg         string `xorm:"error_msg"`
+	EntityNum        int    `xorm:"entity_num"`
+	BatchExecutionId string `xorm:"batch_execution_id"`
+	BatchExecName    string `xorm:"batch_exec_name"`
+	ErrorCode        string `xorm:"error_code"`
+	ErrorMessage     string `xorm:"error_message"`
+	CreatedTime      string `xorm:"created_time"`
+}
@@ -7,7 +7,7 @@ type BatchExecution struct {
 	Name                       string                `json:"name" xorm:"name"`                                                // 名称
 	BatchExecutionTemplateId   string                `json:"batchExecutionTemplateId" xorm:"batch_execution_template_id"`     // 模板id
 	BatchExecutionTemplateName string                `json:"batchExecutionTemplateName" xorm:"batch_execution_template_name"` // 模板名称
-	ErrorCode                  string                `json:"errorCode" xorm:"error_code"`                                     // 错误码, 0:成功, 1:失败, 2:执行中
+	ErrorCode                  string                `json:"errorCode" xorm:"error_code"`                                     // 错误码, 0:成功, 1:失败, 2:执行中, 3:高危拦截, 4:已取消
 	ErrorMessage               string                `json:"errorMessage" xorm:"error_message"`                               // 错误信息
 	ConfigDataStr              string                `json:"-" xorm:"config_data"`                                            // 配置数据
 	ConfigData                 *BatchExecRun         `json:"configData" xorm:"-"`                                             // 配置数据
@@ -18,6 +18,7 @@ type BatchExecution struct {
 	CreatedTimeStr             string                `json:"createdTime" xorm:"-"`                                            // 创建时间
 	UpdatedTime                *time.Time            `json:"updatedTimeT" xorm:"updated_time"`                                // 更新时间
 	UpdatedTimeStr             string                `json:"updatedTime" xorm:"-"`                                            // 更新时间
+	ExecHost                   string                `json:"-" xorm:"exec_host"`                                              // 执行的实例
 	BatchExecutionJobs         []*BatchExecutionJobs `json:"batchExecutionJobs" xorm:"-"`
 }
 
@@ -36,7 +37,7 @@ type BatchExecutionJobs struct {
 	ExecuteTimeStr          string     `json:"executeTime" xorm:"-"`                                      // 执行时间
 	CompleteTime            *time.Time `json:"completeTimeT" xorm:"complete_time"`                        // 完成时间
 	CompleteTimeStr         string     `json:"completeTime" xorm:"-"`                                     // 完成时间
-	ErrorCode               string     `json:"errorCode" xorm:"error_code"`                               // 错误码, 0:成功, 1:失败, 2:执行中
+	ErrorCode               string     `json:"errorCode" xorm:"error_code"`                               // 错误码, 0:成功, 1:失败, 2:执行中, 4:已取消
 	ErrorMessage            string     `json:"errorMessage" xorm:"error_message"`                         // 错误信息
 	InputJson               string     `json:"inputJson" xorm:"input_json"`                               // 输入json
 	ReturnJson              string     `json:"returnJson" xorm:"return_json"`                             // 输出json
@@ -170,6 +171,18 @@ type BatchExecRunResp struct {
 	DangerousCheckResult *ItsdangerousBatchCheckResultData `json:"dangerousCheckResult"`
 }
 
+// BatchExecProgress 批量执行进度,按实体统计
+type BatchExecProgress struct {
+	BatchExecId  string `json:"batchExecId"`
+	ErrorCode    string `json:"errorCode"`    // 整体状态, 0:成功, 1:失败, 2:执行中, 4:已取消
+	ErrorMessage string `json:"errorMessage"` // 整体错误信息
+	Total        int    `json:"total"`        // 实体总数
+	Succeed      int    `json:"succeed"`      // 成功数
+	Failed       int    `json:"failed"`       // 失败数
+	Running      int    `json:"running"`      // 待执行和执行中数
+	Canceled     int    `json:"canceled"`     // 取消未执行数
+}
+
 type BatchExecutionItsdangerousExecParam struct {
 	Operator        string                                     `json:"operator"`
 	ServiceName     string                                     `json:"serviceName"`
@@ -14,6 +14,7 @@ type HttpServerConfig struct {
 	Cross             bool   `json:"cross"`
 	ErrorTemplateDir  string `json:"error_template_dir"`
 	ErrorDetailReturn bool   `json:"error_detail_return"`
+	MetricPort        string `json:"metric_port"` // 监控指标单独监听端口,为空时/metrics走鉴权
 }
 
 type LogConfig struct {
@@ -63,12 +64,15 @@ type StaticResourceConfig struct {
 }
 
 type PluginJsonConfig struct {
-	BaseMountPath         string `json:"base_mount_path"`
-	DeployPath            string `json:"deploy_path"`
-	PasswordPubKeyPath    string `json:"password_pub_key_path"`
-	PasswordPubKeyContent string `json:"-"`
-	ResourcePasswordSeed  string `json:"resource_password_seed"`
-	PublicReleaseUrl      string `json:"public_release_url"`
+	BaseMountPath         string                   `json:"base_mount_path"`
+	DeployPath            string                   `json:"deploy_path"`
+	PasswordPubKeyPath    string                   `json:"password_pub_key_path"`
+	PasswordPubKeyContent string                   `json:"-"`
+	ResourcePasswordSeed  string                   `json:"resource_password_seed"`
+	PublicReleaseUrl      string                   `json:"public_release_url"`
+	ContainerRuntime      *ContainerRuntimeConfig  `json:"container_runtime"`
+	Kubernetes            *K8sConfig               `json:"kubernetes"`
+	HealthCheck           *PluginHealthCheckConfig `json:"health_check"`
 }
 
 type GatewayConfig struct {
@@ -80,6 +84,25 @@ type CronConfig struct {
 	KeepBatchExecDays int64 `json:"keep_batch_exec_days"`
 }
 
+type WorkflowConfig struct {
+	OperationNotifyType  string `json:"operation_notify_type"`  // 操作通知方式->http(直接通知工作流所在实例) | 空(仅轮询)
+	OperationNotifyPort  string `json:"operation_notify_port"`  // 实例间通知端口,为空时用http_server端口
+	OperationScanSeconds int    `json:"operation_scan_seconds"` // 操作表轮询间隔秒数
+}
+
+type BatchExecConfig struct {
+	ChunkSize   int `json:"chunk_size"`  // 每次插件调用的实体数
+	Parallelism int `json:"parallelism"` // 同一批量执行并发调用的分片数
+}
+
+type TraceConfig struct {
+	Enable      bool   `json:"enable"`
+	Exporter    string `json:"exporter"`     // 导出方式->otlp | file | stdout
+	Endpoint    string `json:"endpoint"`     // otlp http 地址,如 http://127.0.0.1:4318
+	FilePath    string `json:"file_path"`    // file 方式的导出文件
+	ServiceName string `json:"service_name"` // 服务名,默认 platform-core
+}
+
 type GlobalConfig struct {
 	Version                string                  `json:"version"`
 	DefaultLanguage        string                  `json:"default_language"`
@@ -95,6 +118,9 @@ type GlobalConfig struct {
 	Plugin                 *PluginJsonConfig       `json:"plugin"`
 	Gateway                *GatewayConfig          `json:"gateway"`
 	Cron                   *CronConfig             `json:"cron"`
+	Workflow               *WorkflowConfig         `json:"workflow"`
+	Trace                  *TraceConfig            `json:"trace"`
+	BatchExec              *BatchExecConfig        `json:"batch_execution"`
 }
 
 var (
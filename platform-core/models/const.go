@@ -53,6 +53,9 @@ const (
 	BatchExecErrorCodeFailed            = "1"
 	BatchExecErrorCodePending           = "2"
 	BatchExecErrorCodeDangerousBlock    = "3"
+	BatchExecErrorCodeCanceled          = "4"
+	DefaultBatchExecChunkSize           = 50
+	DefaultBatchExecParallelism         = 5
 	DefaultKeepBatchExecDays            = 365
 
 	// permission type
@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+const (
+	ContainerDriverSsh       = "ssh"        // ssh登录目标机器执行docker命令
+	ContainerDriverDockerApi = "docker_api" // 通过tcp/tls调用docker engine api
+	ContainerDriverK8s       = "kubernetes" // kubernetes集群,由资源服务器类型决定
+
+	ResourceServerTypeDocker = "docker"
+	ResourceServerTypeK8s    = "kubernetes" // host和port为api server地址,login_password为service account token
+
+	ContainerStateCreated = "created"
+	ContainerStateRunning = "running"
+	ContainerStateExited  = "exited"
+	ContainerStateMissing = "missing"
+	ContainerStatePending = "pending"
+
+	K8sServiceTypeNodePort  = "NodePort"
+	K8sServiceTypeClusterIP = "ClusterIP"
+)
+
+// ContainerRunParam 启动容器参数
+type ContainerRunParam struct {
+	Name           string   `json:"name"`           // 容器名
+	Image          string   `json:"image"`          // 镜像名
+	PortBindings   []string `json:"portBindings"`   // 端口映射->宿主端口:容器端口
+	VolumeBindings []string `json:"volumeBindings"` // 目录挂载->宿主目录:容器目录
+	EnvVariables   []string `json:"envVariables"`   // 环境变量->key=value
+}
+
+// ContainerState 容器运行状态
+type ContainerState struct {
+	Id         string    `json:"id"`         // 容器id
+	Name       string    `json:"name"`       // 容器名
+	Image      string    `json:"image"`      // 镜像名
+	Status     string    `json:"status"`     // 状态->created | running | exited | missing(不存在)
+	Running    bool      `json:"running"`    // 是否运行中
+	ExitCode   int       `json:"exitCode"`   // 退出码
+	Error      string    `json:"error"`      // 运行报错
+	StartedAt  time.Time `json:"startedAt"`  // 启动时间
+	FinishedAt time.Time `json:"finishedAt"` // 退出时间
+}
+
+// ContainerRuntimeConfig 插件容器运行时配置
+type ContainerRuntimeConfig struct {
+	Driver        string `json:"driver"`          // 驱动->ssh(默认) | docker_api
+	DockerApiPort string `json:"docker_api_port"` // docker engine api端口,默认2376
+	TlsEnable     bool   `json:"tls_enable"`      // docker engine api是否启用tls
+	TlsCaFile     string `json:"tls_ca_file"`
+	TlsCertFile   string `json:"tls_cert_file"`
+	TlsKeyFile    string `json:"tls_key_file"`
+	StopTimeout   int    `json:"stop_timeout"` // 停止容器等待秒数,默认10
+}
+
+// K8sConfig 插件部署到kubernetes的配置
+type K8sConfig struct {
+	Namespace          string `json:"namespace"`            // 命名空间,默认wecube-plugins
+	ServiceType        string `json:"service_type"`         // Service类型->NodePort(默认) | ClusterIP(gateway在集群内时)
+	NodeHost           string `json:"node_host"`            // NodePort访问地址,为空时用api server的host
+	ImageRegistry      string `json:"image_registry"`       // 插件镜像仓库前缀,镜像需提前推送到仓库
+	ImagePullSecret    string `json:"image_pull_secret"`    // 拉取镜像的secret
+	CaFile             string `json:"ca_file"`              // api server的ca证书
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // 不校验api server证书
+	ReadyTimeout       int    `json:"ready_timeout"`        // 启动后等待pod就绪的秒数,默认120
+}
@@ -0,0 +1,99 @@
+package models
+
+import "time"
+
+const (
+	HumanTaskAssigneeRole = "role"
+	HumanTaskAssigneeUser = "user"
+
+	HumanTaskStatusPending = "pending"
+	HumanTaskStatusDone    = "done"
+
+	HumanTaskActionCreate   = "create"
+	HumanTaskActionReassign = "reassign"
+	HumanTaskActionDelegate = "delegate"
+	HumanTaskActionEscalate = "escalate"
+	HumanTaskActionRemind   = "remind"
+	HumanTaskActionComplete = "complete"
+	HumanTaskActionRevert   = "revert"
+)
+
+// HumanTaskConfigDto 人工节点任务配置
+type HumanTaskConfigDto struct {
+	AssigneeType      string `json:"assigneeType"`      // 处理人类型->role(角色) | user(用户)
+	Assignee          string `json:"assignee"`          // 处理角色或用户,为空时默认编排启动人
+	SlaMinutes        int    `json:"slaMinutes"`        // 处理时限分钟,0不限制
+	EscalationMinutes int    `json:"escalationMinutes"` // 等待多少分钟后升级,0不升级
+	EscalationRole    string `json:"escalationRole"`    // 升级后的处理角色
+	RemindMinutes     int    `json:"remindMinutes"`     // 提醒邮件间隔分钟,0不提醒
+}
+
+// ProcRunHumanTask 人工任务
+type ProcRunHumanTask struct {
+	Id             string    `json:"id" xorm:"id"`                           // 唯一标识
+	ProcRunNodeId  string    `json:"procRunNodeId" xorm:"proc_run_node_id"`  // 任务节点id
+	ProcInsNodeId  string    `json:"procInsNodeId" xorm:"proc_ins_node_id"`  // 编排节点id
+	ProcInsId      string    `json:"procInsId" xorm:"proc_ins_id"`           // 编排实例id
+	AssigneeType   string    `json:"assigneeType" xorm:"assignee_type"`      // 处理人类型->role(角色) | user(用户)
+	Assignee       string    `json:"assignee" xorm:"assignee"`               // 处理角色或用户
+	DelegateFrom   string    `json:"delegateFrom" xorm:"delegate_from"`      // 委托人
+	Status         string    `json:"status" xorm:"status"`                   // 状态->pending(待处理) | done(已处理)
+	DueTime        time.Time `json:"dueTime" xorm:"due_time"`                // 处理时限
+	EscalateTime   time.Time `json:"escalateTime" xorm:"escalate_time"`      // 升级时间
+	EscalationRole string    `json:"escalationRole" xorm:"escalation_role"`  // 升级后的处理角色
+	Escalated      bool      `json:"escalated" xorm:"escalated"`             // 是否已升级
+	RemindMinutes  int       `json:"remindMinutes" xorm:"remind_minutes"`    // 提醒邮件间隔分钟
+	LastRemindTime time.Time `json:"lastRemindTime" xorm:"last_remind_time"` // 上次提醒时间
+	CreatedTime    time.Time `json:"createdTime" xorm:"created_time"`        // 创建时间
+	UpdatedTime    time.Time `json:"updatedTime" xorm:"updated_time"`        // 更新时间
+	CompletedTime  time.Time `json:"completedTime" xorm:"completed_time"`    // 完成时间
+}
+
+// ProcRunHumanTaskLog 人工任务处理纪录
+type ProcRunHumanTaskLog struct {
+	Id           int       `json:"id" xorm:"id"`                      // 自增id
+	TaskId       string    `json:"taskId" xorm:"task_id"`             // 人工任务id
+	Action       string    `json:"action" xorm:"action"`              // 动作->create | reassign | delegate | escalate | remind | complete | revert
+	FromAssignee string    `json:"fromAssignee" xorm:"from_assignee"` // 原处理人
+	ToAssignee   string    `json:"toAssignee" xorm:"to_assignee"`     // 新处理人
+	Message      string    `json:"message" xorm:"message"`            // 说明
+	CreatedBy    string    `json:"createdBy" xorm:"created_by"`       // 操作人
+	CreatedTime  time.Time `json:"createdTime" xorm:"created_time"`   // 创建时间
+}
+
+// HumanTaskQueryObj 待办任务查询结果
+type HumanTaskQueryObj struct {
+	ProcRunHumanTask `xorm:"extends"`
+	NodeName         string `json:"nodeName" xorm:"node_name"`              // 节点名称
+	ProcDefId        string `json:"procDefId" xorm:"proc_def_id"`           // 编排定义id
+	ProcDefName      string `json:"procDefName" xorm:"proc_def_name"`       // 编排名称
+	EntityDataName   string `json:"entityDataName" xorm:"entity_data_name"` // 根数据名称
+	Overdue          bool   `json:"overdue" xorm:"-"`                       // 是否超过处理时限
+}
+
+// HumanTaskAssignParam 任务转派或委托参数
+type HumanTaskAssignParam struct {
+	AssigneeType string `json:"assigneeType"` // 处理人类型->role | user,委托只能是user
+	Assignee     string `json:"assignee" binding:"required"`
+	Message      string `json:"message"`
+}
+
+// PluginTaskReassignParam 通知任务表单插件处理人变更参数
+type PluginTaskReassignParam struct {
+	ProcInstId   string `json:"procInstId"`   // 编排实例id
+	NodeDefId    string `json:"nodeDefId"`    // 编排节点定义id
+	TaskId       string `json:"taskId"`       // 人工任务id
+	Action       string `json:"action"`       // 动作->reassign | delegate | escalate
+	AssigneeType string `json:"assigneeType"` // 处理人类型->role | user
+	Assignee     string `json:"assignee"`     // 处理角色或用户
+	DelegateFrom string `json:"delegateFrom"` // 委托人
+	Operator     string `json:"operator"`     // 操作人
+}
+
+// ValidAssignee 处理人类型为空或role、user,配置了类型时处理人不能为空
+func (h *HumanTaskConfigDto) ValidAssignee() bool {
+	if h.AssigneeType == "" {
+		return h.Assignee == ""
+	}
+	return (h.AssigneeType == HumanTaskAssigneeRole || h.AssigneeType == HumanTaskAssigneeUser) && h.Assignee != ""
+}
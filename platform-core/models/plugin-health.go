@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+const (
+	PluginHealthStatusUnknown   = "unknown"
+	PluginHealthStatusHealthy   = "healthy"
+	PluginHealthStatusUnhealthy = "unhealthy"
+
+	PluginInstanceStatusRunning   = "RUNNING"
+	PluginInstanceStatusUnhealthy = "UNHEALTHY" // 健康检查失败,不注册到gateway
+
+	DefaultPluginHealthCheckInterval = 30
+	DefaultPluginHealthCheckTimeout  = 5
+	DefaultPluginHealthFailThreshold = 3
+	DefaultPluginMaxRestarts         = 3
+)
+
+// PluginHealthCheckConfig 插件实例健康检查配置
+type PluginHealthCheckConfig struct {
+	Enable           bool   `json:"enable"`
+	IntervalSeconds  int    `json:"interval_seconds"`  // 检查间隔秒数,默认30
+	TimeoutSeconds   int    `json:"timeout_seconds"`   // 单次探测超时秒数,默认5
+	FailureThreshold int    `json:"failure_threshold"` // 连续失败多少次判定不健康,默认3
+	AutoRestart      bool   `json:"auto_restart"`      // 不健康时是否自动重启容器
+	MaxRestarts      int    `json:"max_restarts"`      // 恢复健康前最多自动重启次数,默认3
+	AlarmRole        string `json:"alarm_role"`        // 告警邮件发给该角色邮箱,为空不发
+}
+
+// PluginInstanceHealth 插件实例健康状态
+type PluginInstanceHealth struct {
+	InstanceId          string    `json:"instanceId" xorm:"instance_id"`                   // 插件实例id
+	HealthStatus        string    `json:"healthStatus" xorm:"health_status"`               // 健康状态->unknown | healthy | unhealthy
+	ConsecutiveFailures int       `json:"consecutiveFailures" xorm:"consecutive_failures"` // 连续失败次数
+	LastCheckTime       time.Time `json:"lastCheckTime" xorm:"last_check_time"`            // 最近检查时间
+	LastSuccessTime     time.Time `json:"lastSuccessTime" xorm:"last_success_time"`        // 最近检查成功时间
+	LastError           string    `json:"lastError" xorm:"last_error"`                     // 最近失败原因
+	RestartTimes        int       `json:"restartTimes" xorm:"restart_times"`               // 恢复健康前已自动重启次数
+	LastRestartTime     time.Time `json:"lastRestartTime" xorm:"last_restart_time"`        // 最近自动重启时间
+	CheckHost           string    `json:"checkHost" xorm:"check_host"`                     // 执行检查的platform主机
+	UpdatedTime         time.Time `json:"updatedTime" xorm:"updated_time"`                 // 更新时间
+}
+
+// PluginInstanceHealthQueryObj 健康检查的插件实例
+type PluginInstanceHealthQueryObj struct {
+	PluginInstanceHealth     `xorm:"extends"`
+	Id                       string `json:"id" xorm:"id"`                                                // 插件实例id
+	Host                     string `json:"host" xorm:"host"`                                            // 主机
+	Port                     int    `json:"port" xorm:"port"`                                            // 端口
+	ContainerName            string `json:"containerName" xorm:"container_name"`                         // 容器名
+	ContainerStatus          string `json:"containerStatus" xorm:"container_status"`                     // 容器状态
+	DockerInstanceResourceId string `json:"dockerInstanceResourceId" xorm:"docker_instance_resource_id"` // 容器实例资源id
+	PackageName              string `json:"packageName" xorm:"package_name"`                             // 插件名
+	PackageVersion           string `json:"packageVersion" xorm:"package_version"`                       // 插件版本
+	HealthCheckPath          string `json:"healthCheckPath" xorm:"health_check_path"`                    // 健康检查http路径
+}
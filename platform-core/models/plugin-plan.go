@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+const (
+	PluginPlanActionPull     = "pull"     // 从在线插件仓库拉取并上传
+	PluginPlanActionRegister = "register" // 注册插件包
+	PluginPlanActionLaunch   = "launch"   // 创建实例
+	PluginPlanActionUpgrade  = "upgrade"  // 滚动升级已运行的旧版本
+
+	PluginPlanSourceRunning    = "running"    // 已有运行中实例
+	PluginPlanSourceRegistered = "registered" // 已注册未运行
+	PluginPlanSourceUploaded   = "uploaded"   // 已上传未注册
+	PluginPlanSourceArtifact   = "artifact"   // 在线插件仓库
+
+	PluginInstallPlanStatusRunning = "running"
+	PluginInstallPlanStatusDone    = "done"
+	PluginInstallPlanStatusFailed  = "failed"
+)
+
+// PluginInstallPlanParam 插件安装计划参数
+type PluginInstallPlanParam struct {
+	PackageIds    []string                          `json:"packageIds"`    // 本地已上传的插件包
+	ArtifactKeys  []string                          `json:"artifactKeys"`  // 在线插件仓库/plugin-artifacts里的文件名
+	ResolveOnline bool                              `json:"resolveOnline"` // 依赖在本地找不到时从在线插件仓库找
+	Targets       map[string][]*PluginUpgradeTarget `json:"targets"`       // 按插件名指定实例部署位置,执行时需要创建或升级实例的插件必填
+}
+
+// PluginPlanDependency 插件依赖及解析结果
+type PluginPlanDependency struct {
+	Name            string `json:"name"`            // 依赖插件名,platform表示平台版本
+	Constraint      string `json:"constraint"`      // 版本约束
+	ResolvedVersion string `json:"resolvedVersion"` // 满足约束的版本
+}
+
+// PluginPlanItem 计划中的一个插件版本
+type PluginPlanItem struct {
+	Name           string                  `json:"name"`
+	Version        string                  `json:"version"`
+	PackageId      string                  `json:"packageId"`      // 本地插件包id,在线拉取的执行后才有
+	ArtifactKey    string                  `json:"artifactKey"`    // 在线插件仓库文件名
+	Source         string                  `json:"source"`         // 来源->running | registered | uploaded | artifact
+	RunningVersion string                  `json:"runningVersion"` // 当前运行的版本
+	Requested      bool                    `json:"requested"`      // 是否用户指定的,否则是依赖带进来的
+	Dependencies   []*PluginPlanDependency `json:"dependencies"`
+	Actions        []string                `json:"actions"`     // 要执行的动作,按顺序->pull | register | launch | upgrade
+	DoneActions    []string                `json:"doneActions"` // 执行完的动作
+}
+
+// PluginPlanConflict 无法满足的依赖或与运行中插件的冲突
+type PluginPlanConflict struct {
+	Name       string `json:"name"`       // 冲突的插件名
+	Constraint string `json:"constraint"` // 要求的版本约束
+	RequiredBy string `json:"requiredBy"` // 提出约束的插件,name:version
+	Message    string `json:"message"`
+}
+
+// PluginInstallPlan 插件安装计划,items按依赖拓扑排序,被依赖的在前
+type PluginInstallPlan struct {
+	Items      []*PluginPlanItem     `json:"items"`
+	Conflicts  []*PluginPlanConflict `json:"conflicts"`
+	Executable bool                  `json:"executable"` // 没有冲突才能执行
+}
+
+// PluginInstallPlanRecord 插件安装计划执行纪录
+type PluginInstallPlanRecord struct {
+	Id           string             `json:"id" xorm:"id"`                      // 唯一标识
+	Status       string             `json:"status" xorm:"status"`              // 状态->running | done | failed
+	Plan         string             `json:"-" xorm:"plan"`                     // 计划内容json
+	CurrentItem  string             `json:"currentItem" xorm:"current_item"`   // 执行中的插件,name:version
+	ErrorMessage string             `json:"errorMessage" xorm:"error_message"` // 失败原因
+	CreatedBy    string             `json:"createdBy" xorm:"created_by"`       // 创建人
+	CreatedTime  time.Time          `json:"createdTime" xorm:"created_time"`   // 创建时间
+	UpdatedTime  time.Time          `json:"updatedTime" xorm:"updated_time"`   // 更新时间
+	PlanObj      *PluginInstallPlan `json:"plan" xorm:"-"`
+}
+
+// PluginPlanPackageRow 本地插件包及运行中实例数
+type PluginPlanPackageRow struct {
+	PluginPackages   `xorm:"extends"`
+	RunningInstances int `json:"runningInstances" xorm:"running_instances"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+const (
+	PluginInstanceStatusStandby  = "STANDBY"  // 升级中新版本实例已启动但还没切流量
+	PluginInstanceStatusDraining = "DRAINING" // 升级中旧版本实例已摘除路由等待销毁
+
+	PluginUpgradeStatusPreparing  = "preparing"
+	PluginUpgradeStatusLaunching  = "launching"
+	PluginUpgradeStatusVerifying  = "verifying"
+	PluginUpgradeStatusDraining   = "draining"
+	PluginUpgradeStatusDone       = "done"
+	PluginUpgradeStatusRolledBack = "rolled_back"
+	PluginUpgradeStatusFailed     = "failed"
+
+	DefaultPluginUpgradeDrainSeconds  = 30
+	DefaultPluginUpgradeHealthTimeout = 300
+)
+
+// PluginLaunchParam 插件实例创建参数
+type PluginLaunchParam struct {
+	PackageId     string
+	HostIp        string
+	Port          int
+	ContainerName string // 为空时取插件注册的容器名
+	Standby       bool   // 启动后不加入gateway路由
+	SkipDbUpgrade bool   // 已有数据库时不执行升级脚本,滚动升级在新实例健康后再执行
+	Operator      string
+}
+
+// PluginUpgradeTarget 新版本实例部署位置
+type PluginUpgradeTarget struct {
+	HostIp string `json:"hostIp" binding:"required"`
+	Port   int    `json:"port" binding:"required"`
+}
+
+// PluginUpgradeParam 插件滚动升级参数
+type PluginUpgradeParam struct {
+	Instances            []*PluginUpgradeTarget `json:"instances" binding:"required,min=1"` // 新版本实例部署位置
+	DrainSeconds         int                    `json:"drainSeconds"`                       // 切流量后旧实例保留秒数,期间新实例检查失败会回滚,默认30
+	HealthTimeoutSeconds int                    `json:"healthTimeoutSeconds"`               // 等待新实例健康的超时秒数,默认300
+}
+
+// PluginPackageUpgrade 插件滚动升级纪录
+type PluginPackageUpgrade struct {
+	Id           string    `json:"id" xorm:"id"`                      // 唯一标识
+	PluginName   string    `json:"pluginName" xorm:"plugin_name"`     // 插件名
+	FromVersion  string    `json:"fromVersion" xorm:"from_version"`   // 升级前版本
+	ToPackageId  string    `json:"toPackageId" xorm:"to_package_id"`  // 目标插件包id
+	ToVersion    string    `json:"toVersion" xorm:"to_version"`       // 目标版本
+	Status       string    `json:"status" xorm:"status"`              // 状态->preparing | launching | verifying | draining | done | rolled_back | failed
+	OldInstances string    `json:"oldInstances" xorm:"old_instances"` // 旧实例id,逗号分隔
+	NewInstances string    `json:"newInstances" xorm:"new_instances"` // 新实例id,逗号分隔
+	ErrorMessage string    `json:"errorMessage" xorm:"error_message"` // 失败或回滚原因
+	CreatedBy    string    `json:"createdBy" xorm:"created_by"`       // 创建人
+	CreatedTime  time.Time `json:"createdTime" xorm:"created_time"`   // 创建时间
+	UpdatedTime  time.Time `json:"updatedTime" xorm:"updated_time"`   // 更新时间
+	ExecHost     string    `json:"-" xorm:"exec_host"`                // 执行的platform实例
+	RunningLock  string    `json:"-" xorm:"running_lock"`             // 进行中时为插件名,结束后清空
+}
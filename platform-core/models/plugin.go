@@ -74,6 +74,7 @@ type PluginPackageRuntimeResourcesDocker struct {
 	PortBindings    string `json:"portBindings" xorm:"port_bindings"`        // 端口信息
 	VolumeBindings  string `json:"volumeBindings" xorm:"volume_bindings"`    // 目录映射
 	EnvVariables    string `json:"envVariables" xorm:"env_variables"`        // 容器环境变量
+	HealthCheckPath string `json:"healthCheckPath" xorm:"health_check_path"` // 健康检查http路径
 }
 
 type PluginPackageRuntimeResourcesMysql struct {
@@ -238,12 +239,13 @@ type RegisterXML struct {
 	ResourceDependencies struct {
 		Text   string `xml:",chardata"`
 		Docker struct {
-			Text           string `xml:",chardata"`
-			ImageName      string `xml:"imageName,attr"`
-			ContainerName  string `xml:"containerName,attr"`
-			PortBindings   string `xml:"portBindings,attr"`
-			VolumeBindings string `xml:"volumeBindings,attr"`
-			EnvVariables   string `xml:"envVariables,attr"`
+			Text            string `xml:",chardata"`
+			ImageName       string `xml:"imageName,attr"`
+			ContainerName   string `xml:"containerName,attr"`
+			PortBindings    string `xml:"portBindings,attr"`
+			VolumeBindings  string `xml:"volumeBindings,attr"`
+			EnvVariables    string `xml:"envVariables,attr"`
+			HealthCheckPath string `xml:"healthCheckPath,attr"`
 		} `xml:"docker"`
 		Mysql struct {
 			Text            string `xml:",chardata"`
@@ -692,6 +694,7 @@ type ResourceItemProperties struct {
 	ImageName      string `json:"imageName"`
 	PortBindings   string `json:"portBindings"`
 	EnvVariables   string `json:"envVariables"`
+	AllocatePort   int    `json:"allocatePort,omitempty"` // 启动时分配的端口,kubernetes上实例纪录的是Service端口
 }
 
 type UpdatePluginCfgRolesReqParam struct {
@@ -38,8 +38,26 @@ const (
 	ProcDefNodeTypeData         ProcDefNodeType = "data"         //数据节点
 	ProcDefNodeTypeDate         ProcDefNodeType = "date"         //时间节点
 	ProcDefNodeTypeTimeInterval ProcDefNodeType = "timeInterval" //时间间隔
+	ProcDefNodeTypeSubProcess   ProcDefNodeType = "subProcess"   //子编排
+	ProcDefNodeTypeLoop         ProcDefNodeType = "loop"         //循环
+	ProcDefNodeTypeApproval     ProcDefNodeType = "approval"     //多级审批
 )
 
+// 节点边界事件线类型
+const (
+	ProcDefLinkTypeTimeout = "timeout" //节点超时分支
+	ProcDefLinkTypeError   = "error"   //节点异常分支
+)
+
+// 循环节点线类型,循环体最后一个节点回到循环节点的线和循环结束后的出线
+const (
+	ProcDefLinkTypeLoopBack = "loopBack" //循环回线
+	ProcDefLinkTypeLoopExit = "loopExit" //循环结束出线
+)
+
+// ProcDefLinkTypeReject 审批节点驳回分支
+const ProcDefLinkTypeReject = "reject"
+
 type ProcDef struct {
 	Id            string    `json:"id" xorm:"id"`                        // 唯一标识
 	Key           string    `json:"key" xorm:"key"`                      // 编排key
@@ -51,6 +69,7 @@ type ProcDef struct {
 	ForPlugin     string    `json:"forPlugin" xorm:"for_plugin"`         // 授权插件
 	Scene         string    `json:"scene" xorm:"scene"`                  // 使用场景
 	ConflictCheck bool      `json:"conflictCheck" xorm:"conflict_check"` // 冲突检测
+	SlaMinutes    int       `json:"slaMinutes" xorm:"sla_minutes"`       // 编排实例时限分钟,0不限制
 	CreatedBy     string    `json:"createdBy" xorm:"created_by"`         // 创建人
 	CreatedTime   time.Time `json:"createdTime" xorm:"created_time"`     // 创建时间
 	UpdatedBy     string    `json:"updatedBy" xorm:"updated_by"`         // 更新人
@@ -76,6 +95,13 @@ type ProcDefNode struct {
 	TimeConfig        string    `json:"timeConfig" xorm:"time_config"`                // 节点配置
 	OrderedNo         int       `json:"orderedNo" xorm:"ordered_no"`                  // 节点顺序
 	UiStyle           string    `json:"uiStyle" xorm:"ui_style"`                      // 前端样式
+	SubProcDefId      string    `json:"subProcDefId" xorm:"sub_proc_def_id"`          // 子编排定义id
+	MultiInstance     string    `json:"multiInstance" xorm:"multi_instance"`          // 多实例执行配置
+	RetryPolicy       string    `json:"retryPolicy" xorm:"retry_policy"`              // 自动重试策略
+	LoopConfig        string    `json:"loopConfig" xorm:"loop_config"`                // 循环配置
+	HumanTaskConfig   string    `json:"humanTaskConfig" xorm:"human_task_config"`     // 人工任务配置
+	ApprovalConfig    string    `json:"approvalConfig" xorm:"approval_config"`        // 多级审批配置
+	ExpectedMinutes   int       `json:"expectedMinutes" xorm:"expected_minutes"`      // 节点预期执行分钟,0不限制
 	CreatedBy         string    `json:"createdBy" xorm:"created_by"`                  // 创建人
 	CreatedTime       time.Time `json:"createdTime" xorm:"created_time"`              // 创建时间
 	UpdatedBy         string    `json:"updatedBy" xorm:"updated_by"`                  // 更新人
@@ -96,13 +122,16 @@ type ProcDefNodeParam struct {
 }
 
 type ProcDefNodeLink struct {
-	Id        string `json:"id" xorm:"id"`                 // 唯一标识(source__target)
-	ProcDefId string `json:"procDefId" xorm:"proc_def_id"` // 编排id
-	LinkId    string `json:"LinkId" xorm:"link_id"`        // 前端线id
-	Source    string `json:"source" xorm:"source"`         // 源节点
-	Target    string `json:"target" xorm:"target"`         // 目标节点
-	Name      string `json:"name" xorm:"name"`             // 连接名称
-	UiStyle   string `json:"uiStyle" xorm:"ui_style"`      // 前端样式
+	Id        string `json:"id" xorm:"id"`                    // 唯一标识(source__target)
+	ProcDefId string `json:"procDefId" xorm:"proc_def_id"`    // 编排id
+	LinkId    string `json:"LinkId" xorm:"link_id"`           // 前端线id
+	Source    string `json:"source" xorm:"source"`            // 源节点
+	Target    string `json:"target" xorm:"target"`            // 目标节点
+	Name      string `json:"name" xorm:"name"`                // 连接名称
+	UiStyle   string `json:"uiStyle" xorm:"ui_style"`         // 前端样式
+	LinkType  string `json:"linkType" xorm:"link_type"`       // 线类型->空(普通) | timeout(超时分支) | error(异常分支) | loopBack(循环回线) | loopExit(循环结束出线)
+	Condition string `json:"condition" xorm:"condition_expr"` // 判断节点分支条件表达式
+	IsDefault bool   `json:"isDefault" xorm:"is_default"`     // 是否判断节点默认分支
 }
 
 type ProcDefPermission struct {
@@ -137,6 +166,7 @@ type ProcessDefinitionParam struct {
 	AuthPlugins      []string         `json:"authPlugins"`      // 授权插件列表
 	Tags             string           `json:"tags"`             // 标签
 	ConflictCheck    bool             `json:"conflictCheck"`    // 冲突检测
+	SlaMinutes       int              `json:"slaMinutes"`       // 编排实例时限分钟,0不限制
 	RootEntity       string           `json:"rootEntity"`       // 根节点
 	PermissionToRole PermissionToRole `json:"permissionToRole"` // 角色
 }
@@ -170,6 +200,55 @@ type BatchUpdateProcDefPermission struct {
 	PermissionToRole PermissionToRole `json:"permissionToRole"` // 角色
 }
 
+// ProcDefVersionDto 编排版本
+type ProcDefVersionDto struct {
+	Id          string    `json:"id"`          // 编排id
+	Key         string    `json:"key"`         // 编排key
+	Name        string    `json:"name"`        // 编排名称
+	Version     string    `json:"version"`     // 版本
+	Status      string    `json:"status"`      // 状态
+	UpdatedBy   string    `json:"updatedBy"`   // 更新人
+	UpdatedTime time.Time `json:"updatedTime"` // 更新时间
+}
+
+// ProcDefDiffDto 两个编排版本的差异
+type ProcDefDiffDto struct {
+	SourceProcDefId string                `json:"sourceProcDefId"` // 对比基准编排id
+	SourceVersion   string                `json:"sourceVersion"`   // 对比基准版本
+	TargetProcDefId string                `json:"targetProcDefId"` // 对比目标编排id
+	TargetVersion   string                `json:"targetVersion"`   // 对比目标版本
+	ProcDef         []*ProcDefFieldDiff   `json:"procDef"`         // 编排基础属性差异
+	Nodes           []*ProcDefItemDiffDto `json:"nodes"`           // 节点差异(包含节点参数和定位规则)
+	Links           []*ProcDefItemDiffDto `json:"links"`           // 线差异
+	Permissions     []*ProcDefItemDiffDto `json:"permissions"`     // 权限差异
+}
+
+type ProcDefItemDiffDto struct {
+	Id         string              `json:"id"`         // 前端节点id|前端线id|角色:权限
+	Name       string              `json:"name"`       // 名称
+	ChangeType string              `json:"changeType"` // 变化类型->add(新增) | delete(删除) | modify(修改)
+	Fields     []*ProcDefFieldDiff `json:"fields"`     // 变化的属性
+}
+
+type ProcDefFieldDiff struct {
+	Field  string `json:"field"`  // 属性
+	Source string `json:"source"` // 基准版本的值
+	Target string `json:"target"` // 目标版本的值
+}
+
+type ProcDefValidateResult struct {
+	Pass     bool                   `json:"pass"`     // 没有错误时为true
+	Errors   []*ProcDefValidateItem `json:"errors"`   // 错误,有错误时不能发布
+	Warnings []*ProcDefValidateItem `json:"warnings"` // 警告,仅提示
+}
+
+type ProcDefValidateItem struct {
+	Rule     string `json:"rule"`     // 校验规则
+	NodeId   string `json:"nodeId"`   // 前端节点id
+	NodeName string `json:"nodeName"` // 节点名称
+	Message  string `json:"message"`  // 描述
+}
+
 type ProcDefCondition struct {
 	Key     string `json:"Key"`     // key
 	Name    string `json:"name"`    // 编排名称
@@ -225,6 +304,13 @@ type ProcDefNodeCustomAttrs struct {
 	ContextParamNodes []string            `json:"contextParamNodes"` // 上下文参数节点
 	TimeConfig        interface{}         `json:"timeConfig"`        // 节点配置
 	OrderedNo         int                 `json:"orderedNo"`         // 节点顺序
+	SubProcDefId      string              `json:"subProcDefId"`      // 子编排定义id
+	MultiInstance     *MultiInstanceDto   `json:"multiInstance"`     // 多实例执行配置
+	RetryPolicy       *RetryPolicyDto     `json:"retryPolicy"`       // 自动重试策略
+	LoopConfig        *LoopConfigDto      `json:"loopConfig"`        // 循环配置
+	HumanTaskConfig   *HumanTaskConfigDto `json:"humanTaskConfig"`   // 人工任务配置
+	ApprovalConfig    *ApprovalConfigDto  `json:"approvalConfig"`    // 多级审批配置
+	ExpectedMinutes   int                 `json:"expectedMinutes"`   // 节点预期执行分钟,0不限制
 	CreatedBy         string              `json:"createdBy" `        // 创建人
 	CreatedTime       string              `json:"createdTime" `      // 创建时间
 	UpdatedBy         string              `json:"updatedBy" `        // 更新人
@@ -248,6 +334,13 @@ type ProcDefNodeCustomAttrsDto struct {
 	ContextParamNodes []string            `json:"contextParamNodes"` // 上下文参数节点
 	TimeConfig        interface{}         `json:"timeConfig"`        // 节点配置
 	OrderedNo         int                 `json:"orderedNo"`         // 节点顺序
+	SubProcDefId      string              `json:"subProcDefId"`      // 子编排定义id
+	MultiInstance     *MultiInstanceDto   `json:"multiInstance"`     // 多实例执行配置
+	RetryPolicy       *RetryPolicyDto     `json:"retryPolicy"`       // 自动重试策略
+	LoopConfig        *LoopConfigDto      `json:"loopConfig"`        // 循环配置
+	HumanTaskConfig   *HumanTaskConfigDto `json:"humanTaskConfig"`   // 人工任务配置
+	ApprovalConfig    *ApprovalConfigDto  `json:"approvalConfig"`    // 多级审批配置
+	ExpectedMinutes   int                 `json:"expectedMinutes"`   // 节点预期执行分钟,0不限制
 	CreatedBy         string              `json:"createdBy" `        // 创建人
 	CreatedTime       string              `json:"createdTime" `      // 创建时间
 	UpdatedBy         string              `json:"updatedBy" `        // 更新人
@@ -293,10 +386,13 @@ type ImportResultItemDto struct {
 }
 
 type ProcDefNodeLinkCustomAttrs struct {
-	Id     string `json:"id"`     // Id
-	Name   string `json:"name"`   // 线名称
-	Source string `json:"source"` // 源
-	Target string `json:"target"` // 目标
+	Id        string `json:"id"`        // Id
+	Name      string `json:"name"`      // 线名称
+	Source    string `json:"source"`    // 源
+	Target    string `json:"target"`    // 目标
+	LinkType  string `json:"linkType"`  // 线类型
+	Condition string `json:"condition"` // 判断节点分支条件表达式
+	IsDefault bool   `json:"isDefault"` // 是否默认分支
 }
 
 type ProcDefDto struct {
@@ -310,6 +406,7 @@ type ProcDefDto struct {
 	AuthPlugins      []string `json:"authPlugins"`      // 授权插件
 	Scene            string   `json:"scene"`            // 使用场景
 	ConflictCheck    bool     `json:"conflictCheck"`    // 冲突检测
+	SlaMinutes       int      `json:"slaMinutes"`       // 编排实例时限分钟,0不限制
 	CreatedBy        string   `json:"createdBy"`        // 创建人
 	CreatedTime      string   `json:"createdTime"`      // 创建时间
 	UpdatedBy        string   `json:"updatedBy"`        // 更新人
@@ -328,6 +425,31 @@ type TimeConfigDto struct {
 	Unit     string `json:"unit"`
 }
 
+// MultiInstanceDto 自动节点多实例执行配置,按数据逐条调用插件
+type MultiInstanceDto struct {
+	Enable      bool   `json:"enable"`      // 是否开启
+	Concurrency int    `json:"concurrency"` // 最大并发数
+	Threshold   string `json:"threshold"`   // 成功阈值->all(全部成功) | any(任一成功) | N%(成功比例)
+}
+
+// DefaultRetryMaxBackoffSeconds 自动重试间隔默认上限秒数
+const DefaultRetryMaxBackoffSeconds = 600
+
+// RetryPolicyDto 自动节点和数据节点失败自动重试策略
+type RetryPolicyDto struct {
+	MaxAttempts    int      `json:"maxAttempts"`    // 最大执行次数(包含第一次)
+	InitialBackoff int      `json:"initialBackoff"` // 首次重试间隔秒数
+	Multiplier     float64  `json:"multiplier"`     // 重试间隔倍数
+	MaxBackoff     int      `json:"maxBackoff"`     // 重试间隔上限秒数,默认600
+	RetryableCodes []string `json:"retryableCodes"` // 可重试的错误码或错误关键字,为空时所有错误都重试
+}
+
+// LoopConfigDto 循环节点配置,循环体执行完后按退出条件判断是否结束
+type LoopConfigDto struct {
+	MaxIterations int    `json:"maxIterations"` // 最大循环次数
+	ExitCondition string `json:"exitCondition"` // 退出条件表达式,变量同判断节点分支条件,另有 loop.iteration
+}
+
 type ProcDefQueryDto struct {
 	ManageRole        string        `json:"manageRole"`        //管理角色
 	ManageRoleDisplay string        `json:"manageRoleDisplay"` //管理角色-显示名
@@ -481,6 +603,7 @@ func ConvertProcDef2Dto(procDef *ProcDef) *ProcDefDto {
 		AuthPlugins:   authPlugins,
 		Scene:         procDef.Scene,
 		ConflictCheck: procDef.ConflictCheck,
+		SlaMinutes:    procDef.SlaMinutes,
 		CreatedBy:     procDef.CreatedBy,
 		CreatedTime:   procDef.CreatedTime.Format(DateTimeFormat),
 		UpdatedBy:     procDef.UpdatedBy,
@@ -512,6 +635,7 @@ func ConvertProcDefDto2Model(dto *ProcDefDto) *ProcDef {
 		ForPlugin:     authPlugins,
 		Scene:         dto.Scene,
 		ConflictCheck: dto.ConflictCheck,
+		SlaMinutes:    dto.SlaMinutes,
 		CreatedBy:     dto.CreatedBy,
 		CreatedTime:   createTime,
 		UpdatedBy:     dto.UpdatedBy,
@@ -558,6 +682,13 @@ func ConvertProcDefNodeResultDto2Model(dto *ProcDefNodeResultDto) (node *ProcDef
 			TimeConfig:        timeConfig,
 			OrderedNo:         attr.OrderedNo,
 			UiStyle:           uiStyle,
+			SubProcDefId:      attr.SubProcDefId,
+			MultiInstance:     ConvertMultiInstanceDto2String(attr.MultiInstance),
+			RetryPolicy:       ConvertRetryPolicyDto2String(attr.RetryPolicy),
+			LoopConfig:        ConvertLoopConfigDto2String(attr.LoopConfig),
+			HumanTaskConfig:   ConvertHumanTaskConfigDto2String(attr.HumanTaskConfig),
+			ApprovalConfig:    ConvertApprovalConfigDto2String(attr.ApprovalConfig),
+			ExpectedMinutes:   attr.ExpectedMinutes,
 			CreatedBy:         attr.CreatedBy,
 			CreatedTime:       createTime,
 			UpdatedBy:         attr.UpdatedBy,
@@ -602,6 +733,13 @@ func ConvertProcDefNode2Dto(procDefNode *ProcDefNode, list []*ProcDefNodeParam)
 			ContextParamNodes: contextParamNodes,
 			TimeConfig:        procDefNode.TimeConfig,
 			OrderedNo:         procDefNode.OrderedNo,
+			SubProcDefId:      procDefNode.SubProcDefId,
+			MultiInstance:     ConvertString2MultiInstanceDto(procDefNode.MultiInstance),
+			RetryPolicy:       ConvertString2RetryPolicyDto(procDefNode.RetryPolicy),
+			LoopConfig:        ConvertString2LoopConfigDto(procDefNode.LoopConfig),
+			HumanTaskConfig:   ConvertString2HumanTaskConfigDto(procDefNode.HumanTaskConfig),
+			ApprovalConfig:    ConvertString2ApprovalConfigDto(procDefNode.ApprovalConfig),
+			ExpectedMinutes:   procDefNode.ExpectedMinutes,
 			CreatedBy:         procDefNode.CreatedBy,
 			CreatedTime:       procDefNode.CreatedTime.Format(DateTimeFormat),
 			UpdatedBy:         procDefNode.UpdatedBy,
@@ -622,6 +760,9 @@ func ConvertParam2ProcDefNodeLink(param *ProcDefNodeLinkDto) *ProcDefNodeLink {
 		Target:    nodeLinkAttr.Target,
 		Name:      nodeLinkAttr.Name,
 		UiStyle:   string(byteArr),
+		LinkType:  nodeLinkAttr.LinkType,
+		Condition: nodeLinkAttr.Condition,
+		IsDefault: nodeLinkAttr.IsDefault,
 	}
 }
 
@@ -638,6 +779,9 @@ func CovertNodeLinkDto2Model(param *ProcDefNodeLinkDto) *ProcDefNodeLink {
 		Target:    nodeLinkAttr.Target,
 		Name:      nodeLinkAttr.Name,
 		UiStyle:   uiStyle,
+		LinkType:  nodeLinkAttr.LinkType,
+		Condition: nodeLinkAttr.Condition,
+		IsDefault: nodeLinkAttr.IsDefault,
 	}
 }
 
@@ -653,10 +797,13 @@ func ConvertProcDefNodeLink2Dto(nodeLink *ProcDefNodeLink, nodeList []*ProcDefNo
 	dto := &ProcDefNodeLinkDto{
 		ProcDefId: nodeLink.ProcDefId,
 		ProcDefNodeLinkCustomAttrs: &ProcDefNodeLinkCustomAttrs{
-			Id:     nodeLink.LinkId,
-			Name:   nodeLink.Name,
-			Source: source,
-			Target: target,
+			Id:        nodeLink.LinkId,
+			Name:      nodeLink.Name,
+			Source:    source,
+			Target:    target,
+			LinkType:  nodeLink.LinkType,
+			Condition: nodeLink.Condition,
+			IsDefault: nodeLink.IsDefault,
 		},
 		SelfAttrs: nodeLink.UiStyle,
 	}
@@ -704,6 +851,7 @@ func BuildProcDefDto(procDef *ProcDef, userRoles, manageRoles, userRolesDisplay,
 		AuthPlugins:      authPlugins,
 		Scene:            procDef.Scene,
 		ConflictCheck:    procDef.ConflictCheck,
+		SlaMinutes:       procDef.SlaMinutes,
 		CreatedBy:        procDef.CreatedBy,
 		CreatedTime:      procDef.CreatedTime.Format(DateTimeFormat),
 		UpdatedBy:        procDef.UpdatedBy,
@@ -742,6 +890,13 @@ func ConvertParam2ProcDefNode(user string, param ProcDefNodeRequestParam) *ProcD
 		TimeConfig:        string(byteArr2),
 		OrderedNo:         procDefNodeAttr.OrderedNo,
 		UiStyle:           string(byteArr),
+		SubProcDefId:      procDefNodeAttr.SubProcDefId,
+		MultiInstance:     ConvertMultiInstanceDto2String(procDefNodeAttr.MultiInstance),
+		RetryPolicy:       ConvertRetryPolicyDto2String(procDefNodeAttr.RetryPolicy),
+		LoopConfig:        ConvertLoopConfigDto2String(procDefNodeAttr.LoopConfig),
+		HumanTaskConfig:   ConvertHumanTaskConfigDto2String(procDefNodeAttr.HumanTaskConfig),
+		ApprovalConfig:    ConvertApprovalConfigDto2String(procDefNodeAttr.ApprovalConfig),
+		ExpectedMinutes:   procDefNodeAttr.ExpectedMinutes,
 		CreatedBy:         user,
 		CreatedTime:       now,
 		UpdatedBy:         user,
@@ -750,6 +905,179 @@ func ConvertParam2ProcDefNode(user string, param ProcDefNodeRequestParam) *ProcD
 	return node
 }
 
+func ConvertMultiInstanceDto2String(dto *MultiInstanceDto) string {
+	if dto == nil || !dto.Enable {
+		return ""
+	}
+	byteArr, _ := json.Marshal(dto)
+	return string(byteArr)
+}
+
+// parseThresholdPercent 解析N%格式的成功比例
+func (m *MultiInstanceDto) parseThresholdPercent() (percent float64, ok bool) {
+	if !strings.HasSuffix(m.Threshold, "%") {
+		return
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(m.Threshold, "%"), 64)
+	if err != nil || percent <= 0 || percent > 100 {
+		return
+	}
+	ok = true
+	return
+}
+
+func (m *MultiInstanceDto) ValidThreshold() bool {
+	if m.Threshold == "" || m.Threshold == "all" || m.Threshold == "any" {
+		return true
+	}
+	_, ok := m.parseThresholdPercent()
+	return ok
+}
+
+// ThresholdMatch 判断成功数是否达到阈值,阈值为空时默认全部成功
+func (m *MultiInstanceDto) ThresholdMatch(successNum, totalNum int) bool {
+	switch m.Threshold {
+	case "", "all":
+		return successNum == totalNum
+	case "any":
+		return successNum > 0 || totalNum == 0
+	}
+	percent, ok := m.parseThresholdPercent()
+	if !ok {
+		return successNum == totalNum
+	}
+	return float64(successNum)*100 >= percent*float64(totalNum)
+}
+
+func ConvertString2MultiInstanceDto(multiInstance string) *MultiInstanceDto {
+	if multiInstance == "" {
+		return nil
+	}
+	dto := &MultiInstanceDto{}
+	if err := json.Unmarshal([]byte(multiInstance), dto); err != nil {
+		return nil
+	}
+	return dto
+}
+
+// Backoff 第attempt次失败后等待多久再重试,不超过重试间隔上限
+func (r *RetryPolicyDto) Backoff(attempt int) time.Duration {
+	maxBackoffSec := float64(r.MaxBackoff)
+	if maxBackoffSec <= 0 {
+		maxBackoffSec = DefaultRetryMaxBackoffSeconds
+	}
+	backoffSec := float64(r.InitialBackoff)
+	if backoffSec <= 0 {
+		backoffSec = 10
+	}
+	multiplier := r.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	for i := 1; i < attempt && backoffSec < maxBackoffSec; i++ {
+		backoffSec = backoffSec * multiplier
+	}
+	if backoffSec > maxBackoffSec {
+		backoffSec = maxBackoffSec
+	}
+	return time.Duration(backoffSec * float64(time.Second))
+}
+
+// Retryable 判断第attempt次失败后是否还能重试
+func (r *RetryPolicyDto) Retryable(attempt int, errorCode, errorMessage string) bool {
+	if attempt >= r.MaxAttempts {
+		return false
+	}
+	if len(r.RetryableCodes) == 0 {
+		return true
+	}
+	for _, code := range r.RetryableCodes {
+		if code == "" {
+			continue
+		}
+		if code == errorCode || strings.Contains(errorMessage, code) {
+			return true
+		}
+	}
+	return false
+}
+
+func ConvertRetryPolicyDto2String(dto *RetryPolicyDto) string {
+	if dto == nil || dto.MaxAttempts <= 1 {
+		return ""
+	}
+	byteArr, _ := json.Marshal(dto)
+	return string(byteArr)
+}
+
+func ConvertString2RetryPolicyDto(retryPolicy string) *RetryPolicyDto {
+	if retryPolicy == "" {
+		return nil
+	}
+	dto := &RetryPolicyDto{}
+	if err := json.Unmarshal([]byte(retryPolicy), dto); err != nil {
+		return nil
+	}
+	return dto
+}
+
+func ConvertLoopConfigDto2String(dto *LoopConfigDto) string {
+	if dto == nil {
+		return ""
+	}
+	byteArr, _ := json.Marshal(dto)
+	return string(byteArr)
+}
+
+func ConvertString2LoopConfigDto(loopConfig string) *LoopConfigDto {
+	if loopConfig == "" {
+		return nil
+	}
+	dto := &LoopConfigDto{}
+	if err := json.Unmarshal([]byte(loopConfig), dto); err != nil {
+		return nil
+	}
+	return dto
+}
+
+func ConvertHumanTaskConfigDto2String(dto *HumanTaskConfigDto) string {
+	if dto == nil {
+		return ""
+	}
+	byteArr, _ := json.Marshal(dto)
+	return string(byteArr)
+}
+
+func ConvertString2HumanTaskConfigDto(humanTaskConfig string) *HumanTaskConfigDto {
+	if humanTaskConfig == "" {
+		return nil
+	}
+	dto := &HumanTaskConfigDto{}
+	if err := json.Unmarshal([]byte(humanTaskConfig), dto); err != nil {
+		return nil
+	}
+	return dto
+}
+
+func ConvertApprovalConfigDto2String(dto *ApprovalConfigDto) string {
+	if dto == nil {
+		return ""
+	}
+	byteArr, _ := json.Marshal(dto)
+	return string(byteArr)
+}
+
+func ConvertString2ApprovalConfigDto(approvalConfig string) *ApprovalConfigDto {
+	if approvalConfig == "" {
+		return nil
+	}
+	dto := &ApprovalConfigDto{}
+	if err := json.Unmarshal([]byte(approvalConfig), dto); err != nil {
+		return nil
+	}
+	return dto
+}
+
 func GenNodeId(nodeType string) string {
 	nodeTypeShort := nodeType
 	if len(nodeTypeShort) > 4 {
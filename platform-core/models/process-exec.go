@@ -170,6 +170,38 @@ type ProcInsStartParam struct {
 	ProcDefId         string                `json:"procDefId"`
 	ProcessSessionId  string                `json:"processSessionId"`
 	TaskNodeBinds     []*TaskNodeBindingObj `json:"taskNodeBinds"`
+	ParentInsId       string                `json:"-"`        // 父编排实例id,子编排节点启动时使用
+	ParentInsNodeId   string                `json:"-"`        // 父编排实例节点id
+	Simulate          bool                  `json:"simulate"` // 试运行,只生成各节点的执行报告,不调插件也不落库
+}
+
+type ProcSimulationReport struct {
+	ProcDefId        string                `json:"procDefId"`
+	ProcDefName      string                `json:"procDefName"`
+	ProcessSessionId string                `json:"processSessionId"`
+	EntityDataId     string                `json:"entityDataId"`
+	EntityDataName   string                `json:"entityDataName"`
+	Nodes            []*ProcSimulationNode `json:"nodes"`
+}
+
+type ProcSimulationNode struct {
+	NodeId      string                  `json:"nodeId"`      // 前端节点id
+	Name        string                  `json:"name"`        // 节点名称
+	NodeType    string                  `json:"nodeType"`    // 节点类型
+	OrderedNo   int                     `json:"orderedNo"`   // 节点顺序
+	ServiceName string                  `json:"serviceName"` // 插件服务
+	RiskCheck   bool                    `json:"riskCheck"`   // 是否高危检测,试运行不做检测
+	Status      string                  `json:"status"`      // simulated(已模拟) | skipped(无数据空跑) | error(参数解析失败)
+	Message     string                  `json:"message"`     // 说明
+	Targets     []*ProcSimulationTarget `json:"targets"`     // 执行目标数据
+}
+
+type ProcSimulationTarget struct {
+	EntityTypeId   string                 `json:"entityTypeId"`
+	EntityDataId   string                 `json:"entityDataId"`
+	EntityDataName string                 `json:"entityDataName"`
+	Inputs         map[string]interface{} `json:"inputs"`  // 解析后的入参
+	Outputs        map[string]interface{} `json:"outputs"` // 模拟的插件出参
 }
 
 type ProcInsDetail struct {
@@ -184,25 +216,39 @@ type ProcInsDetail struct {
 	Status            string               `json:"status"`
 	Operator          string               `json:"operator"`
 	CreatedTime       string               `json:"createdTime"`
+	ParentProcInstId  string               `json:"parentProcInstId"`
+	ParentNodeInstId  string               `json:"parentNodeInstId"`
 	TaskNodeInstances []*ProcInsNodeDetail `json:"taskNodeInstances"`
 }
 
 type ProcInsNodeDetail struct {
-	Id                string   `json:"id"`
-	NodeId            string   `json:"nodeId"`
-	NodeName          string   `json:"nodeName"`
-	NodeDefId         string   `json:"nodeDefId"`
-	NodeType          string   `json:"nodeType"`
-	Description       string   `json:"description"`
-	OrderedNo         string   `json:"orderedNo"`
-	ProcDefId         string   `json:"procDefId"`
-	ProcDefKey        string   `json:"procDefKey"`
-	ProcInstId        string   `json:"procInstId"`
-	ProcInstKey       string   `json:"procInstKey"`
-	RoutineExpression string   `json:"routineExpression"`
-	Status            string   `json:"status"`
-	PreviousNodeIds   []string `json:"previousNodeIds"`
-	SucceedingNodeIds []string `json:"succeedingNodeIds"`
+	Id                string                 `json:"id"`
+	NodeId            string                 `json:"nodeId"`
+	NodeName          string                 `json:"nodeName"`
+	NodeDefId         string                 `json:"nodeDefId"`
+	NodeType          string                 `json:"nodeType"`
+	Description       string                 `json:"description"`
+	OrderedNo         string                 `json:"orderedNo"`
+	ProcDefId         string                 `json:"procDefId"`
+	ProcDefKey        string                 `json:"procDefKey"`
+	ProcInstId        string                 `json:"procInstId"`
+	ProcInstKey       string                 `json:"procInstKey"`
+	RoutineExpression string                 `json:"routineExpression"`
+	Status            string                 `json:"status"`
+	PreviousNodeIds   []string               `json:"previousNodeIds"`
+	SucceedingNodeIds []string               `json:"succeedingNodeIds"`
+	SubProcInstIds    []string               `json:"subProcInstIds"`
+	ApprovalVotes     []*ProcRunApprovalVote `json:"approvalVotes,omitempty"` // 审批节点投票纪录
+}
+
+type SubProcInsObj struct {
+	ProcInsId    string           `json:"procInsId"`
+	EntityDataId string           `json:"entityDataId"`
+	Status       string           `json:"status"`
+	ErrorMessage string           `json:"errorMessage"`
+	WorkflowRow  *ProcRunWorkflow `json:"-"`
+	WorkNodes    []*ProcRunNode   `json:"-"`
+	WorkLinks    []*ProcRunLink   `json:"-"`
 }
 
 type ProcCallPluginServiceFuncParam struct {
@@ -220,6 +266,7 @@ type ProcCallPluginServiceFuncParam struct {
 	ProcDefNode       *ProcDefNode
 	DataBinding       []*ProcDataBinding
 	ProcIns           *ProcIns
+	MultiInstance     bool
 }
 
 type ProcNodeContextReq struct {
@@ -315,9 +362,10 @@ type StartInstanceResultData struct {
 }
 
 type ProcInsOperationParam struct {
-	Act        string `json:"act"`
+	Act        string `json:"act"` // skip(跳过节点) | replay(从节点重放)
 	ProcInstId string `json:"procInstId"`
 	NodeInstId string `json:"nodeInstId"`
+	Message    string `json:"message"` // 重放原因
 }
 
 func DistinctStringList(input, excludeList []string) (output []string) {
@@ -58,19 +58,21 @@ type ProcDataBinding struct {
 }
 
 type ProcIns struct {
-	Id             string    `json:"id" xorm:"id"`                           // 唯一标识
-	ProcDefId      string    `json:"procDefId" xorm:"proc_def_id"`           // 编排定义id
-	ProcDefKey     string    `json:"procDefKey" xorm:"proc_def_key"`         // 编排定义key
-	ProcDefName    string    `json:"procDefName" xorm:"proc_def_name"`       // 编排定义名称
-	Status         string    `json:"status" xorm:"status"`                   // 状态->ready(初始化
-	EntityDataId   string    `json:"entityDataId" xorm:"entity_data_id"`     // 根数据id
-	EntityTypeId   string    `json:"entityTypeId" xorm:"entity_type_id"`     // 根数据类型
-	EntityDataName string    `json:"entityDataName" xorm:"entity_data_name"` // 根数据名称
-	ProcSessionId  string    `json:"procSessionId" xorm:"proc_session_id"`   // 试算session
-	CreatedBy      string    `json:"createdBy" xorm:"created_by"`            // 创建人
-	CreatedTime    time.Time `json:"createdTime" xorm:"created_time"`        // 创建时间
-	UpdatedBy      string    `json:"updatedBy" xorm:"updated_by"`            // 更新人
-	UpdatedTime    time.Time `json:"updatedTime" xorm:"updated_time"`        // 更新时间
+	Id              string    `json:"id" xorm:"id"`                              // 唯一标识
+	ProcDefId       string    `json:"procDefId" xorm:"proc_def_id"`              // 编排定义id
+	ProcDefKey      string    `json:"procDefKey" xorm:"proc_def_key"`            // 编排定义key
+	ProcDefName     string    `json:"procDefName" xorm:"proc_def_name"`          // 编排定义名称
+	Status          string    `json:"status" xorm:"status"`                      // 状态->ready(初始化
+	EntityDataId    string    `json:"entityDataId" xorm:"entity_data_id"`        // 根数据id
+	EntityTypeId    string    `json:"entityTypeId" xorm:"entity_type_id"`        // 根数据类型
+	EntityDataName  string    `json:"entityDataName" xorm:"entity_data_name"`    // 根数据名称
+	ProcSessionId   string    `json:"procSessionId" xorm:"proc_session_id"`      // 试算session
+	ParentInsId     string    `json:"parentInsId" xorm:"parent_ins_id"`          // 父编排实例id
+	ParentInsNodeId string    `json:"parentInsNodeId" xorm:"parent_ins_node_id"` // 父编排实例节点id
+	CreatedBy       string    `json:"createdBy" xorm:"created_by"`               // 创建人
+	CreatedTime     time.Time `json:"createdTime" xorm:"created_time"`           // 创建时间
+	UpdatedBy       string    `json:"updatedBy" xorm:"updated_by"`               // 更新人
+	UpdatedTime     time.Time `json:"updatedTime" xorm:"updated_time"`           // 更新时间
 }
 
 type ProcInsNode struct {
@@ -98,6 +100,8 @@ type ProcInsNodeReq struct {
 	ErrorMsg        string                 `json:"errorMsg" xorm:"error_msg"`                // 错误信息
 	WithContextData bool                   `json:"withContextData" xorm:"with_context_data"` // 是否有上下文数据
 	ReqDataAmount   int                    `json:"reqDataAmount" xorm:"req_data_amount"`     // 有多少组数据
+	EntityDataId    string                 `json:"entityDataId" xorm:"entity_data_id"`       // 多实例执行时对应的数据id
+	RetryAttempt    int                    `json:"retryAttempt" xorm:"retry_attempt"`        // 自动重试第几次执行
 	CreatedTime     time.Time              `json:"createdTime" xorm:"created_time"`          // 创建时间
 	UpdatedTime     time.Time              `json:"updatedTime" xorm:"updated_time"`          // 更新时间
 	Params          []*ProcInsNodeReqParam `json:"params" xorm:"-"`
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+const (
+	ProcSearchSourceEntity = "entity" // 编排数据的id和名称
+	ProcSearchSourceError  = "error"  // 节点和插件请求的报错信息
+	ProcSearchSourceInput  = "input"  // 插件请求参数
+	ProcSearchSourceOutput = "output" // 插件返回参数
+
+	ProcSearchMaxKeywordTokens = 10
+	ProcSearchMaxDocTokens     = 200
+	ProcSearchMaxNodeTokens    = 5000
+	ProcSearchSnippetLength    = 200
+)
+
+// ProcInsSearchIndex 编排执行纪录全文检索倒排索引
+type ProcInsSearchIndex struct {
+	Id            int64     `json:"id" xorm:"id"`                          // 自增id
+	Token         string    `json:"token" xorm:"token"`                    // 分词
+	DocKey        string    `json:"docKey" xorm:"doc_key"`                 // 索引文档->ins_编排实例id | node_编排节点id,重建索引时按文档删除
+	ProcInsId     string    `json:"procInsId" xorm:"proc_ins_id"`          // 编排实例id
+	ProcInsNodeId string    `json:"procInsNodeId" xorm:"proc_ins_node_id"` // 编排节点id,编排数据为空
+	Source        string    `json:"source" xorm:"source"`                  // 来源->entity | error | input | output
+	Field         string    `json:"field" xorm:"field"`                    // 数据类型或参数名
+	Snippet       string    `json:"snippet" xorm:"snippet"`                // 原文片段
+	CreatedTime   time.Time `json:"createdTime" xorm:"created_time"`       // 创建时间
+}
+
+// ProcSearchDoc 待建索引的一段原文
+type ProcSearchDoc struct {
+	Source string
+	Field  string
+	Text   string
+}
+
+type ProcInsSearchParam struct {
+	Keyword   string    `json:"keyword" binding:"required"` // 检索关键字,多个词时要求在同一段原文里都出现
+	ProcDefId string    `json:"procDefId"`
+	Status    string    `json:"status"`
+	StartTime string    `json:"startTime"`
+	EndTime   string    `json:"endTime"`
+	Pageable  *PageInfo `json:"pageable"`
+}
+
+type ProcInsSearchResponse struct {
+	PageInfo *PageInfo              `json:"pageInfo"`
+	Contents []*ProcInsSearchResult `json:"contents"`
+}
+
+type ProcInsSearchResult struct {
+	ProcInsDetail
+	Matches []*ProcInsSearchMatch `json:"matches"` // 命中的编排数据和节点
+}
+
+type ProcInsSearchMatch struct {
+	ProcInsId     string `json:"-" xorm:"proc_ins_id"`
+	ProcInsNodeId string `json:"procInsNodeId" xorm:"proc_ins_node_id"`
+	NodeName      string `json:"nodeName" xorm:"node_name"`
+	Source        string `json:"source" xorm:"source"`
+	Field         string `json:"field" xorm:"field"`
+	Snippet       string `json:"snippet" xorm:"snippet"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+const (
+	SlaBreachTypeInstance = "instance"
+	SlaBreachTypeNode     = "node"
+
+	SlaNotifyStatusDone = "done"
+	SlaNotifyStatusFail = "fail"
+)
+
+// ProcInsSlaBreach 编排实例或节点超时纪录
+type ProcInsSlaBreach struct {
+	Id              int       `json:"id" xorm:"id"`                            // 自增id
+	BreachType      string    `json:"breachType" xorm:"breach_type"`           // 超时类型->instance(编排实例) | node(节点)
+	ProcDefId       string    `json:"procDefId" xorm:"proc_def_id"`            // 编排定义id
+	ProcInsId       string    `json:"procInsId" xorm:"proc_ins_id"`            // 编排实例id
+	ProcInsNodeId   string    `json:"procInsNodeId" xorm:"proc_ins_node_id"`   // 编排节点id,实例超时为空
+	NodeName        string    `json:"nodeName" xorm:"node_name"`               // 节点名称
+	ExpectedMinutes int       `json:"expectedMinutes" xorm:"expected_minutes"` // 时限分钟
+	StartTime       time.Time `json:"startTime" xorm:"start_time"`             // 开始时间
+	BreachTime      time.Time `json:"breachTime" xorm:"breach_time"`           // 发现超时的时间
+	NotifyStatus    string    `json:"notifyStatus" xorm:"notify_status"`       // 通知状态->done(已发送) | fail(发送失败)
+	NotifyMessage   string    `json:"notifyMessage" xorm:"notify_message"`     // 通知结果
+}
+
+// ProcSlaBreachQueryObj 超时检查结果,带上通知需要的编排信息
+type ProcSlaBreachQueryObj struct {
+	ProcInsSlaBreach `xorm:"extends"`
+	ProcDefName      string `json:"procDefName" xorm:"proc_def_name"`       // 编排名称
+	EntityDataName   string `json:"entityDataName" xorm:"entity_data_name"` // 根数据名称
+	ProcInsCreatedBy string `json:"procInsCreatedBy" xorm:"proc_ins_created_by"`
+}
+
+// ProcDefSlaStatistics 编排在时间范围内的时限达标统计
+type ProcDefSlaStatistics struct {
+	ProcDefId          string                      `json:"procDefId"`
+	ProcDefName        string                      `json:"procDefName"`
+	SlaMinutes         int                         `json:"slaMinutes"`         // 编排实例时限分钟
+	StartTime          string                      `json:"startTime"`          // 统计开始时间
+	EndTime            string                      `json:"endTime"`            // 统计结束时间
+	TotalInstances     int                         `json:"totalInstances"`     // 实例总数
+	FinishedInstances  int                         `json:"finishedInstances"`  // 已结束实例数
+	EvaluatedInstances int                         `json:"evaluatedInstances"` // 参与达标率统计的实例数,已结束或运行中已超过时限
+	BreachedInstances  int                         `json:"breachedInstances"`  // 超时实例数
+	ComplianceRate     float64                     `json:"complianceRate"`     // 达标率百分比
+	AvgDurationMinutes float64                     `json:"avgDurationMinutes"` // 已结束实例平均耗时分钟
+	Nodes              []*ProcDefNodeSlaStatistics `json:"nodes"`              // 配置了预期时长的节点统计
+}
+
+// ProcNodeSlaQueryObj 节点达标统计用的任务节点运行纪录
+type ProcNodeSlaQueryObj struct {
+	ProcDefNodeId string    `xorm:"proc_def_node_id"`
+	ProcInsNodeId string    `xorm:"proc_ins_node_id"`
+	Status        string    `xorm:"status"`
+	StartTime     time.Time `xorm:"start_time"`
+	EndTime       time.Time `xorm:"end_time"`
+}
+
+type ProcDefNodeSlaStatistics struct {
+	NodeDefId       string  `json:"nodeDefId"`       // 编排节点定义id
+	NodeId          string  `json:"nodeId"`          // 前端节点id
+	NodeName        string  `json:"nodeName"`        // 节点名称
+	ExpectedMinutes int     `json:"expectedMinutes"` // 预期执行分钟
+	Executions      int     `json:"executions"`      // 参与统计的执行次数,已结束或运行中已超过预期时长
+	Breaches        int     `json:"breaches"`        // 超时次数
+	ComplianceRate  float64 `json:"complianceRate"`  // 达标率百分比
+}
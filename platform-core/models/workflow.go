@@ -17,6 +17,9 @@ const (
 	JobTimeType     = "timeInterval"
 	JobDateType     = "date"
 	JobDecisionType = "decision"
+	JobSubProcType  = "subProcess"
+	JobLoopType     = "loop"
+	JobApprovalType = "approval"
 
 	JobStatusReady   = "NotStarted"
 	JobStatusRunning = "InProgress"
@@ -41,21 +44,39 @@ type ProcRunWorkflow struct {
 }
 
 type ProcRunNode struct {
-	Id            string    `json:"id" xorm:"id"`                          // 唯一标识
-	WorkflowId    string    `json:"workflowId" xorm:"workflow_id"`         // 工作流id
+	Id             string    `json:"id" xorm:"id"`                           // 唯一标识
+	WorkflowId     string    `json:"workflowId" xorm:"workflow_id"`          // 工作流id
+	ProcInsNodeId  string    `json:"procInsNodeId" xorm:"proc_ins_node_id"`  // 编排节点id
+	Name           string    `json:"name" xorm:"name"`                       // 名称
+	JobType        string    `json:"jobType" xorm:"job_type"`                // 任务类型->start(开始) | auto(自动) | data(数据写入) | human(人工) | | fork(分流) | merge(聚合) | time(定时) | date(定期) | decision(判断) | end(结束) | break(异常结束)
+	Status         string    `json:"status" xorm:"status"`                   // 状态->ready(初始化) | running(运行中) | wait(等待or聚合) | fail(失败) | success(成功) | timeout(超时)
+	Input          string    `json:"input" xorm:"input"`                     // 输入
+	Output         string    `json:"output" xorm:"output"`                   // 输出
+	TmpData        string    `json:"tmpData" xorm:"tmp_data"`                // 临时数据
+	ErrorMessage   string    `json:"errorMessage" xorm:"error_message"`      // 错误信息
+	Timeout        int       `json:"timeout" xorm:"timeout"`                 // 超时时间
+	DecisionLinkId string    `json:"decisionLinkId" xorm:"decision_link_id"` // 判断节点按分支条件选中的线
+	CreatedTime    time.Time `json:"createdTime" xorm:"created_time"`        // 创建时间
+	UpdatedTime    time.Time `json:"updatedTime" xorm:"updated_time"`        // 更新时间
+	StartTime      time.Time `json:"startTime" xorm:"start_time"`            // 开始时间
+	EndTime        time.Time `json:"endTime" xorm:"end_time"`                // 结束时间
+}
+
+// ProcRunNodeIteration 循环体任务节点每轮执行结果
+type ProcRunNodeIteration struct {
+	Id            int       `json:"id" xorm:"id"`                          // 自增id
+	LoopNodeId    string    `json:"loopNodeId" xorm:"loop_node_id"`        // 循环任务节点id
+	Iteration     int       `json:"iteration" xorm:"iteration"`            // 第几轮
+	ProcRunNodeId string    `json:"procRunNodeId" xorm:"proc_run_node_id"` // 循环体任务节点id
 	ProcInsNodeId string    `json:"procInsNodeId" xorm:"proc_ins_node_id"` // 编排节点id
-	Name          string    `json:"name" xorm:"name"`                      // 名称
-	JobType       string    `json:"jobType" xorm:"job_type"`               // 任务类型->start(开始) | auto(自动) | data(数据写入) | human(人工) | | fork(分流) | merge(聚合) | time(定时) | date(定期) | decision(判断) | end(结束) | break(异常结束)
-	Status        string    `json:"status" xorm:"status"`                  // 状态->ready(初始化) | running(运行中) | wait(等待or聚合) | fail(失败) | success(成功) | timeout(超时)
+	NodeName      string    `json:"nodeName" xorm:"node_name"`             // 节点名称
+	Status        string    `json:"status" xorm:"status"`                  // 状态
 	Input         string    `json:"input" xorm:"input"`                    // 输入
 	Output        string    `json:"output" xorm:"output"`                  // 输出
-	TmpData       string    `json:"tmpData" xorm:"tmp_data"`               // 临时数据
 	ErrorMessage  string    `json:"errorMessage" xorm:"error_message"`     // 错误信息
-	Timeout       int       `json:"timeout" xorm:"timeout"`                // 超时时间
-	CreatedTime   time.Time `json:"createdTime" xorm:"created_time"`       // 创建时间
-	UpdatedTime   time.Time `json:"updatedTime" xorm:"updated_time"`       // 更新时间
 	StartTime     time.Time `json:"startTime" xorm:"start_time"`           // 开始时间
 	EndTime       time.Time `json:"endTime" xorm:"end_time"`               // 结束时间
+	CreatedTime   time.Time `json:"createdTime" xorm:"created_time"`       // 创建时间
 }
 
 type ProcRunLink struct {
@@ -65,6 +86,9 @@ type ProcRunLink struct {
 	Name          string `json:"name" xorm:"name"`                      // 名称
 	Source        string `json:"source" xorm:"source"`                  // 源
 	Target        string `json:"target" xorm:"target"`                  // 目标
+	LinkType      string `json:"linkType" xorm:"link_type"`             // 线类型->空(普通) | timeout(超时分支) | error(异常分支) | loopBack(循环回线) | loopExit(循环结束出线)
+	Condition     string `json:"condition" xorm:"condition_expr"`       // 判断节点分支条件表达式
+	IsDefault     bool   `json:"isDefault" xorm:"is_default"`           // 是否判断节点默认分支
 }
 
 type ProcRunWorkRecord struct {
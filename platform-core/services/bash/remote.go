@@ -23,7 +23,7 @@ func RemoteSSHCommand(targetIp, user, pwd, port, command string) (err error) {
 	}
 	_, err = exec.Command("/bin/bash", "-c", commandString).Output()
 	if err != nil {
-		err = fmt.Errorf("run remote ssh command to target %s fail,%s ", targetIp, err.Error())
+		err = fmt.Errorf("run remote ssh command to target %s fail,%w ", targetIp, err)
 		log.Logger.Debug("run remote ssh command fail", log.String("cmd", commandString), log.String("targetIp", targetIp))
 	}
 	return
@@ -37,7 +37,7 @@ func RemoteSSHCommandWithOutput(targetIp, user, pwd, port, command string) (stdo
 	}
 	stdout, err = exec.Command("/bin/bash", "-c", commandString).Output()
 	if err != nil {
-		err = fmt.Errorf("run remote ssh command to target %s fail,%s ", targetIp, err.Error())
+		err = fmt.Errorf("run remote ssh command to target %s fail,%w ", targetIp, err)
 		log.Logger.Debug("run remote ssh command fail", log.String("cmd", commandString), log.String("targetIp", targetIp))
 	}
 	return
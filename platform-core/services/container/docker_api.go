@@ -0,0 +1,300 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+// dockerApiDriver 通过tcp/tls直接调用目标机器的docker engine api
+type dockerApiDriver struct {
+	host    string
+	baseUrl string
+	client  *http.Client
+	config  *models.ContainerRuntimeConfig
+}
+
+// dockerContainerState docker inspect返回的State
+type dockerContainerState struct {
+	Status     string `json:"Status"`
+	Running    bool   `json:"Running"`
+	ExitCode   int    `json:"ExitCode"`
+	Error      string `json:"Error"`
+	StartedAt  string `json:"StartedAt"`
+	FinishedAt string `json:"FinishedAt"`
+}
+
+type dockerContainerInspect struct {
+	Id     string `json:"Id"`
+	Config struct {
+		Image string `json:"Image"`
+	} `json:"Config"`
+	State dockerContainerState `json:"State"`
+}
+
+type dockerPortBinding struct {
+	HostIp   string `json:"HostIp,omitempty"`
+	HostPort string `json:"HostPort"`
+}
+
+type dockerCreateParam struct {
+	Image        string              `json:"Image"`
+	Env          []string            `json:"Env,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	HostConfig   struct {
+		Binds        []string                        `json:"Binds,omitempty"`
+		PortBindings map[string][]*dockerPortBinding `json:"PortBindings,omitempty"`
+	} `json:"HostConfig"`
+}
+
+func newDockerApiDriver(server *models.ResourceServer, config *models.ContainerRuntimeConfig) (Driver, error) {
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	scheme := "http"
+	if config.TlsEnable {
+		scheme = "https"
+		tlsConfig, err := buildDockerTlsConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &dockerApiDriver{
+		host:    server.Host,
+		baseUrl: fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(server.Host, config.DockerApiPort)),
+		client:  &http.Client{Transport: transport},
+		config:  config,
+	}, nil
+}
+
+func buildDockerTlsConfig(config *models.ContainerRuntimeConfig) (tlsConfig *tls.Config, err error) {
+	tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TlsCaFile != "" {
+		caBytes, readErr := os.ReadFile(config.TlsCaFile)
+		if readErr != nil {
+			err = fmt.Errorf("read docker api tls ca file fail,%s ", readErr.Error())
+			return
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caBytes) {
+			err = fmt.Errorf("docker api tls ca file:%s illegal", config.TlsCaFile)
+			return
+		}
+		tlsConfig.RootCAs = certPool
+	}
+	if config.TlsCertFile != "" && config.TlsKeyFile != "" {
+		cert, loadErr := tls.LoadX509KeyPair(config.TlsCertFile, config.TlsKeyFile)
+		if loadErr != nil {
+			err = fmt.Errorf("load docker api tls client cert fail,%s ", loadErr.Error())
+			return
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+func (d *dockerApiDriver) LoadImage(ctx context.Context, imageFile, image string) (err error) {
+	file, openErr := os.Open(imageFile)
+	if openErr != nil {
+		return d.newError("load", image, 0, openErr.Error())
+	}
+	defer file.Close()
+	respBody, err := d.request(ctx, "load", image, http.MethodPost, "/images/load?quiet=1", file, "application/x-tar")
+	if err != nil {
+		return
+	}
+	// 导入失败时状态码也是200,报错在返回的json流里
+	decoder := json.NewDecoder(bytes.NewReader(respBody))
+	for decoder.More() {
+		var message struct {
+			Error string `json:"error"`
+		}
+		if decodeErr := decoder.Decode(&message); decodeErr != nil {
+			break
+		}
+		if message.Error != "" {
+			return d.newError("load", image, http.StatusOK, message.Error)
+		}
+	}
+	return
+}
+
+func (d *dockerApiDriver) Run(ctx context.Context, param *models.ContainerRunParam) (containerId string, err error) {
+	createParam := dockerCreateParam{Image: param.Image, Env: param.EnvVariables}
+	createParam.HostConfig.Binds = param.VolumeBindings
+	for _, portBind := range param.PortBindings {
+		hostIp, hostPort, containerPort, parseErr := parsePortBinding(portBind)
+		if parseErr != nil {
+			err = d.newError("run", param.Name, 0, parseErr.Error())
+			return
+		}
+		if createParam.ExposedPorts == nil {
+			createParam.ExposedPorts = make(map[string]struct{})
+			createParam.HostConfig.PortBindings = make(map[string][]*dockerPortBinding)
+		}
+		createParam.ExposedPorts[containerPort] = struct{}{}
+		createParam.HostConfig.PortBindings[containerPort] = append(createParam.HostConfig.PortBindings[containerPort], &dockerPortBinding{HostIp: hostIp, HostPort: hostPort})
+	}
+	paramBytes, _ := json.Marshal(&createParam)
+	respBody, reqErr := d.request(ctx, "run", param.Name, http.MethodPost, "/containers/create?name="+url.QueryEscape(param.Name), bytes.NewReader(paramBytes), "application/json")
+	if reqErr != nil {
+		err = reqErr
+		return
+	}
+	var createResult struct {
+		Id string `json:"Id"`
+	}
+	if err = json.Unmarshal(respBody, &createResult); err != nil {
+		err = d.newError("run", param.Name, http.StatusCreated, "decode create result fail,"+err.Error())
+		return
+	}
+	if _, err = d.request(ctx, "run", param.Name, http.MethodPost, "/containers/"+createResult.Id+"/start", nil, ""); err != nil {
+		return
+	}
+	containerId = createResult.Id
+	return
+}
+
+func (d *dockerApiDriver) Stop(ctx context.Context, name string) (err error) {
+	_, err = d.request(ctx, "stop", name, http.MethodPost, fmt.Sprintf("/containers/%s/stop?t=%d", url.PathEscape(name), d.config.StopTimeout), nil, "")
+	return
+}
+
+func (d *dockerApiDriver) Restart(ctx context.Context, name string) (err error) {
+	_, err = d.request(ctx, "restart", name, http.MethodPost, fmt.Sprintf("/containers/%s/restart?t=%d", url.PathEscape(name), d.config.StopTimeout), nil, "")
+	return
+}
+
+func (d *dockerApiDriver) Remove(ctx context.Context, name, image string) (err error) {
+	if _, err = d.request(ctx, "remove", name, http.MethodDelete, "/containers/"+url.PathEscape(name)+"?force=1", nil, ""); err != nil {
+		return
+	}
+	if image != "" {
+		_, err = d.request(ctx, "remove", image, http.MethodDelete, "/images/"+image+"?force=1", nil, "")
+	}
+	return
+}
+
+func (d *dockerApiDriver) Inspect(ctx context.Context, name string) (state *models.ContainerState, err error) {
+	respBody, reqErr := d.request(ctx, "inspect", name, http.MethodGet, "/containers/"+url.PathEscape(name)+"/json", nil, "")
+	if reqErr != nil {
+		if runtimeErr, ok := reqErr.(*Error); ok && runtimeErr.NotFound {
+			state = &models.ContainerState{Name: name, Status: models.ContainerStateMissing}
+			return
+		}
+		err = reqErr
+		return
+	}
+	var inspectResult dockerContainerInspect
+	if err = json.Unmarshal(respBody, &inspectResult); err != nil {
+		err = d.newError("inspect", name, http.StatusOK, "decode container state fail,"+err.Error())
+		return
+	}
+	state = inspectResult.State.toContainerState(inspectResult.Id, name, inspectResult.Config.Image)
+	return
+}
+
+func (d *dockerApiDriver) Logs(ctx context.Context, name string, tail int) (output string, err error) {
+	if tail <= 0 {
+		tail = defaultLogTail
+	}
+	respBody, reqErr := d.request(ctx, "logs", name, http.MethodGet, fmt.Sprintf("/containers/%s/logs?stdout=1&stderr=1&tail=%d", url.PathEscape(name), tail), nil, "")
+	if reqErr != nil {
+		err = reqErr
+		return
+	}
+	output = demuxDockerLogs(respBody)
+	return
+}
+
+func (d *dockerApiDriver) request(ctx context.Context, op, target, method, path string, body io.Reader, contentType string) (respBody []byte, err error) {
+	req, newErr := http.NewRequestWithContext(ctx, method, d.baseUrl+path, body)
+	if newErr != nil {
+		err = d.newError(op, target, 0, newErr.Error())
+		return
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, respErr := d.client.Do(req)
+	if respErr != nil {
+		err = d.newError(op, target, 0, respErr.Error())
+		return
+	}
+	defer resp.Body.Close()
+	respBody, _ = io.ReadAll(resp.Body)
+	// 304表示容器已经是启动或停止状态
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		var message struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(respBody, &message) != nil || message.Message == "" {
+			message.Message = string(respBody)
+		}
+		err = d.newError(op, target, resp.StatusCode, message.Message)
+	}
+	return
+}
+
+func (d *dockerApiDriver) newError(op, target string, code int, message string) *Error {
+	return &Error{Driver: models.ContainerDriverDockerApi, Op: op, Host: d.host, Target: target, Code: code, Message: message, NotFound: code == http.StatusNotFound}
+}
+
+func (s *dockerContainerState) toContainerState(id, name, image string) *models.ContainerState {
+	state := models.ContainerState{Id: id, Name: name, Image: image, Status: s.Status, Running: s.Running, ExitCode: s.ExitCode, Error: s.Error}
+	state.StartedAt, _ = time.Parse(time.RFC3339Nano, s.StartedAt)
+	state.FinishedAt, _ = time.Parse(time.RFC3339Nano, s.FinishedAt)
+	return &state
+}
+
+// parsePortBinding 解析端口映射,格式为[宿主ip:]宿主端口:容器端口[/协议]
+func parsePortBinding(portBind string) (hostIp, hostPort, containerPort string, err error) {
+	parts := strings.Split(portBind, ":")
+	switch len(parts) {
+	case 2:
+		hostPort, containerPort = parts[0], parts[1]
+	case 3:
+		hostIp, hostPort, containerPort = parts[0], parts[1], parts[2]
+	default:
+		err = fmt.Errorf("port binding:%s illegal", portBind)
+		return
+	}
+	if !strings.Contains(containerPort, "/") {
+		containerPort += "/tcp"
+	}
+	return
+}
+
+// demuxDockerLogs 非tty容器的日志每段有8字节头,第1字节是stdout/stderr标识,后4字节是长度
+func demuxDockerLogs(data []byte) string {
+	if len(data) < 8 || data[0] > 2 || data[1] != 0 || data[2] != 0 || data[3] != 0 {
+		return string(data)
+	}
+	var buf bytes.Buffer
+	for len(data) >= 8 {
+		frameLength := int(binary.BigEndian.Uint32(data[4:8]))
+		data = data[8:]
+		if frameLength > len(data) {
+			frameLength = len(data)
+		}
+		buf.Write(data[:frameLength])
+		data = data[frameLength:]
+	}
+	return buf.String()
+}
@@ -0,0 +1,201 @@
+package container
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+func newTestDockerApiDriver(t *testing.T, handler http.HandlerFunc) *dockerApiDriver {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &dockerApiDriver{host: "10.0.0.1", baseUrl: server.URL, client: server.Client(), config: &models.ContainerRuntimeConfig{StopTimeout: 5}}
+}
+
+func TestDockerApiRun(t *testing.T) {
+	var createParam dockerCreateParam
+	var requestPaths []string
+	driver := newTestDockerApiDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		requestPaths = append(requestPaths, r.Method+" "+r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/containers/create":
+			json.NewDecoder(r.Body).Decode(&createParam)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"c1"}`))
+		case "/containers/c1/start":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	containerId, err := driver.Run(context.Background(), &models.ContainerRunParam{
+		Name:           "app",
+		Image:          "app:v1",
+		PortBindings:   []string{"20001:8080", "127.0.0.1:20002:9090/udp"},
+		VolumeBindings: []string{"/data/app:/data"},
+		EnvVariables:   []string{"A=1"},
+	})
+	if err != nil || containerId != "c1" {
+		t.Fatalf("Run() = %q, %v", containerId, err)
+	}
+	wantPaths := []string{"POST /containers/create?name=app", "POST /containers/c1/start"}
+	if len(requestPaths) != len(wantPaths) || requestPaths[0] != wantPaths[0] || requestPaths[1] != wantPaths[1] {
+		t.Errorf("Run() requests = %v, want %v", requestPaths, wantPaths)
+	}
+	if bindings := createParam.HostConfig.PortBindings["8080/tcp"]; len(bindings) != 1 || bindings[0].HostPort != "20001" {
+		t.Errorf("Run() tcp port binding = %+v", bindings)
+	}
+	if bindings := createParam.HostConfig.PortBindings["9090/udp"]; len(bindings) != 1 || bindings[0].HostIp != "127.0.0.1" || bindings[0].HostPort != "20002" {
+		t.Errorf("Run() udp port binding = %+v", bindings)
+	}
+	if createParam.Image != "app:v1" || len(createParam.Env) != 1 || len(createParam.HostConfig.Binds) != 1 {
+		t.Errorf("Run() create param = %+v", createParam)
+	}
+}
+
+func TestDockerApiRunConflict(t *testing.T) {
+	driver := newTestDockerApiDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"message":"container name app is already in use"}`))
+	})
+	_, err := driver.Run(context.Background(), &models.ContainerRunParam{Name: "app", Image: "app:v1"})
+	runtimeErr, ok := err.(*Error)
+	if !ok || runtimeErr.Code != http.StatusConflict || runtimeErr.Op != "run" || runtimeErr.Message != "container name app is already in use" || runtimeErr.NotFound {
+		t.Errorf("Run() error = %#v", err)
+	}
+}
+
+func TestDockerApiInspect(t *testing.T) {
+	driver := newTestDockerApiDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/containers/app/json" {
+			w.Write([]byte(`{"Id":"c1","Config":{"Image":"app:v1"},"State":{"Status":"exited","Running":false,"ExitCode":137,"Error":"oom","StartedAt":"2024-01-02T03:04:05.123456789Z","FinishedAt":"2024-01-02T03:05:05Z"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"No such container"}`))
+	})
+	state, err := driver.Inspect(context.Background(), "app")
+	if err != nil {
+		t.Fatalf("Inspect() error: %v", err)
+	}
+	if state.Id != "c1" || state.Image != "app:v1" || state.Status != models.ContainerStateExited || state.ExitCode != 137 || state.Error != "oom" || state.StartedAt.IsZero() || state.FinishedAt.IsZero() {
+		t.Errorf("Inspect() = %+v", state)
+	}
+	state, err = driver.Inspect(context.Background(), "missing")
+	if err != nil || state.Status != models.ContainerStateMissing {
+		t.Errorf("Inspect(missing) = %+v, %v", state, err)
+	}
+}
+
+func TestDockerApiStopNotModified(t *testing.T) {
+	var requestUri string
+	driver := newTestDockerApiDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		requestUri = r.URL.RequestURI()
+		w.WriteHeader(http.StatusNotModified)
+	})
+	if err := driver.Stop(context.Background(), "app"); err != nil {
+		t.Errorf("Stop() already stopped error: %v", err)
+	}
+	if requestUri != "/containers/app/stop?t=5" {
+		t.Errorf("Stop() request = %s", requestUri)
+	}
+}
+
+func TestDockerApiLoadImage(t *testing.T) {
+	imageFile := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(imageFile, []byte("tar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	responseBody := `{"stream":"Loaded image: app:v1\n"}`
+	driver := newTestDockerApiDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "tar" || r.Header.Get("Content-Type") != "application/x-tar" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(responseBody))
+	})
+	if err := driver.LoadImage(context.Background(), imageFile, "app:v1"); err != nil {
+		t.Errorf("LoadImage() error: %v", err)
+	}
+	// 导入失败时状态码也是200,报错在返回的json流里
+	responseBody = `{"stream":"loading"}{"error":"archive/tar: invalid tar header"}`
+	err := driver.LoadImage(context.Background(), imageFile, "app:v1")
+	if runtimeErr, ok := err.(*Error); !ok || runtimeErr.Message != "archive/tar: invalid tar header" {
+		t.Errorf("LoadImage() error = %v", err)
+	}
+	if err = driver.LoadImage(context.Background(), filepath.Join(t.TempDir(), "not-exist.tar"), "app:v1"); err == nil {
+		t.Errorf("LoadImage() missing file should fail")
+	}
+}
+
+func TestDockerApiLogs(t *testing.T) {
+	driver := newTestDockerApiDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(dockerLogFrame(1, "out line\n"))
+		w.Write(dockerLogFrame(2, "err line\n"))
+	})
+	logs, err := driver.Logs(context.Background(), "app", 0)
+	if err != nil || logs != "out line\nerr line\n" {
+		t.Errorf("Logs() = %q, %v", logs, err)
+	}
+}
+
+func TestParsePortBinding(t *testing.T) {
+	tests := []struct {
+		portBind          string
+		wantHostIp        string
+		wantHostPort      string
+		wantContainerPort string
+		wantErr           bool
+	}{
+		{"20001:8080", "", "20001", "8080/tcp", false},
+		{"0.0.0.0:20001:8080/udp", "0.0.0.0", "20001", "8080/udp", false},
+		{"8080", "", "", "", true},
+		{"a:b:c:d", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.portBind, func(t *testing.T) {
+			hostIp, hostPort, containerPort, err := parsePortBinding(tt.portBind)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePortBinding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if hostIp != tt.wantHostIp || hostPort != tt.wantHostPort || containerPort != tt.wantContainerPort {
+				t.Errorf("parsePortBinding() = %q,%q,%q", hostIp, hostPort, containerPort)
+			}
+		})
+	}
+}
+
+func TestDemuxDockerLogs(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"tty raw output", []byte("plain log\n"), "plain log\n"},
+		{"multiplexed", append(dockerLogFrame(1, "a\n"), dockerLogFrame(2, "b\n")...), "a\nb\n"},
+		{"truncated frame", dockerLogFrame(1, "abc")[:10], "ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := demuxDockerLogs(tt.data); got != tt.want {
+				t.Errorf("demuxDockerLogs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func dockerLogFrame(stream byte, content string) []byte {
+	frame := make([]byte, 8, 8+len(content))
+	frame[0] = stream
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(content)))
+	return append(frame, content...)
+}
@@ -0,0 +1,141 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+const (
+	defaultDockerApiPort = "2376"
+	defaultStopTimeout   = 10
+	defaultLogTail       = 200
+)
+
+// Driver 插件容器运行时,屏蔽ssh执行docker命令、docker engine api等不同的实现
+type Driver interface {
+	// LoadImage 把本地镜像包导入到目标机器,image为包里的镜像名
+	LoadImage(ctx context.Context, imageFile, image string) error
+	// Run 创建并启动容器,返回容器id
+	Run(ctx context.Context, param *models.ContainerRunParam) (string, error)
+	// Stop 停止容器
+	Stop(ctx context.Context, name string) error
+	// Restart 重启容器
+	Restart(ctx context.Context, name string) error
+	// Remove 强制删除容器,image不为空时一起删除镜像
+	Remove(ctx context.Context, name, image string) error
+	// Inspect 查询容器状态,容器不存在时返回missing状态
+	Inspect(ctx context.Context, name string) (*models.ContainerState, error)
+	// Logs 查询容器最后tail行日志
+	Logs(ctx context.Context, name string, tail int) (string, error)
+}
+
+// EndpointDriver 对外访问地址不是目标机器ip和分配端口的运行时,如kubernetes的Service
+type EndpointDriver interface {
+	Driver
+	// Endpoint 容器注册到gateway的访问地址
+	Endpoint(ctx context.Context, name string) (host, port string, err error)
+	// ServiceHost 容器对外访问的主机地址,创建前就能确定,作为插件的ALLOCATE_HOST
+	ServiceHost(name string) string
+}
+
+// DriverFactory 按资源服务器创建驱动
+type DriverFactory func(server *models.ResourceServer, config *models.ContainerRuntimeConfig) (Driver, error)
+
+// Error 容器运行时的结构化报错
+type Error struct {
+	Driver   string // 驱动名
+	Op       string // 操作->load | run | stop | restart | remove | inspect | logs
+	Host     string // 目标机器
+	Target   string // 容器名或镜像名
+	Code     int    // docker api的http状态码,ssh驱动为命令退出码
+	Message  string
+	NotFound bool // 容器或镜像不存在
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("container runtime %s %s %s on %s fail,code:%d,%s", e.Driver, e.Op, e.Target, e.Host, e.Code, e.Message)
+}
+
+var (
+	driverFactoryMap  = make(map[string]DriverFactory)
+	driverFactoryLock = new(sync.RWMutex)
+)
+
+func init() {
+	RegisterDriver(models.ContainerDriverSsh, newSshDriver)
+	RegisterDriver(models.ContainerDriverDockerApi, newDockerApiDriver)
+	RegisterDriver(models.ContainerDriverK8s, newK8sDriver)
+}
+
+// RegisterDriver 注册新的容器运行时驱动
+func RegisterDriver(name string, factory DriverFactory) {
+	driverFactoryLock.Lock()
+	driverFactoryMap[name] = factory
+	driverFactoryLock.Unlock()
+}
+
+// NewDriver 按配置的驱动创建目标机器的容器运行时,没配置时用ssh,kubernetes类型的资源服务器固定用kubernetes驱动
+func NewDriver(server *models.ResourceServer) (Driver, error) {
+	config := GetRuntimeConfig()
+	driverName := config.Driver
+	if server.Type == models.ResourceServerTypeK8s {
+		driverName = models.ContainerDriverK8s
+	}
+	driverFactoryLock.RLock()
+	factory, ok := driverFactoryMap[driverName]
+	driverFactoryLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("container runtime driver:%s not support", driverName)
+	}
+	return factory(server, config)
+}
+
+// GetRuntimeConfig 容器运行时配置,补齐默认值
+func GetRuntimeConfig() *models.ContainerRuntimeConfig {
+	config := models.ContainerRuntimeConfig{}
+	if models.Config != nil && models.Config.Plugin != nil && models.Config.Plugin.ContainerRuntime != nil {
+		config = *models.Config.Plugin.ContainerRuntime
+	}
+	if config.Driver == "" {
+		config.Driver = models.ContainerDriverSsh
+	}
+	if config.DockerApiPort == "" {
+		config.DockerApiPort = defaultDockerApiPort
+	}
+	if config.StopTimeout <= 0 {
+		config.StopTimeout = defaultStopTimeout
+	}
+	return &config
+}
+
+// WaitRunning 轮询容器状态直到运行中、已退出或超时,返回最后一次查到的状态
+func WaitRunning(ctx context.Context, driver Driver, name string, timeout time.Duration) (state *models.ContainerState, err error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		if state, err = driver.Inspect(ctx, name); err != nil {
+			return
+		}
+		if state.Running || state.Status == models.ContainerStateExited || state.Status == models.ContainerStateMissing || time.Now().After(deadline) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-time.After(3 * time.Second):
+		}
+	}
+}
+
+// InstanceStatus 容器状态转成plugin_instances的container_status,只有RUNNING的实例会注册到gateway
+func InstanceStatus(state *models.ContainerState) string {
+	if state.Running {
+		return "RUNNING"
+	}
+	return strings.ToUpper(state.Status)
+}
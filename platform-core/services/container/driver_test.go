@@ -0,0 +1,148 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+func setRuntimeConfig(t *testing.T, runtimeConfig *models.ContainerRuntimeConfig) {
+	originConfig := models.Config
+	models.Config = &models.GlobalConfig{Plugin: &models.PluginJsonConfig{ContainerRuntime: runtimeConfig}}
+	t.Cleanup(func() {
+		models.Config = originConfig
+	})
+}
+
+func TestGetRuntimeConfig(t *testing.T) {
+	setRuntimeConfig(t, nil)
+	config := GetRuntimeConfig()
+	if config.Driver != models.ContainerDriverSsh || config.DockerApiPort != defaultDockerApiPort || config.StopTimeout != defaultStopTimeout {
+		t.Errorf("GetRuntimeConfig() default = %+v", config)
+	}
+	setRuntimeConfig(t, &models.ContainerRuntimeConfig{Driver: models.ContainerDriverDockerApi, DockerApiPort: "2375", StopTimeout: 30})
+	config = GetRuntimeConfig()
+	if config.Driver != models.ContainerDriverDockerApi || config.DockerApiPort != "2375" || config.StopTimeout != 30 {
+		t.Errorf("GetRuntimeConfig() configured = %+v", config)
+	}
+}
+
+func TestNewDriver(t *testing.T) {
+	tests := []struct {
+		name    string
+		driver  string
+		server  *models.ResourceServer
+		want    string
+		wantErr bool
+	}{
+		{"default ssh", "", &models.ResourceServer{Host: "10.0.0.1"}, "*container.sshDriver", false},
+		{"docker api", models.ContainerDriverDockerApi, &models.ResourceServer{Host: "10.0.0.1"}, "*container.dockerApiDriver", false},
+		{"registered driver", fakeDriverName, &models.ResourceServer{Host: "10.0.0.1"}, "*container.FakeDriver", false},
+		{"not support", "podman", &models.ResourceServer{Host: "10.0.0.1"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRuntimeConfig(t, &models.ContainerRuntimeConfig{Driver: tt.driver})
+			driver, err := NewDriver(tt.server)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewDriver() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := fmt.Sprintf("%T", driver); got != tt.want {
+				t.Errorf("NewDriver() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitRunning(t *testing.T) {
+	ctx := context.Background()
+	driver := GetFakeDriver("wait-running-host")
+	driver.LoadImage(ctx, "", "demo:v1")
+	if _, err := driver.Run(ctx, &models.ContainerRunParam{Name: "running", Image: "demo:v1"}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if _, err := driver.Run(ctx, &models.ContainerRunParam{Name: "exited", Image: "demo:v1"}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	driver.Exit("exited", 1, "start fail")
+
+	state, err := WaitRunning(ctx, driver, "running", time.Second)
+	if err != nil || !state.Running || InstanceStatus(state) != "RUNNING" {
+		t.Errorf("WaitRunning(running) = %+v, %v", state, err)
+	}
+	state, err = WaitRunning(ctx, driver, "exited", time.Second)
+	if err != nil || state.Running || state.ExitCode != 1 || InstanceStatus(state) != "EXITED" {
+		t.Errorf("WaitRunning(exited) = %+v, %v", state, err)
+	}
+	state, err = WaitRunning(ctx, driver, "not-exist", time.Second)
+	if err != nil || state.Status != models.ContainerStateMissing {
+		t.Errorf("WaitRunning(not-exist) = %+v, %v", state, err)
+	}
+	inspectErr := errors.New("connection refused")
+	driver.FailNext("inspect", inspectErr)
+	if _, err = WaitRunning(ctx, driver, "running", time.Second); !errors.Is(err, inspectErr) {
+		t.Errorf("WaitRunning() error = %v, want %v", err, inspectErr)
+	}
+}
+
+func TestFakeDriverLifecycle(t *testing.T) {
+	ctx := context.Background()
+	driver := GetFakeDriver("lifecycle-host")
+	if _, err := driver.Run(ctx, &models.ContainerRunParam{Name: "app", Image: "app:v1"}); !isNotFound(err) {
+		t.Fatalf("Run() without image error = %v, want not found", err)
+	}
+	if err := driver.LoadImage(ctx, "", "app:v1"); err != nil {
+		t.Fatalf("LoadImage() error: %v", err)
+	}
+	if _, err := driver.Run(ctx, &models.ContainerRunParam{Name: "app", Image: "app:v1"}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if _, err := driver.Run(ctx, &models.ContainerRunParam{Name: "app", Image: "app:v1"}); err == nil {
+		t.Errorf("Run() duplicate name should fail")
+	}
+	driver.AppendLog("app", "started\n")
+	if logs, err := driver.Logs(ctx, "app", 10); err != nil || logs != "started\n" {
+		t.Errorf("Logs() = %q, %v", logs, err)
+	}
+	if err := driver.Stop(ctx, "app"); err != nil {
+		t.Fatalf("Stop() error: %v", err)
+	}
+	if state, _ := driver.Inspect(ctx, "app"); state.Running {
+		t.Errorf("Inspect() after stop = %+v", state)
+	}
+	if err := driver.Restart(ctx, "app"); err != nil {
+		t.Fatalf("Restart() error: %v", err)
+	}
+	if state, _ := driver.Inspect(ctx, "app"); !state.Running {
+		t.Errorf("Inspect() after restart = %+v", state)
+	}
+	if err := driver.Remove(ctx, "app", "app:v1"); err != nil {
+		t.Fatalf("Remove() error: %v", err)
+	}
+	if driver.HasImage("app:v1") {
+		t.Errorf("Remove() should remove image")
+	}
+	if err := driver.Remove(ctx, "app", ""); !isNotFound(err) {
+		t.Errorf("Remove() missing container error = %v, want not found", err)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	err := &Error{Driver: models.ContainerDriverDockerApi, Op: "run", Host: "10.0.0.1", Target: "app", Code: 409, Message: "conflict"}
+	want := "container runtime docker_api run app on 10.0.0.1 fail,code:409,conflict"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func isNotFound(err error) bool {
+	var runtimeErr *Error
+	return errors.As(err, &runtimeErr) && runtimeErr.NotFound
+}
@@ -0,0 +1,189 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+// fakeDriverName 内存模拟驱动只在测试里注册,生产配置不能选到
+const fakeDriverName = "fake"
+
+var (
+	fakeDriverMap  = make(map[string]*FakeDriver)
+	fakeDriverLock = new(sync.Mutex)
+)
+
+func init() {
+	RegisterDriver(fakeDriverName, func(server *models.ResourceServer, config *models.ContainerRuntimeConfig) (Driver, error) {
+		return GetFakeDriver(server.Host), nil
+	})
+}
+
+// FakeDriver 内存模拟的容器运行时,同一主机共用一份状态,用于测试插件部署流程
+type FakeDriver struct {
+	host       string
+	lock       sync.Mutex
+	images     map[string]bool
+	containers map[string]*models.ContainerState
+	logs       map[string]string
+	failOps    map[string]error
+}
+
+// GetFakeDriver 获取主机对应的模拟驱动
+func GetFakeDriver(host string) *FakeDriver {
+	fakeDriverLock.Lock()
+	defer fakeDriverLock.Unlock()
+	driver, ok := fakeDriverMap[host]
+	if !ok {
+		driver = &FakeDriver{host: host, images: make(map[string]bool), containers: make(map[string]*models.ContainerState), logs: make(map[string]string), failOps: make(map[string]error)}
+		fakeDriverMap[host] = driver
+	}
+	return driver
+}
+
+// FailNext 下一次op操作返回指定报错
+func (d *FakeDriver) FailNext(op string, err error) {
+	d.lock.Lock()
+	d.failOps[op] = err
+	d.lock.Unlock()
+}
+
+// Exit 模拟容器退出
+func (d *FakeDriver) Exit(name string, exitCode int, message string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if state, ok := d.containers[name]; ok {
+		state.Status, state.Running, state.ExitCode, state.Error, state.FinishedAt = models.ContainerStateExited, false, exitCode, message, time.Now()
+	}
+}
+
+// AppendLog 模拟容器输出日志
+func (d *FakeDriver) AppendLog(name, content string) {
+	d.lock.Lock()
+	d.logs[name] += content
+	d.lock.Unlock()
+}
+
+// HasImage 镜像是否已导入
+func (d *FakeDriver) HasImage(image string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.images[image]
+}
+
+func (d *FakeDriver) LoadImage(ctx context.Context, imageFile, image string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if err := d.takeFail("load"); err != nil {
+		return err
+	}
+	d.images[image] = true
+	return nil
+}
+
+func (d *FakeDriver) Run(ctx context.Context, param *models.ContainerRunParam) (string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if err := d.takeFail("run"); err != nil {
+		return "", err
+	}
+	if !d.images[param.Image] {
+		return "", d.newError("run", param.Name, http.StatusNotFound, "No such image: "+param.Image)
+	}
+	if _, ok := d.containers[param.Name]; ok {
+		return "", d.newError("run", param.Name, http.StatusConflict, fmt.Sprintf("container name %s is already in use", param.Name))
+	}
+	state := &models.ContainerState{Id: fmt.Sprintf("fake_%s_%d", param.Name, time.Now().UnixNano()), Name: param.Name, Image: param.Image, Status: models.ContainerStateRunning, Running: true, StartedAt: time.Now()}
+	d.containers[param.Name] = state
+	return state.Id, nil
+}
+
+func (d *FakeDriver) Stop(ctx context.Context, name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if err := d.takeFail("stop"); err != nil {
+		return err
+	}
+	state, ok := d.containers[name]
+	if !ok {
+		return d.newError("stop", name, http.StatusNotFound, "No such container: "+name)
+	}
+	if state.Running {
+		state.Status, state.Running, state.FinishedAt = models.ContainerStateExited, false, time.Now()
+	}
+	return nil
+}
+
+func (d *FakeDriver) Restart(ctx context.Context, name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if err := d.takeFail("restart"); err != nil {
+		return err
+	}
+	state, ok := d.containers[name]
+	if !ok {
+		return d.newError("restart", name, http.StatusNotFound, "No such container: "+name)
+	}
+	state.Status, state.Running, state.ExitCode, state.Error, state.StartedAt = models.ContainerStateRunning, true, 0, "", time.Now()
+	return nil
+}
+
+func (d *FakeDriver) Remove(ctx context.Context, name, image string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if err := d.takeFail("remove"); err != nil {
+		return err
+	}
+	if _, ok := d.containers[name]; !ok {
+		return d.newError("remove", name, http.StatusNotFound, "No such container: "+name)
+	}
+	delete(d.containers, name)
+	delete(d.logs, name)
+	if image != "" {
+		delete(d.images, image)
+	}
+	return nil
+}
+
+func (d *FakeDriver) Inspect(ctx context.Context, name string) (*models.ContainerState, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if err := d.takeFail("inspect"); err != nil {
+		return nil, err
+	}
+	state, ok := d.containers[name]
+	if !ok {
+		return &models.ContainerState{Name: name, Status: models.ContainerStateMissing}, nil
+	}
+	stateCopy := *state
+	return &stateCopy, nil
+}
+
+func (d *FakeDriver) Logs(ctx context.Context, name string, tail int) (string, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if err := d.takeFail("logs"); err != nil {
+		return "", err
+	}
+	if _, ok := d.containers[name]; !ok {
+		return "", d.newError("logs", name, http.StatusNotFound, "No such container: "+name)
+	}
+	return d.logs[name], nil
+}
+
+func (d *FakeDriver) takeFail(op string) error {
+	err, ok := d.failOps[op]
+	if ok {
+		delete(d.failOps, op)
+	}
+	return err
+}
+
+func (d *FakeDriver) newError(op, target string, code int, message string) *Error {
+	return &Error{Driver: fakeDriverName, Op: op, Host: d.host, Target: target, Code: code, Message: message, NotFound: code == http.StatusNotFound}
+}
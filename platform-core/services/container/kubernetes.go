@@ -0,0 +1,551 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+const (
+	defaultK8sNamespace    = "wecube-plugins"
+	defaultK8sReadyTimeout = 120
+	k8sAppLabel            = "app"
+	k8sManagedByLabel      = "app.kubernetes.io/managed-by"
+	k8sManagedByValue      = "wecube-platform"
+)
+
+var k8sNameIllegalRegexp = regexp.MustCompile("[^a-z0-9-]+")
+
+// k8sDriver 把插件容器部署成kubernetes的Deployment、Service和存放环境变量的Secret
+type k8sDriver struct {
+	host     string
+	baseUrl  string
+	token    string
+	client   *http.Client
+	config   *models.K8sConfig
+	nodeHost string
+}
+
+type k8sObjectMeta struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type k8sSecret struct {
+	ApiVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   k8sObjectMeta     `json:"metadata"`
+	Type       string            `json:"type"`
+	StringData map[string]string `json:"stringData"`
+}
+
+type k8sContainerPort struct {
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+type k8sVolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+type k8sVolume struct {
+	Name     string `json:"name"`
+	HostPath struct {
+		Path string `json:"path"`
+		Type string `json:"type"`
+	} `json:"hostPath"`
+}
+
+type k8sContainer struct {
+	Name         string             `json:"name"`
+	Image        string             `json:"image"`
+	Ports        []k8sContainerPort `json:"ports,omitempty"`
+	EnvFrom      []interface{}      `json:"envFrom,omitempty"`
+	VolumeMounts []k8sVolumeMount   `json:"volumeMounts,omitempty"`
+}
+
+type k8sDeployment struct {
+	ApiVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   k8sObjectMeta `json:"metadata"`
+	Spec       struct {
+		Replicas int `json:"replicas"`
+		Selector struct {
+			MatchLabels map[string]string `json:"matchLabels"`
+		} `json:"selector"`
+		Template struct {
+			Metadata k8sObjectMeta `json:"metadata"`
+			Spec     struct {
+				Containers       []k8sContainer      `json:"containers"`
+				Volumes          []k8sVolume         `json:"volumes,omitempty"`
+				ImagePullSecrets []map[string]string `json:"imagePullSecrets,omitempty"`
+			} `json:"spec"`
+		} `json:"template"`
+	} `json:"spec"`
+	Status *struct {
+		ReadyReplicas int `json:"readyReplicas"`
+	} `json:"status,omitempty"`
+}
+
+type k8sServicePort struct {
+	Name       string `json:"name"`
+	Protocol   string `json:"protocol"`
+	Port       int    `json:"port"`
+	TargetPort int    `json:"targetPort"`
+	NodePort   int    `json:"nodePort,omitempty"`
+}
+
+type k8sService struct {
+	ApiVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   k8sObjectMeta `json:"metadata"`
+	Spec       struct {
+		Type     string            `json:"type"`
+		Selector map[string]string `json:"selector"`
+		Ports    []k8sServicePort  `json:"ports"`
+	} `json:"spec"`
+}
+
+type k8sPodList struct {
+	Items []struct {
+		Metadata k8sObjectMeta `json:"metadata"`
+		Status   struct {
+			Phase             string `json:"phase"`
+			StartTime         string `json:"startTime"`
+			ContainerStatuses []struct {
+				Ready bool `json:"ready"`
+				State struct {
+					Waiting *struct {
+						Reason  string `json:"reason"`
+						Message string `json:"message"`
+					} `json:"waiting"`
+					Terminated *struct {
+						ExitCode   int    `json:"exitCode"`
+						Reason     string `json:"reason"`
+						FinishedAt string `json:"finishedAt"`
+					} `json:"terminated"`
+				} `json:"state"`
+				LastState struct {
+					Terminated *struct {
+						ExitCode   int    `json:"exitCode"`
+						Reason     string `json:"reason"`
+						FinishedAt string `json:"finishedAt"`
+					} `json:"terminated"`
+				} `json:"lastState"`
+			} `json:"containerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+func newK8sDriver(server *models.ResourceServer, runtimeConfig *models.ContainerRuntimeConfig) (Driver, error) {
+	config := GetK8sConfig()
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CaFile != "" && !config.InsecureSkipVerify {
+		caBytes, readErr := os.ReadFile(config.CaFile)
+		if readErr != nil {
+			return nil, fmt.Errorf("read kubernetes ca file fail,%s ", readErr.Error())
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("kubernetes ca file:%s illegal", config.CaFile)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+	transport := &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     tlsConfig,
+	}
+	driver := &k8sDriver{
+		host:     server.Host,
+		baseUrl:  "https://" + net.JoinHostPort(server.Host, server.Port),
+		token:    server.LoginPassword,
+		client:   &http.Client{Transport: transport, Timeout: 60 * time.Second},
+		config:   config,
+		nodeHost: config.NodeHost,
+	}
+	if driver.nodeHost == "" {
+		driver.nodeHost = server.Host
+	}
+	return driver, nil
+}
+
+// GetK8sConfig kubernetes配置,补齐默认值
+func GetK8sConfig() *models.K8sConfig {
+	config := models.K8sConfig{}
+	if models.Config != nil && models.Config.Plugin != nil && models.Config.Plugin.Kubernetes != nil {
+		config = *models.Config.Plugin.Kubernetes
+	}
+	if config.Namespace == "" {
+		config.Namespace = defaultK8sNamespace
+	}
+	if config.ServiceType == "" {
+		config.ServiceType = models.K8sServiceTypeNodePort
+	}
+	if config.ReadyTimeout <= 0 {
+		config.ReadyTimeout = defaultK8sReadyTimeout
+	}
+	return &config
+}
+
+// K8sResourceName 容器名转成kubernetes资源名,只能是小写字母数字和-
+func K8sResourceName(name string) string {
+	resourceName := strings.Trim(k8sNameIllegalRegexp.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(resourceName) > 63 {
+		resourceName = strings.Trim(resourceName[:63], "-")
+	}
+	return resourceName
+}
+
+// LoadImage kubernetes从镜像仓库拉取镜像,镜像需提前推送到image_registry
+func (d *k8sDriver) LoadImage(ctx context.Context, imageFile, image string) error {
+	log.Logger.Info("kubernetes driver ignore load image,pull from registry", log.String("image", d.buildImage(image)))
+	return nil
+}
+
+func (d *k8sDriver) Run(ctx context.Context, param *models.ContainerRunParam) (containerId string, err error) {
+	name := K8sResourceName(param.Name)
+	labels := map[string]string{k8sAppLabel: name, k8sManagedByLabel: k8sManagedByValue}
+	meta := k8sObjectMeta{Name: name, Namespace: d.config.Namespace, Labels: labels}
+	secret := k8sSecret{ApiVersion: "v1", Kind: "Secret", Metadata: meta, Type: "Opaque", StringData: make(map[string]string)}
+	for _, env := range param.EnvVariables {
+		if eqIndex := strings.Index(env, "="); eqIndex > 0 {
+			secret.StringData[env[:eqIndex]] = env[eqIndex+1:]
+		}
+	}
+	containerObj := k8sContainer{Name: name, Image: d.buildImage(param.Image), EnvFrom: []interface{}{map[string]interface{}{"secretRef": map[string]string{"name": name}}}}
+	service := k8sService{ApiVersion: "v1", Kind: "Service", Metadata: meta}
+	service.Spec.Type = d.config.ServiceType
+	service.Spec.Selector = map[string]string{k8sAppLabel: name}
+	for i, portBind := range param.PortBindings {
+		_, hostPort, containerPort, parseErr := parsePortBinding(portBind)
+		if parseErr != nil {
+			err = d.newError("run", name, 0, parseErr.Error())
+			return
+		}
+		portValue, protocol := splitPortProtocol(containerPort)
+		servicePort, _ := strconv.Atoi(hostPort)
+		if servicePort <= 0 || portValue <= 0 {
+			err = d.newError("run", name, 0, fmt.Sprintf("port binding:%s illegal", portBind))
+			return
+		}
+		containerObj.Ports = append(containerObj.Ports, k8sContainerPort{ContainerPort: portValue, Protocol: protocol})
+		service.Spec.Ports = append(service.Spec.Ports, k8sServicePort{Name: fmt.Sprintf("port-%d", i), Protocol: protocol, Port: servicePort, TargetPort: portValue})
+	}
+	deployment := k8sDeployment{ApiVersion: "apps/v1", Kind: "Deployment", Metadata: meta}
+	deployment.Spec.Replicas = 1
+	deployment.Spec.Selector.MatchLabels = map[string]string{k8sAppLabel: name}
+	deployment.Spec.Template.Metadata = k8sObjectMeta{Name: name, Labels: labels}
+	for i, volumeBind := range param.VolumeBindings {
+		parts := strings.Split(volumeBind, ":")
+		if len(parts) < 2 {
+			err = d.newError("run", name, 0, fmt.Sprintf("volume binding:%s illegal", volumeBind))
+			return
+		}
+		volume := k8sVolume{Name: fmt.Sprintf("volume-%d", i)}
+		volume.HostPath.Path, volume.HostPath.Type = parts[0], "DirectoryOrCreate"
+		deployment.Spec.Template.Spec.Volumes = append(deployment.Spec.Template.Spec.Volumes, volume)
+		containerObj.VolumeMounts = append(containerObj.VolumeMounts, k8sVolumeMount{Name: volume.Name, MountPath: parts[1], ReadOnly: len(parts) > 2 && parts[2] == "ro"})
+	}
+	deployment.Spec.Template.Spec.Containers = []k8sContainer{containerObj}
+	if d.config.ImagePullSecret != "" {
+		deployment.Spec.Template.Spec.ImagePullSecrets = []map[string]string{{"name": d.config.ImagePullSecret}}
+	}
+	// 依次创建,中途失败时清理已创建的资源
+	createList := []struct {
+		path string
+		obj  interface{}
+	}{
+		{path: d.secretPath(""), obj: &secret},
+		{path: d.deploymentPath(""), obj: &deployment},
+		{path: d.servicePath(""), obj: &service},
+	}
+	for i, createObj := range createList {
+		bodyBytes, _ := json.Marshal(createObj.obj)
+		respBody, reqErr := d.request(ctx, "run", name, http.MethodPost, createObj.path, bodyBytes, "application/json")
+		if reqErr != nil {
+			err = reqErr
+			for _, created := range createList[:i] {
+				d.deleteIgnoreNotFound(ctx, "run", name, created.path+"/"+name)
+			}
+			return
+		}
+		if createObj.path == d.deploymentPath("") {
+			var created struct {
+				Metadata struct {
+					Uid string `json:"uid"`
+				} `json:"metadata"`
+			}
+			json.Unmarshal(respBody, &created)
+			containerId = created.Metadata.Uid
+		}
+	}
+	return
+}
+
+// Stop 副本数缩到0
+func (d *k8sDriver) Stop(ctx context.Context, name string) (err error) {
+	name = K8sResourceName(name)
+	_, err = d.request(ctx, "stop", name, http.MethodPatch, d.deploymentPath(name), []byte(`{"spec":{"replicas":0}}`), "application/merge-patch+json")
+	return
+}
+
+// Restart 和kubectl rollout restart一样修改pod模板的注解触发重建,副本数为0时恢复成1
+func (d *k8sDriver) Restart(ctx context.Context, name string) (err error) {
+	name = K8sResourceName(name)
+	patchBody := fmt.Sprintf(`{"spec":{"replicas":1,"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":"%s"}}}}}`, time.Now().Format(time.RFC3339))
+	_, err = d.request(ctx, "restart", name, http.MethodPatch, d.deploymentPath(name), []byte(patchBody), "application/merge-patch+json")
+	return
+}
+
+// Remove 删除Service、Deployment和Secret,镜像由集群回收
+func (d *k8sDriver) Remove(ctx context.Context, name, image string) (err error) {
+	name = K8sResourceName(name)
+	notFoundNum := 0
+	for _, path := range []string{d.servicePath(name), d.deploymentPath(name), d.secretPath(name)} {
+		found, deleteErr := d.deleteIgnoreNotFound(ctx, "remove", name, path)
+		if deleteErr != nil {
+			return deleteErr
+		}
+		if !found {
+			notFoundNum++
+		}
+	}
+	if notFoundNum == 3 {
+		err = d.newError("remove", name, http.StatusNotFound, "deployment not found")
+	}
+	return
+}
+
+func (d *k8sDriver) Inspect(ctx context.Context, name string) (state *models.ContainerState, err error) {
+	name = K8sResourceName(name)
+	respBody, reqErr := d.request(ctx, "inspect", name, http.MethodGet, d.deploymentPath(name), nil, "")
+	if reqErr != nil {
+		if runtimeErr, ok := reqErr.(*Error); ok && runtimeErr.NotFound {
+			state = &models.ContainerState{Name: name, Status: models.ContainerStateMissing}
+			return
+		}
+		err = reqErr
+		return
+	}
+	var deployment k8sDeployment
+	if err = json.Unmarshal(respBody, &deployment); err != nil {
+		err = d.newError("inspect", name, http.StatusOK, "decode deployment fail,"+err.Error())
+		return
+	}
+	state = &models.ContainerState{Name: name, Status: models.ContainerStatePending}
+	if len(deployment.Spec.Template.Spec.Containers) > 0 {
+		state.Image = deployment.Spec.Template.Spec.Containers[0].Image
+	}
+	if deployment.Spec.Replicas == 0 {
+		state.Status = models.ContainerStateExited
+	}
+	podList, getPodErr := d.listPods(ctx, "inspect", name)
+	if getPodErr != nil {
+		err = getPodErr
+		return
+	}
+	if len(podList.Items) > 0 {
+		pod := podList.Items[0]
+		state.Id = pod.Metadata.Name
+		state.StartedAt, _ = time.Parse(time.RFC3339, pod.Status.StartTime)
+		if pod.Status.Phase == "Failed" || pod.Status.Phase == "Succeeded" {
+			state.Status = models.ContainerStateExited
+		}
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if containerStatus.State.Waiting != nil {
+				state.Error = containerStatus.State.Waiting.Reason + ":" + containerStatus.State.Waiting.Message
+			}
+			terminated := containerStatus.State.Terminated
+			if terminated == nil {
+				terminated = containerStatus.LastState.Terminated
+			}
+			if terminated != nil {
+				state.ExitCode = terminated.ExitCode
+				state.FinishedAt, _ = time.Parse(time.RFC3339, terminated.FinishedAt)
+				if state.Error == "" {
+					state.Error = terminated.Reason
+				}
+			}
+		}
+	}
+	if deployment.Status != nil && deployment.Status.ReadyReplicas > 0 {
+		state.Status, state.Running = models.ContainerStateRunning, true
+	}
+	return
+}
+
+func (d *k8sDriver) Logs(ctx context.Context, name string, tail int) (output string, err error) {
+	name = K8sResourceName(name)
+	if tail <= 0 {
+		tail = defaultLogTail
+	}
+	podList, getPodErr := d.listPods(ctx, "logs", name)
+	if getPodErr != nil {
+		err = getPodErr
+		return
+	}
+	if len(podList.Items) == 0 {
+		err = d.newError("logs", name, http.StatusNotFound, "no pod found")
+		return
+	}
+	respBody, reqErr := d.request(ctx, "logs", name, http.MethodGet, fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/log?tailLines=%d", d.config.Namespace, podList.Items[0].Metadata.Name, tail), nil, "")
+	if reqErr != nil {
+		err = reqErr
+		return
+	}
+	output = string(respBody)
+	return
+}
+
+// Endpoint NodePort返回节点地址和分配的nodePort,ClusterIP返回Service的集群内域名
+func (d *k8sDriver) Endpoint(ctx context.Context, name string) (host, port string, err error) {
+	name = K8sResourceName(name)
+	respBody, reqErr := d.request(ctx, "endpoint", name, http.MethodGet, d.servicePath(name), nil, "")
+	if reqErr != nil {
+		err = reqErr
+		return
+	}
+	var service k8sService
+	if err = json.Unmarshal(respBody, &service); err != nil {
+		err = d.newError("endpoint", name, http.StatusOK, "decode service fail,"+err.Error())
+		return
+	}
+	if len(service.Spec.Ports) == 0 {
+		err = d.newError("endpoint", name, http.StatusOK, "service without port")
+		return
+	}
+	host = d.ServiceHost(name)
+	if service.Spec.Type == models.K8sServiceTypeNodePort {
+		port = strconv.Itoa(service.Spec.Ports[0].NodePort)
+	} else {
+		port = strconv.Itoa(service.Spec.Ports[0].Port)
+	}
+	return
+}
+
+// ServiceHost NodePort是节点地址,ClusterIP是Service的集群内域名
+func (d *k8sDriver) ServiceHost(name string) string {
+	if d.config.ServiceType == models.K8sServiceTypeNodePort {
+		return d.nodeHost
+	}
+	return fmt.Sprintf("%s.%s.svc", K8sResourceName(name), d.config.Namespace)
+}
+
+func (d *k8sDriver) listPods(ctx context.Context, op, name string) (podList *k8sPodList, err error) {
+	respBody, reqErr := d.request(ctx, op, name, http.MethodGet, fmt.Sprintf("/api/v1/namespaces/%s/pods?labelSelector=%s", d.config.Namespace, url.QueryEscape(k8sAppLabel+"="+name)), nil, "")
+	if reqErr != nil {
+		err = reqErr
+		return
+	}
+	podList = &k8sPodList{}
+	if err = json.Unmarshal(respBody, podList); err != nil {
+		err = d.newError(op, name, http.StatusOK, "decode pod list fail,"+err.Error())
+	}
+	return
+}
+
+func (d *k8sDriver) deleteIgnoreNotFound(ctx context.Context, op, name, path string) (found bool, err error) {
+	_, err = d.request(ctx, op, name, http.MethodDelete, path+"?propagationPolicy=Background", nil, "")
+	if err != nil {
+		if runtimeErr, ok := err.(*Error); ok && runtimeErr.NotFound {
+			err = nil
+		}
+		return
+	}
+	found = true
+	return
+}
+
+func (d *k8sDriver) request(ctx context.Context, op, target, method, path string, body []byte, contentType string) (respBody []byte, err error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, newErr := http.NewRequestWithContext(ctx, method, d.baseUrl+path, bodyReader)
+	if newErr != nil {
+		err = d.newError(op, target, 0, newErr.Error())
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+d.token)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, respErr := d.client.Do(req)
+	if respErr != nil {
+		err = d.newError(op, target, 0, respErr.Error())
+		return
+	}
+	defer resp.Body.Close()
+	respBody, _ = io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(respBody, &status) != nil || status.Message == "" {
+			status.Message = string(respBody)
+		}
+		err = d.newError(op, target, resp.StatusCode, status.Message)
+	}
+	return
+}
+
+func (d *k8sDriver) newError(op, target string, code int, message string) *Error {
+	return &Error{Driver: models.ContainerDriverK8s, Op: op, Host: d.host, Target: target, Code: code, Message: message, NotFound: code == http.StatusNotFound}
+}
+
+func (d *k8sDriver) buildImage(image string) string {
+	if d.config.ImageRegistry == "" {
+		return image
+	}
+	return strings.TrimSuffix(d.config.ImageRegistry, "/") + "/" + image
+}
+
+func (d *k8sDriver) secretPath(name string) string {
+	return joinK8sPath(fmt.Sprintf("/api/v1/namespaces/%s/secrets", d.config.Namespace), name)
+}
+
+func (d *k8sDriver) deploymentPath(name string) string {
+	return joinK8sPath(fmt.Sprintf("/apis/apps/v1/namespaces/%s/deployments", d.config.Namespace), name)
+}
+
+func (d *k8sDriver) servicePath(name string) string {
+	return joinK8sPath(fmt.Sprintf("/api/v1/namespaces/%s/services", d.config.Namespace), name)
+}
+
+func joinK8sPath(collectionPath, name string) string {
+	if name == "" {
+		return collectionPath
+	}
+	return collectionPath + "/" + name
+}
+
+// splitPortProtocol 8080/tcp -> 8080,TCP
+func splitPortProtocol(containerPort string) (port int, protocol string) {
+	protocol = "TCP"
+	if slashIndex := strings.Index(containerPort, "/"); slashIndex > 0 {
+		protocol = strings.ToUpper(containerPort[slashIndex+1:])
+		containerPort = containerPort[:slashIndex]
+	}
+	port, _ = strconv.Atoi(containerPort)
+	return
+}
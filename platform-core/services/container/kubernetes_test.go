@@ -0,0 +1,47 @@
+package container
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+func newTestK8sDriver(t *testing.T, serviceType string, handler http.HandlerFunc) *k8sDriver {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &k8sDriver{host: "10.0.0.1", baseUrl: server.URL, client: server.Client(), config: &models.K8sConfig{Namespace: "wecube", ServiceType: serviceType}, nodeHost: "10.0.0.2"}
+}
+
+func TestK8sEndpoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceType string
+		wantHost    string
+		wantPort    string
+	}{
+		{"node port", models.K8sServiceTypeNodePort, "10.0.0.2", "30001"},
+		{"cluster ip", models.K8sServiceTypeClusterIP, "demo-plugin.wecube.svc", "20001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := newTestK8sDriver(t, tt.serviceType, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/namespaces/wecube/services/demo-plugin" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(`{"spec":{"type":"` + tt.serviceType + `","ports":[{"port":20001,"targetPort":8080,"nodePort":30001}]}}`))
+			})
+			// 创建前确定的地址和创建后查询到的一致
+			if host := driver.ServiceHost("Demo_Plugin"); host != tt.wantHost {
+				t.Errorf("ServiceHost() = %s, want %s", host, tt.wantHost)
+			}
+			host, port, err := driver.Endpoint(context.Background(), "Demo_Plugin")
+			if err != nil || host != tt.wantHost || port != tt.wantPort {
+				t.Errorf("Endpoint() = %s,%s,%v, want %s,%s", host, port, err, tt.wantHost, tt.wantPort)
+			}
+		})
+	}
+}
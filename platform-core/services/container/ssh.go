@@ -0,0 +1,136 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/bash"
+)
+
+// sshDriver ssh登录目标机器执行docker命令,保持原来的部署方式
+type sshDriver struct {
+	server *models.ResourceServer
+	config *models.ContainerRuntimeConfig
+}
+
+func newSshDriver(server *models.ResourceServer, config *models.ContainerRuntimeConfig) (Driver, error) {
+	return &sshDriver{server: server, config: config}, nil
+}
+
+func (d *sshDriver) LoadImage(ctx context.Context, imageFile, image string) (err error) {
+	targetImagePath := fmt.Sprintf("%s/%s_image.tar", models.Config.Plugin.DeployPath, strings.NewReplacer("/", "_", ":", "_").Replace(image))
+	if err = bash.RemoteSCP(d.server.Host, d.server.LoginUsername, d.server.LoginPassword, d.server.Port, imageFile, targetImagePath); err != nil {
+		return d.buildError("load", image, err, nil)
+	}
+	log.Logger.Info("scp plugin image file", log.String("targetHost", d.server.Host), log.String("tmpFile", imageFile), log.String("targetPath", targetImagePath))
+	_, err = d.exec("load", image, fmt.Sprintf("docker load --input %s && rm -f %s", targetImagePath, targetImagePath))
+	return
+}
+
+func (d *sshDriver) Run(ctx context.Context, param *models.ContainerRunParam) (containerId string, err error) {
+	dockerCmd := fmt.Sprintf("docker run -d --name %s ", param.Name)
+	for _, v := range param.VolumeBindings {
+		dockerCmd += fmt.Sprintf("--volume %s ", v)
+	}
+	for _, v := range param.PortBindings {
+		dockerCmd += fmt.Sprintf("-p %s ", v)
+	}
+	for _, v := range param.EnvVariables {
+		tmpV := v
+		if eqIndex := strings.Index(v, "="); eqIndex > 0 {
+			tmpV = v[:eqIndex+1] + "'" + v[eqIndex+1:] + "'"
+		}
+		dockerCmd += fmt.Sprintf("-e %s ", tmpV)
+	}
+	dockerCmd += param.Image
+	output, execErr := d.exec("run", param.Name, dockerCmd)
+	if execErr != nil {
+		err = execErr
+		return
+	}
+	containerId = strings.TrimSpace(output)
+	return
+}
+
+func (d *sshDriver) Stop(ctx context.Context, name string) (err error) {
+	_, err = d.exec("stop", name, fmt.Sprintf("docker stop -t %d %s", d.config.StopTimeout, name))
+	return
+}
+
+func (d *sshDriver) Restart(ctx context.Context, name string) (err error) {
+	_, err = d.exec("restart", name, fmt.Sprintf("docker restart -t %d %s", d.config.StopTimeout, name))
+	return
+}
+
+func (d *sshDriver) Remove(ctx context.Context, name, image string) (err error) {
+	removeCmd := fmt.Sprintf("docker rm -f %s", name)
+	if image != "" {
+		removeCmd += fmt.Sprintf(" && docker rmi %s", image)
+	}
+	_, err = d.exec("remove", name, removeCmd)
+	return
+}
+
+func (d *sshDriver) Inspect(ctx context.Context, name string) (state *models.ContainerState, err error) {
+	output, execErr := d.exec("inspect", name, fmt.Sprintf("docker inspect --format '{{.Id}}|{{.Config.Image}}|{{json .State}}' %s", name))
+	if execErr != nil {
+		var runtimeErr *Error
+		if errors.As(execErr, &runtimeErr) && runtimeErr.NotFound {
+			state = &models.ContainerState{Name: name, Status: models.ContainerStateMissing}
+			return
+		}
+		err = execErr
+		return
+	}
+	parts := strings.SplitN(strings.TrimSpace(output), "|", 3)
+	if len(parts) != 3 {
+		err = &Error{Driver: models.ContainerDriverSsh, Op: "inspect", Host: d.server.Host, Target: name, Message: "unexpected inspect output:" + output}
+		return
+	}
+	var dockerState dockerContainerState
+	if err = json.Unmarshal([]byte(parts[2]), &dockerState); err != nil {
+		err = &Error{Driver: models.ContainerDriverSsh, Op: "inspect", Host: d.server.Host, Target: name, Message: "decode container state fail," + err.Error()}
+		return
+	}
+	state = dockerState.toContainerState(parts[0], name, parts[1])
+	return
+}
+
+func (d *sshDriver) Logs(ctx context.Context, name string, tail int) (string, error) {
+	if tail <= 0 {
+		tail = defaultLogTail
+	}
+	return d.exec("logs", name, fmt.Sprintf("docker logs --tail %d %s 2>&1", tail, name))
+}
+
+func (d *sshDriver) exec(op, target, command string) (output string, err error) {
+	stdout, execErr := bash.RemoteSSHCommandWithOutput(d.server.Host, d.server.LoginUsername, d.server.LoginPassword, d.server.Port, command)
+	if execErr != nil {
+		err = d.buildError(op, target, execErr, stdout)
+		return
+	}
+	output = string(stdout)
+	return
+}
+
+// buildError 从ssh命令的退出码和stderr构造结构化报错
+func (d *sshDriver) buildError(op, target string, execErr error, stdout []byte) error {
+	runtimeErr := &Error{Driver: models.ContainerDriverSsh, Op: op, Host: d.server.Host, Target: target, Code: -1, Message: execErr.Error()}
+	var exitErr *exec.ExitError
+	if errors.As(execErr, &exitErr) {
+		runtimeErr.Code = exitErr.ExitCode()
+		if stderr := strings.TrimSpace(string(exitErr.Stderr)); stderr != "" {
+			runtimeErr.Message = stderr
+		} else if len(stdout) > 0 {
+			runtimeErr.Message = strings.TrimSpace(string(stdout))
+		}
+	}
+	runtimeErr.NotFound = strings.Contains(runtimeErr.Message, "No such container") || strings.Contains(runtimeErr.Message, "No such object")
+	return runtimeErr
+}
@@ -16,10 +16,19 @@ import (
 )
 
 func StartCronJob() {
+	execution.RecoverInterruptedBatchExecutions(context.WithValue(context.Background(), models.TransactionIdHeader, fmt.Sprintf("recover_batch_exec_%d", time.Now().Unix())))
+	execution.RecoverInterruptedPluginUpgrades(context.WithValue(context.Background(), models.TransactionIdHeader, fmt.Sprintf("recover_plugin_upgrade_%d", time.Now().Unix())))
 	SetupCleanUpBatchExecTicker()
 	go StartSendProcScheduleMail()
+	go StartSendBatchExecScheduleMail()
 	go StartHandleProcEvent()
 	go StartTransProcEvent()
+	go StartHumanTaskEscalation()
+	go StartProcSlaCheck()
+	go execution.StartProcSearchIndexer()
+	go StartProcSearchIndexBackfill()
+	go StartProcSearchIndexCleanup()
+	go StartPluginHealthCheck()
 }
 
 func SetupCleanUpBatchExecTicker() {
@@ -157,15 +166,30 @@ func tryUpdateScheduleJobMail(input *models.ScheduleJobMailQueryObj) bool {
 
 func buildScheduleJobMail(mailMode, user, role string, jobObj *models.ScheduleJobMailQueryObj) (mailObj models.SendMailTarget, err error) {
 	mailObj = models.SendMailTarget{}
+	if mailObj.Accept, err = getScheduleMailAccept(mailMode, user, role); err != nil {
+		return
+	}
+	if jobObj.Status == models.JobStatusSuccess {
+		mailObj.Subject = fmt.Sprintf("Wecube Process Schedule Run %s,[%s][%s]", jobObj.Status, jobObj.ProcDefName, jobObj.EntityDataName)
+		mailObj.Content = mailObj.Subject + fmt.Sprintf("\nProcess Instance Id:%s \nStatus:%s \nTime:%s \n", jobObj.ProcInsId, jobObj.Status, jobObj.CreatedTime)
+	} else if jobObj.NodeStatus == models.JobStatusFail {
+		mailObj.Subject = fmt.Sprintf("Wecube Process Schedule Run Fail,[%s][%s]", jobObj.ProcDefName, jobObj.EntityDataName)
+		mailObj.Content = mailObj.Subject + fmt.Sprintf("\nProcess Instance Id:%s \nStatus:%s \nTime:%s \n", jobObj.ProcInsId, jobObj.Status, jobObj.CreatedTime) + fmt.Sprintf("\nNode [%s] %s", jobObj.NodeName, jobObj.NodeStatus)
+	}
+	return
+}
+
+// getScheduleMailAccept 定时任务邮件接收人,role取角色邮箱,user取创建人邮箱
+func getScheduleMailAccept(mailMode, user, role string) (accept []string, err error) {
 	if mailMode == "role" {
 		if role == "" {
 			err = fmt.Errorf("mail target role empty")
 		} else {
-			if roleObj, roleErr := remote.RetrieveRoleByRoleName(role, remote.GetToken(), "en"); roleErr != nil {
+			if roleObj, roleErr := remote.RetrieveRoleByRoleName(context.Background(), role, remote.GetToken(), "en"); roleErr != nil {
 				err = roleErr
 			} else {
 				if roleObj.Email != "" {
-					mailObj.Accept = []string{roleObj.Email}
+					accept = []string{roleObj.Email}
 				}
 			}
 		}
@@ -173,11 +197,11 @@ func buildScheduleJobMail(mailMode, user, role string, jobObj *models.ScheduleJo
 		if user == "" {
 			err = fmt.Errorf("mail target user empty")
 		} else {
-			if userObj, userErr := remote.RetrieveUserByUsername(user, remote.GetToken(), "en"); userErr != nil {
+			if userObj, userErr := remote.RetrieveUserByUsername(context.Background(), user, remote.GetToken(), "en"); userErr != nil {
 				err = userErr
 			} else {
 				if userObj.EmailAddr != "" {
-					mailObj.Accept = []string{userObj.EmailAddr}
+					accept = []string{userObj.EmailAddr}
 				}
 			}
 		}
@@ -187,16 +211,8 @@ func buildScheduleJobMail(mailMode, user, role string, jobObj *models.ScheduleJo
 	if err != nil {
 		return
 	}
-	if len(mailObj.Accept) == 0 {
+	if len(accept) == 0 {
 		err = fmt.Errorf("accept mail empty")
-		return
-	}
-	if jobObj.Status == models.JobStatusSuccess {
-		mailObj.Subject = fmt.Sprintf("Wecube Process Schedule Run %s,[%s][%s]", jobObj.Status, jobObj.ProcDefName, jobObj.EntityDataName)
-		mailObj.Content = mailObj.Subject + fmt.Sprintf("\nProcess Instance Id:%s \nStatus:%s \nTime:%s \n", jobObj.ProcInsId, jobObj.Status, jobObj.CreatedTime)
-	} else if jobObj.NodeStatus == models.JobStatusFail {
-		mailObj.Subject = fmt.Sprintf("Wecube Process Schedule Run Fail,[%s][%s]", jobObj.ProcDefName, jobObj.EntityDataName)
-		mailObj.Content = mailObj.Subject + fmt.Sprintf("\nProcess Instance Id:%s \nStatus:%s \nTime:%s \n", jobObj.ProcInsId, jobObj.Status, jobObj.CreatedTime) + fmt.Sprintf("\nNode [%s] %s", jobObj.NodeName, jobObj.NodeStatus)
 	}
 	return
 }
@@ -330,3 +346,154 @@ func doTransOldEventToNew() {
 	}
 	log.Logger.Debug("Done trans proc event job")
 }
+
+// StartHumanTaskEscalation 每分钟检查人工任务升级和提醒
+func StartHumanTaskEscalation() {
+	t := time.NewTicker(time.Minute).C
+	for {
+		<-t
+		ctx := context.WithValue(context.Background(), models.TransactionIdHeader, fmt.Sprintf("human_task_escalation_%d", time.Now().Unix()))
+		execution.HandleHumanTaskEscalation(ctx)
+	}
+}
+
+// StartProcSlaCheck 每分钟检查编排实例和节点是否超过时限
+func StartProcSlaCheck() {
+	t := time.NewTicker(time.Minute).C
+	for {
+		<-t
+		ctx := context.WithValue(context.Background(), models.TransactionIdHeader, fmt.Sprintf("proc_sla_check_%d", time.Now().Unix()))
+		execution.HandleProcSlaCheck(ctx)
+	}
+}
+
+// StartPluginHealthCheck 按配置间隔检查插件实例健康状态
+func StartPluginHealthCheck() {
+	if models.Config.Plugin.HealthCheck == nil || !models.Config.Plugin.HealthCheck.Enable {
+		return
+	}
+	t := time.NewTicker(time.Duration(execution.GetPluginHealthCheckConfig().IntervalSeconds) * time.Second).C
+	for {
+		<-t
+		ctx := context.WithValue(context.Background(), models.TransactionIdHeader, fmt.Sprintf("plugin_health_check_%d", time.Now().Unix()))
+		execution.HandlePluginHealthCheck(ctx)
+	}
+}
+
+func StartSendBatchExecScheduleMail() {
+	t := time.NewTicker(time.Minute).C
+	for {
+		<-t
+		doSendBatchExecScheduleMail()
+	}
+}
+
+// doSendBatchExecScheduleMail 定时批量执行结束后给创建人或管理角色发通知邮件
+func doSendBatchExecScheduleMail() {
+	log.Logger.Debug("start check batch execution schedule job mail")
+	// 更新 mail status是sending状态但更新时间小于当前1分钟的，可能是之前实例占用了但没发送成功
+	lastMinuteTime := time.Unix(time.Now().Unix()-60, 0)
+	if _, resetErr := db.MysqlEngine.Exec("update batch_exec_schedule_job set mail_status='wait' where mail_status='sending' and updated_time<?", lastMinuteTime); resetErr != nil {
+		log.Logger.Error("sendBatchExecScheduleMail try to reset sending status job fail", log.Error(resetErr))
+	}
+	var jobList []*models.BatchExecScheduleJobMailQueryObj
+	err := db.MysqlEngine.SQL("select t1.id,t1.schedule_config_id,t1.status,t1.error_msg,t1.entity_num,t1.batch_execution_id,t2.name as batch_exec_name,t2.error_code,t2.error_message,t1.created_time from batch_exec_schedule_job t1 left join batch_execution t2 on t1.batch_execution_id=t2.id where t1.mail_status='wait' and (t1.status='fail' or (t1.status='done' and t2.error_code<>?))", models.BatchExecErrorCodePending).Find(&jobList)
+	if err != nil {
+		log.Logger.Error("sendBatchExecScheduleMail fail with query schedule job table", log.Error(err))
+		return
+	}
+	if len(jobList) == 0 {
+		return
+	}
+	var configList []*models.BatchExecScheduleConfig
+	err = db.MysqlEngine.SQL("select id,batch_execution_template_name,mail_mode,created_by,`role` from batch_exec_schedule_config where mail_mode in ('user','role')").Find(&configList)
+	if err != nil {
+		log.Logger.Error("sendBatchExecScheduleMail fail with query schedule config table", log.Error(err))
+		return
+	}
+	configMap := make(map[string]*models.BatchExecScheduleConfig)
+	for _, row := range configList {
+		configMap[row.Id] = row
+	}
+	for _, v := range jobList {
+		configObj, ok := configMap[v.ScheduleConfigId]
+		if !ok || !tryUpdateBatchExecScheduleJobMail(v.Id) {
+			continue
+		}
+		tmpMail := models.SendMailTarget{}
+		tmpAccept, tmpErr := getScheduleMailAccept(configObj.MailMode, configObj.CreatedBy, configObj.Role)
+		if tmpErr != nil {
+			log.Logger.Error("build batch execution schedule job mail fail", log.String("jobId", v.Id), log.Error(tmpErr))
+		} else {
+			tmpMail = buildBatchExecScheduleJobMail(configObj.BatchExecutionTemplateName, v)
+			tmpMail.Accept = tmpAccept
+			if tmpErr = remote.SendSmtpMail(tmpMail); tmpErr != nil {
+				log.Logger.Error("batch execution schedule job send smtp mail fail", log.String("jobId", v.Id), log.Error(tmpErr))
+			}
+		}
+		if tmpErr != nil {
+			updateBatchExecScheduleJobMail(v.Id, "fail", tmpErr.Error())
+		} else {
+			tmpMailBytes, _ := json.Marshal(&tmpMail)
+			updateBatchExecScheduleJobMail(v.Id, "done", string(tmpMailBytes))
+		}
+	}
+	log.Logger.Debug("done check batch execution schedule job mail")
+}
+
+func buildBatchExecScheduleJobMail(templateName string, jobObj *models.BatchExecScheduleJobMailQueryObj) (mailObj models.SendMailTarget) {
+	if jobObj.Status == "fail" {
+		mailObj.Subject = fmt.Sprintf("Wecube Batch Execution Schedule Run Fail,[%s]", templateName)
+		mailObj.Content = mailObj.Subject + fmt.Sprintf("\nSchedule Job Id:%s \nTime:%s \nError:%s \n", jobObj.Id, jobObj.CreatedTime, jobObj.ErrorMsg)
+		return
+	}
+	statusMap := map[string]string{
+		models.BatchExecErrorCodeSucceed:        "Success",
+		models.BatchExecErrorCodeFailed:         "Fail",
+		models.BatchExecErrorCodeDangerousBlock: "Dangerous Block",
+		models.BatchExecErrorCodeCanceled:       "Canceled",
+	}
+	mailObj.Subject = fmt.Sprintf("Wecube Batch Execution Schedule Run %s,[%s][%s]", statusMap[jobObj.ErrorCode], templateName, jobObj.BatchExecName)
+	mailObj.Content = mailObj.Subject + fmt.Sprintf("\nBatch Execution Id:%s \nEntity Num:%d \nTime:%s \n", jobObj.BatchExecutionId, jobObj.EntityNum, jobObj.CreatedTime)
+	if jobObj.ErrorMessage != "" {
+		mailObj.Content = mailObj.Content + fmt.Sprintf("Message:%s \n", jobObj.ErrorMessage)
+	}
+	return
+}
+
+func tryUpdateBatchExecScheduleJobMail(jobId string) bool {
+	execResult, err := db.MysqlEngine.Exec("update batch_exec_schedule_job set mail_status='sending',updated_time=? where id=? and mail_status='wait'", time.Now(), jobId)
+	if err != nil {
+		log.Logger.Error("tryUpdateBatchExecScheduleJobMail fail with exec sql", log.String("jobId", jobId), log.Error(err))
+		return false
+	}
+	affectNum, _ := execResult.RowsAffected()
+	return affectNum > 0
+}
+
+func updateBatchExecScheduleJobMail(jobId, mailStatus, mailMessage string) {
+	_, err := db.MysqlEngine.Exec("update batch_exec_schedule_job set mail_status=?,mail_msg=? where id=?", mailStatus, mailMessage, jobId)
+	if err != nil {
+		log.Logger.Error("updateBatchExecScheduleJobMail fail", log.String("jobId", jobId), log.String("mailStatus", mailStatus), log.Error(err))
+	}
+}
+
+// StartProcSearchIndexBackfill 每10分钟补建漏掉的编排执行纪录索引
+func StartProcSearchIndexBackfill() {
+	t := time.NewTicker(10 * time.Minute).C
+	for {
+		<-t
+		ctx := context.WithValue(context.Background(), models.TransactionIdHeader, fmt.Sprintf("proc_search_backfill_%d", time.Now().Unix()))
+		execution.HandleProcSearchIndexBackfill(ctx)
+	}
+}
+
+// StartProcSearchIndexCleanup 每天清理已删除或归档的编排实例留下的索引
+func StartProcSearchIndexCleanup() {
+	t := time.NewTicker(24 * time.Hour).C
+	for {
+		<-t
+		ctx := context.WithValue(context.Background(), models.TransactionIdHeader, fmt.Sprintf("proc_search_cleanup_%d", time.Now().Unix()))
+		execution.HandleProcSearchIndexCleanup(ctx)
+	}
+}
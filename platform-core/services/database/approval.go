@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+// GetApprovalRunNode 编排实例下审批节点对应的任务节点
+func GetApprovalRunNode(ctx context.Context, procInsId, procInsNodeId string) (runNode *models.ProcRunNode, err error) {
+	var runNodeRows []*models.ProcRunNode
+	err = db.MysqlEngine.Context(ctx).SQL("select t1.* from proc_run_node t1 join proc_run_workflow t2 on t1.workflow_id=t2.id where t2.proc_ins_id=? and t1.proc_ins_node_id=?", procInsId, procInsNodeId).Find(&runNodeRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(runNodeRows) == 0 {
+		err = exterror.Catch(exterror.New().DatabaseQueryEmptyError, fmt.Errorf("proc_run_node proc_ins_id=%s proc_ins_node_id=%s", procInsId, procInsNodeId))
+		return
+	}
+	runNode = runNodeRows[0]
+	return
+}
+
+// GetApprovalVotes 审批节点本次运行的投票,循环重跑时只取本次运行开始时间的
+func GetApprovalVotes(ctx context.Context, procRunNodeId string, runStartTime time.Time) (result []*models.ProcRunApprovalVote, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select * from proc_run_approval_vote where proc_run_node_id=? and run_start_time=? order by id", procRunNodeId, runStartTime.Truncate(time.Second)).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+// AddApprovalVote 纪录投票,同一次运行同一阶段一个用户只能投一票,并发重复投票时ok为false
+func AddApprovalVote(ctx context.Context, vote *models.ProcRunApprovalVote) (ok bool, err error) {
+	execResult, execErr := db.MysqlEngine.Context(ctx).Exec("insert ignore into proc_run_approval_vote(proc_run_node_id,proc_ins_node_id,proc_ins_id,stage,stage_name,voter,`role`,decision,`comment`,run_start_time,created_time) values (?,?,?,?,?,?,?,?,?,?,?)",
+		vote.ProcRunNodeId, vote.ProcInsNodeId, vote.ProcInsId, vote.Stage, vote.StageName, vote.Voter, vote.Role, vote.Decision, vote.Comment, vote.RunStartTime, vote.CreatedTime)
+	if execErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, execErr)
+		return
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum > 0 {
+		ok = true
+	}
+	return
+}
+
+// GetProcInsApprovalVotes 编排实例下所有审批节点的投票,按编排节点分组
+func GetProcInsApprovalVotes(ctx context.Context, procInsId string) (voteMap map[string][]*models.ProcRunApprovalVote, err error) {
+	var voteRows []*models.ProcRunApprovalVote
+	err = db.MysqlEngine.Context(ctx).SQL("select * from proc_run_approval_vote where proc_ins_id=? order by id", procInsId).Find(&voteRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	voteMap = make(map[string][]*models.ProcRunApprovalVote)
+	for _, row := range voteRows {
+		voteMap[row.ProcInsNodeId] = append(voteMap[row.ProcInsNodeId], row)
+	}
+	return
+}
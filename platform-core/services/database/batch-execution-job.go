@@ -0,0 +1,280 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+// CreateBatchExecPendingJobs 执行前按实体生成待执行纪录,重复的实体只保留一条
+func CreateBatchExecPendingJobs(ctx context.Context, batchExecId string, reqParam *models.BatchExecRun, execTime time.Time) (jobs []*models.BatchExecutionJobs, err error) {
+	var actions []*db.ExecAction
+	existMap := make(map[string]bool)
+	for _, resourceData := range reqParam.ResourceDatas {
+		if existMap[resourceData.Id] {
+			continue
+		}
+		existMap[resourceData.Id] = true
+		job := &models.BatchExecutionJobs{
+			Id:                      guid.CreateGuid(),
+			BatchExecutionId:        batchExecId,
+			PackageName:             reqParam.PackageName,
+			EntityName:              reqParam.EntityName,
+			BusinessKey:             resourceData.BusinessKeyValue,
+			RootEntityId:            resourceData.Id,
+			ExecuteTime:             &execTime,
+			ErrorCode:               models.BatchExecErrorCodePending,
+			PluginConfigInterfaceId: reqParam.PluginConfigInterface.Id,
+		}
+		jobs = append(jobs, job)
+		actions = append(actions, &db.ExecAction{Sql: "insert into batch_exec_jobs(id,batch_execution_id,package_name,entity_name,business_key,root_entity_id,execute_time,error_code,plugin_config_interface_id) values (?,?,?,?,?,?,?,?,?)", Param: []interface{}{
+			job.Id, job.BatchExecutionId, job.PackageName, job.EntityName, job.BusinessKey, job.RootEntityId, execTime, job.ErrorCode, job.PluginConfigInterfaceId,
+		}})
+	}
+	if len(actions) == 0 {
+		err = fmt.Errorf("resourceDatas can not be empty")
+		return
+	}
+	if err = db.Transaction(actions, ctx); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// UpdateBatchExecJobResults 纪录一个分片里各实体的执行结果
+func UpdateBatchExecJobResults(ctx context.Context, jobs []*models.BatchExecutionJobs) (err error) {
+	var actions []*db.ExecAction
+	for _, job := range jobs {
+		actions = append(actions, &db.ExecAction{Sql: "update batch_exec_jobs set execute_time=?,complete_time=?,error_code=?,error_message=?,input_json=?,return_json=? where id=?", Param: []interface{}{
+			job.ExecuteTime, job.CompleteTime, job.ErrorCode, job.ErrorMessage, job.InputJson, job.ReturnJson, job.Id,
+		}})
+	}
+	if len(actions) == 0 {
+		return
+	}
+	if err = db.Transaction(actions, ctx); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// GetBatchExecErrorCode 批量执行的当前状态,用于执行中判断是否已被取消
+func GetBatchExecErrorCode(ctx context.Context, batchExecId string) (errorCode string, err error) {
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select error_code from batch_execution where id=?", batchExecId)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	if len(queryRows) == 0 {
+		err = exterror.Catch(exterror.New().DatabaseQueryEmptyError, fmt.Errorf("batch_execution"))
+		return
+	}
+	errorCode = queryRows[0]["error_code"]
+	return
+}
+
+// FinishBatchExec 全部分片执行完后更新整体结果,已取消的不再覆盖
+func FinishBatchExec(ctx context.Context, batchExecId, errorCode, errorMessage, operator string) (err error) {
+	_, err = db.MysqlEngine.Context(ctx).Exec("update batch_execution set error_code=?,error_message=?,updated_by=?,updated_time=? where id=? and error_code=?",
+		errorCode, errorMessage, operator, time.Now(), batchExecId, models.BatchExecErrorCodePending)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// FailBatchExec 执行中断时把批量执行置为失败,未执行的实体置为取消,重试时会重新执行
+func FailBatchExec(ctx context.Context, batchExecId, operator, message string) (err error) {
+	var actions []*db.ExecAction
+	actions = append(actions, &db.ExecAction{Sql: "update batch_execution set error_code=?,error_message=?,updated_by=?,updated_time=? where id=? and error_code=?", Param: []interface{}{
+		models.BatchExecErrorCodeFailed, message, operator, time.Now(), batchExecId, models.BatchExecErrorCodePending,
+	}})
+	actions = append(actions, &db.ExecAction{Sql: "update batch_exec_jobs set error_code=?,error_message=? where batch_execution_id=? and error_code=?", Param: []interface{}{
+		models.BatchExecErrorCodeCanceled, message, batchExecId, models.BatchExecErrorCodePending,
+	}})
+	if err = db.Transaction(actions, ctx); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// SetBatchExecHost 纪录执行批量执行的实例
+func SetBatchExecHost(ctx context.Context, batchExecId, host string) (err error) {
+	if _, err = db.MysqlEngine.Context(ctx).Exec("update batch_execution set exec_host=? where id=?", host, batchExecId); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// FailInterruptedBatchExec 实例启动时本实例纪录的执行中批量执行都已中断,逐个置为失败
+func FailInterruptedBatchExec(ctx context.Context, host, message string) (batchExecIds []string, err error) {
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select id,updated_by,created_by from batch_execution where exec_host=? and error_code=?", host, models.BatchExecErrorCodePending)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	for _, row := range queryRows {
+		operator := row["updated_by"]
+		if operator == "" {
+			operator = row["created_by"]
+		}
+		if err = FailBatchExec(ctx, row["id"], operator, message); err != nil {
+			return
+		}
+		batchExecIds = append(batchExecIds, row["id"])
+	}
+	return
+}
+
+// CancelBatchExec 取消执行中的批量执行,未执行的实体标记为已取消,返回是否取消成功
+func CancelBatchExec(ctx context.Context, batchExecId, operator, message string) (ok bool, err error) {
+	nowTime := time.Now()
+	var actions []*db.ExecAction
+	actions = append(actions, &db.ExecAction{Sql: "update batch_execution set error_code=?,error_message=?,updated_by=?,updated_time=? where id=? and error_code=?", Param: []interface{}{
+		models.BatchExecErrorCodeCanceled, message, operator, nowTime, batchExecId, models.BatchExecErrorCodePending,
+	}, CheckAffectRow: true})
+	actions = append(actions, &db.ExecAction{Sql: "update batch_exec_jobs set error_code=?,error_message=? where batch_execution_id=? and error_code=?", Param: []interface{}{
+		models.BatchExecErrorCodeCanceled, "canceled", batchExecId, models.BatchExecErrorCodePending,
+	}})
+	if err = db.Transaction(actions, ctx); err != nil {
+		// 状态不是执行中时第一条更新不到数据
+		if errorCode, getErr := GetBatchExecErrorCode(ctx, batchExecId); getErr == nil && errorCode != models.BatchExecErrorCodePending {
+			err = nil
+			return
+		}
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+		return
+	}
+	ok = true
+	return
+}
+
+// GetBatchExecRetryJobs 失败和取消未执行的实体,重试时只执行这些,批量执行已结束时还是待执行的实体是结果没纪录成功,也一起重试
+func GetBatchExecRetryJobs(ctx context.Context, batchExecId string) (jobs []*models.BatchExecutionJobs, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select * from batch_exec_jobs where batch_execution_id=? and error_code in (?,?,?)", batchExecId, models.BatchExecErrorCodeFailed, models.BatchExecErrorCodeCanceled, models.BatchExecErrorCodePending).Find(&jobs)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+// ResetBatchExecRetryJobs 重试前把实体重置为待执行,只有已结束的批量执行可以重试
+func ResetBatchExecRetryJobs(ctx context.Context, batchExecId, operator string, jobs []*models.BatchExecutionJobs) (err error) {
+	nowTime := time.Now()
+	var actions []*db.ExecAction
+	actions = append(actions, &db.ExecAction{Sql: "update batch_execution set error_code=?,error_message=?,updated_by=?,updated_time=? where id=? and error_code in (?,?)", Param: []interface{}{
+		models.BatchExecErrorCodePending, "", operator, nowTime, batchExecId, models.BatchExecErrorCodeFailed, models.BatchExecErrorCodeCanceled,
+	}, CheckAffectRow: true})
+	for _, job := range jobs {
+		actions = append(actions, &db.ExecAction{Sql: "update batch_exec_jobs set error_code=?,error_message=null,complete_time=null,return_json=null where id=? and error_code in (?,?,?)", Param: []interface{}{
+			models.BatchExecErrorCodePending, job.Id, models.BatchExecErrorCodeFailed, models.BatchExecErrorCodeCanceled, models.BatchExecErrorCodePending,
+		}})
+		job.ErrorCode = models.BatchExecErrorCodePending
+		job.ErrorMessage = ""
+		job.CompleteTime = nil
+	}
+	if err = db.Transaction(actions, ctx); err != nil {
+		err = fmt.Errorf("batch execution %s is not finished or already retrying", batchExecId)
+	}
+	return
+}
+
+// GetSimpleBatchExec 只查批量执行纪录,不带实体结果
+func GetSimpleBatchExec(ctx context.Context, batchExecId string) (result *models.BatchExecution, err error) {
+	var batchExecRows []*models.BatchExecution
+	if err = db.MysqlEngine.Context(ctx).SQL("select * from batch_execution where id=?", batchExecId).Find(&batchExecRows); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(batchExecRows) == 0 {
+		err = exterror.Catch(exterror.New().DatabaseQueryEmptyError, fmt.Errorf("batch_execution"))
+		return
+	}
+	result = batchExecRows[0]
+	if result.ConfigDataStr != "" {
+		configData := models.BatchExecRun{}
+		if err = json.Unmarshal([]byte(result.ConfigDataStr), &configData); err != nil {
+			err = fmt.Errorf("unmarshal batchExec: %s configData error: %s", result.Id, err.Error())
+			return
+		}
+		result.ConfigData = &configData
+	}
+	return
+}
+
+// CheckBatchExecAccess 批量执行结果只有创建人可以导出,定时触发的还允许定时配置的管理角色
+func CheckBatchExecAccess(ctx context.Context, batchExec *models.BatchExecution, operator string, roleList []string) (err error) {
+	if batchExec.CreatedBy == operator {
+		return
+	}
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select t2.`role` from batch_exec_schedule_job t1 join batch_exec_schedule_config t2 on t1.schedule_config_id=t2.id where t1.batch_execution_id=?", batchExec.Id)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	for _, row := range queryRows {
+		for _, role := range roleList {
+			if row["role"] != "" && row["role"] == role {
+				return
+			}
+		}
+	}
+	err = exterror.New().DataPermissionDeny
+	return
+}
+
+// GetBatchExecProgress 按实体状态统计批量执行进度
+func GetBatchExecProgress(ctx context.Context, batchExecId string) (result *models.BatchExecProgress, err error) {
+	batchExec, getErr := GetSimpleBatchExec(ctx, batchExecId)
+	if getErr != nil {
+		err = getErr
+		return
+	}
+	result = &models.BatchExecProgress{BatchExecId: batchExecId, ErrorCode: batchExec.ErrorCode, ErrorMessage: batchExec.ErrorMessage}
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select error_code,count(1) as num from batch_exec_jobs where batch_execution_id=? group by error_code", batchExecId)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	for _, row := range queryRows {
+		num, _ := strconv.Atoi(row["num"])
+		result.Total += num
+		switch row["error_code"] {
+		case models.BatchExecErrorCodeSucceed:
+			result.Succeed += num
+		case models.BatchExecErrorCodePending:
+			result.Running += num
+		case models.BatchExecErrorCodeCanceled:
+			result.Canceled += num
+		default:
+			result.Failed += num
+		}
+	}
+	return
+}
+
+// IterateBatchExecExportJobs 逐条遍历导出用的实体结果,onlyFailed时只查失败的,避免一次把全部结果加载到内存
+func IterateBatchExecExportJobs(ctx context.Context, batchExecId string, onlyFailed bool, handle func(job *models.BatchExecutionJobs) error) (err error) {
+	sql := "select * from batch_exec_jobs where batch_execution_id=?"
+	params := []interface{}{batchExecId}
+	if onlyFailed {
+		sql += " and error_code=?"
+		params = append(params, models.BatchExecErrorCodeFailed)
+	}
+	var handleErr error
+	err = db.MysqlEngine.Context(ctx).SQL(sql+" order by execute_time,business_key", params...).Iterate(new(models.BatchExecutionJobs), func(idx int, bean interface{}) error {
+		handleErr = handle(bean.(*models.BatchExecutionJobs))
+		return handleErr
+	})
+	if err != nil && handleErr == nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
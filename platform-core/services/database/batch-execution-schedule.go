@@ -0,0 +1,275 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+func CreateBatchExecSchedule(ctx context.Context, param *models.CreateBatchExecScheduleParam) (result *models.BatchExecScheduleConfig, err error) {
+	entityFilters := ""
+	if len(param.EntityFilters) > 0 {
+		filterBytes, _ := json.Marshal(param.EntityFilters)
+		entityFilters = string(filterBytes)
+	}
+	result = &models.BatchExecScheduleConfig{
+		Id:                         "bsc_" + guid.CreateGuid(),
+		BatchExecutionTemplateId:   param.BatchExecutionTemplateId,
+		BatchExecutionTemplateName: param.TemplateName,
+		EntityFilters:              entityFilters,
+		Status:                     models.ScheduleStatusReady,
+		ScheduleMode:               param.ScheduleMode,
+		ScheduleExpr:               param.ScheduleExpr,
+		CronExpr:                   param.CronExpr,
+		Role:                       param.Role,
+		MailMode:                   param.MailMode,
+		CreatedBy:                  param.Operator,
+		CreatedTime:                time.Now(),
+	}
+	_, err = db.MysqlEngine.Context(ctx).Exec("insert into batch_exec_schedule_config(id,batch_execution_template_id,batch_execution_template_name,entity_filters,status,schedule_mode,schedule_expr,cron_expr,exec_times,`role`,mail_mode,created_by,created_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?)",
+		result.Id, result.BatchExecutionTemplateId, result.BatchExecutionTemplateName, result.EntityFilters, result.Status, result.ScheduleMode, result.ScheduleExpr, result.CronExpr, 0, result.Role, result.MailMode, result.CreatedBy, result.CreatedTime)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+func DeleteBatchExecSchedule(ctx context.Context, id string) (err error) {
+	_, err = db.MysqlEngine.Context(ctx).Exec("delete from batch_exec_schedule_config where id=?", id)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// GetNewBatchExecScheduleList 获取1小时内新增加的批量执行定时配置
+func GetNewBatchExecScheduleList() (result []*models.BatchExecScheduleConfig, err error) {
+	lastHourTime := time.Unix(time.Now().Unix()-3600, 0)
+	err = db.MysqlEngine.SQL("select * from batch_exec_schedule_config where status<>'Deleted' and created_time>?", lastHourTime).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+func GetBatchExecScheduleLoadList() (result []*models.BatchExecScheduleConfig, err error) {
+	err = db.MysqlEngine.SQL("select * from batch_exec_schedule_config where status<>'Deleted'").Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+// UpdateBatchExecScheduleStatus 创建人或管理角色才能修改定时配置状态
+func UpdateBatchExecScheduleStatus(ctx context.Context, id, status, operator string, roleList []string) (err error) {
+	if err = CheckBatchExecScheduleAccess(ctx, id, operator, roleList); err != nil {
+		return
+	}
+	_, err = db.MysqlEngine.Context(ctx).Exec("update batch_exec_schedule_config set status=?,updated_by=?,updated_time=? where id=?", status, operator, time.Now(), id)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// CheckBatchExecScheduleAccess 定时配置只有创建人和管理角色可以查看和操作
+func CheckBatchExecScheduleAccess(ctx context.Context, id, operator string, roleList []string) (err error) {
+	var configRows []*models.BatchExecScheduleConfig
+	err = db.MysqlEngine.Context(ctx).SQL("select created_by,`role` from batch_exec_schedule_config where id=?", id).Find(&configRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(configRows) == 0 {
+		err = fmt.Errorf("can not find batch execution schedule config with id:%s ", id)
+		return
+	}
+	if operator != configRows[0].CreatedBy {
+		matchRoleFlag := false
+		for _, v := range roleList {
+			if v == configRows[0].Role {
+				matchRoleFlag = true
+				break
+			}
+		}
+		if !matchRoleFlag {
+			err = exterror.New().DataPermissionDeny
+		}
+	}
+	return
+}
+
+func GetBatchExecScheduleConfigStatus(ctx context.Context, id string) (status string) {
+	queryRows, err := db.MysqlEngine.Context(ctx).QueryString("select status from batch_exec_schedule_config where id=?", id)
+	if err != nil {
+		log.Logger.Error("GetBatchExecScheduleConfigStatus query status fail", log.String("id", id), log.Error(err))
+		return
+	}
+	if len(queryRows) > 0 {
+		status = queryRows[0]["status"]
+	}
+	return
+}
+
+func AddBatchExecScheduleExecTimes(ctx context.Context, id string) {
+	db.MysqlEngine.Context(ctx).Exec("update batch_exec_schedule_config set exec_times=exec_times+1 where id=?", id)
+}
+
+// NewBatchExecScheduleJob 多实例同时触发时以主键抢占,插入重复的实例不执行
+func NewBatchExecScheduleJob(ctx context.Context, config *models.BatchExecScheduleConfig, newId string) (duplicateRow bool, err error) {
+	mailStatus := "none"
+	if config.MailMode == "role" || config.MailMode == "user" {
+		mailStatus = "wait"
+	}
+	_, insertErr := db.MysqlEngine.Context(ctx).Exec("insert into batch_exec_schedule_job(id,schedule_config_id,status,handle_by,mail_status,created_time) values (?,?,?,?,?,?)",
+		newId, config.Id, "ready", models.Config.HostIp, mailStatus, time.Now())
+	if insertErr != nil {
+		if strings.Contains(strings.ToLower(insertErr.Error()), "duplicate") {
+			duplicateRow = true
+		} else {
+			err = exterror.Catch(exterror.New().DatabaseExecuteError, insertErr)
+		}
+	}
+	return
+}
+
+func UpdateBatchExecScheduleJob(ctx context.Context, jobId, status, errorMsg, batchExecId string, entityNum int) (err error) {
+	_, err = db.MysqlEngine.Context(ctx).Exec("update batch_exec_schedule_job set status=?,error_msg=?,batch_execution_id=?,entity_num=?,updated_time=? where id=?",
+		status, errorMsg, batchExecId, entityNum, time.Now(), jobId)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+func QueryBatchExecScheduleList(ctx context.Context, param *models.BatchExecScheduleQueryParam, operator string, roleList []string) (result []*models.BatchExecScheduleConfigObj, err error) {
+	var configRows []*models.BatchExecScheduleConfig
+	baseSql := "select * from batch_exec_schedule_config where status<>'Deleted' and (`role` in ('" + strings.Join(roleList, "','") + "') or created_by=?)"
+	filterParams := []interface{}{operator}
+	if param.ScheduleMode != "" {
+		baseSql += " and schedule_mode=?"
+		filterParams = append(filterParams, param.ScheduleMode)
+	}
+	if param.Owner != "" {
+		baseSql += " and created_by=?"
+		filterParams = append(filterParams, param.Owner)
+	}
+	if param.BatchExecutionTemplateId != "" {
+		baseSql += " and batch_execution_template_id=?"
+		filterParams = append(filterParams, param.BatchExecutionTemplateId)
+	}
+	baseSql += " order by created_time desc"
+	if err = db.MysqlEngine.Context(ctx).SQL(baseSql, filterParams...).Find(&configRows); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	result = []*models.BatchExecScheduleConfigObj{}
+	if len(configRows) == 0 {
+		return
+	}
+	var idList []string
+	for _, row := range configRows {
+		idList = append(idList, row.Id)
+	}
+	var countRows []*models.BatchExecScheduleQueryRow
+	err = db.MysqlEngine.Context(ctx).SQL("select t1.schedule_config_id as id,t1.status,t2.error_code,count(1) as num from batch_exec_schedule_job t1 left join batch_execution t2 on t1.batch_execution_id=t2.id where t1.schedule_config_id in ('" + strings.Join(idList, "','") + "') group by t1.schedule_config_id,t1.status,t2.error_code").Find(&countRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	resultMap := make(map[string]*models.BatchExecScheduleConfigObj)
+	for _, row := range configRows {
+		resultObj := &models.BatchExecScheduleConfigObj{BatchExecScheduleConfig: *row}
+		resultMap[row.Id] = resultObj
+		result = append(result, resultObj)
+	}
+	for _, row := range countRows {
+		resultObj := resultMap[row.Id]
+		if resultObj == nil {
+			continue
+		}
+		if row.Status == "fail" {
+			resultObj.TotalJobFailed += row.Num
+			continue
+		}
+		switch row.ErrorCode {
+		case models.BatchExecErrorCodeSucceed:
+			resultObj.TotalSucceed += row.Num
+		case models.BatchExecErrorCodePending:
+			resultObj.TotalRunning += row.Num
+		case models.BatchExecErrorCodeFailed, models.BatchExecErrorCodeDangerousBlock, models.BatchExecErrorCodeCanceled:
+			resultObj.TotalFailed += row.Num
+		}
+	}
+	return
+}
+
+// QueryBatchExecScheduleExecList 定时配置每次触发的纪录和对应的批量执行结果
+func QueryBatchExecScheduleExecList(ctx context.Context, scheduleConfigId string) (result []*models.BatchExecScheduleExecObj, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select t1.id,t1.status,t1.error_msg,t1.entity_num,t1.batch_execution_id,t2.error_code,t1.created_time from batch_exec_schedule_job t1 left join batch_execution t2 on t1.batch_execution_id=t2.id where t1.schedule_config_id=? order by t1.created_time desc", scheduleConfigId).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(result) == 0 {
+		result = []*models.BatchExecScheduleExecObj{}
+	}
+	return
+}
+
+// GetBatchExecTemplateUseRoles 有模板使用或管理权限的角色
+func GetBatchExecTemplateUseRoles(ctx context.Context, templateId string) (roles []string, err error) {
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select role_name from batch_execution_template_role where batch_execution_template_id=? and permission in (?,?)", templateId, models.PermissionTypeUSE, models.PermissionTypeMGMT)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	for _, row := range queryRows {
+		roles = append(roles, row["role_name"])
+	}
+	return
+}
+
+// GetPluginConfigUseRoles 有插件配置使用权限的角色
+func GetPluginConfigUseRoles(ctx context.Context, pluginConfigId string) (roles []string, err error) {
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select role_name from plugin_config_roles where plugin_cfg_id=? and perm_type=?", pluginConfigId, models.PermissionTypeUSE)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	for _, row := range queryRows {
+		roles = append(roles, row["role_name"])
+	}
+	return
+}
+
+// GetSimpleBatchExecTemplate 只查模板和配置数据,不处理收藏和角色
+func GetSimpleBatchExecTemplate(ctx context.Context, templateId string) (result *models.BatchExecutionTemplate, err error) {
+	var templateRows []*models.BatchExecutionTemplate
+	if err = db.MysqlEngine.Context(ctx).SQL("select * from batch_execution_template where id=?", templateId).Find(&templateRows); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(templateRows) == 0 {
+		err = fmt.Errorf("templateId: %s is invalid", templateId)
+		return
+	}
+	result = templateRows[0]
+	if result.ConfigDataStr != "" {
+		configData := models.BatchExecRun{}
+		if err = json.Unmarshal([]byte(result.ConfigDataStr), &configData); err != nil {
+			err = fmt.Errorf("unmarshal templateId: %s configData error: %s", result.Id, err.Error())
+			return
+		}
+		result.ConfigData = &configData
+	}
+	return
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -466,7 +467,7 @@ func RetrieveTemplate(c *gin.Context, reqParam *models.QueryRequestParam) (resul
 func UpdateTemplateRolesDisplayName(c *gin.Context, templateDataList []*models.BatchExecutionTemplate) (err error) {
 	userToken := c.GetHeader(models.AuthorizationHeader)
 	language := c.GetHeader(middleware.AcceptLanguageHeader)
-	respData, err := remote.RetrieveAllLocalRoles("Y", userToken, language, false)
+	respData, err := remote.RetrieveAllLocalRoles(c, "Y", userToken, language, false)
 	if err != nil {
 		err = fmt.Errorf("retrieve all local roles failed: %s", err.Error())
 		return
@@ -914,7 +915,9 @@ func RetrieveBatchExecJobs(c *gin.Context, reqParam *models.QueryRequestParam) (
 
 	for _, jobData := range batchExecJobsData {
 		jobData.ExecuteTimeStr = jobData.ExecuteTime.Format(models.DateTimeFormat)
-		jobData.CompleteTimeStr = jobData.CompleteTime.Format(models.DateTimeFormat)
+		if jobData.CompleteTime != nil {
+			jobData.CompleteTimeStr = jobData.CompleteTime.Format(models.DateTimeFormat)
+		}
 	}
 
 	result.Contents = batchExecJobsData
@@ -922,6 +925,11 @@ func RetrieveBatchExecJobs(c *gin.Context, reqParam *models.QueryRequestParam) (
 }
 
 func InsertBatchExec(c *gin.Context, reqParam *models.BatchExecRun) (batchExecId string, err error) {
+	return CreateBatchExec(c, reqParam, middleware.GetRequestUser(c))
+}
+
+// CreateBatchExec 新增批量执行纪录,定时触发时没有请求上下文,由调用方指定创建人
+func CreateBatchExec(ctx context.Context, reqParam *models.BatchExecRun, operator string) (batchExecId string, err error) {
 	var actions []*db.ExecAction
 	now := time.Now()
 
@@ -942,7 +950,7 @@ func InsertBatchExec(c *gin.Context, reqParam *models.BatchExecRun) (batchExecId
 		ErrorCode:                  models.BatchExecErrorCodePending,
 		ConfigDataStr:              configDataStr,
 		SourceData:                 reqParam.SourceData,
-		CreatedBy:                  middleware.GetRequestUser(c),
+		CreatedBy:                  operator,
 		UpdatedBy:                  "",
 		CreatedTime:                &now,
 		UpdatedTime:                &now,
@@ -954,7 +962,7 @@ func InsertBatchExec(c *gin.Context, reqParam *models.BatchExecRun) (batchExecId
 	}
 	actions = append(actions, action)
 
-	err = db.Transaction(actions, c)
+	err = db.Transaction(actions, ctx)
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
 		return
@@ -994,93 +1002,6 @@ func UpdateBatchExec(c *gin.Context, batchExecId string, updateData map[string]i
 	return
 }
 
-func InsertBatchExecJobs(c *gin.Context, batchExecId string, execTime *time.Time,
-	batchExecRunParam *models.BatchExecRun,
-	pluginCallParam *models.BatchExecutionPluginExecParam,
-	batchExecRunResult *models.PluginInterfaceApiResultData) (err error) {
-	var actions []*db.ExecAction
-	now := time.Now()
-
-	retJsonCallbackParameterMap := make(map[string]map[string]interface{})
-	for i, output := range batchExecRunResult.Outputs {
-		if v, isExisted := output[models.PluginCallResultPresetCallback]; isExisted {
-			if callbackParam, ok := v.(string); ok {
-				retJsonCallbackParameterMap[callbackParam] = batchExecRunResult.Outputs[i]
-			}
-		}
-	}
-
-	for i := range pluginCallParam.Inputs {
-		inputJson := ""
-		inputJsonByte, tmpErr := json.Marshal(pluginCallParam.Inputs[i])
-		if tmpErr == nil {
-			inputJson = string(inputJsonByte)
-		}
-		returnJson := ""
-		errCode := ""
-		errMsg := ""
-		/*
-			if len(batchExecRunResult.Outputs) > i {
-				returnJsonByte, tmpErr := json.Marshal(batchExecRunResult.Outputs[i])
-				if tmpErr == nil {
-					returnJson = string(returnJsonByte)
-				}
-				if batchExecRunResult.Outputs[i] != nil {
-					if tmpVal, isOk := batchExecRunResult.Outputs[i]["errorCode"].(string); isOk {
-						errCode = tmpVal
-					}
-					if tmpVal, isOk := batchExecRunResult.Outputs[i]["errorMessage"].(string); isOk {
-						errMsg = tmpVal
-					}
-				}
-			}
-		*/
-		if outputData, isExisted := retJsonCallbackParameterMap[pluginCallParam.EntityInstances[i].Id]; isExisted {
-			if outputData != nil {
-				returnJsonByte, tmpErr := json.Marshal(outputData)
-				if tmpErr == nil {
-					returnJson = string(returnJsonByte)
-				}
-				if tmpVal, isOk := outputData[models.PluginCallResultPresetErrorCode].(string); isOk {
-					errCode = tmpVal
-				}
-				if tmpVal, isOk := outputData[models.PluginCallResultPresetErrorMsg].(string); isOk {
-					errMsg = tmpVal
-				}
-			}
-		}
-
-		batchExecJobData := &models.BatchExecutionJobs{
-			Id:                      guid.CreateGuid(),
-			BatchExecutionId:        batchExecId,
-			PackageName:             batchExecRunParam.PackageName,
-			EntityName:              batchExecRunParam.EntityName,
-			BusinessKey:             pluginCallParam.EntityInstances[i].BusinessKeyValue,
-			RootEntityId:            pluginCallParam.EntityInstances[i].Id,
-			ExecuteTime:             execTime,
-			CompleteTime:            &now,
-			ErrorCode:               errCode,
-			ErrorMessage:            errMsg,
-			InputJson:               inputJson,
-			ReturnJson:              returnJson,
-			PluginConfigInterfaceId: batchExecRunParam.PluginConfigInterface.Id,
-		}
-		action, tmpErr := db.GetInsertTableExecAction(models.TableNameBatchExecJobs, *batchExecJobData, nil)
-		if tmpErr != nil {
-			err = fmt.Errorf("get insert sql failed: %s", tmpErr.Error())
-			return
-		}
-		actions = append(actions, action)
-	}
-
-	err = db.Transaction(actions, c)
-	if err != nil {
-		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
-		return
-	}
-	return
-}
-
 func GetPluginConfigRoles(c *gin.Context, pluginConfigId string) (result []*models.PluginConfigRoles, err error) {
 	var pluginConfigRolesData []*models.PluginConfigRoles
 	err = db.MysqlEngine.Context(c).Table(models.TableNamePluginConfigRoles).
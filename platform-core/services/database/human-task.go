@@ -0,0 +1,211 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+const humanTaskQuerySql = "select t1.*,t2.name as node_name,t3.proc_def_id,t3.proc_def_name,t3.entity_data_name from proc_run_human_task t1 join proc_run_node t2 on t1.proc_run_node_id=t2.id " +
+	"left join proc_ins t3 on t1.proc_ins_id=t3.id where t1.status='" + models.HumanTaskStatusPending + "' and t2.job_type='" + models.JobHumanType + "' and t2.status='" + models.JobStatusRunning + "'"
+
+// CreateHumanTask 新增人工任务,同一个任务节点已有待处理任务时不重复创建
+func CreateHumanTask(ctx context.Context, task *models.ProcRunHumanTask) (err error) {
+	existTask, getErr := GetPendingHumanTaskByRunNode(ctx, task.ProcRunNodeId)
+	if getErr != nil {
+		err = getErr
+		return
+	}
+	if existTask != nil {
+		return
+	}
+	var actions []*db.ExecAction
+	actions = append(actions, &db.ExecAction{Sql: "insert into proc_run_human_task(id,proc_run_node_id,proc_ins_node_id,proc_ins_id,assignee_type,assignee,status,due_time,escalate_time,escalation_role,escalated,remind_minutes,last_remind_time,created_time,updated_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{
+		task.Id, task.ProcRunNodeId, task.ProcInsNodeId, task.ProcInsId, task.AssigneeType, task.Assignee, task.Status, nullTime(task.DueTime), nullTime(task.EscalateTime), task.EscalationRole, false, task.RemindMinutes, task.CreatedTime, task.CreatedTime, task.CreatedTime,
+	}})
+	actions = append(actions, buildHumanTaskLogAction(task.Id, models.HumanTaskActionCreate, "", task.Assignee, "", "sys", task.CreatedTime))
+	if err = db.Transaction(actions, ctx); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// CompleteHumanTask 人工节点回调后完成任务
+func CompleteHumanTask(ctx context.Context, procRunNodeId, message string) (err error) {
+	task, getErr := GetPendingHumanTaskByRunNode(ctx, procRunNodeId)
+	if getErr != nil || task == nil {
+		err = getErr
+		return
+	}
+	nowTime := time.Now()
+	var actions []*db.ExecAction
+	actions = append(actions, &db.ExecAction{Sql: "update proc_run_human_task set status=?,completed_time=?,updated_time=? where id=?", Param: []interface{}{models.HumanTaskStatusDone, nowTime, nowTime, task.Id}})
+	actions = append(actions, buildHumanTaskLogAction(task.Id, models.HumanTaskActionComplete, task.Assignee, "", message, "sys", nowTime))
+	if err = db.Transaction(actions, ctx); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+func GetPendingHumanTaskByRunNode(ctx context.Context, procRunNodeId string) (task *models.ProcRunHumanTask, err error) {
+	var taskRows []*models.ProcRunHumanTask
+	err = db.MysqlEngine.Context(ctx).SQL("select * from proc_run_human_task where proc_run_node_id=? and status=?", procRunNodeId, models.HumanTaskStatusPending).Find(&taskRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(taskRows) > 0 {
+		task = taskRows[0]
+	}
+	return
+}
+
+func GetHumanTask(ctx context.Context, taskId string) (task *models.ProcRunHumanTask, err error) {
+	var taskRows []*models.ProcRunHumanTask
+	err = db.MysqlEngine.Context(ctx).SQL("select * from proc_run_human_task where id=?", taskId).Find(&taskRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(taskRows) == 0 {
+		err = exterror.Catch(exterror.New().DatabaseQueryEmptyError, fmt.Errorf("proc_run_human_task"))
+		return
+	}
+	task = taskRows[0]
+	return
+}
+
+// UpdateHumanTaskAssignee 转派、委托或升级任务,只更新仍是原处理人的待处理任务,返回是否更新成功
+func UpdateHumanTaskAssignee(ctx context.Context, task *models.ProcRunHumanTask, action, assigneeType, assignee, delegateFrom, message, operator string) (ok bool, err error) {
+	nowTime := time.Now()
+	sql := "update proc_run_human_task set assignee_type=?,assignee=?,delegate_from=?,updated_time=?"
+	params := []interface{}{assigneeType, assignee, delegateFrom, nowTime}
+	if action == models.HumanTaskActionEscalate {
+		sql = sql + ",escalated=1"
+	}
+	sql = sql + " where id=? and status=? and assignee_type=? and assignee=?"
+	params = append(params, task.Id, models.HumanTaskStatusPending, task.AssigneeType, task.Assignee)
+	session := db.MysqlEngine.NewSession().Context(ctx)
+	defer session.Close()
+	if err = session.Begin(); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+		return
+	}
+	execResult, execErr := session.Exec(append([]interface{}{sql}, params...)...)
+	if execErr != nil {
+		session.Rollback()
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, execErr)
+		return
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum == 0 {
+		session.Rollback()
+		return
+	}
+	logAction := buildHumanTaskLogAction(task.Id, action, task.Assignee, assignee, message, operator, nowTime)
+	if _, execErr = session.Exec(append([]interface{}{logAction.Sql}, logAction.Param...)...); execErr != nil {
+		session.Rollback()
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, execErr)
+		return
+	}
+	if err = session.Commit(); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+		return
+	}
+	ok = true
+	return
+}
+
+// RevertHumanTaskAssignee 通知插件失败时把处理人恢复成变更前的值,只有处理人还是变更后的值时才恢复
+func RevertHumanTaskAssignee(ctx context.Context, origin *models.ProcRunHumanTask, assigneeType, assignee, message string) (err error) {
+	nowTime := time.Now()
+	var actions []*db.ExecAction
+	actions = append(actions, &db.ExecAction{Sql: "update proc_run_human_task set assignee_type=?,assignee=?,delegate_from=?,escalated=?,updated_time=? where id=? and status=? and assignee_type=? and assignee=?", Param: []interface{}{
+		origin.AssigneeType, origin.Assignee, origin.DelegateFrom, origin.Escalated, nowTime, origin.Id, models.HumanTaskStatusPending, assigneeType, assignee,
+	}})
+	actions = append(actions, buildHumanTaskLogAction(origin.Id, models.HumanTaskActionRevert, assignee, origin.Assignee, message, "sys", nowTime))
+	if err = db.Transaction(actions, ctx); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// UpdateHumanTaskRemindTime 纪录提醒时间,多实例同时扫描时只有一个能更新成功
+func UpdateHumanTaskRemindTime(ctx context.Context, task *models.ProcRunHumanTask, remindTime time.Time) (ok bool, err error) {
+	lastRemindLimit := remindTime.Add(-time.Duration(task.RemindMinutes) * time.Minute)
+	execResult, execErr := db.MysqlEngine.Context(ctx).Exec("update proc_run_human_task set last_remind_time=? where id=? and last_remind_time<=?", remindTime, task.Id, lastRemindLimit)
+	if execErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, execErr)
+		return
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum > 0 {
+		ok = true
+		_, err = db.MysqlEngine.Context(ctx).Exec("insert into proc_run_human_task_log(task_id,`action`,to_assignee,created_by,created_time) values (?,?,?,?,?)", task.Id, models.HumanTaskActionRemind, task.Assignee, "sys", remindTime)
+	}
+	return
+}
+
+// GetUserPendingHumanTasks 用户的待办任务,包括直接分配给用户的和分配给用户所属角色的
+func GetUserPendingHumanTasks(ctx context.Context, user string, roles []string) (result []*models.HumanTaskQueryObj, err error) {
+	sql := humanTaskQuerySql + " and ((t1.assignee_type=? and t1.assignee=?)"
+	params := []interface{}{models.HumanTaskAssigneeUser, user}
+	if len(roles) > 0 {
+		sql = sql + " or (t1.assignee_type=? and t1.assignee in (" + strings.Repeat("?,", len(roles)-1) + "?))"
+		params = append(params, models.HumanTaskAssigneeRole)
+		for _, role := range roles {
+			params = append(params, role)
+		}
+	}
+	sql = sql + ") order by t1.created_time"
+	err = db.MysqlEngine.Context(ctx).SQL(sql, params...).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	nowTime := time.Now()
+	for _, row := range result {
+		row.Overdue = !row.DueTime.IsZero() && row.DueTime.Before(nowTime)
+	}
+	if len(result) == 0 {
+		result = []*models.HumanTaskQueryObj{}
+	}
+	return
+}
+
+// GetRunningPendingHumanTasks 所有运行中人工节点的待处理任务,用于升级和提醒
+func GetRunningPendingHumanTasks(ctx context.Context) (result []*models.HumanTaskQueryObj, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL(humanTaskQuerySql + " and ((t1.escalated=0 and t1.escalate_time is not null) or t1.remind_minutes>0)").Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+func GetHumanTaskLogs(ctx context.Context, taskId string) (result []*models.ProcRunHumanTaskLog, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select * from proc_run_human_task_log where task_id=? order by id", taskId).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(result) == 0 {
+		result = []*models.ProcRunHumanTaskLog{}
+	}
+	return
+}
+
+func buildHumanTaskLogAction(taskId, action, fromAssignee, toAssignee, message, operator string, createdTime time.Time) *db.ExecAction {
+	return &db.ExecAction{Sql: "insert into proc_run_human_task_log(task_id,`action`,from_assignee,to_assignee,message,created_by,created_time) values (?,?,?,?,?,?,?)", Param: []interface{}{
+		taskId, action, fromAssignee, toAssignee, message, operator, createdTime,
+	}}
+}
+
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/metric"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+func init() {
+	metric.RegisterGaugeFunc("platform_core_operation_queue_pending", "Pending operation count in proc_run_operation.", nil, func() []*metric.GaugeSample {
+		pendingNum, _ := getOperationQueueStatus()
+		return []*metric.GaugeSample{{Value: float64(pendingNum)}}
+	})
+	metric.RegisterGaugeFunc("platform_core_operation_queue_lag_seconds", "Wait seconds of the oldest pending operation in proc_run_operation.", nil, func() []*metric.GaugeSample {
+		_, lagSeconds := getOperationQueueStatus()
+		return []*metric.GaugeSample{{Value: lagSeconds}}
+	})
+	metric.RegisterGaugeFunc("platform_core_batch_executions", "Batch execution count by error code,0:success 1:fail 2:running.", []string{"error_code"}, getBatchExecutionCountSamples)
+}
+
+var (
+	operationQueueLock      sync.Mutex
+	operationQueueQueryTime time.Time
+	operationQueuePending   int
+	operationQueueLag       float64
+)
+
+// getOperationQueueStatus 待处理操作数和最早一条的等待时间,一次抓取里两个指标共用一次查询结果
+func getOperationQueueStatus() (pendingNum int, lagSeconds float64) {
+	if db.MysqlEngine == nil {
+		return
+	}
+	operationQueueLock.Lock()
+	defer operationQueueLock.Unlock()
+	if time.Since(operationQueueQueryTime) < time.Second {
+		return operationQueuePending, operationQueueLag
+	}
+	queryRows, err := db.MysqlEngine.QueryString("select count(1) as num,ifnull(timestampdiff(second,min(created_time),now()),0) as lag_seconds from proc_run_operation where status='wait'")
+	if err != nil {
+		log.Logger.Error("query operation queue metric fail", log.Error(err))
+		return
+	}
+	if len(queryRows) > 0 {
+		pendingNum, _ = strconv.Atoi(queryRows[0]["num"])
+		lagSeconds, _ = strconv.ParseFloat(queryRows[0]["lag_seconds"], 64)
+	}
+	operationQueueQueryTime, operationQueuePending, operationQueueLag = time.Now(), pendingNum, lagSeconds
+	return
+}
+
+func getBatchExecutionCountSamples() (result []*metric.GaugeSample) {
+	if db.MysqlEngine == nil {
+		return
+	}
+	queryRows, err := db.MysqlEngine.QueryString("select error_code,count(1) as num from " + models.TableNameBatchExec + " group by error_code")
+	if err != nil {
+		log.Logger.Error("query batch execution metric fail", log.Error(err))
+		return
+	}
+	for _, row := range queryRows {
+		num, _ := strconv.Atoi(row["num"])
+		result = append(result, &metric.GaugeSample{LabelValues: []string{row["error_code"]}, Value: float64(num)})
+	}
+	return
+}
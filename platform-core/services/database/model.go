@@ -93,6 +93,17 @@ func GetDataModels(ctx context.Context, pluginPackage string, withAttr bool) (re
 	return
 }
 
+// CheckEntityModelExist 实体在最新版本数据模型中是否存在
+func CheckEntityModelExist(ctx context.Context, packageName, entityName string) (exist bool, err error) {
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select id from plugin_package_entities where package_name=? and name=? and data_model_id in (select id from plugin_package_data_model where concat(package_name,'_',`version`) in (select concat(package_name,'_',max(`version`)) from plugin_package_data_model group by package_name)) limit 1", packageName, entityName)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	exist = len(queryRows) > 0
+	return
+}
+
 func GetEntityModel(ctx context.Context, packageName, entityName string, onlyAttr bool) (result *models.DataModelEntity, err error) {
 	var entityRows []*models.PluginPackageEntities
 	err = db.MysqlEngine.Context(ctx).SQL("select * from plugin_package_entities where package_name=? and name=? and data_model_id in (select id from plugin_package_data_model where concat(package_name,'_',`version`) in (select concat(package_name,'_',max(`version`)) from plugin_package_data_model group by package_name))", packageName, entityName).Find(&entityRows)
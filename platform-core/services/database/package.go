@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -229,8 +230,8 @@ func UploadPackage(ctx context.Context, registerConfig *models.RegisterXML, with
 			depId, pluginPackageId, dependence.Name, dependence.Version,
 		}})
 	}
-	actions = append(actions, &db.ExecAction{Sql: "insert into plugin_package_runtime_resources_docker (id,plugin_package_id,image_name,container_name,port_bindings,volume_bindings,env_variables) values (?,?,?,?,?,?,?)", Param: []interface{}{
-		"p_res_docker_" + guid.CreateGuid(), pluginPackageId, registerConfig.ResourceDependencies.Docker.ImageName, registerConfig.ResourceDependencies.Docker.ContainerName, registerConfig.ResourceDependencies.Docker.PortBindings, registerConfig.ResourceDependencies.Docker.VolumeBindings, registerConfig.ResourceDependencies.Docker.EnvVariables,
+	actions = append(actions, &db.ExecAction{Sql: "insert into plugin_package_runtime_resources_docker (id,plugin_package_id,image_name,container_name,port_bindings,volume_bindings,env_variables,health_check_path) values (?,?,?,?,?,?,?,?)", Param: []interface{}{
+		"p_res_docker_" + guid.CreateGuid(), pluginPackageId, registerConfig.ResourceDependencies.Docker.ImageName, registerConfig.ResourceDependencies.Docker.ContainerName, registerConfig.ResourceDependencies.Docker.PortBindings, registerConfig.ResourceDependencies.Docker.VolumeBindings, registerConfig.ResourceDependencies.Docker.EnvVariables, registerConfig.ResourceDependencies.Docker.HealthCheckPath,
 	}})
 	if registerConfig.ResourceDependencies.Mysql.Schema != "" {
 		actions = append(actions, &db.ExecAction{Sql: "INSERT INTO plugin_package_runtime_resources_mysql (id,plugin_package_id,schema_name,init_file_name,upgrade_file_name) values (?,?,?,?,?)", Param: []interface{}{
@@ -341,8 +342,13 @@ func CheckPluginPackageDependence(ctx context.Context, pluginPackageId string) (
 		depPluginVersionName = append(depPluginVersionName, row.DependencyPackageName)
 	}
 	if depPlatformVersion != "" {
-		if tools.CompareVersion(depPlatformVersion, models.Config.Version) {
-			err = fmt.Errorf("platform version required %s > %s", depPlatformVersion, models.Config.Version)
+		platformConstraint, parseErr := tools.ParseVersionConstraint(depPlatformVersion)
+		if parseErr != nil {
+			err = parseErr
+			return
+		}
+		if !platformConstraint.Match(models.Config.Version) {
+			err = fmt.Errorf("platform version required %s,current %s", depPlatformVersion, models.Config.Version)
 			return
 		}
 	}
@@ -357,30 +363,47 @@ func CheckPluginPackageDependence(ctx context.Context, pluginPackageId string) (
 		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
 		return
 	}
-	for _, row := range pluginPackageRows {
-		if v, b := depPluginVersionMap[row.Name]; b {
-			if tools.CompareVersion(row.Version, v) {
-				delete(depPluginVersionMap, row.Name)
+	err = matchPluginDependencyVersions(depPluginVersionMap, pluginPackageRows)
+	ok = err == nil
+	return
+}
+
+// matchPluginDependencyVersions 依赖版本支持范围约束,不带操作符的版本号表示最低版本,有任一已注册版本满足即可
+func matchPluginDependencyVersions(depPluginVersionMap map[string]string, registeredRows []*models.PluginPackages) (err error) {
+	unmatchedMap := make(map[string]string)
+	for k, v := range depPluginVersionMap {
+		unmatchedMap[k] = v
+	}
+	for _, row := range registeredRows {
+		if v, b := unmatchedMap[row.Name]; b {
+			versionConstraint, parseErr := tools.ParseVersionConstraint(v)
+			if parseErr != nil {
+				err = parseErr
+				return
+			}
+			if versionConstraint.Match(row.Version) {
+				delete(unmatchedMap, row.Name)
 			}
 		}
 	}
-	if len(depPluginVersionMap) == 0 {
-		return true, nil
+	if len(unmatchedMap) == 0 {
+		return
 	}
 	var errorMessages []string
-	for k, v := range depPluginVersionMap {
+	for k, v := range unmatchedMap {
 		errorMessages = append(errorMessages, fmt.Sprintf("depence %s:%s illegal", k, v))
 	}
-	err = fmt.Errorf(strings.Join(errorMessages, ","))
+	sort.Strings(errorMessages)
+	err = fmt.Errorf("%s", strings.Join(errorMessages, ","))
 	return
 }
 
 func GetResourceServer(ctx context.Context, serverType, serverIp string) (resourceServerObj *models.ResourceServer, err error) {
 	var resourceServerRows []*models.ResourceServer
 	if serverIp == "" {
-		err = db.MysqlEngine.Context(ctx).SQL("select id,host,is_allocated,login_password,login_username,name,port,login_mode from resource_server where `type`=? and status='active'", serverType).Find(&resourceServerRows)
+		err = db.MysqlEngine.Context(ctx).SQL("select id,host,is_allocated,login_password,login_username,name,port,login_mode,`type` from resource_server where `type`=? and status='active'", serverType).Find(&resourceServerRows)
 	} else {
-		err = db.MysqlEngine.Context(ctx).SQL("select id,host,is_allocated,login_password,login_username,name,port,login_mode from resource_server where `type`=? and host=? and status='active'", serverType, serverIp).Find(&resourceServerRows)
+		err = db.MysqlEngine.Context(ctx).SQL("select id,host,is_allocated,login_password,login_username,name,port,login_mode,`type` from resource_server where `type`=? and host=? and status='active'", serverType, serverIp).Find(&resourceServerRows)
 	}
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
@@ -401,8 +424,16 @@ func GetResourceServer(ctx context.Context, serverType, serverIp string) (resour
 	return
 }
 
+// UpdatePluginInstanceContainerStatus 更新插件实例的容器状态
+func UpdatePluginInstanceContainerStatus(ctx context.Context, pluginInstanceId, containerStatus string) (err error) {
+	if _, err = db.MysqlEngine.Context(ctx).Exec("update plugin_instances set container_status=? where id=?", containerStatus, pluginInstanceId); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
 func CheckServerPortRunning(ctx context.Context, serverIp string, port int) (running bool, err error) {
-	queryResult, queryErr := db.MysqlEngine.Context(ctx).QueryString("select id from plugin_instances where host=? and port=? and container_status='RUNNING'", serverIp, port)
+	queryResult, queryErr := db.MysqlEngine.Context(ctx).QueryString("select id from plugin_instances where host=? and port=? and container_status in ('RUNNING','UNHEALTHY','STANDBY','DRAINING')", serverIp, port)
 	if queryErr != nil {
 		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
 		return
@@ -549,7 +580,7 @@ func GetPluginInstance(pluginInstanceId string) (pluginInstance *models.PluginIn
 
 func GetPluginDockerRunningResource(dockerInstanceResourceId string) (pluginResourceServer *models.ResourceServer, err error) {
 	var resourceServerRows []*models.ResourceServer
-	err = db.MysqlEngine.SQL("select id,name,host,login_username,login_password,port,login_mode,is_allocated from resource_server where id in (select resource_server_id from resource_item where id=?)", dockerInstanceResourceId).Find(&resourceServerRows)
+	err = db.MysqlEngine.SQL("select id,name,host,login_username,login_password,port,login_mode,is_allocated,`type` from resource_server where id in (select resource_server_id from resource_item where id=?)", dockerInstanceResourceId).Find(&resourceServerRows)
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
 		return
@@ -588,6 +619,7 @@ func RemovePlugin(ctx context.Context, pluginPackageId, pluginInstanceId, resour
 		return
 	}
 	var actions []*db.ExecAction
+	actions = append(actions, &db.ExecAction{Sql: "delete from plugin_instance_health where instance_id=?", Param: []interface{}{pluginInstanceId}})
 	actions = append(actions, &db.ExecAction{Sql: "delete from plugin_instances where id=?", Param: []interface{}{pluginInstanceId}})
 	actions = append(actions, &db.ExecAction{Sql: "delete from resource_item where id=?", Param: []interface{}{resourceItemId}})
 	if len(queryResult) == 1 {
@@ -794,7 +826,7 @@ func GetPluginPackagePullReq(ctx context.Context, pullId string) (result *models
 
 func IsPluginInstanceRunning(ctx context.Context, pluginPackageId string) (running bool, err error) {
 	var count int64
-	count, err = db.MysqlEngine.Context(ctx).Table(new(models.PluginInstances)).Where("container_status = ?", "RUNNING").And("package_id = ?", pluginPackageId).Count()
+	count, err = db.MysqlEngine.Context(ctx).Table(new(models.PluginInstances)).In("container_status", models.PluginInstanceStatusRunning, models.PluginInstanceStatusUnhealthy, models.PluginInstanceStatusStandby, models.PluginInstanceStatusDraining).And("package_id = ?", pluginPackageId).Count()
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
 	}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+func TestMatchPluginDependencyVersions(t *testing.T) {
+	registeredRows := []*models.PluginPackages{
+		{Name: "wecmdb", Version: "v1.5.0"},
+		{Name: "monitor", Version: "v2.0.1"},
+	}
+	tests := []struct {
+		name       string
+		dependency map[string]string
+		wantErr    string
+	}{
+		{"bare version means minimum", map[string]string{"wecmdb": "v1.2.0"}, ""},
+		{"bare version higher than registered", map[string]string{"wecmdb": "v1.6.0"}, "depence wecmdb:v1.6.0 illegal"},
+		{"range", map[string]string{"wecmdb": ">=1.0.0 <2.0.0", "monitor": "^2.0.0"}, ""},
+		{"range not match", map[string]string{"monitor": "~2.1.0"}, "depence monitor:~2.1.0 illegal"},
+		{"not registered", map[string]string{"artifacts": "v1.0.0", "wecmdb": "v1.0.0"}, "depence artifacts:v1.0.0 illegal"},
+		{"multiple unmatched sorted", map[string]string{"wecmdb": "v2.0.0", "monitor": "v3.0.0"}, "depence monitor:v3.0.0 illegal,depence wecmdb:v2.0.0 illegal"},
+		{"illegal constraint", map[string]string{"wecmdb": ">=abc"}, "version constraint >=abc illegal,condition >=abc illegal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := matchPluginDependencyVersions(tt.dependency, registeredRows)
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tt.wantErr {
+				t.Errorf("matchPluginDependencyVersions(%v) error = %q, want %q", tt.dependency, gotErr, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+const pluginInstanceHealthQuerySql = "select t1.id,t1.id as instance_id,t1.host,t1.port,t1.container_name,t1.container_status,t1.docker_instance_resource_id,t2.name as package_name,t2.`version` as package_version," +
+	"ifnull(t3.health_check_path,'') as health_check_path,ifnull(t4.health_status,'" + models.PluginHealthStatusUnknown + "') as health_status,ifnull(t4.consecutive_failures,0) as consecutive_failures," +
+	"t4.last_check_time,t4.last_success_time,ifnull(t4.last_error,'') as last_error,ifnull(t4.restart_times,0) as restart_times,t4.last_restart_time,ifnull(t4.check_host,'') as check_host,t4.updated_time " +
+	"from plugin_instances t1 join plugin_packages t2 on t1.package_id=t2.id left join plugin_package_runtime_resources_docker t3 on t3.plugin_package_id=t1.package_id " +
+	"left join plugin_instance_health t4 on t4.instance_id=t1.id"
+
+// GetPluginHealthCheckInstances 需要健康检查的插件实例,包括运行中和已判定不健康的
+func GetPluginHealthCheckInstances(ctx context.Context) (result []*models.PluginInstanceHealthQueryObj, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL(pluginInstanceHealthQuerySql+" where t1.container_status in (?,?)", models.PluginInstanceStatusRunning, models.PluginInstanceStatusUnhealthy).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+// GetPluginInstanceHealthList 插件实例健康状态列表,packageId为空时返回全部
+func GetPluginInstanceHealthList(ctx context.Context, packageId string) (result []*models.PluginInstanceHealthQueryObj, err error) {
+	if packageId != "" {
+		err = db.MysqlEngine.Context(ctx).SQL(pluginInstanceHealthQuerySql+" where t1.package_id=? order by t2.name,t1.host,t1.port", packageId).Find(&result)
+	} else {
+		err = db.MysqlEngine.Context(ctx).SQL(pluginInstanceHealthQuerySql + " order by t2.name,t1.host,t1.port").Find(&result)
+	}
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(result) == 0 {
+		result = []*models.PluginInstanceHealthQueryObj{}
+	}
+	return
+}
+
+// ClaimPluginInstanceHealthCheck 占用本轮检查,多实例同时扫描时只有一个能更新成功
+func ClaimPluginInstanceHealthCheck(ctx context.Context, instanceId, checkHost string, checkTime time.Time, interval time.Duration) (ok bool, err error) {
+	if _, err = db.MysqlEngine.Context(ctx).Exec("insert ignore into plugin_instance_health(instance_id,health_status,consecutive_failures,restart_times,updated_time) values (?,?,0,0,?)", instanceId, models.PluginHealthStatusUnknown, checkTime); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+		return
+	}
+	// 留一秒余量,避免ticker抖动导致本实例错过检查
+	lastCheckLimit := checkTime.Add(time.Second - interval)
+	execResult, execErr := db.MysqlEngine.Context(ctx).Exec("update plugin_instance_health set last_check_time=?,check_host=? where instance_id=? and (last_check_time is null or last_check_time<=?)", checkTime, checkHost, instanceId, lastCheckLimit)
+	if execErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, execErr)
+		return
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum > 0 {
+		ok = true
+	}
+	return
+}
+
+// UpdatePluginInstanceHealth 更新检查结果,实例状态变化时一起更新plugin_instances的容器状态
+func UpdatePluginInstanceHealth(ctx context.Context, health *models.PluginInstanceHealth, containerStatus string) (err error) {
+	var actions []*db.ExecAction
+	actions = append(actions, &db.ExecAction{Sql: "update plugin_instance_health set health_status=?,consecutive_failures=?,last_success_time=?,last_error=?,restart_times=?,last_restart_time=?,updated_time=? where instance_id=?", Param: []interface{}{
+		health.HealthStatus, health.ConsecutiveFailures, nullTime(health.LastSuccessTime), health.LastError, health.RestartTimes, nullTime(health.LastRestartTime), time.Now(), health.InstanceId,
+	}})
+	if containerStatus != "" {
+		actions = append(actions, &db.ExecAction{Sql: "update plugin_instances set container_status=? where id=?", Param: []interface{}{containerStatus, health.InstanceId}})
+	}
+	if err = db.Transaction(actions, ctx); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+// GetPluginPlanPackages 没注销的插件包和运行中实例数
+func GetPluginPlanPackages(ctx context.Context) (result []*models.PluginPlanPackageRow, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select t1.*,(select count(1) from plugin_instances t2 where t2.package_id=t1.id and t2.container_status in (?,?)) as running_instances from plugin_packages t1 where t1.status<>? order by t1.name",
+		models.PluginInstanceStatusRunning, models.PluginInstanceStatusUnhealthy, models.PluginStatusDecommissioned).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+// GetPluginPlanDependencies 没注销的插件包的依赖,按插件包id分组
+func GetPluginPlanDependencies(ctx context.Context) (result map[string][]*models.PluginPackageDependencies, err error) {
+	var dependRows []*models.PluginPackageDependencies
+	err = db.MysqlEngine.Context(ctx).SQL("select * from plugin_package_dependencies where plugin_package_id in (select id from plugin_packages where status<>?)", models.PluginStatusDecommissioned).Find(&dependRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	result = make(map[string][]*models.PluginPackageDependencies)
+	for _, row := range dependRows {
+		result[row.PluginPackageId] = append(result[row.PluginPackageId], row)
+	}
+	return
+}
+
+func CreatePluginInstallPlanRecord(ctx context.Context, record *models.PluginInstallPlanRecord) (err error) {
+	_, err = db.MysqlEngine.Context(ctx).Exec("insert into plugin_install_plan(id,status,plan,current_item,created_by,created_time,updated_time) values (?,?,?,?,?,?,?)",
+		record.Id, record.Status, record.Plan, record.CurrentItem, record.CreatedBy, record.CreatedTime, record.CreatedTime)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+func UpdatePluginInstallPlanRecord(ctx context.Context, record *models.PluginInstallPlanRecord) (err error) {
+	record.UpdatedTime = time.Now()
+	_, err = db.MysqlEngine.Context(ctx).Exec("update plugin_install_plan set status=?,plan=?,current_item=?,error_message=?,updated_time=? where id=?",
+		record.Status, record.Plan, record.CurrentItem, record.ErrorMessage, record.UpdatedTime, record.Id)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+func GetPluginInstallPlanRecord(ctx context.Context, planId string) (result *models.PluginInstallPlanRecord, err error) {
+	var recordRows []*models.PluginInstallPlanRecord
+	err = db.MysqlEngine.Context(ctx).SQL("select * from plugin_install_plan where id=?", planId).Find(&recordRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(recordRows) == 0 {
+		err = exterror.Catch(exterror.New().DatabaseQueryEmptyError, fmt.Errorf("plugin_install_plan"))
+		return
+	}
+	result = recordRows[0]
+	return
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+// pluginUpgradeStaleTime 升级纪录超过该时间没更新视为执行的platform已下线,释放锁不再阻止新的升级
+const pluginUpgradeStaleTime = 30 * time.Minute
+
+// CreatePluginPackageUpgrade 新增升级纪录,running_lock唯一键保证同名插件只有一个进行中的升级
+func CreatePluginPackageUpgrade(ctx context.Context, upgrade *models.PluginPackageUpgrade) (err error) {
+	upgrade.RunningLock = upgrade.PluginName
+	if err = insertPluginPackageUpgrade(ctx, upgrade); err == nil || !strings.Contains(err.Error(), "Duplicate entry") {
+		return
+	}
+	// 占用锁的纪录已过期时置为失败并释放锁,多个请求同时释放时只有一个能插入成功
+	execResult, execErr := db.MysqlEngine.Context(ctx).Exec("update plugin_package_upgrade set status=?,error_message=?,running_lock=null,updated_time=? where running_lock=? and updated_time<?",
+		models.PluginUpgradeStatusFailed, "no update for a long time,platform may be offline", time.Now(), upgrade.PluginName, time.Now().Add(-pluginUpgradeStaleTime))
+	if execErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, execErr)
+		return
+	}
+	if affectRows, _ := execResult.RowsAffected(); affectRows > 0 {
+		if err = insertPluginPackageUpgrade(ctx, upgrade); err == nil || !strings.Contains(err.Error(), "Duplicate entry") {
+			return
+		}
+	}
+	err = fmt.Errorf("plugin %s has upgrade in progress", upgrade.PluginName)
+	return
+}
+
+func insertPluginPackageUpgrade(ctx context.Context, upgrade *models.PluginPackageUpgrade) (err error) {
+	_, err = db.MysqlEngine.Context(ctx).Exec("insert into plugin_package_upgrade(id,plugin_name,from_version,to_package_id,to_version,status,old_instances,new_instances,created_by,created_time,updated_time,exec_host,running_lock) values (?,?,?,?,?,?,?,?,?,?,?,?,?)",
+		upgrade.Id, upgrade.PluginName, upgrade.FromVersion, upgrade.ToPackageId, upgrade.ToVersion, upgrade.Status, upgrade.OldInstances, upgrade.NewInstances, upgrade.CreatedBy, upgrade.CreatedTime, upgrade.CreatedTime, upgrade.ExecHost, upgrade.RunningLock)
+	if err != nil && !strings.Contains(err.Error(), "Duplicate entry") {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// UpdatePluginPackageUpgrade 更新升级状态,结束时释放锁
+func UpdatePluginPackageUpgrade(ctx context.Context, upgrade *models.PluginPackageUpgrade) (err error) {
+	upgrade.UpdatedTime = time.Now()
+	updateSql := "update plugin_package_upgrade set status=?,new_instances=?,error_message=?,updated_time=? where id=?"
+	if upgrade.Status == models.PluginUpgradeStatusDone || upgrade.Status == models.PluginUpgradeStatusRolledBack || upgrade.Status == models.PluginUpgradeStatusFailed {
+		upgrade.RunningLock = ""
+		updateSql = "update plugin_package_upgrade set status=?,new_instances=?,error_message=?,updated_time=?,running_lock=null where id=?"
+	}
+	_, err = db.MysqlEngine.Context(ctx).Exec(updateSql, upgrade.Status, upgrade.NewInstances, upgrade.ErrorMessage, upgrade.UpdatedTime, upgrade.Id)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// GetInterruptedPluginUpgrades 本platform实例执行中被重启中断的升级
+func GetInterruptedPluginUpgrades(ctx context.Context, host string) (result []*models.PluginPackageUpgrade, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select * from plugin_package_upgrade where exec_host=? and running_lock is not null", host).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+func GetPluginPackageUpgrade(ctx context.Context, upgradeId string) (result *models.PluginPackageUpgrade, err error) {
+	var upgradeRows []*models.PluginPackageUpgrade
+	err = db.MysqlEngine.Context(ctx).SQL("select * from plugin_package_upgrade where id=?", upgradeId).Find(&upgradeRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(upgradeRows) == 0 {
+		err = exterror.Catch(exterror.New().DatabaseQueryEmptyError, fmt.Errorf("plugin_package_upgrade"))
+		return
+	}
+	result = upgradeRows[0]
+	return
+}
+
+// GetPluginUpgradeOldInstances 同名插件其它版本运行中的实例
+func GetPluginUpgradeOldInstances(ctx context.Context, pluginName, excludePackageId string) (result []*models.PluginInstances, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select t1.* from plugin_instances t1 join plugin_packages t2 on t1.package_id=t2.id where t2.name=? and t1.package_id<>? and t1.container_status in (?,?) order by t1.id",
+		pluginName, excludePackageId, models.PluginInstanceStatusRunning, models.PluginInstanceStatusUnhealthy).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+// GetPluginInstancesByStatus 插件所有版本中指定状态的实例
+func GetPluginInstancesByStatus(ctx context.Context, pluginName string, statusList []string) (result []*models.PluginInstances, err error) {
+	statusFilter, statusParams := db.CreateListParams(statusList, "")
+	err = db.MysqlEngine.Context(ctx).SQL("select t1.* from plugin_instances t1 join plugin_packages t2 on t1.package_id=t2.id where t2.name=? and t1.container_status in ("+statusFilter+") order by t1.id",
+		append([]interface{}{pluginName}, statusParams...)...).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+// ShiftPluginInstanceRoutes 切换路由,activeIds改成运行中加入gateway,drainingIds摘除等待销毁
+func ShiftPluginInstanceRoutes(ctx context.Context, activeIds, drainingIds []string) (err error) {
+	var actions []*db.ExecAction
+	for _, instanceId := range activeIds {
+		actions = append(actions, &db.ExecAction{Sql: "update plugin_instances set container_status=? where id=?", Param: []interface{}{models.PluginInstanceStatusRunning, instanceId}})
+	}
+	for _, instanceId := range drainingIds {
+		actions = append(actions, &db.ExecAction{Sql: "update plugin_instances set container_status=? where id=?", Param: []interface{}{models.PluginInstanceStatusDraining, instanceId}})
+	}
+	if err = db.Transaction(actions, ctx); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
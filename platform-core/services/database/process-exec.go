@@ -183,7 +183,7 @@ func GetSimpleProcDefRow(ctx context.Context, procDefId string) (result *models.
 
 func GetSimpleProcInsRow(ctx context.Context, procInsId string) (result *models.ProcIns, err error) {
 	var procInsRows []*models.ProcIns
-	err = db.MysqlEngine.Context(ctx).SQL("select id,proc_def_id,proc_def_key,proc_def_name,entity_data_id,entity_type_id,proc_session_id,entity_data_name from proc_ins where id=?", procInsId).Find(&procInsRows)
+	err = db.MysqlEngine.Context(ctx).SQL("select id,proc_def_id,proc_def_key,proc_def_name,entity_data_id,entity_type_id,proc_session_id,entity_data_name,created_by from proc_ins where id=?", procInsId).Find(&procInsRows)
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
 		return
@@ -456,6 +456,15 @@ func joinStringList(aList, bList []string) (output []string) {
 	return
 }
 
+// GetProcPreviewRows 查询试算数据
+func GetProcPreviewRows(ctx context.Context, sessionId string) (previewRows []*models.ProcDataPreview, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select * from proc_data_preview where proc_session_id=?", sessionId).Find(&previewRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
 func CreateProcInstance(ctx context.Context, procStartParam *models.ProcInsStartParam, operator string) (procInsId string, workflowRow *models.ProcRunWorkflow, workNodes []*models.ProcRunNode, workLinks []*models.ProcRunLink, err error) {
 	procInsId = "pins_" + guid.CreateGuid()
 	procDefObj, getProcDefErr := GetSimpleProcDefRow(ctx, procStartParam.ProcDefId)
@@ -481,8 +490,8 @@ func CreateProcInstance(ctx context.Context, procStartParam *models.ProcInsStart
 			entityDataName = row.EntityDataName
 		}
 	}
-	actions = append(actions, &db.ExecAction{Sql: "insert into proc_ins(id,proc_def_id,proc_def_key,proc_def_name,status,entity_data_id,entity_type_id,entity_data_name,proc_session_id,created_by,created_time,updated_by,updated_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{
-		procInsId, procDefObj.Id, procDefObj.Key, procDefObj.Name, models.JobStatusReady, entityDataId, entityTypeId, entityDataName, procStartParam.ProcessSessionId, operator, nowTime, operator, nowTime,
+	actions = append(actions, &db.ExecAction{Sql: "insert into proc_ins(id,proc_def_id,proc_def_key,proc_def_name,status,entity_data_id,entity_type_id,entity_data_name,proc_session_id,parent_ins_id,parent_ins_node_id,created_by,created_time,updated_by,updated_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{
+		procInsId, procDefObj.Id, procDefObj.Key, procDefObj.Name, models.JobStatusReady, entityDataId, entityTypeId, entityDataName, procStartParam.ProcessSessionId, procStartParam.ParentInsId, procStartParam.ParentInsNodeId, operator, nowTime, operator, nowTime,
 	}})
 	workflowRow = &models.ProcRunWorkflow{Id: "wf_" + guid.CreateGuid(), ProcInsId: procInsId, Name: procDefObj.Name, Status: models.JobStatusReady, CreatedTime: nowTime}
 	actions = append(actions, &db.ExecAction{Sql: "insert into proc_run_workflow(id,proc_ins_id,name,status,created_time) values (?,?,?,?,?)", Param: []interface{}{
@@ -495,7 +504,7 @@ func CreateProcInstance(ctx context.Context, procStartParam *models.ProcInsStart
 		return
 	}
 	var procDefLinks []*models.ProcDefNodeLink
-	err = db.MysqlEngine.Context(ctx).SQL("select id,link_id,proc_def_id,source,target,name from proc_def_node_link where proc_def_id=?", procStartParam.ProcDefId).Find(&procDefLinks)
+	err = db.MysqlEngine.Context(ctx).SQL("select id,link_id,proc_def_id,source,target,name,link_type,condition_expr,is_default from proc_def_node_link where proc_def_id=? order by id", procStartParam.ProcDefId).Find(&procDefLinks)
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
 		return
@@ -565,9 +574,9 @@ func CreateProcInstance(ctx context.Context, procStartParam *models.ProcInsStart
 		}
 	}
 	for _, link := range procDefLinks {
-		workLinkObj := models.ProcRunLink{Id: "wl_" + guid.CreateGuid(), WorkflowId: workflowRow.Id, ProcDefLinkId: link.Id, Name: link.Name, Source: workNodeIdMap[link.Source], Target: workNodeIdMap[link.Target]}
-		actions = append(actions, &db.ExecAction{Sql: "insert into proc_run_link(id,workflow_id,proc_def_link_id,name,source,target) values (?,?,?,?,?,?)", Param: []interface{}{
-			workLinkObj.Id, workLinkObj.WorkflowId, workLinkObj.ProcDefLinkId, workLinkObj.Name, workLinkObj.Source, workLinkObj.Target,
+		workLinkObj := models.ProcRunLink{Id: "wl_" + guid.CreateGuid(), WorkflowId: workflowRow.Id, ProcDefLinkId: link.Id, Name: link.Name, Source: workNodeIdMap[link.Source], Target: workNodeIdMap[link.Target], LinkType: link.LinkType, Condition: link.Condition, IsDefault: link.IsDefault}
+		actions = append(actions, &db.ExecAction{Sql: "insert into proc_run_link(id,workflow_id,proc_def_link_id,name,source,target,link_type,condition_expr,is_default) values (?,?,?,?,?,?,?,?,?)", Param: []interface{}{
+			workLinkObj.Id, workLinkObj.WorkflowId, workLinkObj.ProcDefLinkId, workLinkObj.Name, workLinkObj.Source, workLinkObj.Target, workLinkObj.LinkType, workLinkObj.Condition, workLinkObj.IsDefault,
 		}})
 		workLinks = append(workLinks, &workLinkObj)
 	}
@@ -617,7 +626,7 @@ func CreatePublicProcInstance(ctx context.Context, startParam *models.RequestPro
 		return
 	}
 	var procDefLinks []*models.ProcDefNodeLink
-	err = db.MysqlEngine.Context(ctx).SQL("select id,link_id,proc_def_id,source,target,name from proc_def_node_link where proc_def_id=?", startParam.ProcDefId).Find(&procDefLinks)
+	err = db.MysqlEngine.Context(ctx).SQL("select id,link_id,proc_def_id,source,target,name,link_type,condition_expr,is_default from proc_def_node_link where proc_def_id=? order by id", startParam.ProcDefId).Find(&procDefLinks)
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
 		return
@@ -680,9 +689,9 @@ func CreatePublicProcInstance(ctx context.Context, startParam *models.RequestPro
 		}
 	}
 	for _, link := range procDefLinks {
-		workLinkObj := models.ProcRunLink{Id: "wl_" + guid.CreateGuid(), WorkflowId: workflowRow.Id, ProcDefLinkId: link.Id, Name: link.Name, Source: workNodeIdMap[link.Source], Target: workNodeIdMap[link.Target]}
-		actions = append(actions, &db.ExecAction{Sql: "insert into proc_run_link(id,workflow_id,proc_def_link_id,name,source,target) values (?,?,?,?,?,?)", Param: []interface{}{
-			workLinkObj.Id, workLinkObj.WorkflowId, workLinkObj.ProcDefLinkId, workLinkObj.Name, workLinkObj.Source, workLinkObj.Target,
+		workLinkObj := models.ProcRunLink{Id: "wl_" + guid.CreateGuid(), WorkflowId: workflowRow.Id, ProcDefLinkId: link.Id, Name: link.Name, Source: workNodeIdMap[link.Source], Target: workNodeIdMap[link.Target], LinkType: link.LinkType, Condition: link.Condition, IsDefault: link.IsDefault}
+		actions = append(actions, &db.ExecAction{Sql: "insert into proc_run_link(id,workflow_id,proc_def_link_id,name,source,target,link_type,condition_expr,is_default) values (?,?,?,?,?,?,?,?,?)", Param: []interface{}{
+			workLinkObj.Id, workLinkObj.WorkflowId, workLinkObj.ProcDefLinkId, workLinkObj.Name, workLinkObj.Source, workLinkObj.Target, workLinkObj.LinkType, workLinkObj.Condition, workLinkObj.IsDefault,
 		}})
 		workLinks = append(workLinks, &workLinkObj)
 	}
@@ -757,6 +766,8 @@ func GetProcInstance(ctx context.Context, procInsId string) (result *models.Proc
 		EntityTypeId:      procInsObj.EntityTypeId,
 		EntityDisplayName: procInsObj.EntityDataName,
 		CreatedTime:       procInsObj.CreatedTime.Format(models.DateTimeFormat),
+		ParentProcInstId:  procInsObj.ParentInsId,
+		ParentNodeInstId:  procInsObj.ParentInsNodeId,
 	}
 	//if transStatus, ok := models.ProcStatusTransMap[result.Status]; ok {
 	//	result.Status = transStatus
@@ -792,6 +803,17 @@ func GetProcInstance(ctx context.Context, procInsId string) (result *models.Proc
 		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
 		return
 	}
+	var subProcInsRows []*models.ProcIns
+	err = db.MysqlEngine.Context(ctx).SQL("select id,parent_ins_node_id from proc_ins where parent_ins_id=? order by created_time", procInsId).Find(&subProcInsRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	approvalVoteMap, getVoteErr := GetProcInsApprovalVotes(ctx, procInsId)
+	if getVoteErr != nil {
+		err = getVoteErr
+		return
+	}
 	orderIndex := 1
 	for _, row := range procInsNodeRows {
 		nodeObj := models.ProcInsNodeDetail{
@@ -807,6 +829,12 @@ func GetProcInstance(ctx context.Context, procInsId string) (result *models.Proc
 			Status:            row.Status,
 			PreviousNodeIds:   []string{},
 			SucceedingNodeIds: []string{},
+			SubProcInstIds:    []string{},
+		}
+		for _, subRow := range subProcInsRows {
+			if subRow.ParentInsNodeId == row.Id {
+				nodeObj.SubProcInstIds = append(nodeObj.SubProcInstIds, subRow.Id)
+			}
 		}
 		for _, defRow := range procNodeDefRows {
 			if defRow.Id == row.ProcDefNodeId {
@@ -817,10 +845,13 @@ func GetProcInstance(ctx context.Context, procInsId string) (result *models.Proc
 		//if transStatus, ok := models.ProcStatusTransMap[nodeObj.Status]; ok {
 		//	nodeObj.Status = transStatus
 		//}
-		if row.NodeType == string(models.ProcDefNodeTypeHuman) || row.NodeType == string(models.ProcDefNodeTypeAutomatic) || row.NodeType == string(models.ProcDefNodeTypeData) {
+		if row.NodeType == string(models.ProcDefNodeTypeHuman) || row.NodeType == string(models.ProcDefNodeTypeAutomatic) || row.NodeType == string(models.ProcDefNodeTypeData) || row.NodeType == string(models.ProcDefNodeTypeSubProcess) || row.NodeType == string(models.ProcDefNodeTypeApproval) {
 			nodeObj.OrderedNo = fmt.Sprintf("%d", orderIndex)
 			orderIndex += 1
 		}
+		if row.NodeType == string(models.ProcDefNodeTypeApproval) {
+			nodeObj.ApprovalVotes = approvalVoteMap[row.Id]
+		}
 		if parentList, ok := parentMap[row.ProcDefNodeId]; ok {
 			nodeObj.PreviousNodeIds = parentList
 		}
@@ -873,7 +904,7 @@ func GetSimpleProcInsNode(ctx context.Context, procInsNodeId, procRunNodeId stri
 
 func GetSimpleProcDefNode(ctx context.Context, procDefNodeId string) (procDefNode *models.ProcDefNode, err error) {
 	var procDefNodeRows []*models.ProcDefNode
-	err = db.MysqlEngine.Context(ctx).SQL("select id,node_id,proc_def_id,name,node_type,service_name,dynamic_bind,bind_node_id,risk_check,routine_expression,context_param_nodes,timeout from proc_def_node where id=?", procDefNodeId).Find(&procDefNodeRows)
+	err = db.MysqlEngine.Context(ctx).SQL("select id,node_id,proc_def_id,name,node_type,service_name,dynamic_bind,bind_node_id,risk_check,routine_expression,context_param_nodes,timeout,sub_proc_def_id,multi_instance,retry_policy,loop_config,human_task_config,approval_config from proc_def_node where id=?", procDefNodeId).Find(&procDefNodeRows)
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
 		return
@@ -886,6 +917,82 @@ func GetSimpleProcDefNode(ctx context.Context, procDefNodeId string) (procDefNod
 	return
 }
 
+// GetSubProcInsList 查询子编排节点本次开始执行后拉起的子编排实例,重放前拉起的不算
+func GetSubProcInsList(ctx context.Context, procInsNodeId string, nodeStartTime time.Time) (result []*models.SubProcInsObj, err error) {
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select t1.id,t1.entity_data_id,t1.status,t2.error_message from proc_ins t1 left join proc_run_workflow t2 on t2.proc_ins_id=t1.id where t1.parent_ins_node_id=? and t1.created_time>=? order by t1.created_time", procInsNodeId, nodeStartTime.Truncate(time.Second))
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	for _, row := range queryRows {
+		result = append(result, &models.SubProcInsObj{ProcInsId: row["id"], EntityDataId: row["entity_data_id"], Status: row["status"], ErrorMessage: row["error_message"]})
+	}
+	return
+}
+
+// GetRunningSubProcWorkflowIds 查询父编排实例拉起的还没结束的子编排工作流,parentInsNodeId不为空时只查该节点拉起的
+func GetRunningSubProcWorkflowIds(ctx context.Context, parentInsId, parentInsNodeId string) (workflowIds []string, err error) {
+	baseSql := "select t2.id from proc_ins t1 join proc_run_workflow t2 on t2.proc_ins_id=t1.id where t2.status in (?,?)"
+	params := []interface{}{models.JobStatusReady, models.JobStatusRunning}
+	if parentInsNodeId != "" {
+		baseSql += " and t1.parent_ins_node_id=?"
+		params = append(params, parentInsNodeId)
+	} else {
+		baseSql += " and t1.parent_ins_id=?"
+		params = append(params, parentInsId)
+	}
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString(append([]interface{}{baseSql}, params...)...)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	for _, row := range queryRows {
+		workflowIds = append(workflowIds, row["id"])
+	}
+	return
+}
+
+// MergeSubProcCacheData 子编排全部成功后把子编排实例的数据值回写到父编排实例,同一数据以后更新的子编排为准
+func MergeSubProcCacheData(ctx context.Context, procInsId string, subProcInsIds []string) (err error) {
+	if len(subProcInsIds) == 0 {
+		return
+	}
+	var parentRows, subRows []*models.ProcDataCache
+	if err = db.MysqlEngine.Context(ctx).SQL("select id,entity_data_id,entity_type_id from proc_data_cache where proc_ins_id=?", procInsId).Find(&parentRows); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	filterSql, filterParams := db.CreateListParams(subProcInsIds, "")
+	if err = db.MysqlEngine.Context(ctx).SQL("select * from proc_data_cache where proc_ins_id in ("+filterSql+") and data_value is not null and data_value<>'' order by updated_time,created_time", filterParams...).Find(&subRows); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	existMap := make(map[string]string)
+	for _, row := range parentRows {
+		existMap[row.EntityTypeId+"^"+row.EntityDataId] = row.Id
+	}
+	var actions []*db.ExecAction
+	nowTime := time.Now()
+	for _, row := range subRows {
+		key := row.EntityTypeId + "^" + row.EntityDataId
+		if existId, ok := existMap[key]; ok {
+			actions = append(actions, &db.ExecAction{Sql: "update proc_data_cache set data_value=?,updated_time=? where id=?", Param: []interface{}{row.DataValue, nowTime, existId}})
+			continue
+		}
+		newId := "p_cache_" + guid.CreateGuid()
+		existMap[key] = newId
+		actions = append(actions, &db.ExecAction{Sql: "insert into proc_data_cache(id,proc_ins_id,entity_id,entity_data_id,entity_data_name,entity_type_id,full_data_id,data_value,created_time) values (?,?,?,?,?,?,?,?,?)", Param: []interface{}{
+			newId, procInsId, row.EntityId, row.EntityDataId, row.EntityDataName, row.EntityTypeId, row.FullDataId, row.DataValue, nowTime,
+		}})
+	}
+	if len(actions) > 0 {
+		if err = db.Transaction(actions, ctx); err != nil {
+			err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+		}
+	}
+	return
+}
+
 func GetDynamicBindNodeData(ctx context.Context, procInsId, procDefId, bindNodeId string) (dataBinding []*models.ProcDataBinding, err error) {
 	err = db.MysqlEngine.Context(ctx).SQL("select * from proc_data_binding where proc_ins_id=? and bind_flag=1 and proc_def_node_id in (select id from proc_def_node where proc_def_id=? and node_id=?)", procInsId, procDefId, bindNodeId).Find(&dataBinding)
 	if err != nil {
@@ -1019,8 +1126,8 @@ func RecordProcCallReq(ctx context.Context, param *models.ProcInsNodeReq, inputF
 	nowTime := time.Now()
 	var actions []*db.ExecAction
 	if inputFlag {
-		actions = append(actions, &db.ExecAction{Sql: "insert into proc_ins_node_req(id,proc_ins_node_id,req_url,req_data_amount,created_time) values (?,?,?,?,?)", Param: []interface{}{
-			param.Id, param.ProcInsNodeId, param.ReqUrl, param.ReqDataAmount, nowTime,
+		actions = append(actions, &db.ExecAction{Sql: "insert into proc_ins_node_req(id,proc_ins_node_id,req_url,req_data_amount,entity_data_id,created_time) values (?,?,?,?,?,?)", Param: []interface{}{
+			param.Id, param.ProcInsNodeId, param.ReqUrl, param.ReqDataAmount, param.EntityDataId, nowTime,
 		}})
 		for _, v := range param.Params {
 			if v.FromType == "input" {
@@ -1030,7 +1137,7 @@ func RecordProcCallReq(ctx context.Context, param *models.ProcInsNodeReq, inputF
 			}
 		}
 	} else {
-		actions = append(actions, &db.ExecAction{Sql: "update proc_ins_node_req set is_completed=1,error_msg=?,updated_time=? where id=?", Param: []interface{}{param.ErrorMsg, nowTime, param.Id}})
+		actions = append(actions, &db.ExecAction{Sql: "update proc_ins_node_req set is_completed=1,error_code=?,error_msg=?,updated_time=? where id=?", Param: []interface{}{param.ErrorCode, param.ErrorMsg, nowTime, param.Id}})
 		for _, v := range param.Params {
 			if v.FromType == "output" {
 				actions = append(actions, &db.ExecAction{Sql: "insert into proc_ins_node_req_param(req_id,data_index,from_type,name,data_type,data_value,entity_data_id,entity_type_id,is_sensitive,full_data_id,multiple,param_def_id,mapping_type,callback_id,created_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{
@@ -1046,6 +1153,47 @@ func RecordProcCallReq(ctx context.Context, param *models.ProcInsNodeReq, inputF
 	return
 }
 
+// RecordProcNodeRetryAttempt 把本次执行产生的节点请求标记为第attempt次执行,本次执行没有请求纪录时(数据节点或调用插件前就失败)补一条失败纪录
+func RecordProcNodeRetryAttempt(ctx context.Context, procInsNodeId string, attempt int, attemptStartTime time.Time, errorCode, errorMsg string) (err error) {
+	execResult, execErr := db.MysqlEngine.Context(ctx).Exec("update proc_ins_node_req set retry_attempt=? where proc_ins_node_id=? and created_time>=? and retry_attempt=0", attempt, procInsNodeId, attemptStartTime)
+	if execErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, execErr)
+		return
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum > 0 {
+		return
+	}
+	nowTime := time.Now()
+	_, execErr = db.MysqlEngine.Context(ctx).Exec("insert into proc_ins_node_req(id,proc_ins_node_id,req_url,is_completed,error_code,error_msg,retry_attempt,created_time,updated_time) values (?,?,'',1,?,?,?,?,?)",
+		"proc_req_"+guid.CreateGuid(), procInsNodeId, errorCode, errorMsg, attempt, nowTime, nowTime)
+	if execErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, execErr)
+	}
+	return
+}
+
+// GetProcNodeAttemptErrorCode 查询节点第attempt次执行最近一次请求的错误码
+func GetProcNodeAttemptErrorCode(ctx context.Context, procInsNodeId string, attempt int) (errorCode string, err error) {
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select error_code from proc_ins_node_req where proc_ins_node_id=? and retry_attempt=? order by created_time desc limit 1", procInsNodeId, attempt)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	if len(queryRows) > 0 {
+		errorCode = queryRows[0]["error_code"]
+	}
+	return
+}
+
+// GetProcNodeSuccessEntityList 查询多实例节点中已成功调用过的数据id
+func GetProcNodeSuccessEntityList(ctx context.Context, procInsNodeId string) (entityDataIdList []string, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select distinct entity_data_id from proc_ins_node_req where proc_ins_node_id=? and is_completed=1 and (error_msg is null or error_msg='') and entity_data_id<>''", procInsNodeId).Find(&entityDataIdList)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
 func GetProcInsNodeContext(ctx context.Context, procInsId, procInsNodeId, procDefNodeId string) (result *models.ProcNodeContextReq, err error) {
 	var queryRows []*models.ProcNodeContextQueryObj
 	if procInsNodeId != "" {
@@ -1238,6 +1386,28 @@ func GetSimpleProcNodeReq(ctx context.Context, procInsNodeId, reqId, procRunNode
 	return
 }
 
+// GetProcInsNodeIterations 查询循环节点各轮循环体节点执行结果,或循环体内某个节点各轮执行结果
+func GetProcInsNodeIterations(ctx context.Context, procInsId, procInsNodeId string) (result []*models.ProcRunNodeIteration, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select t1.*,t2.name as node_name from proc_run_node_iteration t1 left join proc_ins_node t2 on t1.proc_ins_node_id=t2.id where t2.proc_ins_id=? "+
+		"and (t1.proc_ins_node_id=? or t1.loop_node_id in (select id from proc_run_node where proc_ins_node_id=?)) order by t1.iteration,t1.id", procInsId, procInsNodeId, procInsNodeId).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(result) == 0 {
+		result = []*models.ProcRunNodeIteration{}
+	}
+	return
+}
+
+func GetProcNodeReqParamList(ctx context.Context, reqId, fromType string) (reqParams []*models.ProcInsNodeReqParam, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select * from proc_ins_node_req_param where req_id=? and from_type=? order by data_index,id", reqId, fromType).Find(&reqParams)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
 func UpdateProcCacheData(ctx context.Context, procInsId string, taskFormList []*models.PluginTaskFormDto) (err error) {
 	var cacheDataRows []*models.ProcDataCache
 	err = db.MysqlEngine.Context(ctx).SQL("select id,entity_id,entity_data_id,entity_type_id,data_value from proc_data_cache where proc_ins_id=?", procInsId).Find(&cacheDataRows)
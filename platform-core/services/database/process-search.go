@@ -0,0 +1,253 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+const procSearchInsertBatchSize = 200
+
+// GetProcInsNodeSearchDocs 节点的报错信息和插件请求的输入输出,敏感参数不建索引
+func GetProcInsNodeSearchDocs(ctx context.Context, procInsNodeId string) (procInsId string, docs []*models.ProcSearchDoc, err error) {
+	var nodeRows []*models.ProcInsNode
+	if err = db.MysqlEngine.Context(ctx).SQL("select id,proc_ins_id,error_msg from proc_ins_node where id=?", procInsNodeId).Find(&nodeRows); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(nodeRows) == 0 {
+		err = exterror.Catch(exterror.New().DatabaseQueryEmptyError, fmt.Errorf("proc_ins_node"))
+		return
+	}
+	procInsId = nodeRows[0].ProcInsId
+	if nodeRows[0].ErrorMsg != "" {
+		docs = append(docs, &models.ProcSearchDoc{Source: models.ProcSearchSourceError, Field: "node", Text: nodeRows[0].ErrorMsg})
+	}
+	var reqRows []*models.ProcInsNodeReq
+	if err = db.MysqlEngine.Context(ctx).SQL("select id,error_msg from proc_ins_node_req where proc_ins_node_id=?", procInsNodeId).Find(&reqRows); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(reqRows) == 0 {
+		return
+	}
+	var reqIds []string
+	for _, row := range reqRows {
+		reqIds = append(reqIds, row.Id)
+		if row.ErrorMsg != "" {
+			docs = append(docs, &models.ProcSearchDoc{Source: models.ProcSearchSourceError, Field: "request", Text: row.ErrorMsg})
+		}
+	}
+	reqFilterSql, reqFilterParams := db.CreateListParams(reqIds, "")
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString(append([]interface{}{"select from_type,name,data_value,entity_data_id from proc_ins_node_req_param where req_id in (" + reqFilterSql + ") and is_sensitive=0"}, reqFilterParams...)...)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	for _, row := range queryRows {
+		source := models.ProcSearchSourceInput
+		if row["from_type"] == "output" {
+			source = models.ProcSearchSourceOutput
+		}
+		text := row["data_value"]
+		if row["entity_data_id"] != "" {
+			text = row["entity_data_id"] + " " + text
+		}
+		if strings.TrimSpace(text) != "" {
+			docs = append(docs, &models.ProcSearchDoc{Source: source, Field: row["name"], Text: text})
+		}
+	}
+	return
+}
+
+// GetProcInsEntitySearchDocs 编排实例的根数据和缓存的编排数据,数据没有变化时返回needIndex=false
+func GetProcInsEntitySearchDocs(ctx context.Context, procInsId string) (needIndex bool, docs []*models.ProcSearchDoc, err error) {
+	var dataRows []*models.ProcDataCache
+	if err = db.MysqlEngine.Context(ctx).SQL("select entity_data_id,entity_data_name,entity_type_id,created_time,updated_time from proc_data_cache where proc_ins_id=?", procInsId).Find(&dataRows); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	indexedTime, getErr := getProcSearchDocIndexedTime(ctx, "ins_"+procInsId)
+	if getErr != nil {
+		err = getErr
+		return
+	}
+	for _, row := range dataRows {
+		if indexedTime.IsZero() || row.CreatedTime.After(indexedTime) || row.UpdatedTime.After(indexedTime) {
+			needIndex = true
+		}
+		docs = append(docs, &models.ProcSearchDoc{Source: models.ProcSearchSourceEntity, Field: row.EntityTypeId, Text: row.EntityDataId + " " + row.EntityDataName})
+	}
+	if !indexedTime.IsZero() && !needIndex {
+		return
+	}
+	needIndex = true
+	var procInsRows []*models.ProcIns
+	if err = db.MysqlEngine.Context(ctx).SQL("select entity_data_id,entity_data_name,entity_type_id from proc_ins where id=?", procInsId).Find(&procInsRows); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(procInsRows) > 0 && procInsRows[0].EntityDataId != "" {
+		docs = append(docs, &models.ProcSearchDoc{Source: models.ProcSearchSourceEntity, Field: procInsRows[0].EntityTypeId, Text: procInsRows[0].EntityDataId + " " + procInsRows[0].EntityDataName})
+	}
+	return
+}
+
+func getProcSearchDocIndexedTime(ctx context.Context, docKey string) (indexedTime time.Time, err error) {
+	var docRows []*models.ProcInsSearchIndex
+	if err = db.MysqlEngine.Context(ctx).SQL("select created_time from proc_ins_search_doc where doc_key=?", docKey).Find(&docRows); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(docRows) > 0 {
+		indexedTime = docRows[0].CreatedTime
+	}
+	return
+}
+
+// SaveProcInsSearchIndex 按文档重建索引,先删掉旧的分词再写入
+func SaveProcInsSearchIndex(ctx context.Context, docKey, procInsId string, rows []*models.ProcInsSearchIndex) (err error) {
+	nowTime := time.Now()
+	var actions []*db.ExecAction
+	actions = append(actions, &db.ExecAction{Sql: "delete from proc_ins_search_index where doc_key=?", Param: []interface{}{docKey}})
+	for i := 0; i < len(rows); i += procSearchInsertBatchSize {
+		end := i + procSearchInsertBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		var valueSqlList []string
+		var params []interface{}
+		for _, row := range rows[i:end] {
+			valueSqlList = append(valueSqlList, "(?,?,?,?,?,?,?,?)")
+			params = append(params, row.Token, docKey, procInsId, row.ProcInsNodeId, row.Source, row.Field, row.Snippet, nowTime)
+		}
+		actions = append(actions, &db.ExecAction{Sql: "insert into proc_ins_search_index(token,doc_key,proc_ins_id,proc_ins_node_id,source,field,snippet,created_time) values " + strings.Join(valueSqlList, ","), Param: params})
+	}
+	actions = append(actions, &db.ExecAction{Sql: "replace into proc_ins_search_doc(doc_key,proc_ins_id,created_time) values (?,?,?)", Param: []interface{}{docKey, procInsId, nowTime}})
+	if err = db.Transaction(actions, ctx); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// GetUnindexedProcInsNodes 最近结束但还没建索引的节点,补偿队列满或实例重启时丢掉的索引任务
+func GetUnindexedProcInsNodes(ctx context.Context, sinceTime time.Time, limit int) (result []*models.ProcInsNode, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select t1.id,t1.proc_ins_id from proc_ins_node t1 left join proc_ins_search_doc t2 on t2.doc_key=concat('node_',t1.id) where t1.updated_time>=? and t1.status in (?,?,?) and t2.doc_key is null limit ?",
+		sinceTime, models.JobStatusSuccess, models.JobStatusFail, models.JobStatusTimeout, limit).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+// GetOrphanProcSearchIndexInsIds 编排实例已被删除或归档出主表,但还留着索引的实例id
+func GetOrphanProcSearchIndexInsIds(ctx context.Context, limit int) (procInsIds []string, err error) {
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select distinct t1.proc_ins_id from proc_ins_search_doc t1 left join proc_ins t2 on t1.proc_ins_id=t2.id where t2.id is null limit ?", limit)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	for _, row := range queryRows {
+		procInsIds = append(procInsIds, row["proc_ins_id"])
+	}
+	return
+}
+
+// DeleteProcInsSearchIndex 编排实例删除或归档时一起删掉索引
+func DeleteProcInsSearchIndex(ctx context.Context, procInsIds []string) (err error) {
+	if len(procInsIds) == 0 {
+		return
+	}
+	filterSql, filterParams := db.CreateListParams(procInsIds, "")
+	var actions []*db.ExecAction
+	actions = append(actions, &db.ExecAction{Sql: "delete from proc_ins_search_index where proc_ins_id in (" + filterSql + ")", Param: filterParams})
+	actions = append(actions, &db.ExecAction{Sql: "delete from proc_ins_search_doc where proc_ins_id in (" + filterSql + ")", Param: filterParams})
+	if err = db.Transaction(actions, ctx); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+// SearchProcIns 按分词检索编排实例,同一段原文包含全部分词才算命中,按实例分页并带上命中的数据和节点
+func SearchProcIns(ctx context.Context, param *models.ProcInsSearchParam, tokens []string) (result *models.ProcInsSearchResponse, err error) {
+	result = &models.ProcInsSearchResponse{PageInfo: &models.PageInfo{}, Contents: []*models.ProcInsSearchResult{}}
+	if len(tokens) == 0 {
+		return
+	}
+	tokenListSql, tokenParams := db.CreateListParams(tokens, "")
+	tokenFilterSql := "token in (" + tokenListSql + ")"
+	matchSql := "select proc_ins_id from proc_ins_search_index where " + tokenFilterSql + " group by proc_ins_id,doc_key,source,field having count(distinct token)=?"
+	baseSql := "select * from proc_ins where id in (select proc_ins_id from (" + matchSql + ") t)"
+	filterParams := append(append([]interface{}{}, tokenParams...), len(tokens))
+	if param.ProcDefId != "" {
+		baseSql += " and proc_def_id=?"
+		filterParams = append(filterParams, param.ProcDefId)
+	}
+	if param.Status != "" {
+		baseSql += " and status=?"
+		filterParams = append(filterParams, param.Status)
+	}
+	if param.StartTime != "" {
+		baseSql += " and created_time>=?"
+		filterParams = append(filterParams, param.StartTime)
+	}
+	if param.EndTime != "" {
+		baseSql += " and created_time<=?"
+		filterParams = append(filterParams, param.EndTime)
+	}
+	baseSql += " order by created_time desc"
+	if param.Pageable != nil && param.Pageable.PageSize > 0 {
+		result.PageInfo.StartIndex = param.Pageable.StartIndex
+		result.PageInfo.PageSize = param.Pageable.PageSize
+		result.PageInfo.TotalRows = db.QueryCount(baseSql, filterParams...)
+		baseSql += fmt.Sprintf(" limit %d,%d", param.Pageable.StartIndex, param.Pageable.PageSize)
+	}
+	var procInsRows []*models.ProcIns
+	if err = db.MysqlEngine.Context(ctx).SQL(baseSql, filterParams...).Find(&procInsRows); err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(procInsRows) == 0 {
+		return
+	}
+	var procInsIds []string
+	for _, row := range procInsRows {
+		procInsIds = append(procInsIds, row.Id)
+	}
+	procInsFilterSql, matchParams := db.CreateListParams(procInsIds, "")
+	matchParams = append(append(matchParams, tokenParams...), len(tokens))
+	var matchRows []*models.ProcInsSearchMatch
+	err = db.MysqlEngine.Context(ctx).SQL("select t1.proc_ins_id,t1.proc_ins_node_id,t1.source,t1.field,max(t1.snippet) as snippet,max(t2.name) as node_name from proc_ins_search_index t1 left join proc_ins_node t2 on t1.proc_ins_node_id=t2.id where t1.proc_ins_id in ("+procInsFilterSql+") and t1."+tokenFilterSql+" group by t1.proc_ins_id,t1.doc_key,t1.proc_ins_node_id,t1.source,t1.field having count(distinct t1.token)=?",
+		matchParams...).Find(&matchRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	matchMap := make(map[string][]*models.ProcInsSearchMatch)
+	for _, row := range matchRows {
+		matchMap[row.ProcInsId] = append(matchMap[row.ProcInsId], row)
+	}
+	for _, row := range procInsRows {
+		result.Contents = append(result.Contents, &models.ProcInsSearchResult{
+			ProcInsDetail: models.ProcInsDetail{
+				Id:                row.Id,
+				EntityDataId:      row.EntityDataId,
+				EntityTypeId:      row.EntityTypeId,
+				EntityDisplayName: row.EntityDataName,
+				Operator:          row.CreatedBy,
+				ProcDefId:         row.ProcDefId,
+				ProcInstKey:       row.Id,
+				ProcInstName:      row.ProcDefName,
+				Status:            row.Status,
+				CreatedTime:       row.CreatedTime.Format(models.DateTimeFormat),
+			},
+			Matches: matchMap[row.Id],
+		})
+	}
+	return
+}
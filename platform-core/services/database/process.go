@@ -49,7 +49,7 @@ func QueryProcessDefinitionList(ctx context.Context, param models.QueryProcessDe
 	} else {
 		filterProcDefList = procDefList
 	}
-	response, err = remote.RetrieveAllLocalRoles("Y", userToken, language, false)
+	response, err = remote.RetrieveAllLocalRoles(ctx, "Y", userToken, language, false)
 	if err != nil {
 		return
 	}
@@ -163,6 +163,7 @@ func AddProcessDefinition(ctx context.Context, user string, param models.Process
 	draftEntity.ForPlugin = strings.Join(param.AuthPlugins, ",")
 	draftEntity.Scene = param.Scene
 	draftEntity.ConflictCheck = param.ConflictCheck
+	draftEntity.SlaMinutes = param.SlaMinutes
 	draftEntity.CreatedBy = user
 	draftEntity.CreatedTime = now
 	draftEntity.UpdatedBy = user
@@ -195,7 +196,7 @@ func CopyProcessDefinitionByDto(ctx context.Context, procDef *models.ProcessDefi
 	procDefModel.CreatedTime = now
 	procDefModel.UpdatedTime = now
 	// 获取操作用户的角色
-	response, err = remote.GetRolesByUsername(operator, userToken, language)
+	response, err = remote.GetRolesByUsername(ctx, operator, userToken, language)
 	if err != nil {
 		return
 	}
@@ -287,9 +288,9 @@ func execCopyProcessDefinition(ctx context.Context, procDef *models.ProcDef, nod
 	var actions []*db.ExecAction
 	// 插入编排
 	actions = append(actions, &db.ExecAction{Sql: "insert into proc_def (id,`key`,name,root_entity,status,tags,for_plugin,scene," +
-		"conflict_check,created_by,version,created_time,updated_by,updated_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{newProcDefId,
+		"conflict_check,sla_minutes,created_by,version,created_time,updated_by,updated_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{newProcDefId,
 		procDef.Key, procDef.Name, procDef.RootEntity, models.Draft, procDef.Tags, procDef.ForPlugin, procDef.Scene,
-		procDef.ConflictCheck, operator, procDef.Version, currTime, operator, currTime}})
+		procDef.ConflictCheck, procDef.SlaMinutes, operator, procDef.Version, currTime, operator, currTime}})
 
 	// 插入权限
 	if len(permissionList) > 0 {
@@ -305,10 +306,10 @@ func execCopyProcessDefinition(ctx context.Context, procDef *models.ProcDef, nod
 			curNodeParamList = []*models.ProcDefNodeParam{}
 			newNodeId := models.GenNodeId(node.NodeType)
 			actions = append(actions, &db.ExecAction{Sql: "insert into  proc_def_node(id,node_id,proc_def_id,name,description,status,node_type,service_name," +
-				"dynamic_bind,bind_node_id,risk_check,routine_expression,context_param_nodes,timeout,time_config,ordered_no,ui_style,created_by,created_time," +
-				"updated_by,updated_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{newNodeId, node.NodeId, newProcDefId, node.Name, node.Description,
+				"dynamic_bind,bind_node_id,risk_check,routine_expression,context_param_nodes,timeout,time_config,ordered_no,ui_style,sub_proc_def_id,multi_instance,retry_policy,loop_config,human_task_config,approval_config,expected_minutes,created_by,created_time," +
+				"updated_by,updated_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{newNodeId, node.NodeId, newProcDefId, node.Name, node.Description,
 				models.Draft, node.NodeType, node.ServiceName, node.DynamicBind, node.BindNodeId, node.RiskCheck, node.RoutineExpression, node.ContextParamNodes,
-				node.Timeout, node.TimeConfig, node.OrderedNo, node.UiStyle, operator, currTime, node.UpdatedBy, currTime}})
+				node.Timeout, node.TimeConfig, node.OrderedNo, node.UiStyle, node.SubProcDefId, node.MultiInstance, node.RetryPolicy, node.LoopConfig, node.HumanTaskConfig, node.ApprovalConfig, node.ExpectedMinutes, operator, currTime, node.UpdatedBy, currTime}})
 			for _, nodeParam := range nodeParamList {
 				if nodeParam.ProcDefNodeId == node.NodeId {
 					curNodeParamList = append(curNodeParamList, nodeParam)
@@ -336,8 +337,8 @@ func execCopyProcessDefinition(ctx context.Context, procDef *models.ProcDef, nod
 	// 插入线
 	if len(linkList) > 0 {
 		for _, nodeLink := range linkList {
-			actions = append(actions, &db.ExecAction{Sql: "insert into  proc_def_node_link(id,source,target,name,ui_style,link_id,proc_def_id) values(?,?,?,?,?,?,?)",
-				Param: []interface{}{"pdl_" + guid.CreateGuid(), nodeLink.Source, nodeLink.Target, nodeLink.Name, nodeLink.UiStyle, nodeLink.LinkId, newProcDefId}})
+			actions = append(actions, &db.ExecAction{Sql: "insert into  proc_def_node_link(id,source,target,name,ui_style,link_id,proc_def_id,link_type,condition_expr,is_default) values(?,?,?,?,?,?,?,?,?,?)",
+				Param: []interface{}{"pdl_" + guid.CreateGuid(), nodeLink.Source, nodeLink.Target, nodeLink.Name, nodeLink.UiStyle, nodeLink.LinkId, newProcDefId, nodeLink.LinkType, nodeLink.Condition, nodeLink.IsDefault}})
 		}
 	}
 
@@ -419,8 +420,8 @@ func GetProcessDefinitionByCondition(ctx context.Context, condition models.ProcD
 func UpdateProcDef(ctx context.Context, procDef *models.ProcDef) (err error) {
 	var actions []*db.ExecAction
 	actions = append(actions, &db.ExecAction{Sql: "update proc_def set name=?,root_entity=?,tags=?,for_plugin=?,scene=?," +
-		"conflict_check=?,updated_by=?,updated_time=? where id=?", Param: []interface{}{procDef.Name, procDef.RootEntity,
-		procDef.Tags, procDef.ForPlugin, procDef.Scene, procDef.ConflictCheck, procDef.UpdatedBy, procDef.UpdatedTime, procDef.Id}})
+		"conflict_check=?,sla_minutes=?,updated_by=?,updated_time=? where id=?", Param: []interface{}{procDef.Name, procDef.RootEntity,
+		procDef.Tags, procDef.ForPlugin, procDef.Scene, procDef.ConflictCheck, procDef.SlaMinutes, procDef.UpdatedBy, procDef.UpdatedTime, procDef.Id}})
 	err = db.Transaction(actions, ctx)
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
@@ -443,8 +444,8 @@ func UpdateProcDefAndNode(ctx context.Context, procDef *models.ProcDef, nodeList
 	var actions []*db.ExecAction
 	// 更新编排表
 	actions = append(actions, &db.ExecAction{Sql: "update proc_def set name=?,root_entity=?,tags=?,for_plugin=?,scene=?," +
-		"conflict_check=?,updated_by=?,updated_time=? where id=?", Param: []interface{}{procDef.Name, procDef.RootEntity,
-		procDef.Tags, procDef.ForPlugin, procDef.Scene, procDef.ConflictCheck, procDef.UpdatedBy, procDef.UpdatedTime, procDef.Id}})
+		"conflict_check=?,sla_minutes=?,updated_by=?,updated_time=? where id=?", Param: []interface{}{procDef.Name, procDef.RootEntity,
+		procDef.Tags, procDef.ForPlugin, procDef.Scene, procDef.ConflictCheck, procDef.SlaMinutes, procDef.UpdatedBy, procDef.UpdatedTime, procDef.Id}})
 	// 更新节点表
 	actions = append(actions, &db.ExecAction{Sql: "update proc_def_node  set service_name = null,routine_expression = null where" +
 		" proc_def_id =?", Param: []interface{}{procDef.Id}})
@@ -594,11 +595,24 @@ func GetProcDefNodeById(ctx context.Context, procDefId string) (list []*models.P
 	return
 }
 
+// GetProcDefSubProcDefIds 查询编排中子编排节点关联的子编排定义id
+func GetProcDefSubProcDefIds(ctx context.Context, procDefId string) (subProcDefIds []string, err error) {
+	queryRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select distinct sub_proc_def_id from proc_def_node where proc_def_id=? and node_type=? and sub_proc_def_id<>''", procDefId, string(models.ProcDefNodeTypeSubProcess))
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	for _, row := range queryRows {
+		subProcDefIds = append(subProcDefIds, row["sub_proc_def_id"])
+	}
+	return
+}
+
 // InsertProcDefNodeLink 添加编排节点线
 func InsertProcDefNodeLink(ctx context.Context, nodeLink *models.ProcDefNodeLink) (err error) {
 	var actions []*db.ExecAction
-	actions = append(actions, &db.ExecAction{Sql: "insert into  proc_def_node_link(id,source,target,name,ui_style,link_id,proc_def_id) values(?,?,?,?,?,?,?)",
-		Param: []interface{}{nodeLink.Id, nodeLink.Source, nodeLink.Target, nodeLink.Name, nodeLink.UiStyle, nodeLink.LinkId, nodeLink.ProcDefId}})
+	actions = append(actions, &db.ExecAction{Sql: "insert into  proc_def_node_link(id,source,target,name,ui_style,link_id,proc_def_id,link_type,condition_expr,is_default) values(?,?,?,?,?,?,?,?,?,?)",
+		Param: []interface{}{nodeLink.Id, nodeLink.Source, nodeLink.Target, nodeLink.Name, nodeLink.UiStyle, nodeLink.LinkId, nodeLink.ProcDefId, nodeLink.LinkType, nodeLink.Condition, nodeLink.IsDefault}})
 	err = db.Transaction(actions, ctx)
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
@@ -622,10 +636,10 @@ func UpdateProcDefNodeLink(ctx context.Context, procDefNodeLink *models.ProcDefN
 func InsertProcDefNode(ctx context.Context, node *models.ProcDefNode) (err error) {
 	var actions []*db.ExecAction
 	actions = append(actions, &db.ExecAction{Sql: "insert into  proc_def_node(id,node_id,proc_def_id,name,description,status,node_type,service_name," +
-		"dynamic_bind,bind_node_id,risk_check,routine_expression,context_param_nodes,timeout,time_config,ordered_no,ui_style,created_by,created_time," +
-		"updated_by,updated_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{node.Id, node.NodeId, node.ProcDefId, node.Name, node.Description,
+		"dynamic_bind,bind_node_id,risk_check,routine_expression,context_param_nodes,timeout,time_config,ordered_no,ui_style,sub_proc_def_id,multi_instance,retry_policy,loop_config,human_task_config,approval_config,expected_minutes,created_by,created_time," +
+		"updated_by,updated_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{node.Id, node.NodeId, node.ProcDefId, node.Name, node.Description,
 		node.Status, node.NodeType, node.ServiceName, node.DynamicBind, node.BindNodeId, node.RiskCheck, node.RoutineExpression, node.ContextParamNodes,
-		node.Timeout, node.TimeConfig, node.OrderedNo, node.UiStyle, node.CreatedBy, node.CreatedTime.Format(models.DateTimeFormat), node.UpdatedBy, node.UpdatedTime.Format(models.DateTimeFormat)}})
+		node.Timeout, node.TimeConfig, node.OrderedNo, node.UiStyle, node.SubProcDefId, node.MultiInstance, node.RetryPolicy, node.LoopConfig, node.HumanTaskConfig, node.ApprovalConfig, node.ExpectedMinutes, node.CreatedBy, node.CreatedTime.Format(models.DateTimeFormat), node.UpdatedBy, node.UpdatedTime.Format(models.DateTimeFormat)}})
 	err = db.Transaction(actions, ctx)
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
@@ -870,9 +884,9 @@ func BatchAddProcDefPermission(ctx context.Context, procDefId string, permission
 func insertProcDef(ctx context.Context, procDef *models.ProcDef) (err error) {
 	var actions []*db.ExecAction
 	actions = append(actions, &db.ExecAction{Sql: "insert into  proc_def (id,`key`,name,root_entity,status,tags,for_plugin,scene," +
-		"conflict_check,version,created_by,created_time,updated_by,updated_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{procDef.Id,
+		"conflict_check,sla_minutes,version,created_by,created_time,updated_by,updated_time) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{procDef.Id,
 		procDef.Key, procDef.Name, procDef.RootEntity, procDef.Status, procDef.Tags, procDef.ForPlugin, procDef.Scene,
-		procDef.ConflictCheck, procDef.Version, procDef.CreatedBy, procDef.CreatedTime.Format(models.DateTimeFormat), procDef.UpdatedBy, procDef.UpdatedTime.Format(models.DateTimeFormat)}})
+		procDef.ConflictCheck, procDef.SlaMinutes, procDef.Version, procDef.CreatedBy, procDef.CreatedTime.Format(models.DateTimeFormat), procDef.UpdatedBy, procDef.UpdatedTime.Format(models.DateTimeFormat)}})
 	err = db.Transaction(actions, ctx)
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
@@ -939,6 +953,34 @@ func transProcDefNodeUpdateConditionToSQL(procDefNode *models.ProcDefNode) (sql
 		sql = sql + ",ordered_no=?"
 		params = append(params, procDefNode.OrderedNo)
 	}
+	if procDefNode.SubProcDefId != "" {
+		sql = sql + ",sub_proc_def_id=?"
+		params = append(params, procDefNode.SubProcDefId)
+	}
+	if procDefNode.MultiInstance != "" {
+		sql = sql + ",multi_instance=?"
+		params = append(params, procDefNode.MultiInstance)
+	}
+	if procDefNode.RetryPolicy != "" {
+		sql = sql + ",retry_policy=?"
+		params = append(params, procDefNode.RetryPolicy)
+	}
+	if procDefNode.LoopConfig != "" {
+		sql = sql + ",loop_config=?"
+		params = append(params, procDefNode.LoopConfig)
+	}
+	if procDefNode.HumanTaskConfig != "" {
+		sql = sql + ",human_task_config=?"
+		params = append(params, procDefNode.HumanTaskConfig)
+	}
+	if procDefNode.ApprovalConfig != "" {
+		sql = sql + ",approval_config=?"
+		params = append(params, procDefNode.ApprovalConfig)
+	}
+	if procDefNode.ExpectedMinutes != 0 {
+		sql = sql + ",expected_minutes=?"
+		params = append(params, procDefNode.ExpectedMinutes)
+	}
 	if procDefNode.UiStyle != "" {
 		sql = sql + ",ui_style=?"
 		params = append(params, procDefNode.UiStyle)
@@ -981,6 +1023,8 @@ func transProcDefNodeLinkUpdateConditionToSQL(procDefNodeLink *models.ProcDefNod
 		sql = sql + ",proc_def_id=?"
 		params = append(params, procDefNodeLink.ProcDefId)
 	}
+	sql = sql + ",link_type=?,condition_expr=?,is_default=?"
+	params = append(params, procDefNodeLink.LinkType, procDefNodeLink.Condition, procDefNodeLink.IsDefault)
 	sql = sql + " where id= ?"
 	params = append(params, procDefNodeLink.Id)
 	return
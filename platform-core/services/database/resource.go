@@ -2,7 +2,9 @@ package database
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -98,7 +100,7 @@ func DeleteResourceServer(ctx context.Context, params []*models.ResourceServer)
 
 func GetAvailableContainerHost() (availableHost []string, err error) {
 	var resourceServerRows []*models.ResourceServer
-	err = db.MysqlEngine.SQL("select host from resource_server where `type`='docker' and status='active'").Find(&resourceServerRows)
+	err = db.MysqlEngine.SQL("select host from resource_server where `type` in ('docker','kubernetes') and status='active'").Find(&resourceServerRows)
 	if err != nil {
 		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
 		return
@@ -109,6 +111,51 @@ func GetAvailableContainerHost() (availableHost []string, err error) {
 	return
 }
 
+// GetK8sAvailablePort kubernetes上每个插件有独立的Service,只需避开同一集群已部署插件分配过的端口
+// 实例表里纪录的是Service对外端口,分配的端口从资源纪录里取
+func GetK8sAvailablePort(ctx context.Context, resourceServerId string) (port int, err error) {
+	queryResult, queryErr := db.MysqlEngine.Context(ctx).QueryString("select additional_properties from resource_item where resource_server_id=?", resourceServerId)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	existPortMap := make(map[int]bool)
+	for _, row := range queryResult {
+		for _, allocatePort := range getResourceItemAllocatePorts(row["additional_properties"]) {
+			existPortMap[allocatePort] = true
+		}
+	}
+	for i := 20000; i <= 21000; i++ {
+		if !existPortMap[i] {
+			port = i
+			return
+		}
+	}
+	err = fmt.Errorf("kubernetes server %s have no available port in 20000-21000", resourceServerId)
+	return
+}
+
+// getResourceItemAllocatePorts 旧纪录没有allocatePort,从端口映射的主机端口里取
+func getResourceItemAllocatePorts(additionalProperties string) (ports []int) {
+	var properties models.ResourceItemProperties
+	if err := json.Unmarshal([]byte(additionalProperties), &properties); err != nil {
+		return
+	}
+	if properties.AllocatePort > 0 {
+		return []int{properties.AllocatePort}
+	}
+	for _, portBind := range strings.Split(properties.PortBindings, ",") {
+		parts := strings.Split(strings.TrimSpace(portBind), ":")
+		if len(parts) < 2 {
+			continue
+		}
+		if hostPort, _ := strconv.Atoi(parts[len(parts)-2]); hostPort > 0 {
+			ports = append(ports, hostPort)
+		}
+	}
+	return
+}
+
 func GetResourceServerByIp(hostIp string) (resourceServer *models.ResourceServer, err error) {
 	var resourceServerRows []*models.ResourceServer
 	err = db.MysqlEngine.SQL("select id,is_allocated,login_password,login_username,login_mode,name,port,`type`,host from resource_server where host=?", hostIp).Find(&resourceServerRows)
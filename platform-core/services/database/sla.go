@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+// GetOverdueProcInstances 运行中超过编排时限且还没纪录过超时的编排实例
+func GetOverdueProcInstances(ctx context.Context, nowTime time.Time) (result []*models.ProcSlaBreachQueryObj, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select '"+models.SlaBreachTypeInstance+"' as breach_type,t1.proc_def_id,t1.id as proc_ins_id,'' as proc_ins_node_id,t2.sla_minutes as expected_minutes,t1.created_time as start_time,"+
+		"t1.proc_def_name,t1.entity_data_name,t1.created_by as proc_ins_created_by from proc_ins t1 join proc_def t2 on t1.proc_def_id=t2.id "+
+		"where t1.status=? and t2.sla_minutes>0 and t1.created_time<=date_sub(?,interval t2.sla_minutes minute) "+
+		"and not exists (select 1 from proc_ins_sla_breach t3 where t3.proc_ins_id=t1.id and t3.proc_ins_node_id='')", models.JobStatusRunning, nowTime).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+// GetOverdueProcInsNodes 运行中超过节点预期时长且本次运行还没纪录过超时的节点
+func GetOverdueProcInsNodes(ctx context.Context, nowTime time.Time) (result []*models.ProcSlaBreachQueryObj, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select '"+models.SlaBreachTypeNode+"' as breach_type,t4.proc_def_id,t4.id as proc_ins_id,t1.proc_ins_node_id,t1.name as node_name,t3.expected_minutes,t1.start_time,"+
+		"t4.proc_def_name,t4.entity_data_name,t4.created_by as proc_ins_created_by from proc_run_node t1 join proc_ins_node t2 on t1.proc_ins_node_id=t2.id "+
+		"join proc_def_node t3 on t2.proc_def_node_id=t3.id join proc_ins t4 on t2.proc_ins_id=t4.id "+
+		"where t1.status=? and t3.expected_minutes>0 and t1.start_time<=date_sub(?,interval t3.expected_minutes minute) "+
+		"and not exists (select 1 from proc_ins_sla_breach t5 where t5.proc_ins_node_id=t1.proc_ins_node_id and t5.start_time=t1.start_time)", models.JobStatusRunning, nowTime).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+	}
+	return
+}
+
+// AddProcSlaBreach 纪录超时,同一次运行已经纪录过(多实例同时检查)时返回false
+func AddProcSlaBreach(ctx context.Context, breach *models.ProcInsSlaBreach) (ok bool, err error) {
+	execResult, execErr := db.MysqlEngine.Context(ctx).Exec("insert ignore into proc_ins_sla_breach(breach_type,proc_def_id,proc_ins_id,proc_ins_node_id,node_name,expected_minutes,start_time,breach_time) values (?,?,?,?,?,?,?,?)",
+		breach.BreachType, breach.ProcDefId, breach.ProcInsId, breach.ProcInsNodeId, breach.NodeName, breach.ExpectedMinutes, breach.StartTime, breach.BreachTime)
+	if execErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, execErr)
+		return
+	}
+	if affectNum, _ := execResult.RowsAffected(); affectNum > 0 {
+		ok = true
+		lastId, _ := execResult.LastInsertId()
+		breach.Id = int(lastId)
+	}
+	return
+}
+
+func UpdateProcSlaBreachNotify(ctx context.Context, breachId int, notifyStatus, notifyMessage string) (err error) {
+	_, err = db.MysqlEngine.Context(ctx).Exec("update proc_ins_sla_breach set notify_status=?,notify_message=? where id=?", notifyStatus, notifyMessage, breachId)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseExecuteError, err)
+	}
+	return
+}
+
+func GetProcInsSlaBreaches(ctx context.Context, procInsId string) (result []*models.ProcInsSlaBreach, err error) {
+	err = db.MysqlEngine.Context(ctx).SQL("select * from proc_ins_sla_breach where proc_ins_id=? order by id", procInsId).Find(&result)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(result) == 0 {
+		result = []*models.ProcInsSlaBreach{}
+	}
+	return
+}
+
+// GetProcDefSlaStatistics 统计时间范围内创建的编排实例和节点的时限达标情况,只统计已结束或已超过时限的,没到时限的运行中数据不计入达标率
+func GetProcDefSlaStatistics(ctx context.Context, procDef *models.ProcDef, startTime, endTime time.Time) (result *models.ProcDefSlaStatistics, err error) {
+	nowTime := time.Now()
+	result = &models.ProcDefSlaStatistics{ProcDefId: procDef.Id, ProcDefName: procDef.Name, SlaMinutes: procDef.SlaMinutes,
+		StartTime: startTime.Format(models.DateTimeFormat), EndTime: endTime.Format(models.DateTimeFormat), Nodes: []*models.ProcDefNodeSlaStatistics{}}
+	var procInsRows []*models.ProcIns
+	err = db.MysqlEngine.Context(ctx).SQL("select id,status,created_time,updated_time from proc_ins where proc_def_id=? and created_time>=? and created_time<?", procDef.Id, startTime, endTime).Find(&procInsRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	breachInsRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select distinct t1.proc_ins_id from proc_ins_sla_breach t1 join proc_ins t2 on t1.proc_ins_id=t2.id "+
+		"where t2.proc_def_id=? and t2.created_time>=? and t2.created_time<? and t1.proc_ins_node_id=''", procDef.Id, startTime, endTime)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	breachInsMap := make(map[string]bool)
+	for _, row := range breachInsRows {
+		breachInsMap[row["proc_ins_id"]] = true
+	}
+	var durationSum float64
+	for _, row := range procInsRows {
+		result.TotalInstances++
+		if row.Status != models.JobStatusSuccess && row.Status != models.JobStatusFail && row.Status != models.JobStatusKill {
+			// 运行中的实例超过时限才计入,没到时限的还不能判断是否达标
+			if breachInsMap[row.Id] || isSlaExceeded(row.CreatedTime, nowTime, procDef.SlaMinutes) {
+				result.EvaluatedInstances++
+				result.BreachedInstances++
+			}
+			continue
+		}
+		result.FinishedInstances++
+		result.EvaluatedInstances++
+		duration := row.UpdatedTime.Sub(row.CreatedTime).Minutes()
+		durationSum += duration
+		// 定时检查间隔内结束的超时实例没有纪录,按实际耗时补算
+		if breachInsMap[row.Id] || isSlaExceeded(row.CreatedTime, row.UpdatedTime, procDef.SlaMinutes) {
+			result.BreachedInstances++
+		}
+	}
+	if result.FinishedInstances > 0 {
+		result.AvgDurationMinutes = roundPercent(durationSum / float64(result.FinishedInstances))
+	}
+	result.ComplianceRate = calcComplianceRate(result.EvaluatedInstances, result.BreachedInstances)
+	var nodeDefRows []*models.ProcDefNode
+	err = db.MysqlEngine.Context(ctx).SQL("select id,node_id,name,expected_minutes from proc_def_node where proc_def_id=? and expected_minutes>0 order by ordered_no", procDef.Id).Find(&nodeDefRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	if len(nodeDefRows) == 0 {
+		return
+	}
+	var runNodeRows []*models.ProcNodeSlaQueryObj
+	err = db.MysqlEngine.Context(ctx).SQL("select t2.proc_def_node_id,t1.proc_ins_node_id,t1.status,t1.start_time,t1.end_time from proc_run_node t1 join proc_ins_node t2 on t1.proc_ins_node_id=t2.id "+
+		"join proc_ins t3 on t2.proc_ins_id=t3.id where t3.proc_def_id=? and t3.created_time>=? and t3.created_time<? and t1.start_time is not null", procDef.Id, startTime, endTime).Find(&runNodeRows)
+	if err != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, err)
+		return
+	}
+	breachNodeRows, queryErr := db.MysqlEngine.Context(ctx).QueryString("select distinct t1.proc_ins_node_id from proc_ins_sla_breach t1 join proc_ins_node t2 on t1.proc_ins_node_id=t2.id "+
+		"join proc_ins t3 on t2.proc_ins_id=t3.id where t3.proc_def_id=? and t3.created_time>=? and t3.created_time<?", procDef.Id, startTime, endTime)
+	if queryErr != nil {
+		err = exterror.Catch(exterror.New().DatabaseQueryError, queryErr)
+		return
+	}
+	breachNodeMap := make(map[string]bool)
+	for _, row := range breachNodeRows {
+		breachNodeMap[row["proc_ins_node_id"]] = true
+	}
+	nodeStatMap := make(map[string]*models.ProcDefNodeSlaStatistics)
+	for _, node := range nodeDefRows {
+		nodeStat := models.ProcDefNodeSlaStatistics{NodeDefId: node.Id, NodeId: node.NodeId, NodeName: node.Name, ExpectedMinutes: node.ExpectedMinutes}
+		nodeStatMap[node.Id] = &nodeStat
+		result.Nodes = append(result.Nodes, &nodeStat)
+	}
+	for _, row := range runNodeRows {
+		nodeStat, ok := nodeStatMap[row.ProcDefNodeId]
+		if !ok || row.StartTime.IsZero() {
+			continue
+		}
+		if !row.EndTime.IsZero() {
+			nodeStat.Executions++
+			// 检查间隔内结束的超时节点没有纪录,按实际耗时补算
+			if breachNodeMap[row.ProcInsNodeId] || isSlaExceeded(row.StartTime, row.EndTime, nodeStat.ExpectedMinutes) {
+				nodeStat.Breaches++
+			}
+		} else if row.Status == models.JobStatusRunning && (breachNodeMap[row.ProcInsNodeId] || isSlaExceeded(row.StartTime, nowTime, nodeStat.ExpectedMinutes)) {
+			nodeStat.Executions++
+			nodeStat.Breaches++
+		}
+	}
+	for _, nodeStat := range result.Nodes {
+		nodeStat.ComplianceRate = calcComplianceRate(nodeStat.Executions, nodeStat.Breaches)
+	}
+	return
+}
+
+// isSlaExceeded 从开始到结束是否超过时限分钟,时限为0不限制
+func isSlaExceeded(startTime, endTime time.Time, limitMinutes int) bool {
+	return limitMinutes > 0 && endTime.Sub(startTime) > time.Duration(limitMinutes)*time.Minute
+}
+
+// calcComplianceRate 达标率百分比,没有数据时为0
+func calcComplianceRate(total, breaches int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return roundPercent(float64(total-breaches) * 100 / float64(total))
+}
+
+func roundPercent(input float64) float64 {
+	return math.Round(input*100) / 100
+}
@@ -0,0 +1,165 @@
+package execution
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+)
+
+// GetWorkflowNodeApprovalConfig 获取审批节点的审批配置
+func GetWorkflowNodeApprovalConfig(ctx context.Context, procRunNodeId string) (approvalConfig *models.ApprovalConfigDto, err error) {
+	procInsNode, err := database.GetSimpleProcInsNode(ctx, "", procRunNodeId)
+	if err != nil {
+		return
+	}
+	procDefNode, err := database.GetSimpleProcDefNode(ctx, procInsNode.ProcDefNodeId)
+	if err != nil {
+		return
+	}
+	approvalConfig = models.ConvertString2ApprovalConfigDto(procDefNode.ApprovalConfig)
+	if approvalConfig == nil || len(approvalConfig.Stages) == 0 {
+		err = fmt.Errorf("approval node:%s approval config illegal", procDefNode.Name)
+	}
+	return
+}
+
+// CheckWorkflowApproval 按节点本次运行的投票计算审批结果,结果为空表示还在审批中,本次运行开始时间以库里纪录的为准和投票保持一致
+func CheckWorkflowApproval(ctx context.Context, procInsId, procInsNodeId string) (result string, err error) {
+	runNode, err := database.GetApprovalRunNode(ctx, procInsId, procInsNodeId)
+	if err != nil {
+		return
+	}
+	approvalConfig, err := GetWorkflowNodeApprovalConfig(ctx, runNode.Id)
+	if err != nil {
+		return
+	}
+	votes, err := database.GetApprovalVotes(ctx, runNode.Id, runNode.StartTime)
+	if err != nil {
+		return
+	}
+	result, _ = evaluateApproval(approvalConfig, votes)
+	return
+}
+
+// SubmitApprovalVote 用户以所属角色对审批节点当前阶段投票,返回投票后的审批结果
+func SubmitApprovalVote(ctx context.Context, procInsId, procInsNodeId, user string, roles []string, param *models.ApprovalVoteParam) (runNode *models.ProcRunNode, result string, err error) {
+	if param.Decision != models.ApprovalVoteApprove && param.Decision != models.ApprovalVoteReject {
+		err = fmt.Errorf("decision:%s illegal", param.Decision)
+		return
+	}
+	if runNode, err = database.GetApprovalRunNode(ctx, procInsId, procInsNodeId); err != nil {
+		return
+	}
+	if runNode.JobType != models.JobApprovalType || runNode.Status != models.JobStatusRunning {
+		err = fmt.Errorf("node:%s is not approval node in progress", runNode.Name)
+		return
+	}
+	approvalConfig, err := GetWorkflowNodeApprovalConfig(ctx, runNode.Id)
+	if err != nil {
+		return
+	}
+	votes, err := database.GetApprovalVotes(ctx, runNode.Id, runNode.StartTime)
+	if err != nil {
+		return
+	}
+	var stageIndex int
+	if result, stageIndex = evaluateApproval(approvalConfig, votes); result != "" {
+		err = fmt.Errorf("approval already %s", result)
+		return
+	}
+	stage := approvalConfig.Stages[stageIndex]
+	approvedRoleMap := make(map[string]bool)
+	for _, vote := range votes {
+		if vote.Stage != stageIndex {
+			continue
+		}
+		if vote.Voter == user {
+			err = fmt.Errorf("user:%s already voted in stage %s", user, stage.Name)
+			return
+		}
+		approvedRoleMap[vote.Role] = true
+	}
+	voteRole := ""
+	userRoleMap := make(map[string]bool)
+	for _, role := range roles {
+		userRoleMap[role] = true
+	}
+	for _, role := range stage.Roles {
+		if !userRoleMap[role] {
+			continue
+		}
+		// 优先代表还没有人投票的角色,all 规则下一个用户持有多个角色时不会重复占用
+		if voteRole == "" || (approvedRoleMap[voteRole] && !approvedRoleMap[role]) {
+			voteRole = role
+		}
+	}
+	if voteRole == "" {
+		err = fmt.Errorf("user:%s has no role of approval stage %s", user, stage.Name)
+		return
+	}
+	vote := models.ProcRunApprovalVote{
+		ProcRunNodeId: runNode.Id,
+		ProcInsNodeId: procInsNodeId,
+		ProcInsId:     procInsId,
+		Stage:         stageIndex,
+		StageName:     stage.Name,
+		Voter:         user,
+		Role:          voteRole,
+		Decision:      param.Decision,
+		Comment:       param.Comment,
+		RunStartTime:  runNode.StartTime.Truncate(time.Second),
+		CreatedTime:   time.Now(),
+	}
+	voteOk, addErr := database.AddApprovalVote(ctx, &vote)
+	if addErr != nil {
+		err = addErr
+		return
+	}
+	if !voteOk {
+		err = fmt.Errorf("user:%s already voted in stage %s", user, stage.Name)
+		return
+	}
+	result, _ = evaluateApproval(approvalConfig, append(votes, &vote))
+	return
+}
+
+// evaluateApproval 按阶段顺序计算审批结果,阶段内任一驳回即驳回,所有阶段都满足通过规则才通过,返回结果和当前所在阶段
+func evaluateApproval(approvalConfig *models.ApprovalConfigDto, votes []*models.ProcRunApprovalVote) (result string, stageIndex int) {
+	for i, stage := range approvalConfig.Stages {
+		approveRoleMap, approveUserMap := make(map[string]bool), make(map[string]bool)
+		for _, vote := range votes {
+			if vote.Stage != i {
+				continue
+			}
+			if vote.Decision == models.ApprovalVoteReject {
+				return models.ApprovalResultRejected, i
+			}
+			approveRoleMap[vote.Role] = true
+			approveUserMap[vote.Voter] = true
+		}
+		passFlag := false
+		switch stage.Quorum {
+		case models.ApprovalQuorumAll:
+			passFlag = true
+			for _, role := range stage.Roles {
+				if !approveRoleMap[role] {
+					passFlag = false
+					break
+				}
+			}
+		case models.ApprovalQuorumAny:
+			passFlag = len(approveUserMap) > 0
+		default:
+			quorumNum, _ := strconv.Atoi(stage.Quorum)
+			passFlag = quorumNum > 0 && len(approveUserMap) >= quorumNum
+		}
+		if !passFlag {
+			return "", i
+		}
+	}
+	return models.ApprovalResultApproved, len(approvalConfig.Stages)
+}
@@ -38,10 +38,16 @@ type OutputEntityBranchData struct {
 	Data       map[string]interface{}
 }
 
+// batchExecutionCall 批量执行一次插件调用需要的接口定义和输入参数
+type batchExecutionCall struct {
+	pluginInterface *models.PluginConfigInterfaces
+	inputParamDatas []models.BatchExecutionPluginExecInputParams
+}
+
 /**
- * Func: BatchExecutionCallPluginService 批量执行调用插件接口
+ * Func: BatchExecutionDangerousCheck 批量执行前对全部实体做高危检测
  *
- * @params ctx 上下文数据，需要是gin ctx
+ * @params ctx 上下文数据
  * @params operator 操作人用户名
  * @params authToken 用户token
  * @params pluginInterfaceId 要调用的插件接口ID
@@ -50,67 +56,67 @@ type OutputEntityBranchData struct {
  * @params inputParamConstants 输入数据(常量，即用户输入的)
  * @params continueToken 是否进行高危检测(有值则跳过)
  *
- * @return 调用结果, 高危结果, 错误
+ * @return 高危结果, 错误
  */
-
-func BatchExecutionCallPluginService(ctx context.Context, operator, authToken, pluginInterfaceId string, entityType string,
+func BatchExecutionDangerousCheck(ctx context.Context, operator, authToken, pluginInterfaceId string, entityType string,
 	entityInstances []*models.BatchExecutionPluginExecEntityInstances,
 	inputParamConstants []*models.BatchExecutionPluginDefInputParams,
-	continueToken string) (result *models.PluginInterfaceApiResultData, dangerousCheckResult *models.ItsdangerousBatchCheckResultData, pluginCallParam *models.BatchExecutionPluginExecParam, err error) {
-	pluginInterface, errGet := database.GetPluginConfigInterfaceById(pluginInterfaceId, true)
-	if errGet != nil {
-		err = errGet
-		return
-	}
-	if pluginInterface == nil {
-		err = fmt.Errorf("invalid plugin interface %s", pluginInterfaceId)
-		return
-	}
-	if pluginInterface.Type != models.PluginInterfaceTypeExecution {
-		err = fmt.Errorf("unsupported plugin interface type %s", pluginInterface.Type)
-		return
-	}
-	inputConstantMap := make(map[string]string)
-	for _, inputConst := range inputParamConstants {
-		inputConstantMap[inputConst.ParamId] = inputConst.ParameValue
-	}
-	rootExprList, errAnalyze1 := remote.AnalyzeExpression(entityType)
-	if errAnalyze1 != nil {
-		err = errAnalyze1
+	continueToken string) (dangerousCheckResult *models.ItsdangerousBatchCheckResultData, err error) {
+	if continueToken != "" {
 		return
 	}
-	if len(rootExprList) == 0 {
-		err = fmt.Errorf("invalid input entity type %s", entityType)
-		return
-	}
-	rootExpr := rootExprList[len(rootExprList)-1]
-	// 构造输入参数
-	inputParamDatas, errHandle := handleInputData(ctx, authToken, continueToken, entityInstances, pluginInterface.InputParameters, rootExpr, inputConstantMap, nil, &models.ProcInsNodeReq{})
-	if errHandle != nil {
-		err = errHandle
+	batchCall, errPrepare := prepareBatchExecutionCall(ctx, authToken, pluginInterfaceId, entityType, entityInstances, inputParamConstants, continueToken)
+	if errPrepare != nil {
+		err = errPrepare
 		return
 	}
 	// 调用高危插件
 	itsdangerousCallParam := &models.BatchExecutionItsdangerousExecParam{
 		Operator:        operator,
-		ServiceName:     pluginInterface.ServiceName,
-		ServicePath:     pluginInterface.ServiceDisplayName,
+		ServiceName:     batchCall.pluginInterface.ServiceName,
+		ServicePath:     batchCall.pluginInterface.ServiceDisplayName,
 		EntityType:      entityType,
 		EntityInstances: entityInstances,
-		InputParams:     inputParamDatas,
+		InputParams:     batchCall.inputParamDatas,
 	}
 	// 需要有运行时的高危插件
 	// 获取subsystem token
-	subsysToken := remote.GetToken()
-	dangerousResult, errDangerous := performBatchDangerousCheck(ctx, itsdangerousCallParam, continueToken, subsysToken)
+	dangerousResult, errDangerous := performBatchDangerousCheck(ctx, itsdangerousCallParam, continueToken, remote.GetToken())
 	if errDangerous != nil {
 		err = errDangerous
 		return
 	}
 	if dangerousResult != nil && len(dangerousResult.Data) > 0 {
 		dangerousCheckResult = dangerousResult
+	}
+	return
+}
+
+/**
+ * Func: BatchExecutionCallPlugin 批量执行调用插件接口,高危检测已在执行前完成
+ *
+ * @params ctx 上下文数据，需要带transactionId
+ * @params operator 操作人用户名
+ * @params authToken 用户token
+ * @params pluginInterfaceId 要调用的插件接口ID
+ * @params entityType 输入entity的表达式
+ * @params entityInstances 输入entity的数据,批量执行时为一个分片
+ * @params inputParamConstants 输入数据(常量，即用户输入的)
+ * @params continueToken 高危确认token,透传给插件
+ *
+ * @return 调用结果, 插件调用参数, 错误
+ */
+func BatchExecutionCallPlugin(ctx context.Context, operator, authToken, pluginInterfaceId string, entityType string,
+	entityInstances []*models.BatchExecutionPluginExecEntityInstances,
+	inputParamConstants []*models.BatchExecutionPluginDefInputParams,
+	continueToken string) (result *models.PluginInterfaceApiResultData, pluginCallParam *models.BatchExecutionPluginExecParam, err error) {
+	batchCall, errPrepare := prepareBatchExecutionCall(ctx, authToken, pluginInterfaceId, entityType, entityInstances, inputParamConstants, continueToken)
+	if errPrepare != nil {
+		err = errPrepare
 		return
 	}
+	pluginInterface := batchCall.pluginInterface
+	subsysToken := remote.GetToken()
 	// 调用插件接口
 	pluginCallParam = &models.BatchExecutionPluginExecParam{
 		RequestId:       "",
@@ -118,7 +124,7 @@ func BatchExecutionCallPluginService(ctx context.Context, operator, authToken, p
 		ServiceName:     pluginInterface.ServiceName,
 		ServicePath:     pluginInterface.ServiceDisplayName,
 		EntityInstances: entityInstances,
-		Inputs:          inputParamDatas,
+		Inputs:          batchCall.inputParamDatas,
 	}
 	pluginCallParam.RequestId = "batchexec_" + guid.CreateGuid()
 	pluginCallResult, _, errCall := remote.PluginInterfaceApi(ctx, subsysToken, pluginInterface, pluginCallParam)
@@ -127,7 +133,7 @@ func BatchExecutionCallPluginService(ctx context.Context, operator, authToken, p
 		return
 	}
 	// 处理output param(比如类型转换，数据模型写入), handleOutputData主要是用于格式化为output param定义的字段
-	_, errHandle = handleOutputData(ctx, subsysToken, pluginCallResult.Outputs, pluginInterface.OutputParameters, &models.ProcInsNodeReq{})
+	_, errHandle := handleOutputData(ctx, subsysToken, pluginCallResult.Outputs, pluginInterface.OutputParameters, &models.ProcInsNodeReq{})
 	if errHandle != nil {
 		err = errHandle
 		return
@@ -137,6 +143,47 @@ func BatchExecutionCallPluginService(ctx context.Context, operator, authToken, p
 	return
 }
 
+func prepareBatchExecutionCall(ctx context.Context, authToken, pluginInterfaceId string, entityType string,
+	entityInstances []*models.BatchExecutionPluginExecEntityInstances,
+	inputParamConstants []*models.BatchExecutionPluginDefInputParams,
+	continueToken string) (batchCall *batchExecutionCall, err error) {
+	pluginInterface, errGet := database.GetPluginConfigInterfaceById(pluginInterfaceId, true)
+	if errGet != nil {
+		err = errGet
+		return
+	}
+	if pluginInterface == nil {
+		err = fmt.Errorf("invalid plugin interface %s", pluginInterfaceId)
+		return
+	}
+	if pluginInterface.Type != models.PluginInterfaceTypeExecution {
+		err = fmt.Errorf("unsupported plugin interface type %s", pluginInterface.Type)
+		return
+	}
+	inputConstantMap := make(map[string]string)
+	for _, inputConst := range inputParamConstants {
+		inputConstantMap[inputConst.ParamId] = inputConst.ParameValue
+	}
+	rootExprList, errAnalyze1 := remote.AnalyzeExpression(entityType)
+	if errAnalyze1 != nil {
+		err = errAnalyze1
+		return
+	}
+	if len(rootExprList) == 0 {
+		err = fmt.Errorf("invalid input entity type %s", entityType)
+		return
+	}
+	rootExpr := rootExprList[len(rootExprList)-1]
+	// 构造输入参数
+	inputParamDatas, errHandle := handleInputData(ctx, authToken, continueToken, entityInstances, pluginInterface.InputParameters, rootExpr, inputConstantMap, nil, &models.ProcInsNodeReq{})
+	if errHandle != nil {
+		err = errHandle
+		return
+	}
+	batchCall = &batchExecutionCall{pluginInterface: pluginInterface, inputParamDatas: inputParamDatas}
+	return
+}
+
 func normalizePluginInterfaceParamData(inputParamDef *models.PluginConfigInterfaceParameters, value interface{}) (interface{}, error) {
 	// Required 空默认是N
 	// Multiple 空默认是N
@@ -0,0 +1,112 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+)
+
+// ExportBatchExecTable 把批量执行各实体的输入输出展开成表格逐行写出,输入输出参数各占一列
+// 第一次遍历收集全部输入输出参数作为表头,第二次遍历逐行写出,不把全部实体结果放进内存
+func ExportBatchExecTable(ctx context.Context, batchExec *models.BatchExecution, onlyFailed bool, writeRow func(row []string) error) (err error) {
+	inputKeyMap, outputKeyMap := make(map[string]bool), make(map[string]bool)
+	err = database.IterateBatchExecExportJobs(ctx, batchExec.Id, onlyFailed, func(job *models.BatchExecutionJobs) error {
+		input, output := parseBatchExecJobData(job)
+		for k := range input {
+			inputKeyMap[k] = true
+		}
+		for k := range output {
+			outputKeyMap[k] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	// 回调参数就是实体id,错误码和错误信息单独成列
+	delete(inputKeyMap, models.PluginCallResultPresetCallback)
+	for _, k := range []string{models.PluginCallResultPresetCallback, models.PluginCallResultPresetErrorCode, models.PluginCallResultPresetErrorMsg} {
+		delete(outputKeyMap, k)
+	}
+	inputKeys := sortedKeys(inputKeyMap)
+	// 输出参数优先按插件接口定义的顺序
+	var outputKeys []string
+	if batchExec.ConfigData != nil {
+		for _, param := range batchExec.ConfigData.OutputParameterDefinitions {
+			if outputKeyMap[param.Name] {
+				outputKeys = append(outputKeys, param.Name)
+				delete(outputKeyMap, param.Name)
+			}
+		}
+	}
+	outputKeys = append(outputKeys, sortedKeys(outputKeyMap)...)
+
+	header := []string{"entityDataId", "entityDisplayName"}
+	for _, k := range inputKeys {
+		header = append(header, "input."+k)
+	}
+	for _, k := range outputKeys {
+		header = append(header, "output."+k)
+	}
+	header = append(header, "errorCode", "errorMessage", "executeTime", "completeTime", "costSeconds")
+	if err = writeRow(header); err != nil {
+		return
+	}
+	err = database.IterateBatchExecExportJobs(ctx, batchExec.Id, onlyFailed, func(job *models.BatchExecutionJobs) error {
+		input, output := parseBatchExecJobData(job)
+		row := []string{job.RootEntityId, job.BusinessKey}
+		for _, k := range inputKeys {
+			row = append(row, exportCellValue(input[k]))
+		}
+		for _, k := range outputKeys {
+			row = append(row, exportCellValue(output[k]))
+		}
+		executeTime, completeTime, costSeconds := "", "", ""
+		if job.ExecuteTime != nil {
+			executeTime = job.ExecuteTime.Format(models.DateTimeFormat)
+		}
+		if job.CompleteTime != nil {
+			completeTime = job.CompleteTime.Format(models.DateTimeFormat)
+			if job.ExecuteTime != nil {
+				costSeconds = fmt.Sprintf("%.0f", job.CompleteTime.Sub(*job.ExecuteTime).Seconds())
+			}
+		}
+		row = append(row, job.ErrorCode, job.ErrorMessage, executeTime, completeTime, costSeconds)
+		return writeRow(row)
+	})
+	return
+}
+
+func parseBatchExecJobData(job *models.BatchExecutionJobs) (input, output map[string]interface{}) {
+	if job.InputJson != "" {
+		json.Unmarshal([]byte(job.InputJson), &input)
+	}
+	if job.ReturnJson != "" {
+		json.Unmarshal([]byte(job.ReturnJson), &output)
+	}
+	return
+}
+
+func exportCellValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		valueBytes, _ := json.Marshal(v)
+		return string(valueBytes)
+	}
+}
+
+func sortedKeys(input map[string]bool) (keys []string) {
+	for k := range input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
@@ -0,0 +1,215 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/try"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
+)
+
+// batchExecCancelMap 本实例正在执行的批量执行,用于取消时停止后续分片
+var batchExecCancelMap = new(sync.Map)
+
+// BatchExecRunParam 异步批量执行参数,权限在发起时校验,执行时用平台token调用插件,不依赖会过期的用户token
+type BatchExecRunParam struct {
+	BatchExecId   string
+	Operator      string
+	ContinueToken string                       // 高危确认token,透传给插件
+	ReqParam      *models.BatchExecRun         // 批量执行配置
+	Jobs          []*models.BatchExecutionJobs // 待执行的实体纪录
+}
+
+// StartBatchExecution 把实体按分片交给工作池并发调用插件,每个实体单独纪录结果,全部结束后汇总整体状态
+func StartBatchExecution(ctx context.Context, param *BatchExecRunParam) {
+	defer try.ExceptionStack(func(e interface{}, err interface{}) {
+		log.Logger.Error("batch execution panic", log.String("batchExecId", param.BatchExecId), log.String("error", e.(string)))
+		if finishErr := database.FailBatchExec(context.Background(), param.BatchExecId, param.Operator, fmt.Sprintf("batch execution panic: %v", err)); finishErr != nil {
+			log.Logger.Error("finish batch execution fail", log.String("batchExecId", param.BatchExecId), log.Error(finishErr))
+		}
+	})
+	// 纪录执行的实例,实例重启时据此找出被中断的批量执行
+	if err := database.SetBatchExecHost(ctx, param.BatchExecId, models.Config.HostIp); err != nil {
+		log.Logger.Error("set batch execution host fail", log.String("batchExecId", param.BatchExecId), log.Error(err))
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	batchExecCancelMap.Store(param.BatchExecId, cancel)
+	defer func() {
+		batchExecCancelMap.Delete(param.BatchExecId)
+		cancel()
+	}()
+	chunkSize, parallelism := getBatchExecConcurrency()
+	log.Logger.Info("start batch execution", log.String("batchExecId", param.BatchExecId), log.Int("entityNum", len(param.Jobs)), log.Int("chunkSize", chunkSize), log.Int("parallelism", parallelism))
+	chunkChan := make(chan []*models.BatchExecutionJobs)
+	wg := sync.WaitGroup{}
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunkChan {
+				runBatchExecChunkSafe(ctx, param, chunk)
+			}
+		}()
+	}
+	for i := 0; i < len(param.Jobs); i += chunkSize {
+		if checkBatchExecCanceled(ctx, param.BatchExecId) {
+			cancel()
+			break
+		}
+		end := i + chunkSize
+		if end > len(param.Jobs) {
+			end = len(param.Jobs)
+		}
+		select {
+		case chunkChan <- param.Jobs[i:end]:
+		case <-ctx.Done():
+		}
+	}
+	close(chunkChan)
+	wg.Wait()
+	finishBatchExecution(param)
+}
+
+// CancelBatchExecution 停止本实例上的后续分片,其它实例上的执行在下一个分片前从数据库状态发现取消
+func CancelBatchExecution(batchExecId string) {
+	if cancel, ok := batchExecCancelMap.Load(batchExecId); ok {
+		cancel.(context.CancelFunc)()
+	}
+}
+
+// RecoverInterruptedBatchExecutions 实例启动时把本实例上次未执行完的批量执行置为失败,未执行的实体置为取消,用户可以重试
+func RecoverInterruptedBatchExecutions(ctx context.Context) {
+	batchExecIds, err := database.FailInterruptedBatchExec(ctx, models.Config.HostIp, "interrupted by platform restart, retry to continue")
+	if err != nil {
+		log.Logger.Error("recover interrupted batch execution fail", log.Error(err))
+		return
+	}
+	if len(batchExecIds) > 0 {
+		log.Logger.Warn("fail interrupted batch execution", log.StringList("batchExecIds", batchExecIds))
+	}
+}
+
+func getBatchExecConcurrency() (chunkSize, parallelism int) {
+	chunkSize, parallelism = models.DefaultBatchExecChunkSize, models.DefaultBatchExecParallelism
+	if batchExecConfig := models.Config.BatchExec; batchExecConfig != nil {
+		if batchExecConfig.ChunkSize > 0 {
+			chunkSize = batchExecConfig.ChunkSize
+		}
+		if batchExecConfig.Parallelism > 0 {
+			parallelism = batchExecConfig.Parallelism
+		}
+	}
+	return
+}
+
+func checkBatchExecCanceled(ctx context.Context, batchExecId string) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	errorCode, err := database.GetBatchExecErrorCode(ctx, batchExecId)
+	if err != nil {
+		log.Logger.Error("check batch execution status fail", log.String("batchExecId", batchExecId), log.Error(err))
+		return false
+	}
+	return errorCode == models.BatchExecErrorCodeCanceled
+}
+
+// runBatchExecChunkSafe 分片执行异常时只影响本分片,实体保持待执行,汇总时按失败处理
+func runBatchExecChunkSafe(ctx context.Context, param *BatchExecRunParam, jobs []*models.BatchExecutionJobs) {
+	defer try.ExceptionStack(func(e interface{}, err interface{}) {
+		log.Logger.Error("batch execution chunk panic", log.String("batchExecId", param.BatchExecId), log.String("error", e.(string)))
+	})
+	runBatchExecChunk(ctx, param, jobs)
+}
+
+// runBatchExecChunk 一个分片调用一次插件,按callbackParameter把输出对应回各实体
+func runBatchExecChunk(ctx context.Context, param *BatchExecRunParam, jobs []*models.BatchExecutionJobs) {
+	if ctx.Err() != nil {
+		return
+	}
+	reqParam := param.ReqParam
+	var entityInstances []*models.BatchExecutionPluginExecEntityInstances
+	for _, job := range jobs {
+		entityInstances = append(entityInstances, &models.BatchExecutionPluginExecEntityInstances{Id: job.RootEntityId, BusinessKeyValue: job.BusinessKey})
+	}
+	var inputParamConstants []*models.BatchExecutionPluginDefInputParams
+	for _, inputParam := range reqParam.InputParameterDefinitions {
+		inputParamConstants = append(inputParamConstants, &models.BatchExecutionPluginDefInputParams{ParamId: inputParam.InputParameter.Id, ParameValue: inputParam.InputParameterValue})
+	}
+	execTime := time.Now()
+	result, pluginCallParam, err := BatchExecutionCallPlugin(ctx, param.Operator, remote.GetToken(), reqParam.PluginConfigInterface.Id, reqParam.DataModelExpression, entityInstances, inputParamConstants, param.ContinueToken)
+	completeTime := time.Now()
+	inputJsonMap := make(map[string]string)
+	if pluginCallParam != nil {
+		for i, input := range pluginCallParam.Inputs {
+			if inputBytes, marshalErr := json.Marshal(input); marshalErr == nil && i < len(pluginCallParam.EntityInstances) {
+				inputJsonMap[pluginCallParam.EntityInstances[i].Id] = string(inputBytes)
+			}
+		}
+	}
+	outputMap := make(map[string]map[string]interface{})
+	if result != nil {
+		for _, output := range result.Outputs {
+			if callbackParam, ok := output[models.PluginCallResultPresetCallback].(string); ok {
+				outputMap[callbackParam] = output
+			}
+		}
+	}
+	for _, job := range jobs {
+		job.ExecuteTime = &execTime
+		job.CompleteTime = &completeTime
+		job.InputJson = inputJsonMap[job.RootEntityId]
+		if err != nil {
+			job.ErrorCode = models.BatchExecErrorCodeFailed
+			job.ErrorMessage = fmt.Sprintf("plugin call error: %s", err.Error())
+			continue
+		}
+		outputData, ok := outputMap[job.RootEntityId]
+		if !ok || outputData == nil {
+			job.ErrorCode = models.BatchExecErrorCodeFailed
+			job.ErrorMessage = "plugin output not found for entity"
+			continue
+		}
+		if returnBytes, marshalErr := json.Marshal(outputData); marshalErr == nil {
+			job.ReturnJson = string(returnBytes)
+		}
+		job.ErrorCode, _ = outputData[models.PluginCallResultPresetErrorCode].(string)
+		job.ErrorMessage, _ = outputData[models.PluginCallResultPresetErrorMsg].(string)
+		if job.ErrorCode != models.BatchExecErrorCodeSucceed {
+			job.ErrorCode = models.BatchExecErrorCodeFailed
+		}
+	}
+	if err != nil {
+		log.Logger.Error("batch execution chunk fail", log.String("batchExecId", param.BatchExecId), log.Int("entityNum", len(jobs)), log.Error(err))
+	}
+	if updateErr := database.UpdateBatchExecJobResults(context.Background(), jobs); updateErr != nil {
+		log.Logger.Error("update batch execution job results fail", log.String("batchExecId", param.BatchExecId), log.Error(updateErr))
+	}
+}
+
+// finishBatchExecution 有实体失败时整体为失败,已取消的保持取消状态
+func finishBatchExecution(param *BatchExecRunParam) {
+	ctx := context.Background()
+	progress, err := database.GetBatchExecProgress(ctx, param.BatchExecId)
+	if err != nil {
+		log.Logger.Error("finish batch execution fail with get progress", log.String("batchExecId", param.BatchExecId), log.Error(err))
+		return
+	}
+	errorCode, errorMessage := models.BatchExecErrorCodeSucceed, ""
+	if progress.Failed > 0 || progress.Running > 0 {
+		// 还有待执行的说明分片结果没有纪录成功,按失败处理以便重试
+		errorCode = models.BatchExecErrorCodeFailed
+		errorMessage = fmt.Sprintf("%d of %d entities failed", progress.Failed+progress.Running, progress.Total)
+	}
+	if err = database.FinishBatchExec(ctx, param.BatchExecId, errorCode, errorMessage, param.Operator); err != nil {
+		log.Logger.Error("finish batch execution fail", log.String("batchExecId", param.BatchExecId), log.Error(err))
+		return
+	}
+	log.Logger.Info("batch execution done", log.String("batchExecId", param.BatchExecId), log.Int("succeed", progress.Succeed), log.Int("failed", progress.Failed), log.Int("canceled", progress.Canceled))
+}
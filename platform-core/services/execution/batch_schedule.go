@@ -0,0 +1,169 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
+)
+
+// RunBatchExecSchedule 定时触发批量执行模板,每次按模板表达式和定时配置的过滤条件重新查询目标实体,以定时配置创建人身份执行
+func RunBatchExecSchedule(ctx context.Context, config *models.BatchExecScheduleConfig) (batchExecId string, entityNum int, err error) {
+	template, getErr := database.GetSimpleBatchExecTemplate(ctx, config.BatchExecutionTemplateId)
+	if getErr != nil {
+		err = getErr
+		return
+	}
+	reqParam := template.ConfigData
+	if reqParam == nil || reqParam.PluginConfigInterface == nil || reqParam.PluginConfigInterface.Id == "" || reqParam.DataModelExpression == "" {
+		err = fmt.Errorf("batch execution template:%s config data illegal", template.Id)
+		return
+	}
+	// 创建后角色或授权可能已变更,每次执行前按创建人当前角色重新校验模板和插件的使用权限
+	if err = checkBatchExecScheduleCreatorPermission(ctx, config.CreatedBy, template.Id, reqParam.PluginConfigInterface.PluginConfigId); err != nil {
+		return
+	}
+	resourceDatas, resolveErr := resolveBatchExecScheduleEntities(ctx, config, reqParam)
+	if resolveErr != nil {
+		err = fmt.Errorf("query target entities fail,%s", resolveErr.Error())
+		return
+	}
+	entityNum = len(resourceDatas)
+	if entityNum == 0 {
+		err = fmt.Errorf("no target entity match expression:%s", reqParam.DataModelExpression)
+		return
+	}
+	reqParam.BatchExecId = ""
+	reqParam.Name = fmt.Sprintf("%s_%s", template.Name, time.Now().Format("20060102150405"))
+	reqParam.BatchExecutionTemplateId = template.Id
+	reqParam.BatchExecutionTemplateName = template.Name
+	reqParam.ResourceDatas = resourceDatas
+	operator := config.CreatedBy
+	if batchExecId, err = database.CreateBatchExec(ctx, reqParam, operator); err != nil {
+		return
+	}
+	var entityInstances []*models.BatchExecutionPluginExecEntityInstances
+	for _, resourceData := range resourceDatas {
+		entityInstances = append(entityInstances, &models.BatchExecutionPluginExecEntityInstances{Id: resourceData.Id, BusinessKeyValue: resourceData.BusinessKeyValue})
+	}
+	var inputParamConstants []*models.BatchExecutionPluginDefInputParams
+	for _, inputParam := range reqParam.InputParameterDefinitions {
+		inputParamConstants = append(inputParamConstants, &models.BatchExecutionPluginDefInputParams{ParamId: inputParam.InputParameter.Id, ParameValue: inputParam.InputParameterValue})
+	}
+	authToken := remote.GetToken()
+	// 定时执行没有人确认高危操作,被拦截时取消全部实体,由用户在批量执行纪录里重试并确认
+	dangerousCheckResult, checkErr := BatchExecutionDangerousCheck(ctx, operator, authToken, reqParam.PluginConfigInterface.Id, reqParam.DataModelExpression, entityInstances, inputParamConstants, "")
+	if checkErr != nil {
+		err = fmt.Errorf("plugin call error: %s", checkErr.Error())
+		if finishErr := database.FinishBatchExec(ctx, batchExecId, models.BatchExecErrorCodeFailed, err.Error(), operator); finishErr != nil {
+			log.Logger.Error("update batch execution record failed", log.String("batchExecId", batchExecId), log.Error(finishErr))
+		}
+		return
+	}
+	jobs, createErr := database.CreateBatchExecPendingJobs(ctx, batchExecId, reqParam, time.Now())
+	if createErr != nil {
+		err = createErr
+		if finishErr := database.FinishBatchExec(ctx, batchExecId, models.BatchExecErrorCodeFailed, err.Error(), operator); finishErr != nil {
+			log.Logger.Error("update batch execution record failed", log.String("batchExecId", batchExecId), log.Error(finishErr))
+		}
+		return
+	}
+	if dangerousCheckResult != nil {
+		log.Logger.Warn("batch execution schedule blocked by dangerous check", log.String("scheduleId", config.Id), log.String("batchExecId", batchExecId), log.JsonObj("dangerousCheckResult", dangerousCheckResult))
+		_, err = database.CancelBatchExec(ctx, batchExecId, operator, "dangerous block, retry to confirm")
+		return
+	}
+	go StartBatchExecution(ctx, &BatchExecRunParam{
+		BatchExecId: batchExecId,
+		Operator:    operator,
+		ReqParam:    reqParam,
+		Jobs:        jobs,
+	})
+	return
+}
+
+// checkBatchExecScheduleCreatorPermission 创建人当前角色需要同时有模板和插件配置的使用权限
+func checkBatchExecScheduleCreatorPermission(ctx context.Context, creator, templateId, pluginConfigId string) (err error) {
+	rolesResp, getErr := remote.GetRolesByUsername(ctx, creator, remote.GetToken(), "en")
+	if getErr != nil {
+		err = fmt.Errorf("get roles of creator:%s fail,%s", creator, getErr.Error())
+		return
+	}
+	if rolesResp.Status != models.DefaultHttpSuccessCode {
+		err = fmt.Errorf("get roles of creator:%s fail,%s", creator, rolesResp.Message)
+		return
+	}
+	userRoleMap := make(map[string]bool)
+	for _, role := range rolesResp.Data {
+		userRoleMap[role.Name] = true
+	}
+	templateRoles, getErr := database.GetBatchExecTemplateUseRoles(ctx, templateId)
+	if getErr != nil {
+		err = getErr
+		return
+	}
+	if !matchAnyRole(userRoleMap, templateRoles) {
+		err = fmt.Errorf("creator:%s has no permission to use batch execution template:%s", creator, templateId)
+		return
+	}
+	pluginRoles, getErr := database.GetPluginConfigUseRoles(ctx, pluginConfigId)
+	if getErr != nil {
+		err = getErr
+		return
+	}
+	if !matchAnyRole(userRoleMap, pluginRoles) {
+		err = fmt.Errorf("creator:%s has no permission to use plugin config:%s", creator, pluginConfigId)
+	}
+	return
+}
+
+func matchAnyRole(userRoleMap map[string]bool, roles []string) bool {
+	for _, role := range roles {
+		if userRoleMap[role] {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveBatchExecScheduleEntities 按表达式查询当前的目标实体,业务主键取模板配置的业务属性
+func resolveBatchExecScheduleEntities(ctx context.Context, config *models.BatchExecScheduleConfig, reqParam *models.BatchExecRun) (resourceDatas []*models.ResourceData, err error) {
+	var filters []*models.QueryExpressionDataFilter
+	if config.EntityFilters != "" {
+		if err = json.Unmarshal([]byte(config.EntityFilters), &filters); err != nil {
+			err = fmt.Errorf("unmarshal entity filters fail,%s", err.Error())
+			return
+		}
+	}
+	exprList, analyzeErr := remote.AnalyzeExpression(reqParam.DataModelExpression)
+	if analyzeErr != nil {
+		err = analyzeErr
+		return
+	}
+	dataList, queryErr := remote.QueryPluginData(ctx, exprList, filters, remote.GetToken())
+	if queryErr != nil {
+		err = queryErr
+		return
+	}
+	businessKeyAttr := "displayName"
+	if reqParam.BusinessKeyAttribute != nil && reqParam.BusinessKeyAttribute.Name != "" {
+		businessKeyAttr = reqParam.BusinessKeyAttribute.Name
+	}
+	for _, data := range dataList {
+		dataId, _ := data["id"].(string)
+		if dataId == "" {
+			continue
+		}
+		businessKey := dataId
+		if keyValue, ok := data[businessKeyAttr]; ok && keyValue != nil {
+			businessKey = fmt.Sprintf("%v", keyValue)
+		}
+		resourceDatas = append(resourceDatas, &models.ResourceData{Id: dataId, BusinessKeyValue: businessKey})
+	}
+	return
+}
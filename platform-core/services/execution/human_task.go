@@ -0,0 +1,218 @@
+package execution
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
+)
+
+// createWorkflowHumanTask 人工节点发出任务后按节点配置生成待办,没配置处理人时默认给编排启动人
+func createWorkflowHumanTask(ctx context.Context, procRunNodeId string, procInsNode *models.ProcInsNode, procDefNode *models.ProcDefNode, procIns *models.ProcIns) (err error) {
+	nowTime := time.Now()
+	task := models.ProcRunHumanTask{
+		Id:            "prht_" + guid.CreateGuid(),
+		ProcRunNodeId: procRunNodeId,
+		ProcInsNodeId: procInsNode.Id,
+		ProcInsId:     procInsNode.ProcInsId,
+		AssigneeType:  models.HumanTaskAssigneeUser,
+		Assignee:      procIns.CreatedBy,
+		Status:        models.HumanTaskStatusPending,
+		CreatedTime:   nowTime,
+	}
+	if taskConfig := models.ConvertString2HumanTaskConfigDto(procDefNode.HumanTaskConfig); taskConfig != nil {
+		if taskConfig.Assignee != "" {
+			task.AssigneeType = taskConfig.AssigneeType
+			task.Assignee = taskConfig.Assignee
+		}
+		if taskConfig.SlaMinutes > 0 {
+			task.DueTime = nowTime.Add(time.Duration(taskConfig.SlaMinutes) * time.Minute)
+		}
+		if taskConfig.EscalationMinutes > 0 && taskConfig.EscalationRole != "" {
+			task.EscalateTime = nowTime.Add(time.Duration(taskConfig.EscalationMinutes) * time.Minute)
+			task.EscalationRole = taskConfig.EscalationRole
+		}
+		task.RemindMinutes = taskConfig.RemindMinutes
+	}
+	err = database.CreateHumanTask(ctx, &task)
+	return
+}
+
+// ReassignHumanTask 转派人工任务给其它角色或用户
+func ReassignHumanTask(ctx context.Context, task *models.ProcRunHumanTask, param *models.HumanTaskAssignParam, operator string) (err error) {
+	if param.AssigneeType != models.HumanTaskAssigneeRole && param.AssigneeType != models.HumanTaskAssigneeUser {
+		err = fmt.Errorf("assigneeType:%s illegal", param.AssigneeType)
+		return
+	}
+	ok, updateErr := database.UpdateHumanTaskAssignee(ctx, task, models.HumanTaskActionReassign, param.AssigneeType, param.Assignee, "", param.Message, operator)
+	if updateErr != nil {
+		err = updateErr
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("human task:%s already done or assignee changed", task.Id)
+		return
+	}
+	origin := *task
+	task.AssigneeType, task.Assignee, task.DelegateFrom = param.AssigneeType, param.Assignee, ""
+	if err = syncPluginTaskAssignee(ctx, &origin, task, models.HumanTaskActionReassign, operator); err != nil {
+		return
+	}
+	sendHumanTaskMail(ctx, task, fmt.Sprintf("Wecube Human Task Reassigned By %s", operator))
+	return
+}
+
+// DelegateHumanTask 当前处理人把任务委托给其它用户,保留委托人纪录
+func DelegateHumanTask(ctx context.Context, task *models.ProcRunHumanTask, param *models.HumanTaskAssignParam, operator string) (err error) {
+	delegateFrom := task.DelegateFrom
+	if delegateFrom == "" {
+		delegateFrom = operator
+	}
+	ok, updateErr := database.UpdateHumanTaskAssignee(ctx, task, models.HumanTaskActionDelegate, models.HumanTaskAssigneeUser, param.Assignee, delegateFrom, param.Message, operator)
+	if updateErr != nil {
+		err = updateErr
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("human task:%s already done or assignee changed", task.Id)
+		return
+	}
+	origin := *task
+	task.AssigneeType, task.Assignee, task.DelegateFrom = models.HumanTaskAssigneeUser, param.Assignee, delegateFrom
+	if err = syncPluginTaskAssignee(ctx, &origin, task, models.HumanTaskActionDelegate, operator); err != nil {
+		return
+	}
+	sendHumanTaskMail(ctx, task, fmt.Sprintf("Wecube Human Task Delegated By %s", operator))
+	return
+}
+
+// syncPluginTaskAssignee 处理人变更同步给人工节点的任务表单插件,同步失败时恢复成变更前的处理人
+func syncPluginTaskAssignee(ctx context.Context, origin, task *models.ProcRunHumanTask, action, operator string) (err error) {
+	if err = notifyPluginTaskAssignee(ctx, task, action, operator); err == nil {
+		return
+	}
+	log.Logger.Error("sync human task assignee to plugin fail,revert assignee", log.String("taskId", task.Id), log.String("action", action), log.Error(err))
+	if revertErr := database.RevertHumanTaskAssignee(ctx, origin, task.AssigneeType, task.Assignee, fmt.Sprintf("%s sync plugin fail,%s", action, err.Error())); revertErr != nil {
+		log.Logger.Error("revert human task assignee fail", log.String("taskId", task.Id), log.Error(revertErr))
+	}
+	*task = *origin
+	err = fmt.Errorf("sync human task assignee to plugin fail,%s", err.Error())
+	return
+}
+
+func notifyPluginTaskAssignee(ctx context.Context, task *models.ProcRunHumanTask, action, operator string) (err error) {
+	procInsNode, err := database.GetSimpleProcInsNode(ctx, task.ProcInsNodeId, "")
+	if err != nil {
+		return
+	}
+	procDefNode, err := database.GetSimpleProcDefNode(ctx, procInsNode.ProcDefNodeId)
+	if err != nil {
+		return
+	}
+	pluginInterface, err := database.GetLastEnablePluginInterface(ctx, procDefNode.ServiceName)
+	if err != nil {
+		return
+	}
+	err = remote.ReassignPluginTask(ctx, pluginInterface, &models.PluginTaskReassignParam{
+		ProcInstId:   task.ProcInsId,
+		NodeDefId:    procDefNode.NodeId,
+		TaskId:       task.Id,
+		Action:       action,
+		AssigneeType: task.AssigneeType,
+		Assignee:     task.Assignee,
+		DelegateFrom: task.DelegateFrom,
+		Operator:     operator,
+	})
+	return
+}
+
+// CheckHumanTaskAssignee 判断用户是否是任务当前处理人
+func CheckHumanTaskAssignee(task *models.ProcRunHumanTask, user string, roles []string) bool {
+	if task.AssigneeType == models.HumanTaskAssigneeUser {
+		return task.Assignee == user
+	}
+	for _, role := range roles {
+		if role == task.Assignee {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleHumanTaskEscalation 扫描运行中人工节点的待办,超过升级时间的转给升级角色,到提醒间隔的发提醒邮件
+func HandleHumanTaskEscalation(ctx context.Context) {
+	taskList, err := database.GetRunningPendingHumanTasks(ctx)
+	if err != nil {
+		log.Logger.Error("handle human task escalation fail with query pending tasks", log.Error(err))
+		return
+	}
+	nowTime := time.Now()
+	for _, row := range taskList {
+		task := &row.ProcRunHumanTask
+		if !task.Escalated && !task.EscalateTime.IsZero() && !task.EscalateTime.After(nowTime) {
+			ok, updateErr := database.UpdateHumanTaskAssignee(ctx, task, models.HumanTaskActionEscalate, models.HumanTaskAssigneeRole, task.EscalationRole, task.DelegateFrom, "escalate after wait timeout", "sys")
+			if updateErr != nil {
+				log.Logger.Error("escalate human task fail", log.String("taskId", task.Id), log.Error(updateErr))
+			} else if ok {
+				log.Logger.Info("escalate human task", log.String("taskId", task.Id), log.String("from", task.Assignee), log.String("to", task.EscalationRole))
+				origin := *task
+				task.AssigneeType, task.Assignee, task.Escalated = models.HumanTaskAssigneeRole, task.EscalationRole, true
+				if syncErr := syncPluginTaskAssignee(ctx, &origin, task, models.HumanTaskActionEscalate, "sys"); syncErr != nil {
+					log.Logger.Error("escalate human task fail with sync plugin task", log.String("taskId", task.Id), log.Error(syncErr))
+					continue
+				}
+				sendHumanTaskMail(ctx, task, fmt.Sprintf("Wecube Human Task Escalated,[%s][%s][%s]", row.ProcDefName, row.EntityDataName, row.NodeName))
+			}
+			continue
+		}
+		if task.RemindMinutes > 0 && !task.LastRemindTime.Add(time.Duration(task.RemindMinutes)*time.Minute).After(nowTime) {
+			ok, updateErr := database.UpdateHumanTaskRemindTime(ctx, task, nowTime)
+			if updateErr != nil {
+				log.Logger.Error("update human task remind time fail", log.String("taskId", task.Id), log.Error(updateErr))
+			} else if ok {
+				subject := fmt.Sprintf("Wecube Human Task Reminder,[%s][%s][%s]", row.ProcDefName, row.EntityDataName, row.NodeName)
+				if !task.DueTime.IsZero() && task.DueTime.Before(nowTime) {
+					subject = fmt.Sprintf("Wecube Human Task Overdue,[%s][%s][%s]", row.ProcDefName, row.EntityDataName, row.NodeName)
+				}
+				sendHumanTaskMail(ctx, task, subject)
+			}
+		}
+	}
+}
+
+// sendHumanTaskMail 给任务当前处理人发邮件,角色取角色邮箱,用户取用户邮箱
+func sendHumanTaskMail(ctx context.Context, task *models.ProcRunHumanTask, subject string) {
+	var accept string
+	if task.AssigneeType == models.HumanTaskAssigneeRole {
+		roleObj, err := remote.RetrieveRoleByRoleName(ctx, task.Assignee, remote.GetToken(), "en")
+		if err != nil {
+			log.Logger.Error("send human task mail fail with get role", log.String("taskId", task.Id), log.String("role", task.Assignee), log.Error(err))
+			return
+		}
+		accept = roleObj.Email
+	} else {
+		userObj, err := remote.RetrieveUserByUsername(ctx, task.Assignee, remote.GetToken(), "en")
+		if err != nil {
+			log.Logger.Error("send human task mail fail with get user", log.String("taskId", task.Id), log.String("user", task.Assignee), log.Error(err))
+			return
+		}
+		accept = userObj.EmailAddr
+	}
+	if accept == "" {
+		log.Logger.Warn("send human task mail ignore with empty mail address", log.String("taskId", task.Id), log.String("assignee", task.Assignee))
+		return
+	}
+	mailObj := models.SendMailTarget{Accept: []string{accept}, Subject: subject}
+	mailObj.Content = subject + fmt.Sprintf("\nProcess Instance Id:%s \nTask Id:%s \nAssignee:%s \nCreated Time:%s \n", task.ProcInsId, task.Id, task.Assignee, task.CreatedTime.Format(models.DateTimeFormat))
+	if !task.DueTime.IsZero() {
+		mailObj.Content = mailObj.Content + fmt.Sprintf("Due Time:%s \n", task.DueTime.Format(models.DateTimeFormat))
+	}
+	if err := remote.SendSmtpMail(mailObj); err != nil {
+		log.Logger.Error("send human task mail fail", log.String("taskId", task.Id), log.Error(err))
+	}
+}
@@ -0,0 +1,228 @@
+package execution
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/metric"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/container"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
+)
+
+const pluginHealthCheckConcurrency = 10
+
+// GetPluginHealthCheckConfig 健康检查配置,没配置的项取默认值
+func GetPluginHealthCheckConfig() models.PluginHealthCheckConfig {
+	healthConfig := models.PluginHealthCheckConfig{}
+	if models.Config.Plugin.HealthCheck != nil {
+		healthConfig = *models.Config.Plugin.HealthCheck
+	}
+	if healthConfig.IntervalSeconds <= 0 {
+		healthConfig.IntervalSeconds = models.DefaultPluginHealthCheckInterval
+	}
+	if healthConfig.TimeoutSeconds <= 0 {
+		healthConfig.TimeoutSeconds = models.DefaultPluginHealthCheckTimeout
+	}
+	if healthConfig.FailureThreshold <= 0 {
+		healthConfig.FailureThreshold = models.DefaultPluginHealthFailThreshold
+	}
+	if healthConfig.MaxRestarts <= 0 {
+		healthConfig.MaxRestarts = models.DefaultPluginMaxRestarts
+	}
+	return healthConfig
+}
+
+// GetPluginInstanceDriver 插件实例所在机器的容器运行时
+func GetPluginInstanceDriver(dockerInstanceResourceId string) (containerDriver container.Driver, err error) {
+	resourceServer, getServerErr := database.GetPluginDockerRunningResource(dockerInstanceResourceId)
+	if getServerErr != nil {
+		err = getServerErr
+		return
+	}
+	containerDriver, err = container.NewDriver(resourceServer)
+	return
+}
+
+// HandlePluginHealthCheck 探测运行中和不健康的插件实例,连续失败达到阈值后从gateway摘除、告警并按配置自动重启,恢复后重新注册
+func HandlePluginHealthCheck(ctx context.Context) {
+	healthConfig := GetPluginHealthCheckConfig()
+	instanceList, err := database.GetPluginHealthCheckInstances(ctx)
+	if err != nil {
+		log.Logger.Error("handle plugin health check fail with query instances", log.Error(err))
+		return
+	}
+	nowTime := time.Now()
+	interval := time.Duration(healthConfig.IntervalSeconds) * time.Second
+	wg := sync.WaitGroup{}
+	concurrencyChan := make(chan int, pluginHealthCheckConcurrency)
+	for _, instance := range instanceList {
+		ok, claimErr := database.ClaimPluginInstanceHealthCheck(ctx, instance.Id, models.Config.HostIp, nowTime, interval)
+		if claimErr != nil {
+			log.Logger.Error("claim plugin instance health check fail", log.String("instanceId", instance.Id), log.Error(claimErr))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		concurrencyChan <- 1
+		go func(instanceObj *models.PluginInstanceHealthQueryObj) {
+			defer func() {
+				<-concurrencyChan
+				wg.Done()
+			}()
+			checkPluginInstanceHealth(ctx, instanceObj, &healthConfig)
+		}(instance)
+	}
+	wg.Wait()
+}
+
+func checkPluginInstanceHealth(ctx context.Context, instance *models.PluginInstanceHealthQueryObj, healthConfig *models.PluginHealthCheckConfig) {
+	nowTime := time.Now()
+	health := instance.PluginInstanceHealth
+	health.InstanceId = instance.Id
+	probeErr := probePluginInstance(ctx, instance, time.Duration(healthConfig.TimeoutSeconds)*time.Second)
+	var containerStatus, mailSubject, mailContent string
+	if probeErr == nil {
+		if health.HealthStatus == models.PluginHealthStatusUnhealthy || instance.ContainerStatus == models.PluginInstanceStatusUnhealthy {
+			log.Logger.Info("plugin instance recover healthy", log.String("instanceId", instance.Id), log.String("plugin", instance.PackageName))
+			mailSubject = fmt.Sprintf("Wecube Plugin Instance Recovered,[%s][%s:%d]", instance.PackageName, instance.Host, instance.Port)
+		}
+		if instance.ContainerStatus == models.PluginInstanceStatusUnhealthy {
+			containerStatus = models.PluginInstanceStatusRunning
+		}
+		health.HealthStatus = models.PluginHealthStatusHealthy
+		health.ConsecutiveFailures = 0
+		health.LastSuccessTime = nowTime
+		health.LastError = ""
+		health.RestartTimes = 0
+	} else {
+		metric.PluginHealthCheckFailures.Inc(instance.PackageName)
+		health.ConsecutiveFailures = health.ConsecutiveFailures + 1
+		health.LastError = probeErr.Error()
+		log.Logger.Warn("plugin instance health check fail", log.String("instanceId", instance.Id), log.String("plugin", instance.PackageName), log.Int("failures", health.ConsecutiveFailures), log.Error(probeErr))
+		if health.ConsecutiveFailures >= healthConfig.FailureThreshold {
+			health.HealthStatus = models.PluginHealthStatusUnhealthy
+			if instance.ContainerStatus == models.PluginInstanceStatusRunning {
+				containerStatus = models.PluginInstanceStatusUnhealthy
+				mailSubject = fmt.Sprintf("Wecube Plugin Instance Unhealthy,[%s][%s:%d]", instance.PackageName, instance.Host, instance.Port)
+			}
+			// 每累计阈值次失败重启一次,给容器留出启动时间
+			if healthConfig.AutoRestart && health.RestartTimes < healthConfig.MaxRestarts && (health.ConsecutiveFailures-healthConfig.FailureThreshold)%healthConfig.FailureThreshold == 0 {
+				mailContent = restartPluginInstance(ctx, instance, &health, nowTime)
+				if mailSubject == "" {
+					mailSubject = fmt.Sprintf("Wecube Plugin Instance Restarted,[%s][%s:%d]", instance.PackageName, instance.Host, instance.Port)
+				}
+			}
+		}
+	}
+	if err := database.UpdatePluginInstanceHealth(ctx, &health, containerStatus); err != nil {
+		log.Logger.Error("update plugin instance health fail", log.String("instanceId", instance.Id), log.Error(err))
+		return
+	}
+	if containerStatus != "" {
+		// gateway从core拉取运行中的实例,状态变化后触发刷新即可摘除或恢复路由
+		if err := remote.RegisterPluginRoute(instance.PackageName, instance.Host, strconv.Itoa(instance.Port)); err != nil {
+			log.Logger.Error("refresh plugin gateway route fail", log.String("instanceId", instance.Id), log.Error(err))
+		}
+	}
+	if mailSubject != "" {
+		sendPluginHealthMail(ctx, healthConfig.AlarmRole, instance, &health, mailSubject, mailContent)
+	}
+}
+
+// probePluginInstance 配置了健康检查路径的走http探测,否则检查容器是否在运行
+func probePluginInstance(ctx context.Context, instance *models.PluginInstanceHealthQueryObj, timeout time.Duration) (err error) {
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if instance.HealthCheckPath == "" {
+		containerDriver, getDriverErr := GetPluginInstanceDriver(instance.DockerInstanceResourceId)
+		if getDriverErr != nil {
+			err = fmt.Errorf("get container driver fail,%s ", getDriverErr.Error())
+			return
+		}
+		state, inspectErr := containerDriver.Inspect(probeCtx, instance.ContainerName)
+		if inspectErr != nil {
+			err = fmt.Errorf("inspect container fail,%s ", inspectErr.Error())
+			return
+		}
+		if !state.Running {
+			err = fmt.Errorf("container %s not running,status:%s exitCode:%d %s", instance.ContainerName, state.Status, state.ExitCode, state.Error)
+		}
+		return
+	}
+	checkPath := instance.HealthCheckPath
+	if !strings.HasPrefix(checkPath, "/") {
+		checkPath = "/" + checkPath
+	}
+	req, reqErr := http.NewRequestWithContext(probeCtx, http.MethodGet, fmt.Sprintf("http://%s:%d%s", instance.Host, instance.Port, checkPath), nil)
+	if reqErr != nil {
+		err = fmt.Errorf("new health check request fail,%s ", reqErr.Error())
+		return
+	}
+	resp, respErr := http.DefaultClient.Do(req)
+	if respErr != nil {
+		err = fmt.Errorf("do health check request fail,%s ", respErr.Error())
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		err = fmt.Errorf("health check %s response status code %d", checkPath, resp.StatusCode)
+	}
+	return
+}
+
+// restartPluginInstance 通过容器运行时重启插件实例,返回写进告警邮件的说明
+func restartPluginInstance(ctx context.Context, instance *models.PluginInstanceHealthQueryObj, health *models.PluginInstanceHealth, nowTime time.Time) (message string) {
+	health.RestartTimes = health.RestartTimes + 1
+	health.LastRestartTime = nowTime
+	containerDriver, err := GetPluginInstanceDriver(instance.DockerInstanceResourceId)
+	if err == nil {
+		err = containerDriver.Restart(ctx, instance.ContainerName)
+	}
+	if err != nil {
+		metric.PluginInstanceRestarts.Inc(instance.PackageName, "fail")
+		log.Logger.Error("restart unhealthy plugin instance fail", log.String("instanceId", instance.Id), log.String("container", instance.ContainerName), log.Error(err))
+		message = fmt.Sprintf("Auto Restart(%d):fail,%s \n", health.RestartTimes, err.Error())
+		return
+	}
+	metric.PluginInstanceRestarts.Inc(instance.PackageName, "success")
+	log.Logger.Info("restart unhealthy plugin instance", log.String("instanceId", instance.Id), log.String("container", instance.ContainerName), log.Int("restartTimes", health.RestartTimes))
+	message = fmt.Sprintf("Auto Restart(%d):success \n", health.RestartTimes)
+	return
+}
+
+// sendPluginHealthMail 给告警角色邮箱发插件实例健康状态变化邮件
+func sendPluginHealthMail(ctx context.Context, alarmRole string, instance *models.PluginInstanceHealthQueryObj, health *models.PluginInstanceHealth, subject, extContent string) {
+	if alarmRole == "" {
+		return
+	}
+	roleObj, err := remote.RetrieveRoleByRoleName(ctx, alarmRole, remote.GetToken(), "en")
+	if err != nil {
+		log.Logger.Error("send plugin health mail fail with get role", log.String("role", alarmRole), log.Error(err))
+		return
+	}
+	if roleObj.Email == "" {
+		log.Logger.Warn("send plugin health mail ignore with empty role mail address", log.String("role", alarmRole))
+		return
+	}
+	mailObj := models.SendMailTarget{Accept: []string{roleObj.Email}, Subject: subject}
+	mailObj.Content = subject + fmt.Sprintf("\nPlugin:%s %s \nInstance Id:%s \nContainer:%s \nConsecutive Failures:%d \n", instance.PackageName, instance.PackageVersion, instance.Id, instance.ContainerName, health.ConsecutiveFailures)
+	if health.LastError != "" {
+		mailObj.Content = mailObj.Content + fmt.Sprintf("Last Error:%s \n", health.LastError)
+	}
+	mailObj.Content = mailObj.Content + extContent
+	if err = remote.SendSmtpMail(mailObj); err != nil {
+		log.Logger.Error("send plugin health mail fail", log.String("instanceId", instance.Id), log.Error(err))
+	}
+}
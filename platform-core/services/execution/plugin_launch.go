@@ -0,0 +1,352 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/go-common-lib/cipher"
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/tools"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/bash"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/container"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
+)
+
+// LaunchPluginInstance 创建插件实例,准备插件数据库后在目标主机启动容器并纪录实例,gateway路由由调用方注册
+func LaunchPluginInstance(ctx context.Context, param *models.PluginLaunchParam) (pluginInstance *models.PluginInstances, pluginPackageObj *models.PluginPackages, err error) {
+	pluginPackageObj = &models.PluginPackages{Id: param.PackageId}
+	if err = database.GetSimplePluginPackage(ctx, pluginPackageObj, true); err != nil {
+		log.Logger.Error("GetSimplePluginPackage fail", log.Error(err))
+		return
+	}
+	log.Logger.Debug("pluginPackage", log.JsonObj("data", pluginPackageObj))
+	resources, getResourceErr := database.GetPluginRuntimeResources(ctx, param.PackageId)
+	if getResourceErr != nil {
+		log.Logger.Error("GetPluginRuntimeResources fail", log.Error(getResourceErr))
+		err = getResourceErr
+		return
+	}
+	if len(resources.Docker) == 0 {
+		err = fmt.Errorf("plugin must contain docker resource")
+		return
+	}
+	portValue := strconv.Itoa(param.Port)
+	pluginInstance = &models.PluginInstances{
+		Id:              "p_docker_" + guid.CreateGuid(),
+		Host:            param.HostIp,
+		Port:            param.Port,
+		ContainerStatus: models.PluginInstanceStatusRunning,
+		PackageId:       param.PackageId,
+		InstanceName:    pluginPackageObj.Name,
+	}
+	var mysqlInstance *models.PluginMysqlInstances
+	var mysqlServer *models.ResourceServer
+	if len(resources.Mysql) > 0 {
+		pluginInstance.PluginMysqlInstanceResourceId = resources.Mysql[0].Id
+		if mysqlInstance, mysqlServer, err = PreparePluginDatabase(ctx, pluginPackageObj, resources.Mysql[0], param.Operator, param.SkipDbUpgrade); err != nil {
+			return
+		}
+	}
+	dockerResource := resources.Docker[0]
+	pluginInstance.ContainerName = dockerResource.ContainerName
+	if param.ContainerName != "" {
+		pluginInstance.ContainerName = param.ContainerName
+	}
+	dockerServer, getDockerServerErr := database.GetResourceServer(ctx, models.ResourceServerTypeDocker, param.HostIp)
+	if getDockerServerErr != nil {
+		// 不是docker主机时再找kubernetes集群
+		k8sServer, getK8sServerErr := database.GetResourceServer(ctx, models.ResourceServerTypeK8s, param.HostIp)
+		if getK8sServerErr != nil {
+			err = getDockerServerErr
+			return
+		}
+		dockerServer = k8sServer
+	}
+	containerDriver, newDriverErr := container.NewDriver(dockerServer)
+	if newDriverErr != nil {
+		err = newDriverErr
+		return
+	}
+	envMap := make(map[string]string)
+	portBindList := getEnvMap(dockerResource.PortBindings, envMap)
+	volumeBindList := getEnvMap(dockerResource.VolumeBindings, envMap)
+	envBindList := getEnvMap(dockerResource.EnvVariables, envMap)
+	envMap["ALLOCATE_PORT"] = portValue
+	envMap["ALLOCATE_HOST"] = param.HostIp
+	if endpointDriver, ok := containerDriver.(container.EndpointDriver); ok {
+		// kubernetes上插件通过Service访问,不是集群api地址
+		envMap["ALLOCATE_HOST"] = endpointDriver.ServiceHost(pluginInstance.ContainerName)
+	}
+	envMap["BASE_MOUNT_PATH"] = models.Config.Plugin.BaseMountPath
+	envMap["MONITOR_PORT"] = fmt.Sprintf("%d", param.Port+10000)
+	if mysqlInstance != nil {
+		envMap["DB_SCHEMA"] = mysqlInstance.SchemaName
+		envMap["DB_USER"] = mysqlInstance.Username
+		if models.Config.Plugin.PasswordPubKeyContent != "" {
+			if encryptDBPwd, enErr := cipher.EncryptRsa(mysqlInstance.Password, models.Config.Plugin.PasswordPubKeyContent); enErr != nil {
+				log.Logger.Error("Try to encrypt plugin password fail", log.Error(enErr))
+				envMap["DB_PWD"] = mysqlInstance.Password
+			} else {
+				envMap["DB_PWD"] = encryptDBPwd
+			}
+		} else {
+			envMap["DB_PWD"] = mysqlInstance.Password
+		}
+		if mysqlServer != nil {
+			envMap["DB_HOST"] = mysqlServer.Host
+			envMap["DB_PORT"] = mysqlServer.Port
+		}
+	}
+	// 向auth server注册插件并返回插件认证的code和pubKey,插件会拿着这两个东西去获取插件专属的token来访问platform
+	subSystemCode, subSystemKey, subSystemPubKey, registerAuthErr := remote.RegisterSubSystem(ctx, pluginPackageObj)
+	if registerAuthErr != nil {
+		err = registerAuthErr
+		return
+	}
+	envMap["SUB_SYSTEM_CODE"] = subSystemCode
+	envMap["SUB_SYSTEM_KEY"] = subSystemKey
+	if models.Config.Auth.JwtSigningKey != "" {
+		if models.Config.Plugin.PasswordPubKeyContent != "" {
+			if encryptJwtKey, enErr := cipher.EncryptRsa(models.Config.Auth.JwtSigningKey, models.Config.Plugin.PasswordPubKeyContent); enErr != nil {
+				envMap["JWT_SIGNING_KEY"] = models.Config.Auth.JwtSigningKey
+			} else {
+				envMap["JWT_SIGNING_KEY"] = encryptJwtKey
+			}
+		} else {
+			envMap["JWT_SIGNING_KEY"] = models.Config.Auth.JwtSigningKey
+		}
+	}
+	// 企业版的认证信息环境变量
+	if pluginPackageObj.Edition == "enterprise" {
+		licCode, licPk, licData, licSign, getLicenceErr := database.GeneratePluginEnv(subSystemPubKey, subSystemKey, pluginPackageObj.Name)
+		if getLicenceErr != nil {
+			err = getLicenceErr
+			return
+		}
+		envBindList = append(envBindList, "LICENSE_CODE="+licCode)
+		envBindList = append(envBindList, "LICENSE_PK="+licPk)
+		envBindList = append(envBindList, "LICENSE_DATA="+licData)
+		envBindList = append(envBindList, "LICENSE_SIGNATURE="+licSign)
+	}
+	// 替换容器参数差异化变量
+	replaceMap, buildEnvErr := database.BuildDockerEnvMap(ctx, envMap)
+	if buildEnvErr != nil {
+		err = buildEnvErr
+		return
+	}
+	portBindList = replaceEnvMap(portBindList, replaceMap)
+	volumeBindList = replaceEnvMap(volumeBindList, replaceMap)
+	envBindList = replaceEnvMap(envBindList, replaceMap)
+	// 先检查目标机器上有没有相关版本容器镜像，如果有的话就跳过下面两个下载和传镜像的操作
+	// 把s3上的image.tar下载来到本地？可否直接让目标机器下载image.tar
+	// kubernetes从镜像仓库拉取镜像,不用传image.tar
+	if dockerServer.Type != models.ResourceServerTypeK8s {
+		tmpImageFile, downloadImageErr := bash.DownloadPackageFile(models.Config.S3.PluginPackageBucket, fmt.Sprintf("%s/%s/image.tar", pluginPackageObj.Name, pluginPackageObj.Version))
+		if downloadImageErr != nil {
+			err = downloadImageErr
+			return
+		}
+		// 把image.tar导入到目标机器
+		if err = containerDriver.LoadImage(ctx, tmpImageFile, dockerResource.ImageName); err != nil {
+			return
+		}
+	}
+	// 去目标机器上把容器运行起来
+	containerId, runErr := containerDriver.Run(ctx, &models.ContainerRunParam{
+		Name:           pluginInstance.ContainerName,
+		Image:          dockerResource.ImageName,
+		PortBindings:   portBindList,
+		VolumeBindings: volumeBindList,
+		EnvVariables:   envBindList,
+	})
+	if runErr != nil {
+		err = runErr
+		return
+	}
+	log.Logger.Info("run plugin container", log.String("targetHost", dockerServer.Host), log.String("containerName", pluginInstance.ContainerName), log.String("containerId", containerId))
+	if endpointDriver, ok := containerDriver.(container.EndpointDriver); ok {
+		// kubernetes等待pod就绪,未就绪的实例先纪录状态不注册路由,查询容器状态时再刷新
+		state, waitErr := container.WaitRunning(ctx, containerDriver, pluginInstance.ContainerName, time.Duration(container.GetK8sConfig().ReadyTimeout)*time.Second)
+		if waitErr != nil {
+			err = waitErr
+			removeLaunchFailContainer(ctx, containerDriver, pluginInstance.ContainerName)
+			return
+		}
+		pluginInstance.ContainerStatus = container.InstanceStatus(state)
+		routeHost, routePort, endpointErr := endpointDriver.Endpoint(ctx, pluginInstance.ContainerName)
+		if endpointErr != nil {
+			err = endpointErr
+			removeLaunchFailContainer(ctx, containerDriver, pluginInstance.ContainerName)
+			return
+		}
+		pluginInstance.Host = routeHost
+		pluginInstance.Port, _ = strconv.Atoi(routePort)
+		log.Logger.Info("plugin service endpoint", log.String("containerName", pluginInstance.ContainerName), log.String("host", routeHost), log.String("port", routePort), log.String("status", pluginInstance.ContainerStatus))
+	}
+	if param.Standby && pluginInstance.ContainerStatus == models.PluginInstanceStatusRunning {
+		pluginInstance.ContainerStatus = models.PluginInstanceStatusStandby
+	}
+	// 更新插件注册的菜单状态和更新插件实例数据
+	resourceItemProperties := models.ResourceItemProperties{
+		ImageName:      dockerResource.ImageName,
+		PortBindings:   strings.Join(portBindList, ","),
+		VolumeBindings: strings.Join(volumeBindList, ","),
+		EnvVariables:   strings.Join(envBindList, ","),
+		AllocatePort:   param.Port,
+	}
+	resourceItemPropertiesBytes, _ := json.Marshal(&resourceItemProperties)
+	resourceItem := models.ResourceItem{
+		Id:                   "rs_item_" + guid.CreateGuid(),
+		ResourceServerId:     dockerServer.Id,
+		AdditionalProperties: string(resourceItemPropertiesBytes),
+		CreatedBy:            param.Operator,
+		CreatedDate:          time.Now(),
+		Name:                 pluginInstance.ContainerName,
+	}
+	pluginInstance.DockerInstanceResourceId = resourceItem.Id
+	err = database.LaunchPlugin(ctx, pluginInstance, &resourceItem)
+	return
+}
+
+// removeLaunchFailContainer 启动失败时清理已创建的容器,kubernetes上是Deployment、Service和Secret,避免残留占用端口
+func removeLaunchFailContainer(ctx context.Context, containerDriver container.Driver, containerName string) {
+	if removeErr := containerDriver.Remove(ctx, containerName, ""); removeErr != nil {
+		log.Logger.Error("remove launch fail container fail", log.String("containerName", containerName), log.Error(removeErr))
+	}
+}
+
+// PreparePluginDatabase 第一次部署时创建插件数据库,插件版本比数据库脚本纪录的版本新时执行升级脚本,同一版本只执行一次
+// skipUpgrade为true时已有的数据库不执行升级脚本,新建的数据库没有其它实例在用,照常执行初始化脚本
+func PreparePluginDatabase(ctx context.Context, pluginPackageObj *models.PluginPackages, mysqlResource *models.PluginPackageRuntimeResourcesMysql, operator string, skipUpgrade bool) (mysqlInstance *models.PluginMysqlInstances, mysqlServer *models.ResourceServer, err error) {
+	// 先检查数据库脚本执行纪录的版本，如果执行过了就跳过下面数据库相关操作
+	if mysqlInstance, err = database.GetPluginMysqlInstance(ctx, pluginPackageObj.Name); err != nil {
+		return
+	}
+	// 如果连纪录都没有，第一次要创建数据库
+	if mysqlServer, err = database.GetResourceServer(ctx, "mysql", ""); err != nil {
+		return
+	}
+	if mysqlInstance == nil {
+		dbPass, createErr := bash.CreatePluginDatabase(ctx, pluginPackageObj.Name, mysqlResource, mysqlServer)
+		if createErr != nil {
+			err = createErr
+			return
+		}
+		mysqlInstance = &models.PluginMysqlInstances{
+			Id:              "p_mysql_" + guid.CreateGuid(),
+			Password:        dbPass,
+			PluginPackageId: pluginPackageObj.Id,
+			ResourceItemId:  mysqlResource.Id,
+			SchemaName:      mysqlResource.SchemaName,
+			Username:        pluginPackageObj.Name,
+		}
+		log.Logger.Debug("database pwd", log.String("pass", dbPass))
+		if err = database.NewPluginMysqlInstance(ctx, mysqlServer, mysqlInstance, operator); err != nil {
+			return
+		}
+	} else if skipUpgrade {
+		return
+	}
+	if !tools.CompareVersion(pluginPackageObj.Version, mysqlInstance.PreVersion) {
+		return
+	}
+	// 把s3上的init.sql下载来到本地
+	var intiSqlFile, upgradeSqlFile string
+	if mysqlResource.InitFileName != "" {
+		tmpFile, downloadErr := bash.DownloadPackageFile(models.Config.S3.PluginPackageBucket, fmt.Sprintf("%s/%s/%s", pluginPackageObj.Name, pluginPackageObj.Version, mysqlResource.InitFileName))
+		if downloadErr != nil {
+			err = downloadErr
+			return
+		}
+		intiSqlFile = tmpFile
+	}
+	if mysqlResource.UpgradeFileName != "" {
+		tmpFile, downloadErr := bash.DownloadPackageFile(models.Config.S3.PluginPackageBucket, fmt.Sprintf("%s/%s/%s", pluginPackageObj.Name, pluginPackageObj.Version, mysqlResource.UpgradeFileName))
+		if downloadErr != nil {
+			log.Logger.Warn("plugin have no upgrade sql", log.String("plugin", pluginPackageObj.Name), log.String("version", pluginPackageObj.Version))
+		} else {
+			upgradeSqlFile = tmpFile
+		}
+	}
+	// 检查数据库脚本是否有更新
+	outputSqlFile, buildErr := bash.BuildPluginUpgradeSqlFile(intiSqlFile, upgradeSqlFile, mysqlInstance.PreVersion)
+	if buildErr != nil {
+		err = buildErr
+		return
+	}
+	// 执行数据库脚本并更新纪录
+	if outputSqlFile != "" {
+		if err = bash.ExecPluginUpgradeSql(ctx, mysqlInstance, mysqlServer, outputSqlFile); err != nil {
+			return
+		}
+	}
+	if err = database.UpdatePluginMysqlInstancePreVersion(ctx, mysqlInstance.Id, pluginPackageObj.Version); err != nil {
+		return
+	}
+	mysqlInstance.PreVersion = pluginPackageObj.Version
+	return
+}
+
+// RemovePluginInstance 销毁插件实例容器并删除实例纪录,keepImage为true时保留镜像给其它实例使用
+func RemovePluginInstance(ctx context.Context, pluginInstanceObj *models.PluginInstances, keepImage bool) (err error) {
+	// 查询容器资源信息
+	containerDriver, getDriverErr := GetPluginInstanceDriver(pluginInstanceObj.DockerInstanceResourceId)
+	if getDriverErr != nil {
+		err = getDriverErr
+		return
+	}
+	// 查询容器运行信息
+	imageName, containerName, getErr := database.GetPluginDockerRuntimeMessage(pluginInstanceObj.PackageId)
+	if getErr != nil {
+		err = getErr
+		return
+	}
+	// 滚动升级启动的实例容器名和插件注册的不一样,以实例纪录为准
+	if pluginInstanceObj.ContainerName != "" {
+		containerName = pluginInstanceObj.ContainerName
+	}
+	if keepImage {
+		imageName = ""
+	}
+	// 销毁容器
+	if err = containerDriver.Remove(ctx, containerName, imageName); err != nil {
+		return
+	}
+	// 更新插件注册的菜单状态和更新插件实例数据
+	err = database.RemovePlugin(ctx, pluginInstanceObj.PackageId, pluginInstanceObj.Id, pluginInstanceObj.DockerInstanceResourceId)
+	return
+}
+
+func getEnvMap(input string, envMap map[string]string) (inputList []string) {
+	re, _ := regexp.Compile(".*{{(.*)}}.*")
+	inputList = strings.Split(input, ",")
+	for _, v := range inputList {
+		for i, matchEnv := range re.FindStringSubmatch(v) {
+			if i == 0 {
+				continue
+			}
+			envMap[matchEnv] = ""
+		}
+	}
+	return
+}
+func replaceEnvMap(inputList []string, replaceMap map[string]string) (outputList []string) {
+	for _, input := range inputList {
+		inputV := input
+		if strings.Contains(input, "{{") {
+			for k, v := range replaceMap {
+				inputV = strings.ReplaceAll(inputV, k, v)
+			}
+		}
+		outputList = append(outputList, inputV)
+	}
+	return
+}
@@ -0,0 +1,212 @@
+package execution
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/bash"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+)
+
+// UploadPluginPackageArchive 解析插件包,把包里的文件传到s3并写入插件数据,返回插件包id
+func UploadPluginPackageArchive(ctx context.Context, archiveFilePath string) (pluginPkgId string, err error) {
+	tmpFileDir := fmt.Sprintf("/tmp/%d", time.Now().UnixNano())
+	if err = os.MkdirAll(tmpFileDir, 0777); err != nil {
+		err = fmt.Errorf("make tmp dir fail,%s ", err.Error())
+		return
+	}
+	// 上传包
+	log.Logger.Debug("tmpFile", log.String("tmpFileDir", tmpFileDir))
+	defer func() {
+		if removeTmpDirErr := os.RemoveAll(tmpFileDir); removeTmpDirErr != nil {
+			log.Logger.Error("Try to remove package upload tmp dir fail", log.String("tmpDir", tmpFileDir), log.Error(removeTmpDirErr))
+		}
+	}()
+	if _, err = bash.DecompressFile(archiveFilePath, tmpFileDir); err != nil {
+		return
+	}
+	packageFiles, readErr := bash.ListDirFiles(tmpFileDir)
+	if readErr != nil {
+		err = readErr
+		return
+	}
+	// 解析xml文件
+	var registerFile, imageFile, uiFile, initSql, upgradeSql string
+	withUi := false
+	for _, v := range packageFiles {
+		if v == "register.xml" {
+			registerFile = v
+			continue
+		}
+		if v == "image.tar" {
+			imageFile = v
+			continue
+		}
+		if v == "ui.zip" {
+			uiFile = v
+			withUi = true
+			continue
+		}
+	}
+	if registerFile == "" || imageFile == "" {
+		err = fmt.Errorf("register xml and image tar can not empty")
+		return
+	}
+	var registerConfig models.RegisterXML
+	registerConfigBytes, readRegisterConfigErr := os.ReadFile(fmt.Sprintf("%s/%s", tmpFileDir, registerFile))
+	if readRegisterConfigErr != nil {
+		err = fmt.Errorf("read register xml file fail,%s ", readRegisterConfigErr.Error())
+		return
+	}
+	if err = xml.Unmarshal(registerConfigBytes, &registerConfig); err != nil {
+		err = fmt.Errorf("xml unmarshal regisger xml fail,%s ", err.Error())
+		return
+	}
+	pluginPackageObj := models.PluginPackages{Name: registerConfig.Name, Version: registerConfig.Version}
+	if err = database.GetSimplePluginPackage(ctx, &pluginPackageObj, false); err != nil {
+		return
+	}
+	if pluginPackageObj.Id != "" {
+		err = fmt.Errorf("plugin %s:%s already existed", registerConfig.Name, registerConfig.Version)
+		return
+	}
+	if registerConfig.ResourceDependencies.Mysql.InitFileName != "" {
+		initSql = registerConfig.ResourceDependencies.Mysql.InitFileName
+		upgradeSql = registerConfig.ResourceDependencies.Mysql.UpgradeFileName
+		if !bash.ListContains(packageFiles, initSql) {
+			err = fmt.Errorf("init sql file:%s can not find in package", initSql)
+			return
+		}
+		if !bash.ListContains(packageFiles, upgradeSql) {
+			upgradeSql = ""
+		}
+	}
+	// 上传解压后的文件到s3
+	s3Prefix := fmt.Sprintf("%s/%s/", registerConfig.Name, registerConfig.Version)
+	s3FileMap := make(map[string]string)
+	s3FileMap[fmt.Sprintf("%s/%s", tmpFileDir, registerFile)] = s3Prefix + registerFile
+	s3FileMap[fmt.Sprintf("%s/%s", tmpFileDir, imageFile)] = s3Prefix + imageFile
+	if uiFile != "" {
+		s3FileMap[fmt.Sprintf("%s/%s", tmpFileDir, uiFile)] = s3Prefix + uiFile
+	}
+	if initSql != "" {
+		s3FileMap[fmt.Sprintf("%s/%s", tmpFileDir, initSql)] = s3Prefix + initSql
+	}
+	if upgradeSql != "" {
+		s3FileMap[fmt.Sprintf("%s/%s", tmpFileDir, upgradeSql)] = s3Prefix + upgradeSql
+	}
+	if err = bash.UploadPluginPackage(models.Config.S3.PluginPackageBucket, s3FileMap); err != nil {
+		return
+	}
+	if registerConfig.ResourceDependencies.S3.BucketName != "" {
+		if err = bash.MakeBucket(registerConfig.ResourceDependencies.S3.BucketName); err != nil {
+			return
+		}
+	}
+	// 写数据库
+	pkgId := "plugin_" + guid.CreateGuid()
+	err = database.UploadPackage(ctx, &registerConfig, withUi, false, pkgId)
+	if err != nil {
+		return
+	}
+	return pkgId, nil
+}
+
+// RegisterPluginPackage 检查依赖后注册插件包,有ui的把静态文件传到静态资源服务器
+func RegisterPluginPackage(ctx context.Context, pluginPackageObj *models.PluginPackages) (err error) {
+	pluginPackageId := pluginPackageObj.Id
+	// 依赖包检测
+	depOk, checkErr := database.CheckPluginPackageDependence(ctx, pluginPackageId)
+	if checkErr != nil {
+		err = checkErr
+		return
+	}
+	if !depOk {
+		err = exterror.New().PluginDependencyIllegal
+		return
+	}
+	if pluginPackageObj.UiPackageIncluded {
+		if len(models.Config.StaticResources) == 0 {
+			err = fmt.Errorf("static resource config empty")
+			return
+		}
+		// 把s3上的ui.zip下下来放到本地
+		log.Logger.Debug("register plugin,start download ui.zip")
+		var uiFileLocalPath, uiDir string
+		if uiFileLocalPath, err = bash.DownloadPackageFile(models.Config.S3.PluginPackageBucket, fmt.Sprintf("%s/%s/ui.zip", pluginPackageObj.Name, pluginPackageObj.Version)); err != nil {
+			return
+		}
+		log.Logger.Debug("register plugin,start decompress ui.zip", log.String("uiFileLocalPath", uiFileLocalPath))
+		// 本地解压ui.zip
+		if uiDir, err = bash.DecompressFile(uiFileLocalPath, ""); err != nil {
+			return
+		}
+		// 把ui.zip用ssh传到静态资源服务器上并解压，如果有两台服务器，则每台都要上传与解压
+		for _, staticResourceObj := range models.Config.StaticResources {
+			targetPath := fmt.Sprintf("%s/%s/%s/ui.zip", staticResourceObj.Path, pluginPackageObj.Name, pluginPackageObj.Version)
+			unzipCmd := fmt.Sprintf("cd %s/%s/%s && unzip -o ui.zip", staticResourceObj.Path, pluginPackageObj.Name, pluginPackageObj.Version)
+			log.Logger.Debug("register plugin,start scp ui.zip to remote host", log.String("server", staticResourceObj.Server), log.String("targetPath", targetPath))
+			if err = bash.RemoteSCP(staticResourceObj.Server, staticResourceObj.User, staticResourceObj.Password, staticResourceObj.Port, uiFileLocalPath, targetPath); err != nil {
+				break
+			}
+			log.Logger.Debug("register plugin,start unzip ui.zip in remote host", log.String("server", staticResourceObj.Server), log.String("unzipCmd", unzipCmd))
+			if err = bash.RemoteSSHCommand(staticResourceObj.Server, staticResourceObj.User, staticResourceObj.Password, staticResourceObj.Port, unzipCmd); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			return
+		}
+		// 把ui.zip里的静态文件读出来
+		var fileNameList []string
+		indexPath, matchIndexFlag, findErr := bash.GetDirIndexPath(uiDir)
+		if findErr != nil {
+			err = findErr
+			return
+		}
+		if !matchIndexFlag {
+			err = fmt.Errorf("can not find index.html in ui package")
+			return
+		}
+		log.Logger.Debug("match index path", log.String("indexPath", indexPath))
+		indexPath = strings.TrimSuffix(indexPath, "/")
+		dirPrefix := uiDir
+		if indexPath != "" {
+			dirPrefix = uiDir + "/" + indexPath
+		}
+		fileNameList, err = bash.ListDirAllFiles(dirPrefix)
+		if err != nil {
+			return
+		}
+		uiStaticPath := models.Config.StaticResources[0].Path
+		if pathIndex := strings.LastIndex(uiStaticPath, "/"); pathIndex >= 0 {
+			uiStaticPath = uiStaticPath[pathIndex:]
+		}
+		uiStaticPath = fmt.Sprintf("%s/%s/%s", uiStaticPath, pluginPackageObj.Name, pluginPackageObj.Version)
+		if indexPath != "" {
+			uiStaticPath = uiStaticPath + "/" + indexPath
+		}
+		resourceFileList := []*models.PluginPackageResourceFiles{}
+		for _, v := range fileNameList {
+			tmpResourceObj := models.PluginPackageResourceFiles{PluginPackageId: pluginPackageId, PackageName: pluginPackageObj.Name, PackageVersion: pluginPackageObj.Version, Source: "ui.zip", RelatedPath: strings.ReplaceAll(v, dirPrefix, uiStaticPath)}
+			resourceFileList = append(resourceFileList, &tmpResourceObj)
+		}
+		if len(resourceFileList) > 0 {
+			log.Logger.Debug("register plugin,start update plugin static resource file data", log.JsonObj("resourceFileList", resourceFileList))
+			if err = database.UpdatePluginStaticResourceFiles(ctx, pluginPackageId, resourceFileList); err != nil {
+				return
+			}
+		}
+	}
+	// 把对应插件版本的系统变量置为active
+	err = database.RegisterPlugin(ctx, pluginPackageObj.Name, pluginPackageObj.Version)
+	return
+}
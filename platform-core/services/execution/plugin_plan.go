@@ -0,0 +1,692 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/tools"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/try"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
+)
+
+const (
+	pluginPlatformDependencyName = "platform"
+	// pluginPlanMaxOnlineTries 解析一个依赖时最多读取几个在线插件包的register.xml确认版本
+	pluginPlanMaxOnlineTries = 5
+	// pluginPlanMaxResolveRounds 带着上一轮收集的约束重新解析的最大轮数
+	pluginPlanMaxResolveRounds = 10
+	// pluginPlanUpgradeLaunchTimeout 等待滚动升级时在健康检查和观察期之外给启动实例和执行数据库脚本预留的时间
+	pluginPlanUpgradeLaunchTimeout = 10 * time.Minute
+)
+
+var artifactVersionRegexp = regexp.MustCompile(`[vV]?([0-9]+(?:\.[0-9]+)+)`)
+
+// pluginPlanCandidate 可选的插件版本
+type pluginPlanCandidate struct {
+	name         string
+	version      string
+	packageId    string
+	source       string
+	running      bool
+	artifactKey  string
+	dependencies []*models.PluginPlanDependency
+}
+
+// pluginPlanConstraint 计划里的插件对依赖提出的版本约束
+type pluginPlanConstraint struct {
+	requiredBy string
+	constraint *tools.VersionConstraint
+}
+
+// pluginPlanner 从指定插件出发解析依赖,本地运行中的版本优先,其次已注册、已上传,最后在线插件仓库
+// 同名依赖要同时满足所有约束,一轮解析有冲突时带着这一轮收集的约束重新解析,直到选择的版本不再变化
+type pluginPlanner struct {
+	ctx              context.Context
+	param            *models.PluginInstallPlanParam
+	localCandidates  map[string][]*pluginPlanCandidate
+	runningVersion   map[string]string
+	onlineKeys       []string
+	onlineLoaded     bool
+	onlineCandidates map[string]*pluginPlanCandidate
+	items            map[string]*models.PluginPlanItem
+	candidates       map[string]*pluginPlanCandidate
+	conflicts        []*models.PluginPlanConflict
+	prevConstraints  map[string][]*pluginPlanConstraint
+	roundConstraints map[string][]*pluginPlanConstraint
+}
+
+// PreviewPluginInstallPlan 计算安装计划,不执行
+func PreviewPluginInstallPlan(ctx context.Context, param *models.PluginInstallPlanParam) (plan *models.PluginInstallPlan, err error) {
+	planner, buildErr := buildPluginInstallPlan(ctx, param)
+	if buildErr != nil {
+		err = buildErr
+		return
+	}
+	plan = planner.plan()
+	return
+}
+
+// StartPluginInstallPlan 计算安装计划,没有冲突时按拓扑顺序在后台执行
+func StartPluginInstallPlan(ctx context.Context, param *models.PluginInstallPlanParam, operator string) (record *models.PluginInstallPlanRecord, err error) {
+	planner, buildErr := buildPluginInstallPlan(ctx, param)
+	if buildErr != nil {
+		err = buildErr
+		return
+	}
+	plan := planner.plan()
+	if err = checkPluginInstallPlanExecutable(plan, param); err != nil {
+		return
+	}
+	planBytes, _ := json.Marshal(plan)
+	nowTime := time.Now()
+	record = &models.PluginInstallPlanRecord{
+		Id:          "pip_" + guid.CreateGuid(),
+		Status:      models.PluginInstallPlanStatusRunning,
+		Plan:        string(planBytes),
+		CreatedBy:   operator,
+		CreatedTime: nowTime,
+		UpdatedTime: nowTime,
+		PlanObj:     plan,
+	}
+	if err = database.CreatePluginInstallPlanRecord(ctx, record); err != nil {
+		return
+	}
+	planCtx := context.WithValue(context.Background(), models.TransactionIdHeader, fmt.Sprintf("plugin_install_plan_%s", record.Id))
+	go runPluginInstallPlan(planCtx, record, param, operator)
+	return
+}
+
+// GetPluginInstallPlanRecord 安装计划执行进度
+func GetPluginInstallPlanRecord(ctx context.Context, planId string) (record *models.PluginInstallPlanRecord, err error) {
+	if record, err = database.GetPluginInstallPlanRecord(ctx, planId); err != nil {
+		return
+	}
+	record.PlanObj = &models.PluginInstallPlan{}
+	if record.Plan != "" {
+		if err = json.Unmarshal([]byte(record.Plan), record.PlanObj); err != nil {
+			err = fmt.Errorf("json unmarshal plugin install plan fail,%s ", err.Error())
+		}
+	}
+	return
+}
+
+func checkPluginInstallPlanExecutable(plan *models.PluginInstallPlan, param *models.PluginInstallPlanParam) (err error) {
+	if !plan.Executable {
+		var messages []string
+		for _, conflict := range plan.Conflicts {
+			messages = append(messages, conflict.Message)
+		}
+		err = fmt.Errorf("plugin install plan has conflicts:%s", strings.Join(messages, ";"))
+		return
+	}
+	var missingTargets []string
+	for _, item := range plan.Items {
+		if tools.StringListContains(item.Actions, models.PluginPlanActionLaunch) || tools.StringListContains(item.Actions, models.PluginPlanActionUpgrade) {
+			if len(param.Targets[item.Name]) == 0 {
+				missingTargets = append(missingTargets, item.Name)
+			}
+		}
+	}
+	if len(missingTargets) > 0 {
+		err = fmt.Errorf("plugin %s need launch targets", strings.Join(missingTargets, ","))
+	}
+	return
+}
+
+func buildPluginInstallPlan(ctx context.Context, param *models.PluginInstallPlanParam) (planner *pluginPlanner, err error) {
+	if len(param.PackageIds) == 0 && len(param.ArtifactKeys) == 0 {
+		err = fmt.Errorf("packageIds and artifactKeys can not be both empty")
+		return
+	}
+	planner = &pluginPlanner{
+		ctx:              ctx,
+		param:            param,
+		localCandidates:  make(map[string][]*pluginPlanCandidate),
+		runningVersion:   make(map[string]string),
+		onlineCandidates: make(map[string]*pluginPlanCandidate),
+	}
+	if err = planner.loadLocalCandidates(); err != nil {
+		return
+	}
+	var requested []*pluginPlanCandidate
+	for _, packageId := range param.PackageIds {
+		candidate := planner.getLocalCandidateById(packageId)
+		if candidate == nil {
+			err = fmt.Errorf("can not find plugin package %s", packageId)
+			return
+		}
+		requested = append(requested, candidate)
+	}
+	for _, artifactKey := range param.ArtifactKeys {
+		candidate, loadErr := planner.loadArtifactCandidate(artifactKey)
+		if loadErr != nil {
+			err = loadErr
+			return
+		}
+		requested = append(requested, candidate)
+	}
+	planner.resolveRounds(requested)
+	planner.checkRunningConflicts()
+	return
+}
+
+// resolveRounds 有冲突时带着这一轮收集的约束重新解析,没有冲突或者选不出别的版本时结束
+func (p *pluginPlanner) resolveRounds(requested []*pluginPlanCandidate) {
+	var prevVersions map[string]string
+	for round := 0; round < pluginPlanMaxResolveRounds; round++ {
+		p.resolve(requested)
+		roundVersions := p.itemVersions()
+		if len(p.conflicts) == 0 || reflect.DeepEqual(roundVersions, prevVersions) {
+			return
+		}
+		prevVersions = roundVersions
+		p.prevConstraints = p.roundConstraints
+	}
+}
+
+// resolve 从指定插件出发广度优先解析依赖,已经运行的版本不再往下解析
+func (p *pluginPlanner) resolve(requested []*pluginPlanCandidate) {
+	p.items = make(map[string]*models.PluginPlanItem)
+	p.candidates = make(map[string]*pluginPlanCandidate)
+	p.conflicts = nil
+	p.roundConstraints = make(map[string][]*pluginPlanConstraint)
+	var queue []*pluginPlanCandidate
+	for _, candidate := range requested {
+		if existItem, ok := p.items[candidate.name]; ok {
+			if existItem.Version != candidate.version {
+				p.addConflict(candidate.name, candidate.version, "", fmt.Sprintf("plugin %s requested with multiple versions %s and %s", candidate.name, existItem.Version, candidate.version))
+			}
+			continue
+		}
+		p.addItem(candidate, true)
+		queue = append(queue, candidate)
+	}
+	for len(queue) > 0 {
+		candidate := queue[0]
+		queue = queue[1:]
+		if candidate.running {
+			continue
+		}
+		for _, dependency := range candidate.dependencies {
+			if resolved := p.resolveDependency(candidate, dependency); resolved != nil {
+				queue = append(queue, resolved)
+			}
+		}
+	}
+}
+
+func (p *pluginPlanner) itemVersions() map[string]string {
+	result := make(map[string]string)
+	for name, item := range p.items {
+		result[name] = item.Version
+	}
+	return result
+}
+
+func (p *pluginPlanner) loadLocalCandidates() (err error) {
+	packageRows, getPackageErr := database.GetPluginPlanPackages(p.ctx)
+	if getPackageErr != nil {
+		err = getPackageErr
+		return
+	}
+	dependencyMap, getDependencyErr := database.GetPluginPlanDependencies(p.ctx)
+	if getDependencyErr != nil {
+		err = getDependencyErr
+		return
+	}
+	for _, row := range packageRows {
+		candidate := &pluginPlanCandidate{name: row.Name, version: row.Version, packageId: row.Id, source: models.PluginPlanSourceUploaded, dependencies: []*models.PluginPlanDependency{}}
+		if row.Status == models.PluginStatusRegistered {
+			candidate.source = models.PluginPlanSourceRegistered
+		}
+		if row.RunningInstances > 0 {
+			candidate.source = models.PluginPlanSourceRunning
+			candidate.running = true
+			if p.runningVersion[row.Name] == "" || tools.CompareVersionSegments(row.Version, p.runningVersion[row.Name]) > 0 {
+				p.runningVersion[row.Name] = row.Version
+			}
+		}
+		for _, dependRow := range dependencyMap[row.Id] {
+			candidate.dependencies = append(candidate.dependencies, &models.PluginPlanDependency{Name: dependRow.DependencyPackageName, Constraint: dependRow.DependencyPackageVersion})
+		}
+		p.localCandidates[row.Name] = append(p.localCandidates[row.Name], candidate)
+	}
+	for _, candidateList := range p.localCandidates {
+		sortPluginPlanCandidates(candidateList)
+	}
+	return
+}
+
+func (p *pluginPlanner) getLocalCandidateById(packageId string) *pluginPlanCandidate {
+	for _, candidateList := range p.localCandidates {
+		for _, candidate := range candidateList {
+			if candidate.packageId == packageId {
+				return candidate
+			}
+		}
+	}
+	return nil
+}
+
+func (p *pluginPlanner) getLocalCandidate(name, version string) *pluginPlanCandidate {
+	for _, candidate := range p.localCandidates[name] {
+		if candidate.version == version {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// loadArtifactCandidate 只读取在线插件包里的register.xml,本地已上传过同版本的直接用本地的,插件包在执行计划时才下载
+func (p *pluginPlanner) loadArtifactCandidate(artifactKey string) (candidate *pluginPlanCandidate, err error) {
+	registerConfigBytes, readErr := remote.ReadOnlinePluginPackageEntry(p.ctx, artifactKey, "register.xml")
+	if readErr != nil {
+		err = fmt.Errorf("read plugin artifact %s fail,%s ", artifactKey, readErr.Error())
+		return
+	}
+	registerConfig := &models.RegisterXML{}
+	if err = xml.Unmarshal(registerConfigBytes, registerConfig); err != nil {
+		err = fmt.Errorf("xml unmarshal plugin artifact %s register xml fail,%s ", artifactKey, err.Error())
+		return
+	}
+	if localCandidate := p.getLocalCandidate(registerConfig.Name, registerConfig.Version); localCandidate != nil {
+		candidate = localCandidate
+		return
+	}
+	candidate = &pluginPlanCandidate{name: registerConfig.Name, version: registerConfig.Version, source: models.PluginPlanSourceArtifact, artifactKey: artifactKey, dependencies: []*models.PluginPlanDependency{}}
+	for _, dependency := range registerConfig.PackageDependencies.PackageDependency {
+		candidate.dependencies = append(candidate.dependencies, &models.PluginPlanDependency{Name: dependency.Name, Constraint: dependency.Version})
+	}
+	return
+}
+
+// resolveDependency 选出满足约束的版本,新加入计划的返回出来继续解析它的依赖
+func (p *pluginPlanner) resolveDependency(owner *pluginPlanCandidate, dependency *models.PluginPlanDependency) (resolved *pluginPlanCandidate) {
+	requiredBy := owner.name + ":" + owner.version
+	// 上一轮的解析结果不算数
+	dependency.ResolvedVersion = ""
+	constraint, parseErr := tools.ParseVersionConstraint(dependency.Constraint)
+	if parseErr != nil {
+		p.addConflict(dependency.Name, dependency.Constraint, requiredBy, parseErr.Error())
+		return
+	}
+	if dependency.Name == pluginPlatformDependencyName {
+		if !constraint.Match(models.Config.Version) {
+			p.addConflict(dependency.Name, dependency.Constraint, requiredBy, fmt.Sprintf("%s requires platform %s,current %s", requiredBy, constraint, models.Config.Version))
+			return
+		}
+		dependency.ResolvedVersion = models.Config.Version
+		return
+	}
+	p.roundConstraints[dependency.Name] = append(p.roundConstraints[dependency.Name], &pluginPlanConstraint{requiredBy: requiredBy, constraint: constraint})
+	if existItem, ok := p.items[dependency.Name]; ok {
+		if !constraint.Match(existItem.Version) {
+			p.addConflict(dependency.Name, dependency.Constraint, requiredBy, fmt.Sprintf("%s requires %s %s,but plan uses %s", requiredBy, dependency.Name, constraint, existItem.Version))
+			return
+		}
+		dependency.ResolvedVersion = existItem.Version
+		return
+	}
+	candidate := p.findLocalCandidate(dependency.Name)
+	if candidate == nil && p.param.ResolveOnline {
+		candidate = p.findOnlineCandidate(dependency.Name)
+	}
+	if candidate == nil {
+		p.addConflict(dependency.Name, dependency.Constraint, requiredBy, fmt.Sprintf("%s requires %s %s,no package satisfies %s", requiredBy, dependency.Name, constraint, p.describeConstraints(dependency.Name)))
+		return
+	}
+	dependency.ResolvedVersion = candidate.version
+	p.addItem(candidate, false)
+	resolved = candidate
+	return
+}
+
+// matchConstraints 版本要同时满足上一轮和这一轮已收集的所有约束
+func (p *pluginPlanner) matchConstraints(name, version string) bool {
+	for _, constraintList := range [][]*pluginPlanConstraint{p.prevConstraints[name], p.roundConstraints[name]} {
+		for _, planConstraint := range constraintList {
+			if !planConstraint.constraint.Match(version) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (p *pluginPlanner) describeConstraints(name string) string {
+	var descList []string
+	descMap := make(map[string]bool)
+	for _, constraintList := range [][]*pluginPlanConstraint{p.prevConstraints[name], p.roundConstraints[name]} {
+		for _, planConstraint := range constraintList {
+			desc := fmt.Sprintf("%s(%s)", planConstraint.constraint, planConstraint.requiredBy)
+			if !descMap[desc] {
+				descMap[desc] = true
+				descList = append(descList, desc)
+			}
+		}
+	}
+	return strings.Join(descList, " and ")
+}
+
+// findLocalCandidate 运行中的版本满足就不动,否则取满足所有约束的最高本地版本
+func (p *pluginPlanner) findLocalCandidate(name string) *pluginPlanCandidate {
+	if runningVersion := p.runningVersion[name]; runningVersion != "" && p.matchConstraints(name, runningVersion) {
+		return p.getLocalCandidate(name, runningVersion)
+	}
+	for _, candidate := range p.localCandidates[name] {
+		if p.matchConstraints(name, candidate.version) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// findOnlineCandidate 按文件名里的插件名和版本号从在线仓库挑选,以register.xml为准,读取过的结果缓存给后面的轮次用
+func (p *pluginPlanner) findOnlineCandidate(name string) *pluginPlanCandidate {
+	if !p.onlineLoaded {
+		p.onlineLoaded = true
+		onlinePackages, err := remote.GetOnliePluginPackageList(p.ctx)
+		if err != nil {
+			log.Logger.Error("get online plugin package list fail", log.Error(err))
+			return nil
+		}
+		for _, onlinePackage := range onlinePackages {
+			p.onlineKeys = append(p.onlineKeys, onlinePackage.KeyName)
+		}
+	}
+	type keyVersion struct {
+		key     string
+		version string
+	}
+	var matchKeys []*keyVersion
+	for _, key := range p.onlineKeys {
+		if !strings.HasSuffix(strings.ToLower(key), ".zip") || !strings.Contains(strings.ToLower(key), strings.ToLower(name)) {
+			continue
+		}
+		versionMatchList := artifactVersionRegexp.FindAllStringSubmatch(key, -1)
+		if len(versionMatchList) == 0 {
+			continue
+		}
+		keyVersionObj := &keyVersion{key: key, version: versionMatchList[len(versionMatchList)-1][1]}
+		if p.matchConstraints(name, keyVersionObj.version) {
+			matchKeys = append(matchKeys, keyVersionObj)
+		}
+	}
+	sort.SliceStable(matchKeys, func(i, j int) bool {
+		return tools.CompareVersionSegments(matchKeys[i].version, matchKeys[j].version) > 0
+	})
+	for i, keyVersionObj := range matchKeys {
+		if i >= pluginPlanMaxOnlineTries {
+			break
+		}
+		candidate, loaded := p.onlineCandidates[keyVersionObj.key]
+		if !loaded {
+			var err error
+			if candidate, err = p.loadArtifactCandidate(keyVersionObj.key); err != nil {
+				log.Logger.Warn("load online plugin artifact fail", log.String("key", keyVersionObj.key), log.Error(err))
+			}
+			p.onlineCandidates[keyVersionObj.key] = candidate
+		}
+		if candidate != nil && candidate.name == name && p.matchConstraints(name, candidate.version) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+func (p *pluginPlanner) addItem(candidate *pluginPlanCandidate, requested bool) {
+	item := &models.PluginPlanItem{
+		Name:           candidate.name,
+		Version:        candidate.version,
+		PackageId:      candidate.packageId,
+		ArtifactKey:    candidate.artifactKey,
+		Source:         candidate.source,
+		RunningVersion: p.runningVersion[candidate.name],
+		Requested:      requested,
+		Dependencies:   candidate.dependencies,
+		Actions:        []string{},
+		DoneActions:    []string{},
+	}
+	switch candidate.source {
+	case models.PluginPlanSourceArtifact:
+		item.Actions = append(item.Actions, models.PluginPlanActionPull, models.PluginPlanActionRegister)
+	case models.PluginPlanSourceUploaded:
+		item.Actions = append(item.Actions, models.PluginPlanActionRegister)
+	}
+	if !candidate.running {
+		if item.RunningVersion == "" {
+			item.Actions = append(item.Actions, models.PluginPlanActionLaunch)
+		} else if tools.CompareVersionSegments(item.Version, item.RunningVersion) > 0 {
+			item.Actions = append(item.Actions, models.PluginPlanActionUpgrade)
+		} else {
+			p.addConflict(item.Name, item.Version, "", fmt.Sprintf("plugin %s running version %s is newer than %s,downgrade not supported", item.Name, item.RunningVersion, item.Version))
+		}
+	}
+	p.items[candidate.name] = item
+	p.candidates[candidate.name] = candidate
+}
+
+// checkRunningConflicts 升级后的版本不能破坏不在计划里的运行中插件的依赖
+func (p *pluginPlanner) checkRunningConflicts() {
+	for _, item := range p.items {
+		if !tools.StringListContains(item.Actions, models.PluginPlanActionUpgrade) {
+			continue
+		}
+		for runningName, runningVersion := range p.runningVersion {
+			if runningName == item.Name {
+				continue
+			}
+			if planItem, ok := p.items[runningName]; ok && planItem.Version != runningVersion {
+				continue
+			}
+			runningCandidate := p.getLocalCandidate(runningName, runningVersion)
+			if runningCandidate == nil {
+				continue
+			}
+			for _, dependency := range runningCandidate.dependencies {
+				if dependency.Name != item.Name {
+					continue
+				}
+				constraint, parseErr := tools.ParseVersionConstraint(dependency.Constraint)
+				if parseErr != nil || constraint.Match(item.Version) {
+					continue
+				}
+				requiredBy := runningName + ":" + runningVersion
+				p.addConflict(item.Name, dependency.Constraint, requiredBy, fmt.Sprintf("running %s requires %s %s,upgrade to %s will break it", requiredBy, item.Name, constraint, item.Version))
+			}
+		}
+	}
+}
+
+func (p *pluginPlanner) addConflict(name, constraint, requiredBy, message string) {
+	p.conflicts = append(p.conflicts, &models.PluginPlanConflict{Name: name, Constraint: constraint, RequiredBy: requiredBy, Message: message})
+}
+
+// plan 按依赖拓扑排序,同一层按插件名排序,有环时纪录冲突
+func (p *pluginPlanner) plan() *models.PluginInstallPlan {
+	result := &models.PluginInstallPlan{Items: []*models.PluginPlanItem{}, Conflicts: p.conflicts}
+	inDegree := make(map[string]int)
+	dependents := make(map[string][]string)
+	for name := range p.items {
+		inDegree[name] = 0
+	}
+	for name, item := range p.items {
+		// 已经运行的版本不用等依赖
+		if p.candidates[name].running {
+			continue
+		}
+		for _, dependency := range item.Dependencies {
+			if _, ok := p.items[dependency.Name]; ok && dependency.Name != name {
+				inDegree[name]++
+				dependents[dependency.Name] = append(dependents[dependency.Name], name)
+			}
+		}
+	}
+	var readyNames []string
+	for name, degree := range inDegree {
+		if degree == 0 {
+			readyNames = append(readyNames, name)
+		}
+	}
+	for len(readyNames) > 0 {
+		sort.Strings(readyNames)
+		name := readyNames[0]
+		readyNames = readyNames[1:]
+		result.Items = append(result.Items, p.items[name])
+		for _, dependent := range dependents[name] {
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				readyNames = append(readyNames, dependent)
+			}
+		}
+	}
+	if len(result.Items) < len(p.items) {
+		var cycleNames []string
+		for name, degree := range inDegree {
+			if degree > 0 {
+				cycleNames = append(cycleNames, name)
+			}
+		}
+		sort.Strings(cycleNames)
+		p.addConflict(strings.Join(cycleNames, ","), "", "", fmt.Sprintf("dependency cycle among %s", strings.Join(cycleNames, ",")))
+		result.Conflicts = p.conflicts
+	}
+	if result.Conflicts == nil {
+		result.Conflicts = []*models.PluginPlanConflict{}
+	}
+	result.Executable = len(result.Conflicts) == 0
+	return result
+}
+
+// runPluginInstallPlan 按顺序执行每个插件的动作,失败时停止,已完成的插件不回滚
+func runPluginInstallPlan(ctx context.Context, record *models.PluginInstallPlanRecord, param *models.PluginInstallPlanParam, operator string) {
+	defer try.ExceptionStack(func(e interface{}, err interface{}) {
+		record.Status = models.PluginInstallPlanStatusFailed
+		record.ErrorMessage = fmt.Sprintf("%v", err)
+		updatePluginInstallPlanRecord(ctx, record)
+		log.Logger.Error(e.(string))
+	})
+	for _, item := range record.PlanObj.Items {
+		record.CurrentItem = item.Name + ":" + item.Version
+		for _, action := range item.Actions {
+			if tools.StringListContains(item.DoneActions, action) {
+				continue
+			}
+			log.Logger.Info("execute plugin install plan action", log.String("planId", record.Id), log.String("item", record.CurrentItem), log.String("action", action))
+			if err := executePluginPlanAction(ctx, item, action, param.Targets[item.Name], operator); err != nil {
+				log.Logger.Error("execute plugin install plan action fail", log.String("planId", record.Id), log.String("item", record.CurrentItem), log.String("action", action), log.Error(err))
+				record.Status = models.PluginInstallPlanStatusFailed
+				record.ErrorMessage = fmt.Sprintf("%s %s fail,%s", record.CurrentItem, action, err.Error())
+				updatePluginInstallPlanRecord(ctx, record)
+				return
+			}
+			item.DoneActions = append(item.DoneActions, action)
+			updatePluginInstallPlanRecord(ctx, record)
+		}
+	}
+	record.CurrentItem = ""
+	record.Status = models.PluginInstallPlanStatusDone
+	updatePluginInstallPlanRecord(ctx, record)
+}
+
+func executePluginPlanAction(ctx context.Context, item *models.PluginPlanItem, action string, targets []*models.PluginUpgradeTarget, operator string) (err error) {
+	switch action {
+	case models.PluginPlanActionPull:
+		archiveFile, downloadErr := remote.GetOnlinePluginPackageFile(ctx, item.ArtifactKey)
+		if downloadErr != nil {
+			err = fmt.Errorf("download plugin artifact %s fail,%s ", item.ArtifactKey, downloadErr.Error())
+			return
+		}
+		archiveFile.Close()
+		defer os.Remove(archiveFile.Name())
+		item.PackageId, err = UploadPluginPackageArchive(ctx, archiveFile.Name())
+	case models.PluginPlanActionRegister:
+		pluginPackageObj := models.PluginPackages{Id: item.PackageId}
+		if err = database.GetSimplePluginPackage(ctx, &pluginPackageObj, true); err != nil {
+			return
+		}
+		err = RegisterPluginPackage(ctx, &pluginPackageObj)
+	case models.PluginPlanActionLaunch:
+		for _, target := range targets {
+			running, checkErr := database.CheckServerPortRunning(ctx, target.HostIp, target.Port)
+			if checkErr != nil {
+				err = checkErr
+				return
+			}
+			if running {
+				err = fmt.Errorf("server:%s port:%d already in running", target.HostIp, target.Port)
+				return
+			}
+			pluginInstance, _, launchErr := LaunchPluginInstance(ctx, &models.PluginLaunchParam{PackageId: item.PackageId, HostIp: target.HostIp, Port: target.Port, Operator: operator})
+			if launchErr != nil {
+				err = launchErr
+				return
+			}
+			if pluginInstance.ContainerStatus == models.PluginInstanceStatusRunning {
+				if err = remote.RegisterPluginRoute(item.Name, pluginInstance.Host, strconv.Itoa(pluginInstance.Port)); err != nil {
+					return
+				}
+			}
+		}
+	case models.PluginPlanActionUpgrade:
+		err = waitPluginUpgrade(ctx, item.PackageId, targets, operator)
+	}
+	return
+}
+
+// waitPluginUpgrade 发起滚动升级并等待结束,超过健康检查、观察期和启动预留时间之和还没结束就报错,升级本身继续在后台执行
+func waitPluginUpgrade(ctx context.Context, pluginPackageId string, targets []*models.PluginUpgradeTarget, operator string) (err error) {
+	upgradeParam := &models.PluginUpgradeParam{Instances: targets}
+	upgradeRecord, startErr := StartPluginUpgrade(ctx, pluginPackageId, upgradeParam, operator)
+	if startErr != nil {
+		err = startErr
+		return
+	}
+	// StartPluginUpgrade已补齐默认的超时时间
+	deadline := time.Now().Add(time.Duration(upgradeParam.HealthTimeoutSeconds+upgradeParam.DrainSeconds)*time.Second + pluginPlanUpgradeLaunchTimeout)
+	for {
+		if time.Now().After(deadline) {
+			err = fmt.Errorf("wait upgrade %s timeout,last status %s", upgradeRecord.Id, upgradeRecord.Status)
+			return
+		}
+		time.Sleep(pluginUpgradeProbeInterval)
+		if upgradeRecord, err = database.GetPluginPackageUpgrade(ctx, upgradeRecord.Id); err != nil {
+			return
+		}
+		switch upgradeRecord.Status {
+		case models.PluginUpgradeStatusDone:
+			return
+		case models.PluginUpgradeStatusRolledBack, models.PluginUpgradeStatusFailed:
+			err = fmt.Errorf("upgrade %s %s,%s", upgradeRecord.Id, upgradeRecord.Status, upgradeRecord.ErrorMessage)
+			return
+		}
+	}
+}
+
+func updatePluginInstallPlanRecord(ctx context.Context, record *models.PluginInstallPlanRecord) {
+	planBytes, _ := json.Marshal(record.PlanObj)
+	record.Plan = string(planBytes)
+	if err := database.UpdatePluginInstallPlanRecord(ctx, record); err != nil {
+		log.Logger.Error("update plugin install plan record fail", log.String("planId", record.Id), log.Error(err))
+	}
+}
+
+// sortPluginPlanCandidates 版本从高到低
+func sortPluginPlanCandidates(candidateList []*pluginPlanCandidate) {
+	sort.SliceStable(candidateList, func(i, j int) bool {
+		return tools.CompareVersionSegments(candidateList[i].version, candidateList[j].version) > 0
+	})
+}
@@ -0,0 +1,195 @@
+package execution
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+// newTestPluginPlanner 用本地插件包构造planner,依赖写成 名称:约束
+func newTestPluginPlanner(packages ...*pluginPlanCandidate) *pluginPlanner {
+	planner := &pluginPlanner{
+		ctx:              context.Background(),
+		param:            &models.PluginInstallPlanParam{},
+		localCandidates:  make(map[string][]*pluginPlanCandidate),
+		runningVersion:   make(map[string]string),
+		onlineCandidates: make(map[string]*pluginPlanCandidate),
+	}
+	for _, candidate := range packages {
+		if candidate.running {
+			planner.runningVersion[candidate.name] = candidate.version
+		}
+		planner.localCandidates[candidate.name] = append(planner.localCandidates[candidate.name], candidate)
+	}
+	for _, candidateList := range planner.localCandidates {
+		sortPluginPlanCandidates(candidateList)
+	}
+	return planner
+}
+
+func testPlanPackage(name, version string, running bool, dependencies ...string) *pluginPlanCandidate {
+	candidate := &pluginPlanCandidate{name: name, version: version, packageId: name + "__" + version, source: models.PluginPlanSourceRegistered, running: running, dependencies: []*models.PluginPlanDependency{}}
+	if running {
+		candidate.source = models.PluginPlanSourceRunning
+	}
+	for _, dependency := range dependencies {
+		parts := strings.SplitN(dependency, ":", 2)
+		candidate.dependencies = append(candidate.dependencies, &models.PluginPlanDependency{Name: parts[0], Constraint: parts[1]})
+	}
+	return candidate
+}
+
+func TestPluginInstallPlan(t *testing.T) {
+	tests := []struct {
+		name          string
+		packages      []*pluginPlanCandidate
+		requested     []string
+		wantItems     []string
+		wantActions   map[string][]string
+		wantConflicts []string
+	}{
+		{
+			name: "intersect constraints of same dependency",
+			packages: []*pluginPlanCandidate{
+				testPlanPackage("a", "1.0.0", false, "b:>=1.0.0", "c:1.0.0"),
+				testPlanPackage("b", "2.5.0", false),
+				testPlanPackage("b", "1.5.0", false),
+				testPlanPackage("c", "1.0.0", false, "b:<2.0.0"),
+			},
+			requested:   []string{"a__1.0.0"},
+			wantItems:   []string{"b:1.5.0", "c:1.0.0", "a:1.0.0"},
+			wantActions: map[string][]string{"b": {"launch"}, "c": {"launch"}, "a": {"launch"}},
+		},
+		{
+			name: "bare version means minimum",
+			packages: []*pluginPlanCandidate{
+				testPlanPackage("a", "1.0.0", false, "b:v1.2.0"),
+				testPlanPackage("b", "1.1.0", false),
+				testPlanPackage("b", "1.3.0", false),
+			},
+			requested: []string{"a__1.0.0"},
+			wantItems: []string{"b:1.3.0", "a:1.0.0"},
+		},
+		{
+			name: "no version satisfies all constraints",
+			packages: []*pluginPlanCandidate{
+				testPlanPackage("a", "1.0.0", false, "b:>=2.0.0", "c:1.0.0"),
+				testPlanPackage("b", "2.5.0", false),
+				testPlanPackage("b", "1.5.0", false),
+				testPlanPackage("c", "1.0.0", false, "b:<2.0.0"),
+			},
+			requested: []string{"a__1.0.0"},
+			wantItems: []string{"c:1.0.0", "a:1.0.0"},
+			wantConflicts: []string{
+				"a:1.0.0 requires b >=2.0.0,no package satisfies >=2.0.0(a:1.0.0) and <2.0.0(c:1.0.0)",
+				"c:1.0.0 requires b <2.0.0,no package satisfies >=2.0.0(a:1.0.0) and <2.0.0(c:1.0.0)",
+			},
+		},
+		{
+			name: "running version kept when satisfied",
+			packages: []*pluginPlanCandidate{
+				testPlanPackage("a", "1.0.0", false, "b:^1.0.0"),
+				testPlanPackage("b", "1.2.0", true),
+				testPlanPackage("b", "1.5.0", false),
+			},
+			requested:   []string{"a__1.0.0"},
+			wantItems:   []string{"b:1.2.0", "a:1.0.0"},
+			wantActions: map[string][]string{"a": {"launch"}, "b": {}},
+		},
+		{
+			name: "upgrade running version",
+			packages: []*pluginPlanCandidate{
+				testPlanPackage("a", "1.0.0", false, "b:>=1.4.0"),
+				testPlanPackage("b", "1.2.0", true),
+				testPlanPackage("b", "1.5.0", false),
+			},
+			requested:   []string{"a__1.0.0"},
+			wantItems:   []string{"b:1.5.0", "a:1.0.0"},
+			wantActions: map[string][]string{"a": {"launch"}, "b": {"upgrade"}},
+		},
+		{
+			name: "requested version is not replaced",
+			packages: []*pluginPlanCandidate{
+				testPlanPackage("a", "1.0.0", false, "b:<2.0.0"),
+				testPlanPackage("b", "2.5.0", false),
+				testPlanPackage("b", "1.5.0", false),
+			},
+			requested:     []string{"b__2.5.0", "a__1.0.0"},
+			wantItems:     []string{"b:2.5.0", "a:1.0.0"},
+			wantConflicts: []string{"a:1.0.0 requires b <2.0.0,but plan uses 2.5.0"},
+		},
+		{
+			name: "upgrade breaks running plugin",
+			packages: []*pluginPlanCandidate{
+				testPlanPackage("b", "1.0.0", true),
+				testPlanPackage("b", "2.0.0", false),
+				testPlanPackage("d", "1.0.0", true, "b:<2.0.0"),
+			},
+			requested:     []string{"b__2.0.0"},
+			wantItems:     []string{"b:2.0.0"},
+			wantConflicts: []string{"running d:1.0.0 requires b <2.0.0,upgrade to 2.0.0 will break it"},
+		},
+		{
+			name: "dependency cycle",
+			packages: []*pluginPlanCandidate{
+				testPlanPackage("a", "1.0.0", false, "b:1.0.0"),
+				testPlanPackage("b", "1.0.0", false, "a:1.0.0"),
+			},
+			requested:     []string{"a__1.0.0"},
+			wantItems:     []string{},
+			wantConflicts: []string{"dependency cycle among a,b"},
+		},
+		{
+			name: "missing dependency",
+			packages: []*pluginPlanCandidate{
+				testPlanPackage("a", "1.0.0", false, "b:^1.0.0"),
+				testPlanPackage("b", "2.0.0", false),
+			},
+			requested:     []string{"a__1.0.0"},
+			wantItems:     []string{"a:1.0.0"},
+			wantConflicts: []string{"a:1.0.0 requires b ^1.0.0,no package satisfies ^1.0.0(a:1.0.0)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planner := newTestPluginPlanner(tt.packages...)
+			var requested []*pluginPlanCandidate
+			for _, packageId := range tt.requested {
+				requested = append(requested, planner.getLocalCandidateById(packageId))
+			}
+			planner.resolveRounds(requested)
+			planner.checkRunningConflicts()
+			plan := planner.plan()
+			gotItems := []string{}
+			gotActions := make(map[string][]string)
+			for _, item := range plan.Items {
+				gotItems = append(gotItems, item.Name+":"+item.Version)
+				gotActions[item.Name] = item.Actions
+			}
+			if !reflect.DeepEqual(gotItems, tt.wantItems) {
+				t.Errorf("plan items = %v, want %v", gotItems, tt.wantItems)
+			}
+			for name, wantActions := range tt.wantActions {
+				if !reflect.DeepEqual(gotActions[name], wantActions) {
+					t.Errorf("plan %s actions = %v, want %v", name, gotActions[name], wantActions)
+				}
+			}
+			gotConflicts := []string{}
+			for _, conflict := range plan.Conflicts {
+				gotConflicts = append(gotConflicts, conflict.Message)
+			}
+			if tt.wantConflicts == nil {
+				tt.wantConflicts = []string{}
+			}
+			if !reflect.DeepEqual(gotConflicts, tt.wantConflicts) {
+				t.Errorf("plan conflicts = %v, want %v", gotConflicts, tt.wantConflicts)
+			}
+			if plan.Executable != (len(tt.wantConflicts) == 0) {
+				t.Errorf("plan executable = %v", plan.Executable)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package execution
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/tools"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/try"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
+)
+
+const pluginUpgradeProbeInterval = 5 * time.Second
+
+var containerNameIllegalRegexp = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// pluginUpgradeTask 后台执行中的滚动升级
+type pluginUpgradeTask struct {
+	record         *models.PluginPackageUpgrade
+	param          *models.PluginUpgradeParam
+	pluginPackage  *models.PluginPackages
+	dockerResource *models.PluginPackageRuntimeResourcesDocker
+	oldInstances   []*models.PluginInstances
+	oldImageName   string
+	newInstances   []*models.PluginInstances
+	failThreshold  int
+	probeTimeout   time.Duration
+	routesShifted  bool
+	dbUpgraded     bool
+}
+
+// StartPluginUpgrade 校验目标版本和部署位置后创建升级纪录,在后台滚动升级
+func StartPluginUpgrade(ctx context.Context, pluginPackageId string, param *models.PluginUpgradeParam, operator string) (record *models.PluginPackageUpgrade, err error) {
+	pluginPackageObj := &models.PluginPackages{Id: pluginPackageId}
+	if err = database.GetSimplePluginPackage(ctx, pluginPackageObj, true); err != nil {
+		return
+	}
+	resources, getResourceErr := database.GetPluginRuntimeResources(ctx, pluginPackageId)
+	if getResourceErr != nil {
+		err = getResourceErr
+		return
+	}
+	if len(resources.Docker) == 0 {
+		err = fmt.Errorf("plugin must contain docker resource")
+		return
+	}
+	oldInstances, getOldErr := database.GetPluginUpgradeOldInstances(ctx, pluginPackageObj.Name, pluginPackageId)
+	if getOldErr != nil {
+		err = getOldErr
+		return
+	}
+	if len(oldInstances) == 0 {
+		err = fmt.Errorf("plugin %s has no running instance of other version,please launch instead", pluginPackageObj.Name)
+		return
+	}
+	oldPackageObj := &models.PluginPackages{Id: oldInstances[0].PackageId}
+	if err = database.GetSimplePluginPackage(ctx, oldPackageObj, true); err != nil {
+		return
+	}
+	for _, instance := range oldInstances {
+		if instance.PackageId != oldPackageObj.Id {
+			err = fmt.Errorf("plugin %s running instances belong to multiple versions,please clean up first", pluginPackageObj.Name)
+			return
+		}
+	}
+	if !tools.CompareVersion(pluginPackageObj.Version, oldPackageObj.Version) {
+		err = fmt.Errorf("target version %s must be newer than running version %s", pluginPackageObj.Version, oldPackageObj.Version)
+		return
+	}
+	targetMap := make(map[string]bool)
+	for _, target := range param.Instances {
+		if target.Port < 20000 {
+			err = fmt.Errorf("param port %d illegal", target.Port)
+			return
+		}
+		targetKey := fmt.Sprintf("%s:%d", target.HostIp, target.Port)
+		if targetMap[targetKey] {
+			err = fmt.Errorf("duplicate target %s", targetKey)
+			return
+		}
+		targetMap[targetKey] = true
+		running, checkErr := database.CheckServerPortRunning(ctx, target.HostIp, target.Port)
+		if checkErr != nil {
+			err = checkErr
+			return
+		}
+		if running {
+			err = fmt.Errorf("server:%s port:%d already in running", target.HostIp, target.Port)
+			return
+		}
+	}
+	oldImageName, _, getOldDockerErr := database.GetPluginDockerRuntimeMessage(oldPackageObj.Id)
+	if getOldDockerErr != nil {
+		err = getOldDockerErr
+		return
+	}
+	if param.DrainSeconds <= 0 {
+		param.DrainSeconds = models.DefaultPluginUpgradeDrainSeconds
+	}
+	if param.HealthTimeoutSeconds <= 0 {
+		param.HealthTimeoutSeconds = models.DefaultPluginUpgradeHealthTimeout
+	}
+	var oldInstanceIds []string
+	for _, instance := range oldInstances {
+		oldInstanceIds = append(oldInstanceIds, instance.Id)
+	}
+	nowTime := time.Now()
+	record = &models.PluginPackageUpgrade{
+		Id:           "pu_" + guid.CreateGuid(),
+		PluginName:   pluginPackageObj.Name,
+		FromVersion:  oldPackageObj.Version,
+		ToPackageId:  pluginPackageId,
+		ToVersion:    pluginPackageObj.Version,
+		Status:       models.PluginUpgradeStatusPreparing,
+		OldInstances: strings.Join(oldInstanceIds, ","),
+		CreatedBy:    operator,
+		CreatedTime:  nowTime,
+		UpdatedTime:  nowTime,
+		ExecHost:     models.Config.HostIp,
+	}
+	if err = database.CreatePluginPackageUpgrade(ctx, record); err != nil {
+		return
+	}
+	healthConfig := GetPluginHealthCheckConfig()
+	task := &pluginUpgradeTask{
+		record:         record,
+		param:          param,
+		pluginPackage:  pluginPackageObj,
+		dockerResource: resources.Docker[0],
+		oldInstances:   oldInstances,
+		oldImageName:   oldImageName,
+		failThreshold:  healthConfig.FailureThreshold,
+		probeTimeout:   time.Duration(healthConfig.TimeoutSeconds) * time.Second,
+	}
+	upgradeCtx := context.WithValue(context.Background(), models.TransactionIdHeader, fmt.Sprintf("plugin_upgrade_%s", record.Id))
+	go task.run(upgradeCtx, resources, operator)
+	return
+}
+
+// run 新版本实例以STANDBY并行启动,健康后执行数据库升级脚本再切路由,观察期内新实例不健康就切回旧实例,否则销毁旧实例
+// 数据库升级脚本不可逆,执行后回滚只切回旧实例的路由,旧实例运行在升级后的数据库上
+func (t *pluginUpgradeTask) run(ctx context.Context, resources *models.PluginRuntimeResourceData, operator string) {
+	defer try.ExceptionStack(func(e interface{}, err interface{}) {
+		t.rollback(ctx, fmt.Errorf("%v", err))
+		log.Logger.Error(e.(string))
+	})
+	log.Logger.Info("start plugin upgrade", log.String("upgradeId", t.record.Id), log.String("plugin", t.record.PluginName), log.String("from", t.record.FromVersion), log.String("to", t.record.ToVersion))
+	t.updateStatus(ctx, models.PluginUpgradeStatusLaunching)
+	containerName := t.dockerResource.ContainerName
+	for _, instance := range t.oldInstances {
+		if instance.ContainerName == containerName {
+			// 新旧实例可能在同一台主机上,新容器名带上版本号避免冲突
+			containerName = fmt.Sprintf("%s-%s", t.dockerResource.ContainerName, containerNameIllegalRegexp.ReplaceAllString(t.pluginPackage.Version, "_"))
+			break
+		}
+	}
+	for _, target := range t.param.Instances {
+		newInstance, _, err := LaunchPluginInstance(ctx, &models.PluginLaunchParam{PackageId: t.pluginPackage.Id, HostIp: target.HostIp, Port: target.Port, ContainerName: containerName, Standby: true, SkipDbUpgrade: true, Operator: operator})
+		if err != nil {
+			t.rollback(ctx, fmt.Errorf("launch instance on %s:%d fail,%s ", target.HostIp, target.Port, err.Error()))
+			return
+		}
+		t.newInstances = append(t.newInstances, newInstance)
+		t.record.NewInstances = strings.Join(t.instanceIds(t.newInstances), ",")
+		t.updateStatus(ctx, models.PluginUpgradeStatusLaunching)
+	}
+	t.updateStatus(ctx, models.PluginUpgradeStatusVerifying)
+	if err := t.waitNewInstancesHealthy(ctx); err != nil {
+		t.rollback(ctx, err)
+		return
+	}
+	// 新实例都健康后才执行数据库升级脚本,启动失败的版本不会改动数据库
+	if len(resources.Mysql) > 0 {
+		t.dbUpgraded = true
+		if _, _, err := PreparePluginDatabase(ctx, t.pluginPackage, resources.Mysql[0], operator, false); err != nil {
+			t.rollback(ctx, fmt.Errorf("upgrade plugin database fail,%s ", err.Error()))
+			return
+		}
+	}
+	// 切流量:新实例加入gateway路由,旧实例摘除
+	if err := database.ShiftPluginInstanceRoutes(ctx, t.instanceIds(t.newInstances), t.instanceIds(t.oldInstances)); err != nil {
+		t.rollback(ctx, err)
+		return
+	}
+	t.routesShifted = true
+	t.refreshRoutes()
+	t.updateStatus(ctx, models.PluginUpgradeStatusDraining)
+	// 旧实例保留一段时间处理完已接收的请求,期间新实例持续不健康就回滚
+	if err := t.watchNewInstances(ctx, time.Duration(t.param.DrainSeconds)*time.Second); err != nil {
+		t.rollback(ctx, err)
+		return
+	}
+	var removeErrors []string
+	for _, instance := range t.oldInstances {
+		if err := RemovePluginInstance(ctx, instance, t.oldImageName == t.dockerResource.ImageName); err != nil {
+			log.Logger.Error("remove old plugin instance fail", log.String("upgradeId", t.record.Id), log.String("instanceId", instance.Id), log.Error(err))
+			removeErrors = append(removeErrors, fmt.Sprintf("remove old instance %s fail,%s", instance.Id, err.Error()))
+		}
+	}
+	t.record.ErrorMessage = strings.Join(removeErrors, "\n")
+	t.updateStatus(ctx, models.PluginUpgradeStatusDone)
+	log.Logger.Info("plugin upgrade done", log.String("upgradeId", t.record.Id), log.String("plugin", t.record.PluginName), log.String("version", t.record.ToVersion))
+}
+
+// waitNewInstancesHealthy 等待所有新实例健康检查通过
+func (t *pluginUpgradeTask) waitNewInstancesHealthy(ctx context.Context) (err error) {
+	deadline := time.Now().Add(time.Duration(t.param.HealthTimeoutSeconds) * time.Second)
+	pendingInstances := t.newInstances
+	for {
+		var stillPending []*models.PluginInstances
+		var lastErr error
+		for _, instance := range pendingInstances {
+			if probeErr := probePluginInstance(ctx, t.buildProbeObj(instance), t.probeTimeout); probeErr != nil {
+				stillPending = append(stillPending, instance)
+				lastErr = fmt.Errorf("instance %s:%d not healthy,%s", instance.Host, instance.Port, probeErr.Error())
+			}
+		}
+		if len(stillPending) == 0 {
+			return
+		}
+		t.keepAlive(ctx)
+		if time.Now().After(deadline) {
+			err = fmt.Errorf("wait new instances healthy timeout after %ds,%s", t.param.HealthTimeoutSeconds, lastErr.Error())
+			return
+		}
+		pendingInstances = stillPending
+		time.Sleep(pluginUpgradeProbeInterval)
+	}
+}
+
+// watchNewInstances 观察期内持续探测新实例,连续失败达到阈值返回错误
+func (t *pluginUpgradeTask) watchNewInstances(ctx context.Context, duration time.Duration) (err error) {
+	failuresMap := make(map[string]int)
+	deadline := time.Now().Add(duration)
+	for time.Now().Before(deadline) {
+		time.Sleep(pluginUpgradeProbeInterval)
+		for _, instance := range t.newInstances {
+			probeErr := probePluginInstance(ctx, t.buildProbeObj(instance), t.probeTimeout)
+			if probeErr == nil {
+				failuresMap[instance.Id] = 0
+				continue
+			}
+			failuresMap[instance.Id] = failuresMap[instance.Id] + 1
+			log.Logger.Warn("upgraded plugin instance health check fail", log.String("upgradeId", t.record.Id), log.String("instanceId", instance.Id), log.Int("failures", failuresMap[instance.Id]), log.Error(probeErr))
+			if failuresMap[instance.Id] >= t.failThreshold {
+				err = fmt.Errorf("instance %s:%d unhealthy after routes shifted,%s", instance.Host, instance.Port, probeErr.Error())
+				return
+			}
+		}
+		t.keepAlive(ctx)
+	}
+	return
+}
+
+// rollback 路由切回旧实例并销毁已启动的新实例,数据库升级脚本不回滚
+func (t *pluginUpgradeTask) rollback(ctx context.Context, cause error) {
+	log.Logger.Error("plugin upgrade fail,start rollback", log.String("upgradeId", t.record.Id), log.String("plugin", t.record.PluginName), log.Error(cause))
+	messages := []string{cause.Error()}
+	if t.dbUpgraded {
+		messages = append(messages, fmt.Sprintf("database upgrade sql of version %s has been executed and is not rolled back", t.record.ToVersion))
+	}
+	if t.routesShifted {
+		if err := database.ShiftPluginInstanceRoutes(ctx, t.instanceIds(t.oldInstances), t.instanceIds(t.newInstances)); err != nil {
+			// 旧实例恢复不了路由时保留新实例,避免插件完全不可用
+			messages = append(messages, "restore old instance routes fail,"+err.Error())
+			t.record.ErrorMessage = strings.Join(messages, "\n")
+			t.updateStatus(ctx, models.PluginUpgradeStatusFailed)
+			return
+		}
+		t.refreshRoutes()
+	}
+	for _, instance := range t.newInstances {
+		if err := RemovePluginInstance(ctx, instance, t.oldImageName == t.dockerResource.ImageName); err != nil {
+			log.Logger.Error("remove new plugin instance fail", log.String("upgradeId", t.record.Id), log.String("instanceId", instance.Id), log.Error(err))
+			messages = append(messages, fmt.Sprintf("remove new instance %s fail,%s", instance.Id, err.Error()))
+		}
+	}
+	t.record.ErrorMessage = strings.Join(messages, "\n")
+	t.updateStatus(ctx, models.PluginUpgradeStatusRolledBack)
+}
+
+func (t *pluginUpgradeTask) updateStatus(ctx context.Context, status string) {
+	t.record.Status = status
+	if err := database.UpdatePluginPackageUpgrade(ctx, t.record); err != nil {
+		log.Logger.Error("update plugin upgrade status fail", log.String("upgradeId", t.record.Id), log.String("status", status), log.Error(err))
+	}
+}
+
+// keepAlive 等待期间定时更新纪录,避免被当成platform下线的过期升级
+func (t *pluginUpgradeTask) keepAlive(ctx context.Context) {
+	if time.Since(t.record.UpdatedTime) > time.Minute {
+		t.updateStatus(ctx, t.record.Status)
+	}
+}
+
+// RecoverInterruptedPluginUpgrades platform重启后处理本实例中断的升级,纪录置为失败
+// STANDBY的新实例还没切流量直接销毁,DRAINING的旧实例已摘除路由也销毁,两种状态不会同时存在
+func RecoverInterruptedPluginUpgrades(ctx context.Context) {
+	records, err := database.GetInterruptedPluginUpgrades(ctx, models.Config.HostIp)
+	if err != nil {
+		log.Logger.Error("get interrupted plugin upgrade fail", log.Error(err))
+		return
+	}
+	for _, record := range records {
+		messages := []string{"interrupted by platform restart"}
+		instances, getErr := database.GetPluginInstancesByStatus(ctx, record.PluginName, []string{models.PluginInstanceStatusStandby, models.PluginInstanceStatusDraining})
+		if getErr != nil {
+			messages = append(messages, "get standby and draining instances fail,"+getErr.Error())
+		}
+		for _, instance := range instances {
+			// 镜像可能还在被其它版本实例使用,保留
+			if removeErr := RemovePluginInstance(ctx, instance, true); removeErr != nil {
+				messages = append(messages, fmt.Sprintf("remove %s instance %s fail,%s", instance.ContainerStatus, instance.Id, removeErr.Error()))
+			} else {
+				messages = append(messages, fmt.Sprintf("remove %s instance %s", instance.ContainerStatus, instance.Id))
+			}
+		}
+		record.Status = models.PluginUpgradeStatusFailed
+		record.ErrorMessage = strings.Join(messages, "\n")
+		if err = database.UpdatePluginPackageUpgrade(ctx, record); err != nil {
+			log.Logger.Error("update interrupted plugin upgrade fail", log.String("upgradeId", record.Id), log.Error(err))
+			continue
+		}
+		log.Logger.Warn("fail interrupted plugin upgrade", log.String("upgradeId", record.Id), log.String("plugin", record.PluginName), log.String("message", record.ErrorMessage))
+	}
+}
+
+// refreshRoutes gateway从core拉取运行中的实例,触发刷新即可完成路由切换
+func (t *pluginUpgradeTask) refreshRoutes() {
+	instance := t.newInstances[0]
+	if err := remote.RegisterPluginRoute(t.record.PluginName, instance.Host, strconv.Itoa(instance.Port)); err != nil {
+		log.Logger.Error("refresh plugin gateway route fail", log.String("upgradeId", t.record.Id), log.Error(err))
+	}
+}
+
+func (t *pluginUpgradeTask) buildProbeObj(instance *models.PluginInstances) *models.PluginInstanceHealthQueryObj {
+	return &models.PluginInstanceHealthQueryObj{
+		Id:                       instance.Id,
+		Host:                     instance.Host,
+		Port:                     instance.Port,
+		ContainerName:            instance.ContainerName,
+		ContainerStatus:          instance.ContainerStatus,
+		DockerInstanceResourceId: instance.DockerInstanceResourceId,
+		PackageName:              t.record.PluginName,
+		PackageVersion:           t.record.ToVersion,
+		HealthCheckPath:          t.dockerResource.HealthCheckPath,
+	}
+}
+
+func (t *pluginUpgradeTask) instanceIds(instances []*models.PluginInstances) (ids []string) {
+	for _, instance := range instances {
+		ids = append(ids, instance.Id)
+	}
+	return
+}
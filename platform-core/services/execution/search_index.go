@@ -0,0 +1,112 @@
+package execution
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/tools"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+)
+
+type procSearchIndexTask struct {
+	ProcInsId     string
+	ProcInsNodeId string
+}
+
+var procSearchIndexChan = make(chan *procSearchIndexTask, 1000)
+
+// AddProcInsSearchIndexTask 节点结束后加入建索引队列,队列满时丢弃由定时补偿
+func AddProcInsSearchIndexTask(procInsId, procInsNodeId string) {
+	select {
+	case procSearchIndexChan <- &procSearchIndexTask{ProcInsId: procInsId, ProcInsNodeId: procInsNodeId}:
+	default:
+		log.Logger.Warn("proc search index queue full,wait for backfill", log.String("procInsNodeId", procInsNodeId))
+	}
+}
+
+// StartProcSearchIndexer 异步消费建索引队列,不阻塞编排执行
+func StartProcSearchIndexer() {
+	for task := range procSearchIndexChan {
+		ctx := context.WithValue(context.Background(), models.TransactionIdHeader, fmt.Sprintf("proc_search_index_%d", time.Now().UnixNano()))
+		if err := indexProcInsNode(ctx, task.ProcInsId, task.ProcInsNodeId); err != nil {
+			log.Logger.Error("index proc ins node fail", log.String("procInsNodeId", task.ProcInsNodeId), log.Error(err))
+		}
+	}
+}
+
+// HandleProcSearchIndexBackfill 补建最近一天已结束但没有索引的节点
+func HandleProcSearchIndexBackfill(ctx context.Context) {
+	nodeList, err := database.GetUnindexedProcInsNodes(ctx, time.Now().Add(-24*time.Hour), 500)
+	if err != nil {
+		log.Logger.Error("query unindexed proc ins node fail", log.Error(err))
+		return
+	}
+	for _, node := range nodeList {
+		if err = indexProcInsNode(ctx, node.ProcInsId, node.Id); err != nil {
+			log.Logger.Error("backfill proc ins node index fail", log.String("procInsNodeId", node.Id), log.Error(err))
+		}
+	}
+}
+
+// HandleProcSearchIndexCleanup 清理已删除或归档出主表的编排实例的索引,每批500个实例直到清完
+func HandleProcSearchIndexCleanup(ctx context.Context) {
+	for {
+		procInsIds, err := database.GetOrphanProcSearchIndexInsIds(ctx, 500)
+		if err != nil {
+			log.Logger.Error("query orphan proc search index fail", log.Error(err))
+			return
+		}
+		if len(procInsIds) == 0 {
+			return
+		}
+		if err = database.DeleteProcInsSearchIndex(ctx, procInsIds); err != nil {
+			log.Logger.Error("delete orphan proc search index fail", log.Error(err))
+			return
+		}
+		log.Logger.Info("delete orphan proc search index", log.Int("procInsNum", len(procInsIds)))
+	}
+}
+
+// indexProcInsNode 重建节点的索引,编排数据有变化时一起重建实例的数据索引
+func indexProcInsNode(ctx context.Context, procInsId, procInsNodeId string) (err error) {
+	if procInsId != "" {
+		needIndex, entityDocs, getErr := database.GetProcInsEntitySearchDocs(ctx, procInsId)
+		if getErr != nil {
+			return getErr
+		}
+		if needIndex {
+			if err = database.SaveProcInsSearchIndex(ctx, "ins_"+procInsId, procInsId, buildProcSearchIndexRows("", entityDocs)); err != nil {
+				return
+			}
+		}
+	}
+	if procInsNodeId == "" {
+		return
+	}
+	nodeProcInsId, nodeDocs, getErr := database.GetProcInsNodeSearchDocs(ctx, procInsNodeId)
+	if getErr != nil {
+		return getErr
+	}
+	err = database.SaveProcInsSearchIndex(ctx, "node_"+procInsNodeId, nodeProcInsId, buildProcSearchIndexRows(procInsNodeId, nodeDocs))
+	return
+}
+
+// buildProcSearchIndexRows 每段原文分词后生成索引行,同一段原文里的重复分词只保留一行
+func buildProcSearchIndexRows(procInsNodeId string, docs []*models.ProcSearchDoc) (rows []*models.ProcInsSearchIndex) {
+	for _, doc := range docs {
+		snippet := []rune(doc.Text)
+		if len(snippet) > models.ProcSearchSnippetLength {
+			snippet = snippet[:models.ProcSearchSnippetLength]
+		}
+		for _, token := range tools.SplitSearchTokens(doc.Text, models.ProcSearchMaxDocTokens) {
+			if len(rows) >= models.ProcSearchMaxNodeTokens {
+				return
+			}
+			rows = append(rows, &models.ProcInsSearchIndex{Token: token, ProcInsNodeId: procInsNodeId, Source: doc.Source, Field: doc.Field, Snippet: string(snippet)})
+		}
+	}
+	return
+}
@@ -0,0 +1,91 @@
+package execution
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
+)
+
+// HandleProcSlaCheck 检查运行中超过时限的编排实例和节点,纪录超时并发邮件给实例启动人和编排管理角色
+func HandleProcSlaCheck(ctx context.Context) {
+	nowTime := time.Now()
+	insList, err := database.GetOverdueProcInstances(ctx, nowTime)
+	if err != nil {
+		log.Logger.Error("handle proc sla check fail with query overdue instances", log.Error(err))
+		return
+	}
+	nodeList, err := database.GetOverdueProcInsNodes(ctx, nowTime)
+	if err != nil {
+		log.Logger.Error("handle proc sla check fail with query overdue nodes", log.Error(err))
+		return
+	}
+	for _, row := range append(insList, nodeList...) {
+		breach := &row.ProcInsSlaBreach
+		breach.BreachTime = nowTime
+		ok, addErr := database.AddProcSlaBreach(ctx, breach)
+		if addErr != nil {
+			log.Logger.Error("add proc sla breach fail", log.String("procInsId", breach.ProcInsId), log.String("procInsNodeId", breach.ProcInsNodeId), log.Error(addErr))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		log.Logger.Warn("proc sla breach", log.String("type", breach.BreachType), log.String("procInsId", breach.ProcInsId), log.String("procInsNodeId", breach.ProcInsNodeId), log.Int("expectedMinutes", breach.ExpectedMinutes))
+		notifyStatus, notifyMessage := models.SlaNotifyStatusDone, ""
+		if notifyErr := sendProcSlaBreachMail(ctx, row); notifyErr != nil {
+			log.Logger.Error("send proc sla breach mail fail", log.String("procInsId", breach.ProcInsId), log.Error(notifyErr))
+			notifyStatus, notifyMessage = models.SlaNotifyStatusFail, notifyErr.Error()
+		}
+		if updateErr := database.UpdateProcSlaBreachNotify(ctx, breach.Id, notifyStatus, notifyMessage); updateErr != nil {
+			log.Logger.Error("update proc sla breach notify status fail", log.Int("breachId", breach.Id), log.Error(updateErr))
+		}
+	}
+}
+
+func sendProcSlaBreachMail(ctx context.Context, row *models.ProcSlaBreachQueryObj) (err error) {
+	acceptMap := make(map[string]bool)
+	var acceptList []string
+	addAccept := func(mail string) {
+		if mail != "" && !acceptMap[mail] {
+			acceptMap[mail] = true
+			acceptList = append(acceptList, mail)
+		}
+	}
+	if row.ProcInsCreatedBy != "" {
+		if userObj, userErr := remote.RetrieveUserByUsername(ctx, row.ProcInsCreatedBy, remote.GetToken(), "en"); userErr != nil {
+			log.Logger.Warn("get proc instance creator mail fail", log.String("user", row.ProcInsCreatedBy), log.Error(userErr))
+		} else {
+			addAccept(userObj.EmailAddr)
+		}
+	}
+	permissionList, err := database.GetProcDefPermissionByCondition(ctx, models.ProcDefPermission{ProcDefId: row.ProcDefId, Permission: string(models.MGMT)})
+	if err != nil {
+		return
+	}
+	for _, permission := range permissionList {
+		if roleObj, roleErr := remote.RetrieveRoleByRoleName(ctx, permission.RoleName, remote.GetToken(), "en"); roleErr != nil {
+			log.Logger.Warn("get proc def manage role mail fail", log.String("role", permission.RoleName), log.Error(roleErr))
+		} else {
+			addAccept(roleObj.Email)
+		}
+	}
+	if len(acceptList) == 0 {
+		err = fmt.Errorf("accept mail empty")
+		return
+	}
+	mailObj := models.SendMailTarget{Accept: acceptList}
+	if row.BreachType == models.SlaBreachTypeNode {
+		mailObj.Subject = fmt.Sprintf("Wecube Process Node SLA Breach,[%s][%s][%s]", row.ProcDefName, row.EntityDataName, row.NodeName)
+	} else {
+		mailObj.Subject = fmt.Sprintf("Wecube Process SLA Breach,[%s][%s]", row.ProcDefName, row.EntityDataName)
+	}
+	mailObj.Content = mailObj.Subject + fmt.Sprintf("\nProcess Instance Id:%s \nStart Time:%s \nExpected Minutes:%d \nBreach Time:%s \n", row.ProcInsId,
+		row.StartTime.Format(models.DateTimeFormat), row.ExpectedMinutes, row.BreachTime.Format(models.DateTimeFormat))
+	err = remote.SendSmtpMail(mailObj)
+	return
+}
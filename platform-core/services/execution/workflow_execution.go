@@ -3,14 +3,17 @@ package execution
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/exterror"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/tools"
 	"github.com/WeBankPartners/wecube-platform/platform-core/models"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -37,20 +40,13 @@ func WorkflowExecutionCallPluginService(ctx context.Context, param *models.ProcC
 		ProcInsNodeId: param.ProcInsNode.Id,
 		ReqUrl:        fmt.Sprintf("%s%s", models.Config.Gateway.Url, param.PluginInterface.Path),
 	}
-	rootExprList, errAnalyze1 := remote.AnalyzeExpression(param.EntityType)
-	if errAnalyze1 != nil {
-		err = errAnalyze1
-		return
+	if param.MultiInstance && len(param.EntityInstances) == 1 {
+		procInsNodeReq.EntityDataId = param.EntityInstances[0].Id
 	}
-	if len(rootExprList) == 0 {
-		err = fmt.Errorf("invalid input entity type %s", param.EntityType)
-		return
-	}
-	rootExpr := rootExprList[len(rootExprList)-1]
 	// 获取subsystem token
 	subsysToken := remote.GetToken()
 	// 构造输入参数
-	inputParamDatas, errHandle := handleInputData(ctx, subsysToken, param.ContinueToken, param.EntityInstances, param.PluginInterface.InputParameters, rootExpr, param.InputConstantMap, param.InputParamContext, &procInsNodeReq)
+	inputParamDatas, errHandle := buildWorkflowPluginInputData(ctx, subsysToken, param, &procInsNodeReq)
 	if errHandle != nil {
 		err = errHandle
 		return
@@ -58,16 +54,8 @@ func WorkflowExecutionCallPluginService(ctx context.Context, param *models.ProcC
 	procInsNodeReq.ReqDataAmount = len(inputParamDatas)
 	// 调用高危插件
 	if param.RiskCheck {
-		itsdangerousCallParam := &models.BatchExecutionItsdangerousExecParam{
-			Operator:        param.Operator,
-			ServiceName:     param.PluginInterface.ServiceName,
-			ServicePath:     param.PluginInterface.ServiceDisplayName,
-			EntityType:      param.EntityType,
-			EntityInstances: param.EntityInstances,
-			InputParams:     inputParamDatas,
-		}
 		// 需要有运行时的高危插件
-		dangerousResult, errDangerous := performWorkflowDangerousCheck(ctx, itsdangerousCallParam, param.ContinueToken, subsysToken)
+		dangerousResult, errDangerous := performWorkflowDangerousCheck(ctx, buildWorkflowDangerousCheckParam(param, inputParamDatas), param.ContinueToken, subsysToken)
 		if errDangerous != nil {
 			err = errDangerous
 			return
@@ -101,6 +89,7 @@ func WorkflowExecutionCallPluginService(ctx context.Context, param *models.ProcC
 			}
 		}
 		err = errCall
+		procInsNodeReq.ErrorCode = errCode
 		procInsNodeReq.ErrorMsg = err.Error()
 		database.RecordProcCallReq(ctx, &procInsNodeReq, false)
 		return
@@ -119,6 +108,45 @@ func WorkflowExecutionCallPluginService(ctx context.Context, param *models.ProcC
 	return
 }
 
+func buildWorkflowPluginInputData(ctx context.Context, subsysToken string, param *models.ProcCallPluginServiceFuncParam, procInsNodeReq *models.ProcInsNodeReq) (inputParamDatas []models.BatchExecutionPluginExecInputParams, err error) {
+	rootExprList, errAnalyze1 := remote.AnalyzeExpression(param.EntityType)
+	if errAnalyze1 != nil {
+		err = errAnalyze1
+		return
+	}
+	if len(rootExprList) == 0 {
+		err = fmt.Errorf("invalid input entity type %s", param.EntityType)
+		return
+	}
+	rootExpr := rootExprList[len(rootExprList)-1]
+	inputParamDatas, err = handleInputData(ctx, subsysToken, param.ContinueToken, param.EntityInstances, param.PluginInterface.InputParameters, rootExpr, param.InputConstantMap, param.InputParamContext, procInsNodeReq)
+	return
+}
+
+func buildWorkflowDangerousCheckParam(param *models.ProcCallPluginServiceFuncParam, inputParamDatas []models.BatchExecutionPluginExecInputParams) *models.BatchExecutionItsdangerousExecParam {
+	return &models.BatchExecutionItsdangerousExecParam{
+		Operator:        param.Operator,
+		ServiceName:     param.PluginInterface.ServiceName,
+		ServicePath:     param.PluginInterface.ServiceDisplayName,
+		EntityType:      param.EntityType,
+		EntityInstances: param.EntityInstances,
+		InputParams:     inputParamDatas,
+	}
+}
+
+// checkWorkflowNodeDangerous 对节点的全部数据做一次高危检测,不调用插件
+func checkWorkflowNodeDangerous(ctx context.Context, param *models.ProcCallPluginServiceFuncParam) (dangerousCheckResult *models.ItsdangerousWorkflowCheckResultData, err error) {
+	subsysToken := remote.GetToken()
+	procInsNodeReq := models.ProcInsNodeReq{ProcInsNodeId: param.ProcInsNode.Id}
+	inputParamDatas, buildErr := buildWorkflowPluginInputData(ctx, subsysToken, param, &procInsNodeReq)
+	if buildErr != nil {
+		err = buildErr
+		return
+	}
+	dangerousCheckResult, err = performWorkflowDangerousCheck(ctx, buildWorkflowDangerousCheckParam(param, inputParamDatas), param.ContinueToken, subsysToken)
+	return
+}
+
 func performWorkflowDangerousCheck(ctx context.Context, pluginCallParam interface{}, continueToken string, authToken string) (result *models.ItsdangerousWorkflowCheckResultData, err error) {
 	if continueToken != "" {
 		return
@@ -212,6 +240,10 @@ func DoWorkflowAutoJob(ctx context.Context, procRunNodeId, continueToken string,
 		Operator:          "SYSTEM",
 		ProcInsNode:       procInsNode,
 	}
+	if multiInstance := models.ConvertString2MultiInstanceDto(procDefNode.MultiInstance); multiInstance != nil && multiInstance.Enable {
+		err = doWorkflowMultiInstanceJob(ctx, &callPluginServiceParam, multiInstance, retryFlag)
+		return
+	}
 	callOutput, dangerousCheckResult, pluginCallParam, callErr := WorkflowExecutionCallPluginService(ctx, &callPluginServiceParam)
 	if callErr != nil {
 		err = callErr
@@ -225,6 +257,204 @@ func DoWorkflowAutoJob(ctx context.Context, procRunNodeId, continueToken string,
 	return
 }
 
+// doWorkflowMultiInstanceJob 多实例执行,每条数据单独调用插件并限制并发,重试时只调用之前失败的数据
+func doWorkflowMultiInstanceJob(ctx context.Context, param *models.ProcCallPluginServiceFuncParam, multiInstance *models.MultiInstanceDto, retryFlag bool) (err error) {
+	totalNum := len(param.EntityInstances)
+	successNum := 0
+	var todoInstances []*models.BatchExecutionPluginExecEntityInstances
+	if retryFlag {
+		successEntityList, getSuccessErr := database.GetProcNodeSuccessEntityList(ctx, param.ProcInsNode.Id)
+		if getSuccessErr != nil {
+			err = getSuccessErr
+			return
+		}
+		successEntityMap := make(map[string]bool)
+		for _, v := range successEntityList {
+			successEntityMap[v] = true
+		}
+		for _, v := range param.EntityInstances {
+			if successEntityMap[v.Id] {
+				successNum += 1
+				continue
+			}
+			todoInstances = append(todoInstances, v)
+		}
+	} else {
+		todoInstances = param.EntityInstances
+	}
+	concurrency := multiInstance.Concurrency
+	if concurrency <= 0 || concurrency > len(todoInstances) {
+		concurrency = len(todoInstances)
+	}
+	log.Logger.Info("do multi instance job", log.String("procInsNode", param.ProcInsNode.Id), log.Int("total", totalNum), log.Int("todo", len(todoInstances)), log.Int("concurrency", concurrency))
+	// 高危检测和单实例一样对节点数据整体做一次,命中时纪录检测结果,不按单条数据失败处理
+	if param.RiskCheck && len(todoInstances) > 0 {
+		checkParam := *param
+		checkParam.EntityInstances = todoInstances
+		dangerousCheckResult, checkErr := checkWorkflowNodeDangerous(ctx, &checkParam)
+		if checkErr != nil {
+			err = checkErr
+			return
+		}
+		if dangerousCheckResult != nil {
+			dangerousCheckResultBytes, _ := json.Marshal(dangerousCheckResult)
+			database.UpdateProcInsNodeData(ctx, param.ProcInsNode.Id, "", "", string(dangerousCheckResultBytes))
+			return
+		}
+	}
+	var errorList []string
+	resultLock := new(sync.Mutex)
+	wg := sync.WaitGroup{}
+	concurrencyChan := make(chan int, concurrency)
+	for _, entityInstance := range todoInstances {
+		concurrencyChan <- 1
+		wg.Add(1)
+		go func(instance *models.BatchExecutionPluginExecEntityInstances) {
+			defer func() {
+				<-concurrencyChan
+				wg.Done()
+			}()
+			instanceParam := *param
+			instanceParam.EntityInstances = []*models.BatchExecutionPluginExecEntityInstances{instance}
+			instanceParam.MultiInstance = true
+			instanceParam.RiskCheck = false
+			_, _, _, callErr := WorkflowExecutionCallPluginService(ctx, &instanceParam)
+			resultLock.Lock()
+			if callErr != nil {
+				errorList = append(errorList, fmt.Sprintf("%s:%s", instance.Id, callErr.Error()))
+			} else {
+				successNum += 1
+			}
+			resultLock.Unlock()
+		}(entityInstance)
+	}
+	wg.Wait()
+	if !multiInstance.ThresholdMatch(successNum, totalNum) {
+		err = fmt.Errorf("multi instance success %d/%d not match threshold %s,%s", successNum, totalNum, multiInstance.Threshold, strings.Join(errorList, ";"))
+	} else if len(errorList) > 0 {
+		log.Logger.Warn("multi instance job match threshold with some entity fail", log.String("procInsNode", param.ProcInsNode.Id), log.StringList("errors", errorList))
+	}
+	return
+}
+
+// CheckWorkflowNodeRetry 根据节点重试策略判断第attempt次失败后是否需要重试,并把本次失败纪录到节点请求历史中
+func CheckWorkflowNodeRetry(ctx context.Context, procRunNodeId string, attempt int, attemptStartTime time.Time, jobErr error) (retryFlag bool, backoff time.Duration) {
+	procInsNode, err := database.GetSimpleProcInsNode(ctx, "", procRunNodeId)
+	if err != nil {
+		log.Logger.Error("check workflow node retry fail,get proc ins node error", log.String("procRunNode", procRunNodeId), log.Error(err))
+		return
+	}
+	procDefNode, err := database.GetSimpleProcDefNode(ctx, procInsNode.ProcDefNodeId)
+	if err != nil {
+		log.Logger.Error("check workflow node retry fail,get proc def node error", log.String("procRunNode", procRunNodeId), log.Error(err))
+		return
+	}
+	retryPolicy := models.ConvertString2RetryPolicyDto(procDefNode.RetryPolicy)
+	if retryPolicy == nil {
+		return
+	}
+	if err = database.RecordProcNodeRetryAttempt(ctx, procInsNode.Id, attempt, attemptStartTime.Truncate(time.Second), getJobErrorCode(jobErr), jobErr.Error()); err != nil {
+		log.Logger.Error("record proc node retry attempt fail", log.String("procInsNode", procInsNode.Id), log.Error(err))
+	}
+	errorCode, err := database.GetProcNodeAttemptErrorCode(ctx, procInsNode.Id, attempt)
+	if err != nil {
+		log.Logger.Error("check workflow node retry,get attempt error code fail", log.String("procInsNode", procInsNode.Id), log.Error(err))
+	}
+	if retryFlag = retryPolicy.Retryable(attempt, errorCode, jobErr.Error()); retryFlag {
+		backoff = retryPolicy.Backoff(attempt)
+	}
+	return
+}
+
+// getJobErrorCode 节点失败没有插件返回的错误码时,取平台错误的编码
+func getJobErrorCode(jobErr error) string {
+	var customErr exterror.CustomError
+	if errors.As(jobErr, &customErr) {
+		return customErr.Key
+	}
+	return ""
+}
+
+// GetWorkflowNodeLoopConfig 获取循环节点的循环配置
+func GetWorkflowNodeLoopConfig(ctx context.Context, procRunNodeId string) (loopConfig *models.LoopConfigDto, err error) {
+	procInsNode, err := database.GetSimpleProcInsNode(ctx, "", procRunNodeId)
+	if err != nil {
+		return
+	}
+	procDefNode, err := database.GetSimpleProcDefNode(ctx, procInsNode.ProcDefNodeId)
+	if err != nil {
+		return
+	}
+	loopConfig = models.ConvertString2LoopConfigDto(procDefNode.LoopConfig)
+	if loopConfig == nil || loopConfig.MaxIterations <= 0 || loopConfig.ExitCondition == "" {
+		err = fmt.Errorf("loop node:%s loop config illegal", procDefNode.Name)
+	}
+	return
+}
+
+// BuildDecisionConditionVars 构造判断节点分支条件的变量,input为判断节点输入,output为上游节点最近一次插件调用结果,entity为根数据,proc为编排实例信息
+func BuildDecisionConditionVars(ctx context.Context, procInsId, sourceRunNodeId, input string) (vars map[string]interface{}, err error) {
+	procIns, getProcInsErr := database.GetSimpleProcInsRow(ctx, procInsId)
+	if getProcInsErr != nil {
+		err = getProcInsErr
+		return
+	}
+	outputMap := make(map[string]interface{})
+	if sourceRunNodeId != "" {
+		procReq, getReqErr := database.GetSimpleProcNodeReq(ctx, "", "", sourceRunNodeId)
+		if getReqErr != nil {
+			err = getReqErr
+			return
+		}
+		if procReq != nil {
+			outputMap["errorCode"] = procReq.ErrorCode
+			outputMap["errorMessage"] = procReq.ErrorMsg
+			reqParams, getParamErr := database.GetProcNodeReqParamList(ctx, procReq.Id, "output")
+			if getParamErr != nil {
+				err = getParamErr
+				return
+			}
+			// 多组数据时取第一组数据的输出
+			for _, reqParam := range reqParams {
+				if reqParam.DataIndex != reqParams[0].DataIndex {
+					break
+				}
+				outputMap[reqParam.Name] = reqParam.DataValue
+			}
+		}
+	}
+	entityMap := make(map[string]interface{})
+	cacheDataList, getCacheErr := database.GetProcCacheData(ctx, procInsId)
+	if getCacheErr != nil {
+		err = getCacheErr
+		return
+	}
+	for _, cacheData := range cacheDataList {
+		if cacheData.EntityDataId != procIns.EntityDataId {
+			continue
+		}
+		if cacheData.DataValue != "" {
+			if unmarshalErr := json.Unmarshal([]byte(cacheData.DataValue), &entityMap); unmarshalErr != nil {
+				log.Logger.Warn("decision condition parse entity data value fail", log.String("entityDataId", cacheData.EntityDataId), log.Error(unmarshalErr))
+			}
+		}
+		break
+	}
+	entityMap["id"] = procIns.EntityDataId
+	entityMap["displayName"] = procIns.EntityDataName
+	vars = map[string]interface{}{
+		"input":  input,
+		"output": outputMap,
+		"entity": entityMap,
+		"proc": map[string]interface{}{
+			"id":         procIns.Id,
+			"procDefKey": procIns.ProcDefKey,
+			"operator":   procIns.CreatedBy,
+		},
+	}
+	return
+}
+
 func DoWorkflowDataJob(ctx context.Context, procRunNodeId string, retryFlag bool) (err error) {
 	ctx = context.WithValue(ctx, models.TransactionIdHeader, procRunNodeId)
 	// 查proc def node定义和proc ins绑定数据
@@ -412,6 +642,12 @@ func DoWorkflowHumanJob(ctx context.Context, procRunNodeId string, recoverFlag b
 	} else if pluginInterface.Type == "APPROVAL" {
 		err = CallDynamicFormReq(ctx, &callPluginServiceParam)
 	}
+	if err != nil {
+		return
+	}
+	if createTaskErr := createWorkflowHumanTask(ctx, procRunNodeId, procInsNode, procDefNode, procIns); createTaskErr != nil {
+		log.Logger.Error("create workflow human task fail", log.String("procRunNodeId", procRunNodeId), log.Error(createTaskErr))
+	}
 	return
 }
 
@@ -633,6 +869,142 @@ func HandleCallbackHumanJob(ctx context.Context, procRunNodeId string, callbackD
 	if len(taskFormList) > 0 {
 		err = database.UpdateProcCacheData(ctx, procInsNode.ProcInsId, taskFormList)
 	}
+	if completeErr := database.CompleteHumanTask(ctx, procRunNodeId, choseOption); completeErr != nil {
+		log.Logger.Error("complete human task fail", log.String("procRunNodeId", procRunNodeId), log.Error(completeErr))
+	}
+	return
+}
+
+// DoWorkflowSubProcJob 子编排节点,按节点绑定数据逐条创建子编排实例,节点本次开始执行后已存在且未终止的子编排不重复创建
+func DoWorkflowSubProcJob(ctx context.Context, procRunNodeId string, nodeStartTime time.Time) (subProcList []*models.SubProcInsObj, err error) {
+	ctx = context.WithValue(ctx, models.TransactionIdHeader, procRunNodeId)
+	procInsNode, procDefNode, _, dataBindings, getNodeDataErr := database.GetProcExecNodeData(ctx, procRunNodeId)
+	if getNodeDataErr != nil {
+		err = getNodeDataErr
+		return
+	}
+	if procDefNode.SubProcDefId == "" {
+		err = fmt.Errorf("sub process node:%s with empty sub proc def id", procDefNode.Name)
+		return
+	}
+	if procDefNode.DynamicBind {
+		if dataBindings, err = database.GetDynamicBindNodeData(ctx, procInsNode.ProcInsId, procDefNode.ProcDefId, procDefNode.BindNodeId); err != nil {
+			return
+		}
+	}
+	if len(dataBindings) == 0 {
+		log.Logger.Warn("sub process job return with empty binding data", log.String("procIns", procInsNode.ProcInsId), log.String("procInsNode", procInsNode.Id))
+		return
+	}
+	procIns, getProcInsErr := database.GetSimpleProcInsRow(ctx, procInsNode.ProcInsId)
+	if getProcInsErr != nil {
+		err = getProcInsErr
+		return
+	}
+	if err = database.AddProcCacheData(ctx, procInsNode.ProcInsId, dataBindings); err != nil {
+		return
+	}
+	existSubProcList, getExistErr := database.GetSubProcInsList(ctx, procInsNode.Id, nodeStartTime)
+	if getExistErr != nil {
+		err = getExistErr
+		return
+	}
+	// 重试或恢复时,已终止的子编排重新拉起,其余的继续等待
+	existSubProcMap := make(map[string]*models.SubProcInsObj)
+	for _, v := range existSubProcList {
+		if v.Status == models.JobStatusFail || v.Status == models.JobStatusKill || v.Status == models.JobStatusTimeout {
+			continue
+		}
+		existSubProcMap[v.EntityDataId] = v
+	}
+	operator := procIns.CreatedBy
+	for _, bindingObj := range dataBindings {
+		if existObj, ok := existSubProcMap[bindingObj.EntityDataId]; ok {
+			subProcList = append(subProcList, existObj)
+			continue
+		}
+		previewData, buildErr := BuildProcPreviewData(ctx, procDefNode.SubProcDefId, bindingObj.EntityDataId, operator)
+		if buildErr != nil {
+			err = fmt.Errorf("build sub process preview data with entity:%s fail,%s ", bindingObj.EntityDataId, buildErr.Error())
+			return
+		}
+		procStartParam := models.ProcInsStartParam{
+			EntityDataId:      bindingObj.EntityDataId,
+			EntityDisplayName: bindingObj.EntityDataName,
+			EntityTypeId:      bindingObj.EntityTypeId,
+			ProcDefId:         procDefNode.SubProcDefId,
+			ProcessSessionId:  previewData.ProcessSessionId,
+			ParentInsId:       procIns.Id,
+			ParentInsNodeId:   procInsNode.Id,
+		}
+		newProcInsId, workflowRow, workNodes, workLinks, createInsErr := database.CreateProcInstance(ctx, &procStartParam, operator)
+		if createInsErr != nil {
+			err = createInsErr
+			return
+		}
+		subProcList = append(subProcList, &models.SubProcInsObj{ProcInsId: newProcInsId, EntityDataId: bindingObj.EntityDataId, Status: models.JobStatusReady, WorkflowRow: workflowRow, WorkNodes: workNodes, WorkLinks: workLinks})
+	}
+	return
+}
+
+// CheckWorkflowSubProcJob 检查子编排实例运行状态,全部完成时把子编排数据回写父编排并返回子编排结果作为节点输出,有子编排失败时返回错误
+func CheckWorkflowSubProcJob(ctx context.Context, procInsId, procInsNodeId string, nodeStartTime time.Time) (doneFlag bool, output string, err error) {
+	subProcList, getErr := database.GetSubProcInsList(ctx, procInsNodeId, nodeStartTime)
+	if getErr != nil {
+		err = getErr
+		return
+	}
+	var errorList []string
+	doneFlag = true
+	for _, v := range subProcList {
+		switch v.Status {
+		case models.JobStatusSuccess:
+			continue
+		case models.JobStatusFail, models.JobStatusKill, models.JobStatusTimeout:
+			errorList = append(errorList, fmt.Sprintf("sub process instance:%s status:%s", v.ProcInsId, v.Status))
+		default:
+			// 子编排有节点失败时工作流仍在运行中,此时也认为子编排失败,等子编排处理后再重试父节点
+			if v.ErrorMessage != "" {
+				errorList = append(errorList, fmt.Sprintf("sub process instance:%s has node error:%s", v.ProcInsId, v.ErrorMessage))
+			} else {
+				doneFlag = false
+			}
+		}
+	}
+	if len(errorList) > 0 {
+		err = errors.New(strings.Join(errorList, ";"))
+		return
+	}
+	if !doneFlag {
+		return
+	}
+	var subProcInsIds []string
+	for _, v := range subProcList {
+		subProcInsIds = append(subProcInsIds, v.ProcInsId)
+	}
+	if err = database.MergeSubProcCacheData(ctx, procInsId, subProcInsIds); err != nil {
+		doneFlag = false
+		return
+	}
+	outputBytes, _ := json.Marshal(subProcList)
+	output = string(outputBytes)
+	return
+}
+
+// KillWorkflowSubProc 给父编排实例(或父编排节点)拉起还在运行的子编排添加终止操作,返回新增的操作由调用方通知处理
+func KillWorkflowSubProc(ctx context.Context, parentInsId, parentInsNodeId, operator string) (operations []*models.ProcRunOperation, err error) {
+	workflowIds, getErr := database.GetRunningSubProcWorkflowIds(ctx, parentInsId, parentInsNodeId)
+	if getErr != nil {
+		err = getErr
+		return
+	}
+	for _, workflowId := range workflowIds {
+		operationObj := models.ProcRunOperation{WorkflowId: workflowId, Operation: "kill", Status: "wait", Message: "kill by parent process " + parentInsId, CreatedBy: operator}
+		if operationObj.Id, err = database.AddWorkflowOperation(ctx, &operationObj); err != nil {
+			return
+		}
+		operations = append(operations, &operationObj)
+	}
 	return
 }
 
@@ -665,8 +1037,24 @@ func buildAutoNodeContextMap(ctx context.Context,
 	if len(sourceNodeDataBindings) == 0 {
 		return
 	}
-	// 找两份dataBinding的关系，通过判断目标的full_data_id里有没有源的entity_data_id来确定目标是不是源关联出来的数据
-	entityDataMap := make(map[string][]*models.ProcDataBinding) // key -> 目标的entityDataId  value -> 源的绑定数据
+	entityDataMap := buildNodeEntityDataMap(sourceNodeDataBindings, targetNodeDataBindings)
+	if len(entityDataMap) == 0 {
+		return
+	}
+	sourceReq, getSourceReqErr := database.GetProcInsNodeContext(ctx, procIns.Id, "", bindNodeDef.Id)
+	if getSourceReqErr != nil {
+		err = getSourceReqErr
+		return
+	}
+	fillNodeContextMap(entityInstances, entityDataMap, sourceReq.RequestObjects, bindParamType, bindParamName, paramName)
+	log.Logger.Debug("buildAutoNodeContextMap done", log.JsonObj("entityInstances", entityInstances))
+	return
+}
+
+// buildNodeEntityDataMap 找两份dataBinding的关系，通过判断目标的full_data_id里有没有源的entity_data_id来确定目标是不是源关联出来的数据
+// key -> 目标的entityDataId  value -> 源的绑定数据
+func buildNodeEntityDataMap(sourceNodeDataBindings, targetNodeDataBindings []*models.ProcDataBinding) (entityDataMap map[string][]*models.ProcDataBinding) {
+	entityDataMap = make(map[string][]*models.ProcDataBinding)
 	for _, targetData := range targetNodeDataBindings {
 		fullDataList := strings.Split(targetData.FullDataId, "::")
 		for _, sourceData := range sourceNodeDataBindings {
@@ -679,19 +1067,19 @@ func buildAutoNodeContextMap(ctx context.Context,
 			}
 		}
 	}
-	if len(entityDataMap) == 0 {
-		return
-	}
-	sourceReq, getSourceReqErr := database.GetProcInsNodeContext(ctx, procIns.Id, "", bindNodeDef.Id)
-	if getSourceReqErr != nil {
-		err = getSourceReqErr
-		return
-	}
+	return
+}
+
+// fillNodeContextMap 按源节点和目标节点绑定数据的关联关系,把源节点请求的出入参填到目标数据的上下文中
+func fillNodeContextMap(entityInstances []*models.BatchExecutionPluginExecEntityInstances,
+	entityDataMap map[string][]*models.ProcDataBinding,
+	sourceReqObjects []models.ProcNodeContextReqObject,
+	bindParamType, bindParamName, paramName string) {
 	for _, entityInstance := range entityInstances {
 		if sourceBindList, ok := entityDataMap[entityInstance.Id]; ok {
 			var sourceBindValues []interface{}
 			for _, sourceBindObj := range sourceBindList {
-				for _, sourceReqData := range sourceReq.RequestObjects {
+				for _, sourceReqData := range sourceReqObjects {
 					if sourceReqData.CallbackParameter == sourceBindObj.EntityDataId {
 						if bindParamType == "INPUT" {
 							if len(sourceReqData.Inputs) > 0 {
@@ -712,8 +1100,6 @@ func buildAutoNodeContextMap(ctx context.Context,
 			}
 		}
 	}
-	log.Logger.Debug("buildAutoNodeContextMap done", log.JsonObj("entityInstances", entityInstances))
-	return
 }
 
 func BuildProcPreviewData(c context.Context, procDefId, entityDataId, operator string) (result *models.ProcPreviewData, err error) {
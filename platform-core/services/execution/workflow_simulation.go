@@ -0,0 +1,309 @@
+package execution
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/WeBankPartners/go-common-lib/guid"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/database"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
+)
+
+const (
+	simulationStatusSimulated = "simulated"
+	simulationStatusSkipped   = "skipped"
+	simulationStatusError     = "error"
+
+	simulationSensitiveMask = "******"
+)
+
+// procSimulation 试运行上下文,节点按顺序模拟执行,插件出参用模拟值代替
+type procSimulation struct {
+	ctx          context.Context
+	procIns      *models.ProcIns
+	nodeIdMap    map[string]*models.ProcDefNode               // key:node.NodeId
+	nodeBindings map[string][]*models.ProcDataBinding         // key:node.Id
+	reqObjects   map[string][]models.ProcNodeContextReqObject // key:node.Id 模拟的请求出入参,供后续节点取上下文参数
+}
+
+// SimulateProcInstance 试运行编排,按试算数据解析每个节点的目标数据和入参,不调用插件也不回写数据
+func SimulateProcInstance(ctx context.Context, param *models.ProcInsStartParam, operator string) (report *models.ProcSimulationReport, err error) {
+	procDefObj, getProcDefErr := database.GetSimpleProcDefRow(ctx, param.ProcDefId)
+	if getProcDefErr != nil {
+		err = getProcDefErr
+		return
+	}
+	sessionId := param.ProcessSessionId
+	if sessionId == "" {
+		if param.EntityDataId == "" {
+			err = fmt.Errorf("processSessionId and entityDataId can not both empty")
+			return
+		}
+		previewData, previewErr := BuildProcPreviewData(ctx, param.ProcDefId, param.EntityDataId, operator)
+		if previewErr != nil {
+			err = previewErr
+			return
+		}
+		sessionId = previewData.ProcessSessionId
+	}
+	previewRows, getPreviewErr := database.GetProcPreviewRows(ctx, sessionId)
+	if getPreviewErr != nil {
+		err = getPreviewErr
+		return
+	}
+	nodeList, getNodeErr := database.GetProcDefNodeById(ctx, param.ProcDefId)
+	if getNodeErr != nil {
+		err = getNodeErr
+		return
+	}
+	sort.SliceStable(nodeList, func(i, j int) bool {
+		return nodeList[i].OrderedNo < nodeList[j].OrderedNo
+	})
+	s := &procSimulation{
+		ctx:          ctx,
+		procIns:      &models.ProcIns{Id: "simulation_" + guid.CreateGuid(), ProcDefId: procDefObj.Id, ProcDefKey: procDefObj.Key, ProcDefName: procDefObj.Name, ProcSessionId: sessionId},
+		nodeIdMap:    make(map[string]*models.ProcDefNode),
+		nodeBindings: make(map[string][]*models.ProcDataBinding),
+		reqObjects:   make(map[string][]models.ProcNodeContextReqObject),
+	}
+	for _, row := range previewRows {
+		if row.BindType == "process" {
+			s.procIns.EntityDataId = row.EntityDataId
+			s.procIns.EntityTypeId = row.EntityTypeId
+			s.procIns.EntityDataName = row.EntityDataName
+		}
+	}
+	for _, node := range nodeList {
+		s.nodeIdMap[node.NodeId] = node
+		for _, row := range previewRows {
+			if row.ProcDefNodeId == node.Id && row.IsBound {
+				s.nodeBindings[node.Id] = append(s.nodeBindings[node.Id], &models.ProcDataBinding{ProcDefId: procDefObj.Id, ProcInsId: s.procIns.Id, ProcDefNodeId: node.Id,
+					EntityId: row.EntityDataId, EntityDataId: row.EntityDataId, EntityDataName: row.EntityDataName, EntityTypeId: row.EntityTypeId, BindFlag: row.IsBound, BindType: row.BindType, FullDataId: row.FullDataId})
+			}
+		}
+	}
+	report = &models.ProcSimulationReport{ProcDefId: procDefObj.Id, ProcDefName: procDefObj.Name, ProcessSessionId: sessionId,
+		EntityDataId: s.procIns.EntityDataId, EntityDataName: s.procIns.EntityDataName, Nodes: []*models.ProcSimulationNode{}}
+	for _, node := range nodeList {
+		simNode := &models.ProcSimulationNode{NodeId: node.NodeId, Name: node.Name, NodeType: node.NodeType, OrderedNo: node.OrderedNo, ServiceName: node.ServiceName,
+			RiskCheck: node.RiskCheck, Status: simulationStatusSimulated, Targets: []*models.ProcSimulationTarget{}}
+		var simErr error
+		switch node.NodeType {
+		case models.JobAutoType:
+			simErr = s.simulateAutoNode(node, simNode)
+		case models.JobHumanType:
+			simErr = s.simulateHumanNode(node, simNode)
+		case models.JobDataType:
+			simErr = s.simulateDataNode(node, simNode)
+		case models.JobSubProcType:
+			s.appendBindingTargets(s.getNodeDataBindings(node), simNode)
+			simNode.Message = fmt.Sprintf("sub process %s would start for each target", node.SubProcDefId)
+		case models.JobDecisionType:
+			options, getOptionErr := database.GetProcNodeAllowOptions(ctx, node.ProcDefId, node.Id)
+			if getOptionErr != nil {
+				simErr = getOptionErr
+			} else {
+				simNode.Message = fmt.Sprintf("branch is decided at runtime,options:%s", strings.Join(options, ","))
+			}
+		case models.JobLoopType:
+			if loopConfig := models.ConvertString2LoopConfigDto(node.LoopConfig); loopConfig != nil {
+				simNode.Message = fmt.Sprintf("loop body is simulated once,runtime repeats until %s or %d iterations", loopConfig.ExitCondition, loopConfig.MaxIterations)
+			}
+		case models.JobApprovalType:
+			if approvalConfig := models.ConvertString2ApprovalConfigDto(node.ApprovalConfig); approvalConfig != nil {
+				simNode.Message = fmt.Sprintf("approval waits for votes of %d stage(s) at runtime", len(approvalConfig.Stages))
+			}
+		}
+		if simErr != nil {
+			simNode.Status = simulationStatusError
+			simNode.Message = simErr.Error()
+		}
+		report.Nodes = append(report.Nodes, simNode)
+	}
+	log.Logger.Info("simulate proc instance done", log.String("procDefId", param.ProcDefId), log.String("sessionId", sessionId), log.String("operator", operator))
+	return
+}
+
+// getNodeDataBindings 节点绑定数据,动态绑定的取绑定节点的数据
+func (s *procSimulation) getNodeDataBindings(node *models.ProcDefNode) []*models.ProcDataBinding {
+	if node.DynamicBind {
+		if bindNode, ok := s.nodeIdMap[node.BindNodeId]; ok {
+			return s.nodeBindings[bindNode.Id]
+		}
+		return nil
+	}
+	return s.nodeBindings[node.Id]
+}
+
+func (s *procSimulation) appendBindingTargets(dataBindings []*models.ProcDataBinding, simNode *models.ProcSimulationNode) {
+	for _, binding := range dataBindings {
+		simNode.Targets = append(simNode.Targets, &models.ProcSimulationTarget{EntityTypeId: binding.EntityTypeId, EntityDataId: binding.EntityDataId, EntityDataName: binding.EntityDataName})
+	}
+}
+
+// buildNodeParamMap 和 DoWorkflowAutoJob 一样解析节点的静态参数和上下文参数,上下文取之前模拟节点的出入参
+func (s *procSimulation) buildNodeParamMap(node *models.ProcDefNode, pluginInterface *models.PluginConfigInterfaces, entityInstances []*models.BatchExecutionPluginExecEntityInstances, dataBindings []*models.ProcDataBinding) (inputConstantMap map[string]string, inputContextMap map[string]interface{}, err error) {
+	procDefNodeParams, getParamErr := database.GetProcDefNodeParamByNodeId(s.ctx, node.Id)
+	if getParamErr != nil {
+		err = getParamErr
+		return
+	}
+	inputConstantMap = make(map[string]string)
+	inputContextMap = make(map[string]interface{})
+	interfaceParamIdMap := make(map[string]string)
+	for _, v := range pluginInterface.InputParameters {
+		interfaceParamIdMap[v.Name] = v.Id
+	}
+	for _, v := range pluginInterface.OutputParameters {
+		interfaceParamIdMap[v.Name] = v.Id
+	}
+	for _, v := range procDefNodeParams {
+		if v.BindType == "constant" {
+			inputConstantMap[interfaceParamIdMap[v.Name]] = v.Value
+		} else if v.BindType == "context" {
+			bindNodeDef, ok := s.nodeIdMap[v.CtxBindNode]
+			if !ok {
+				err = fmt.Errorf("can not find context bind node:%s in procDef:%s ", v.CtxBindNode, node.ProcDefId)
+				return
+			}
+			if bindNodeDef.NodeType == models.JobStartType {
+				buildStartNodeContextMap(inputContextMap, s.procIns)
+			} else if bindNodeDef.NodeType == models.JobAutoType || bindNodeDef.NodeType == models.JobHumanType {
+				fillNodeContextMap(entityInstances, buildNodeEntityDataMap(s.getNodeDataBindings(bindNodeDef), dataBindings), s.reqObjects[bindNodeDef.Id], v.CtxBindType, v.CtxBindName, v.Name)
+			}
+		}
+	}
+	return
+}
+
+func (s *procSimulation) simulateAutoNode(node *models.ProcDefNode, simNode *models.ProcSimulationNode) (err error) {
+	dataBindings := s.getNodeDataBindings(node)
+	if len(dataBindings) == 0 {
+		simNode.Status = simulationStatusSkipped
+		simNode.Message = "empty binding data"
+		return
+	}
+	pluginInterface, getIntErr := database.GetLastEnablePluginInterface(s.ctx, node.ServiceName)
+	if getIntErr != nil {
+		err = getIntErr
+		return
+	}
+	var entityInstances []*models.BatchExecutionPluginExecEntityInstances
+	for _, bindingObj := range dataBindings {
+		entityInstances = append(entityInstances, &models.BatchExecutionPluginExecEntityInstances{Id: bindingObj.EntityDataId, ContextMap: make(map[string]interface{})})
+	}
+	inputConstantMap, inputContextMap, buildErr := s.buildNodeParamMap(node, pluginInterface, entityInstances, dataBindings)
+	if buildErr != nil {
+		err = buildErr
+		return
+	}
+	rootExprList, analyzeErr := remote.AnalyzeExpression(node.RoutineExpression)
+	if analyzeErr != nil {
+		err = analyzeErr
+		return
+	}
+	if len(rootExprList) == 0 {
+		err = fmt.Errorf("invalid input entity type %s", node.RoutineExpression)
+		return
+	}
+	// 只解析入参,不记录请求也不调用插件
+	procInsNodeReq := models.ProcInsNodeReq{Id: s.procIns.Id}
+	inputParamDatas, handleErr := handleInputData(s.ctx, remote.GetToken(), "", entityInstances, pluginInterface.InputParameters, rootExprList[len(rootExprList)-1], inputConstantMap, inputContextMap, &procInsNodeReq)
+	if handleErr != nil {
+		err = handleErr
+		return
+	}
+	for i, inputParamData := range inputParamDatas {
+		outputs := buildSimulationOutputs(pluginInterface)
+		s.reqObjects[node.Id] = append(s.reqObjects[node.Id], models.ProcNodeContextReqObject{CallbackParameter: entityInstances[i].Id, Inputs: []map[string]interface{}{inputParamData}, Outputs: []map[string]interface{}{outputs}})
+		simNode.Targets = append(simNode.Targets, &models.ProcSimulationTarget{EntityTypeId: dataBindings[i].EntityTypeId, EntityDataId: dataBindings[i].EntityDataId, EntityDataName: dataBindings[i].EntityDataName,
+			Inputs: maskSimulationInputs(pluginInterface, inputParamData), Outputs: outputs})
+	}
+	if multiInstance := models.ConvertString2MultiInstanceDto(node.MultiInstance); multiInstance != nil && multiInstance.Enable {
+		simNode.Message = fmt.Sprintf("multi instance,each target calls plugin separately,concurrency:%d", multiInstance.Concurrency)
+	}
+	return
+}
+
+func (s *procSimulation) simulateHumanNode(node *models.ProcDefNode, simNode *models.ProcSimulationNode) (err error) {
+	pluginInterface, getIntErr := database.GetLastEnablePluginInterface(s.ctx, node.ServiceName)
+	if getIntErr != nil {
+		err = getIntErr
+		return
+	}
+	dataBindings := s.getNodeDataBindings(node)
+	// 人工任务以根数据发起
+	entityInstance := &models.BatchExecutionPluginExecEntityInstances{Id: s.procIns.EntityDataId, ContextMap: make(map[string]interface{})}
+	inputConstantMap, inputContextMap, buildErr := s.buildNodeParamMap(node, pluginInterface, []*models.BatchExecutionPluginExecEntityInstances{entityInstance}, dataBindings)
+	if buildErr != nil {
+		err = buildErr
+		return
+	}
+	inputs := make(map[string]interface{})
+	for _, inputDef := range pluginInterface.InputParameters {
+		if value, ok := inputConstantMap[inputDef.Id]; ok {
+			inputs[inputDef.Name] = value
+		} else if value, ok := entityInstance.ContextMap[inputDef.Name]; ok {
+			inputs[inputDef.Name] = value
+		} else if value, ok := inputContextMap[inputDef.Name]; ok {
+			inputs[inputDef.Name] = value
+		}
+	}
+	inputs[models.PluginCallParamPresetCallback] = entityInstance.Id
+	outputs := buildSimulationOutputs(pluginInterface)
+	s.reqObjects[node.Id] = append(s.reqObjects[node.Id], models.ProcNodeContextReqObject{CallbackParameter: entityInstance.Id, Inputs: []map[string]interface{}{inputs}, Outputs: []map[string]interface{}{outputs}})
+	simNode.Targets = append(simNode.Targets, &models.ProcSimulationTarget{EntityTypeId: s.procIns.EntityTypeId, EntityDataId: s.procIns.EntityDataId, EntityDataName: s.procIns.EntityDataName,
+		Inputs: maskSimulationInputs(pluginInterface, inputs), Outputs: outputs})
+	simNode.Message = fmt.Sprintf("human task would be created with %d bound data", len(dataBindings))
+	return
+}
+
+func (s *procSimulation) simulateDataNode(node *models.ProcDefNode, simNode *models.ProcSimulationNode) (err error) {
+	exprObjList, parseErr := database.GetProcDataNodeExpression(node.RoutineExpression)
+	if parseErr != nil {
+		err = parseErr
+		return
+	}
+	dataBindings := s.getNodeDataBindings(node)
+	if len(dataBindings) == 0 {
+		simNode.Status = simulationStatusSkipped
+		simNode.Message = "empty binding data"
+		return
+	}
+	s.appendBindingTargets(dataBindings, simNode)
+	var operationList []string
+	for _, exprObj := range exprObjList {
+		operationList = append(operationList, fmt.Sprintf("%s(%s)", exprObj.Operation, exprObj.Expression))
+	}
+	simNode.Message = fmt.Sprintf("data would be written back:%s", strings.Join(operationList, ","))
+	return
+}
+
+// buildSimulationOutputs 模拟插件成功返回,出参用占位值
+func buildSimulationOutputs(pluginInterface *models.PluginConfigInterfaces) map[string]interface{} {
+	outputs := map[string]interface{}{"errorCode": "0", "errorMessage": ""}
+	for _, outputDef := range pluginInterface.OutputParameters {
+		if _, ok := outputs[outputDef.Name]; !ok {
+			outputs[outputDef.Name] = fmt.Sprintf("[mock]%s", outputDef.Name)
+		}
+	}
+	return outputs
+}
+
+// maskSimulationInputs 报告里隐藏敏感参数
+func maskSimulationInputs(pluginInterface *models.PluginConfigInterfaces, inputs map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	for k, v := range inputs {
+		result[k] = v
+	}
+	for _, inputDef := range pluginInterface.InputParameters {
+		if _, ok := result[inputDef.Name]; ok && inputDef.SensitiveData == "Y" {
+			result[inputDef.Name] = simulationSensitiveMask
+		}
+	}
+	return result
+}
@@ -1,6 +1,7 @@
 package remote
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -58,7 +59,7 @@ const (
 	pathGetRoleInfoByRoleName = "/auth/v1/roles/name/%s"
 )
 
-func RegisterSubSystem(pluginPackageObj *models.PluginPackages) (subSystemCode, subSystemKey, subSystemPubKey string, err error) {
+func RegisterSubSystem(ctx context.Context, pluginPackageObj *models.PluginPackages) (subSystemCode, subSystemKey, subSystemPubKey string, err error) {
 	var byteArr []byte
 	subSystemCode = fmt.Sprintf("SYS_%s", strings.ToUpper(pluginPackageObj.Name))
 	param := models.SimpleSubSystemDto{
@@ -70,7 +71,7 @@ func RegisterSubSystem(pluginPackageObj *models.PluginPackages) (subSystemCode,
 	}
 	postBytes, _ := json.Marshal(param)
 	log.Logger.Debug("RegisterSubSystem", log.String("token", GetToken()))
-	byteArr, err = network.HttpPost(models.Config.Auth.Url+pathRegisterSubSystem, GetToken(), "en", postBytes)
+	byteArr, err = network.HttpPost(ctx, models.Config.Auth.Url+pathRegisterSubSystem, GetToken(), "en", postBytes)
 	if err != nil {
 		return
 	}
@@ -89,10 +90,10 @@ func RegisterSubSystem(pluginPackageObj *models.PluginPackages) (subSystemCode,
 	return
 }
 
-func RegisterLocalUser(userDto *models.SimpleLocalUserDto, userToken, language string) (response models.QuerySingleUserResponse, err error) {
+func RegisterLocalUser(ctx context.Context, userDto *models.SimpleLocalUserDto, userToken, language string) (response models.QuerySingleUserResponse, err error) {
 	var byteArr []byte
 	postBytes, _ := json.Marshal(userDto)
-	byteArr, err = network.HttpPost(models.Config.Auth.Url+pathRegisterLocalUser, userToken, language, postBytes)
+	byteArr, err = network.HttpPost(ctx, models.Config.Auth.Url+pathRegisterLocalUser, userToken, language, postBytes)
 	if err != nil {
 		return
 	}
@@ -104,8 +105,8 @@ func RegisterLocalUser(userDto *models.SimpleLocalUserDto, userToken, language s
 }
 
 // RetrieveAllUsers 获取所有用户
-func RetrieveAllUsers(userToken, language string) (response models.QueryUserResponse, err error) {
-	byteArr, err := network.HttpGet(models.Config.Auth.Url+pathRetrieveAllUserAccounts, userToken, language)
+func RetrieveAllUsers(ctx context.Context, userToken, language string) (response models.QueryUserResponse, err error) {
+	byteArr, err := network.HttpGet(ctx, models.Config.Auth.Url+pathRetrieveAllUserAccounts, userToken, language)
 	if err != nil {
 		return
 	}
@@ -118,8 +119,8 @@ func RetrieveAllUsers(userToken, language string) (response models.QueryUserResp
 }
 
 // RetrieveAllLocalRoles 查询所有角色
-func RetrieveAllLocalRoles(requiredAll, userToken, language string, roleAdmin bool) (response models.QueryRolesResponse, err error) {
-	byteArr, err := network.HttpGet(fmt.Sprintf(models.Config.Auth.Url+pathRetrieveAllRoles, requiredAll, strconv.FormatBool(roleAdmin)), userToken, language)
+func RetrieveAllLocalRoles(ctx context.Context, requiredAll, userToken, language string, roleAdmin bool) (response models.QueryRolesResponse, err error) {
+	byteArr, err := network.HttpGet(ctx, fmt.Sprintf(models.Config.Auth.Url+pathRetrieveAllRoles, requiredAll, strconv.FormatBool(roleAdmin)), userToken, language)
 	if err != nil {
 		return
 	}
@@ -132,8 +133,8 @@ func RetrieveAllLocalRoles(requiredAll, userToken, language string, roleAdmin bo
 }
 
 // GetRolesByUsername 根据用户名获取角色
-func GetRolesByUsername(username, userToken, language string) (response models.QueryRolesResponse, err error) {
-	byteArr, err := network.HttpGet(fmt.Sprintf(models.Config.Auth.Url+pathRetrieveGrantedRolesByUsername, username), userToken, language)
+func GetRolesByUsername(ctx context.Context, username, userToken, language string) (response models.QueryRolesResponse, err error) {
+	byteArr, err := network.HttpGet(ctx, fmt.Sprintf(models.Config.Auth.Url+pathRetrieveGrantedRolesByUsername, username), userToken, language)
 	if err != nil {
 		return
 	}
@@ -146,9 +147,9 @@ func GetRolesByUsername(username, userToken, language string) (response models.Q
 }
 
 // RetrieveRoleInfo 根据roleId获取角色
-func RetrieveRoleInfo(roleId, userToken, language string) (response models.QuerySingleRolesResponse, err error) {
+func RetrieveRoleInfo(ctx context.Context, roleId, userToken, language string) (response models.QuerySingleRolesResponse, err error) {
 	url := fmt.Sprintf(models.Config.Auth.Url+pathRetrieveRoleById, roleId)
-	byteArr, err := network.HttpGet(url, userToken, language)
+	byteArr, err := network.HttpGet(ctx, url, userToken, language)
 	if err != nil {
 		return
 	}
@@ -161,8 +162,8 @@ func RetrieveRoleInfo(roleId, userToken, language string) (response models.Query
 }
 
 // GetUsersByRoleId 返回角色用户列表
-func GetUsersByRoleId(roleId, userToken, language string) (response models.QueryUserResponse, err error) {
-	byteArr, err := network.HttpGet(fmt.Sprintf(models.Config.Auth.Url+pathRetrieveAllUsersBelongsToRoleId, roleId), userToken, language)
+func GetUsersByRoleId(ctx context.Context, roleId, userToken, language string) (response models.QueryUserResponse, err error) {
+	byteArr, err := network.HttpGet(ctx, fmt.Sprintf(models.Config.Auth.Url+pathRetrieveAllUsersBelongsToRoleId, roleId), userToken, language)
 	if err != nil {
 		return
 	}
@@ -175,18 +176,18 @@ func GetUsersByRoleId(roleId, userToken, language string) (response models.Query
 }
 
 // ConfigureUserWithRoles 修改用户角色
-func ConfigureUserWithRoles(userId, userToken, language string, rolesList []string) (err error) {
+func ConfigureUserWithRoles(ctx context.Context, userId, userToken, language string, rolesList []string) (err error) {
 	var postParams []*models.SimpleLocalRoleDto
 	for _, role := range rolesList {
 		postParams = append(postParams, &models.SimpleLocalRoleDto{ID: role})
 	}
 	postBytes, _ := json.Marshal(postParams)
-	err = network.HttpPostCommon(fmt.Sprintf(models.Config.Auth.Url+pathConfigureRolesForUser, userId), userToken, language, postBytes)
+	err = network.HttpPostCommon(ctx, fmt.Sprintf(models.Config.Auth.Url+pathConfigureRolesForUser, userId), userToken, language, postBytes)
 	return
 }
 
 // ModifyLocalUserPassword 修改密码
-func ModifyLocalUserPassword(param models.UserPasswordChangeParam, username, userToken, language string) (response models.QuerySingleUserPassResponse, err error) {
+func ModifyLocalUserPassword(ctx context.Context, param models.UserPasswordChangeParam, username, userToken, language string) (response models.QuerySingleUserPassResponse, err error) {
 	var byteArr []byte
 	userPassDto := &models.SimpleLocalUserPassDto{
 		Username:         username,
@@ -194,7 +195,7 @@ func ModifyLocalUserPassword(param models.UserPasswordChangeParam, username, use
 		ChangedPassword:  param.NewPassword,
 	}
 	postBytes, _ := json.Marshal(userPassDto)
-	byteArr, err = network.HttpPost(models.Config.Auth.Url+pathUserChangePassword, userToken, language, postBytes)
+	byteArr, err = network.HttpPost(ctx, models.Config.Auth.Url+pathUserChangePassword, userToken, language, postBytes)
 	if err != nil {
 		return
 	}
@@ -206,11 +207,11 @@ func ModifyLocalUserPassword(param models.UserPasswordChangeParam, username, use
 }
 
 // ResetLocalUserPassword 重置密码
-func ResetLocalUserPassword(param models.UserPasswordResetParam, userToken, language string) (response models.RestUserPasswordResponse, err error) {
+func ResetLocalUserPassword(ctx context.Context, param models.UserPasswordResetParam, userToken, language string) (response models.RestUserPasswordResponse, err error) {
 	var byteArr []byte
 	userPassDto := &models.SimpleLocalUserPassDto{Username: param.Username}
 	postBytes, _ := json.Marshal(userPassDto)
-	byteArr, err = network.HttpPost(models.Config.Auth.Url+pathUserResetPassword, userToken, language, postBytes)
+	byteArr, err = network.HttpPost(ctx, models.Config.Auth.Url+pathUserResetPassword, userToken, language, postBytes)
 	if err != nil {
 		return
 	}
@@ -222,8 +223,8 @@ func ResetLocalUserPassword(param models.UserPasswordResetParam, userToken, lang
 }
 
 // RetrieveUserByUserId 获取用户信息
-func RetrieveUserByUserId(userId, userToken, language string) (response models.QuerySingleUserResponse, err error) {
-	byteArr, err := network.HttpGet(fmt.Sprintf(models.Config.Auth.Url+pathGetUserByUserId, userId), userToken, language)
+func RetrieveUserByUserId(ctx context.Context, userId, userToken, language string) (response models.QuerySingleUserResponse, err error) {
+	byteArr, err := network.HttpGet(ctx, fmt.Sprintf(models.Config.Auth.Url+pathGetUserByUserId, userId), userToken, language)
 	if err != nil {
 		return
 	}
@@ -236,15 +237,15 @@ func RetrieveUserByUserId(userId, userToken, language string) (response models.Q
 }
 
 // UnregisterLocalUser 删除用户
-func UnregisterLocalUser(userId, userToken, language string) error {
-	return network.HttpDeleteCommon(fmt.Sprintf(models.Config.Auth.Url+pathDeleteUserAccountByUserId, userId), userToken, language)
+func UnregisterLocalUser(ctx context.Context, userId, userToken, language string) error {
+	return network.HttpDeleteCommon(ctx, fmt.Sprintf(models.Config.Auth.Url+pathDeleteUserAccountByUserId, userId), userToken, language)
 }
 
 // UpdateLocalRole 更新角色
-func UpdateLocalRole(userToken, language string, param models.SimpleLocalRoleDto) (response models.QuerySingleRolesResponse, err error) {
+func UpdateLocalRole(ctx context.Context, userToken, language string, param models.SimpleLocalRoleDto) (response models.QuerySingleRolesResponse, err error) {
 	var byteArr []byte
 	postBytes, _ := json.Marshal(param)
-	byteArr, err = network.HttpPost(models.Config.Auth.Url+pathUpdateLocalRole, userToken, language, postBytes)
+	byteArr, err = network.HttpPost(ctx, models.Config.Auth.Url+pathUpdateLocalRole, userToken, language, postBytes)
 	if err != nil {
 		return
 	}
@@ -256,44 +257,44 @@ func UpdateLocalRole(userToken, language string, param models.SimpleLocalRoleDto
 }
 
 // ConfigureRoleForUsers 角色添加用户列表
-func ConfigureRoleForUsers(roleId, userToken, language string, userIdList []string) (err error) {
+func ConfigureRoleForUsers(ctx context.Context, roleId, userToken, language string, userIdList []string) (err error) {
 	var postParams []*models.SimpleLocalUserDto
 	for _, userId := range userIdList {
 		postParams = append(postParams, &models.SimpleLocalUserDto{ID: userId})
 	}
 	postBytes, _ := json.Marshal(postParams)
-	err = network.HttpPostCommon(fmt.Sprintf(models.Config.Auth.Url+pathConfigureRoleForUsers, roleId), userToken, language, postBytes)
+	err = network.HttpPostCommon(ctx, fmt.Sprintf(models.Config.Auth.Url+pathConfigureRoleForUsers, roleId), userToken, language, postBytes)
 	return
 }
 
 // RevokeRoleFromUsers 角色移除用户
-func RevokeRoleFromUsers(roleId, userToken, language string, userIdList []string) (err error) {
+func RevokeRoleFromUsers(ctx context.Context, roleId, userToken, language string, userIdList []string) (err error) {
 	var postParams []*models.SimpleLocalUserDto
 	for _, userId := range userIdList {
 		postParams = append(postParams, &models.SimpleLocalUserDto{ID: userId})
 	}
 	postBytes, _ := json.Marshal(postParams)
-	err = network.HttpPostCommon(fmt.Sprintf(models.Config.Auth.Url+pathRevokeRoleFromUsers, roleId), userToken, language, postBytes)
+	err = network.HttpPostCommon(ctx, fmt.Sprintf(models.Config.Auth.Url+pathRevokeRoleFromUsers, roleId), userToken, language, postBytes)
 	return
 }
 
 // RevokeRoleAuthoritiesById 取消角色授权
-func RevokeRoleAuthoritiesById(roleId, userToken, language string, authoritiesToRevoke []*models.SimpleAuthorityDto) error {
+func RevokeRoleAuthoritiesById(ctx context.Context, roleId, userToken, language string, authoritiesToRevoke []*models.SimpleAuthorityDto) error {
 	postBytes, _ := json.Marshal(authoritiesToRevoke)
-	return network.HttpPostCommon(fmt.Sprintf(models.Config.Auth.Url+pathRevokeAuthoritiesFromRole, roleId), userToken, language, postBytes)
+	return network.HttpPostCommon(ctx, fmt.Sprintf(models.Config.Auth.Url+pathRevokeAuthoritiesFromRole, roleId), userToken, language, postBytes)
 }
 
 // ConfigureRoleWithAuthoritiesById 配置角色权限
-func ConfigureRoleWithAuthoritiesById(roleId, userToken, language string, authoritiesToGrantList []*models.SimpleAuthorityDto) error {
+func ConfigureRoleWithAuthoritiesById(ctx context.Context, roleId, userToken, language string, authoritiesToGrantList []*models.SimpleAuthorityDto) error {
 	postBytes, _ := json.Marshal(authoritiesToGrantList)
-	return network.HttpPostCommon(fmt.Sprintf(models.Config.Auth.Url+pathConfigureRoleAuthorities, roleId), userToken, language, postBytes)
+	return network.HttpPostCommon(ctx, fmt.Sprintf(models.Config.Auth.Url+pathConfigureRoleAuthorities, roleId), userToken, language, postBytes)
 }
 
 // RegisterLocalRole 创建角色
-func RegisterLocalRole(roleDto *models.SimpleLocalRoleDto, userToken, language string) (response models.QuerySingleRolesResponse, err error) {
+func RegisterLocalRole(ctx context.Context, roleDto *models.SimpleLocalRoleDto, userToken, language string) (response models.QuerySingleRolesResponse, err error) {
 	var byteArr []byte
 	postBytes, _ := json.Marshal(roleDto)
-	byteArr, err = network.HttpPost(models.Config.Auth.Url+pathRegisterLocalRole, userToken, language, postBytes)
+	byteArr, err = network.HttpPost(ctx, models.Config.Auth.Url+pathRegisterLocalRole, userToken, language, postBytes)
 	if err != nil {
 		return
 	}
@@ -305,13 +306,13 @@ func RegisterLocalRole(roleDto *models.SimpleLocalRoleDto, userToken, language s
 }
 
 // ModifyUserInfo 修改用户信息
-func ModifyUserInfo(username, userToken, language string, param *models.UserDto) (err error) {
+func ModifyUserInfo(ctx context.Context, username, userToken, language string, param *models.UserDto) (err error) {
 	userDto := models.SimpleLocalUserDto{
 		Username:  username,
 		EmailAddr: param.Email,
 	}
 	postBytes, _ := json.Marshal(&userDto)
-	byteArr, err := network.HttpPost(fmt.Sprintf(models.Config.Auth.Url+pathModifyUserInfo, username), userToken, language, postBytes)
+	byteArr, err := network.HttpPost(ctx, fmt.Sprintf(models.Config.Auth.Url+pathModifyUserInfo, username), userToken, language, postBytes)
 	if err != nil {
 		return
 	}
@@ -328,8 +329,8 @@ func ModifyUserInfo(username, userToken, language string, param *models.UserDto)
 }
 
 // RetrieveUserByUsername 通过用户名获取用户信息
-func RetrieveUserByUsername(username, userToken, language string) (responseData *models.SimpleLocalUserDto, err error) {
-	byteArr, getErr := network.HttpGet(fmt.Sprintf(models.Config.Auth.Url+pathGetUserByUsername, username), userToken, language)
+func RetrieveUserByUsername(ctx context.Context, username, userToken, language string) (responseData *models.SimpleLocalUserDto, err error) {
+	byteArr, getErr := network.HttpGet(ctx, fmt.Sprintf(models.Config.Auth.Url+pathGetUserByUsername, username), userToken, language)
 	if getErr != nil {
 		err = getErr
 		return
@@ -349,8 +350,8 @@ func RetrieveUserByUsername(username, userToken, language string) (responseData
 }
 
 // RetrieveRoleByRoleName 通过角色名获取角色信息
-func RetrieveRoleByRoleName(roleName, userToken, language string) (responseData *models.SimpleLocalRoleDto, err error) {
-	byteArr, getErr := network.HttpGet(fmt.Sprintf(models.Config.Auth.Url+pathGetRoleInfoByRoleName, roleName), userToken, language)
+func RetrieveRoleByRoleName(ctx context.Context, roleName, userToken, language string) (responseData *models.SimpleLocalRoleDto, err error) {
+	byteArr, getErr := network.HttpGet(ctx, fmt.Sprintf(models.Config.Auth.Url+pathGetRoleInfoByRoleName, roleName), userToken, language)
 	if getErr != nil {
 		err = getErr
 		return
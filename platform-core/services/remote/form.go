@@ -1,11 +1,13 @@
 package remote
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/WeBankPartners/go-common-lib/guid"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/trace"
 	"github.com/WeBankPartners/wecube-platform/platform-core/models"
 	"io"
 	"net/http"
@@ -34,6 +36,7 @@ func GetInputFormMeta(ctx context.Context, procInstId, nodeDefId string, pluginI
 	req.Header.Set(models.RequestIdHeader, reqId)
 	req.Header.Set(models.TransactionIdHeader, transId)
 	req.Header.Set(models.AuthorizationHeader, GetToken())
+	trace.Inject(ctx, req.Header)
 	resp, respErr := http.DefaultClient.Do(req)
 	if respErr != nil {
 		err = fmt.Errorf("do request fail,%s ", respErr.Error())
@@ -59,6 +62,53 @@ func GetInputFormMeta(ctx context.Context, procInstId, nodeDefId string, pluginI
 	return
 }
 
+// ReassignPluginTask 通知任务表单插件人工任务处理人变更,插件接口路径后加/reassign
+func ReassignPluginTask(ctx context.Context, pluginInterface *models.PluginConfigInterfaces, param *models.PluginTaskReassignParam) (err error) {
+	uri := fmt.Sprintf("%s%s/reassign", models.Config.Gateway.Url, pluginInterface.Path)
+	if models.Config.HttpsEnable == "true" {
+		uri = "https://" + uri
+	} else {
+		uri = "http://" + uri
+	}
+	postBytes, _ := json.Marshal(param)
+	req, reqErr := http.NewRequest(http.MethodPost, uri, bytes.NewReader(postBytes))
+	if reqErr != nil {
+		err = fmt.Errorf("new request fail,%s ", reqErr.Error())
+		return
+	}
+	transId, _ := ctx.Value(models.TransactionIdHeader).(string)
+	req.Header.Set(models.RequestIdHeader, "req_"+guid.CreateGuid())
+	req.Header.Set(models.TransactionIdHeader, transId)
+	req.Header.Set(models.AuthorizationHeader, GetToken())
+	req.Header.Set("Content-Type", "application/json")
+	trace.Inject(ctx, req.Header)
+	resp, respErr := http.DefaultClient.Do(req)
+	if respErr != nil {
+		err = fmt.Errorf("do request fail,%s ", respErr.Error())
+		return
+	}
+	respBody, readBodyErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if readBodyErr != nil {
+		err = fmt.Errorf("read response body fail,%s ", readBodyErr.Error())
+		return
+	}
+	log.Logger.Debug("reassign plugin task", log.String("taskId", param.TaskId), log.String("response", string(respBody)))
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("reassign plugin task fail,http status:%d,%s ", resp.StatusCode, string(respBody))
+		return
+	}
+	var response models.ResponseWrap
+	if err = json.Unmarshal(respBody, &response); err != nil {
+		err = fmt.Errorf("json unmarshal response body fail,%s ", err.Error())
+		return
+	}
+	if response.Status != models.DefaultHttpSuccessCode {
+		err = fmt.Errorf("reassign plugin task fail,%s ", response.Message)
+	}
+	return
+}
+
 func CallPluginCustomForm() {
 
 }
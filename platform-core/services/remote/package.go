@@ -1,6 +1,7 @@
 package remote
 
 import (
+	"archive/zip"
 	"bufio"
 	"context"
 	"fmt"
@@ -71,3 +72,79 @@ func GetOnlinePluginPackageFile(ctx context.Context, fileName string) (result *o
 	result.Sync()
 	return
 }
+
+// ReadOnlinePluginPackageEntry 用Range请求只读取在线插件包里的单个文件,不下载整个包
+func ReadOnlinePluginPackageEntry(ctx context.Context, fileName, entryName string) (content []byte, err error) {
+	uri := models.Config.Plugin.PublicReleaseUrl + fileName
+	req, reqErr := http.NewRequestWithContext(ctx, http.MethodHead, uri, nil)
+	if reqErr != nil {
+		err = fmt.Errorf("new request fail,%s ", reqErr.Error())
+		return
+	}
+	resp, respErr := http.DefaultClient.Do(req)
+	if respErr != nil {
+		err = fmt.Errorf("do request fail,%s ", respErr.Error())
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength <= 0 {
+		err = fmt.Errorf("do request fail, status code=%d content length=%d", resp.StatusCode, resp.ContentLength)
+		return
+	}
+	zipReader, openErr := zip.NewReader(&httpRangeReader{ctx: ctx, uri: uri}, resp.ContentLength)
+	if openErr != nil {
+		err = fmt.Errorf("open plugin archive fail,%s ", openErr.Error())
+		return
+	}
+	for _, zipFile := range zipReader.File {
+		if zipFile.Name != entryName {
+			continue
+		}
+		fileReader, readErr := zipFile.Open()
+		if readErr != nil {
+			err = fmt.Errorf("open %s fail,%s ", entryName, readErr.Error())
+			return
+		}
+		defer fileReader.Close()
+		if content, err = io.ReadAll(fileReader); err != nil {
+			err = fmt.Errorf("read %s fail,%s ", entryName, err.Error())
+		}
+		return
+	}
+	err = fmt.Errorf("%s can not find in package", entryName)
+	return
+}
+
+// httpRangeReader 按偏移量发Range请求读取远程文件,zip只需读取目录和指定文件
+type httpRangeReader struct {
+	ctx context.Context
+	uri string
+}
+
+func (r *httpRangeReader) ReadAt(p []byte, off int64) (n int, err error) {
+	if len(p) == 0 {
+		return
+	}
+	req, reqErr := http.NewRequestWithContext(r.ctx, http.MethodGet, r.uri, nil)
+	if reqErr != nil {
+		err = fmt.Errorf("new request fail,%s ", reqErr.Error())
+		return
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	resp, respErr := http.DefaultClient.Do(req)
+	if respErr != nil {
+		err = fmt.Errorf("do request fail,%s ", respErr.Error())
+		return
+	}
+	defer resp.Body.Close()
+	// 不支持Range时返回200和整个文件,报错而不是下载整个包
+	if resp.StatusCode != http.StatusPartialContent {
+		err = fmt.Errorf("range request fail, status code=%d", resp.StatusCode)
+		return
+	}
+	n, err = io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return
+}
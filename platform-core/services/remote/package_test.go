@@ -0,0 +1,67 @@
+package remote
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+func TestReadOnlinePluginPackageEntry(t *testing.T) {
+	var archiveBuffer bytes.Buffer
+	zipWriter := zip.NewWriter(&archiveBuffer)
+	imageWriter, _ := zipWriter.CreateHeader(&zip.FileHeader{Name: "image.tar", Method: zip.Store})
+	imageContent := make([]byte, 1<<20)
+	rand.Read(imageContent)
+	imageWriter.Write(imageContent)
+	registerWriter, _ := zipWriter.Create("register.xml")
+	registerWriter.Write([]byte(`<package name="demo" version="v1.0.0"></package>`))
+	zipWriter.Close()
+	archiveContent := archiveBuffer.Bytes()
+
+	var servedBytes int64
+	supportRange := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !supportRange {
+			r.Header.Del("Range")
+		}
+		http.ServeContent(&countResponseWriter{ResponseWriter: w, count: &servedBytes}, r, "demo.zip", time.Now(), bytes.NewReader(archiveContent))
+	}))
+	defer server.Close()
+	originConfig := models.Config
+	models.Config = &models.GlobalConfig{Plugin: &models.PluginJsonConfig{PublicReleaseUrl: server.URL + "/"}}
+	defer func() {
+		models.Config = originConfig
+	}()
+
+	content, err := ReadOnlinePluginPackageEntry(context.Background(), "demo.zip", "register.xml")
+	if err != nil || string(content) != `<package name="demo" version="v1.0.0"></package>` {
+		t.Fatalf("ReadOnlinePluginPackageEntry() = %q, %v", content, err)
+	}
+	if servedBytes >= int64(len(imageContent)) {
+		t.Errorf("ReadOnlinePluginPackageEntry() should not download whole archive")
+	}
+	if _, err = ReadOnlinePluginPackageEntry(context.Background(), "demo.zip", "not-exist.xml"); err == nil {
+		t.Errorf("ReadOnlinePluginPackageEntry() missing entry should fail")
+	}
+	supportRange = false
+	if _, err = ReadOnlinePluginPackageEntry(context.Background(), "demo.zip", "register.xml"); err == nil {
+		t.Errorf("ReadOnlinePluginPackageEntry() without range support should fail")
+	}
+}
+
+type countResponseWriter struct {
+	http.ResponseWriter
+	count *int64
+}
+
+func (w *countResponseWriter) Write(p []byte) (int, error) {
+	*w.count += int64(len(p))
+	return w.ResponseWriter.Write(p)
+}
@@ -14,6 +14,8 @@ import (
 
 	"github.com/WeBankPartners/go-common-lib/guid"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/metric"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/trace"
 	"github.com/WeBankPartners/wecube-platform/platform-core/models"
 )
 
@@ -35,6 +37,7 @@ func GetPluginDataModels(ctx context.Context, pluginName, token string) (result
 	req.Header.Set(models.RequestIdHeader, reqId)
 	req.Header.Set(models.TransactionIdHeader, transId)
 	req.Header.Set(models.AuthorizationHeader, token)
+	trace.Inject(ctx, req.Header)
 	resp, respErr := http.DefaultClient.Do(req)
 	if respErr != nil {
 		err = fmt.Errorf("do request fail,%s ", respErr.Error())
@@ -388,6 +391,7 @@ func RequestPluginModelData(ctx context.Context, packageName, entity, token stri
 	req.Header.Set("Content-type", "application/json")
 	startTime := time.Now()
 	log.Logger.Info("Start remote modelData request --->>> ", log.String("requestId", reqId), log.String("transactionId", transId), log.String("method", http.MethodPost), log.String("url", urlObj.String()), log.JsonObj("Authorization", token), log.String("requestBody", string(postBytes)))
+	trace.Inject(ctx, req.Header)
 	resp, respErr := http.DefaultClient.Do(req)
 	if respErr != nil {
 		err = fmt.Errorf("do request fail,%s ", respErr.Error())
@@ -448,6 +452,7 @@ func CreatePluginModelData(ctx context.Context, packageName, entity, token, oper
 	}
 	startTime := time.Now()
 	log.Logger.Info("Start remote modelData create --->>> ", log.String("requestId", reqId), log.String("transactionId", transId), log.String("method", http.MethodPost), log.String("url", urlObj.String()), log.String("operation", operation), log.JsonObj("Authorization", token), log.String("requestBody", string(postBytes)))
+	trace.Inject(ctx, req.Header)
 	resp, respErr := http.DefaultClient.Do(req)
 	if respErr != nil {
 		err = fmt.Errorf("do request fail,%s ", respErr.Error())
@@ -508,6 +513,7 @@ func UpdatePluginModelData(ctx context.Context, packageName, entity, token, oper
 	}
 	startTime := time.Now()
 	log.Logger.Info("Start remote modelData update --->>> ", log.String("requestId", reqId), log.String("transactionId", transId), log.String("method", http.MethodPost), log.String("url", urlObj.String()), log.JsonObj("Authorization", token), log.String("requestBody", string(postBytes)))
+	trace.Inject(ctx, req.Header)
 	resp, respErr := http.DefaultClient.Do(req)
 	if respErr != nil {
 		err = fmt.Errorf("do request fail,%s ", respErr.Error())
@@ -590,6 +596,7 @@ func DangerousBatchCheck(ctx context.Context, token string, reqParam interface{}
 	req.Header.Set("Content-type", "application/json")
 	startTime := time.Now()
 	log.Logger.Info("Start remote dangerousBatchCheck request --->>> ", log.String("requestId", reqId), log.String("transactionId", transId), log.String("method", http.MethodPost), log.String("url", urlObj.String()), log.JsonObj("Authorization", token), log.String("requestBody", string(reqBody)))
+	trace.Inject(ctx, req.Header)
 	resp, respErr := http.DefaultClient.Do(req)
 	if respErr != nil {
 		err = fmt.Errorf("do request fail,%s ", respErr.Error())
@@ -653,6 +660,7 @@ func DangerousWorkflowCheck(ctx context.Context, token string, reqParam interfac
 	req.Header.Set("Content-type", "application/json")
 	startTime := time.Now()
 	log.Logger.Info("Start remote dangerousWorkflowCheck request --->>> ", log.String("requestId", reqId), log.String("transactionId", transId), log.String("method", http.MethodPost), log.String("url", urlObj.String()), log.JsonObj("Authorization", token), log.String("requestBody", string(reqBody)))
+	trace.Inject(ctx, req.Header)
 	resp, respErr := http.DefaultClient.Do(req)
 	if respErr != nil {
 		err = fmt.Errorf("do request fail,%s ", respErr.Error())
@@ -717,7 +725,19 @@ func PluginInterfaceApi(ctx context.Context, token string, pluginInterface *mode
 	req.Header.Set(models.TransactionIdHeader, transId)
 	req.Header.Set(models.AuthorizationHeader, token)
 	req.Header.Set("Content-type", "application/json")
+	ctx, span := trace.StartSpan(ctx, "plugin "+pluginInterface.ServiceName, trace.SpanKindClient)
+	span.SetAttribute("plugin.service", pluginInterface.ServiceName)
+	span.SetAttribute("http.url", urlObj.String())
+	trace.Inject(ctx, req.Header)
 	startTime := time.Now()
+	defer func() {
+		metric.PluginCallDuration.Observe(time.Since(startTime).Seconds(), pluginInterface.ServiceName)
+		if err != nil {
+			metric.PluginCallErrors.Inc(pluginInterface.ServiceName, errCode)
+			span.SetAttribute("plugin.error_code", errCode)
+		}
+		span.End(err)
+	}()
 	log.Logger.Info("Start remote pluginInterfaceApi request --->>> ", log.String("requestId", reqId), log.String("transactionId", transId), log.String("method", http.MethodPost), log.String("url", urlObj.String()), log.JsonObj("Authorization", token), log.String("requestBody", string(reqBodyPtr)))
 	resp, respErr := http.DefaultClient.Do(req)
 	if respErr != nil {
@@ -0,0 +1,50 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/execution"
+)
+
+// doApprovalJob 审批节点,每次收到投票通知后按已有投票计算结果,通过走普通出线,驳回走驳回分支
+func (n *WorkNode) doApprovalJob(ctx context.Context) (output string, err error) {
+	log.Logger.Info("do approval job", log.String("nodeId", n.Id))
+	for {
+		// 恢复时可能已经投完票,先按已有投票计算一次
+		result, checkErr := execution.CheckWorkflowApproval(ctx, n.workflow.ProcInsId, n.ProcInsNodeId)
+		if checkErr != nil {
+			err = checkErr
+			return
+		}
+		if result == models.ApprovalResultApproved {
+			output = result
+			return
+		}
+		if result == models.ApprovalResultRejected {
+			if !n.hasRejectLink() {
+				err = fmt.Errorf("approval rejected and node without reject link")
+				return
+			}
+			output = result
+			return
+		}
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("approval node canceled")
+			return
+		case <-n.callbackChan:
+		}
+	}
+}
+
+func (n *WorkNode) hasRejectLink() bool {
+	for _, ref := range n.workflow.Links {
+		if ref.Source == n.Id && ref.LinkType == models.ProcDefLinkTypeReject {
+			return true
+		}
+	}
+	return false
+}
@@ -11,6 +11,7 @@ import (
 
 func StartCronJob() {
 	instanceHost = models.Config.HostIp
+	initOperationNotifier()
 	go startScanOperationJob()
 	go loadAllWorkflow()
 	go startTakeOverJob()
@@ -19,8 +20,9 @@ func StartCronJob() {
 
 // 当前自身内存中有运行工作流的情况下，没有就跳过
 // 每2s扫描工作流操作表看工作流是否有新的指示，条件是 where status=wait and workflow_id in (工作流id列表)，组合索引(status+workflow_id)
+// 开启操作通知后新操作由插入方直接通知过来，扫描降为低频兜底
 func startScanOperationJob() {
-	t := time.NewTicker(2 * time.Second).C
+	t := time.NewTicker(getOperationScanInterval()).C
 	for {
 		<-t
 		doScanOperationJob()
@@ -51,6 +53,19 @@ func doScanOperationJob() {
 	}
 }
 
+func getWaitOperationRow(operationId int64) (operation *models.ProcRunOperation, err error) {
+	var operationRows []*models.ProcRunOperation
+	err = db.MysqlEngine.SQL("select id,workflow_id,node_id,operation,message,created_by,created_time from proc_run_operation where id=? and status='wait'", operationId).Find(&operationRows)
+	if err != nil {
+		err = fmt.Errorf("query proc operation fail,%s ", err.Error())
+		return
+	}
+	if len(operationRows) > 0 {
+		operation = operationRows[0]
+	}
+	return
+}
+
 func HandleProOperation(operation *models.ProcRunOperation) {
 	// 尝试抢占
 	execResult, err := db.MysqlEngine.Exec("update proc_run_operation set status='doing',handle_by=?,start_time=? where id=? and status='wait'", instanceHost, time.Now(), operation.Id)
@@ -63,8 +78,11 @@ func HandleProOperation(operation *models.ProcRunOperation) {
 		return
 	}
 	doneFlag := false
+	failMessage := ""
 	defer func() {
-		if doneFlag {
+		if failMessage != "" {
+			_, err = db.MysqlEngine.Exec("update proc_run_operation set status='fail',message=?,end_time=? where id=?", failMessage, time.Now(), operation.Id)
+		} else if doneFlag {
 			_, err = db.MysqlEngine.Exec("update proc_run_operation set status='done',end_time=? where id=?", time.Now(), operation.Id)
 		} else {
 			_, err = db.MysqlEngine.Exec("update proc_run_operation set status='wait' where id=?", operation.Id)
@@ -73,6 +91,17 @@ func HandleProOperation(operation *models.ProcRunOperation) {
 			log.Logger.Error("handle operation update operation status fail", log.Bool("done", doneFlag), log.String("host", instanceHost), log.Int64("operation", operation.Id), log.Error(err))
 		}
 	}()
+	if operation.Operation == workflowOperationReplay {
+		if replayErr := ReplayWorkflow(operation); replayErr != nil {
+			log.Logger.Error("handle replay operation fail", log.String("workflowId", operation.WorkflowId), log.String("nodeId", operation.NodeId), log.Error(replayErr))
+			failMessage = replayErr.Error()
+			if operation.Message != "" {
+				failMessage = operation.Message + "," + failMessage
+			}
+		}
+		doneFlag = true
+		return
+	}
 	if workIf, ok := GlobalWorkflowMap.Load(operation.WorkflowId); ok {
 		workObj := workIf.(*Workflow)
 		doWorkflowOperation(operation, workObj)
@@ -115,6 +144,7 @@ func doWorkflowOperation(operation *models.ProcRunOperation, workObj *Workflow)
 	opObj := models.ProcOperation{Ctx: context.Background(), WorkflowId: operation.WorkflowId, Message: operation.Message, CreatedBy: operation.CreatedBy}
 	switch operation.Operation {
 	case "kill":
+		killSubProcWorkflows(workObj.ProcInsId, "")
 		workObj.Kill(&opObj)
 	case "retry":
 		workObj.RetryNode(operation.NodeId)
@@ -238,7 +268,7 @@ func getWorkflowData(ctx context.Context, workflowId string) (workflowRow *model
 		err = fmt.Errorf("query workflow node table fail,%s ", err.Error())
 		return
 	}
-	err = db.MysqlEngine.Context(ctx).SQL("select * from proc_run_link where workflow_id=?", workflowId).Find(&linkList)
+	err = db.MysqlEngine.Context(ctx).SQL("select l.* from proc_run_link l left join proc_def_node_link d on l.proc_def_link_id=d.id where l.workflow_id=? order by d.id", workflowId).Find(&linkList)
 	if err != nil {
 		err = fmt.Errorf("query workflow link table fail,%s ", err.Error())
 		return
@@ -306,10 +336,10 @@ func isWorkflowNeedSleep(ctx context.Context, workflowRow *models.ProcRunWorkflo
 		ok = true
 		return
 	}
-	// 正在运行的节点是人工节点
+	// 正在运行的节点是人工节点或审批节点
 	allHumanTypeFlag := true
 	for _, v := range currentNodes {
-		if v.JobType != models.JobHumanType {
+		if v.JobType != models.JobHumanType && v.JobType != models.JobApprovalType {
 			allHumanTypeFlag = false
 			break
 		}
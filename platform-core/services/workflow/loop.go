@@ -0,0 +1,156 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/tools"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/execution"
+)
+
+// doLoopJob 循环节点,每轮触发循环体执行,循环体经回线回到循环节点后计算退出条件,满足则结束,否则归档本轮结果并重置循环体进入下一轮
+func (n *WorkNode) doLoopJob(ctx context.Context, recoverFlag bool) (output string, err error) {
+	loopConfig, getConfigErr := execution.GetWorkflowNodeLoopConfig(ctx, n.Id)
+	if getConfigErr != nil {
+		err = getConfigErr
+		return
+	}
+	bodyLink, backLink := n.getLoopLinks()
+	if bodyLink == nil || backLink == nil {
+		err = fmt.Errorf("loop node:%s without body link or loop back link", n.Name)
+		return
+	}
+	iteration := 1
+	if recoverFlag && n.TmpData != "" {
+		// 恢复时从上次所在轮次继续
+		if lastIteration, parseErr := strconv.Atoi(n.TmpData); parseErr == nil && lastIteration > 0 {
+			iteration = lastIteration
+		}
+	}
+	bodyNodes := n.getLoopBodyNodes(bodyLink.Target)
+	for {
+		n.TmpData = strconv.Itoa(iteration)
+		updateNodeTmpData(&n.ProcRunNode)
+		log.Logger.Info("loop node start iteration", log.String("nodeId", n.Id), log.Int("iteration", iteration))
+		n.workflow.startLinkTarget(bodyLink, n)
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("loop node canceled in iteration %d", iteration)
+			return
+		case <-n.StartChan:
+		}
+		if n.workflow.getStatus() == models.JobStatusKill {
+			err = fmt.Errorf("workflow killed in loop iteration %d", iteration)
+			return
+		}
+		vars, buildErr := execution.BuildDecisionConditionVars(ctx, n.workflow.ProcInsId, backLink.Source, getNodeOutputData(backLink.Source))
+		if buildErr != nil {
+			err = fmt.Errorf("build loop exit condition vars fail,%s", buildErr.Error())
+			return
+		}
+		vars["loop"] = map[string]interface{}{"iteration": iteration}
+		exitFlag, evalErr := tools.EvalConditionExpr(loopConfig.ExitCondition, vars)
+		if evalErr != nil {
+			err = fmt.Errorf("loop exit condition %s eval fail,%s", loopConfig.ExitCondition, evalErr.Error())
+			return
+		}
+		if exitFlag {
+			log.Logger.Info("loop node exit condition match", log.String("nodeId", n.Id), log.Int("iteration", iteration))
+			output = strconv.Itoa(iteration)
+			return
+		}
+		if iteration >= loopConfig.MaxIterations {
+			err = fmt.Errorf("loop node reach max iterations %d and exit condition not match", loopConfig.MaxIterations)
+			return
+		}
+		if err = n.resetLoopBody(bodyNodes, iteration); err != nil {
+			return
+		}
+		iteration = iteration + 1
+	}
+}
+
+// getLoopLinks 循环节点进入循环体的线和循环体回到循环节点的回线
+func (n *WorkNode) getLoopLinks() (bodyLink, backLink *models.ProcRunLink) {
+	for _, ref := range n.workflow.Links {
+		if ref.Source == n.Id && ref.LinkType == "" {
+			bodyLink = ref
+		}
+		if ref.Target == n.Id && ref.LinkType == models.ProcDefLinkTypeLoopBack {
+			backLink = ref
+		}
+	}
+	return
+}
+
+// getLoopBodyNodes 从循环体入口出发到循环节点为止经过的节点
+func (n *WorkNode) getLoopBodyNodes(entryNodeId string) (bodyNodes []*WorkNode) {
+	existMap := map[string]bool{n.Id: true, entryNodeId: true}
+	bodyNodeIds := []string{entryNodeId}
+	for i := 0; i < len(bodyNodeIds); i++ {
+		for _, ref := range n.workflow.Links {
+			if ref.Source == bodyNodeIds[i] && !existMap[ref.Target] {
+				existMap[ref.Target] = true
+				bodyNodeIds = append(bodyNodeIds, ref.Target)
+			}
+		}
+	}
+	for _, nodeId := range bodyNodeIds {
+		for _, node := range n.workflow.Nodes {
+			if node.Id == nodeId {
+				bodyNodes = append(bodyNodes, node)
+				break
+			}
+		}
+	}
+	return
+}
+
+// resetLoopBody 归档循环体本轮执行过的节点结果,并重置为未开始等待下一轮
+func (n *WorkNode) resetLoopBody(bodyNodes []*WorkNode, iteration int) (err error) {
+	nowTime := time.Now()
+	var actions []*db.ExecAction
+	var resetNodes []*WorkNode
+	for _, node := range bodyNodes {
+		// 未执行的分支节点还在等待开始信号,不用重置
+		if node.getStatus() == models.JobStatusReady {
+			continue
+		}
+		resetNodes = append(resetNodes, node)
+		actions = append(actions, &db.ExecAction{Sql: "insert into proc_run_node_iteration(loop_node_id,iteration,proc_run_node_id,proc_ins_node_id,status,input,`output`,error_message,start_time,end_time,created_time) select ?,?,id,proc_ins_node_id,status,input,`output`,error_message,start_time,end_time,? from proc_run_node where id=?", Param: []interface{}{n.Id, iteration, nowTime, node.Id}})
+		actions = append(actions, &db.ExecAction{Sql: "update proc_run_node set status=?,`output`=null,tmp_data=null,error_message=null,decision_link_id=null,start_time=null,end_time=null,updated_time=? where id=?", Param: []interface{}{models.JobStatusReady, nowTime, node.Id}})
+		actions = append(actions, &db.ExecAction{Sql: "update proc_ins_node set status=?,error_msg=null,updated_time=? where id=?", Param: []interface{}{models.JobStatusReady, nowTime, node.ProcInsNodeId}})
+	}
+	if err = db.Transaction(actions, context.Background()); err != nil {
+		err = fmt.Errorf("archive loop iteration %d fail,%s ", iteration, err.Error())
+		return
+	}
+	for _, node := range resetNodes {
+		node.statusLock.Lock()
+		node.Status = models.JobStatusReady
+		if node.JobType == models.JobDecisionType {
+			// 判断节点的输入是上一轮的选择结果,其它节点的输入是定义时的配置要保留
+			node.Input = ""
+		}
+		node.Output = ""
+		node.TmpData = ""
+		node.ErrorMessage = ""
+		node.Err = nil
+		node.StartTime = time.Time{}
+		node.DecisionLinkId = ""
+		node.statusLock.Unlock()
+		go node.Ready()
+	}
+	return
+}
+
+func updateNodeTmpData(n *models.ProcRunNode) {
+	if _, err := db.MysqlEngine.Exec("update proc_run_node set tmp_data=?,updated_time=? where id=?", n.TmpData, time.Now(), n.Id); err != nil {
+		log.Logger.Error("update node tmp data fail", log.String("nodeId", n.Id), log.Error(err))
+	}
+}
@@ -0,0 +1,16 @@
+package workflow
+
+import (
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/metric"
+)
+
+func init() {
+	metric.RegisterGaugeFunc("platform_core_workflow_running", "Running workflow count in current instance.", nil, func() []*metric.GaugeSample {
+		count := 0
+		GlobalWorkflowMap.Range(func(key, value any) bool {
+			count = count + 1
+			return true
+		})
+		return []*metric.GaugeSample{{Value: float64(count)}}
+	})
+}
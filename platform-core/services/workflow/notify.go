@@ -0,0 +1,101 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/network"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+	"github.com/WeBankPartners/wecube-platform/platform-core/services/remote"
+)
+
+const (
+	OperationNotifyTypeHttp = "http"
+
+	pathNotifyOperation = "%s/v1/process/operations/%d/notify"
+)
+
+// OperationNotifier 工作流操作通知,新增操作后直接通知工作流所在的实例处理,轮询扫描只作为兜底
+type OperationNotifier interface {
+	Notify(host string, operation *models.ProcRunOperation) error
+}
+
+var operationNotifier OperationNotifier
+
+// RegisterOperationNotifier 注册操作通知方式,为空时只依赖轮询扫描
+func RegisterOperationNotifier(notifier OperationNotifier) {
+	operationNotifier = notifier
+}
+
+// httpOperationNotifier 通过内部http接口通知工作流所在实例
+type httpOperationNotifier struct {
+	port string
+}
+
+func (h *httpOperationNotifier) Notify(host string, operation *models.ProcRunOperation) error {
+	url := fmt.Sprintf("http://%s:%s"+pathNotifyOperation, host, h.port, models.UrlPrefix, operation.Id)
+	return network.HttpPostCommon(context.Background(), url, remote.GetToken(), "en", []byte("{}"))
+}
+
+func initOperationNotifier() {
+	workflowConfig := models.Config.Workflow
+	if workflowConfig == nil || workflowConfig.OperationNotifyType != OperationNotifyTypeHttp {
+		return
+	}
+	notifyPort := workflowConfig.OperationNotifyPort
+	if notifyPort == "" {
+		notifyPort = models.Config.HttpServer.Port
+	}
+	RegisterOperationNotifier(&httpOperationNotifier{port: notifyPort})
+}
+
+// getOperationScanInterval 开启操作通知后轮询扫描降级为低频兜底
+func getOperationScanInterval() time.Duration {
+	if workflowConfig := models.Config.Workflow; workflowConfig != nil && workflowConfig.OperationScanSeconds > 0 {
+		return time.Duration(workflowConfig.OperationScanSeconds) * time.Second
+	}
+	if operationNotifier != nil {
+		return 30 * time.Second
+	}
+	return 2 * time.Second
+}
+
+// NotifyWorkflowOperation 工作流在本实例或休眠时直接处理,在其它实例运行时通知对应实例处理,通知失败的等轮询兜底
+// 重放针对已结束的工作流,任意实例都可以直接处理
+func NotifyWorkflowOperation(operation *models.ProcRunOperation) {
+	if _, ok := GlobalWorkflowMap.Load(operation.WorkflowId); ok || operationNotifier == nil || operation.Operation == workflowOperationReplay {
+		HandleProOperation(operation)
+		return
+	}
+	workflowRow, err := getWorkflowRow(operation.WorkflowId)
+	if err != nil {
+		log.Logger.Error("notify workflow operation fail with get workflow row", log.Int64("operation", operation.Id), log.Error(err))
+		return
+	}
+	if workflowRow.Sleep || workflowRow.Host == "" || workflowRow.Host == instanceHost {
+		HandleProOperation(operation)
+		return
+	}
+	if err = operationNotifier.Notify(workflowRow.Host, operation); err != nil {
+		log.Logger.Warn("notify workflow operation to host fail,wait for scan job", log.String("host", workflowRow.Host), log.Int64("operation", operation.Id), log.Error(err))
+		return
+	}
+	log.Logger.Debug("notify workflow operation to host", log.String("host", workflowRow.Host), log.Int64("operation", operation.Id))
+}
+
+// HandleNotifyOperation 收到其它实例的操作通知后处理
+func HandleNotifyOperation(operationId int64) (err error) {
+	operation, getErr := getWaitOperationRow(operationId)
+	if getErr != nil {
+		err = getErr
+		return
+	}
+	if operation == nil {
+		log.Logger.Warn("handle notify operation,operation already handled", log.Int64("operation", operationId))
+		return
+	}
+	go HandleProOperation(operation)
+	return
+}
@@ -0,0 +1,169 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/models"
+)
+
+const (
+	workflowOperationReplay = "replay"
+)
+
+// isReplayableNode 可以作为重放起点的节点类型,只有这些节点开始执行前保存数据缓存快照
+func isReplayableNode(jobType string) bool {
+	return jobType == models.JobAutoType || jobType == models.JobDataType || jobType == models.JobHumanType || jobType == models.JobSubProcType
+}
+
+// saveNodeCheckpoint 任务节点第一次开始执行前保存编排数据缓存快照,从该节点重放时恢复到这份快照
+func saveNodeCheckpoint(n *WorkNode) {
+	var cacheRows []*models.ProcDataCache
+	err := db.MysqlEngine.SQL("select * from proc_data_cache where proc_ins_id=?", n.workflow.ProcInsId).Find(&cacheRows)
+	if err != nil {
+		log.Logger.Error("save node checkpoint fail with query proc data cache", log.String("nodeId", n.Id), log.Error(err))
+		return
+	}
+	cacheBytes, _ := json.Marshal(cacheRows)
+	if _, err = db.MysqlEngine.Exec("replace into proc_run_checkpoint(proc_run_node_id,workflow_id,data_cache,created_time) values (?,?,?,?)", n.Id, n.WorkflowId, string(cacheBytes), time.Now()); err != nil {
+		log.Logger.Error("save node checkpoint fail", log.String("nodeId", n.Id), log.Error(err))
+	}
+}
+
+// ReplayWorkflow 已结束的工作流从指定节点开始重放,重置该节点及下游节点状态,恢复数据缓存快照后重新加载工作流
+func ReplayWorkflow(operation *models.ProcRunOperation) (err error) {
+	ctx := context.WithValue(context.Background(), models.TransactionIdHeader, fmt.Sprintf("replay_%s_%d", operation.WorkflowId, time.Now().Unix()))
+	if _, ok := GlobalWorkflowMap.Load(operation.WorkflowId); ok {
+		err = fmt.Errorf("workflow:%s is running,can not replay", operation.WorkflowId)
+		return
+	}
+	workflowRow, nodeList, linkList, getErr := getWorkflowData(ctx, operation.WorkflowId)
+	if getErr != nil {
+		err = getErr
+		return
+	}
+	if workflowRow.Status != models.JobStatusSuccess && workflowRow.Status != models.JobStatusFail && workflowRow.Status != models.JobStatusKill {
+		err = fmt.Errorf("workflow status:%s illegal,only finished workflow can replay", workflowRow.Status)
+		return
+	}
+	replayNodeIds := getDownstreamNodeIds(operation.NodeId, linkList)
+	nodeMap := make(map[string]*models.ProcRunNode)
+	for _, node := range nodeList {
+		nodeMap[node.Id] = node
+	}
+	if _, ok := nodeMap[operation.NodeId]; !ok {
+		err = fmt.Errorf("can not find node:%s in workflow:%s", operation.NodeId, operation.WorkflowId)
+		return
+	}
+	if !isReplayableNode(nodeMap[operation.NodeId].JobType) {
+		err = fmt.Errorf("node:%s type:%s can not replay", operation.NodeId, nodeMap[operation.NodeId].JobType)
+		return
+	}
+	nowTime := time.Now()
+	var actions []*db.ExecAction
+	for _, nodeId := range replayNodeIds {
+		node := nodeMap[nodeId]
+		if node.JobType == models.JobDecisionType {
+			// 判断节点的输入是上次的选择结果,其它节点的输入是定义时的配置要保留
+			actions = append(actions, &db.ExecAction{Sql: "update proc_run_node set input=null,decision_link_id=null where id=?", Param: []interface{}{node.Id}})
+		}
+		actions = append(actions, &db.ExecAction{Sql: "update proc_run_node set status=?,`output`=null,tmp_data=null,error_message=null,start_time=null,end_time=null,updated_time=? where id=?", Param: []interface{}{models.JobStatusReady, nowTime, node.Id}})
+		actions = append(actions, &db.ExecAction{Sql: "update proc_ins_node set status=?,error_msg=null,updated_time=? where id=?", Param: []interface{}{models.JobStatusReady, nowTime, node.ProcInsNodeId}})
+	}
+	cacheActions, restoreFlag, restoreErr := buildRestoreCheckpointActions(workflowRow.ProcInsId, operation.NodeId)
+	if restoreErr != nil {
+		err = restoreErr
+		return
+	}
+	actions = append(actions, cacheActions...)
+	replayMessage := fmt.Sprintf("replay from node:%s(%s),reset %d nodes,restore data cache:%t", nodeMap[operation.NodeId].Name, operation.NodeId, len(replayNodeIds), restoreFlag)
+	if operation.Message != "" {
+		replayMessage = replayMessage + "," + operation.Message
+	}
+	actions = append(actions, &db.ExecAction{Sql: "update proc_run_workflow set status=?,error_message=null,stop=0,sleep=0,host=?,last_alive_time=?,updated_time=? where id=?", Param: []interface{}{models.JobStatusRunning, instanceHost, nowTime, nowTime, workflowRow.Id}})
+	actions = append(actions, &db.ExecAction{Sql: "update proc_ins set status=?,updated_time=? where id=?", Param: []interface{}{models.JobStatusRunning, nowTime, workflowRow.ProcInsId}})
+	actions = append(actions, &db.ExecAction{Sql: "insert into proc_run_work_record(workflow_id,host,`action`,message,created_by,created_time) values (?,?,?,?,?,?)", Param: []interface{}{workflowRow.Id, instanceHost, workflowOperationReplay, replayMessage, operation.CreatedBy, nowTime}})
+	if err = db.Transaction(actions, ctx); err != nil {
+		err = fmt.Errorf("reset workflow replay nodes fail,%s ", err.Error())
+		return
+	}
+	log.Logger.Info("replay workflow", log.String("workflowId", workflowRow.Id), log.String("message", replayMessage))
+	if err = recoverWorkflow(workflowRow.Id); err != nil {
+		// 节点已重置但工作流没加载起来,置为失败避免实例一直显示运行中
+		failMessage := fmt.Sprintf("replay recover workflow fail,%s", err.Error())
+		var failActions []*db.ExecAction
+		failActions = append(failActions, &db.ExecAction{Sql: "update proc_run_workflow set status=?,error_message=?,updated_time=? where id=?", Param: []interface{}{models.JobStatusFail, failMessage, time.Now(), workflowRow.Id}})
+		failActions = append(failActions, &db.ExecAction{Sql: "update proc_ins set status=?,updated_time=? where id=?", Param: []interface{}{models.JobStatusFail, time.Now(), workflowRow.ProcInsId}})
+		if failErr := db.Transaction(failActions, ctx); failErr != nil {
+			log.Logger.Error("replay workflow update fail status error", log.String("workflowId", workflowRow.Id), log.Error(failErr))
+		}
+	}
+	return
+}
+
+// getDownstreamNodeIds 查找节点及其所有下游节点
+func getDownstreamNodeIds(nodeId string, linkList []*models.ProcRunLink) (nodeIds []string) {
+	existMap := map[string]bool{nodeId: true}
+	nodeIds = []string{nodeId}
+	for i := 0; i < len(nodeIds); i++ {
+		for _, link := range linkList {
+			if link.Source == nodeIds[i] && !existMap[link.Target] {
+				existMap[link.Target] = true
+				nodeIds = append(nodeIds, link.Target)
+			}
+		}
+	}
+	return
+}
+
+// buildRestoreCheckpointActions 快照之后写过的编排数据缓存恢复成快照内容(快照中没有的删除),快照之后没写过的数据不动,没有快照的历史数据不恢复
+func buildRestoreCheckpointActions(procInsId, procRunNodeId string) (actions []*db.ExecAction, restoreFlag bool, err error) {
+	queryRows, queryErr := db.MysqlEngine.QueryString("select data_cache from proc_run_checkpoint where proc_run_node_id=?", procRunNodeId)
+	if queryErr != nil {
+		err = fmt.Errorf("query node checkpoint fail,%s ", queryErr.Error())
+		return
+	}
+	if len(queryRows) == 0 {
+		log.Logger.Warn("replay workflow without node checkpoint,keep current data cache", log.String("nodeId", procRunNodeId))
+		return
+	}
+	var cacheRows []*models.ProcDataCache
+	if err = json.Unmarshal([]byte(queryRows[0]["data_cache"]), &cacheRows); err != nil {
+		err = fmt.Errorf("json unmarshal node checkpoint fail,%s ", err.Error())
+		return
+	}
+	var existIds, writtenIds []string
+	if err = db.MysqlEngine.SQL("select id from proc_data_cache where proc_ins_id=?", procInsId).Find(&existIds); err != nil {
+		err = fmt.Errorf("query proc data cache fail,%s ", err.Error())
+		return
+	}
+	if err = db.MysqlEngine.SQL("select t1.id from proc_data_cache t1 join proc_run_checkpoint t2 on t2.proc_run_node_id=? where t1.proc_ins_id=? and (t1.created_time>=t2.created_time or t1.updated_time>=t2.created_time)", procRunNodeId, procInsId).Find(&writtenIds); err != nil {
+		err = fmt.Errorf("query proc data cache written after checkpoint fail,%s ", err.Error())
+		return
+	}
+	existMap := make(map[string]bool)
+	for _, id := range existIds {
+		existMap[id] = true
+	}
+	restoreMap := make(map[string]bool)
+	for _, id := range writtenIds {
+		actions = append(actions, &db.ExecAction{Sql: "delete from proc_data_cache where id=?", Param: []interface{}{id}})
+		restoreMap[id] = true
+	}
+	nowTime := time.Now()
+	for _, row := range cacheRows {
+		// 快照后被改写或被删除的数据才恢复
+		if existMap[row.Id] && !restoreMap[row.Id] {
+			continue
+		}
+		actions = append(actions, &db.ExecAction{Sql: "insert into proc_data_cache(id,proc_ins_id,entity_id,entity_data_id,entity_data_name,entity_type_id,full_data_id,data_value,prev_ids,succ_ids,created_time,updated_time) values (?,?,?,?,?,?,?,?,?,?,?,?)", Param: []interface{}{
+			row.Id, procInsId, row.EntityId, row.EntityDataId, row.EntityDataName, row.EntityTypeId, row.FullDataId, row.DataValue, row.PrevIds, row.SuccIds, row.CreatedTime, nowTime,
+		}})
+	}
+	restoreFlag = true
+	return
+}
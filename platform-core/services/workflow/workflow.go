@@ -7,6 +7,9 @@ import (
 	"fmt"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/db"
 	"github.com/WeBankPartners/wecube-platform/platform-core/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/metric"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/tools"
+	"github.com/WeBankPartners/wecube-platform/platform-core/common/trace"
 	"github.com/WeBankPartners/wecube-platform/platform-core/models"
 	"github.com/WeBankPartners/wecube-platform/platform-core/services/execution"
 
@@ -103,12 +106,17 @@ func (w *Workflow) nodeDoneCallback(node *WorkNode) {
 	decisionChose := ""
 	if node.JobType == models.JobDecisionType {
 		decisionChose = node.Input
-		log.Logger.Info("decision node receive choose", log.String(decisionChose, "decisionChose"))
+		log.Logger.Info("decision node receive choose", log.String(decisionChose, "decisionChose"), log.String("decisionLink", node.DecisionLinkId))
 	}
+	var boundaryLink *models.ProcRunLink
 	if node.Err != nil {
-		w.updateErrorList(true, "", &models.WorkProblemErrObj{NodeId: node.Id, NodeName: node.Name, ErrMessage: node.Err.Error()})
-		//w.setStatus(models.JobStatusFail, &models.ProcOperation{NodeErr: &models.WorkProblemErrObj{NodeId: node.Id, NodeName: node.Name, ErrMessage: node.Err.Error()}})
-		return
+		// 节点配置了超时或异常分支时走对应分支,不再停在失败状态
+		if boundaryLink = w.getBoundaryLink(node); boundaryLink == nil {
+			w.updateErrorList(true, "", &models.WorkProblemErrObj{NodeId: node.Id, NodeName: node.Name, ErrMessage: node.Err.Error()})
+			//w.setStatus(models.JobStatusFail, &models.ProcOperation{NodeErr: &models.WorkProblemErrObj{NodeId: node.Id, NodeName: node.Name, ErrMessage: node.Err.Error()}})
+			return
+		}
+		log.Logger.Info("node fail and route to boundary link", log.String("nodeId", node.Id), log.String("linkType", boundaryLink.LinkType), log.String("error", node.Err.Error()))
 	}
 	// stop 的时候要处理普通节点等待
 	curStatus := w.getStatus()
@@ -120,23 +128,65 @@ func (w *Workflow) nodeDoneCallback(node *WorkNode) {
 		w.stopNodeChanList = append(w.stopNodeChanList, waitStopChan)
 		<-waitStopChan
 	}
+	if boundaryLink != nil {
+		w.startLinkTarget(boundaryLink, node)
+		return
+	}
 	// 找到节点下一跳发出start信号
 	for _, ref := range w.Links {
-		if decisionChose != "" {
+		if !isRouteLink(&node.ProcRunNode, ref) {
+			continue
+		}
+		if node.DecisionLinkId != "" {
+			if ref.Id != node.DecisionLinkId {
+				continue
+			}
+		} else if decisionChose != "" {
 			if ref.Name != decisionChose {
 				continue
 			}
 		}
 		if ref.Source == node.Id {
-			for _, targetNode := range w.Nodes {
-				if targetNode.Id == ref.Target {
-					if targetNode.JobType == models.JobDecisionType {
-						targetNode.Input = node.Output
-					}
-					targetNode.StartChan <- 1
-					break
-				}
+			w.startLinkTarget(ref, node)
+		}
+	}
+}
+
+// isRouteLink 节点正常结束后要走的线,循环节点只走循环结束出线(循环体入线由循环节点自己触发),审批节点驳回时只走驳回分支,其它节点走普通线和循环回线
+func isRouteLink(node *models.ProcRunNode, ref *models.ProcRunLink) bool {
+	if node.JobType == models.JobLoopType {
+		return ref.LinkType == models.ProcDefLinkTypeLoopExit
+	}
+	if node.JobType == models.JobApprovalType && node.Output == models.ApprovalResultRejected {
+		return ref.LinkType == models.ProcDefLinkTypeReject
+	}
+	return ref.LinkType == "" || ref.LinkType == models.ProcDefLinkTypeLoopBack
+}
+
+// getBoundaryLink 节点失败时查找边界分支,超时优先走超时分支,其它失败走异常分支
+func (w *Workflow) getBoundaryLink(node *WorkNode) (boundaryLink *models.ProcRunLink) {
+	for _, ref := range w.Links {
+		if ref.Source != node.Id {
+			continue
+		}
+		if ref.LinkType == models.ProcDefLinkTypeTimeout && node.getStatus() == models.JobStatusTimeout {
+			return ref
+		}
+		if ref.LinkType == models.ProcDefLinkTypeError {
+			boundaryLink = ref
+		}
+	}
+	return
+}
+
+func (w *Workflow) startLinkTarget(ref *models.ProcRunLink, node *WorkNode) {
+	for _, targetNode := range w.Nodes {
+		if targetNode.Id == ref.Target {
+			if targetNode.JobType == models.JobDecisionType {
+				targetNode.Input = node.Output
 			}
+			targetNode.StartChan <- 1
+			break
 		}
 	}
 }
@@ -229,8 +279,10 @@ func (w *Workflow) RetryNode(nodeId string) {
 		log.Logger.Error("can not find node in workflow", log.String("node", nodeId), log.String("workflowId", w.Id))
 		return
 	}
+	nodeObj.statusLock.Lock()
 	nodeObj.Input = ""
 	nodeObj.Status = models.JobStatusRunning
+	nodeObj.statusLock.Unlock()
 	go nodeObj.Ready()
 	time.Sleep(500 * time.Millisecond)
 	nodeObj.StartTime = time.Now()
@@ -255,11 +307,13 @@ func (w *Workflow) IgnoreNode(nodeId string) {
 		log.Logger.Error("can not find node in workflow", log.String("node", nodeId), log.String("workflowId", w.Id))
 		return
 	}
+	nodeObj.statusLock.Lock()
 	nodeObj.Status = models.JobStatusSuccess
 	updateNodeDB(&nodeObj.ProcRunNode)
+	nodeObj.statusLock.Unlock()
 	w.updateErrorList(false, nodeId, nil)
 	for _, ref := range w.Links {
-		if ref.Source == nodeId {
+		if ref.Source == nodeId && isRouteLink(&nodeObj.ProcRunNode, ref) {
 			for _, targetNode := range w.Nodes {
 				if targetNode.Id == ref.Target {
 					targetNode.StartChan <- 1
@@ -314,6 +368,9 @@ type WorkNode struct {
 	DoneChan     chan int
 	Err          error
 	callbackChan chan string
+	statusLock   *sync.Mutex
+	runSeq       int  // 第几次执行,超时后还没退出的上一次执行不能再改状态
+	finished     bool // 本次执行是否已结束,执行完成和超时先到的生效
 }
 
 func (n *WorkNode) Init(w *Workflow) {
@@ -321,6 +378,7 @@ func (n *WorkNode) Init(w *Workflow) {
 	n.StartChan = make(chan int, 1)
 	n.DoneChan = make(chan int, 1)
 	n.callbackChan = make(chan string, 1)
+	n.statusLock = new(sync.Mutex)
 }
 
 func (n *WorkNode) Ready() {
@@ -335,14 +393,22 @@ func (n *WorkNode) Ready() {
 	if n.StartTime.IsZero() {
 		n.StartTime = time.Now()
 	}
-	go n.start()
+	n.statusLock.Lock()
+	n.runSeq = n.runSeq + 1
+	runSeq := n.runSeq
+	n.finished = false
+	n.statusLock.Unlock()
+	runCtx, cancel := context.WithCancel(n.Ctx)
+	defer cancel()
+	go n.start(runCtx, runSeq)
 	if n.Timeout > 0 {
 		select {
 		case <-time.After(time.Duration(n.Timeout) * time.Minute):
-			n.ErrorMessage = fmt.Sprintf(timeoutErrorTpl, n.Timeout)
-			n.Err = errors.New(n.ErrorMessage)
-			n.Status = models.JobStatusTimeout
-			updateNodeDB(&n.ProcRunNode)
+			// 超时先到时取消还在执行的任务,执行刚好同时结束的以执行结果为准
+			cancel()
+			if !n.finish(runSeq, models.JobStatusTimeout, "", fmt.Errorf(timeoutErrorTpl, n.Timeout), true) {
+				<-n.DoneChan
+			}
 		case <-n.DoneChan:
 			log.Logger.Info("<--- done node", log.String("id", n.Id), log.String("type", n.JobType))
 		}
@@ -356,38 +422,87 @@ func (n *WorkNode) Ready() {
 	n.workflow.nodeDoneCallback(n)
 }
 
-func (n *WorkNode) start() {
-	if n.Status == models.JobStatusSuccess {
-		n.DoneChan <- 1
+// finish 结束本次执行并纪录状态,执行完成和超时只有先到的生效,返回是否生效
+func (n *WorkNode) finish(runSeq int, status, output string, err error, recordFlag bool) bool {
+	n.statusLock.Lock()
+	defer n.statusLock.Unlock()
+	if n.finished || n.runSeq != runSeq {
+		return false
+	}
+	n.finished = true
+	n.Status = status
+	n.Output = output
+	n.Err = err
+	if err != nil {
+		n.ErrorMessage = err.Error()
+	}
+	if recordFlag {
+		updateNodeDB(&n.ProcRunNode)
+	}
+	return true
+}
+
+// updateRunStatus 执行过程中更新节点状态,本次执行已结束时不再更新
+func (n *WorkNode) updateRunStatus(runSeq int, status string) {
+	n.statusLock.Lock()
+	defer n.statusLock.Unlock()
+	if n.finished || n.runSeq != runSeq {
 		return
 	}
-	if n.Status == models.JobStatusFail {
-		n.Err = fmt.Errorf(n.ErrorMessage)
-		n.DoneChan <- 1
+	n.Status = status
+	updateNodeDB(&n.ProcRunNode)
+}
+
+func (n *WorkNode) getStatus() (status string) {
+	n.statusLock.Lock()
+	status = n.Status
+	n.statusLock.Unlock()
+	return
+}
+
+func (n *WorkNode) start(ctx context.Context, runSeq int) {
+	startStatus := n.getStatus()
+	if startStatus == models.JobStatusSuccess {
+		if n.finish(runSeq, models.JobStatusSuccess, n.Output, nil, false) {
+			n.DoneChan <- 1
+		}
+		return
+	}
+	if startStatus == models.JobStatusFail {
+		if n.finish(runSeq, models.JobStatusFail, n.Output, errors.New(n.ErrorMessage), false) {
+			n.DoneChan <- 1
+		}
 		return
 	}
 	retryFlag := false
-	if n.Status == models.JobStatusRunning {
+	if startStatus == models.JobStatusRunning {
 		retryFlag = true
 		if n.Timeout > 0 {
 			// 如果是恢复态，自动化和数据写入任务时间太久的情况下就置为超时，不然可能时间太长一些数据都不一样了，让用户自行重试
 			if n.JobType == models.JobAutoType || n.JobType == models.JobDataType {
 				if time.Since(n.StartTime).Minutes() > float64(n.Timeout) {
-					n.ErrorMessage = fmt.Sprintf(timeoutErrorTpl, n.Timeout)
-					n.Err = errors.New(n.ErrorMessage)
-					n.Status = models.JobStatusTimeout
-					updateNodeDB(&n.ProcRunNode)
-					n.DoneChan <- 1
+					if n.finish(runSeq, models.JobStatusTimeout, "", fmt.Errorf(timeoutErrorTpl, n.Timeout), true) {
+						n.DoneChan <- 1
+					}
 					return
 				}
 			}
 		}
 	}
 	log.Logger.Info("---> start node", log.String("id", n.Id), log.String("type", n.JobType), log.String("input", n.Input))
+	var span *trace.Span
+	ctx, span = trace.StartSpan(ctx, "workflow.node "+n.JobType, trace.SpanKindInternal)
+	span.SetAttribute("workflow.id", n.WorkflowId)
+	span.SetAttribute("workflow.node_id", n.Id)
+	span.SetAttribute("workflow.node_name", n.Name)
 	if !retryFlag {
-		n.Status = models.JobStatusRunning
-		updateNodeDB(&n.ProcRunNode)
+		n.updateRunStatus(runSeq, models.JobStatusRunning)
+		if isReplayableNode(n.JobType) {
+			saveNodeCheckpoint(n)
+		}
 	}
+	var output string
+	var jobErr error
 	switch n.JobType {
 	case models.JobStartType:
 		break
@@ -396,11 +511,11 @@ func (n *WorkNode) start() {
 	case models.JobBreakType:
 		break
 	case models.JobAutoType:
-		n.Output, n.Err = n.doAutoJob(retryFlag)
+		output, jobErr = n.doAutoJob(ctx, retryFlag)
 	case models.JobDataType:
-		n.Output, n.Err = n.doDataJob(retryFlag)
+		output, jobErr = n.doDataJob(ctx, retryFlag)
 	case models.JobHumanType:
-		n.Output, n.Err = n.doHumanJob(retryFlag)
+		output, jobErr = n.doHumanJob(ctx, retryFlag)
 	case models.JobForkType:
 		break
 	case models.JobMergeType:
@@ -412,8 +527,7 @@ func (n *WorkNode) start() {
 		}
 		if needStartCount > 1 {
 			log.Logger.Info("merge wait other signal", log.Int("wait signal num", needStartCount-1))
-			n.Status = "wait"
-			updateNodeDB(&n.ProcRunNode)
+			n.updateRunStatus(runSeq, "wait")
 			for needStartCount > 1 {
 				<-n.StartChan
 				needStartCount = needStartCount - 1
@@ -421,11 +535,19 @@ func (n *WorkNode) start() {
 			}
 		}
 	case models.JobTimeType:
-		n.Output, n.Err = n.doTimeJob(retryFlag)
+		output, jobErr = n.doTimeJob(ctx, retryFlag)
 	case models.JobDateType:
-		n.Output, n.Err = n.doDateJob(retryFlag)
+		output, jobErr = n.doDateJob(ctx, retryFlag)
+	case models.JobSubProcType:
+		output, jobErr = n.doSubProcJob(ctx, retryFlag)
+	case models.JobLoopType:
+		output, jobErr = n.doLoopJob(ctx, retryFlag)
+	case models.JobApprovalType:
+		output, jobErr = n.doApprovalJob(ctx)
 	case models.JobDecisionType:
-		if n.Input == "" {
+		if conditionLinks := n.getConditionLinks(); len(conditionLinks) > 0 {
+			output, jobErr = n.doConditionDecision(ctx, conditionLinks)
+		} else if n.Input == "" {
 			for _, tmpLink := range n.workflow.Links {
 				if tmpLink.Target == n.Id {
 					n.Input = getNodeOutputData(tmpLink.Source)
@@ -433,40 +555,147 @@ func (n *WorkNode) start() {
 				}
 			}
 			if n.Input == "" {
-				n.Err = fmt.Errorf("dicision type receive empty choose")
+				jobErr = fmt.Errorf("dicision type receive empty choose")
 			}
 		}
 	}
-	if n.Err == nil {
-		n.Status = models.JobStatusSuccess
-		updateNodeDB(&n.ProcRunNode)
-	} else {
-		n.Status = models.JobStatusFail
-		n.ErrorMessage = n.Err.Error()
-		updateNodeDB(&n.ProcRunNode)
+	status := models.JobStatusSuccess
+	if jobErr != nil {
+		status = models.JobStatusFail
+	}
+	span.SetAttribute("workflow.node_status", status)
+	span.End(jobErr)
+	if !n.finish(runSeq, status, output, jobErr, true) {
+		log.Logger.Warn("node job finish after node already end,ignore result", log.String("id", n.Id), log.String("status", status))
+		return
+	}
+	execution.AddProcInsSearchIndexTask(n.workflow.ProcInsId, n.ProcInsNodeId)
+	if !n.StartTime.IsZero() {
+		metric.WorkflowNodeDuration.Observe(time.Since(n.StartTime).Seconds(), n.JobType, status)
 	}
 	n.DoneChan <- 1
 }
 
-func (n *WorkNode) doAutoJob(retry bool) (output string, err error) {
+func (n *WorkNode) doAutoJob(ctx context.Context, retry bool) (output string, err error) {
 	log.Logger.Info("do auto job", log.String("nodeId", n.Id), log.String("input", n.Input))
-	err = execution.DoWorkflowAutoJob(n.Ctx, n.Id, "", retry)
-	if err != nil {
-		log.Logger.Error("do auto job error", log.Error(err))
+	for attempt := 1; ; attempt++ {
+		attemptStartTime := time.Now()
+		err = execution.DoWorkflowAutoJob(ctx, n.Id, "", retry)
+		if err == nil {
+			break
+		}
+		log.Logger.Error("do auto job error", log.Int("attempt", attempt), log.Error(err))
+		if !n.waitRetry(ctx, attempt, attemptStartTime, err) {
+			break
+		}
+		retry = true
 	}
 	return
 }
 
-func (n *WorkNode) doDataJob(retry bool) (output string, err error) {
+func (n *WorkNode) doDataJob(ctx context.Context, retry bool) (output string, err error) {
 	log.Logger.Info("do data job", log.String("nodeId", n.Id), log.String("input", n.Input))
-	err = execution.DoWorkflowDataJob(n.Ctx, n.Id, retry)
-	if err != nil {
-		log.Logger.Error("do data job error", log.Error(err))
+	for attempt := 1; ; attempt++ {
+		attemptStartTime := time.Now()
+		err = execution.DoWorkflowDataJob(ctx, n.Id, retry)
+		if err == nil {
+			break
+		}
+		log.Logger.Error("do data job error", log.Int("attempt", attempt), log.Error(err))
+		if !n.waitRetry(ctx, attempt, attemptStartTime, err) {
+			break
+		}
+		retry = true
 	}
 	return
 }
 
-func (n *WorkNode) doHumanJob(recoverFlag bool) (output string, err error) {
+// getConditionLinks 判断节点配置了分支条件或默认分支的出线
+func (n *WorkNode) getConditionLinks() (conditionLinks []*models.ProcRunLink) {
+	for _, ref := range n.workflow.Links {
+		if ref.Source == n.Id && ref.LinkType == "" && (ref.Condition != "" || ref.IsDefault) {
+			conditionLinks = append(conditionLinks, ref)
+		}
+	}
+	return
+}
+
+// doConditionDecision 按顺序计算分支条件,选中第一个满足条件的分支,都不满足时走默认分支
+func (n *WorkNode) doConditionDecision(ctx context.Context, conditionLinks []*models.ProcRunLink) (output string, err error) {
+	sourceRunNodeId := ""
+	for _, tmpLink := range n.workflow.Links {
+		if tmpLink.Target == n.Id && tmpLink.LinkType == "" {
+			sourceRunNodeId = tmpLink.Source
+			break
+		}
+	}
+	input := n.Input
+	if input == "" && sourceRunNodeId != "" {
+		input = getNodeOutputData(sourceRunNodeId)
+	}
+	vars, buildErr := execution.BuildDecisionConditionVars(ctx, n.workflow.ProcInsId, sourceRunNodeId, input)
+	if buildErr != nil {
+		err = fmt.Errorf("build decision condition vars fail,%s", buildErr.Error())
+		return
+	}
+	var chooseLink, defaultLink *models.ProcRunLink
+	for _, ref := range conditionLinks {
+		if ref.IsDefault {
+			defaultLink = ref
+			continue
+		}
+		matchFlag, evalErr := tools.EvalConditionExpr(ref.Condition, vars)
+		if evalErr != nil {
+			err = fmt.Errorf("decision link %s condition %s eval fail,%s", ref.Name, ref.Condition, evalErr.Error())
+			return
+		}
+		if matchFlag {
+			chooseLink = ref
+			break
+		}
+	}
+	if chooseLink == nil {
+		if defaultLink == nil {
+			err = fmt.Errorf("decision node no condition match and without default link")
+			return
+		}
+		chooseLink = defaultLink
+	}
+	log.Logger.Info("decision node condition choose", log.String("nodeId", n.Id), log.String("link", chooseLink.Name), log.String("condition", chooseLink.Condition))
+	n.DecisionLinkId = chooseLink.Id
+	n.Input = chooseLink.Name
+	output = chooseLink.Name
+	return
+}
+
+// waitRetry 按节点重试策略退避等待,返回是否需要再次执行,工作流已终止或等待会超过节点超时时间时不再重试
+func (n *WorkNode) waitRetry(ctx context.Context, attempt int, attemptStartTime time.Time, jobErr error) bool {
+	retryFlag, backoff := execution.CheckWorkflowNodeRetry(ctx, n.Id, attempt, attemptStartTime, jobErr)
+	if !retryFlag {
+		return false
+	}
+	if n.workflow.getStatus() == models.JobStatusKill {
+		log.Logger.Info("node job give up retry,workflow killed", log.String("nodeId", n.Id))
+		return false
+	}
+	if n.Timeout > 0 && time.Since(n.StartTime)+backoff >= time.Duration(n.Timeout)*time.Minute {
+		log.Logger.Info("node job give up retry,backoff exceed node deadline", log.String("nodeId", n.Id), log.Int("attempt", attempt), log.String("backoff", backoff.String()))
+		return false
+	}
+	log.Logger.Info("node job wait retry", log.String("nodeId", n.Id), log.Int("attempt", attempt), log.String("backoff", backoff.String()))
+	select {
+	case <-ctx.Done():
+		log.Logger.Info("node job retry cancel", log.String("nodeId", n.Id))
+		return false
+	case <-time.After(backoff):
+	}
+	if n.workflow.getStatus() == models.JobStatusKill {
+		return false
+	}
+	return true
+}
+
+func (n *WorkNode) doHumanJob(ctx context.Context, recoverFlag bool) (output string, err error) {
 	log.Logger.Info("do human job", log.String("nodeId", n.Id), log.String("input", n.Input))
 	// call task
 	if recoverFlag {
@@ -475,23 +704,29 @@ func (n *WorkNode) doHumanJob(recoverFlag bool) (output string, err error) {
 			recoverFlag = false
 		}
 	}
-	err = execution.DoWorkflowHumanJob(n.Ctx, n.Id, recoverFlag)
+	err = execution.DoWorkflowHumanJob(ctx, n.Id, recoverFlag)
 	if err != nil {
 		log.Logger.Error("do human job error", log.Error(err))
 		return
 	}
 	// wait callback
-	callbackMessage := <-n.callbackChan
+	var callbackMessage string
+	select {
+	case <-ctx.Done():
+		err = fmt.Errorf("human job canceled while waiting callback")
+		return
+	case callbackMessage = <-n.callbackChan:
+	}
 	var callbackData models.PluginTaskCreateResp
 	if err = json.Unmarshal([]byte(callbackMessage), &callbackData); err != nil {
 		err = fmt.Errorf("json unmarshal human job callback data fail,%s ", err.Error())
 		return
 	}
-	output, err = execution.HandleCallbackHumanJob(n.Ctx, n.Id, &callbackData)
+	output, err = execution.HandleCallbackHumanJob(ctx, n.Id, &callbackData)
 	return
 }
 
-func (n *WorkNode) doTimeJob(recoverFlag bool) (output string, err error) {
+func (n *WorkNode) doTimeJob(ctx context.Context, recoverFlag bool) (output string, err error) {
 	log.Logger.Info("do time job", log.String("nodeId", n.Id), log.String("input", n.Input))
 	var timeConfig models.TimeNodeParam
 	if err = json.Unmarshal([]byte(n.Input), &timeConfig); err != nil {
@@ -522,16 +757,25 @@ func (n *WorkNode) doTimeJob(recoverFlag bool) (output string, err error) {
 		nowSubSec := time.Since(n.StartTime).Seconds()
 		if nowSubSec > timeDuration.Seconds() {
 			log.Logger.Info("time job already start,now time match done", log.String("startTime", n.StartTime.Format(models.DateTimeFormat)), log.Float64("waitSec", timeDuration.Seconds()))
-		} else {
-			time.Sleep(time.Duration(timeDuration.Seconds()-nowSubSec) * time.Second)
+			return
 		}
-	} else {
-		time.Sleep(timeDuration)
+		timeDuration = time.Duration(timeDuration.Seconds()-nowSubSec) * time.Second
 	}
+	err = sleepWithContext(ctx, timeDuration)
 	return
 }
 
-func (n *WorkNode) doDateJob(recoverFlag bool) (output string, err error) {
+// sleepWithContext 等待指定时间,节点超时或工作流取消时提前返回
+func sleepWithContext(ctx context.Context, duration time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("node canceled while waiting")
+	case <-time.After(duration):
+	}
+	return nil
+}
+
+func (n *WorkNode) doDateJob(ctx context.Context, recoverFlag bool) (output string, err error) {
 	log.Logger.Info("do date job", log.String("nodeId", n.Id), log.String("input", n.Input), log.Bool("recover", recoverFlag))
 	var timeConfig models.TimeNodeParam
 	if err = json.Unmarshal([]byte(n.Input), &timeConfig); err != nil {
@@ -546,12 +790,75 @@ func (n *WorkNode) doDateJob(recoverFlag bool) (output string, err error) {
 	timeSub := t.Unix() - time.Now().Unix()
 	if timeSub < 0 {
 		return
-	} else {
-		time.Sleep(time.Duration(timeSub) * time.Second)
 	}
+	err = sleepWithContext(ctx, time.Duration(timeSub)*time.Second)
 	return
 }
 
+func (n *WorkNode) doSubProcJob(ctx context.Context, recoverFlag bool) (output string, err error) {
+	log.Logger.Info("do sub process job", log.String("nodeId", n.Id), log.Bool("recover", recoverFlag))
+	subProcList, createErr := execution.DoWorkflowSubProcJob(ctx, n.Id, n.StartTime)
+	if createErr != nil {
+		err = createErr
+		log.Logger.Error("do sub process job error", log.Error(err))
+		return
+	}
+	if len(subProcList) == 0 {
+		return
+	}
+	for _, subProc := range subProcList {
+		// 已存在的子编排由自身工作流恢复机制接管,这里只启动新建的
+		if subProc.WorkflowRow == nil {
+			continue
+		}
+		subWorkObj := Workflow{ProcRunWorkflow: *subProc.WorkflowRow}
+		subWorkObj.Init(trace.Detach(ctx), subProc.WorkNodes, subProc.WorkLinks)
+		go subWorkObj.Start(&models.ProcOperation{CreatedBy: "sys", Message: "start by sub process node " + n.ProcInsNodeId})
+	}
+	// wait sub process done,节点超时或工作流终止时不再等待并终止还在运行的子编排
+	t := time.NewTicker(5 * time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("sub process node cancel while waiting sub process")
+		case <-t.C:
+		}
+		if err == nil && n.workflow.getStatus() == models.JobStatusKill {
+			err = fmt.Errorf("workflow killed while waiting sub process")
+		}
+		if err == nil && n.Timeout > 0 && time.Since(n.StartTime) > time.Duration(n.Timeout)*time.Minute {
+			err = fmt.Errorf(timeoutErrorTpl, n.Timeout)
+		}
+		if err != nil {
+			killSubProcWorkflows(n.workflow.ProcInsId, n.ProcInsNodeId)
+			return
+		}
+		doneFlag, subOutput, checkErr := execution.CheckWorkflowSubProcJob(ctx, n.workflow.ProcInsId, n.ProcInsNodeId, n.StartTime)
+		if checkErr != nil {
+			err = checkErr
+			log.Logger.Error("sub process job fail", log.String("nodeId", n.Id), log.Error(err))
+			return
+		}
+		if doneFlag {
+			output = subOutput
+			break
+		}
+	}
+	return
+}
+
+// killSubProcWorkflows 终止父编排实例(或父编排节点)拉起还在运行的子编排,子编排终止时再逐级终止自己的子编排
+func killSubProcWorkflows(parentInsId, parentInsNodeId string) {
+	operations, err := execution.KillWorkflowSubProc(context.Background(), parentInsId, parentInsNodeId, "sys")
+	if err != nil {
+		log.Logger.Error("kill sub process workflow fail", log.String("procInsId", parentInsId), log.String("procInsNodeId", parentInsNodeId), log.Error(err))
+	}
+	for _, operation := range operations {
+		go NotifyWorkflowOperation(operation)
+	}
+}
+
 func (n *WorkNode) Callback(message string) {
 	n.callbackChan <- message
 }
@@ -601,6 +908,10 @@ func updateNodeDB(n *models.ProcRunNode) {
 	} else if n.Status == models.JobStatusSuccess {
 		actions = append(actions, &db.ExecAction{Sql: "update proc_run_node set status=?,`output`=?,end_time=?,updated_time=? where id=?", Param: []interface{}{n.Status, n.Output, nowTime, nowTime, n.Id}})
 		actions = append(actions, &db.ExecAction{Sql: "update proc_ins_node set status=?,updated_time=? where id=?", Param: []interface{}{n.Status, nowTime, n.ProcInsNodeId}})
+		if n.JobType == models.JobDecisionType {
+			// 判断节点的选择结果要落库,恢复或重放时已完成的判断节点按原来的选择走
+			actions = append(actions, &db.ExecAction{Sql: "update proc_run_node set input=?,decision_link_id=? where id=?", Param: []interface{}{n.Input, n.DecisionLinkId, n.Id}})
+		}
 	} else if n.Status == models.JobStatusTimeout {
 		actions = append(actions, &db.ExecAction{Sql: "update proc_run_node set status=?,error_message=?,end_time=?,updated_time=? where id=?", Param: []interface{}{n.Status, n.ErrorMessage, nowTime, nowTime, n.Id}})
 		actions = append(actions, &db.ExecAction{Sql: "update proc_ins_node set status=?,error_msg=?,updated_time=? where id=?", Param: []interface{}{n.Status, n.ErrorMessage, nowTime, n.ProcInsNodeId}})
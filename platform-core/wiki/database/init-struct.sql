@@ -101,6 +101,55 @@ CREATE TABLE `plugin_instances` (
     CONSTRAINT `fk_plugin_instances_package` FOREIGN KEY (`package_id`) REFERENCES `plugin_packages` (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
 
+-- 插件安装计划执行纪录表
+CREATE TABLE `plugin_install_plan` (
+    `id` varchar(64) NOT NULL COMMENT '唯一标识',
+    `status` varchar(16) NOT NULL COMMENT '状态->running | done | failed',
+    `plan` mediumtext DEFAULT NULL COMMENT '计划内容json',
+    `current_item` varchar(128) DEFAULT NULL COMMENT '执行中的插件',
+    `error_message` text DEFAULT NULL COMMENT '失败原因',
+    `created_by` varchar(64) DEFAULT NULL COMMENT '创建人',
+    `created_time` datetime DEFAULT NULL COMMENT '创建时间',
+    `updated_time` datetime DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+-- 插件滚动升级纪录表
+CREATE TABLE `plugin_package_upgrade` (
+    `id` varchar(64) NOT NULL COMMENT '唯一标识',
+    `plugin_name` varchar(64) NOT NULL COMMENT '插件名',
+    `from_version` varchar(32) DEFAULT NULL COMMENT '升级前版本',
+    `to_package_id` varchar(64) NOT NULL COMMENT '目标插件包id',
+    `to_version` varchar(32) DEFAULT NULL COMMENT '目标版本',
+    `status` varchar(16) NOT NULL COMMENT '状态->preparing | launching | verifying | draining | done | rolled_back | failed',
+    `old_instances` text DEFAULT NULL COMMENT '旧实例id,逗号分隔',
+    `new_instances` text DEFAULT NULL COMMENT '新实例id,逗号分隔',
+    `error_message` text DEFAULT NULL COMMENT '失败或回滚原因',
+    `created_by` varchar(64) DEFAULT NULL COMMENT '创建人',
+    `created_time` datetime DEFAULT NULL COMMENT '创建时间',
+    `updated_time` datetime DEFAULT NULL COMMENT '更新时间',
+    `exec_host` varchar(64) DEFAULT NULL COMMENT '执行的platform实例',
+    `running_lock` varchar(64) DEFAULT NULL COMMENT '进行中时为插件名,结束后清空',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_plugin_upgrade_running` (`running_lock`),
+    KEY `idx_plugin_upgrade_name` (`plugin_name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+-- 插件实例健康状态表
+CREATE TABLE `plugin_instance_health` (
+    `instance_id` varchar(64) NOT NULL COMMENT '插件实例id',
+    `health_status` varchar(16) DEFAULT 'unknown' COMMENT '健康状态->unknown | healthy | unhealthy',
+    `consecutive_failures` int(11) DEFAULT 0 COMMENT '连续失败次数',
+    `last_check_time` datetime DEFAULT NULL COMMENT '最近检查时间',
+    `last_success_time` datetime DEFAULT NULL COMMENT '最近检查成功时间',
+    `last_error` text DEFAULT NULL COMMENT '最近失败原因',
+    `restart_times` int(11) DEFAULT 0 COMMENT '恢复健康前已自动重启次数',
+    `last_restart_time` datetime DEFAULT NULL COMMENT '最近自动重启时间',
+    `check_host` varchar(64) DEFAULT NULL COMMENT '执行检查的platform主机',
+    `updated_time` datetime DEFAULT NULL COMMENT '更新时间',
+    PRIMARY KEY (`instance_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
 CREATE TABLE `plugin_package_runtime_resources_docker` (
        `id` varchar(64) NOT NULL COMMENT '唯一标识',
        `plugin_package_id` varchar(64) NOT NULL COMMENT '插件',
@@ -109,6 +158,7 @@ CREATE TABLE `plugin_package_runtime_resources_docker` (
        `port_bindings` varchar(255) NOT NULL COMMENT '端口信息',
        `volume_bindings` varchar(1024) NOT NULL COMMENT '目录映射',
        `env_variables` text DEFAULT NULL COMMENT '容器环境变量',
+       `health_check_path` varchar(255) DEFAULT NULL COMMENT '健康检查http路径',
        PRIMARY KEY (`id`),
        CONSTRAINT `fk_plugin_rrd_package` FOREIGN KEY (`plugin_package_id`) REFERENCES `plugin_packages` (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
@@ -316,6 +366,7 @@ CREATE TABLE `proc_def` (
      `for_plugin` varchar(255) DEFAULT NULL COMMENT '授权插件',
      `scene` varchar(255) DEFAULT NULL COMMENT '使用场景',
      `conflict_check` bit(1) DEFAULT 0 COMMENT '冲突检测',
+     `sla_minutes` int(11) DEFAULT 0 COMMENT '编排实例时限分钟,0不限制',
      `created_by` varchar(64) DEFAULT NULL COMMENT '创建人',
      `created_time` datetime DEFAULT NULL COMMENT '创建时间',
      `updated_by` varchar(64) DEFAULT NULL COMMENT '更新人',
@@ -341,6 +392,13 @@ CREATE TABLE `proc_def_node` (
     `ordered_no` int(11) DEFAULT 0 COMMENT '节点顺序',
     `time_config` varchar(1024) DEFAULT NULL COMMENT '时间节点配置',
     `ui_style` text DEFAULT NULL COMMENT '前端样式',
+    `sub_proc_def_id` varchar(64) DEFAULT NULL COMMENT '子编排定义id',
+    `multi_instance` varchar(255) DEFAULT NULL COMMENT '多实例执行配置',
+    `retry_policy` varchar(1024) DEFAULT NULL COMMENT '自动重试策略',
+    `loop_config` varchar(1024) DEFAULT NULL COMMENT '循环节点配置',
+    `human_task_config` varchar(1024) DEFAULT NULL COMMENT '人工任务配置',
+    `approval_config` varchar(2048) DEFAULT NULL COMMENT '多级审批配置',
+    `expected_minutes` int(11) DEFAULT 0 COMMENT '节点预期执行分钟,0不限制',
     `created_by` varchar(64) DEFAULT NULL COMMENT '创建人',
     `created_time` datetime DEFAULT NULL COMMENT '创建时间',
     `updated_by` varchar(64) DEFAULT NULL COMMENT '更新人',
@@ -372,6 +430,9 @@ CREATE TABLE `proc_def_node_link` (
        `target` varchar(64) NOT NULL COMMENT '目标节点',
        `name` varchar(64) DEFAULT NULL COMMENT '连接名称',
        `ui_style` text DEFAULT NULL COMMENT '前端样式',
+       `link_type` varchar(32) DEFAULT NULL COMMENT '线类型->空(普通) | timeout(超时分支) | error(异常分支) | loopBack(循环回线) | loopExit(循环结束出线)',
+       `condition_expr` varchar(1024) DEFAULT NULL COMMENT '判断节点分支条件表达式',
+       `is_default` bit(1) DEFAULT 0 COMMENT '是否判断节点默认分支',
        PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
 
@@ -401,7 +462,7 @@ CREATE TABLE `batch_execution` (
     `name` varchar(128) NOT NULL COMMENT '名称',
     `batch_execution_template_id` varchar(64) DEFAULT NULL COMMENT '模板id',
     `batch_execution_template_name` varchar(128) DEFAULT NULL COMMENT '模板名称',
-    `error_code` varchar(1) NULL COMMENT '错误码, 0:成功, 1:失败, 2:执行中',
+    `error_code` varchar(1) NULL COMMENT '错误码, 0:成功, 1:失败, 2:执行中, 3:高危拦截, 4:已取消',
     `error_message` text NULL COMMENT '错误信息',
     `config_data` mediumtext NULL COMMENT '配置数据',
     `source_data` mediumtext NULL COMMENT '回显数据',
@@ -409,6 +470,7 @@ CREATE TABLE `batch_execution` (
     `updated_by` varchar(64) NULL COMMENT '更新者',
     `created_time` datetime NOT NULL COMMENT '创建时间',
     `updated_time` datetime NULL COMMENT '更新时间',
+    `exec_host` varchar(64) DEFAULT NULL COMMENT '执行的platform实例',
     PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
 CREATE INDEX batch_exec_name_IDX USING BTREE ON batch_execution (`name`);
@@ -425,7 +487,7 @@ CREATE TABLE `batch_exec_jobs` (
     `root_entity_id` varchar(64) NOT NULL COMMENT '根实体id',
     `execute_time` datetime NOT NULL COMMENT '执行时间',
     `complete_time` datetime NULL COMMENT '完成时间',
-    `error_code` varchar(1) NULL COMMENT '错误码, 0:成功, 1:失败, 2:执行中',
+    `error_code` varchar(1) NULL COMMENT '错误码, 0:成功, 1:失败, 2:执行中, 3:高危拦截, 4:已取消',
     `error_message` text NULL COMMENT '错误信息',
     `input_json` longtext NULL COMMENT '输入json',
     `return_json` longtext NULL COMMENT '输出json',
@@ -563,12 +625,15 @@ CREATE TABLE `proc_ins` (
      `entity_type_id` varchar(64) DEFAULT NULL COMMENT '根数据类型',
      `entity_data_name` varchar(64) DEFAULT NULL COMMENT '根数据名称',
      `proc_session_id` varchar(64) DEFAULT NULL COMMENT '试算session',
+     `parent_ins_id` varchar(64) DEFAULT NULL COMMENT '父编排实例id',
+     `parent_ins_node_id` varchar(64) DEFAULT NULL COMMENT '父编排实例节点id',
      `created_by` varchar(64) DEFAULT NULL COMMENT '创建人',
      `created_time` datetime DEFAULT NULL COMMENT '创建时间',
      `updated_by` varchar(64) DEFAULT NULL COMMENT '更新人',
      `updated_time` datetime DEFAULT NULL COMMENT '更新时间',
      PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE INDEX idx_proc_ins_parent_node USING BTREE ON proc_ins (parent_ins_node_id);
 
 CREATE TABLE `proc_ins_node` (
     `id` varchar(64) NOT NULL COMMENT '唯一标识',
@@ -596,6 +661,8 @@ CREATE TABLE `proc_ins_node_req` (
      `error_msg` text DEFAULT NULL COMMENT '错误信息',
      `with_context_data` bit(1) DEFAULT 0 COMMENT '是否有上下文数据',
      `req_data_amount` int(11) DEFAULT NULL COMMENT '有多少组数据',
+     `entity_data_id` varchar(64) DEFAULT NULL COMMENT '多实例执行时对应的数据id',
+     `retry_attempt` int(11) DEFAULT 0 COMMENT '自动重试第几次执行',
      `created_time` datetime DEFAULT NULL COMMENT '创建时间',
      `updated_time` datetime DEFAULT NULL COMMENT '更新时间',
      PRIMARY KEY (`id`)
@@ -621,6 +688,50 @@ CREATE TABLE `proc_ins_node_req_param` (
      PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
 
+-- 编排时限超时纪录表,纪录超过时限的编排实例和节点
+CREATE TABLE `proc_ins_sla_breach` (
+    `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增id',
+    `breach_type` varchar(16) NOT NULL COMMENT '超时类型->instance(编排实例) | node(节点)',
+    `proc_def_id` varchar(64) NOT NULL COMMENT '编排定义id',
+    `proc_ins_id` varchar(64) NOT NULL COMMENT '编排实例id',
+    `proc_ins_node_id` varchar(64) NOT NULL DEFAULT '' COMMENT '编排节点id,实例超时为空',
+    `node_name` varchar(64) DEFAULT NULL COMMENT '节点名称',
+    `expected_minutes` int(11) DEFAULT 0 COMMENT '时限分钟',
+    `start_time` datetime NOT NULL COMMENT '开始时间',
+    `breach_time` datetime DEFAULT NULL COMMENT '发现超时的时间',
+    `notify_status` varchar(16) DEFAULT NULL COMMENT '通知状态->done(已发送) | fail(发送失败)',
+    `notify_message` text DEFAULT NULL COMMENT '通知结果',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_sla_breach_run` (`proc_ins_id`,`proc_ins_node_id`,`start_time`),
+    KEY `idx_sla_breach_def` (`proc_def_id`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+-- 编排执行纪录全文检索倒排索引表,按节点结束增量写入
+CREATE TABLE `proc_ins_search_index` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增id',
+    `token` varchar(128) NOT NULL COMMENT '分词',
+    `doc_key` varchar(96) NOT NULL COMMENT '索引文档->ins_编排实例id | node_编排节点id',
+    `proc_ins_id` varchar(64) NOT NULL COMMENT '编排实例id',
+    `proc_ins_node_id` varchar(64) NOT NULL DEFAULT '' COMMENT '编排节点id,编排数据为空',
+    `source` varchar(16) NOT NULL COMMENT '来源->entity(编排数据) | error(报错信息) | input(插件输入) | output(插件输出)',
+    `field` varchar(255) DEFAULT NULL COMMENT '数据类型或参数名',
+    `snippet` varchar(255) DEFAULT NULL COMMENT '原文片段',
+    `created_time` datetime DEFAULT NULL COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_search_index_token` (`token`,`proc_ins_id`),
+    KEY `idx_search_index_doc` (`doc_key`),
+    KEY `idx_search_index_ins` (`proc_ins_id`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+-- 编排执行纪录全文检索已建索引的文档
+CREATE TABLE `proc_ins_search_doc` (
+    `doc_key` varchar(96) NOT NULL COMMENT '索引文档->ins_编排实例id | node_编排节点id',
+    `proc_ins_id` varchar(64) NOT NULL COMMENT '编排实例id',
+    `created_time` datetime DEFAULT NULL COMMENT '建索引时间',
+    PRIMARY KEY (`doc_key`),
+    KEY `idx_search_doc_ins` (`proc_ins_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
 -- 工作流表
 CREATE TABLE `proc_run_workflow` (
      `id` varchar(64) NOT NULL COMMENT '唯一标识',
@@ -650,6 +761,7 @@ CREATE TABLE `proc_run_node` (
      `tmp_data` text DEFAULT NULL COMMENT '临时数据',
      `error_message` text DEFAULT NULL COMMENT '错误信息',
      `timeout` int(11) DEFAULT 0 COMMENT '超时时间',
+     `decision_link_id` varchar(64) DEFAULT NULL COMMENT '判断节点按分支条件选中的线',
      `created_time` datetime DEFAULT NULL COMMENT '创建时间',
      `updated_time` datetime DEFAULT NULL COMMENT '更新时间',
      `start_time` datetime DEFAULT NULL COMMENT '开始时间',
@@ -665,6 +777,9 @@ CREATE TABLE `proc_run_link` (
      `name` varchar(64) DEFAULT NULL COMMENT '名称',
      `source` varchar(64) NOT NULL COMMENT '源',
      `target` varchar(64) NOT NULL COMMENT '目标',
+     `link_type` varchar(32) DEFAULT NULL COMMENT '线类型->空(普通) | timeout(超时分支) | error(异常分支) | loopBack(循环回线) | loopExit(循环结束出线)',
+     `condition_expr` varchar(1024) DEFAULT NULL COMMENT '判断节点分支条件表达式',
+     `is_default` bit(1) DEFAULT 0 COMMENT '是否判断节点默认分支',
      PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
 
@@ -673,21 +788,106 @@ CREATE TABLE `proc_run_work_record` (
     `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增id',
     `workflow_id` varchar(64) NOT NULL COMMENT '工作流id',
     `host` varchar(64) DEFAULT NULL COMMENT '主机',
-    `action` varchar(32) DEFAULT NULL COMMENT '状态->NotStarted(初始化) | InProgress(运行中) | Faulted(失败) | Completed(成功) | kill(终止) | sleep(休眠) | takeOver(接管) | stop(暂停) | recover(恢复)',
+    `action` varchar(32) DEFAULT NULL COMMENT '状态->NotStarted(初始化) | InProgress(运行中) | Faulted(失败) | Completed(成功) | kill(终止) | sleep(休眠) | takeOver(接管) | stop(暂停) | recover(恢复) | replay(重放)',
     `message` text DEFAULT NULL COMMENT '详细信息,终止原因等',
     `created_by` varchar(64) DEFAULT NULL COMMENT '创建人',
     `created_time` datetime DEFAULT NULL COMMENT '创建时间',
     PRIMARY KEY (`id`)
 ) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
 
+-- 节点快照表,任务节点开始执行前的编排数据缓存,用于从节点重放
+CREATE TABLE `proc_run_checkpoint` (
+    `proc_run_node_id` varchar(64) NOT NULL COMMENT '任务节点id',
+    `workflow_id` varchar(64) NOT NULL COMMENT '工作流id',
+    `data_cache` mediumtext DEFAULT NULL COMMENT '编排数据缓存快照',
+    `created_time` datetime DEFAULT NULL COMMENT '创建时间',
+    PRIMARY KEY (`proc_run_node_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+-- 循环迭代纪录表,循环节点每轮结束后归档循环体内任务节点的执行结果
+CREATE TABLE `proc_run_node_iteration` (
+    `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增id',
+    `loop_node_id` varchar(64) NOT NULL COMMENT '循环任务节点id',
+    `iteration` int(11) NOT NULL COMMENT '第几轮',
+    `proc_run_node_id` varchar(64) NOT NULL COMMENT '循环体任务节点id',
+    `proc_ins_node_id` varchar(64) DEFAULT NULL COMMENT '编排节点id',
+    `status` varchar(32) DEFAULT NULL COMMENT '状态',
+    `input` text DEFAULT NULL COMMENT '输入',
+    `output` text DEFAULT NULL COMMENT '输出',
+    `error_message` text DEFAULT NULL COMMENT '错误信息',
+    `start_time` datetime DEFAULT NULL COMMENT '开始时间',
+    `end_time` datetime DEFAULT NULL COMMENT '结束时间',
+    `created_time` datetime DEFAULT NULL COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_run_node_iteration_loop` (`loop_node_id`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+-- 人工任务表,纪录人工节点任务的处理人、时限和升级状态
+CREATE TABLE `proc_run_human_task` (
+    `id` varchar(64) NOT NULL COMMENT '唯一标识',
+    `proc_run_node_id` varchar(64) NOT NULL COMMENT '任务节点id',
+    `proc_ins_node_id` varchar(64) DEFAULT NULL COMMENT '编排节点id',
+    `proc_ins_id` varchar(64) DEFAULT NULL COMMENT '编排实例id',
+    `assignee_type` varchar(16) DEFAULT NULL COMMENT '处理人类型->role(角色) | user(用户)',
+    `assignee` varchar(64) DEFAULT NULL COMMENT '处理角色或用户',
+    `delegate_from` varchar(64) DEFAULT NULL COMMENT '委托人',
+    `status` varchar(16) NOT NULL COMMENT '状态->pending(待处理) | done(已处理)',
+    `due_time` datetime DEFAULT NULL COMMENT '处理时限',
+    `escalate_time` datetime DEFAULT NULL COMMENT '升级时间',
+    `escalation_role` varchar(64) DEFAULT NULL COMMENT '升级后的处理角色',
+    `escalated` bit(1) DEFAULT 0 COMMENT '是否已升级',
+    `remind_minutes` int(11) DEFAULT 0 COMMENT '提醒邮件间隔分钟',
+    `last_remind_time` datetime DEFAULT NULL COMMENT '上次提醒时间',
+    `created_time` datetime DEFAULT NULL COMMENT '创建时间',
+    `updated_time` datetime DEFAULT NULL COMMENT '更新时间',
+    `completed_time` datetime DEFAULT NULL COMMENT '完成时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_human_task_run_node` (`proc_run_node_id`),
+    KEY `idx_human_task_assignee` (`status`,`assignee`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+-- 人工任务处理纪录表
+CREATE TABLE `proc_run_human_task_log` (
+    `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增id',
+    `task_id` varchar(64) NOT NULL COMMENT '人工任务id',
+    `action` varchar(32) NOT NULL COMMENT '动作->create(创建) | reassign(转派) | delegate(委托) | escalate(升级) | remind(提醒) | complete(完成)',
+    `from_assignee` varchar(64) DEFAULT NULL COMMENT '原处理人',
+    `to_assignee` varchar(64) DEFAULT NULL COMMENT '新处理人',
+    `message` text DEFAULT NULL COMMENT '说明',
+    `created_by` varchar(64) DEFAULT NULL COMMENT '操作人',
+    `created_time` datetime DEFAULT NULL COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_human_task_log_task` (`task_id`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+-- 审批投票表,纪录多级审批节点每个阶段的投票
+CREATE TABLE `proc_run_approval_vote` (
+    `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增id',
+    `proc_run_node_id` varchar(64) NOT NULL COMMENT '任务节点id',
+    `proc_ins_node_id` varchar(64) DEFAULT NULL COMMENT '编排节点id',
+    `proc_ins_id` varchar(64) DEFAULT NULL COMMENT '编排实例id',
+    `stage` int(11) NOT NULL COMMENT '阶段序号,从0开始',
+    `stage_name` varchar(255) DEFAULT NULL COMMENT '阶段名称',
+    `voter` varchar(64) NOT NULL COMMENT '投票人',
+    `role` varchar(64) DEFAULT NULL COMMENT '投票人代表的角色',
+    `decision` varchar(16) NOT NULL COMMENT '投票->approve(通过) | reject(驳回)',
+    `comment` text DEFAULT NULL COMMENT '意见',
+    `run_start_time` datetime NOT NULL COMMENT '任务节点本次运行开始时间,循环或重放重跑时区分每次运行',
+    `created_time` datetime DEFAULT NULL COMMENT '投票时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_approval_vote_voter` (`proc_run_node_id`,`run_start_time`,`stage`,`voter`),
+    KEY `idx_approval_vote_run_node` (`proc_run_node_id`),
+    KEY `idx_approval_vote_ins_node` (`proc_ins_node_id`)
+) ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
 -- 工作流操作表,纪录所有工作流的外部事件
 CREATE TABLE `proc_run_operation` (
       `id` int(11) NOT NULL AUTO_INCREMENT COMMENT '自增id',
       `workflow_id` varchar(64) NOT NULL COMMENT '工作流id',
       `node_id` varchar(64) DEFAULT NULL COMMENT '节点id',
-      `operation` varchar(64) NOT NULL COMMENT '操作->kill(终止工作流) | retry(重试节点) | continue(跳过节点) | approve(人工审批) | date(定期触发)',
-      `status` varchar(32) DEFAULT NULL COMMENT '状态->wait(待处理) | done(已处理)',
-      `message` text DEFAULT NULL COMMENT '详细信息->审批结果,终止原因等',
+      `operation` varchar(64) NOT NULL COMMENT '操作->kill(终止工作流) | retry(重试节点) | continue(跳过节点) | approve(人工审批) | date(定期触发) | replay(从节点重放)',
+      `status` varchar(32) DEFAULT NULL COMMENT '状态->wait(待处理) | done(已处理) | fail(处理失败)',
+      `message` text DEFAULT NULL COMMENT '详细信息->审批结果,终止原因,处理失败原因等',
       `created_by` varchar(64) DEFAULT NULL COMMENT '创建人',
       `created_time` datetime DEFAULT NULL COMMENT '创建时间',
       `handle_by` varchar(64) DEFAULT NULL COMMENT '处理的主机',
@@ -738,6 +938,44 @@ CREATE INDEX idx_proc_schedule_job_config USING BTREE ON proc_schedule_job (sche
 CREATE INDEX idx_proc_schedule_job_status USING BTREE ON proc_schedule_job (status);
 CREATE INDEX idx_proc_schedule_job_time USING BTREE ON proc_schedule_job (created_time);
 
+CREATE TABLE `batch_exec_schedule_config` (
+      `id` varchar(64) NOT NULL COMMENT '唯一标识',
+      `batch_execution_template_id` varchar(64) NOT NULL COMMENT '批量执行模板id',
+      `batch_execution_template_name` varchar(255) DEFAULT NULL COMMENT '批量执行模板名称',
+      `entity_filters` text DEFAULT NULL COMMENT '每次执行时查询目标实体的过滤条件json',
+      `status` varchar(32) DEFAULT NULL COMMENT '状态->Ready(正在运行) | Stopped(暂停) | Deleted(删除)',
+      `schedule_mode` varchar(64) DEFAULT NULL COMMENT '定时模式->Monthly(每月) | Weekly(每周) | Daily(每天) | Hourly(每小时)',
+      `schedule_expr` varchar(64) DEFAULT NULL COMMENT '时间表达式',
+      `cron_expr` varchar(64) DEFAULT NULL COMMENT 'cron表达式',
+      `exec_times` int(11) DEFAULT 0 COMMENT '执行次数',
+      `role` varchar(64) DEFAULT NULL COMMENT '管理角色',
+      `mail_mode` varchar(64) DEFAULT NULL COMMENT '邮件发送模式->role(角色邮箱) | user(用户邮箱) | none(不发送)',
+      `created_by` varchar(64) DEFAULT NULL COMMENT '创建人',
+      `created_time` datetime DEFAULT NULL COMMENT '创建时间',
+      `updated_by` varchar(64) DEFAULT NULL COMMENT '更新人',
+      `updated_time` datetime DEFAULT NULL COMMENT '更新时间',
+      PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE INDEX idx_batch_exec_schedule_config_template USING BTREE ON batch_exec_schedule_config (batch_execution_template_id);
+CREATE INDEX idx_batch_exec_schedule_config_status USING BTREE ON batch_exec_schedule_config (status);
+
+CREATE TABLE `batch_exec_schedule_job` (
+      `id` varchar(96) NOT NULL COMMENT '定时配置id加时间戳',
+      `schedule_config_id` varchar(64) NOT NULL COMMENT '定时配置id',
+      `batch_execution_id` varchar(64) DEFAULT NULL COMMENT '批量执行id',
+      `entity_num` int(11) DEFAULT 0 COMMENT '本次查到的目标实体数',
+      `status` varchar(32) DEFAULT NULL COMMENT '状态->ready(准备启动) | fail(报错) | done(已启动)',
+      `handle_by` varchar(64) DEFAULT NULL COMMENT '处理的主机',
+      `error_msg` text DEFAULT NULL COMMENT '错误信息',
+      `mail_status` varchar(32) DEFAULT NULL COMMENT '邮件状态->none(不发邮件) | wait(等待发) | sending(正在发) | fail(发送失败) | done(已发送)',
+      `mail_msg` text DEFAULT NULL COMMENT '邮件通知信息',
+      `created_time` datetime DEFAULT NULL COMMENT '创建时间',
+      `updated_time` datetime DEFAULT NULL COMMENT '更新时间',
+      PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE INDEX idx_batch_exec_schedule_job_config USING BTREE ON batch_exec_schedule_job (schedule_config_id);
+CREATE INDEX idx_batch_exec_schedule_job_mail USING BTREE ON batch_exec_schedule_job (mail_status);
+
 CREATE INDEX idx_sys_var_name USING BTREE ON system_variables (`name`);
 CREATE INDEX idx_sys_var_scope USING BTREE ON system_variables (`scope`);
 CREATE INDEX idx_sys_var_source USING BTREE ON system_variables (`source`(128));
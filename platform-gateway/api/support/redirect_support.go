@@ -5,9 +5,11 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
+	"strconv"
 	"strings"
 
 	"github.com/WeBankPartners/wecube-platform/platform-gateway/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-gateway/common/trace"
 	"github.com/WeBankPartners/wecube-platform/platform-gateway/model"
 	"github.com/gin-gonic/gin"
 )
@@ -44,6 +46,13 @@ func (invoke RedirectInvoke) Do(c *gin.Context) error {
 	newRequest.URL.RawQuery = cloneRequest.URL.RawQuery
 	//auth.SetRequestSourceAuth(newRequest, config.Config.Auth.Source.AppId, config.Config.Auth.Source.PrivateKeyBytes)
 
+	// 以上游的traceparent为父节点记录转发span,并把新的traceparent传给下游
+	_, span := trace.StartServerSpan(c.Request.Context(), c.Request.Header, fmt.Sprintf("%s %s", newRequest.Method, newRequest.URL.Path))
+	if span != nil {
+		span.SetAttribute("http.method", newRequest.Method)
+		span.SetAttribute("http.url", invoke.TargetUrl)
+		newRequest.Header.Set(trace.TraceparentHeader, span.Traceparent())
+	}
 	client := &http.Client{}
 	if strings.EqualFold(model.Config.Log.Level, "debug") {
 		requestDump, _ := httputil.DumpRequest(newRequest, true)
@@ -52,8 +61,11 @@ func (invoke RedirectInvoke) Do(c *gin.Context) error {
 	log.Logger.Debug(fmt.Sprintf("Sending request to downstream system: [Method: %s] [URL: %s]", newRequest.Method, invoke.TargetUrl))
 
 	if response, err := client.Do(newRequest); err != nil {
+		span.End(err)
 		return err
 	} else {
+		span.SetAttribute("http.status_code", strconv.Itoa(response.StatusCode))
+		span.End(nil)
 		respBody, _ := ioutil.ReadAll(response.Body)
 		defer response.Body.Close()
 
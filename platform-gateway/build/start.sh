@@ -5,5 +5,8 @@ sed -i "s~\[#GATEWAY_ROUTE_CONFIG_SERVER\]~$GATEWAY_ROUTE_CONFIG_SERVER~g" /app/
 sed -i "s~\[#GATEWAY_ROUTE_CONFIG_URI\]~$GATEWAY_ROUTE_CONFIG_URI~g" /app/platform-gateway/config/default.json
 sed -i "s~\[#WECUBE_CORE_ADDRESS\]~$WECUBE_CORE_ADDRESS~g" /app/platform-gateway/config/default.json
 sed -i "s~\[#AUTH_SERVER_ADDRESS\]~$AUTH_SERVER_ADDRESS~g" /app/platform-gateway/config/default.json
+sed -i "s~\[#TRACE_ENABLE\]~${TRACE_ENABLE:-false}~g" /app/platform-gateway/config/default.json
+sed -i "s~\[#TRACE_EXPORTER\]~$TRACE_EXPORTER~g" /app/platform-gateway/config/default.json
+sed -i "s~\[#TRACE_OTLP_ENDPOINT\]~$TRACE_OTLP_ENDPOINT~g" /app/platform-gateway/config/default.json
 
 exec ./platform-gateway
\ No newline at end of file
@@ -15,3 +15,6 @@ services:
       - GATEWAY_ROUTE_CONFIG_URI=[#GATEWAY_ROUTE_CONFIG_URI]
       - WECUBE_CORE_ADDRESS=[#WECUBE_CORE_ADDRESS]
       - AUTH_SERVER_ADDRESS=[#AUTH_SERVER_ADDRESS]
+      - TRACE_ENABLE=false
+      - TRACE_EXPORTER=otlp
+      - TRACE_OTLP_ENDPOINT=
@@ -6,6 +6,7 @@ import (
 	sw "github.com/WeBankPartners/wecube-platform/platform-gateway/api"
 	"github.com/WeBankPartners/wecube-platform/platform-gateway/api/middleware"
 	"github.com/WeBankPartners/wecube-platform/platform-gateway/common/log"
+	"github.com/WeBankPartners/wecube-platform/platform-gateway/common/trace"
 	"github.com/WeBankPartners/wecube-platform/platform-gateway/model"
 	"github.com/WeBankPartners/wecube-platform/platform-gateway/service"
 )
@@ -19,6 +20,7 @@ func main() {
 	}
 
 	log.InitLogger()
+	trace.InitTracer()
 	middleware.Init()
 
 	if err := service.Init(); err != nil {
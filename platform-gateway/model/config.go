@@ -39,6 +39,14 @@ type RedirectRoute struct {
 	TargetPath string `json:"target_path"`
 }
 
+type TraceConfig struct {
+	Enable      bool   `json:"enable"`
+	Exporter    string `json:"exporter"`     // 导出方式->otlp | file | stdout
+	Endpoint    string `json:"endpoint"`     // otlp http 地址,如 http://127.0.0.1:4318
+	FilePath    string `json:"file_path"`    // file 方式的导出文件
+	ServiceName string `json:"service_name"` // 服务名,默认 platform-gateway
+}
+
 type GlobalConfig struct {
 	ServerAddress  string              `json:"server_address"`
 	ServerPort     string              `json:"server_port"`
@@ -46,6 +54,7 @@ type GlobalConfig struct {
 	Database       DatabaseConfig      `json:"database"`
 	Remote         RemoteServiceConfig `json:"remote_service"`
 	RedirectRoutes []RedirectRoute     `json:"redirect_routes"`
+	Trace          *TraceConfig        `json:"trace"`
 }
 
 var (